@@ -434,6 +434,10 @@ benchtest: $(JUNIT_REPORT) ## Runs all benchmarks
 report-benchtest:
 	prow/benchtest.sh report
 
+.PHONY: perftest.endpoints
+perftest.endpoints: ## Checks the endpoint builder benchmarks against their recorded baselines
+	go test ${GOBUILDFLAGS} -tags=perftest -count=1 -run TestEndpointBuilderPerfGate ./pilot/pkg/xds/endpoints/
+
 #-----------------------------------------------------------------------------
 # Target: clean
 #-----------------------------------------------------------------------------
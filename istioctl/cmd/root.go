@@ -33,6 +33,9 @@ import (
 	"istio.io/istio/istioctl/pkg/config"
 	"istio.io/istio/istioctl/pkg/dashboard"
 	"istio.io/istio/istioctl/pkg/describe"
+	"istio.io/istio/istioctl/pkg/edsdiff"
+	"istio.io/istio/istioctl/pkg/endpointrefs"
+	"istio.io/istio/istioctl/pkg/endpointwatch"
 	"istio.io/istio/istioctl/pkg/injector"
 	"istio.io/istio/istioctl/pkg/install"
 	"istio.io/istio/istioctl/pkg/internaldebug"
@@ -211,6 +214,9 @@ debug and diagnose their Istio mesh.
 	experimentalCmd.AddCommand(proxyconfig.StatsConfigCmd(ctx))
 	experimentalCmd.AddCommand(checkinject.Cmd(ctx))
 	experimentalCmd.AddCommand(waypoint.Cmd(ctx))
+	experimentalCmd.AddCommand(endpointwatch.Cmd(ctx))
+	experimentalCmd.AddCommand(edsdiff.Cmd(ctx))
+	experimentalCmd.AddCommand(endpointrefs.Cmd(ctx))
 
 	analyzeCmd := analyze.Analyze(ctx)
 	hideInheritedFlags(analyzeCmd, cli.FlagIstioNamespace)
@@ -0,0 +1,267 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edsdiff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/proto"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/istioctl/pkg/clioptions"
+	"istio.io/istio/istioctl/pkg/multixds"
+	"istio.io/istio/istioctl/pkg/util"
+	"istio.io/istio/istioctl/pkg/util/handlers"
+	pilot_util "istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/xds/endpoints"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/util/protomarshal"
+)
+
+// Cmd returns the eds-diff command, which compares the endpoints two Istiod revisions send to a proxy.
+func Cmd(ctx cli.Context) *cobra.Command {
+	var opts clioptions.ControlPlaneOptions
+	var centralOpts clioptions.CentralControlPlaneOptions
+	var compareRevision string
+
+	cmd := &cobra.Command{
+		Use:   "eds-diff <pod-name>[.<namespace>]",
+		Short: "Compares the endpoints two Istiod revisions build for a proxy",
+		Long: `
+Builds the ClusterLoadAssignments of the services visible to a proxy with two Istiod revisions, in their canonical
+form, and prints their differences. Revisions serving the same endpoints should build identical assignments, so
+differences show the EDS churn the proxy would see when migrating from one revision to the other.
+The proxy does not need to be connected to either revision. The command fails if the assignments differ.
+`,
+		Example: `  # Compare the endpoints of the default revision and the canary revision for a pod
+  istioctl x eds-diff productpage-v1-c7765c886-7zzd4.default --compare-revision canary
+
+  # Compare two explicit revisions
+  istioctl x eds-diff productpage-v1-c7765c886-7zzd4.default --revision 1-22 --compare-revision 1-23
+`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return util.CommandParseError{Err: fmt.Errorf("expected a single pod name")}
+			}
+			if compareRevision == opts.Revision {
+				return util.CommandParseError{Err: fmt.Errorf("--compare-revision must differ from --revision")}
+			}
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			kubeClient, err := ctx.CLIClientWithRevision(opts.Revision)
+			if err != nil {
+				return err
+			}
+			podName, ns := handlers.InferPodInfo(args[0], ctx.NamespaceOrDefault(ctx.Namespace()))
+			pod, err := kubeClient.Kube().CoreV1().Pods(ns).Get(context.TODO(), podName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if pod.Status.PodIP == "" {
+				return fmt.Errorf("pod %s.%s has no address", podName, ns)
+			}
+			resource := edszResource(pod)
+
+			assignments := make([]map[string]*endpoint.ClusterLoadAssignment, 0, 2)
+			for _, revision := range []string{opts.Revision, compareRevision} {
+				revisionClient, err := ctx.CLIClientWithRevision(revision)
+				if err != nil {
+					return err
+				}
+				xdsRequest := discovery.DiscoveryRequest{
+					ResourceNames: []string{resource},
+					Node: &core.Node{
+						Id: "debug~0.0.0.0~istioctl~cluster.local",
+					},
+					TypeUrl: v3.DebugType,
+				}
+				xdsResponses, err := multixds.FirstRequestAndProcessXds(&xdsRequest, centralOpts, ctx.IstioNamespace(),
+					"", "", revisionClient, multixds.DefaultOptions)
+				if err != nil {
+					return err
+				}
+				clas, err := parseEdsz(xdsResponses)
+				if err != nil {
+					return fmt.Errorf("revision %s: %v", revisionName(revision), err)
+				}
+				assignments = append(assignments, clas)
+			}
+
+			diffs := compare(assignments[0], assignments[1])
+			return report(c.OutOrStdout(), diffs, len(assignments[0]), revisionName(opts.Revision), revisionName(compareRevision))
+		},
+	}
+
+	opts.AttachControlPlaneFlags(cmd)
+	centralOpts.AttachControlPlaneFlags(cmd)
+	cmd.PersistentFlags().StringVar(&compareRevision, "compare-revision", "",
+		"Control plane revision to compare with the revision given by --revision")
+	cmd.Long += "\n\n" + util.ExperimentalMsg
+	return cmd
+}
+
+// edszResource is the debug request for the canonical endpoints of the pod. The address, cluster and network of
+// the pod let revisions it is not connected to build its endpoints.
+func edszResource(pod *corev1.Pod) string {
+	q := url.Values{}
+	q.Set("proxyID", pod.Name+"."+pod.Namespace)
+	q.Set("ip", pod.Status.PodIP)
+	for _, c := range pod.Spec.Containers {
+		for _, e := range c.Env {
+			switch e.Name {
+			case "ISTIO_META_CLUSTER_ID":
+				q.Set("cluster", e.Value)
+			case "ISTIO_META_NETWORK":
+				q.Set("network", e.Value)
+			}
+		}
+	}
+	return "edsz?canonical&" + q.Encode()
+}
+
+func revisionName(revision string) string {
+	if revision == "" {
+		return "default"
+	}
+	return revision
+}
+
+// parseEdsz returns the ClusterLoadAssignments served by the edsz debug endpoint, by cluster name.
+func parseEdsz(responses map[string]*discovery.DiscoveryResponse) (map[string]*endpoint.ClusterLoadAssignment, error) {
+	for _, response := range responses {
+		for _, resource := range response.Resources {
+			var raw []json.RawMessage
+			if err := json.Unmarshal(resource.Value, &raw); err != nil {
+				return nil, fmt.Errorf("unexpected response from Istiod: %s", strings.TrimSpace(string(resource.Value)))
+			}
+			out := make(map[string]*endpoint.ClusterLoadAssignment, len(raw))
+			for _, r := range raw {
+				cla := &endpoint.ClusterLoadAssignment{}
+				if err := protomarshal.Unmarshal(r, cla); err != nil {
+					return nil, fmt.Errorf("failed to parse endpoints from Istiod: %v", err)
+				}
+				// Older revisions do not canonicalize the endpoints.
+				out[cla.ClusterName] = endpoints.CanonicalizeClusterLoadAssignment(cla)
+			}
+			return out, nil
+		}
+	}
+	return nil, fmt.Errorf("no endpoints were returned by Istiod")
+}
+
+// compare returns the differences between the ClusterLoadAssignments of two revisions, sorted.
+func compare(a, b map[string]*endpoint.ClusterLoadAssignment) []string {
+	var diffs []string
+	for name, claA := range a {
+		claB, ok := b[name]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("%s: only built by the first revision", name))
+			continue
+		}
+		diffs = append(diffs, compareCluster(name, claA, claB)...)
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s: only built by the second revision", name))
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
+
+func compareCluster(name string, a, b *endpoint.ClusterLoadAssignment) []string {
+	if proto.Equal(a, b) {
+		return nil
+	}
+	epsA, epsB := endpointsByKey(a), endpointsByKey(b)
+	var diffs []string
+	for key, lbA := range epsA {
+		lbB, ok := epsB[key]
+		switch {
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("%s: endpoint %s only sent by the first revision", name, key))
+		case len(lbA) != len(lbB):
+			diffs = append(diffs, fmt.Sprintf("%s: endpoint %s sent %d times by the first revision and %d times by the second",
+				name, key, len(lbA), len(lbB)))
+		default:
+			for i := range lbA {
+				if !proto.Equal(lbA[i], lbB[i]) {
+					diffs = append(diffs, fmt.Sprintf("%s: endpoint %s differs", name, key))
+					break
+				}
+			}
+		}
+	}
+	for key := range epsB {
+		if _, ok := epsA[key]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s: endpoint %s only sent by the second revision", name, key))
+		}
+	}
+	if len(diffs) == 0 {
+		// The endpoints are the same, so the policy or the weights of the localities differ.
+		diffs = append(diffs, fmt.Sprintf("%s: load balancing policy or locality weights differ", name))
+	}
+	return diffs
+}
+
+// endpointsByKey groups the endpoints of a ClusterLoadAssignment by priority, locality and address.
+func endpointsByKey(cla *endpoint.ClusterLoadAssignment) map[string][]*endpoint.LbEndpoint {
+	out := map[string][]*endpoint.LbEndpoint{}
+	for _, llb := range cla.Endpoints {
+		for _, lbEp := range llb.LbEndpoints {
+			key := fmt.Sprintf("%s (priority %d, locality %q)", addressString(lbEp.GetEndpoint().GetAddress()),
+				llb.Priority, pilot_util.LocalityToString(llb.Locality))
+			out[key] = append(out[key], lbEp)
+		}
+	}
+	return out
+}
+
+func addressString(addr *core.Address) string {
+	switch a := addr.GetAddress().(type) {
+	case *core.Address_SocketAddress:
+		return fmt.Sprintf("%s:%d", a.SocketAddress.GetAddress(), a.SocketAddress.GetPortValue())
+	case *core.Address_Pipe:
+		return a.Pipe.GetPath()
+	case *core.Address_EnvoyInternalAddress:
+		return a.EnvoyInternalAddress.GetServerListenerName() + "/" + a.EnvoyInternalAddress.GetEndpointId()
+	}
+	return "<unknown>"
+}
+
+func report(out io.Writer, diffs []string, clusters int, revisionA, revisionB string) error {
+	if len(diffs) == 0 {
+		_, _ = fmt.Fprintf(out, "Revisions %s and %s build identical endpoints for %d clusters\n", revisionA, revisionB, clusters)
+		return nil
+	}
+	_, _ = fmt.Fprintf(out, "Differences between revisions %s (first) and %s (second):\n", revisionA, revisionB)
+	for _, d := range diffs {
+		_, _ = fmt.Fprintf(out, "  %s\n", d)
+	}
+	return fmt.Errorf("revisions %s and %s build different endpoints", revisionA, revisionB)
+}
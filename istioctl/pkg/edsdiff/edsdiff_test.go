@@ -0,0 +1,128 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edsdiff
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+const reviews = "outbound|9080||reviews.default.svc.cluster.local"
+
+func cla(name string, addresses ...string) *endpoint.ClusterLoadAssignment {
+	llb := &endpoint.LocalityLbEndpoints{Locality: &core.Locality{Region: "us-east"}}
+	for _, addr := range addresses {
+		llb.LbEndpoints = append(llb.LbEndpoints, &endpoint.LbEndpoint{
+			HostIdentifier: &endpoint.LbEndpoint_Endpoint{Endpoint: &endpoint.Endpoint{Address: util.BuildAddress(addr, 9080)}},
+		})
+	}
+	return &endpoint.ClusterLoadAssignment{ClusterName: name, Endpoints: []*endpoint.LocalityLbEndpoints{llb}}
+}
+
+func TestCompare(t *testing.T) {
+	a := map[string]*endpoint.ClusterLoadAssignment{
+		reviews: cla(reviews, "10.0.0.1", "10.0.0.2"),
+		"outbound|80||httpbin.default.svc.cluster.local": cla("outbound|80||httpbin.default.svc.cluster.local", "10.0.1.1"),
+	}
+	same := map[string]*endpoint.ClusterLoadAssignment{
+		reviews: cla(reviews, "10.0.0.1", "10.0.0.2"),
+		"outbound|80||httpbin.default.svc.cluster.local": cla("outbound|80||httpbin.default.svc.cluster.local", "10.0.1.1"),
+	}
+	assert.Equal(t, len(compare(a, same)), 0)
+
+	weighted := cla(reviews, "10.0.0.1", "10.0.0.3")
+	weighted.Endpoints[0].LbEndpoints[0].LoadBalancingWeight = wrapperspb.UInt32(2)
+	b := map[string]*endpoint.ClusterLoadAssignment{
+		reviews: weighted,
+		"outbound|80||ratings.default.svc.cluster.local": cla("outbound|80||ratings.default.svc.cluster.local", "10.0.2.1"),
+	}
+	assert.Equal(t, compare(a, b), []string{
+		"outbound|80||httpbin.default.svc.cluster.local: only built by the first revision",
+		"outbound|80||ratings.default.svc.cluster.local: only built by the second revision",
+		reviews + `: endpoint 10.0.0.1:9080 (priority 0, locality "us-east") differs`,
+		reviews + `: endpoint 10.0.0.2:9080 (priority 0, locality "us-east") only sent by the first revision`,
+		reviews + `: endpoint 10.0.0.3:9080 (priority 0, locality "us-east") only sent by the second revision`,
+	})
+
+	policy := cla(reviews, "10.0.0.1", "10.0.0.2")
+	policy.Policy = &endpoint.ClusterLoadAssignment_Policy{OverprovisioningFactor: wrapperspb.UInt32(200)}
+	assert.Equal(t, compareCluster(reviews, a[reviews], policy), []string{
+		reviews + ": load balancing policy or locality weights differ",
+	})
+}
+
+func TestParseEdsz(t *testing.T) {
+	response := func(value string) map[string]*discovery.DiscoveryResponse {
+		return map[string]*discovery.DiscoveryResponse{
+			"istiod": {Resources: []*anypb.Any{{Value: []byte(value)}}},
+		}
+	}
+	clas, err := parseEdsz(response(`[{"clusterName":"` + reviews + `","endpoints":[{"lbEndpoints":[` +
+		`{"endpoint":{"address":{"socketAddress":{"address":"10.0.0.2","portValue":9080}}}},` +
+		`{"endpoint":{"address":{"socketAddress":{"address":"10.0.0.1","portValue":9080}}}}]}]}]`))
+	assert.NoError(t, err)
+	// Responses of revisions which do not canonicalize the endpoints are canonicalized.
+	assert.Equal(t, clas[reviews].Endpoints[0].LbEndpoints[0].GetEndpoint().GetAddress().GetSocketAddress().GetAddress(), "10.0.0.1")
+
+	_, err = parseEdsz(response("Proxy not connected to this Pilot instance.\n"))
+	assert.Error(t, err)
+}
+
+func TestEdszResource(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "reviews-v1", Namespace: "default"},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "istio-proxy",
+			Env:  []corev1.EnvVar{{Name: "ISTIO_META_CLUSTER_ID", Value: "cluster1"}, {Name: "ISTIO_META_NETWORK", Value: "network1"}},
+		}}},
+		Status: corev1.PodStatus{PodIP: "10.0.0.1"},
+	}
+	resource := edszResource(pod)
+	path, query, _ := strings.Cut(resource, "?")
+	assert.Equal(t, path, "edsz")
+	values, err := url.ParseQuery(query)
+	assert.NoError(t, err)
+	assert.Equal(t, values, url.Values{
+		"canonical": {""},
+		"proxyID":   {"reviews-v1.default"},
+		"ip":        {"10.0.0.1"},
+		"cluster":   {"cluster1"},
+		"network":   {"network1"},
+	})
+}
+
+func TestReport(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, report(&out, nil, 2, "default", "canary"))
+	assert.Equal(t, out.String(), "Revisions default and canary build identical endpoints for 2 clusters\n")
+
+	out.Reset()
+	assert.Error(t, report(&out, []string{reviews + ": only built by the first revision"}, 2, "default", "canary"))
+	assert.Equal(t, out.String(), "Differences between revisions default (first) and canary (second):\n  "+
+		reviews+": only built by the first revision\n")
+}
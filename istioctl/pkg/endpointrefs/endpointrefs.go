@@ -0,0 +1,159 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpointrefs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/istioctl/pkg/clioptions"
+	"istio.io/istio/istioctl/pkg/multixds"
+	"istio.io/istio/istioctl/pkg/util"
+	"istio.io/istio/istioctl/pkg/util/handlers"
+	"istio.io/istio/pilot/pkg/xds"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+// Cmd returns the endpoint-references command, which lists the services and clusters including an endpoint.
+func Cmd(ctx cli.Context) *cobra.Command {
+	var opts clioptions.ControlPlaneOptions
+	var centralOpts clioptions.CentralControlPlaneOptions
+
+	cmd := &cobra.Command{
+		Use:   "endpoint-references <pod-name>[.<namespace>]|<ip>",
+		Short: "Lists the services and the clusters of the connected proxies including an endpoint",
+		Long: `
+Lists the services an endpoint belongs to in the endpoint index of Istiod, and the clusters of the proxies connected
+to each Istiod instance which currently include it, with the health and the weight they send for it.
+Endpoints reached through a network gateway are included in the clusters under the address of the gateway.
+`,
+		Example: `  # List the clusters including a pod
+  istioctl x endpoint-references productpage-v1-c7765c886-7zzd4.default
+
+  # List the clusters including an address, such as one of a VM
+  istioctl x endpoint-references 10.128.0.12
+`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return util.CommandParseError{Err: fmt.Errorf("expected a single pod name or address")}
+			}
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			kubeClient, err := ctx.CLIClientWithRevision(opts.Revision)
+			if err != nil {
+				return err
+			}
+			address := args[0]
+			if _, err := netip.ParseAddr(address); err != nil {
+				podName, ns := handlers.InferPodInfo(args[0], ctx.NamespaceOrDefault(ctx.Namespace()))
+				pod, err := kubeClient.Kube().CoreV1().Pods(ns).Get(context.TODO(), podName, metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				if pod.Status.PodIP == "" {
+					return fmt.Errorf("pod %s.%s has no address", podName, ns)
+				}
+				address = pod.Status.PodIP
+			}
+
+			xdsRequest := discovery.DiscoveryRequest{
+				ResourceNames: []string{"endpoint_references?ip=" + url.QueryEscape(address)},
+				Node: &core.Node{
+					Id: "debug~0.0.0.0~istioctl~cluster.local",
+				},
+				TypeUrl: v3.DebugType,
+			}
+			// The proxies are spread across the Istiod instances, so all of them are queried.
+			xdsResponses, err := multixds.AllRequestAndProcessXds(&xdsRequest, centralOpts, ctx.IstioNamespace(),
+				"", "", kubeClient, multixds.DefaultOptions)
+			if err != nil {
+				return err
+			}
+			refs, err := parseReferences(xdsResponses)
+			if err != nil {
+				return err
+			}
+			return printReferences(c.OutOrStdout(), refs)
+		},
+	}
+
+	opts.AttachControlPlaneFlags(cmd)
+	centralOpts.AttachControlPlaneFlags(cmd)
+	cmd.Long += "\n\n" + util.ExperimentalMsg
+	return cmd
+}
+
+// parseReferences merges the references served by the endpoint_references debug endpoint of each Istiod instance.
+// The services are the same for all instances, while each instance lists the clusters of its own proxies.
+func parseReferences(responses map[string]*discovery.DiscoveryResponse) (*xds.EndpointReferences, error) {
+	var out *xds.EndpointReferences
+	for _, response := range responses {
+		for _, resource := range response.Resources {
+			refs := &xds.EndpointReferences{}
+			if err := json.Unmarshal(resource.Value, refs); err != nil {
+				return nil, fmt.Errorf("unexpected response from Istiod: %s", strings.TrimSpace(string(resource.Value)))
+			}
+			if out == nil {
+				out = refs
+				continue
+			}
+			out.Clusters = append(out.Clusters, refs.Clusters...)
+		}
+	}
+	if out == nil {
+		return nil, fmt.Errorf("no endpoint references were returned by Istiod")
+	}
+	sort.SliceStable(out.Clusters, func(i, j int) bool {
+		if out.Clusters[i].Proxy != out.Clusters[j].Proxy {
+			return out.Clusters[i].Proxy < out.Clusters[j].Proxy
+		}
+		return out.Clusters[i].Cluster < out.Clusters[j].Cluster
+	})
+	return out, nil
+}
+
+func printReferences(w io.Writer, refs *xds.EndpointReferences) error {
+	if len(refs.Services) == 0 && len(refs.Clusters) == 0 {
+		_, _ = fmt.Fprintf(w, "No service or cluster includes %s\n", refs.Address)
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "SERVICE\tNAMESPACE\tSHARD\tPORT\tLOCALITY\tHEALTH\tWEIGHT")
+	for _, ref := range refs.Services {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%d\n",
+			ref.Service, ref.Namespace, ref.Shard, ref.Port, ref.Locality, ref.Health, ref.Weight)
+	}
+	_, _ = fmt.Fprintln(tw)
+	_, _ = fmt.Fprintln(tw, "PROXY\tCLUSTER\tPORT\tLOCALITY\tPRIORITY\tHEALTH\tWEIGHT")
+	for _, ref := range refs.Clusters {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%d\t%s\t%d\n",
+			ref.Proxy, ref.Cluster, ref.Port, ref.Locality, ref.Priority, ref.Health, ref.Weight)
+	}
+	return tw.Flush()
+}
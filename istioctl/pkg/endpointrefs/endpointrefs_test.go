@@ -0,0 +1,69 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpointrefs
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pilot/pkg/xds/endpoints"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func response(t *testing.T, refs xds.EndpointReferences) *discovery.DiscoveryResponse {
+	b, err := json.Marshal(refs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &discovery.DiscoveryResponse{Resources: []*anypb.Any{{Value: b}}}
+}
+
+func TestParseReferences(t *testing.T) {
+	services := []endpoints.ServiceEndpointReference{{
+		Service: "reviews.default.svc.cluster.local", Namespace: "default", Shard: "Kubernetes/c1", Port: 9080, Health: "HEALTHY", Weight: 1,
+	}}
+	refs, err := parseReferences(map[string]*discovery.DiscoveryResponse{
+		"istiod-1": response(t, xds.EndpointReferences{Address: "10.0.0.1", Services: services, Clusters: []endpoints.ClusterEndpointReference{
+			{Proxy: "productpage.default", Cluster: "outbound|9080||reviews.default.svc.cluster.local", Port: 9080, Health: "HEALTHY", Weight: 1},
+		}}),
+		"istiod-2": response(t, xds.EndpointReferences{Address: "10.0.0.1", Services: services, Clusters: []endpoints.ClusterEndpointReference{
+			{Proxy: "gateway.istio-system", Cluster: "outbound|9080||reviews.default.svc.cluster.local", Port: 9080, Health: "HEALTHY", Weight: 1},
+		}}),
+	})
+	assert.NoError(t, err)
+	// The services are listed once, and the clusters of the proxies of all the instances are merged.
+	assert.Equal(t, refs.Services, services)
+	assert.Equal(t, len(refs.Clusters), 2)
+	assert.Equal(t, refs.Clusters[0].Proxy, "gateway.istio-system")
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, printReferences(out, refs))
+	if !strings.Contains(out.String(), "gateway.istio-system outbound|9080||reviews.default.svc.cluster.local 9080") {
+		t.Fatalf("expected the cluster of the gateway, got:\n%s", out.String())
+	}
+
+	_, err = parseReferences(map[string]*discovery.DiscoveryResponse{
+		"istiod-1": {Resources: []*anypb.Any{{Value: []byte("You must provide an ip parameter\n")}}},
+	})
+	assert.Error(t, err)
+	_, err = parseReferences(map[string]*discovery.DiscoveryResponse{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,250 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpointwatch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/istioctl/pkg/clioptions"
+	"istio.io/istio/istioctl/pkg/multixds"
+	"istio.io/istio/istioctl/pkg/util"
+	"istio.io/istio/istioctl/pkg/util/handlers"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds"
+)
+
+const (
+	eventAdd    = "ADD"
+	eventRemove = "REMOVE"
+	eventHealth = "HEALTH"
+)
+
+// Cmd returns the endpoint-watch command, which streams endpoint changes of a service as seen by Istiod.
+func Cmd(ctx cli.Context) *cobra.Command {
+	var opts clioptions.ControlPlaneOptions
+	var centralOpts clioptions.CentralControlPlaneOptions
+
+	cmd := &cobra.Command{
+		Use:   "endpoint-watch <service>[.<namespace>]",
+		Short: "Streams endpoint changes of a service as seen by Istiod",
+		Long: `
+Watches the endpoints Istiod holds for a service and prints an event each time an endpoint is added,
+removed or changes health, along with the cluster and registry shard it came from.
+The current endpoints are printed as ADD events when the watch starts. The changes are streamed by Istiod
+as they happen, over the same xDS connection.
+`,
+		Example: `  # Watch the endpoints of the reviews service in the default namespace
+  istioctl x endpoint-watch reviews.default
+
+  # Watch a ServiceEntry host
+  istioctl x endpoint-watch api.example.com.external
+
+  # Watch endpoints through a control plane reachable outside of the cluster
+  istioctl x endpoint-watch reviews.default --xds-address istio.cloudprovider.example.com:15012
+`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return util.CommandParseError{Err: fmt.Errorf("expected a single service name")}
+			}
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			kubeClient, err := ctx.CLIClientWithRevision(opts.Revision)
+			if err != nil {
+				return err
+			}
+			name, ns := handlers.InferPodInfo(args[0], ctx.NamespaceOrDefault(ctx.Namespace()))
+			w := &watcher{
+				name:      name,
+				namespace: ns,
+			}
+			sigCtx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer cancel()
+			watch := func(handle func(map[string]map[string]*endpointShards) error) error {
+				xdsRequest := discovery.DiscoveryRequest{
+					ResourceNames: []string{ns},
+					Node: &core.Node{
+						Id: "debug~0.0.0.0~istioctl~cluster.local",
+					},
+					TypeUrl: xds.TypeURLEndpointShards,
+				}
+				return multixds.WatchXds(sigCtx, &xdsRequest, centralOpts, ctx.IstioNamespace(), kubeClient,
+					func(response *discovery.DiscoveryResponse) error {
+						shardz, err := parseShardz(response)
+						if err != nil {
+							return err
+						}
+						return handle(shardz)
+					})
+			}
+			return w.run(c.OutOrStdout(), watch)
+		},
+	}
+
+	opts.AttachControlPlaneFlags(cmd)
+	centralOpts.AttachControlPlaneFlags(cmd)
+	cmd.Long += "\n\n" + util.ExperimentalMsg
+	return cmd
+}
+
+// endpointState is the part of an endpoint that is reported by the watch.
+type endpointState struct {
+	key     string
+	service string
+	address string
+	port    uint32
+	cluster string
+	shard   string
+	health  model.HealthStatus
+}
+
+type event struct {
+	kind string
+	endpointState
+	// previous health, only set for HEALTH events
+	from model.HealthStatus
+}
+
+// endpointShards mirrors model.EndpointShards as streamed by Istiod, where shards are keyed by their string form.
+type endpointShards struct {
+	Shards map[string][]*model.IstioEndpoint
+}
+
+type watcher struct {
+	// name is either a Kubernetes service name or a full hostname.
+	name      string
+	namespace string
+
+	last map[string]endpointState
+}
+
+// run prints the events of each endpoint shards streamed by watch, until watch returns.
+func (w *watcher) run(out io.Writer, watch func(handle func(map[string]map[string]*endpointShards) error) error) error {
+	tw := tabwriter.NewWriter(out, 0, 8, 1, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "TIME\tEVENT\tENDPOINT\tSERVICE\tCLUSTER\tSHARD\tHEALTH")
+	return watch(func(shardz map[string]map[string]*endpointShards) error {
+		now := time.Now().Format(time.RFC3339)
+		for _, e := range w.update(shardz) {
+			health := healthString(e.health)
+			if e.kind == eventHealth {
+				health = healthString(e.from) + " -> " + health
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s:%d\t%s\t%s\t%s\t%s\n",
+				now, e.kind, e.address, e.port, e.service, e.cluster, e.shard, health)
+		}
+		_ = tw.Flush()
+		return nil
+	})
+}
+
+// update records the endpoints of the watched service in shardz and returns the events since the last update.
+func (w *watcher) update(shardz map[string]map[string]*endpointShards) []event {
+	current := map[string]endpointState{}
+	for hostname, byNs := range shardz {
+		if !w.matches(hostname) {
+			continue
+		}
+		shards := byNs[w.namespace]
+		if shards == nil {
+			continue
+		}
+		for shard, eps := range shards.Shards {
+			for _, ep := range eps {
+				st := endpointState{
+					service: hostname,
+					address: ep.Address,
+					port:    ep.EndpointPort,
+					cluster: ep.Locality.ClusterID.String(),
+					shard:   shard,
+					health:  ep.HealthStatus,
+				}
+				st.key = fmt.Sprintf("%s/%s/%s:%d", st.service, st.shard, st.address, st.port)
+				current[st.key] = st
+			}
+		}
+	}
+
+	var events []event
+	for key, st := range current {
+		prev, ok := w.last[key]
+		switch {
+		case !ok:
+			events = append(events, event{kind: eventAdd, endpointState: st})
+		case prev.health != st.health:
+			events = append(events, event{kind: eventHealth, endpointState: st, from: prev.health})
+		}
+	}
+	for key, st := range w.last {
+		if _, ok := current[key]; !ok {
+			events = append(events, event{kind: eventRemove, endpointState: st})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].key != events[j].key {
+			return events[i].key < events[j].key
+		}
+		return events[i].kind < events[j].kind
+	})
+	w.last = current
+	return events
+}
+
+// matches reports whether hostname is the watched service, either by full hostname or by
+// the Kubernetes short name and namespace.
+func (w *watcher) matches(hostname string) bool {
+	if hostname == w.name {
+		return true
+	}
+	return strings.HasPrefix(hostname, w.name+"."+w.namespace+".svc.")
+}
+
+func parseShardz(response *discovery.DiscoveryResponse) (map[string]map[string]*endpointShards, error) {
+	for _, resource := range response.Resources {
+		shardz := map[string]map[string]*endpointShards{}
+		if err := json.Unmarshal(resource.Value, &shardz); err != nil {
+			return nil, fmt.Errorf("failed to parse endpoint shards from Istiod: %v", err)
+		}
+		return shardz, nil
+	}
+	return nil, fmt.Errorf("no endpoint shards were returned by Istiod")
+}
+
+func healthString(h model.HealthStatus) string {
+	switch h {
+	case model.Healthy:
+		return "HEALTHY"
+	case model.UnHealthy:
+		return "UNHEALTHY"
+	case model.Draining:
+		return "DRAINING"
+	default:
+		return "UNKNOWN"
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpointwatch
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func shardzFor(eps map[string][]*model.IstioEndpoint) map[string]map[string]*endpointShards {
+	return map[string]map[string]*endpointShards{
+		"reviews.default.svc.cluster.local": {
+			"default": {Shards: eps},
+		},
+		"reviews.other.svc.cluster.local": {
+			"other": {Shards: map[string][]*model.IstioEndpoint{
+				"Kubernetes/cluster1": {{Address: "9.9.9.9", EndpointPort: 80}},
+			}},
+		},
+	}
+}
+
+func endpoint(addr string, clusterID string, health model.HealthStatus) *model.IstioEndpoint {
+	return &model.IstioEndpoint{
+		Address:      addr,
+		EndpointPort: 8080,
+		Locality:     model.Locality{ClusterID: cluster.ID(clusterID)},
+		HealthStatus: health,
+	}
+}
+
+func kinds(events []event) []string {
+	var out []string
+	for _, e := range events {
+		out = append(out, e.kind+" "+e.address+" "+e.shard)
+	}
+	return out
+}
+
+func TestUpdate(t *testing.T) {
+	w := &watcher{name: "reviews", namespace: "default"}
+
+	events := w.update(shardzFor(map[string][]*model.IstioEndpoint{
+		"Kubernetes/cluster1": {endpoint("1.1.1.1", "cluster1", model.Healthy), endpoint("2.2.2.2", "cluster1", model.Healthy)},
+	}))
+	assert.Equal(t, kinds(events), []string{
+		"ADD 1.1.1.1 Kubernetes/cluster1",
+		"ADD 2.2.2.2 Kubernetes/cluster1",
+	})
+
+	// No changes, no events
+	events = w.update(shardzFor(map[string][]*model.IstioEndpoint{
+		"Kubernetes/cluster1": {endpoint("1.1.1.1", "cluster1", model.Healthy), endpoint("2.2.2.2", "cluster1", model.Healthy)},
+	}))
+	assert.Equal(t, len(events), 0)
+
+	events = w.update(shardzFor(map[string][]*model.IstioEndpoint{
+		"Kubernetes/cluster1":   {endpoint("1.1.1.1", "cluster1", model.UnHealthy)},
+		"External/cluster1":     {endpoint("3.3.3.3", "cluster1", model.Healthy)},
+		"Kubernetes/unrelated2": nil,
+	}))
+	assert.Equal(t, kinds(events), []string{
+		"ADD 3.3.3.3 External/cluster1",
+		"HEALTH 1.1.1.1 Kubernetes/cluster1",
+		"REMOVE 2.2.2.2 Kubernetes/cluster1",
+	})
+	assert.Equal(t, events[1].from, model.Healthy)
+	assert.Equal(t, events[1].health, model.UnHealthy)
+}
+
+func TestMatches(t *testing.T) {
+	w := &watcher{name: "reviews", namespace: "default"}
+	assert.Equal(t, w.matches("reviews.default.svc.cluster.local"), true)
+	assert.Equal(t, w.matches("reviews.default.svc.example.com"), true)
+	assert.Equal(t, w.matches("reviews.other.svc.cluster.local"), false)
+	assert.Equal(t, w.matches("reviews-v2.default.svc.cluster.local"), false)
+
+	w = &watcher{name: "api.example.com", namespace: "external"}
+	assert.Equal(t, w.matches("api.example.com"), true)
+	assert.Equal(t, w.matches("example.com"), false)
+}
+
+func TestParseShardz(t *testing.T) {
+	// Marshal the real model type, as Istiod does, to make sure the client side types stay compatible.
+	shards := &model.EndpointShards{
+		Shards: map[model.ShardKey][]*model.IstioEndpoint{
+			{Cluster: "cluster1", Provider: "Kubernetes"}: {endpoint("1.1.1.1", "cluster1", model.Healthy)},
+		},
+	}
+	b, err := config.ToJSON(map[string]map[string]*model.EndpointShards{
+		"reviews.default.svc.cluster.local": {"default": shards},
+	})
+	assert.NoError(t, err)
+	shardz, err := parseShardz(&discovery.DiscoveryResponse{Resources: []*anypb.Any{{Value: b}}})
+	assert.NoError(t, err)
+	eps := shardz["reviews.default.svc.cluster.local"]["default"].Shards["Kubernetes/cluster1"]
+	assert.Equal(t, len(eps), 1)
+	assert.Equal(t, eps[0].Address, "1.1.1.1")
+	assert.Equal(t, eps[0].Locality.ClusterID.String(), "cluster1")
+
+	_, err = parseShardz(&discovery.DiscoveryResponse{})
+	assert.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	w := &watcher{name: "reviews", namespace: "default"}
+	watch := func(handle func(map[string]map[string]*endpointShards) error) error {
+		if err := handle(shardzFor(map[string][]*model.IstioEndpoint{
+			"Kubernetes/cluster1": {endpoint("1.1.1.1", "cluster1", model.Healthy)},
+		})); err != nil {
+			return err
+		}
+		return handle(shardzFor(nil))
+	}
+	out := &bytes.Buffer{}
+	assert.NoError(t, w.run(out, watch))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, len(lines), 3)
+	assert.Equal(t, strings.Fields(lines[0]), []string{"TIME", "EVENT", "ENDPOINT", "SERVICE", "CLUSTER", "SHARD", "HEALTH"})
+	assert.Equal(t, strings.Fields(lines[1])[1:], []string{
+		"ADD", "1.1.1.1:8080", "reviews.default.svc.cluster.local", "cluster1", "Kubernetes/cluster1", "HEALTHY",
+	})
+	assert.Equal(t, strings.Fields(lines[2])[1:3], []string{"REMOVE", "1.1.1.1:8080"})
+}
@@ -385,6 +385,58 @@ func MultiRequestAndProcessXds(all bool, dr *discovery.DiscoveryRequest, central
 	return mapShards(responses)
 }
 
+// WatchXds streams the responses to a watched XDS request from 1 central or the first K8s cluster-based XDS server
+// to handle, until ctx is done or handle fails.
+func WatchXds(ctx context.Context, dr *discovery.DiscoveryRequest, centralOpts clioptions.CentralControlPlaneOptions,
+	istioNamespace string, kubeClient kube.CLIClient, handle func(*discovery.DiscoveryResponse) error,
+) error {
+	if centralOpts.Xds != "" {
+		dialOpts, err := xds.DialOptions(centralOpts, istioNamespace, tokenServiceAccount, kubeClient)
+		if err != nil {
+			return err
+		}
+		return xds.WatchXdsResponses(ctx, dr, istioNamespace, tokenServiceAccount, centralOpts, dialOpts, handle)
+	}
+
+	labelSelector := centralOpts.XdsPodLabel
+	if labelSelector == "" {
+		labelSelector = "app=istiod"
+	}
+	pods, err := kubeClient.GetIstioPods(context.TODO(), istioNamespace, metav1.ListOptions{
+		LabelSelector: labelSelector,
+		FieldSelector: kube.RunningStatus,
+	})
+	if err != nil {
+		return err
+	}
+	if len(pods) == 0 {
+		return ControlPlaneNotFoundError{istioNamespace}
+	}
+	xdsOpts := clioptions.CentralControlPlaneOptions{
+		XDSSAN:  makeSan(istioNamespace, kubeClient.Revision()),
+		CertDir: centralOpts.CertDir,
+		Timeout: centralOpts.Timeout,
+	}
+	dialOpts, err := xds.DialOptions(xdsOpts, istioNamespace, tokenServiceAccount, kubeClient)
+	if err != nil {
+		return err
+	}
+	pod := pods[0]
+	fw, err := kubeClient.NewPortForwarder(pod.Name, pod.Namespace, "localhost", 0, centralOpts.XdsPodPort)
+	if err != nil {
+		return err
+	}
+	if err := fw.Start(); err != nil {
+		return err
+	}
+	defer fw.Close()
+	xdsOpts.Xds = fw.Address()
+	if err := xds.WatchXdsResponses(ctx, dr, istioNamespace, tokenServiceAccount, xdsOpts, dialOpts, handle); err != nil {
+		return fmt.Errorf("could not watch XDS from discovery pod %q: %v", pod.Name, err)
+	}
+	return nil
+}
+
 func mapShards(responses []*discovery.DiscoveryResponse) (map[string]*discovery.DiscoveryResponse, error) {
 	retval := map[string]*discovery.DiscoveryResponse{}
 
@@ -72,6 +72,54 @@ func GetXdsResponse(dr *discovery.DiscoveryRequest, ns string, serviceAccount st
 	return response, err
 }
 
+// WatchXdsResponses opens a gRPC connection to opts.xds, sends the request and calls handle with each response
+// to it until ctx is done, handle fails or the connection is closed.
+func WatchXdsResponses(ctx context.Context, dr *discovery.DiscoveryRequest, ns string, serviceAccount string,
+	opts clioptions.CentralControlPlaneOptions, grpcOpts []grpc.DialOption, handle func(*discovery.DiscoveryResponse) error,
+) error {
+	adscConn, err := adsc.NewWithBackoffPolicy(opts.Xds, &adsc.Config{
+		Meta: model.NodeMetadata{
+			Generator:      "event",
+			ServiceAccount: serviceAccount,
+			Namespace:      ns,
+			CloudrunAddr:   opts.IstiodAddr,
+		}.ToStruct(),
+		CertDir:            opts.CertDir,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+		XDSSAN:             opts.XDSSAN,
+		GrpcOpts:           grpcOpts,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("could not dial: %w", err)
+	}
+	defer adscConn.Close()
+	err = adscConn.Run()
+	if err != nil {
+		return fmt.Errorf("ADSC: failed running %v", err)
+	}
+
+	err = adscConn.Send(dr)
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case response := <-adscConn.XDSUpdates:
+			if response == nil {
+				return fmt.Errorf("connection to %s closed", opts.Xds)
+			}
+			if response.TypeUrl != dr.TypeUrl {
+				continue
+			}
+			if err := handle(response); err != nil {
+				return err
+			}
+		}
+	}
+}
+
 // DialOptions constructs gRPC dial options from command line configuration
 func DialOptions(opts clioptions.CentralControlPlaneOptions,
 	ns, serviceAccount string, kubeClient kube.CLIClient,
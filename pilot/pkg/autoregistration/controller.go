@@ -36,7 +36,6 @@ import (
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/model/status"
-	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/kube/controllers"
@@ -333,9 +332,15 @@ func (c *Controller) changeWorkloadEntryStateToConnected(entryName string, proxy
 	if conTime.Before(lastConTime) {
 		return false, nil
 	}
+	// The proxy may report another weight on reconnection, for example after its VM was resized, and the defaults of
+	// the WorkloadGroup may have changed since it registered.
+	weight, locality, refreshDefaults := c.autoRegisteredDefaults(wle, proxy)
 	// Try to patch, if it fails then try to create
 	_, err := c.store.Patch(*wle, func(cfg config.Config) (config.Config, kubetypes.PatchType) {
 		setConnectMeta(&cfg, c.instanceID, conTime)
+		if refreshDefaults {
+			setDefaults(&cfg, weight, locality)
+		}
 		return cfg, kubetypes.MergePatchType
 	})
 	if err != nil {
@@ -661,9 +666,7 @@ func workloadEntryFromGroup(name string, proxy *model.Proxy, groupCfg *config.Co
 	if proxy.Metadata.Network != "" {
 		entry.Network = string(proxy.Metadata.Network)
 	}
-	if proxy.Locality != nil {
-		entry.Locality = util.LocalityToString(proxy.Locality)
-	}
+	entry.Weight, entry.Locality = groupDefaults(proxy, groupCfg)
 	if proxy.Metadata.ProxyConfig != nil && proxy.Metadata.ProxyConfig.ReadinessProbe != nil {
 		annotations[status.WorkloadEntryHealthCheckAnnotation] = "true"
 	}
@@ -36,6 +36,7 @@ import (
 	"istio.io/istio/pilot/pkg/model/status"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/schema/collections"
 	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/keepalive"
@@ -379,6 +380,164 @@ func TestWorkloadEntryFromGroup(t *testing.T) {
 	assert.Equal(t, got, &want)
 }
 
+func TestWorkloadEntryFromGroupLocalityOverride(t *testing.T) {
+	group := func(locality string, annotations, labels map[string]string) *config.Config {
+		return &config.Config{
+			Meta: config.Meta{
+				GroupVersionKind: gvk.WorkloadGroup,
+				Namespace:        "a",
+				Name:             "wg-a",
+				Annotations:      annotations,
+				Labels:           labels,
+			},
+			Spec: &v1alpha3.WorkloadGroup{
+				Template: &v1alpha3.WorkloadEntry{Locality: locality},
+			},
+		}
+	}
+	cases := []struct {
+		name     string
+		group    *config.Config
+		locality *core.Locality
+		want     string
+	}{
+		{
+			name:  "annotation",
+			group: group("", map[string]string{constants.LocalityOverride: "rgn1/zone1"}, nil),
+			want:  "rgn1/zone1",
+		},
+		{
+			name:  "label",
+			group: group("", nil, map[string]string{constants.LocalityOverride: "rgn1.zone1.subzone1"}),
+			want:  "rgn1/zone1/subzone1",
+		},
+		{
+			name:  "template locality",
+			group: group("rgn2/zone2", map[string]string{constants.LocalityOverride: "rgn1/zone1"}, nil),
+			want:  "rgn2/zone2",
+		},
+		{
+			name:     "empty proxy locality",
+			group:    group("rgn2/zone2", nil, nil),
+			locality: &core.Locality{},
+			want:     "rgn2/zone2",
+		},
+		{
+			name:     "proxy locality",
+			group:    group("", map[string]string{constants.LocalityOverride: "rgn1/zone1"}, nil),
+			locality: &core.Locality{Region: "rgn3"},
+			want:     "rgn3",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := fakeProxy("10.0.0.1", *tt.group, "nw1", "sa")
+			proxy.Locality = tt.locality
+			got := workloadEntryFromGroup("test-we", proxy, tt.group)
+			assert.Equal(t, got.Spec.(*v1alpha3.WorkloadEntry).Locality, tt.want)
+		})
+	}
+
+	t.Run("asserted locality", func(t *testing.T) {
+		wg := group("", nil, nil)
+		proxy := fakeProxy("10.0.0.1", *wg, "nw1", "sa")
+		proxy.Metadata.Locality = "edge/site1"
+		test.SetForTest(t, &features.ProxyLocalityPolicy, model.ProxyLocalityFallback)
+		got := workloadEntryFromGroup("test-we", proxy, wg)
+		assert.Equal(t, got.Spec.(*v1alpha3.WorkloadEntry).Locality, "edge/site1")
+
+		proxy.Locality = &core.Locality{Region: "rgn3"}
+		got = workloadEntryFromGroup("test-we", proxy, wg)
+		assert.Equal(t, got.Spec.(*v1alpha3.WorkloadEntry).Locality, "rgn3")
+
+		test.SetForTest(t, &features.ProxyLocalityPolicy, model.ProxyLocalityOverride)
+		got = workloadEntryFromGroup("test-we", proxy, wg)
+		assert.Equal(t, got.Spec.(*v1alpha3.WorkloadEntry).Locality, "edge/site1")
+	})
+}
+
+func TestWorkloadEntryFromGroupWeight(t *testing.T) {
+	group := func(weight uint32, expr string) *config.Config {
+		return &config.Config{
+			Meta: config.Meta{
+				GroupVersionKind: gvk.WorkloadGroup,
+				Namespace:        "a",
+				Name:             "wg-a",
+				Annotations:      map[string]string{constants.WeightFromMetadata: expr},
+			},
+			Spec: &v1alpha3.WorkloadGroup{
+				Template: &v1alpha3.WorkloadEntry{Weight: weight},
+			},
+		}
+	}
+	cases := []struct {
+		name     string
+		group    *config.Config
+		metadata map[string]any
+		want     uint32
+	}{
+		{name: "template weight", group: group(5, ""), metadata: map[string]any{"CPUS": "4"}, want: 5},
+		{name: "metadata", group: group(5, "CPUS"), metadata: map[string]any{"CPUS": "4"}, want: 4},
+		{name: "factor", group: group(5, "CPUS * 2.5"), metadata: map[string]any{"CPUS": "3"}, want: 8},
+		{name: "fractional", group: group(5, "CPUS"), metadata: map[string]any{"CPUS": "0.25"}, want: 1},
+		{name: "missing metadata", group: group(5, "CPUS"), metadata: map[string]any{}, want: 5},
+		{name: "invalid metadata", group: group(5, "CPUS"), metadata: map[string]any{"CPUS": "many"}, want: 5},
+		{name: "negative metadata", group: group(5, "CPUS"), metadata: map[string]any{"CPUS": "-2"}, want: 5},
+		{name: "invalid factor", group: group(5, "CPUS*x"), metadata: map[string]any{"CPUS": "4"}, want: 5},
+		{name: "default maximum", group: group(5, "CPUS"), metadata: map[string]any{"CPUS": "1e9"}, want: 100},
+		{
+			name:     "maximum",
+			group:    withAnnotation(group(5, "CPUS*10"), constants.MaxWeight, "50"),
+			metadata: map[string]any{"CPUS": "8"},
+			want:     50,
+		},
+		{
+			name:     "invalid maximum",
+			group:    withAnnotation(group(5, "CPUS*10"), constants.MaxWeight, "none"),
+			metadata: map[string]any{"CPUS": "16"},
+			want:     100,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := fakeProxy("10.0.0.1", *tt.group, "nw1", "sa")
+			proxy.Metadata.Raw = tt.metadata
+			got := workloadEntryFromGroup("test-we", proxy, tt.group)
+			assert.Equal(t, got.Spec.(*v1alpha3.WorkloadEntry).Weight, tt.want)
+		})
+	}
+
+	t.Run("reconnection", func(t *testing.T) {
+		store := memory.NewController(memory.Make(collections.All))
+		c := NewController(store, "pilot-x", keepalive.Infinity)
+		go c.Run(test.NewStop(t))
+		wg := group(5, "CPUS")
+		createOrFail(t, store, *wg)
+		proxy := fakeProxy("10.0.0.1", *wg, "nw1", "sa")
+		proxy.Metadata.Raw = map[string]any{"CPUS": "4"}
+		wle := workloadEntryFromGroup("test-we", proxy, wg)
+
+		// The VM was resized before reconnecting, and the WorkloadGroup now declares a default locality.
+		spec := wg.Spec.(*v1alpha3.WorkloadGroup).DeepCopy()
+		spec.Template.Locality = "rgn1/zone1"
+		wg.Spec = spec
+		if _, err := store.Update(*wg); err != nil {
+			t.Fatal(err)
+		}
+		proxy.Metadata.Raw = map[string]any{"CPUS": "8"}
+		weight, locality, ok := c.autoRegisteredDefaults(wle, proxy)
+		assert.Equal(t, ok, true)
+		setDefaults(wle, weight, locality)
+		assert.Equal(t, wle.Spec.(*v1alpha3.WorkloadEntry).Weight, uint32(8))
+		assert.Equal(t, wle.Spec.(*v1alpha3.WorkloadEntry).Locality, "rgn1/zone1")
+	})
+}
+
+func withAnnotation(cfg *config.Config, key, value string) *config.Config {
+	cfg.Annotations[key] = value
+	return cfg
+}
+
 func TestNonAutoregisteredWorkloads_UnsuitableForHealthChecks_WorkloadEntryNotFound(t *testing.T) {
 	store := memory.NewController(memory.Make(collections.All))
 	createOrFail(t, store, weB)
@@ -0,0 +1,146 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package autoregistration
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"istio.io/api/annotation"
+	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+// defaultMaxWeight is the maximum weight of the WorkloadEntries weighted from metadata, when their WorkloadGroup does
+// not set the constants.MaxWeight annotation.
+const defaultMaxWeight = 100
+
+// groupDefaults returns the weight and locality of the WorkloadEntry auto-registered for the proxy. The weight is read
+// from metadata as weightFromMetadata, or else is the weight of the template. The locality is the one reported, then
+// asserted, by the proxy, or else the locality of the template, or else the constants.LocalityOverride annotation or
+// label of the WorkloadGroup, as VMs often do not report their locality.
+func groupDefaults(proxy *model.Proxy, groupCfg *config.Config) (uint32, string) {
+	template := groupCfg.Spec.(*v1alpha3.WorkloadGroup).GetTemplate()
+	weight := template.GetWeight()
+	if w, ok := weightFromMetadata(proxy, groupCfg); ok {
+		weight = w
+	}
+	locality := util.LocalityToString(proxy.Locality)
+	if asserted, override := proxy.AssertedLocality(); asserted != "" && (override || locality == "") {
+		locality = asserted
+	}
+	if locality == "" {
+		locality = template.GetLocality()
+	}
+	if locality == "" {
+		if l := groupCfg.Annotations[constants.LocalityOverride]; l != "" {
+			locality = model.GetLocalityLabel(l)
+		} else {
+			locality = model.GetLocalityLabel(groupCfg.Labels[constants.LocalityOverride])
+		}
+	}
+	return weight, locality
+}
+
+// weightFromMetadata returns the weight of the WorkloadEntry auto-registered for the proxy, from the node metadata
+// field named by the constants.WeightFromMetadata annotation of its WorkloadGroup, at most the constants.MaxWeight
+// annotation of the WorkloadGroup as the field is reported by the proxy itself. It returns false if the annotation
+// is not set, or if the field is missing or invalid.
+func weightFromMetadata(proxy *model.Proxy, groupCfg *config.Config) (uint32, bool) {
+	expr := groupCfg.Annotations[constants.WeightFromMetadata]
+	if expr == "" {
+		return 0, false
+	}
+	field, factorStr, hasFactor := strings.Cut(expr, "*")
+	field = strings.TrimSpace(field)
+	factor := 1.0
+	if hasFactor {
+		f, err := strconv.ParseFloat(strings.TrimSpace(factorStr), 64)
+		if err != nil || f <= 0 {
+			log.Warnf("invalid %s annotation %q on WorkloadGroup %s/%s", constants.WeightFromMetadata, expr,
+				groupCfg.Namespace, groupCfg.Name)
+			return 0, false
+		}
+		factor = f
+	}
+	raw, ok := proxy.Metadata.Raw[field]
+	if !ok {
+		log.Debugf("proxy %s does not report the %s metadata weighting WorkloadGroup %s/%s", proxy.ID, field,
+			groupCfg.Namespace, groupCfg.Name)
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(fmt.Sprint(raw)), 64)
+	if err != nil || value <= 0 || math.IsInf(value, 0) {
+		log.Warnf("proxy %s reports an invalid %s metadata %q weighting WorkloadGroup %s/%s", proxy.ID, field, raw,
+			groupCfg.Namespace, groupCfg.Name)
+		return 0, false
+	}
+	weight := util.ScaleWeight(value, factor)
+	if limit := maxWeight(groupCfg); weight > limit {
+		log.Debugf("proxy %s reports a %s metadata weighting WorkloadGroup %s/%s at %d, beyond its maximum of %d",
+			proxy.ID, field, groupCfg.Namespace, groupCfg.Name, weight, limit)
+		weight = limit
+	}
+	return weight, true
+}
+
+// maxWeight returns the maximum weight of the WorkloadEntries weighted from metadata, from the constants.MaxWeight
+// annotation of the WorkloadGroup, or defaultMaxWeight if it is not set or invalid.
+func maxWeight(groupCfg *config.Config) uint32 {
+	v, ok := groupCfg.Annotations[constants.MaxWeight]
+	if !ok {
+		return defaultMaxWeight
+	}
+	limit, err := strconv.ParseUint(strings.TrimSpace(v), 10, 32)
+	if err != nil || limit == 0 {
+		log.Warnf("invalid %s annotation %q on WorkloadGroup %s/%s", constants.MaxWeight, v, groupCfg.Namespace,
+			groupCfg.Name)
+		return defaultMaxWeight
+	}
+	return uint32(limit)
+}
+
+// autoRegisteredDefaults returns the weight and locality of an auto-registered WorkloadEntry, as groupDefaults. It
+// returns false if the WorkloadEntry was not auto-registered.
+func (c *Controller) autoRegisteredDefaults(wle *config.Config, proxy *model.Proxy) (uint32, string, bool) {
+	groupName := wle.Annotations[annotation.IoIstioAutoRegistrationGroup.Name]
+	if groupName == "" {
+		return 0, "", false
+	}
+	groupCfg := c.store.Get(gvk.WorkloadGroup, groupName, wle.Namespace)
+	if groupCfg == nil {
+		return 0, "", false
+	}
+	weight, locality := groupDefaults(proxy, groupCfg)
+	return weight, locality, true
+}
+
+// setDefaults sets the weight and locality of the WorkloadEntry, without mutating its spec which may be shared.
+func setDefaults(cfg *config.Config, weight uint32, locality string) {
+	entry, ok := cfg.Spec.(*v1alpha3.WorkloadEntry)
+	if !ok || (entry.Weight == weight && entry.Locality == locality) {
+		return
+	}
+	entry = entry.DeepCopy()
+	entry.Weight = weight
+	entry.Locality = locality
+	cfg.Spec = entry
+}
@@ -0,0 +1,204 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/kube/watcher/configmapwatcher"
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/util/protomarshal"
+)
+
+const (
+	// edsMeshCanaryConfigMap is the ConfigMap of the istiod namespace holding the state of the rollout of the
+	// meshConfig endpoint settings, shared by the istiod pods.
+	edsMeshCanaryConfigMap = "istio-eds-mesh-canary"
+	edsMeshCanaryStateKey  = "state"
+)
+
+// edsMeshSettings is the stored form of the meshConfig endpoint settings.
+type edsMeshSettings struct {
+	LocalityLbSetting json.RawMessage `json:"localityLbSetting,omitempty"`
+	ClusterLocalHosts []host.Name     `json:"clusterLocalHosts,omitempty"`
+	Version           string          `json:"version,omitempty"`
+}
+
+// edsMeshCanaryState is the stored form of the rollout state.
+type edsMeshCanaryState struct {
+	Settings *edsMeshSettings `json:"settings,omitempty"`
+	Baseline *edsMeshSettings `json:"baseline,omitempty"`
+	Percent  int              `json:"percent,omitempty"`
+	Since    time.Time        `json:"since,omitempty"`
+}
+
+// initEDSMeshCanary shares the rollouts of the meshConfig endpoint settings between the istiod pods, and keeps them
+// across restarts, through a ConfigMap of the istiod namespace, if PILOT_EDS_MESH_CANARY_PERCENT is set.
+func (s *Server) initEDSMeshCanary(args *PilotArgs) {
+	if features.EDSMeshCanaryPercent <= 0 || s.kubeClient == nil {
+		return
+	}
+	s.XDSServer.SetEDSMeshCanaryStore(func(update func(*xds.EDSMeshCanaryState) *xds.EDSMeshCanaryState) error {
+		return s.updateEDSMeshCanary(args.Namespace, update)
+	})
+	watcher := configmapwatcher.NewController(s.kubeClient, args.Namespace, edsMeshCanaryConfigMap, func(cm *v1.ConfigMap) {
+		state, err := edsMeshCanaryFromConfigMap(cm)
+		if err != nil {
+			log.Warnf("failed to read the EDS canary rollout from ConfigMap %s/%s: %v", args.Namespace, edsMeshCanaryConfigMap, err)
+			return
+		}
+		s.XDSServer.LoadEDSMeshCanary(state)
+	})
+	s.addStartFunc("eds mesh canary", func(stop <-chan struct{}) error {
+		go watcher.Run(stop)
+		return nil
+	})
+}
+
+// updateEDSMeshCanary applies the update to the rollout state stored in the ConfigMap, retrying on conflicts with
+// the other istiod pods.
+func (s *Server) updateEDSMeshCanary(namespace string, update func(*xds.EDSMeshCanaryState) *xds.EDSMeshCanaryState) error {
+	configMaps := s.kubeClient.Kube().CoreV1().ConfigMaps(namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := configMaps.Get(context.TODO(), edsMeshCanaryConfigMap, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			cm = nil
+		} else if err != nil {
+			return err
+		}
+		stored, err := edsMeshCanaryFromConfigMap(cm)
+		if err != nil {
+			// The state which cannot be read is replaced.
+			log.Warnf("failed to read the EDS canary rollout from ConfigMap %s/%s: %v", namespace, edsMeshCanaryConfigMap, err)
+		}
+		next := update(stored)
+		if next == nil {
+			if cm == nil {
+				return nil
+			}
+			return configMaps.Delete(context.TODO(), edsMeshCanaryConfigMap, metav1.DeleteOptions{
+				Preconditions: &metav1.Preconditions{ResourceVersion: &cm.ResourceVersion},
+			})
+		}
+		value, err := edsMeshCanaryToJSON(next)
+		if err != nil {
+			return err
+		}
+		if cm == nil {
+			_, err = configMaps.Create(context.TODO(), &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      edsMeshCanaryConfigMap,
+					Namespace: namespace,
+					Labels:    map[string]string{"istio.io/config": "true"},
+				},
+				Data: map[string]string{edsMeshCanaryStateKey: value},
+			}, metav1.CreateOptions{})
+			if kerrors.IsAlreadyExists(err) {
+				// Created by another istiod pod in the meantime: retry as a conflict.
+				return kerrors.NewConflict(v1.Resource("configmaps"), edsMeshCanaryConfigMap, err)
+			}
+			return err
+		}
+		if cm.Data[edsMeshCanaryStateKey] == value {
+			return nil
+		}
+		cm = cm.DeepCopy()
+		cm.Data = map[string]string{edsMeshCanaryStateKey: value}
+		_, err = configMaps.Update(context.TODO(), cm, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// edsMeshCanaryFromConfigMap returns the rollout state stored in the ConfigMap, nil if it does not exist.
+func edsMeshCanaryFromConfigMap(cm *v1.ConfigMap) (*xds.EDSMeshCanaryState, error) {
+	if cm == nil || cm.Data[edsMeshCanaryStateKey] == "" {
+		return nil, nil
+	}
+	value := cm.Data[edsMeshCanaryStateKey]
+	var stored edsMeshCanaryState
+	if err := json.Unmarshal([]byte(value), &stored); err != nil {
+		return nil, err
+	}
+	settings, err := edsMeshSettingsFromStored(stored.Settings)
+	if err != nil {
+		return nil, err
+	}
+	baseline, err := edsMeshSettingsFromStored(stored.Baseline)
+	if err != nil {
+		return nil, err
+	}
+	return &xds.EDSMeshCanaryState{Settings: settings, Baseline: baseline, Percent: stored.Percent, Since: stored.Since}, nil
+}
+
+func edsMeshSettingsFromStored(stored *edsMeshSettings) (*model.EndpointMeshSettings, error) {
+	if stored == nil {
+		return nil, nil
+	}
+	settings := &model.EndpointMeshSettings{
+		ClusterLocalHosts: model.NewClusterLocalHosts(stored.ClusterLocalHosts),
+		Version:           stored.Version,
+	}
+	if len(stored.LocalityLbSetting) > 0 {
+		settings.LocalityLbSetting = &v1alpha3.LocalityLoadBalancerSetting{}
+		if err := protomarshal.Unmarshal(stored.LocalityLbSetting, settings.LocalityLbSetting); err != nil {
+			return nil, err
+		}
+	}
+	return settings, nil
+}
+
+// edsMeshCanaryToJSON returns the stored form of the rollout state.
+func edsMeshCanaryToJSON(state *xds.EDSMeshCanaryState) (string, error) {
+	settings, err := edsMeshSettingsToStored(state.Settings)
+	if err != nil {
+		return "", err
+	}
+	baseline, err := edsMeshSettingsToStored(state.Baseline)
+	if err != nil {
+		return "", err
+	}
+	value, err := json.Marshal(edsMeshCanaryState{Settings: settings, Baseline: baseline, Percent: state.Percent, Since: state.Since})
+	return string(value), err
+}
+
+func edsMeshSettingsToStored(settings *model.EndpointMeshSettings) (*edsMeshSettings, error) {
+	if settings == nil {
+		return nil, nil
+	}
+	stored := &edsMeshSettings{
+		ClusterLocalHosts: settings.ClusterLocalHosts.Hosts(),
+		Version:           settings.Version,
+	}
+	if settings.LocalityLbSetting != nil {
+		value, err := protomarshal.Marshal(settings.LocalityLbSetting)
+		if err != nil {
+			return nil, err
+		}
+		stored.LocalityLbSetting = value
+	}
+	return stored, nil
+}
@@ -0,0 +1,71 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestUpdateEDSMeshCanary(t *testing.T) {
+	s := &Server{kubeClient: kube.NewFakeClient()}
+	read := func() *xds.EDSMeshCanaryState {
+		t.Helper()
+		cm, err := s.kubeClient.Kube().CoreV1().ConfigMaps("istio-system").Get(context.TODO(), edsMeshCanaryConfigMap, metav1.GetOptions{})
+		assert.NoError(t, err)
+		state, err := edsMeshCanaryFromConfigMap(cm)
+		assert.NoError(t, err)
+		return state
+	}
+
+	since := time.Now().Truncate(time.Second)
+	started := &xds.EDSMeshCanaryState{
+		Settings: &model.EndpointMeshSettings{
+			LocalityLbSetting: &v1alpha3.LocalityLoadBalancerSetting{Enabled: wrapperspb.Bool(true)},
+			ClusterLocalHosts: model.NewClusterLocalHosts([]host.Name{"*.kube-system.svc.cluster.local"}),
+		},
+		Baseline: &model.EndpointMeshSettings{Version: "baseline/v1"},
+		Percent:  20,
+		Since:    since,
+	}
+	assert.NoError(t, s.updateEDSMeshCanary("istio-system", func(stored *xds.EDSMeshCanaryState) *xds.EDSMeshCanaryState {
+		assert.Equal(t, stored, nil)
+		return started
+	}))
+	state := read()
+	assert.Equal(t, state.Settings.Equals(started.Settings), true)
+	assert.Equal(t, state.Baseline.Equals(started.Baseline), true)
+	assert.Equal(t, state.Baseline.Version, "baseline/v1")
+	assert.Equal(t, state.Percent, 20)
+	assert.Equal(t, state.Since.Equal(since), true)
+
+	assert.NoError(t, s.updateEDSMeshCanary("istio-system", func(stored *xds.EDSMeshCanaryState) *xds.EDSMeshCanaryState {
+		return &xds.EDSMeshCanaryState{Settings: stored.Settings}
+	}))
+	state = read()
+	assert.Equal(t, state.Baseline, nil)
+	assert.Equal(t, state.Settings.LocalityLbSetting.GetEnabled().GetValue(), true)
+}
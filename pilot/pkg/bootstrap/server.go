@@ -42,6 +42,7 @@ import (
 	istiogrpc "istio.io/istio/pilot/pkg/grpc"
 	"istio.io/istio/pilot/pkg/keycertbundle"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/loadbalancer"
 	"istio.io/istio/pilot/pkg/server"
 	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
 	"istio.io/istio/pilot/pkg/serviceregistry/provider"
@@ -235,6 +236,13 @@ func NewServer(args *PilotArgs, initFuncs ...func(*Server)) (*Server, error) {
 	for _, fn := range initFuncs {
 		fn(s)
 	}
+	if features.LocalityPriorityExpression != "" {
+		fn, err := loadbalancer.NewCELLocalityPriorityFunc(features.LocalityPriorityExpression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PILOT_LOCALITY_PRIORITY_EXPRESSION: %v", err)
+		}
+		loadbalancer.SetLocalityPriorityFunc(fn)
+	}
 	// Initialize workload Trust Bundle before XDS Server
 	e.TrustBundle = s.workloadTrustBundle
 	s.XDSServer = xds.NewDiscoveryServer(e, args.RegistryOptions.KubeOptions.ClusterAliases)
@@ -716,6 +724,7 @@ func (s *Server) initGrpcServer(options *istiokeepalive.Options) {
 		grpcprom.UnaryServerInterceptor,
 	}
 	grpcOptions := istiogrpc.ServerOptions(options, interceptors...)
+	grpcOptions = append(grpcOptions, grpc.StatsHandler(xds.WireStatsHandler{}))
 	s.grpcServer = grpc.NewServer(grpcOptions...)
 	s.XDSServer.Register(s.grpcServer)
 	reflection.Register(s.grpcServer)
@@ -762,7 +771,7 @@ func (s *Server) initSecureDiscoveryService(args *PilotArgs) error {
 		grpcprom.UnaryServerInterceptor,
 	}
 	opts := istiogrpc.ServerOptions(args.KeepaliveOptions, interceptors...)
-	opts = append(opts, grpc.Creds(tlsCreds))
+	opts = append(opts, grpc.Creds(tlsCreds), grpc.StatsHandler(xds.WireStatsHandler{}))
 
 	s.secureGrpcServer = grpc.NewServer(opts...)
 	s.XDSServer.Register(s.secureGrpcServer)
@@ -1107,6 +1116,8 @@ func (s *Server) initControllers(args *PilotArgs) error {
 	if err := s.initConfigController(args); err != nil {
 		return fmt.Errorf("error initializing config controller: %v", err)
 	}
+	s.initSidecarSuggestions(args)
+	s.initEDSMeshCanary(args)
 	if err := s.initServiceControllers(args); err != nil {
 		return fmt.Errorf("error initializing service controllers: %v", err)
 	}
@@ -38,6 +38,7 @@ func (s *Server) initServiceControllers(args *PilotArgs) error {
 		serviceentry.WithClusterID(s.clusterID),
 	)
 	serviceControllers.AddRegistry(s.serviceEntryController)
+	s.XDSServer.ApplyWorkloadEntryBatch = s.serviceEntryController.ApplyWorkloadEntryBatch
 
 	registered := sets.New[provider.ID]()
 	for _, r := range args.RegistryOptions.Registries {
@@ -74,6 +75,7 @@ func (s *Server) initKubeRegistry(args *PilotArgs) (err error) {
 	args.RegistryOptions.KubeOptions.XDSUpdater = s.XDSServer
 	args.RegistryOptions.KubeOptions.MeshNetworksWatcher = s.environment.NetworksWatcher
 	args.RegistryOptions.KubeOptions.MeshWatcher = s.environment.Watcher
+	args.RegistryOptions.KubeOptions.RolloutWeights = s.environment.RolloutWeights
 	args.RegistryOptions.KubeOptions.SystemNamespace = args.Namespace
 	args.RegistryOptions.KubeOptions.MeshServiceController = s.ServiceController()
 	// pass namespace to k8s service registry
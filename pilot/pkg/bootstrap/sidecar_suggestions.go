@@ -0,0 +1,156 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// sidecarSuggestionsConfigMap is the ConfigMap of each namespace holding the Sidecar egress hosts suggested for
+// its sidecars, keyed by the name of the istiod pod the sidecars are connected to.
+const sidecarSuggestionsConfigMap = "istio-sidecar-suggestions"
+
+// staleSidecarSuggestionIntervals is the number of intervals after which the suggestions not updated, such as the
+// ones of the istiod pods which no longer exist, are removed.
+const staleSidecarSuggestionIntervals = 3
+
+// initSidecarSuggestions periodically publishes the suggested Sidecar egress hosts of each namespace, if
+// PILOT_SIDECAR_SUGGESTIONS_INTERVAL is set.
+func (s *Server) initSidecarSuggestions(args *PilotArgs) {
+	if features.SidecarSuggestionsInterval <= 0 || s.kubeClient == nil {
+		return
+	}
+	s.addStartFunc("sidecar suggestions", func(stop <-chan struct{}) error {
+		go func() {
+			ticker := time.NewTicker(features.SidecarSuggestionsInterval)
+			defer ticker.Stop()
+			published := sets.New[string]()
+			for {
+				select {
+				case <-ticker.C:
+					published = s.publishSidecarSuggestions(args.PodName, s.XDSServer.SidecarSuggestions(), published, time.Now())
+				case <-stop:
+					return
+				}
+			}
+		}()
+		return nil
+	})
+}
+
+// publishSidecarSuggestions stores the suggestions of the pod, and removes the ones of the namespaces previously
+// published without suggestions anymore. It returns the namespaces published.
+func (s *Server) publishSidecarSuggestions(podName string, suggestions []xds.SidecarSuggestion, published sets.String,
+	now time.Time,
+) sets.String {
+	current := sets.NewWithLength[string](len(suggestions))
+	for _, suggestion := range suggestions {
+		suggestion.Updated = now
+		value, err := json.Marshal(suggestion)
+		if err != nil {
+			log.Warnf("failed to publish sidecar suggestions for namespace %s: %v", suggestion.Namespace, err)
+			continue
+		}
+		current.Insert(suggestion.Namespace)
+		if err := s.updateSidecarSuggestions(suggestion.Namespace, now, func(data map[string]string) {
+			data[podName] = string(value)
+		}); err != nil {
+			log.Warnf("failed to publish sidecar suggestions for namespace %s: %v", suggestion.Namespace, err)
+		}
+	}
+	for ns := range published.Difference(current) {
+		if err := s.updateSidecarSuggestions(ns, now, func(data map[string]string) {
+			delete(data, podName)
+		}); err != nil {
+			log.Warnf("failed to remove sidecar suggestions for namespace %s: %v", ns, err)
+		}
+	}
+	return current
+}
+
+// updateSidecarSuggestions applies the update to the suggestions of the namespace, removes the stale suggestions,
+// and writes them back, retrying on conflicts with the other istiod pods. The ConfigMap is deleted once empty.
+func (s *Server) updateSidecarSuggestions(namespace string, now time.Time, update func(data map[string]string)) error {
+	configMaps := s.kubeClient.Kube().CoreV1().ConfigMaps(namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := configMaps.Get(context.TODO(), sidecarSuggestionsConfigMap, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			data := map[string]string{}
+			update(data)
+			if len(data) == 0 {
+				return nil
+			}
+			_, err = configMaps.Create(context.TODO(), &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      sidecarSuggestionsConfigMap,
+					Namespace: namespace,
+					Labels:    map[string]string{"istio.io/config": "true"},
+				},
+				Data: data,
+			}, metav1.CreateOptions{})
+			if kerrors.IsAlreadyExists(err) {
+				// Created by another istiod pod in the meantime: retry as a conflict.
+				return kerrors.NewConflict(v1.Resource("configmaps"), sidecarSuggestionsConfigMap, err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		data := make(map[string]string, len(cm.Data))
+		for k, v := range cm.Data {
+			data[k] = v
+		}
+		update(data)
+		pruneSidecarSuggestions(data, now)
+		if len(data) > 0 && reflect.DeepEqual(data, cm.Data) {
+			return nil
+		}
+		if len(data) == 0 {
+			return configMaps.Delete(context.TODO(), sidecarSuggestionsConfigMap, metav1.DeleteOptions{
+				Preconditions: &metav1.Preconditions{ResourceVersion: &cm.ResourceVersion},
+			})
+		}
+		cm = cm.DeepCopy()
+		cm.Data = data
+		_, err = configMaps.Update(context.TODO(), cm, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// pruneSidecarSuggestions removes the suggestions which were not updated for staleSidecarSuggestionIntervals
+// intervals, or cannot be parsed.
+func pruneSidecarSuggestions(data map[string]string, now time.Time) {
+	staleBefore := now.Add(-staleSidecarSuggestionIntervals * features.SidecarSuggestionsInterval)
+	for k, v := range data {
+		var suggestion xds.SidecarSuggestion
+		if err := json.Unmarshal([]byte(v), &suggestion); err != nil || suggestion.Updated.Before(staleBefore) {
+			delete(data, k)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/util/sets"
+)
+
+func TestPublishSidecarSuggestions(t *testing.T) {
+	test.SetForTest(t, &features.SidecarSuggestionsInterval, time.Minute)
+	now := time.Now()
+	suggestion := func(ns string, updated time.Time) string {
+		b, err := json.Marshal(xds.SidecarSuggestion{Namespace: ns, Updated: updated})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	s := &Server{kubeClient: kube.NewFakeClient(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: sidecarSuggestionsConfigMap, Namespace: "ns1"},
+		Data: map[string]string{
+			"istiod-gone":  suggestion("ns1", now.Add(-10*time.Minute)),
+			"istiod-other": suggestion("ns1", now.Add(-time.Minute)),
+		},
+	})}
+	keys := func(ns string) []string {
+		t.Helper()
+		cm, err := s.kubeClient.Kube().CoreV1().ConfigMaps(ns).Get(context.TODO(), sidecarSuggestionsConfigMap, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		assert.NoError(t, err)
+		return sets.SortedList(sets.New(maps.Keys(cm.Data)...))
+	}
+
+	published := s.publishSidecarSuggestions("istiod", []xds.SidecarSuggestion{{Namespace: "ns1"}, {Namespace: "ns2"}},
+		sets.New[string](), now)
+	assert.Equal(t, sets.SortedList(published), []string{"ns1", "ns2"})
+	// The suggestions of the istiod pods no longer updating them are removed.
+	assert.Equal(t, keys("ns1"), []string{"istiod", "istiod-other"})
+	assert.Equal(t, keys("ns2"), []string{"istiod"})
+
+	// The suggestions of the namespaces without suggestions anymore are removed, with the ConfigMap once empty.
+	published = s.publishSidecarSuggestions("istiod", []xds.SidecarSuggestion{{Namespace: "ns1"}}, published, now)
+	assert.Equal(t, sets.SortedList(published), []string{"ns1"})
+	assert.Equal(t, keys("ns1"), []string{"istiod", "istiod-other"})
+	assert.Equal(t, keys("ns2"), nil)
+}
@@ -16,6 +16,7 @@ package bootstrap
 
 import (
 	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/xds"
 	"istio.io/istio/pkg/config/schema/collections"
 	"istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/webhooks/validation/controller"
@@ -34,6 +35,13 @@ func (s *Server) initConfigValidation(args *PilotArgs) error {
 		DomainSuffix: args.RegistryOptions.KubeOptions.DomainSuffix,
 		Mux:          s.httpsMux,
 	}
+	switch features.DestinationRuleDryRun {
+	case xds.DestinationRuleDryRunWarn, xds.DestinationRuleDryRunReject:
+		params.Check = s.XDSServer.DryRunDestinationRule
+	case "":
+	default:
+		log.Warnf("ignoring invalid PILOT_DESTINATION_RULE_DRY_RUN %q: must be warn or reject", features.DestinationRuleDryRun)
+	}
 	_, err := server.New(params)
 	if err != nil {
 		return err
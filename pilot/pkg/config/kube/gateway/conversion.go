@@ -41,6 +41,7 @@ import (
 	"istio.io/istio/pkg/config/schema/kind"
 	"istio.io/istio/pkg/ptr"
 	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/util/protomarshal"
 	"istio.io/istio/pkg/util/sets"
 )
 
@@ -113,6 +114,8 @@ func convertReferencePolicies(r GatewayResources) AllowedReferences {
 				fromKey.Kind = gvk.KubernetesGateway
 			} else if string(from.Group) == gvk.HTTPRoute.Group && string(from.Kind) == gvk.HTTPRoute.Kind {
 				fromKey.Kind = gvk.HTTPRoute
+			} else if string(from.Group) == gvk.GRPCRoute.Group && string(from.Kind) == gvk.GRPCRoute.Kind {
+				fromKey.Kind = gvk.GRPCRoute
 			} else if string(from.Group) == gvk.TLSRoute.Group && string(from.Kind) == gvk.TLSRoute.Kind {
 				fromKey.Kind = gvk.TLSRoute
 			} else if string(from.Group) == gvk.TCPRoute.Group && string(from.Kind) == gvk.TCPRoute.Kind {
@@ -242,7 +245,7 @@ func convertHTTPRoute(r k8s.HTTPRouteRule, ctx configContext,
 		case k8sbeta.HTTPRouteFilterRequestRedirect:
 			vs.Redirect = createRedirectFilter(filter.RequestRedirect)
 		case k8sbeta.HTTPRouteFilterRequestMirror:
-			mirror, err := createMirrorFilter(ctx, filter.RequestMirror, obj.Namespace, enforceRefGrant)
+			mirror, err := createMirrorFilter(ctx, filter.RequestMirror, gvk.HTTPRoute, obj.Namespace, enforceRefGrant)
 			if err != nil {
 				return nil, err
 			}
@@ -277,7 +280,7 @@ func convertHTTPRoute(r k8s.HTTPRouteRule, ctx configContext,
 func convertGRPCRoute(r k8s.GRPCRouteRule, ctx configContext,
 	obj config.Config, pos int, enforceRefGrant bool,
 ) (*istio.HTTPRoute, *ConfigError) {
-	// TODO: implement rewrite, timeout, mirror, corspolicy, retries
+	// Timeouts, retries, fault injection and CORS are configured with route policies, see applyRoutePolicy.
 	vs := &istio.HTTPRoute{}
 	// Auto-name the route. If upstream defines an explicit name, will use it instead
 	// The position within the route is unique
@@ -318,11 +321,15 @@ func convertGRPCRoute(r k8s.GRPCRouteRule, ctx configContext,
 			}
 			vs.Headers.Response = h
 		case k8s.GRPCRouteFilterRequestMirror:
-			mirror, err := createMirrorFilter(ctx, filter.RequestMirror, obj.Namespace, enforceRefGrant)
+			mirror, err := createMirrorFilter(ctx, filter.RequestMirror, gvk.GRPCRoute, obj.Namespace, enforceRefGrant)
 			if err != nil {
 				return nil, err
 			}
 			vs.Mirrors = append(vs.Mirrors, mirror)
+		case k8s.GRPCRouteFilterExtensionRef:
+			if err := applyRoutePolicy(vs, filter.ExtensionRef, obj); err != nil {
+				return nil, err
+			}
 		default:
 			return nil, &ConfigError{
 				Reason:  InvalidFilter,
@@ -1187,7 +1194,7 @@ func buildTCPDestination(
 	var invalidBackendErr *ConfigError
 	res := []*istio.RouteDestination{}
 	for i, fwd := range action {
-		dst, err := buildDestination(ctx, fwd, ns, enforceRefGrant)
+		dst, err := buildDestination(ctx, fwd, gvk.HTTPRoute, ns, enforceRefGrant)
 		if err != nil {
 			if isInvalidBackend(err) {
 				invalidBackendErr = err
@@ -1272,7 +1279,7 @@ func buildHTTPDestination(
 	var invalidBackendErr *ConfigError
 	res := []*istio.HTTPRouteDestination{}
 	for i, fwd := range action {
-		dst, err := buildDestination(ctx, fwd.BackendRef, ns, enforceRefGrant)
+		dst, err := buildDestination(ctx, fwd.BackendRef, gvk.HTTPRoute, ns, enforceRefGrant)
 		if err != nil {
 			if isInvalidBackend(err) {
 				invalidBackendErr = err
@@ -1340,7 +1347,7 @@ func buildGRPCDestination(
 	var invalidBackendErr *ConfigError
 	res := []*istio.HTTPRouteDestination{}
 	for i, fwd := range action {
-		dst, err := buildDestination(ctx, fwd.BackendRef, ns, enforceRefGrant)
+		dst, err := buildDestination(ctx, fwd.BackendRef, gvk.GRPCRoute, ns, enforceRefGrant)
 		if err != nil {
 			if isInvalidBackend(err) {
 				invalidBackendErr = err
@@ -1373,6 +1380,14 @@ func buildGRPCDestination(
 					rd.Headers = &istio.Headers{}
 				}
 				rd.Headers.Response = h
+			case k8s.GRPCRouteFilterExtensionRef:
+				subset, err := backendSubset(filter.ExtensionRef)
+				if err != nil {
+					return nil, nil, err
+				}
+				if rd.Destination != nil {
+					rd.Destination.Subset = subset
+				}
 			default:
 				return nil, nil, &ConfigError{Reason: InvalidFilter, Message: fmt.Sprintf("unsupported filter type %q", filter.Type)}
 			}
@@ -1382,12 +1397,14 @@ func buildGRPCDestination(
 	return res, invalidBackendErr, nil
 }
 
-func buildDestination(ctx configContext, to k8s.BackendRef, ns string, enforceRefGrant bool) (*istio.Destination, *ConfigError) {
+func buildDestination(ctx configContext, to k8s.BackendRef, k config.GroupVersionKind, ns string,
+	enforceRefGrant bool,
+) (*istio.Destination, *ConfigError) {
 	// check if the reference is allowed
 	if enforceRefGrant {
 		refs := ctx.AllowedReferences
 		if toNs := to.Namespace; toNs != nil && string(*toNs) != ns {
-			if !refs.BackendAllowed(gvk.HTTPRoute, to.Name, *toNs, ns) {
+			if !refs.BackendAllowed(k, to.Name, *toNs, ns) {
 				return &istio.Destination{}, &ConfigError{
 					Reason:  InvalidDestinationPermit,
 					Message: fmt.Sprintf("backendRef %v/%v not accessible to a route in namespace %q (missing a ReferenceGrant?)", to.Name, *toNs, ns),
@@ -1487,7 +1504,9 @@ func headerListToMap(hl []k8s.HTTPHeader) map[string]string {
 	return res
 }
 
-func createMirrorFilter(ctx configContext, filter *k8s.HTTPRequestMirrorFilter, ns string, enforceRefGrant bool) (*istio.HTTPMirrorPolicy, *ConfigError) {
+func createMirrorFilter(ctx configContext, filter *k8s.HTTPRequestMirrorFilter, k config.GroupVersionKind, ns string,
+	enforceRefGrant bool,
+) (*istio.HTTPMirrorPolicy, *ConfigError) {
 	if filter == nil {
 		return nil, nil
 	}
@@ -1495,13 +1514,63 @@ func createMirrorFilter(ctx configContext, filter *k8s.HTTPRequestMirrorFilter,
 	dst, err := buildDestination(ctx, k8s.BackendRef{
 		BackendObjectReference: filter.BackendRef,
 		Weight:                 &weightOne,
-	}, ns, enforceRefGrant)
+	}, k, ns, enforceRefGrant)
 	if err != nil {
 		return nil, err
 	}
 	return &istio.HTTPMirrorPolicy{Destination: dst}, nil
 }
 
+// applyRoutePolicy applies the route policy attached to a rule by an ExtensionRef filter of kind RoutePolicy.
+// The policy is defined by the networking.istio.io/route-policy.<name> annotation of the route, as a JSON Istio
+// HTTPRoute of which the timeout, retries, fault injection and CORS policy are used. As gRPC methods are matched
+// by rules, this allows configuring them per method.
+func applyRoutePolicy(vs *istio.HTTPRoute, ref *k8s.LocalObjectReference, obj config.Config) *ConfigError {
+	if ref == nil || string(ref.Group) != gvk.DestinationRule.Group || string(ref.Kind) != routePolicyKind {
+		return unsupportedExtensionRef(ref)
+	}
+	js, f := obj.Annotations[routePolicyAnnotationPrefix+string(ref.Name)]
+	if !f {
+		return &ConfigError{
+			Reason:  InvalidFilter,
+			Message: fmt.Sprintf("route policy %q not found: missing annotation %s%s", ref.Name, routePolicyAnnotationPrefix, ref.Name),
+		}
+	}
+	policy := &istio.HTTPRoute{}
+	if err := protomarshal.UnmarshalString(js, policy); err != nil {
+		return &ConfigError{Reason: InvalidFilter, Message: fmt.Sprintf("invalid route policy %q: %v", ref.Name, err)}
+	}
+	if policy.Timeout != nil {
+		vs.Timeout = policy.Timeout
+	}
+	if policy.Retries != nil {
+		vs.Retries = policy.Retries
+	}
+	if policy.Fault != nil {
+		vs.Fault = policy.Fault
+	}
+	if policy.CorsPolicy != nil {
+		vs.CorsPolicy = policy.CorsPolicy
+	}
+	return nil
+}
+
+// backendSubset returns the DestinationRule subset selected by an ExtensionRef filter of kind Subset, which allows
+// splitting the traffic between subsets of a service with weighted backends.
+func backendSubset(ref *k8s.LocalObjectReference) (string, *ConfigError) {
+	if ref == nil || string(ref.Group) != gvk.DestinationRule.Group || string(ref.Kind) != subsetKind {
+		return "", unsupportedExtensionRef(ref)
+	}
+	return string(ref.Name), nil
+}
+
+func unsupportedExtensionRef(ref *k8s.LocalObjectReference) *ConfigError {
+	if ref == nil {
+		return &ConfigError{Reason: InvalidFilter, Message: "extensionRef is required"}
+	}
+	return &ConfigError{Reason: InvalidFilter, Message: fmt.Sprintf("unsupported extensionRef %s/%s", ref.Group, ref.Kind)}
+}
+
 func createRewriteFilter(filter *k8s.HTTPURLRewriteFilter) *istio.HTTPRewrite {
 	if filter == nil {
 		return nil
@@ -368,6 +368,7 @@ func TestConvertResources(t *testing.T) {
 		{"tcp"},
 		{"tls"},
 		{"grpc"},
+		{"grpc-policy"},
 		{"mismatch"},
 		{"weighted"},
 		{"zero"},
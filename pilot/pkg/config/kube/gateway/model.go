@@ -33,6 +33,11 @@ const (
 	gatewayNameOverride          = "gateway.istio.io/name-override"
 	gatewaySAOverride            = "gateway.istio.io/service-account"
 	serviceTypeOverride          = "networking.istio.io/service-type"
+	routePolicyAnnotationPrefix  = "networking.istio.io/route-policy."
+	// routePolicyKind and subsetKind are the kinds of ExtensionRef filters, in the networking.istio.io group,
+	// attaching a route policy to a GRPCRoute rule and selecting the DestinationRule subset of a backend.
+	routePolicyKind = "RoutePolicy"
+	subsetKind      = "Subset"
 )
 
 // GatewayResources stores all gateway resources used for our conversion.
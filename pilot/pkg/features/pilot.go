@@ -96,6 +96,22 @@ var (
 			" EDS pushes may be delayed, but there will be fewer pushes. By default this is enabled",
 	).Get()
 
+	LocalityPriorityExpression = env.Register(
+		"PILOT_LOCALITY_PRIORITY_EXPRESSION",
+		"",
+		"If set, a CEL expression of type int replacing the priority of the endpoints of each locality with locality "+
+			"failover, for orderings the region/zone/subzone match cannot express, such as paired regions. It can use "+
+			"the proxy and endpoint localities, as maps with the region, zone and subzone keys, and the default priority.",
+	).Get()
+
+	EDSLastKnownGoodGrace = env.Register(
+		"PILOT_EDS_LAST_KNOWN_GOOD_GRACE",
+		time.Duration(0),
+		"If set, when no registry has reported the endpoints of a service yet, as during a resync of a cluster, the "+
+			"proxies keep receiving the last non-empty endpoints of its clusters for up to this duration, instead of "+
+			"empty ones. Services whose registries report no endpoints are not affected.",
+	).Get()
+
 	SendUnhealthyEndpoints = atomic.NewBool(env.Register(
 		"PILOT_SEND_UNHEALTHY_ENDPOINTS",
 		false,
@@ -122,12 +138,159 @@ var (
 		"If not empty, services with this label will use header based persistent sessions",
 	).Get()
 
+	PersistentSessionDrainTTL = env.Register(
+		"PILOT_PERSISTENT_SESSION_DRAIN_TTL",
+		time.Duration(0),
+		"If set, the draining endpoints of services using persistent sessions are removed this long after they "+
+			"started draining, ending the sessions still pinned to them. By default, they are kept until their "+
+			"workload is removed.",
+	).Get()
+
+	EnableEndpointCohorts = env.Register(
+		"PILOT_ENABLE_ENDPOINT_COHORTS",
+		false,
+		"If enabled, the endpoints of services with 'PILOT_ENDPOINT_COHORT_LABEL' set are partitioned into cohorts "+
+			"by the value of the same label on their workloads, and requests are pinned to a cohort by the "+
+			"'PILOT_ENDPOINT_COHORT_HEADER' header. Requests without the header, or for an unknown cohort, "+
+			"are sent to any endpoint.",
+	).Get()
+
+	EndpointCohortLabel = env.Register(
+		"PILOT_ENDPOINT_COHORT_LABEL",
+		"istio.io/cohort",
+		"If not empty, services with this label partition their endpoints into cohorts by the value of the label on "+
+			"the workloads. Requires PILOT_ENABLE_ENDPOINT_COHORTS.",
+	).Get()
+
+	EndpointCohortHeader = env.Register(
+		"PILOT_ENDPOINT_COHORT_HEADER",
+		"x-istio-cohort",
+		"The request header naming the endpoint cohort to send the request to. Requires PILOT_ENABLE_ENDPOINT_COHORTS.",
+	).Get()
+
+	MergeEndpointPorts = env.Register(
+		"PILOT_MERGE_ENDPOINT_PORTS",
+		false,
+		"If enabled, the endpoints of a Kubernetes Service serving several ports are stored as a single endpoint "+
+			"per address carrying all its ports, instead of one endpoint per port, reducing the memory and the "+
+			"updates of the endpoint index for multi-port services.",
+	).Get()
+
+	EnableEndpointMigration = env.Register(
+		"PILOT_ENABLE_ENDPOINT_MIGRATION",
+		false,
+		"If enabled, when the address of a pod or WorkloadEntry changes, for example after a live migration, "+
+			"the endpoint of its previous address is kept as DRAINING until the next update of the endpoints of "+
+			"its cluster, and the old and new endpoints are linked through their load balancer metadata.",
+	).Get()
+
+	EnableScaleUpRamp = env.Register(
+		"PILOT_ENABLE_SCALE_UP_RAMP",
+		false,
+		"If enabled, istiod records when endpoints first appear, and the endpoints added by large scale-ups of "+
+			"services with the networking.istio.io/scale-up-ramp annotation are introduced in waves, with ramping "+
+			"weights, over the annotated window.",
+	).Get()
+
+	EnablePrecomputedEndpoints = env.Register(
+		"PILOT_ENABLE_PRECOMPUTED_ENDPOINTS",
+		true,
+		"If enabled, the Envoy endpoints built for CDS are cached on the endpoints and reused until they change. "+
+			"Disable it if extensions modify endpoints in ways the cached endpoints miss. It can also be disabled "+
+			"for a single service with the networking.istio.io/precompute-endpoints: \"false\" annotation.",
+	).Get()
+
+	EnableTopologyAwareHints = env.Register(
+		"PILOT_ENABLE_TOPOLOGY_AWARE_HINTS",
+		false,
+		"If enabled, the endpoints of Kubernetes services with the service.kubernetes.io/topology-mode: Auto annotation "+
+			"are filtered by their zone hints, as kube-proxy does. All the endpoints are sent when the hints cannot be used. "+
+			"Proxies then no longer fail over to the endpoints of other zones, unlike with locality load balancing, so this "+
+			"is disabled by default to keep the behavior of existing services with the annotation on upgrade.",
+	).Get()
+
+	EnablePassthroughEndpointMetadata = env.Register(
+		"PILOT_ENABLE_PASSTHROUGH_ENDPOINT_METADATA",
+		false,
+		"If enabled, outbound traffic to an endpoint of a ServiceEntry that matches no listener goes through the "+
+			"PassthroughEndpointsCluster instead of the PassthroughCluster, so the telemetry of the connection carries the "+
+			"metadata of the endpoint. The sidecars have a filter chain for each endpoint address and port, so the changes "+
+			"of the addresses and ports of the endpoints of ServiceEntries trigger full pushes; their other changes are "+
+			"pushed by EDS.",
+	).Get()
+
+	CanonicalizeEndpoints = env.Register(
+		"PILOT_CANONICALIZE_ENDPOINTS",
+		false,
+		"If enabled, the endpoints of a ClusterLoadAssignment are sorted by priority, locality and address, "+
+			"and their empty metadata is dropped, so istiod revisions with the same endpoints send identical EDS "+
+			"responses. Enable it on both revisions during a canary upgrade to avoid churn when proxies migrate.",
+	).Get()
+
+	EDSSoftLimitEndpoints = env.Register("PILOT_EDS_SOFT_LIMIT_ENDPOINTS", 0,
+		"If greater than 0, a warning is logged and the pilot_eds_cla_size_limit_exceeded metric is incremented "+
+			"when a ClusterLoadAssignment has more endpoints.").Get()
+
+	EDSHardLimitEndpoints = env.Register("PILOT_EDS_HARD_LIMIT_ENDPOINTS", 0,
+		"If greater than 0, a ClusterLoadAssignment with more endpoints is truncated to a deterministic subset of "+
+			"this many endpoints, so that pathological services cannot exhaust the memory of istiod or the proxies. "+
+			"The remaining endpoints carry the number of endpoints before truncation in their istio metadata.").Get()
+
+	EDSSoftLimitBytes = env.Register("PILOT_EDS_SOFT_LIMIT_BYTES", 0,
+		"If greater than 0, a warning is logged and the pilot_eds_cla_size_limit_exceeded metric is incremented "+
+			"when the serialized ClusterLoadAssignment is larger.").Get()
+
+	EDSHardLimitBytes = env.Register("PILOT_EDS_HARD_LIMIT_BYTES", 0,
+		"If greater than 0, a ClusterLoadAssignment whose serialized size is larger is truncated to a deterministic "+
+			"subset of its endpoints fitting in this many bytes, as for PILOT_EDS_HARD_LIMIT_ENDPOINTS.").Get()
+
+	EnableEDSPrecomputation = env.Register("PILOT_ENABLE_EDS_PRECOMPUTATION", false,
+		"If enabled, the ClusterLoadAssignments of a service are rebuilt in the background when its endpoints change, "+
+			"for each distinct ClusterLoadAssignment recently built for a connected proxy, so that the pushes following "+
+			"the update are served from the XDS cache. Note: this depends on PILOT_ENABLE_XDS_CACHE, and is ignored when "+
+			"PILOT_EDS_LAST_KNOWN_GOOD_GRACE is set.").Get()
+
+	EDSPrecomputationWorkers = env.Register("PILOT_EDS_PRECOMPUTATION_WORKERS", 4,
+		"The number of workers rebuilding ClusterLoadAssignments in the background, when PILOT_ENABLE_EDS_PRECOMPUTATION "+
+			"is enabled.").Get()
+
+	MaxDirectPodClusters = env.Register("PILOT_MAX_DIRECT_POD_CLUSTERS", 100,
+		"The maximum number of endpoints of a service port with the networking.istio.io/direct-pod-clusters annotation "+
+			"getting a cluster each. The ports with more endpoints get no pod cluster, and their pods are reached through "+
+			"the PassthroughCluster.").Get()
+
 	DrainingLabel = env.Register(
 		"PILOT_DRAINING_LABEL",
 		"istio.io/draining",
 		"If not empty, endpoints with the label value present will be sent with status DRAINING.",
 	).Get()
 
+	IncludeTerminatingEndpoints = env.Register(
+		"PILOT_INCLUDE_TERMINATING_ENDPOINTS",
+		"Never",
+		"Controls whether the endpoints of terminating pods which are still serving are sent: Always, WhenNoReady, "+
+			"when the service has no ready endpoint, or Never. It can be overridden for a Service with the "+
+			"networking.istio.io/include-terminating annotation. Services with persistent sessions always receive "+
+			"them with status DRAINING.",
+	).Get()
+
+	CrossNetworkFailover = env.Register(
+		"PILOT_CROSS_NETWORK_FAILOVER",
+		"Weighted",
+		"Controls how the endpoints of remote networks, reached through their network gateways, are load balanced: "+
+			"Weighted, along with the local endpoints according to their weights, or Priority, at a lower priority "+
+			"than the local endpoints, so that cross-network traffic only happens when the local endpoints are "+
+			"unhealthy. It can be overridden for a Service with the networking.istio.io/cross-network-failover annotation.",
+	).Get()
+
+	CompletedPodEndpoints = env.Register(
+		"PILOT_COMPLETED_POD_ENDPOINTS",
+		"exclude",
+		"Controls how the endpoints of completed pods, in phase Succeeded or Failed such as the pods of Jobs, are handled "+
+			"while they linger in EndpointSlices: 'exclude' to drop them, or 'unhealthy' to keep them with an unhealthy "+
+			"status. They never receive traffic.",
+	).Get()
+
 	// HTTP10 will add "accept_http_10" to http outbound listeners. Can also be set only for specific sidecars via meta.
 	HTTP10 = env.Register(
 		"PILOT_HTTP10",
@@ -256,6 +419,24 @@ var (
 			"that ENABLE_MCS_SERVICE_DISCOVERY also be enabled.").Get() &&
 		EnableMCSServiceDiscovery
 
+	EnableClusterSetServiceResolution = env.Register(
+		"PILOT_ENABLE_CLUSTERSET_SERVICE_RESOLUTION",
+		false,
+		"If enabled, the ClusterSet host (<svc>.<namespace>.svc.clusterset.local) of a Service exported with a "+
+			"ServiceExport, such as a ServiceImport referenced by a Gateway API route, gets its clusters and endpoints "+
+			"even if no cluster of the mesh imports it. Requires that ENABLE_MCS_HOST also be enabled.").Get() &&
+		EnableMCSHost
+
+	EnableMCSServiceImportProperties = env.Register(
+		"ENABLE_MCS_SERVICE_IMPORT_PROPERTIES",
+		false,
+		"If enabled, the ClusterSet host (<svc>.<namespace>.svc.clusterset.local) of a ServiceImport uses the ports "+
+			"and the session affinity of the ServiceImport, which the MCS controller resolved from the exported "+
+			"Services of all the clusters, instead of those of the Service of one cluster. ClientIP session affinity "+
+			"is honored with a consistent hash on the source IP when no DestinationRule sets a load balancer. "+
+			"Requires that ENABLE_MCS_HOST also be enabled.").Get() &&
+		EnableMCSHost
+
 	EnableMCSClusterLocal = env.Register(
 		"ENABLE_MCS_CLUSTER_LOCAL",
 		false,
@@ -377,6 +558,21 @@ var (
 		"If enabled, pilot will authorize XDS clients, to ensure they are acting only as namespaces they have permissions for.",
 	).Get()
 
+	DestinationRuleDryRun = env.Register(
+		"PILOT_DESTINATION_RULE_DRY_RUN",
+		"",
+		"If set to warn or reject, the validation webhook builds the endpoints of the subsets of the DestinationRules "+
+			"being applied for a sample of the connected proxies, and warns about or rejects the DestinationRules with "+
+			"subsets selecting no endpoints for any of them, while the service has endpoints.",
+	).Get()
+
+	EnableReadOnlyEDS = env.Register(
+		"PILOT_ENABLE_READ_ONLY_EDS",
+		false,
+		"If enabled, authenticated clients outside of the mesh, such as external load balancers, can subscribe to the "+
+			"endpoints of the services exported to their namespace by setting the GENERATOR node metadata to \"readonly\".",
+	).Get()
+
 	// TODO: Move this to proper API.
 	trustedGatewayCIDR = env.Register(
 		"TRUSTED_GATEWAY_CIDR",
@@ -451,6 +647,11 @@ var (
 	EnableRDSCaching = env.Register("PILOT_ENABLE_RDS_CACHE", true,
 		"If true, Pilot will cache RDS responses. Note: this depends on PILOT_ENABLE_XDS_CACHE.").Get()
 
+	// EnableEDSDeduplication determines if byte-identical cached EDS responses share a single copy.
+	EnableEDSDeduplication = env.Register("PILOT_ENABLE_EDS_DEDUPLICATION", true,
+		"If true, ClusterLoadAssignments in the XDS cache which are byte-identical, for example because they were built "+
+			"for proxies in different localities without locality load balancing, share a single copy.").Get()
+
 	EnableXDSCacheMetrics = env.Register("PILOT_XDS_CACHE_STATS", false,
 		"If true, Pilot will collect metrics for XDS cache efficiency.").Get()
 
@@ -473,6 +674,22 @@ var (
 			"Setting the timeout to 0 disables this behavior.",
 	).Get()
 
+	RemoteClusterTunnelURL = env.Register(
+		"PILOT_REMOTE_CLUSTER_TUNNEL_URL",
+		"",
+		"If set, requests and watches to the API servers of clusters added via remote-secrets go through the "+
+			"tunnel proxy at this URL, for istiod running outside of the clusters. Credentials for the tunnel are "+
+			"taken from the user info of the URL. Clusters whose kubeconfig sets a proxy-url are not affected.",
+	).Get()
+
+	ResumeRemoteClusterShards = env.Register(
+		"PILOT_RESUME_REMOTE_CLUSTER_SHARDS",
+		false,
+		"If enabled, the endpoints of a remote cluster are kept when its clients are recreated, for instance after the "+
+			"tunnel credentials rotate, and only the endpoints that changed meanwhile are updated once the cluster is "+
+			"synced again, instead of removing and resyncing all of them.",
+	).Get()
+
 	EnableTelemetryLabel = env.Register("PILOT_ENABLE_TELEMETRY_LABEL", true,
 		"If true, pilot will add telemetry related metadata to cluster and endpoint resources, which will be consumed by telemetry filter.",
 	).Get()
@@ -499,6 +716,11 @@ var (
 	WorkloadEntryHealthChecks = env.Register("PILOT_ENABLE_WORKLOAD_ENTRY_HEALTHCHECKS", true,
 		"Enables automatic health checks of WorkloadEntries based on the config provided in the associated WorkloadGroup").Get()
 
+	EnableWorkloadEntryBatchAPI = env.Register("PILOT_ENABLE_WORKLOAD_ENTRY_BATCH_API", false,
+		"If enabled, the /debug/workloadentry_batch endpoint accepts whole fleets of WorkloadEntries at once. "+
+			"Batched entries are diffed against the WorkloadEntries of the fleet and written to the config store. "+
+			"Only localhost, or identities of the namespace of the batch, may post batches.").Get()
+
 	WorkloadEntryCrossCluster = env.Register("PILOT_ENABLE_CROSS_CLUSTER_WORKLOAD_ENTRY", true,
 		"If enabled, pilot will read WorkloadEntry from other clusters, selectable by Services in that cluster.").Get()
 
@@ -537,6 +759,14 @@ var (
 		false,
 		"If enabled, controllers required for ambient will run. This is required to run ambient mesh.").Get()
 
+	// AmbientEastWestHBONE assumes that the east-west gateways of remote networks accept HBONE on port 15008 for the
+	// workloads captured by ztunnel, which have no sidecar to terminate the mTLS of SNI routing.
+	AmbientEastWestHBONE = env.Register(
+		"PILOT_ENABLE_AMBIENT_EAST_WEST_HBONE",
+		false,
+		"If enabled, the endpoints of workloads captured by ztunnel on a remote network are tunneled through "+
+			"port 15008 of the east-west gateways of their network, even if the gateways do not declare an HBONE port.").Get()
+
 	// EnableUnsafeAssertions enables runtime checks to test assertions in our code. This should never be enabled in
 	// production; when assertions fail Istio will panic.
 	EnableUnsafeAssertions = env.Register(
@@ -676,9 +906,222 @@ var (
 	// User should not rely on builtin resource labels, this flag will be removed in future releases(1.20).
 	EnableOTELBuiltinResourceLables = env.Register("ENABLE_OTEL_BUILTIN_RESOURCE_LABELS", false,
 		"If enabled, envoy will send builtin lables(e.g. node_name) via OTel sink.").Get()
+
+	MaxEndpointWeight = env.Register("PILOT_MAX_ENDPOINT_WEIGHT", 0,
+		"If set, the load balancing weights of a service's endpoints are scaled down, keeping their ratios, so that no "+
+			"endpoint weight exceeds this value. Regardless of this setting, weights are always scaled down when the total "+
+			"weight would overflow.").Get()
+
+	MaxEndpointWeightLabel = env.Register("PILOT_MAX_ENDPOINT_WEIGHT_LABEL", "istio.io/max-endpoint-weight",
+		"If not empty, services with this label override PILOT_MAX_ENDPOINT_WEIGHT with the label value.").Get()
+
+	ExternalNameResolutionInterval = env.Register("PILOT_EXTERNAL_NAME_RESOLUTION_INTERVAL", 30*time.Second,
+		"The interval at which istiod resolves the ExternalName Services served through EDS, "+
+			"as configured by the networking.istio.io/external-name-resolution annotation.").Get()
+
+	EnableSNIDNATPortRanges = env.Register("PILOT_ENABLE_SNI_DNAT_PORT_RANGES", false,
+		"If enabled, AUTO_PASSTHROUGH gateways use a single SNI-DNAT cluster for consecutive service ports with the same "+
+			"protocol, instead of one cluster per port. This reduces the number of clusters for services exposing many ports, "+
+			"such as Kafka brokers. Services resolved by DNS or with DestinationRule subsets or port level settings keep one "+
+			"cluster per port.").Get()
+
+	EnableEndpointHostnameMetadata = env.Register("PILOT_ENABLE_ENDPOINT_HOSTNAME_METADATA", false,
+		"If enabled, the endpoints of ServiceEntries, including the ones of their WorkloadEntries, carry the hostname "+
+			"that produced them: the DNS name of the endpoint for DNS resolution, the host of the ServiceEntry otherwise. "+
+			"The hostname is set as the Envoy endpoint hostname and in the load balancer metadata, so that access logs "+
+			"and Dynamic Forward Proxy clusters can report it, and routes can match on it.").Get()
+
+	EnableEndpointHostname = env.Register("PILOT_ENABLE_ENDPOINT_HOSTNAME", false,
+		"If enabled, the Envoy endpoints carry a hostname, so that clusters with auto_host_rewrite rewrite the Host "+
+			"header per endpoint: the hostname that produced the endpoint for ServiceEntries, and the fully qualified "+
+			"hostname of the pod, <hostname>.<subdomain>.<namespace>.svc.<cluster domain>, for the endpoints of "+
+			"Kubernetes services backed by pods with a subdomain.").Get()
+
+	EndpointAccessLogMetadataMaxBytes = env.Register("PILOT_ENDPOINT_ACCESS_LOG_METADATA_MAX_BYTES", 0,
+		"If greater than 0, the endpoints carry the name of their workload instance, such as their pod, and the kind "+
+			"of their owning workload, such as Deployment or StatefulSet, in their istio metadata, along the name and "+
+			"revision of the workload, so that access logs and tracing can record the upstream pod. Each endpoint carries "+
+			"at most this many bytes of them, in that order: the ones which do not fit are left out.").Get()
+
+	EnableEndpointProxyProtocol = env.Register("PILOT_ENABLE_ENDPOINT_PROXY_PROTOCOL", false,
+		"If enabled, outbound clusters send a PROXY protocol header to the endpoints of workloads labeled with "+
+			"networking.istio.io/proxy-protocol, using the label value (v1 or v2) as the protocol version.").Get()
+
+	EnableLoadReportWeights = env.Register("PILOT_ENABLE_LOAD_REPORT_WEIGHTS", false,
+		"If enabled, istiod serves the Load Reporting Service (LRS) and adjusts the endpoint weights of ROUND_ROBIN clusters "+
+			"from the requests in progress reported by the proxies, so that less loaded endpoints receive more traffic. "+
+			"Proxies send load reports when configured with the ISTIO_META_LOAD_STATS_CONFIG_JSON proxy metadata, for example "+
+			`{"api_type":"GRPC","transport_api_version":"V3","grpc_services":[{"envoy_grpc":{"cluster_name":"xds-grpc"}}]}.`).Get()
+
+	LoadReportInterval = env.Register("PILOT_LOAD_REPORT_INTERVAL", 10*time.Second,
+		"The interval at which the proxies send load reports, and at which the endpoint weights are adjusted, "+
+			"if PILOT_ENABLE_LOAD_REPORT_WEIGHTS is enabled.").Get()
+
+	LoadReportMaxWeightFactor = env.Register("PILOT_LOAD_REPORT_MAX_WEIGHT_FACTOR", 4.0,
+		"The bound of the endpoint weight adjustments done from load reports: the weight of an endpoint is at most "+
+			"multiplied or divided by this factor.").Get()
+
+	SidecarSuggestionsInterval = env.Register("PILOT_SIDECAR_SUGGESTIONS_INTERVAL", time.Duration(0),
+		"If set, istiod serves the Load Reporting Service (LRS) and records the clusters each proxy sends requests to. "+
+			"At this interval, it compares them with the clusters the sidecars of each namespace subscribe to, and publishes "+
+			"the suggested Sidecar egress hosts of the namespace in its istio-sidecar-suggestions ConfigMap. The suggestions "+
+			"are not enforced. Proxies send load reports when configured with the ISTIO_META_LOAD_STATS_CONFIG_JSON proxy metadata.").Get()
+
+	MemoryBallastBytes = env.Register("PILOT_MEMORY_BALLAST_BYTES", 0,
+		"The size of a heap allocation istiod keeps for its lifetime, so that the garbage collector runs less often "+
+			"while the live heap is small. The ballast is never touched, so it uses little resident memory. "+
+			"It is bounded by 4GiB and half the memory limit. It can be changed at runtime with POST requests to the "+
+			"/debug/memz endpoint, along with the GC percentage and the memory limit.").Get()
+
+	MemoryAccountingInterval = env.Register("PILOT_MEMORY_ACCOUNTING_INTERVAL", time.Duration(0),
+		"If set, the interval at which the memory used by the endpoint index, the xDS cache, the push queue and "+
+			"the config store is estimated and reported by the pilot_memory_estimated_bytes metric. The estimates "+
+			"are always available from the /debug/memz endpoint.").Get()
+
+	GRPCXdstpAuthority = env.Register("PILOT_GRPC_XDSTP_AUTHORITY", "",
+		"If set, the clusters sent to proxyless gRPC clients reference their endpoints with xdstp:// names of this "+
+			"authority, for clients using xDS federation. The authority must be configured in the bootstrap of the "+
+			"clients, with istiod as server. Envoy proxies keep the legacy names.").Get()
+
+	EnableEndpointCostWeights = env.Register("PILOT_ENABLE_ENDPOINT_COST_WEIGHTS", false,
+		"If enabled, the weight of an endpoint is divided by its cost, as provided by the endpoint cost provider "+
+			"configured in istiod. Otherwise, the costs are only added to the endpoint metadata.").Get()
+
+	EnableEndpointShardSummaries = env.Register("PILOT_ENABLE_ENDPOINT_SHARD_SUMMARIES", false,
+		"If enabled, the endpoint index summarizes the endpoints of each shard by network, so that the incremental "+
+			"endpoint pushes skip building the ClusterLoadAssignments of the proxies that cannot view any of the "+
+			"networks whose endpoints changed.").Get()
+
+	EnableCapacityLocalityWeights = env.Register("PILOT_ENABLE_CAPACITY_LOCALITY_WEIGHTS", false,
+		"If enabled, when all the endpoints of a cluster have a requests per second budget, from the "+
+			"networking.istio.io/capacity-rps pod annotation, the weight of each locality is the sum of the budgets of "+
+			"its endpoints. Otherwise, the budgets are only added to the endpoint metadata.").Get()
+
+	EnableRolloutWeights = env.Register("PILOT_ENABLE_ROLLOUT_WEIGHTS", false,
+		"If enabled, istiod watches the Deployments for the networking.istio.io/rollout-weight annotation, and "+
+			"weights the endpoints of annotated Deployments to receive that percentage of the traffic of their services.").Get()
+
+	PublishNotReadyAddressesHealth = env.Register("PILOT_PUBLISH_NOT_READY_ADDRESSES_HEALTH", "healthy",
+		"How the not ready endpoints of services with publishNotReadyAddresses are sent to proxies: 'healthy' to send "+
+			"them as healthy, as Kubernetes does, or 'unhealthy' to send them with an unhealthy status. It can be overridden by "+
+			"the networking.istio.io/publish-not-ready-addresses annotation of the DestinationRule of the service.").Get()
+
+	EDSMeshCanaryPercent = env.Register("PILOT_EDS_MESH_CANARY_PERCENT", 0,
+		"If set, changes to the meshConfig settings affecting the endpoints (localityLbSetting and the cluster-local "+
+			"serviceSettings) first apply to this percentage of the proxies, picked by the hash of their ID. The other proxies "+
+			"keep the previous settings for their endpoints and clusters until the rollout is promoted with the "+
+			"/debug/eds_mesh_canary endpoint. The rollout is stored in the istio-eds-mesh-canary ConfigMap of the istiod "+
+			"namespace, shared by the istiod replicas. 0 applies the changes to all proxies at once.").Get()
+
+	ProxyLocalityPolicy = env.Register("PILOT_PROXY_LOCALITY_POLICY", "ignore",
+		"How the locality asserted by proxies with the LOCALITY metadata is used: 'ignore' to ignore it, 'fallback' to use "+
+			"it when the service registry has no locality for the proxy, or 'override' to always prefer it. The asserted "+
+			"locality is used for the proxy, for its pod endpoints, and for the endpoints it registers through a "+
+			"WorkloadGroup. It is only accepted from the proxies whose identity is verified by istiod.").Get()
+
+	// ProxyLocalityAllowedRegions are the regions the proxies may assert, by the namespace of their verified identity.
+	// The regions of the empty namespace are allowed for all namespaces.
+	ProxyLocalityAllowedRegions = func() map[string]sets.String {
+		v := env.Register("PILOT_PROXY_LOCALITY_ALLOWED_REGIONS", "",
+			"Comma separated list of regions proxies may assert with the LOCALITY metadata, either <region> for the proxies "+
+				"of all namespaces or <namespace>=<region> for the proxies of a namespace. If empty, any region is allowed.").Get()
+		return parseProxyLocalityAllowedRegions(v)
+	}()
+
+	CrossNetworkSNIOverrides = func() map[string]string {
+		v := env.Register("PILOT_CROSS_NETWORK_SNI_OVERRIDES", "",
+			"Comma separated list of <cluster ID>=<SNI format> pairs overriding the SNI sent by sidecars to the network "+
+				"gateways of the cluster, for example when their AUTO_PASSTHROUGH servers expect a different SNI. "+
+				"In the format, {sni} is replaced with the default SNI. Invalid pairs are ignored.").Get()
+		if v == "" {
+			return nil
+		}
+		out := map[string]string{}
+		for _, pair := range strings.Split(v, ",") {
+			clusterID, format, ok := strings.Cut(pair, "=")
+			if !ok || clusterID == "" || !strings.Contains(format, "{sni}") {
+				continue
+			}
+			out[clusterID] = format
+		}
+		return out
+	}()
+
+	// EndpointMetadataAnnotations is the table of the annotations mapped to endpoint metadata. It is read from the
+	// environment of istiod, as MeshConfig has no such table.
+	EndpointMetadataAnnotations = func() map[string]string {
+		v := env.Register("PILOT_ENDPOINT_METADATA_ANNOTATIONS", "",
+			"Comma separated list of <annotation>=<filter metadata namespace> pairs. The value of each annotation of a pod "+
+				"or WorkloadEntry is added to the metadata of its endpoints, under the annotation name in the given filter metadata "+
+				"namespace, for example my.org/billing-tier=envoy.lb to use it for subset load balancing. Invalid pairs are ignored.").Get()
+		if v == "" {
+			return nil
+		}
+		out := map[string]string{}
+		for _, pair := range strings.Split(v, ",") {
+			annotation, namespace, ok := strings.Cut(pair, "=")
+			if !ok || annotation == "" || namespace == "" {
+				continue
+			}
+			out[annotation] = namespace
+		}
+		return out
+	}()
+
+	EndpointDiscoverabilityMetadataKeys = func() []string {
+		keys := env.Register("PILOT_ENDPOINT_DISCOVERABILITY_METADATA_KEYS", "",
+			"Comma separated list of proxy metadata keys used to restrict endpoint discoverability, for example to isolate tenants. "+
+				"An endpoint labeled with one of the keys is only sent to proxies whose metadata has the same value for that key.").Get()
+		var out []string
+		for _, key := range strings.Split(keys, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				out = append(out, key)
+			}
+		}
+		return out
+	}()
+
+	DataResidencyPolicy = func() map[string]sets.String {
+		v := env.Register("PILOT_DATA_RESIDENCY_POLICY", "",
+			"Comma separated list of <residency>=<region>|<region>... entries. The endpoints labeled with "+
+				"topology.istio.io/data-residency=<residency>, directly or through their node, are only sent to proxies "+
+				"in one of the listed regions. Endpoints of a residency not in the list are sent to all proxies. "+
+				"Invalid entries are ignored.").Get()
+		if v == "" {
+			return nil
+		}
+		out := map[string]sets.String{}
+		for _, entry := range strings.Split(v, ",") {
+			residency, regions, ok := strings.Cut(entry, "=")
+			if !ok || residency == "" || regions == "" {
+				continue
+			}
+			out[residency] = sets.New(strings.Split(regions, "|")...)
+		}
+		return out
+	}()
 )
 
 // UnsafeFeaturesEnabled returns true if any unsafe features are enabled.
 func UnsafeFeaturesEnabled() bool {
 	return EnableUnsafeAdminEndpoints || EnableUnsafeAssertions
 }
+
+func parseProxyLocalityAllowedRegions(v string) map[string]sets.String {
+	var out map[string]sets.String
+	for _, entry := range strings.Split(v, ",") {
+		namespace, region, ok := strings.Cut(entry, "=")
+		if !ok {
+			namespace, region = "", namespace
+		}
+		namespace, region = strings.TrimSpace(namespace), strings.TrimSpace(region)
+		if region == "" {
+			continue
+		}
+		if out == nil {
+			out = map[string]sets.String{}
+		}
+		sets.InsertOrNew(out, namespace, region)
+	}
+	return out
+}
@@ -216,6 +216,22 @@ func (policy *AuthenticationPolicies) GetRootNamespace() string {
 	return policy.rootNamespace
 }
 
+// HasWorkloadPeerAuthentications returns true if a PeerAuthentication with a workload selector applies to the
+// namespace, in the namespace itself or the root namespace.
+func (policy *AuthenticationPolicies) HasWorkloadPeerAuthentications(namespace string) bool {
+	if policy == nil {
+		return false
+	}
+	for _, ns := range []string{namespace, policy.rootNamespace} {
+		for _, cfg := range policy.peerAuthentications[ns] {
+			if len(cfg.Spec.(*v1beta1.PeerAuthentication).GetSelector().GetMatchLabels()) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // GetVersion return versions of all peer authentications..
 func (policy *AuthenticationPolicies) GetVersion() string {
 	return policy.aggregateVersion
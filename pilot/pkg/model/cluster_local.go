@@ -41,6 +41,32 @@ func (c ClusterLocalHosts) IsClusterLocal(h host.Name) bool {
 	return ok
 }
 
+// Equals returns whether both have the same hosts.
+func (c ClusterLocalHosts) Equals(other ClusterLocalHosts) bool {
+	return c.specific.Equals(other.specific) && c.wildcard.Equals(other.wildcard)
+}
+
+// NewClusterLocalHosts returns the cluster-local hosts of the host names or wildcard patterns.
+func NewClusterLocalHosts(hosts []host.Name) ClusterLocalHosts {
+	c := ClusterLocalHosts{
+		specific: sets.New[host.Name](),
+		wildcard: sets.New[host.Name](),
+	}
+	for _, h := range hosts {
+		if h.IsWildCarded() {
+			c.wildcard.Insert(h)
+		} else {
+			c.specific.Insert(h)
+		}
+	}
+	return c
+}
+
+// Hosts returns the sorted host names and wildcard patterns of the cluster-local hosts.
+func (c ClusterLocalHosts) Hosts() []host.Name {
+	return sets.SortedList(c.specific.Union(c.wildcard))
+}
+
 // ClusterLocalProvider provides the cluster-local hosts.
 type ClusterLocalProvider interface {
 	// GetClusterLocalHosts returns the list of cluster-local hosts, sorted in
@@ -0,0 +1,102 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/util/sets"
+)
+
+const clusterSetLocalSuffix = ".svc." + constants.DefaultClusterSetLocalDomain
+
+// ClusterSetIdentity returns the namespaced name of the service of a Kubernetes Multi-Cluster Services (MCS)
+// ClusterSet host, <name>.<namespace>.svc.clusterset.local, and whether the hostname is one.
+func ClusterSetIdentity(hostname host.Name) (types.NamespacedName, bool) {
+	prefix, ok := strings.CutSuffix(string(hostname), clusterSetLocalSuffix)
+	if !ok {
+		return types.NamespacedName{}, false
+	}
+	name, namespace, ok := strings.Cut(prefix, ".")
+	if !ok || name == "" || namespace == "" || strings.Contains(namespace, ".") {
+		return types.NamespacedName{}, false
+	}
+	return types.NamespacedName{Name: name, Namespace: namespace}, true
+}
+
+// clusterSetServices returns the services of the ClusterSet hosts of the exported Kubernetes Services which no
+// cluster of the mesh imports, so that the proxies can reach them, such as a ServiceImport referenced by a Gateway
+// API route. Each one is a copy of the exported Service with the ClusterSet hostname and no address, as the
+// endpoints of the Service are also indexed under the ClusterSet hostname. Services which are not exported by any
+// cluster are never exposed under their ClusterSet hostname.
+func clusterSetServices(env *Environment, services []*Service) []*Service {
+	exported := sets.New[types.NamespacedName]()
+	for _, mcs := range env.MCSServices() {
+		if mcs.Exported {
+			exported.Insert(types.NamespacedName{Name: mcs.Name, Namespace: mcs.Namespace})
+		}
+	}
+	if exported.IsEmpty() {
+		return nil
+	}
+	existing := sets.New[host.Name]()
+	for _, svc := range services {
+		if _, ok := ClusterSetIdentity(svc.Hostname); ok {
+			existing.Insert(svc.Hostname)
+		}
+	}
+	var out []*Service
+	for _, svc := range services {
+		nn := types.NamespacedName{Name: svc.Attributes.Name, Namespace: svc.Attributes.Namespace}
+		if !exported.Contains(nn) {
+			continue
+		}
+		if _, ok := ClusterSetIdentity(svc.Hostname); ok {
+			continue
+		}
+		hostname := host.Name(nn.Name + "." + nn.Namespace + clusterSetLocalSuffix)
+		if existing.InsertContains(hostname) {
+			continue
+		}
+		cs := svc.DeepCopy()
+		cs.Hostname = hostname
+		cs.DefaultAddress = constants.UnspecifiedIP
+		cs.ClusterVIPs = AddressMap{}
+		cs.Attributes.ClusterSetOrigin = svc.Hostname
+		out = append(out, cs)
+	}
+	return out
+}
+
+// SessionAffinityLoadBalancer returns the load balancer honoring the ClientIP session affinity of the service, a
+// consistent hash on the source IP, or nil if the service has no session affinity. The affinity timeout is not
+// enforced, as the hash of a client does not expire.
+func SessionAffinityLoadBalancer(svc *Service) *networking.LoadBalancerSettings {
+	if svc == nil || !svc.Attributes.SessionAffinityClientIP {
+		return nil
+	}
+	return &networking.LoadBalancerSettings{
+		LbPolicy: &networking.LoadBalancerSettings_ConsistentHash{
+			ConsistentHash: &networking.LoadBalancerSettings_ConsistentHashLB{
+				HashKey: &networking.LoadBalancerSettings_ConsistentHashLB_UseSourceIp{UseSourceIp: true},
+			},
+		},
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestClusterSetIdentity(t *testing.T) {
+	cases := []struct {
+		hostname host.Name
+		want     types.NamespacedName
+		ok       bool
+	}{
+		{hostname: "example.ns.svc.clusterset.local", want: types.NamespacedName{Name: "example", Namespace: "ns"}, ok: true},
+		{hostname: "example.ns.svc.cluster.local"},
+		{hostname: "a.b.ns.svc.clusterset.local"},
+		{hostname: "ns.svc.clusterset.local"},
+	}
+	for _, tt := range cases {
+		t.Run(string(tt.hostname), func(t *testing.T) {
+			got, ok := ClusterSetIdentity(tt.hostname)
+			assert.Equal(t, got, tt.want)
+			assert.Equal(t, ok, tt.ok)
+		})
+	}
+}
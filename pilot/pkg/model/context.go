@@ -62,9 +62,10 @@ func NewEnvironment() *Environment {
 		cache = DisabledCache{}
 	}
 	return &Environment{
-		pushContext:   NewPushContext(),
-		Cache:         cache,
-		EndpointIndex: NewEndpointIndex(cache),
+		pushContext:    NewPushContext(),
+		Cache:          cache,
+		EndpointIndex:  NewEndpointIndex(cache),
+		RolloutWeights: NewRolloutWeights(),
 	}
 }
 
@@ -118,6 +119,9 @@ type Environment struct {
 
 	// Cache for XDS resources.
 	Cache XdsCache
+
+	// RolloutWeights are the traffic percentages of the workloads being rolled out.
+	RolloutWeights *RolloutWeights
 }
 
 func (e *Environment) Mesh() *meshconfig.MeshConfig {
@@ -337,6 +341,13 @@ type Proxy struct {
 	// The merged gateways associated with the proxy if this is a Router
 	MergedGateway *MergedGateway
 
+	// TelemetryEndpointMetadataKeys are the endpoint label keys requested by the telemetry providers of the proxy.
+	TelemetryEndpointMetadataKeys []string
+
+	// EndpointMeshSettings replaces the meshConfig settings of the push context changing the endpoints and clusters
+	// of the proxy, while a change to them is rolled out progressively. Nil uses the settings of the push context.
+	EndpointMeshSettings *EndpointMeshSettings
+
 	// ServiceTargets contains a list of all Services associated with the proxy, contextualized for this particular proxy.
 	// These are unique to this proxy, as the port information is specific to it - while a ServicePort is shared with the
 	// service, the target port may be distinct per-endpoint. So this maintains a view specific to this proxy.
@@ -629,6 +640,10 @@ type NodeMetadata struct {
 	// will be replaced with the gateway defined in the settings.
 	Network network.ID `json:"NETWORK,omitempty"`
 
+	// Locality is the locality asserted by the proxy, in the region/zone/subzone form, for deployments where
+	// the service registry does not know it. It is only honored as allowed by PILOT_PROXY_LOCALITY_POLICY.
+	Locality string `json:"LOCALITY,omitempty"`
+
 	// RequestedNetworkView specifies the networks that the proxy wants to see
 	RequestedNetworkView StringList `json:"REQUESTED_NETWORK_VIEW,omitempty"`
 
@@ -662,6 +677,16 @@ type NodeMetadata struct {
 	// Generator indicates the client wants to use a custom Generator plugin.
 	Generator string `json:"GENERATOR,omitempty"`
 
+	// XdsCapabilities lists the features of the xDS resources supported by the client, such as "tunnel", overriding
+	// the capabilities registered for its Generator. It is set by xDS clients other than Envoy.
+	XdsCapabilities StringList `json:"XDS_CAPABILITIES,omitempty"`
+
+	// NAT64Prefix is the NAT64 prefix of the network of the workload, for example 64:ff9b::/96. If set on an
+	// IPv6-only proxy, the IPv4 endpoint addresses of ServiceEntries are translated to IPv6 addresses within the
+	// prefix, as described in RFC 6052, so that they are reachable through the NAT64 gateway. It is typically set for
+	// the whole mesh with the ISTIO_META_NAT64_PREFIX proxyMetadata of the MeshConfig defaultConfig.
+	NAT64Prefix string `json:"NAT64_PREFIX,omitempty"`
+
 	// DNSCapture indicates whether the workload has enabled dns capture
 	DNSCapture StringBool `json:"DNS_CAPTURE,omitempty"`
 
@@ -752,6 +777,16 @@ func (node *Proxy) InCluster(cluster cluster.ID) bool {
 	return node == nil || identifier.IsSameOrEmpty(cluster.String(), node.Metadata.ClusterID.String())
 }
 
+// DiscoverabilityMetadata returns the string value of the given key in the proxy metadata. It is used to
+// restrict the endpoints discoverable from the proxy, see features.EndpointDiscoverabilityMetadataKeys.
+func (node *Proxy) DiscoverabilityMetadata(key string) (string, bool) {
+	if node == nil || node.Metadata == nil {
+		return "", false
+	}
+	v, f := node.Metadata.Raw[key].(string)
+	return v, f
+}
+
 // IsWaypointProxy returns true if the proxy is acting as a waypoint proxy in an ambient mesh.
 func (node *Proxy) IsWaypointProxy() bool {
 	return node.Type == Waypoint
@@ -930,6 +965,16 @@ func (node *Proxy) SetGatewaysForProxy(ps *PushContext) {
 	node.MergedGateway = ps.mergeGateways(node)
 }
 
+// SetTelemetryEndpointMetadataKeys computes the endpoint label keys requested by the Telemetries applying to this
+// proxy and caches them in the proxy Node, so that they are not computed for every cluster.
+func (node *Proxy) SetTelemetryEndpointMetadataKeys(ps *PushContext) {
+	if ps.Telemetry == nil {
+		node.TelemetryEndpointMetadataKeys = nil
+		return
+	}
+	node.TelemetryEndpointMetadataKeys = ps.Telemetry.EndpointMetadataKeys(node)
+}
+
 func (node *Proxy) SetServiceTargets(serviceDiscovery ServiceDiscovery) {
 	instances := serviceDiscovery.GetProxyServiceTargets(node)
 
@@ -1243,6 +1288,63 @@ func (node *Proxy) IsProxylessGrpc() bool {
 	return node.Metadata != nil && node.Metadata.Generator == "grpc"
 }
 
+// Proxy locality policies, set with PILOT_PROXY_LOCALITY_POLICY.
+const (
+	ProxyLocalityIgnore   = "ignore"
+	ProxyLocalityFallback = "fallback"
+	ProxyLocalityOverride = "override"
+)
+
+// AssertedLocality returns the locality asserted by the proxy in its metadata, if it is valid and allowed by the
+// proxy locality policy, or an empty string. The second result is true if the asserted locality should take
+// precedence over the one of the service registry. As the metadata is not authenticated, the locality is only
+// accepted from the proxies whose identity was verified, in the regions allowed for their namespace.
+func (node *Proxy) AssertedLocality() (string, bool) {
+	if node.Metadata == nil || node.Metadata.Locality == "" {
+		return "", false
+	}
+	policy := features.ProxyLocalityPolicy
+	if policy != ProxyLocalityFallback && policy != ProxyLocalityOverride {
+		return "", false
+	}
+	locality := node.Metadata.Locality
+	if node.VerifiedIdentity == nil {
+		log.Warnf("ignoring locality %q asserted by proxy %s: the identity of the proxy is not verified", locality, node.ID)
+		return "", false
+	}
+	if err := validateProxyLocality(locality, node.VerifiedIdentity.Namespace); err != nil {
+		log.Warnf("ignoring locality %q asserted by proxy %s: %v", locality, node.ID, err)
+		return "", false
+	}
+	return locality, policy == ProxyLocalityOverride
+}
+
+func validateProxyLocality(locality, namespace string) error {
+	parts := strings.Split(locality, "/")
+	if len(parts) > 3 {
+		return fmt.Errorf("expected region/zone/subzone")
+	}
+	if parts[0] == "" {
+		return fmt.Errorf("region is required")
+	}
+	if strings.Contains(locality, "*") {
+		return fmt.Errorf("wildcards are not allowed")
+	}
+	if allowed := features.ProxyLocalityAllowedRegions; allowed != nil &&
+		!allowed[""].Contains(parts[0]) && !allowed[namespace].Contains(parts[0]) {
+		return fmt.Errorf("region %q is not allowed for namespace %q", parts[0], namespace)
+	}
+	return nil
+}
+
+// ProxyLocalityRecorder is implemented by the service registries applying the locality asserted by the proxies to the
+// endpoints of their workloads.
+type ProxyLocalityRecorder interface {
+	// RecordProxyLocality records the locality asserted by the proxy, as returned by AssertedLocality. An empty
+	// locality clears the one previously asserted.
+	RecordProxyLocality(proxy *Proxy, locality string, override bool)
+}
+
 func (node *Proxy) GetNodeName() string {
 	if node.Metadata != nil && len(node.Metadata.NodeName) > 0 {
 		return node.Metadata.NodeName
@@ -1302,19 +1404,22 @@ func (node *Proxy) EnableHBONE() bool {
 	return node.IsAmbient() || (features.EnableHBONE && bool(node.Metadata.EnableHBONE))
 }
 
-// WaypointScope is either an entire namespace or an individual service account
-// in the namespace. This setting dictates the upstream TLS verification
-// strategy, depending on the binding of the waypoints to its backend
-// workloads.
+// WaypointScope is either an entire namespace, an individual service account
+// in the namespace, or the workloads of the namespace matching a label selector.
+// This setting dictates the upstream TLS verification strategy, depending on the
+// binding of the waypoints to its backend workloads.
 type WaypointScope struct {
 	Namespace      string
 	ServiceAccount string // optional
+	// Selector is the canonical label selector of the workloads, see WaypointSelector. Optional.
+	Selector string
 }
 
 func (node *Proxy) WaypointScope() WaypointScope {
 	return WaypointScope{
 		Namespace:      node.ConfigNamespace,
 		ServiceAccount: node.Metadata.Annotations[constants.WaypointServiceAccount],
+		Selector:       WaypointSelector(node.Metadata.Annotations[constants.WaypointSelector]),
 	}
 }
 
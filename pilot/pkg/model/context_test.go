@@ -25,12 +25,16 @@ import (
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/serviceregistry/memory"
 	"istio.io/istio/pilot/pkg/serviceregistry/mock"
 	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/test/util/assert"
 	"istio.io/istio/pkg/util/protomarshal"
+	"istio.io/istio/pkg/util/sets"
 )
 
 func TestNodeMetadata(t *testing.T) {
@@ -146,6 +150,46 @@ func TestNodeMetadata(t *testing.T) {
 	}
 }
 
+func TestAssertedLocality(t *testing.T) {
+	proxy := func(locality string) *model.Proxy {
+		return &model.Proxy{
+			Metadata:         &model.NodeMetadata{Locality: locality},
+			VerifiedIdentity: &spiffe.Identity{Namespace: "ns1", ServiceAccount: "sa"},
+		}
+	}
+	test.SetForTest(t, &features.ProxyLocalityPolicy, model.ProxyLocalityIgnore)
+	locality, _ := proxy("region1/zone1").AssertedLocality()
+	assert.Equal(t, locality, "")
+
+	test.SetForTest(t, &features.ProxyLocalityPolicy, model.ProxyLocalityFallback)
+	locality, override := proxy("region1/zone1").AssertedLocality()
+	assert.Equal(t, locality, "region1/zone1")
+	assert.Equal(t, override, false)
+	for _, invalid := range []string{"/zone1", "region1/zone1/subzone1/extra", "region1/*"} {
+		locality, _ := proxy(invalid).AssertedLocality()
+		assert.Equal(t, locality, "")
+	}
+	unverified := proxy("region1/zone1")
+	unverified.VerifiedIdentity = nil
+	locality, _ = unverified.AssertedLocality()
+	assert.Equal(t, locality, "")
+
+	test.SetForTest(t, &features.ProxyLocalityPolicy, model.ProxyLocalityOverride)
+	test.SetForTest(t, &features.ProxyLocalityAllowedRegions, map[string]sets.String{"": sets.New("region1")})
+	locality, override = proxy("region1").AssertedLocality()
+	assert.Equal(t, locality, "region1")
+	assert.Equal(t, override, true)
+	locality, _ = proxy("region2/zone1").AssertedLocality()
+	assert.Equal(t, locality, "")
+
+	test.SetForTest(t, &features.ProxyLocalityAllowedRegions, map[string]sets.String{"ns2": sets.New("region1")})
+	locality, _ = proxy("region1").AssertedLocality()
+	assert.Equal(t, locality, "")
+	test.SetForTest(t, &features.ProxyLocalityAllowedRegions, map[string]sets.String{"ns1": sets.New("region1")})
+	locality, _ = proxy("region1").AssertedLocality()
+	assert.Equal(t, locality, "region1")
+}
+
 func TestStringList(t *testing.T) {
 	cases := []struct {
 		in          string
@@ -16,11 +16,16 @@ package model
 
 import (
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/types"
 
 	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/config/visibility"
@@ -119,6 +124,45 @@ func (ps *PushContext) mergeDestinationRule(p *consolidatedDestRules, destRuleCo
 	p.exportTo[resolvedHost] = exportToSet
 }
 
+// mergeSelectedDestinationRules merges destination rules with different workload selectors that all
+// select the same proxy. Subsets are concatenated and the first rule's top level traffic policy wins,
+// following the same logic as mergeDestinationRule.
+func mergeSelectedDestinationRules(rules []*ConsolidatedDestRule) *ConsolidatedDestRule {
+	copied := rules[0].rule.DeepCopy()
+	merged := &ConsolidatedDestRule{
+		rule: &copied,
+		from: append([]types.NamespacedName{}, rules[0].from...),
+	}
+	mergedRule := copied.Spec.(*networking.DestinationRule)
+	existingSubset := sets.String{}
+	for _, subset := range mergedRule.Subsets {
+		existingSubset.Insert(subset.Name)
+	}
+	from := sets.New(merged.from...)
+	for _, r := range rules[1:] {
+		// Rules without a workload selector are already merged into each selected rule.
+		for _, nn := range r.from {
+			if !from.InsertContains(nn) {
+				merged.from = append(merged.from, nn)
+			}
+		}
+		rule := r.rule.Spec.(*networking.DestinationRule)
+		for _, subset := range rule.Subsets {
+			if !existingSubset.InsertContains(subset.Name) {
+				mergedRule.Subsets = append(mergedRule.Subsets, subset)
+			}
+		}
+		if mergedRule.TrafficPolicy == nil && rule.TrafficPolicy != nil {
+			mergedRule.TrafficPolicy = rule.TrafficPolicy
+		}
+	}
+	merged.selectors = make([]string, 0, len(rules))
+	for _, r := range rules {
+		merged.selectors = append(merged.selectors, r.SelectorFingerprints()...)
+	}
+	return merged
+}
+
 func ConvertConsolidatedDestRule(cfg *config.Config) *ConsolidatedDestRule {
 	return &ConsolidatedDestRule{
 		rule: cfg,
@@ -160,3 +204,186 @@ func (l *ConsolidatedDestRule) GetFrom() []types.NamespacedName {
 	}
 	return l.from
 }
+
+// SelectorFingerprints returns the workload selectors the rule was selected through, in a canonical
+// string form. It is empty for rules without a workload selector.
+func (l *ConsolidatedDestRule) SelectorFingerprints() []string {
+	if l == nil {
+		return nil
+	}
+	if l.selectors != nil {
+		return l.selectors
+	}
+	dr, ok := l.rule.Spec.(*networking.DestinationRule)
+	if !ok || dr.GetWorkloadSelector() == nil {
+		return nil
+	}
+	return []string{labels.Instance(dr.GetWorkloadSelector().GetMatchLabels()).String()}
+}
+
+// PanicThreshold returns the healthy panic threshold set on the DestinationRule with the
+// networking.istio.io/panic-threshold annotation. Invalid values are ignored.
+func PanicThreshold(dr *config.Config) (float64, bool) {
+	if dr == nil {
+		return 0, false
+	}
+	v, f := dr.Annotations[constants.PanicThresholdAnnotation]
+	if !f {
+		return 0, false
+	}
+	threshold, err := strconv.ParseFloat(v, 64)
+	if err != nil || threshold < 0 || threshold > 100 {
+		return 0, false
+	}
+	return threshold, true
+}
+
+// OverprovisioningFactor returns the overprovisioning factor set on the DestinationRule with the
+// networking.istio.io/overprovisioning-factor annotation. Invalid values are ignored.
+func OverprovisioningFactor(dr *config.Config) (uint32, bool) {
+	if dr == nil {
+		return 0, false
+	}
+	v, f := dr.Annotations[constants.OverprovisioningFactorAnnotation]
+	if !f {
+		return 0, false
+	}
+	factor, err := strconv.ParseUint(v, 10, 32)
+	if err != nil || factor < 100 {
+		return 0, false
+	}
+	return uint32(factor), true
+}
+
+// FailoverPrewarmPercentage returns the percentage of requests sent to the first failover priority set on the
+// DestinationRule with the networking.istio.io/failover-prewarm-percentage annotation. Invalid values are ignored.
+func FailoverPrewarmPercentage(dr *config.Config) (float64, bool) {
+	if dr == nil {
+		return 0, false
+	}
+	v, f := dr.Annotations[constants.FailoverPrewarmAnnotation]
+	if !f {
+		return 0, false
+	}
+	percentage, err := strconv.ParseFloat(v, 64)
+	if err != nil || percentage <= 0 || percentage >= 100 {
+		return 0, false
+	}
+	return percentage, true
+}
+
+// LocalitySpilloverThreshold returns the utilization percentage beyond which the traffic spills over to the first
+// failover priority, set on the DestinationRule with the networking.istio.io/locality-spillover-threshold annotation.
+// Invalid values are ignored.
+func LocalitySpilloverThreshold(dr *config.Config) (float64, bool) {
+	if dr == nil {
+		return 0, false
+	}
+	v, f := dr.Annotations[constants.LocalitySpilloverAnnotation]
+	if !f {
+		return 0, false
+	}
+	threshold, err := strconv.ParseFloat(v, 64)
+	if err != nil || threshold <= 0 || math.IsInf(threshold, 0) {
+		return 0, false
+	}
+	return threshold, true
+}
+
+// ClusterWeights returns the weights of the clusters of the endpoints, set on the DestinationRule with the
+// networking.istio.io/cluster-weights annotation. The annotation is ignored if it is invalid or all its weights are
+// zero.
+func ClusterWeights(dr *config.Config) (map[cluster.ID]uint32, bool) {
+	if dr == nil {
+		return nil, false
+	}
+	v := dr.Annotations[constants.ClusterWeightsAnnotation]
+	if v == "" {
+		return nil, false
+	}
+	weights := map[cluster.ID]uint32{}
+	var total uint64
+	for _, entry := range strings.Split(v, ",") {
+		name, w, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || name == "" {
+			return nil, false
+		}
+		weight, err := strconv.ParseUint(w, 10, 32)
+		if err != nil {
+			return nil, false
+		}
+		weights[cluster.ID(name)] = uint32(weight)
+		total += weight
+	}
+	if total == 0 {
+		return nil, false
+	}
+	return weights, true
+}
+
+// TrafficCut returns the labels of the endpoints whose traffic is cut by the networking.istio.io/traffic-cut
+// annotation of the DestinationRule. The annotation is ignored if it is invalid.
+func TrafficCut(dr *config.Config) (labels.Instance, bool) {
+	if dr == nil {
+		return nil, false
+	}
+	v := dr.Annotations[constants.TrafficCutAnnotation]
+	if v == "" {
+		return nil, false
+	}
+	selector := labels.Instance{}
+	for _, entry := range strings.Split(v, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || key == "" {
+			return nil, false
+		}
+		selector[key] = value
+	}
+	if selector.Validate() != nil {
+		return nil, false
+	}
+	return selector, true
+}
+
+// MirrorSubsetName is the subset of the shadow clusters receiving the traffic mirrored with the
+// networking.istio.io/mirror-to annotation.
+const MirrorSubsetName = "istio-mirror"
+
+// MirrorTo returns the subset the traffic of the DestinationRule host is mirrored to with the
+// networking.istio.io/mirror-to annotation, and the percentage of requests mirrored. The annotation is ignored if it
+// does not name a subset of the rule, or if the networking.istio.io/mirror-percentage annotation is invalid.
+func MirrorTo(dr *config.Config) (*networking.Subset, float64, bool) {
+	if dr == nil {
+		return nil, 0, false
+	}
+	name := dr.Annotations[constants.MirrorToAnnotation]
+	if name == "" || name == MirrorSubsetName {
+		return nil, 0, false
+	}
+	percentage := 100.0
+	if v, f := dr.Annotations[constants.MirrorPercentageAnnotation]; f {
+		p, err := strconv.ParseFloat(v, 64)
+		if err != nil || p < 0 || p > 100 {
+			return nil, 0, false
+		}
+		percentage = p
+	}
+	rule, ok := dr.Spec.(*networking.DestinationRule)
+	if !ok {
+		return nil, 0, false
+	}
+	for _, subset := range rule.GetSubsets() {
+		if subset.GetName() == name {
+			return subset, percentage, true
+		}
+	}
+	return nil, 0, false
+}
+
+// SubsetLoadBalancer returns true if the subsets of the DestinationRule are selected by the subset load balancer of
+// the default cluster of the service port, as set by the networking.istio.io/subset-load-balancer annotation. It only
+// applies to the HTTP ports of services load balanced through EDS, the others keeping a cluster per subset.
+func SubsetLoadBalancer(dr *config.Config, svc *Service, port *Port) bool {
+	return dr != nil && dr.Annotations[constants.SubsetLoadBalancerAnnotation] == "true" &&
+		svc != nil && svc.Resolution == ClientSideLB && port != nil && port.Protocol.IsHTTP()
+}
@@ -19,6 +19,11 @@ import (
 
 	"k8s.io/apimachinery/pkg/types"
 
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/test/util/assert"
 )
 
@@ -147,3 +152,175 @@ func TestConsolidatedDestRuleEquals(t *testing.T) {
 		})
 	}
 }
+
+func TestPanicThresholdAndOverprovisioningFactor(t *testing.T) {
+	dr := func(annotations map[string]string) *config.Config {
+		return &config.Config{Meta: config.Meta{Annotations: annotations}}
+	}
+	testcases := []struct {
+		name           string
+		dr             *config.Config
+		threshold      float64
+		thresholdFound bool
+		factor         uint32
+		factorFound    bool
+	}{
+		{
+			name: "no destination rule",
+		},
+		{
+			name: "no annotations",
+			dr:   dr(nil),
+		},
+		{
+			name: "fail fast",
+			dr: dr(map[string]string{
+				constants.PanicThresholdAnnotation:         "0",
+				constants.OverprovisioningFactorAnnotation: "100",
+			}),
+			thresholdFound: true,
+			factor:         100,
+			factorFound:    true,
+		},
+		{
+			name: "fractional threshold",
+			dr: dr(map[string]string{
+				constants.PanicThresholdAnnotation: "12.5",
+			}),
+			threshold:      12.5,
+			thresholdFound: true,
+		},
+		{
+			name: "invalid values",
+			dr: dr(map[string]string{
+				constants.PanicThresholdAnnotation:         "101",
+				constants.OverprovisioningFactorAnnotation: "99",
+			}),
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			threshold, f := PanicThreshold(tc.dr)
+			assert.Equal(t, threshold, tc.threshold)
+			assert.Equal(t, f, tc.thresholdFound)
+			factor, f := OverprovisioningFactor(tc.dr)
+			assert.Equal(t, factor, tc.factor)
+			assert.Equal(t, f, tc.factorFound)
+		})
+	}
+}
+
+func TestLocalitySpilloverThreshold(t *testing.T) {
+	dr := func(v string) *config.Config {
+		return &config.Config{Meta: config.Meta{Annotations: map[string]string{constants.LocalitySpilloverAnnotation: v}}}
+	}
+	threshold, f := LocalitySpilloverThreshold(dr("120"))
+	assert.Equal(t, threshold, 120.0)
+	assert.Equal(t, f, true)
+	for _, invalid := range []string{"0", "-1", "+Inf", "high"} {
+		_, f := LocalitySpilloverThreshold(dr(invalid))
+		assert.Equal(t, f, false)
+	}
+	_, f = LocalitySpilloverThreshold(nil)
+	assert.Equal(t, f, false)
+}
+
+func TestClusterWeights(t *testing.T) {
+	dr := func(v string) *config.Config {
+		return &config.Config{Meta: config.Meta{Annotations: map[string]string{constants.ClusterWeightsAnnotation: v}}}
+	}
+	weights, f := ClusterWeights(dr("cluster-a=80, cluster-b=20,cluster-c=0"))
+	assert.Equal(t, weights, map[cluster.ID]uint32{"cluster-a": 80, "cluster-b": 20, "cluster-c": 0})
+	assert.Equal(t, f, true)
+	for _, invalid := range []string{"", "cluster-a", "=80", "cluster-a=-1", "cluster-a=0.5", "cluster-a=0,cluster-b=0"} {
+		_, f := ClusterWeights(dr(invalid))
+		assert.Equal(t, f, false)
+	}
+	_, f = ClusterWeights(nil)
+	assert.Equal(t, f, false)
+}
+
+func TestTrafficCut(t *testing.T) {
+	dr := func(v string) *config.Config {
+		return &config.Config{Meta: config.Meta{Annotations: map[string]string{constants.TrafficCutAnnotation: v}}}
+	}
+	selector, f := TrafficCut(dr("version=blue, track=stable"))
+	assert.Equal(t, selector, labels.Instance{"version": "blue", "track": "stable"})
+	assert.Equal(t, f, true)
+	for _, invalid := range []string{"", "version", "=blue", "version=blue,", "version=not valid"} {
+		_, f := TrafficCut(dr(invalid))
+		assert.Equal(t, f, false)
+	}
+	_, f = TrafficCut(nil)
+	assert.Equal(t, f, false)
+}
+
+func TestFailoverPrewarmPercentage(t *testing.T) {
+	dr := func(v string) *config.Config {
+		return &config.Config{Meta: config.Meta{Annotations: map[string]string{constants.FailoverPrewarmAnnotation: v}}}
+	}
+	percentage, f := FailoverPrewarmPercentage(dr("1.5"))
+	assert.Equal(t, percentage, 1.5)
+	assert.Equal(t, f, true)
+	for _, invalid := range []string{"0", "100", "-1", "one"} {
+		_, f := FailoverPrewarmPercentage(dr(invalid))
+		assert.Equal(t, f, false)
+	}
+	_, f = FailoverPrewarmPercentage(nil)
+	assert.Equal(t, f, false)
+}
+
+func TestMirrorTo(t *testing.T) {
+	dr := func(annotations map[string]string) *config.Config {
+		return &config.Config{
+			Meta: config.Meta{Annotations: annotations},
+			Spec: &networking.DestinationRule{
+				Host:    "reviews",
+				Subsets: []*networking.Subset{{Name: "canary", Labels: map[string]string{"track": "canary"}}},
+			},
+		}
+	}
+	testcases := []struct {
+		name       string
+		dr         *config.Config
+		subset     string
+		percentage float64
+		found      bool
+	}{
+		{name: "no destination rule"},
+		{name: "no annotations", dr: dr(nil)},
+		{
+			name:       "all traffic",
+			dr:         dr(map[string]string{constants.MirrorToAnnotation: "canary"}),
+			subset:     "canary",
+			percentage: 100,
+			found:      true,
+		},
+		{
+			name: "percentage",
+			dr: dr(map[string]string{
+				constants.MirrorToAnnotation:         "canary",
+				constants.MirrorPercentageAnnotation: "2.5",
+			}),
+			subset:     "canary",
+			percentage: 2.5,
+			found:      true,
+		},
+		{name: "unknown subset", dr: dr(map[string]string{constants.MirrorToAnnotation: "stable"})},
+		{
+			name: "invalid percentage",
+			dr: dr(map[string]string{
+				constants.MirrorToAnnotation:         "canary",
+				constants.MirrorPercentageAnnotation: "150",
+			}),
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			subset, percentage, f := MirrorTo(tc.dr)
+			assert.Equal(t, subset.GetName(), tc.subset)
+			assert.Equal(t, percentage, tc.percentage)
+			assert.Equal(t, f, tc.found)
+		})
+	}
+}
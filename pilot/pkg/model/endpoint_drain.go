@@ -0,0 +1,53 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"time"
+
+	"istio.io/istio/pkg/slices"
+)
+
+// stampDrainingSince returns the new endpoints of a shard with the DrainingSince time of the Draining ones set. The
+// endpoints of workload instances already draining in the shard keep the time they started draining, the others
+// start draining now. The endpoints are owned by the registries, so the stamped ones are copies.
+func stampDrainingSince(oldEndpoints, newEndpoints []*IstioEndpoint, now time.Time) []*IstioEndpoint {
+	drainingSince := map[endpointInstance]time.Time{}
+	for _, oie := range oldEndpoints {
+		if oie.HealthStatus == Draining && !oie.DrainingSince.IsZero() {
+			drainingSince[oie.endpointInstance()] = oie.DrainingSince
+		}
+	}
+	var out []*IstioEndpoint
+	for i, nie := range newEndpoints {
+		if nie.HealthStatus != Draining || !nie.DrainingSince.IsZero() {
+			continue
+		}
+		if out == nil {
+			out = slices.Clone(newEndpoints)
+		}
+		stamped := nie.ShallowCopy()
+		if t, f := drainingSince[nie.endpointInstance()]; f {
+			stamped.DrainingSince = t
+		} else {
+			stamped.DrainingSince = now
+		}
+		out[i] = stamped
+	}
+	if out == nil {
+		return newEndpoints
+	}
+	return out
+}
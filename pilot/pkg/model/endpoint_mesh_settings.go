@@ -0,0 +1,47 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"google.golang.org/protobuf/proto"
+
+	"istio.io/api/networking/v1alpha3"
+)
+
+// EndpointMeshSettings holds the meshConfig settings that change the generated endpoints and clusters. They replace
+// the settings of the push context, so that proxies can keep receiving endpoints and clusters built with a previous
+// meshConfig while a change is rolled out to a subset of them.
+type EndpointMeshSettings struct {
+	LocalityLbSetting *v1alpha3.LocalityLoadBalancerSetting
+	ClusterLocalHosts ClusterLocalHosts
+	// Version identifies the settings. It is part of the cache key of the endpoints and clusters.
+	Version string
+}
+
+// Equals returns whether both hold the same settings, regardless of their version.
+func (s *EndpointMeshSettings) Equals(other *EndpointMeshSettings) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	return proto.Equal(s.LocalityLbSetting, other.LocalityLbSetting) && s.ClusterLocalHosts.Equals(other.ClusterLocalHosts)
+}
+
+// GetVersion returns the version of the settings, empty if nil.
+func (s *EndpointMeshSettings) GetVersion() string {
+	if s == nil {
+		return ""
+	}
+	return s.Version
+}
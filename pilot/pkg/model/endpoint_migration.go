@@ -0,0 +1,79 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"istio.io/istio/pkg/util/sets"
+)
+
+// instancePort identifies the endpoint of a workload instance for a service port, regardless of its address.
+type instancePort struct {
+	namespace string
+	instance  string
+	port      string
+}
+
+func (ep *IstioEndpoint) instancePort() instancePort {
+	return instancePort{namespace: ep.Namespace, instance: ep.InstanceName, port: ep.ServicePortName}
+}
+
+// linkMigratedEndpoints returns the new endpoints of a shard, replacing the old ones, along with the endpoints of the
+// workload instances whose address changed, kept as draining. The endpoints of the old and new addresses are linked
+// through their PreviousAddress and MigratedTo fields, so that the old address is drained rather than dropped as an
+// unrelated host. The draining endpoints are dropped on the next update.
+func linkMigratedEndpoints(oldEndpoints, newEndpoints []*IstioEndpoint) []*IstioEndpoint {
+	addresses := sets.NewWithLength[networkAddress](len(newEndpoints))
+	for _, nie := range newEndpoints {
+		addresses.Insert(nie.networkAddress())
+	}
+	previous := map[instancePort]*IstioEndpoint{}
+	for _, oie := range oldEndpoints {
+		// The draining endpoints of earlier migrations are not carried over.
+		if oie.InstanceName == "" || oie.MigratedTo != "" || addresses.Contains(oie.networkAddress()) {
+			continue
+		}
+		previous[oie.instancePort()] = oie
+	}
+	if len(previous) == 0 {
+		return newEndpoints
+	}
+
+	out := make([]*IstioEndpoint, 0, len(newEndpoints)+len(previous))
+	for _, nie := range newEndpoints {
+		if nie.InstanceName == "" {
+			out = append(out, nie)
+			continue
+		}
+		oie, f := previous[nie.instancePort()]
+		if !f {
+			out = append(out, nie)
+			continue
+		}
+		delete(previous, nie.instancePort())
+		// The endpoints belong to the registries, update copies instead.
+		migrated := nie.ShallowCopy()
+		migrated.PreviousAddress = oie.Address
+		migrated.ComputeEnvoyEndpoint(nil)
+		draining := oie.ShallowCopy()
+		draining.HealthStatus = Draining
+		draining.PreviousAddress = ""
+		draining.MigratedTo = nie.Address
+		draining.ComputeEnvoyEndpoint(nil)
+		log.Debugf("endpoint of %s/%s for port %s migrated from %s to %s", nie.Namespace, nie.InstanceName,
+			nie.ServicePortName, oie.Address, nie.Address)
+		out = append(out, migrated, draining)
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/slices"
+)
+
+// ServesPort returns whether the endpoint serves the service port of the given name.
+func (ep *IstioEndpoint) ServesPort(name string) bool {
+	if ep.ServicePortName == name {
+		return true
+	}
+	_, f := ep.AdditionalPorts[name]
+	return f
+}
+
+// ForPort returns the endpoint of the service port of the given name, or nil if the endpoint does not serve it.
+// For the primary port of the endpoint, this is the endpoint itself; for its additional ports, it is the endpoint
+// built by PrecomputePorts, or a copy with the service and endpoint ports of the port if they were not precomputed.
+func (ep *IstioEndpoint) ForPort(name string) *IstioEndpoint {
+	if ep.ServicePortName == name {
+		return ep
+	}
+	if pep, f := ep.portEndpoints[name]; f {
+		return pep
+	}
+	port, f := ep.AdditionalPorts[name]
+	if !f {
+		return nil
+	}
+	return ep.newPortEndpoint(name, port)
+}
+
+// PrecomputePorts builds the endpoints of the additional ports once, so that ForPort does not copy the endpoint for
+// each cluster built. It must be called once the ports of the endpoint are set, before the endpoint is shared.
+func (ep *IstioEndpoint) PrecomputePorts() {
+	if len(ep.AdditionalPorts) == 0 {
+		ep.portEndpoints = nil
+		return
+	}
+	ep.portEndpoints = make(map[string]*IstioEndpoint, len(ep.AdditionalPorts))
+	for name, port := range ep.AdditionalPorts {
+		ep.portEndpoints[name] = ep.newPortEndpoint(name, port)
+	}
+}
+
+func (ep *IstioEndpoint) newPortEndpoint(name string, port uint32) *IstioEndpoint {
+	cpy := ep.ShallowCopy()
+	cpy.ServicePortName = name
+	cpy.EndpointPort = port
+	cpy.AdditionalPorts = nil
+	cpy.ComputeEnvoyEndpoint(nil)
+	return cpy
+}
+
+// ExpandPorts returns the endpoints of each of the ports served by the endpoint, starting with its primary port.
+func (ep *IstioEndpoint) ExpandPorts() []*IstioEndpoint {
+	if len(ep.AdditionalPorts) == 0 {
+		return []*IstioEndpoint{ep}
+	}
+	out := make([]*IstioEndpoint, 0, len(ep.AdditionalPorts)+1)
+	out = append(out, ep)
+	for _, name := range slices.Sort(maps.Keys(ep.AdditionalPorts)) {
+		out = append(out, ep.ForPort(name))
+	}
+	return out
+}
@@ -0,0 +1,176 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"istio.io/istio/pkg/monitoring"
+	"istio.io/istio/pkg/util/sets"
+)
+
+var quarantinedEndpoints = monitoring.NewGauge(
+	"pilot_quarantined_endpoints",
+	"Number of endpoints rejected from the endpoint index because of an invalid address.",
+)
+
+// QuarantinedEndpoint is an endpoint that was rejected from the EndpointIndex because its address
+// can not be turned into a valid Envoy address.
+type QuarantinedEndpoint struct {
+	Hostname  string   `json:"hostname"`
+	Namespace string   `json:"namespace"`
+	Shard     ShardKey `json:"shard"`
+	Address   string   `json:"address"`
+	Reason    string   `json:"reason"`
+}
+
+type quarantineKey struct {
+	hostname  string
+	namespace string
+	shard     ShardKey
+}
+
+// ValidateEndpointAddress checks that the address of an endpoint can be turned into an Envoy address.
+// Endpoints without a port are unix domain sockets, and any path is accepted. Otherwise the address must
+// be an IP address or a DNS name. An empty address is valid: it is used for endpoints on remote networks
+// that are only reachable through the network gateways.
+func ValidateEndpointAddress(address string, port uint32) error {
+	if address == "" {
+		return nil
+	}
+	if port == 0 {
+		if strings.TrimPrefix(address, UnixAddressPrefix) == "" {
+			return fmt.Errorf("unix domain socket path is empty")
+		}
+		return nil
+	}
+	if _, err := netip.ParseAddr(address); err == nil {
+		return nil
+	}
+	// Hostnames can not contain colons, nor have a numeric top level label: treat those as malformed IPs.
+	if strings.Contains(address, ":") || isNumeric(address[strings.LastIndex(address, ".")+1:]) {
+		return fmt.Errorf("address %q is not a valid IP address", address)
+	}
+	if errs := validation.IsDNS1123Subdomain(strings.ToLower(address)); len(errs) > 0 {
+		return fmt.Errorf("address %q is neither an IP address nor a valid hostname: %s", address, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// filterInvalidEndpoints returns the endpoints with a valid address, and the rejected ones.
+func filterInvalidEndpoints(shard ShardKey, hostname, namespace string, istioEndpoints []*IstioEndpoint) ([]*IstioEndpoint, []QuarantinedEndpoint) {
+	var valid []*IstioEndpoint
+	var rejected []QuarantinedEndpoint
+	for i, ep := range istioEndpoints {
+		if err := ValidateEndpointAddress(ep.Address, ep.EndpointPort); err != nil {
+			if valid == nil {
+				valid = make([]*IstioEndpoint, i, len(istioEndpoints))
+				copy(valid, istioEndpoints[:i])
+			}
+			rejected = append(rejected, QuarantinedEndpoint{
+				Hostname:  hostname,
+				Namespace: namespace,
+				Shard:     shard,
+				Address:   ep.Address,
+				Reason:    err.Error(),
+			})
+			continue
+		}
+		if valid != nil {
+			valid = append(valid, ep)
+		}
+	}
+	if len(rejected) == 0 {
+		return istioEndpoints, nil
+	}
+	return valid, rejected
+}
+
+// setQuarantine replaces the quarantined endpoints of the service shard. Must be called with lock.
+func (e *EndpointIndex) setQuarantine(shard ShardKey, hostname, namespace string, rejected []QuarantinedEndpoint) {
+	key := quarantineKey{hostname: hostname, namespace: namespace, shard: shard}
+	if len(rejected) == 0 {
+		if _, f := e.quarantine[key]; !f {
+			return
+		}
+		delete(e.quarantine, key)
+	} else {
+		// The endpoints still quarantined by the previous updates of the shard were already reported.
+		previous := sets.New(e.quarantine[key]...)
+		for _, r := range rejected {
+			if previous.Contains(r) {
+				log.Debugf("endpoint %s of service %s/%s from shard %v is still quarantined: %s", r.Address, namespace, hostname, shard, r.Reason)
+				continue
+			}
+			log.Warnf("quarantined endpoint %s of service %s/%s from shard %v: %s", r.Address, namespace, hostname, shard, r.Reason)
+		}
+		e.quarantine[key] = rejected
+	}
+	e.recordQuarantine()
+}
+
+// clearQuarantine drops the quarantined endpoints of the service shard. Must be called with lock.
+func (e *EndpointIndex) clearQuarantine(shard ShardKey, hostname, namespace string) {
+	e.setQuarantine(shard, hostname, namespace, nil)
+}
+
+// must be called with lock
+func (e *EndpointIndex) recordQuarantine() {
+	total := 0
+	for _, v := range e.quarantine {
+		total += len(v)
+	}
+	quarantinedEndpoints.Record(float64(total))
+}
+
+// Quarantined returns the endpoints currently rejected from the index because of an invalid address.
+func (e *EndpointIndex) Quarantined() []QuarantinedEndpoint {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	out := make([]QuarantinedEndpoint, 0, len(e.quarantine))
+	for _, v := range e.quarantine {
+		out = append(out, v...)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Namespace != out[j].Namespace {
+			return out[i].Namespace < out[j].Namespace
+		}
+		if out[i].Hostname != out[j].Hostname {
+			return out[i].Hostname < out[j].Hostname
+		}
+		if out[i].Shard != out[j].Shard {
+			return out[i].Shard.String() < out[j].Shard.String()
+		}
+		return out[i].Address < out[j].Address
+	})
+	return out
+}
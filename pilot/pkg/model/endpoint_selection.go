@@ -0,0 +1,172 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// EndpointSelection restricts the endpoints sent to the proxies of a Sidecar, for example to exclude the localities
+// or clusters a namespace never reaches, shrinking their EDS in large multi-cluster meshes.
+// It is set with the networking.istio.io/endpoint-selection annotation of the Sidecar.
+type EndpointSelection struct {
+	// ExcludeLocalities are the localities whose endpoints are not sent, as region, region/zone or region/zone/subzone.
+	ExcludeLocalities []string `json:"excludeLocalities,omitempty"`
+	// ExcludeClusters are the clusters whose endpoints are not sent.
+	ExcludeClusters []string `json:"excludeClusters,omitempty"`
+	// Egress restricts the endpoints of the services matching some hosts further, like the egress listeners of the
+	// Sidecar import them.
+	Egress []EgressEndpointSelection `json:"egress,omitempty"`
+
+	key string
+}
+
+// EgressEndpointSelection restricts the endpoints of the services matching its hosts.
+type EgressEndpointSelection struct {
+	// Hosts are the services the selection applies to, in the namespace/dnsName format of the hosts of the Sidecar
+	// egress listeners. The namespace can be "*" for all namespaces, or "." for the namespace of the Sidecar.
+	Hosts []string `json:"hosts"`
+	// ExcludeLocalities are the localities whose endpoints are not sent, in addition to the ones of the Sidecar.
+	ExcludeLocalities []string `json:"excludeLocalities,omitempty"`
+	// ExcludeClusters are the clusters whose endpoints are not sent, in addition to the ones of the Sidecar.
+	ExcludeClusters []string `json:"excludeClusters,omitempty"`
+
+	hosts []egressHost
+}
+
+type egressHost struct {
+	namespace string
+	hostname  host.Name
+}
+
+// ParseEndpointSelection parses the value of the networking.istio.io/endpoint-selection annotation of a Sidecar of
+// the namespace.
+func ParseEndpointSelection(value string, namespace string) (*EndpointSelection, error) {
+	sel := &EndpointSelection{}
+	d := json.NewDecoder(bytes.NewReader([]byte(value)))
+	d.DisallowUnknownFields()
+	if err := d.Decode(sel); err != nil {
+		return nil, fmt.Errorf("invalid endpoint selection %q: %v", value, err)
+	}
+	if err := validateExcludedLocalities(sel.ExcludeLocalities); err != nil {
+		return nil, err
+	}
+	for i := range sel.Egress {
+		egress := &sel.Egress[i]
+		if len(egress.Hosts) == 0 {
+			return nil, fmt.Errorf("egress endpoint selection without hosts")
+		}
+		if err := validateExcludedLocalities(egress.ExcludeLocalities); err != nil {
+			return nil, err
+		}
+		for _, h := range egress.Hosts {
+			ns, hostname, ok := strings.Cut(h, "/")
+			if !ok || ns == "" || hostname == "" {
+				return nil, fmt.Errorf("invalid host %q in endpoint selection, expected namespace/dnsName", h)
+			}
+			if ns == currentNamespace {
+				ns = namespace
+			}
+			egress.hosts = append(egress.hosts, egressHost{namespace: ns, hostname: host.Name(hostname)})
+		}
+	}
+	return sel.withKey(), nil
+}
+
+func validateExcludedLocalities(localities []string) error {
+	for _, l := range localities {
+		if l == "" || strings.HasPrefix(l, "/") || strings.HasSuffix(l, "/") || strings.Count(l, "/") > 2 {
+			return fmt.Errorf("invalid locality %q in endpoint selection", l)
+		}
+	}
+	return nil
+}
+
+func (s *EndpointSelection) withKey() *EndpointSelection {
+	s.ExcludeLocalities = slices.Sort(s.ExcludeLocalities)
+	s.ExcludeClusters = slices.Sort(s.ExcludeClusters)
+	s.key = strings.Join(s.ExcludeLocalities, ",") + ";" + strings.Join(s.ExcludeClusters, ",")
+	return s
+}
+
+// ForService returns the selection of the endpoints of the service: the exclusions of the Sidecar, and the ones of
+// the egress selections matching the service.
+func (s *EndpointSelection) ForService(svc *Service) *EndpointSelection {
+	if s == nil || svc == nil || len(s.Egress) == 0 {
+		return s
+	}
+	var out *EndpointSelection
+	for _, egress := range s.Egress {
+		if !egress.matches(svc) {
+			continue
+		}
+		if out == nil {
+			out = &EndpointSelection{
+				ExcludeLocalities: slices.Clone(s.ExcludeLocalities),
+				ExcludeClusters:   slices.Clone(s.ExcludeClusters),
+			}
+		}
+		out.ExcludeLocalities = append(out.ExcludeLocalities, egress.ExcludeLocalities...)
+		out.ExcludeClusters = append(out.ExcludeClusters, egress.ExcludeClusters...)
+	}
+	if out == nil {
+		return s
+	}
+	out.ExcludeLocalities = sets.SortedList(sets.New(out.ExcludeLocalities...))
+	out.ExcludeClusters = sets.SortedList(sets.New(out.ExcludeClusters...))
+	return out.withKey()
+}
+
+func (e EgressEndpointSelection) matches(svc *Service) bool {
+	for _, h := range e.hosts {
+		if (h.namespace == wildcardNamespace || h.namespace == svc.Attributes.Namespace) && svc.Hostname.SubsetOf(h.hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// Excludes returns true if the endpoint must not be sent.
+func (s *EndpointSelection) Excludes(ep *IstioEndpoint) bool {
+	if s == nil {
+		return false
+	}
+	for _, c := range s.ExcludeClusters {
+		if ep.Locality.ClusterID.String() == c {
+			return true
+		}
+	}
+	for _, l := range s.ExcludeLocalities {
+		if ep.Locality.Label == l || strings.HasPrefix(ep.Locality.Label, l+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Key identifies the selection, for the cache keys of the endpoints.
+func (s *EndpointSelection) Key() string {
+	if s == nil {
+		return ""
+	}
+	return s.key
+}
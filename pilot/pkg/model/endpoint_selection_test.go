@@ -0,0 +1,135 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestParseEndpointSelection(t *testing.T) {
+	cases := []struct {
+		value   string
+		wantKey string
+		wantErr bool
+	}{
+		{value: `{}`, wantKey: ";"},
+		{value: `{"excludeLocalities":["us-east1/zone-b","eu-west1"],"excludeClusters":["c2"]}`, wantKey: "eu-west1,us-east1/zone-b;c2"},
+		{value: `{"excludeZones":["a"]}`, wantErr: true},
+		{value: `{"excludeLocalities":["us-east1/"]}`, wantErr: true},
+		{value: `{"excludeLocalities":["a/b/c/d"]}`, wantErr: true},
+		{value: `not json`, wantErr: true},
+		{value: `{"egress":[{"hosts":["*/*"],"excludeClusters":["c2"]}]}`, wantKey: ";"},
+		{value: `{"egress":[{"excludeClusters":["c2"]}]}`, wantErr: true},
+		{value: `{"egress":[{"hosts":["example.com"]}]}`, wantErr: true},
+		{value: `{"egress":[{"hosts":["*/*"],"excludeLocalities":["/a"]}]}`, wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.value, func(t *testing.T) {
+			sel, err := ParseEndpointSelection(tt.value, "ns")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, sel.Key(), tt.wantKey)
+		})
+	}
+}
+
+func TestEndpointSelectionExcludes(t *testing.T) {
+	sel, err := ParseEndpointSelection(`{"excludeLocalities":["us-east1/zone-b","eu-west1"],"excludeClusters":["c2"]}`, "ns")
+	assert.NoError(t, err)
+	cases := []struct {
+		locality string
+		cluster  string
+		want     bool
+	}{
+		{locality: "us-east1/zone-a/sub", cluster: "c1", want: false},
+		{locality: "us-east1/zone-b", cluster: "c1", want: true},
+		{locality: "us-east1/zone-b/sub", cluster: "c1", want: true},
+		// Only whole locality segments match.
+		{locality: "us-east1/zone-bb", cluster: "c1", want: false},
+		{locality: "eu-west1/zone-a", cluster: "c1", want: true},
+		{locality: "", cluster: "c2", want: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.locality+"/"+tt.cluster, func(t *testing.T) {
+			ep := &IstioEndpoint{Locality: Locality{Label: tt.locality, ClusterID: cluster.ID(tt.cluster)}}
+			assert.Equal(t, sel.Excludes(ep), tt.want)
+		})
+	}
+	var none *EndpointSelection
+	assert.Equal(t, none.Excludes(&IstioEndpoint{}), false)
+}
+
+func TestEndpointSelectionForService(t *testing.T) {
+	sel, err := ParseEndpointSelection(`{"excludeClusters":["c3"],"egress":[`+
+		`{"hosts":["./*"],"excludeLocalities":["eu-west1"]},`+
+		`{"hosts":["*/*.example.com"],"excludeClusters":["c2","c3"]}]}`, "ns")
+	assert.NoError(t, err)
+	svc := func(hostname, namespace string) *Service {
+		return &Service{Hostname: host.Name(hostname), Attributes: ServiceAttributes{Namespace: namespace}}
+	}
+	cases := []struct {
+		name    string
+		svc     *Service
+		wantKey string
+	}{
+		{name: "no service", wantKey: ";c3"},
+		{name: "no egress match", svc: svc("a.other.svc.cluster.local", "other"), wantKey: ";c3"},
+		{name: "namespace of the sidecar", svc: svc("a.ns.svc.cluster.local", "ns"), wantKey: "eu-west1;c3"},
+		{name: "any namespace", svc: svc("api.example.com", "other"), wantKey: ";c2,c3"},
+		{name: "several matches", svc: svc("api.example.com", "ns"), wantKey: "eu-west1;c2,c3"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, sel.ForService(tt.svc).Key(), tt.wantKey)
+		})
+	}
+	// The selection of the Sidecar is left unchanged.
+	assert.Equal(t, sel.Key(), ";c3")
+	var none *EndpointSelection
+	assert.Equal(t, none.ForService(svc("a.ns.svc.cluster.local", "ns")) == nil, true)
+}
+
+func TestSidecarScopeEndpointSelection(t *testing.T) {
+	ps := NewPushContext()
+	ps.Mesh = &meshconfig.MeshConfig{RootNamespace: "istio-system"}
+	sidecar := func(annotation string) *config.Config {
+		return &config.Config{
+			Meta: config.Meta{
+				GroupVersionKind: gvk.Sidecar,
+				Name:             "sidecar",
+				Namespace:        "ns",
+				Annotations:      map[string]string{constants.EndpointSelection: annotation},
+			},
+			Spec: &networking.Sidecar{},
+		}
+	}
+	sc := ConvertToSidecarScope(ps, sidecar(`{"excludeClusters":["c2"]}`), "ns")
+	assert.Equal(t, sc.EndpointSelection.ExcludeClusters, []string{"c2"})
+	// Invalid selections are ignored.
+	sc = ConvertToSidecarScope(ps, sidecar(`{"excludeClusters":"c2"}`), "ns")
+	assert.Equal(t, sc.EndpointSelection == nil, true)
+}
@@ -0,0 +1,173 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"encoding/binary"
+	"sort"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/network"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// shardSummary digests the endpoints of a shard by network. The digest of a network changes whenever any of its
+// endpoints is added, removed or modified, so that the networks affected by an update are known without comparing
+// the endpoints.
+type shardSummary map[network.ID]uint64
+
+// summarizeEndpoints returns the summary of the endpoints of a shard. The digest of a network is the sum of the
+// hashes of its endpoints, which does not depend on their order.
+func summarizeEndpoints(eps []*IstioEndpoint) shardSummary {
+	out := make(shardSummary)
+	h := endpointHasher{d: xxhash.New()}
+	for _, ep := range eps {
+		out[ep.Network] += h.hash(ep)
+	}
+	return out
+}
+
+// endpointHasher hashes the fields of endpoints which may affect the configuration of the proxies. It is called
+// with the lock of the shards held, so it hashes the fields in place rather than serializing the endpoints.
+type endpointHasher struct {
+	d    *xxhash.Digest
+	buf  [8]byte
+	keys []string
+}
+
+func (h *endpointHasher) hash(ep *IstioEndpoint) uint64 {
+	h.d.Reset()
+	h.strings(ep.Labels)
+	h.str(ep.Address)
+	h.str(ep.ServicePortName)
+	h.str(ep.ServiceAccount)
+	h.str(string(ep.Network))
+	h.str(ep.Locality.Label)
+	h.str(string(ep.Locality.ClusterID))
+	h.uint(uint64(ep.EndpointPort))
+	h.ports(ep.AdditionalPorts)
+	h.uint(uint64(ep.LbWeight))
+	h.str(ep.TLSMode)
+	h.str(ep.Namespace)
+	h.str(ep.WorkloadName)
+	h.str(ep.WorkloadKind)
+	h.str(ep.HostName)
+	h.str(ep.SubDomain)
+	h.uint(uint64(ep.HealthStatus))
+	h.str(ep.NodeName)
+	h.str(ep.DataResidency)
+	h.uint(uint64(len(ep.ZoneHints)))
+	for _, z := range ep.ZoneHints {
+		h.str(z)
+	}
+	h.str(ep.InstanceName)
+	h.str(ep.PreviousAddress)
+	h.str(ep.MigratedTo)
+	h.str(ep.WorkloadAddress)
+	h.strings(ep.Annotations)
+	h.uint(uint64(ep.TTL))
+	if ep.Completed {
+		h.uint(1)
+	} else {
+		h.uint(0)
+	}
+	h.uint(uint64(ep.CapacityRPS))
+	if so := ep.SocketOptions; so != nil {
+		h.uint(1)
+		h.uint(uint64(so.KeepaliveTime))
+		h.uint(uint64(so.KeepaliveInterval))
+		h.uint(uint64(so.KeepaliveProbes))
+	} else {
+		h.uint(0)
+	}
+	h.time(ep.FirstSeen)
+	h.time(ep.DrainingSince)
+	return h.d.Sum64()
+}
+
+func (h *endpointHasher) str(s string) {
+	h.uint(uint64(len(s)))
+	_, _ = h.d.WriteString(s)
+}
+
+func (h *endpointHasher) uint(v uint64) {
+	binary.LittleEndian.PutUint64(h.buf[:], v)
+	_, _ = h.d.Write(h.buf[:])
+}
+
+func (h *endpointHasher) time(t time.Time) {
+	if t.IsZero() {
+		h.uint(0)
+		return
+	}
+	h.uint(uint64(t.UnixNano()))
+}
+
+// sortedKeys returns the keys of the map, sorted, reusing the same slice across calls.
+func sortedKeys[V any](h *endpointHasher, m map[string]V) []string {
+	h.keys = h.keys[:0]
+	for k := range m {
+		h.keys = append(h.keys, k)
+	}
+	sort.Strings(h.keys)
+	return h.keys
+}
+
+func (h *endpointHasher) strings(m map[string]string) {
+	h.uint(uint64(len(m)))
+	for _, k := range sortedKeys(h, m) {
+		h.str(k)
+		h.str(m[k])
+	}
+}
+
+func (h *endpointHasher) ports(m map[string]uint32) {
+	h.uint(uint64(len(m)))
+	for _, k := range sortedKeys(h, m) {
+		h.str(k)
+		h.uint(uint64(m[k]))
+	}
+}
+
+// updateSummary records the summary of the endpoints of the shard, and returns the networks whose endpoints changed
+// since the previous summary. Must be called with the lock held.
+func (es *EndpointShards) updateSummary(shard ShardKey) sets.Set[network.ID] {
+	prev := es.summaries[shard]
+	eps, f := es.Shards[shard]
+	if !f {
+		delete(es.summaries, shard)
+		return sets.New(maps.Keys(prev)...)
+	}
+	next := summarizeEndpoints(eps)
+	if es.summaries == nil {
+		es.summaries = map[ShardKey]shardSummary{}
+	}
+	es.summaries[shard] = next
+	changed := sets.New[network.ID]()
+	for n, digest := range next {
+		if prev[n] != digest {
+			changed.Insert(n)
+		}
+	}
+	for n := range prev {
+		if _, f := next[n]; !f {
+			changed.Insert(n)
+		}
+	}
+	return changed
+}
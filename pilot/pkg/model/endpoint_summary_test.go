@@ -0,0 +1,111 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pkg/network"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/util/sets"
+)
+
+func TestUpdateServiceEndpointsByNetwork(t *testing.T) {
+	index := NewEndpointIndex(DisabledCache{})
+	shard := ShardKey{Cluster: "c1"}
+	ep := func(address string, nw network.ID, health HealthStatus) *IstioEndpoint {
+		return &IstioEndpoint{Address: address, Network: nw, HealthStatus: health, Labels: map[string]string{"app": "a"}}
+	}
+	update := func(eps ...*IstioEndpoint) sets.Set[network.ID] {
+		_, changed := index.UpdateServiceEndpointsByNetwork(shard, "a.com", "ns", eps)
+		return changed
+	}
+
+	// The networks are unknown unless enabled.
+	assert.Equal(t, update(ep("10.0.0.1", "n1", Healthy)), nil)
+
+	test.SetForTest(t, &features.EnableEndpointShardSummaries, true)
+	assert.Equal(t, update(ep("10.0.0.1", "n1", Healthy), ep("10.0.1.1", "n2", Healthy)), sets.New[network.ID]("n1", "n2"))
+	assert.Equal(t, update(ep("10.0.1.1", "n2", Healthy), ep("10.0.0.1", "n1", Healthy)), sets.New[network.ID]())
+	assert.Equal(t, update(ep("10.0.0.1", "n1", Healthy), ep("10.0.1.1", "n2", UnHealthy)), sets.New[network.ID]("n2"))
+	assert.Equal(t, update(ep("10.0.0.1", "n1", Healthy)), sets.New[network.ID]("n2"))
+	// Removing the shard removes its summary.
+	index.DeleteServiceShard(shard, "a.com", "ns", true)
+	assert.Equal(t, update(ep("10.0.1.1", "n2", Healthy)), sets.New[network.ID]("n2"))
+}
+
+func TestSummarizeEndpointsCoversFields(t *testing.T) {
+	// Every field which may affect the configuration of the proxies must change the summary when set. Only the
+	// fields below are not hashed.
+	ignored := sets.New("DiscoverabilityPolicy")
+	base := &IstioEndpoint{Network: "n1"}
+	digest := summarizeEndpoints([]*IstioEndpoint{base})["n1"]
+	typ := reflect.TypeOf(base).Elem()
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if !f.IsExported() || ignored.Contains(f.Name) || f.Name == "Network" {
+			continue
+		}
+		ep := &IstioEndpoint{Network: "n1"}
+		setNonZero(t, reflect.ValueOf(ep).Elem().Field(i))
+		if summarizeEndpoints([]*IstioEndpoint{ep})["n1"] == digest {
+			t.Errorf("field %s does not change the summary of the endpoints", f.Name)
+		}
+	}
+}
+
+// setNonZero sets a non-zero value to a field of an endpoint, and to the fields of its structs.
+func setNonZero(t *testing.T, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString("x")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.Slice:
+		s := reflect.MakeSlice(v.Type(), 1, 1)
+		setNonZero(t, s.Index(0))
+		v.Set(s)
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		val := reflect.New(v.Type().Elem()).Elem()
+		setNonZero(t, val)
+		m.SetMapIndex(reflect.ValueOf("k"), val)
+		v.Set(m)
+	case reflect.Pointer:
+		p := reflect.New(v.Type().Elem())
+		setNonZero(t, p.Elem())
+		v.Set(p)
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(time.Time{}) {
+			v.Set(reflect.ValueOf(time.Unix(1, 0)))
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				setNonZero(t, v.Field(i))
+			}
+		}
+	default:
+		t.Fatalf("unsupported kind %v", v.Kind())
+	}
+}
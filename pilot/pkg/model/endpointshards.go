@@ -18,11 +18,13 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"time"
 
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/serviceregistry/provider"
 	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/network"
 	"istio.io/istio/pkg/util/sets"
 )
 
@@ -70,6 +72,10 @@ type EndpointShards struct {
 	// Due to the larger time, it is still possible that connection errors will occur while
 	// CDS is updated.
 	ServiceAccounts sets.String
+
+	// summaries holds the summary of the endpoints of each shard, maintained when
+	// features.EnableEndpointShardSummaries is enabled.
+	summaries map[ShardKey]shardSummary
 }
 
 // Keys gives a sorted list of keys for EndpointShards.Shards.
@@ -100,6 +106,15 @@ func (es *EndpointShards) CopyEndpoints(portMap map[string]int) map[int][]*Istio
 	res := map[int][]*IstioEndpoint{}
 	for _, v := range es.Shards {
 		for _, ep := range v {
+			if len(ep.AdditionalPorts) > 0 {
+				// Endpoints serving several ports are listed under each of them.
+				for name, portNum := range portMap {
+					if pep := ep.ForPort(name); pep != nil {
+						res[portNum] = append(res[portNum], pep)
+					}
+				}
+				continue
+			}
 			portNum, f := portMap[ep.ServicePortName]
 			if !f {
 				continue
@@ -133,12 +148,15 @@ type EndpointIndex struct {
 	shardsBySvc map[string]map[string]*EndpointShards
 	// We'll need to clear the cache in-sync with endpoint shards modifications.
 	cache XdsCache
+	// quarantine holds the endpoints rejected because of an invalid address, keyed by service shard.
+	quarantine map[quarantineKey][]QuarantinedEndpoint
 }
 
 func NewEndpointIndex(cache XdsCache) *EndpointIndex {
 	return &EndpointIndex{
 		shardsBySvc: make(map[string]map[string]*EndpointShards),
 		cache:       cache,
+		quarantine:  make(map[quarantineKey][]QuarantinedEndpoint),
 	}
 }
 
@@ -166,6 +184,53 @@ func (e *EndpointIndex) Shardz() map[string]map[string]*EndpointShards {
 	return out
 }
 
+// MemoryEstimate returns the number of endpoints in the index and an estimate of the memory they use, in bytes.
+func (e *EndpointIndex) MemoryEstimate() (int, int) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	count, size := 0, 0
+	for _, byNs := range e.shardsBySvc {
+		for _, shards := range byNs {
+			shards.RLock()
+			for _, eps := range shards.Shards {
+				for _, ep := range eps {
+					count++
+					size += ep.sizeEstimate()
+				}
+			}
+			shards.RUnlock()
+		}
+	}
+	return count, size
+}
+
+// ForEachEndpoint calls f with each endpoint of the index, along with its service, namespace and shard. The shards of
+// each service are locked while f is called with their endpoints, so f must not update them or retain the endpoints;
+// the index itself is only locked to list the services, so updates of other services are not blocked by the walk.
+func (e *EndpointIndex) ForEachEndpoint(f func(serviceName, namespace string, shard ShardKey, ep *IstioEndpoint)) {
+	type serviceShards struct {
+		svc, ns string
+		shards  *EndpointShards
+	}
+	e.mu.RLock()
+	all := make([]serviceShards, 0, len(e.shardsBySvc))
+	for svc, byNs := range e.shardsBySvc {
+		for ns, shards := range byNs {
+			all = append(all, serviceShards{svc: svc, ns: ns, shards: shards})
+		}
+	}
+	e.mu.RUnlock()
+	for _, s := range all {
+		s.shards.RLock()
+		for shard, eps := range s.shards.Shards {
+			for _, ep := range eps {
+				f(s.svc, s.ns, shard, ep)
+			}
+		}
+		s.shards.RUnlock()
+	}
+}
+
 // ShardsForService returns the shards and true if they are found, or returns nil, false.
 func (e *EndpointIndex) ShardsForService(serviceName, namespace string) (*EndpointShards, bool) {
 	e.mu.RLock()
@@ -183,7 +248,27 @@ func (e *EndpointIndex) ShardsForService(serviceName, namespace string) (*Endpoi
 func (e *EndpointIndex) GetOrCreateEndpointShard(serviceName, namespace string) (*EndpointShards, bool) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	return e.getOrCreateEndpointShardInner(serviceName, namespace)
+}
+
+// lockEndpointShard is GetOrCreateEndpointShard, also locking the shards and replacing the quarantined endpoints of
+// the service shard in the same critical section, so concurrent updates apply both in the same order.
+func (e *EndpointIndex) lockEndpointShard(
+	shard ShardKey,
+	serviceName string,
+	namespace string,
+	rejected []QuarantinedEndpoint,
+) (*EndpointShards, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ep, created := e.getOrCreateEndpointShardInner(serviceName, namespace)
+	ep.Lock()
+	e.setQuarantine(shard, serviceName, namespace, rejected)
+	return ep, created
+}
 
+// must be called with lock
+func (e *EndpointIndex) getOrCreateEndpointShardInner(serviceName, namespace string) (*EndpointShards, bool) {
 	if _, exists := e.shardsBySvc[serviceName]; !exists {
 		e.shardsBySvc[serviceName] = map[string]*EndpointShards{}
 	}
@@ -215,6 +300,11 @@ func (e *EndpointIndex) DeleteShard(shardKey ShardKey) {
 			e.deleteServiceInner(shardKey, svc, ns, false)
 		}
 	}
+	for k := range e.quarantine {
+		if k.shard == shardKey {
+			e.clearQuarantine(k.shard, k.hostname, k.namespace)
+		}
+	}
 	if e.cache == nil {
 		return
 	}
@@ -223,6 +313,7 @@ func (e *EndpointIndex) DeleteShard(shardKey ShardKey) {
 
 // must be called with lock
 func (e *EndpointIndex) deleteServiceInner(shard ShardKey, serviceName, namespace string, preserveKeys bool) {
+	e.clearQuarantine(shard, serviceName, namespace)
 	if e.shardsBySvc[serviceName] == nil ||
 		e.shardsBySvc[serviceName][namespace] == nil {
 		return
@@ -230,6 +321,9 @@ func (e *EndpointIndex) deleteServiceInner(shard ShardKey, serviceName, namespac
 	epShards := e.shardsBySvc[serviceName][namespace]
 	epShards.Lock()
 	delete(epShards.Shards, shard)
+	if features.EnableEndpointShardSummaries {
+		epShards.updateSummary(shard)
+	}
 	// Clear the cache here to avoid race in cache writes.
 	e.clearCacheForService(serviceName, namespace)
 	if !preserveKeys {
@@ -264,27 +358,43 @@ func (e *EndpointIndex) UpdateServiceEndpoints(
 	namespace string,
 	istioEndpoints []*IstioEndpoint,
 ) PushType {
+	pushType, _ := e.UpdateServiceEndpointsByNetwork(shard, hostname, namespace, istioEndpoints)
+	return pushType
+}
+
+// UpdateServiceEndpointsByNetwork is UpdateServiceEndpoints, also returning the networks of the endpoints that changed
+// when features.EnableEndpointShardSummaries is enabled. The networks are nil if unknown.
+func (e *EndpointIndex) UpdateServiceEndpointsByNetwork(
+	shard ShardKey,
+	hostname string,
+	namespace string,
+	istioEndpoints []*IstioEndpoint,
+) (PushType, sets.Set[network.ID]) {
+	// Endpoints with an invalid address would result in broken Envoy configuration; keep them aside instead.
+	istioEndpoints, rejected := filterInvalidEndpoints(shard, hostname, namespace, istioEndpoints)
 	if len(istioEndpoints) == 0 {
 		// Should delete the service EndpointShards when endpoints become zero to prevent memory leak,
 		// but we should not delete the keys from EndpointIndex map - that will trigger
 		// unnecessary full push which can become a real problem if a pod is in crashloop and thus endpoints
 		// flip flopping between 1 and 0.
-		e.DeleteServiceShard(shard, hostname, namespace, true)
+		e.mu.Lock()
+		e.deleteServiceInner(shard, hostname, namespace, true)
+		e.setQuarantine(shard, hostname, namespace, rejected)
+		e.mu.Unlock()
 		log.Infof("Incremental push, service %s at shard %v has no endpoints", hostname, shard)
-		return IncrementalPush
+		return IncrementalPush, nil
 	}
 
 	pushType := IncrementalPush
 	// Find endpoint shard for this service, if it is available - otherwise create a new one.
-	ep, created := e.GetOrCreateEndpointShard(hostname, namespace)
+	ep, created := e.lockEndpointShard(shard, hostname, namespace, rejected)
+	defer ep.Unlock()
 	// If we create a new endpoint shard, that means we have not seen the service earlier. We should do a full push.
 	if created {
 		log.Infof("Full push, new service %s/%s", namespace, hostname)
 		pushType = FullPush
 	}
 
-	ep.Lock()
-	defer ep.Unlock()
 	newIstioEndpoints := istioEndpoints
 	if features.SendUnhealthyEndpoints.Load() {
 		oldIstioEndpoints := ep.Shards[shard]
@@ -295,19 +405,21 @@ func (e *EndpointIndex) UpdateServiceEndpoints(
 		// we do not unnecessarily push that config to Envoy.
 		// Please note that address is not a unique key. So this may not accurately
 		// identify based on health status and push too many times - which is ok since its an optimization.
-		emap := make(map[string]*IstioEndpoint, len(oldIstioEndpoints))
-		nmap := make(map[string]*IstioEndpoint, len(newIstioEndpoints))
+		// Pod IP ranges may overlap across networks, so addresses are scoped by network, and pods may share the
+		// address of their node, so they are told apart by workload instance.
+		emap := make(map[endpointInstance]*IstioEndpoint, len(oldIstioEndpoints))
+		nmap := make(map[endpointInstance]*IstioEndpoint, len(newIstioEndpoints))
 		// Add new endpoints only if they are ever ready once to shards
 		// so that full push does not send them from shards.
 		for _, oie := range oldIstioEndpoints {
-			emap[oie.Address] = oie
+			emap[oie.endpointInstance()] = oie
 		}
 		for _, nie := range istioEndpoints {
-			nmap[nie.Address] = nie
+			nmap[nie.endpointInstance()] = nie
 		}
 		needPush := false
 		for _, nie := range istioEndpoints {
-			if oie, exists := emap[nie.Address]; exists {
+			if oie, exists := emap[nie.endpointInstance()]; exists {
 				// If endpoint exists already, we should push if it's health status changes.
 				if oie.HealthStatus != nie.HealthStatus {
 					needPush = true
@@ -324,7 +436,7 @@ func (e *EndpointIndex) UpdateServiceEndpoints(
 		// Next, check for endpoints that were in old but no longer exist. If there are any, there is a
 		// removal so we need to push an update.
 		for _, oie := range oldIstioEndpoints {
-			if _, f := nmap[oie.Address]; !f {
+			if _, f := nmap[oie.endpointInstance()]; !f {
 				needPush = true
 			}
 		}
@@ -336,7 +448,20 @@ func (e *EndpointIndex) UpdateServiceEndpoints(
 
 	}
 
+	if features.EnableScaleUpRamp {
+		newIstioEndpoints = stampFirstSeen(ep.Shards[shard], newIstioEndpoints, time.Now())
+	}
+	if features.PersistentSessionDrainTTL > 0 {
+		newIstioEndpoints = stampDrainingSince(ep.Shards[shard], newIstioEndpoints, time.Now())
+	}
+	if features.EnableEndpointMigration {
+		newIstioEndpoints = linkMigratedEndpoints(ep.Shards[shard], newIstioEndpoints)
+	}
 	ep.Shards[shard] = newIstioEndpoints
+	var changedNetworks sets.Set[network.ID]
+	if features.EnableEndpointShardSummaries {
+		changedNetworks = ep.updateSummary(shard)
+	}
 
 	// Check if ServiceAccounts have changed. We should do a full push if they have changed.
 	saUpdated := updateShardServiceAccount(ep, hostname)
@@ -357,7 +482,30 @@ func (e *EndpointIndex) UpdateServiceEndpoints(
 	// would clear it shortly after anyways.
 	e.clearCacheForService(hostname, namespace)
 
-	return pushType
+	return pushType, changedNetworks
+}
+
+// networkAddress identifies an endpoint address. Pod IP ranges may overlap across networks, so an
+// address alone does not identify an endpoint.
+type networkAddress struct {
+	network network.ID
+	address string
+}
+
+func (ep *IstioEndpoint) networkAddress() networkAddress {
+	return networkAddress{network: ep.Network, address: ep.Address}
+}
+
+// endpointInstance identifies the workload instance of an endpoint on its address. Several workload instances may
+// share an address, such as the pods reached through the address of their node.
+type endpointInstance struct {
+	networkAddress
+	namespace string
+	instance  string
+}
+
+func (ep *IstioEndpoint) endpointInstance() endpointInstance {
+	return endpointInstance{networkAddress: ep.networkAddress(), namespace: ep.Namespace, instance: ep.InstanceName}
 }
 
 // updateShardServiceAccount updates the service endpoints' sa when service/endpoint event happens.
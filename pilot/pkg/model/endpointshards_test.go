@@ -15,7 +15,14 @@
 package model
 
 import (
+	"fmt"
 	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
 )
 
 func TestUpdateServiceAccount(t *testing.T) {
@@ -101,3 +108,175 @@ func TestUpdateServiceAccount(t *testing.T) {
 		})
 	}
 }
+
+func TestEndpointQuarantine(t *testing.T) {
+	shard := ShardKey{Cluster: "c1"}
+	index := NewEndpointIndex(DisabledCache{})
+	index.UpdateServiceEndpoints(shard, "a.ns.svc.cluster.local", "ns", []*IstioEndpoint{
+		{Address: "10.0.0.1", EndpointPort: 80},
+		{Address: "10.0.0.300", EndpointPort: 80},
+		{Address: "unix:///var/run/a.sock"},
+		{Address: "vm.example.com", EndpointPort: 80},
+		{Address: "", Network: "remote", EndpointPort: 80},
+		{Address: "fd00::1::2", EndpointPort: 80},
+	})
+
+	shards, _ := index.ShardsForService("a.ns.svc.cluster.local", "ns")
+	got := []string{}
+	for _, ep := range shards.Shards[shard] {
+		got = append(got, ep.Address)
+	}
+	if want := []string{"10.0.0.1", "unix:///var/run/a.sock", "vm.example.com", ""}; !slices.Equal(got, want) {
+		t.Fatalf("expected endpoints %v, got %v", want, got)
+	}
+	quarantined := index.Quarantined()
+	if len(quarantined) != 2 || quarantined[0].Address != "10.0.0.300" || quarantined[1].Address != "fd00::1::2" {
+		t.Fatalf("unexpected quarantined endpoints %+v", quarantined)
+	}
+
+	// Fixing the endpoints releases them from the quarantine.
+	index.UpdateServiceEndpoints(shard, "a.ns.svc.cluster.local", "ns", []*IstioEndpoint{{Address: "10.0.0.3", EndpointPort: 80}})
+	if q := index.Quarantined(); len(q) != 0 {
+		t.Fatalf("expected no quarantined endpoints, got %+v", q)
+	}
+
+	// Deleting the shard drops its quarantined endpoints.
+	index.UpdateServiceEndpoints(shard, "a.ns.svc.cluster.local", "ns", []*IstioEndpoint{{Address: "bad address", EndpointPort: 80}})
+	if q := index.Quarantined(); len(q) != 1 {
+		t.Fatalf("expected one quarantined endpoint, got %+v", q)
+	}
+	index.DeleteShard(shard)
+	if q := index.Quarantined(); len(q) != 0 {
+		t.Fatalf("expected no quarantined endpoints, got %+v", q)
+	}
+}
+
+func TestUpdateServiceEndpointsOverlappingNetworks(t *testing.T) {
+	test.SetAtomicBoolForTest(t, features.SendUnhealthyEndpoints, true)
+	shard := ShardKey{Cluster: "c1"}
+	index := NewEndpointIndex(DisabledCache{})
+	endpoints := func(nw1, nw2 HealthStatus) []*IstioEndpoint {
+		// Both networks use the same pod IP range.
+		return []*IstioEndpoint{
+			{Address: "10.0.0.1", Network: "nw1", EndpointPort: 80, HealthStatus: nw1},
+			{Address: "10.0.0.1", Network: "nw2", EndpointPort: 80, HealthStatus: nw2},
+		}
+	}
+	index.UpdateServiceEndpoints(shard, "a.ns.svc.cluster.local", "ns", endpoints(Healthy, Healthy))
+
+	cases := []struct {
+		name      string
+		endpoints []*IstioEndpoint
+		want      PushType
+	}{
+		{"no change", endpoints(Healthy, Healthy), NoPush},
+		{"nw1 unhealthy", endpoints(UnHealthy, Healthy), IncrementalPush},
+		{"nw1 healthy again", endpoints(Healthy, Healthy), IncrementalPush},
+		{"nw1 removed", endpoints(Healthy, Healthy)[1:], IncrementalPush},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := index.UpdateServiceEndpoints(shard, "a.ns.svc.cluster.local", "ns", tt.endpoints); got != tt.want {
+				t.Fatalf("expected push type %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUpdateServiceEndpointsMigration(t *testing.T) {
+	test.SetForTest(t, &features.EnableEndpointMigration, true)
+	shard := ShardKey{Cluster: "c1"}
+	index := NewEndpointIndex(DisabledCache{})
+	endpoint := func(instance, address string) *IstioEndpoint {
+		return &IstioEndpoint{
+			Address: address, InstanceName: instance, Namespace: "ns", ServicePortName: "http",
+			EndpointPort: 80, HealthStatus: Healthy,
+		}
+	}
+	type summary struct {
+		Address, Previous, MigratedTo string
+		Health                        HealthStatus
+	}
+	update := func(eps ...*IstioEndpoint) []summary {
+		index.UpdateServiceEndpoints(shard, "a.ns.svc.cluster.local", "ns", eps)
+		shards, _ := index.ShardsForService("a.ns.svc.cluster.local", "ns")
+		var out []summary
+		for _, ep := range shards.Shards[shard] {
+			out = append(out, summary{ep.Address, ep.PreviousAddress, ep.MigratedTo, ep.HealthStatus})
+		}
+		return out
+	}
+
+	update(endpoint("pod-1", "10.0.0.1"), endpoint("pod-2", "10.0.0.2"))
+	// pod-1 is re-addressed: its previous address is kept as draining, and linked to the new one.
+	assert.Equal(t, update(endpoint("pod-1", "10.0.0.3"), endpoint("pod-2", "10.0.0.2")), []summary{
+		{"10.0.0.3", "10.0.0.1", "", Healthy},
+		{"10.0.0.1", "", "10.0.0.3", Draining},
+		{"10.0.0.2", "", "", Healthy},
+	})
+	// The draining endpoint is dropped on the next update.
+	assert.Equal(t, update(endpoint("pod-1", "10.0.0.3"), endpoint("pod-2", "10.0.0.2")), []summary{
+		{"10.0.0.3", "", "", Healthy},
+		{"10.0.0.2", "", "", Healthy},
+	})
+	// Endpoints of other instances are not linked.
+	assert.Equal(t, update(endpoint("pod-1", "10.0.0.3"), endpoint("pod-3", "10.0.0.4")), []summary{
+		{"10.0.0.3", "", "", Healthy},
+		{"10.0.0.4", "", "", Healthy},
+	})
+}
+
+func TestCopyEndpointsMultiPort(t *testing.T) {
+	es := &EndpointShards{Shards: map[ShardKey][]*IstioEndpoint{
+		{Cluster: "c1"}: {
+			{Address: "10.0.0.1", ServicePortName: "http", EndpointPort: 8080, AdditionalPorts: map[string]uint32{"grpc": 9090}},
+			{Address: "10.0.0.2", ServicePortName: "http", EndpointPort: 8080},
+		},
+	}}
+	ports := func(eps []*IstioEndpoint) []string {
+		return slices.Map(eps, func(ep *IstioEndpoint) string {
+			return fmt.Sprintf("%s/%s:%d", ep.ServicePortName, ep.Address, ep.EndpointPort)
+		})
+	}
+	got := es.CopyEndpoints(map[string]int{"http": 80, "grpc": 90})
+	assert.Equal(t, ports(got[80]), []string{"http/10.0.0.1:8080", "http/10.0.0.2:8080"})
+	assert.Equal(t, ports(got[90]), []string{"grpc/10.0.0.1:9090"})
+	// The endpoint of an additional port does not carry the other ports.
+	assert.Equal(t, got[90][0].AdditionalPorts, nil)
+	assert.Equal(t, es.Shards[ShardKey{Cluster: "c1"}][0].ForPort("tcp"), nil)
+}
+
+func TestStampFirstSeen(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	t1 := t0.Add(time.Minute)
+	old := []*IstioEndpoint{
+		{Address: "10.0.0.1", FirstSeen: t0},
+		{Address: "10.0.0.2", FirstSeen: t0},
+	}
+	registered := []*IstioEndpoint{
+		{Address: "10.0.0.1"},
+		{Address: "10.0.0.3"},
+		{Address: "10.0.0.2", Network: "remote"},
+	}
+	stamped := stampFirstSeen(old, registered, t1)
+	assert.Equal(t, stamped[0].FirstSeen, t0)
+	assert.Equal(t, stamped[1].FirstSeen, t1)
+	// Endpoints are identified by their network and address.
+	assert.Equal(t, stamped[2].FirstSeen, t1)
+	// The endpoints are owned by the registries, they are not modified.
+	for _, ep := range registered {
+		assert.Equal(t, ep.FirstSeen.IsZero(), true)
+	}
+
+	// Workload instances sharing an address, such as the address of their node, are told apart.
+	old = []*IstioEndpoint{{Address: "192.168.0.1", Namespace: "ns", InstanceName: "pod-a", FirstSeen: t0}}
+	stamped = stampFirstSeen(old, []*IstioEndpoint{
+		{Address: "192.168.0.1", Namespace: "ns", InstanceName: "pod-a"},
+		{Address: "192.168.0.1", Namespace: "ns", InstanceName: "pod-b"},
+	}, t1)
+	assert.Equal(t, stamped[0].FirstSeen, t0)
+	assert.Equal(t, stamped[1].FirstSeen, t1)
+
+	// The endpoints already stamped are kept as is.
+	assert.Equal(t, stampFirstSeen(nil, stamped, t1)[0] == stamped[0], true)
+}
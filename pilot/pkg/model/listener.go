@@ -24,6 +24,10 @@ const (
 	// VirtualOutboundCatchAllTCPFilterChainName is the name of the catch all tcp filter chain
 	VirtualOutboundCatchAllTCPFilterChainName = "virtualOutbound-catchall-tcp"
 
+	// VirtualOutboundPassthroughEndpointFilterChainPrefix prefixes the names of the filter chains forwarding traffic
+	// to an endpoint of a ServiceEntry through the PassthroughEndpointsCluster, followed by its <address>:<port>.
+	VirtualOutboundPassthroughEndpointFilterChainPrefix = "virtualOutbound-passthrough-endpoint-"
+
 	// VirtualOutboundBlackholeFilterChainName is the name of the filter chain to blackhole undesired traffic
 	VirtualOutboundBlackholeFilterChainName = "virtualOutbound-blackhole"
 	// VirtualInboundBlackholeFilterChainName is the name of the filter chain to blackhole undesired traffic
@@ -0,0 +1,69 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"sync"
+
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+)
+
+// mtlsModeCache caches the mTLS mode derived from PeerAuthentication for service ports. It lives in the
+// PushContext: PeerAuthentication changes always result in a new PushContext, which invalidates the cache.
+type mtlsModeCache struct {
+	mu    sync.RWMutex
+	modes map[mtlsModeKey]MutualTLSMode
+}
+
+// mtlsModeKey identifies a port of a service. The labels are only set when a PeerAuthentication with a
+// workload selector applies to the namespace, as the endpoints of the service may then have different modes.
+type mtlsModeKey struct {
+	service   host.Name
+	namespace string
+	port      uint32
+	labels    string
+}
+
+func newMtlsModeCache() *mtlsModeCache {
+	return &mtlsModeCache{modes: map[mtlsModeKey]MutualTLSMode{}}
+}
+
+// MutualTLSModeForService returns the mTLS mode for the port of the endpoints of the service in the namespace,
+// calling compute on a cache miss. Without PeerAuthentications selecting workloads, all the endpoints of the
+// service share the mode; otherwise it is cached per workload labels.
+func (ps *PushContext) MutualTLSModeForService(service host.Name, namespace string, port uint32,
+	workloadLabels labels.Instance, compute func() MutualTLSMode,
+) MutualTLSMode {
+	c := ps.mtlsModes
+	if c == nil {
+		return compute()
+	}
+	key := mtlsModeKey{service: service, namespace: namespace, port: port}
+	if ps.AuthnPolicies.HasWorkloadPeerAuthentications(namespace) {
+		key.labels = workloadLabels.String()
+	}
+	c.mu.RLock()
+	mode, f := c.modes[key]
+	c.mu.RUnlock()
+	if f {
+		return mode
+	}
+	mode = compute()
+	c.mu.Lock()
+	c.modes[key] = mode
+	c.mu.Unlock()
+	return mode
+}
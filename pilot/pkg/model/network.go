@@ -17,6 +17,7 @@ package model
 import (
 	"fmt"
 	"net"
+	"reflect"
 	"sort"
 	"strings"
 	"sync"
@@ -27,6 +28,8 @@ import (
 
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/monitoring"
 	"istio.io/istio/pkg/network"
 	"istio.io/istio/pkg/slices"
 	"istio.io/istio/pkg/util/istiomultierror"
@@ -44,6 +47,9 @@ type NetworkGateway struct {
 	Addr string
 	// gateway port
 	Port uint32
+	// HBONEPort is the port on which the gateway accepts HBONE (double CONNECT) connections to the workloads of its
+	// network, preserving their identity. 0 if the gateway does not support HBONE.
+	HBONEPort uint32
 }
 
 type NetworkGatewaysWatcher interface {
@@ -72,6 +78,8 @@ type NetworkGateways struct {
 	lcm                 uint32
 	byNetwork           map[network.ID][]NetworkGateway
 	byNetworkAndCluster map[networkAndCluster][]NetworkGateway
+	// unresolvable holds the gateway hostnames the control plane failed to resolve to any address.
+	unresolvable sets.String
 }
 
 // NetworkManager provides gateway details for accessing remote networks.
@@ -88,6 +96,9 @@ type NetworkManager struct {
 	*NetworkGateways
 	// includes all gateways with no DNS resolution or filtering, regardless of feature flags
 	Unresolved *NetworkGateways
+
+	// addressTranslations are the 1:1 NAT mappings of the addresses of the endpoints of each network, from MeshNetworks.
+	addressTranslations map[network.ID][]mesh.NetworkAddressTranslation
 }
 
 // NewNetworkManager creates a new NetworkManager from the Environment by merging
@@ -161,9 +172,50 @@ func (mgr *NetworkManager) reload() bool {
 	// - the internal map of label gateways - these get deleted if the service is deleted, updated if the ip changes etc.
 	// - the computed map from meshNetworks (triggered by reloadNetworkLookup, the ported logic from getGatewayAddresses)
 	gatewaySet.InsertAll(mgr.env.NetworkGateways()...)
-	resolvedGatewaySet := mgr.resolveHostnameGateways(gatewaySet)
+	resolvedGatewaySet, unresolvable := mgr.resolveHostnameGateways(gatewaySet)
+
+	changed := mgr.NetworkGateways.update(resolvedGatewaySet)
+	changed = mgr.Unresolved.update(gatewaySet) || changed
+	changed = mgr.Unresolved.updateUnresolvable(unresolvable) || changed
+	changed = mgr.updateAddressTranslations() || changed
+	return changed
+}
+
+// updateAddressTranslations calls should with the lock held
+func (mgr *NetworkManager) updateAddressTranslations() bool {
+	var translations map[network.ID][]mesh.NetworkAddressTranslation
+	for nw, t := range mgr.env.NetworksWatcher.AddressTranslations() {
+		if translations == nil {
+			translations = map[network.ID][]mesh.NetworkAddressTranslation{}
+		}
+		translations[network.ID(nw)] = t
+	}
+	if reflect.DeepEqual(translations, mgr.addressTranslations) {
+		return false
+	}
+	mgr.addressTranslations = translations
+	return true
+}
 
-	return mgr.NetworkGateways.update(resolvedGatewaySet) || mgr.Unresolved.update(gatewaySet)
+// AddressTranslations returns the 1:1 NAT mappings of the addresses of the endpoints of the network, for the proxies
+// of other networks.
+func (mgr *NetworkManager) AddressTranslations(nw network.ID) []mesh.NetworkAddressTranslation {
+	if mgr == nil {
+		return nil
+	}
+	mgr.mu.RLock()
+	defer mgr.mu.RUnlock()
+	return mgr.addressTranslations[nw]
+}
+
+// updateUnresolvable calls should with the lock held
+func (gws *NetworkGateways) updateUnresolvable(unresolvable sets.String) bool {
+	unresolvableGateways.Record(float64(unresolvable.Len()))
+	if unresolvable.Equals(gws.unresolvable) {
+		return false
+	}
+	gws.unresolvable = unresolvable
+	return true
 }
 
 // update calls should with the lock held
@@ -208,8 +260,9 @@ func (gws *NetworkGateways) update(gatewaySet NetworkGatewaySet) bool {
 	return true
 }
 
-// resolveHostnameGateway either resolves or removes gateways that use a non-IP Address
-func (mgr *NetworkManager) resolveHostnameGateways(gatewaySet NetworkGatewaySet) NetworkGatewaySet {
+// resolveHostnameGateway either resolves or removes gateways that use a non-IP Address. The hostnames that could not
+// be resolved to any address are returned as well.
+func (mgr *NetworkManager) resolveHostnameGateways(gatewaySet NetworkGatewaySet) (NetworkGatewaySet, sets.String) {
 	resolvedGatewaySet := make(NetworkGatewaySet, len(gatewaySet))
 	// filter the list of gateways to resolve
 	hostnameGateways := map[string][]NetworkGateway{}
@@ -229,14 +282,16 @@ func (mgr *NetworkManager) resolveHostnameGateways(gatewaySet NetworkGatewaySet)
 		names.Insert(gw.Addr)
 	}
 
+	unresolvable := sets.New[string]()
 	if !features.ResolveHostnameGateways {
-		return resolvedGatewaySet
+		return resolvedGatewaySet, unresolvable
 	}
 	// resolve each hostname
 	for host, addrs := range mgr.NameCache.Resolve(names) {
 		gwsForHost := hostnameGateways[host]
 		if len(addrs) == 0 {
 			log.Warnf("could not resolve hostname %q for %d gateways", host, len(gwsForHost))
+			unresolvable.Insert(host)
 		}
 		// expand each resolved address into a NetworkGateway
 		for _, gw := range gwsForHost {
@@ -248,7 +303,7 @@ func (mgr *NetworkManager) resolveHostnameGateways(gatewaySet NetworkGatewaySet)
 			}
 		}
 	}
-	return resolvedGatewaySet
+	return resolvedGatewaySet, unresolvable
 }
 
 func (gws *NetworkGateways) IsMultiNetworkEnabled() bool {
@@ -267,6 +322,17 @@ func (gws *NetworkGateways) GetLBWeightScaleFactor() uint32 {
 	return gws.lcm
 }
 
+// IsUnresolvable returns true if the gateway has a hostname the control plane failed to resolve to any address.
+// Such gateways are only kept by the clusters resolving their endpoints with DNS, and marked unhealthy.
+func (gws *NetworkGateways) IsUnresolvable(gw NetworkGateway) bool {
+	if gws == nil || gws.mu == nil {
+		return false
+	}
+	gws.mu.RLock()
+	defer gws.mu.RUnlock()
+	return gws.unresolvable.Contains(gw.Addr)
+}
+
 func (gws *NetworkGateways) AllGateways() []NetworkGateway {
 	gws.mu.RLock()
 	defer gws.mu.RUnlock()
@@ -350,10 +416,19 @@ func lcm(x, y int) int {
 // NetworkGatewaySet is a helper to manage a set of NetworkGateway instances.
 type NetworkGatewaySet = sets.Set[NetworkGateway]
 
+var unresolvableGateways = monitoring.NewGauge(
+	"pilot_network_gateways_unresolvable",
+	"Number of network gateway hostnames the control plane failed to resolve to any address.",
+)
+
 var (
 	// MinGatewayTTL is exported for testing
 	MinGatewayTTL = 30 * time.Second
 
+	// GatewayResolveRetryDelay is the delay before retrying the resolution of a gateway hostname which failed, doubled
+	// on each consecutive failure up to MinGatewayTTL. Exported for testing.
+	GatewayResolveRetryDelay = 1 * time.Second
+
 	// https://github.com/coredns/coredns/blob/v1.10.1/plugin/pkg/dnsutil/ttl.go#L51
 	MaxGatewayTTL = 1 * time.Hour
 )
@@ -370,6 +445,8 @@ type nameCacheEntry struct {
 	value  []string
 	expiry time.Time
 	timer  *time.Timer
+	// failures is the number of consecutive resolutions which failed or returned no address.
+	failures int
 }
 
 func newNetworkGatewayNameCache() (*networkGatewayNameCache, error) {
@@ -431,6 +508,14 @@ func (n *networkGatewayNameCache) resolveAndCache(name string) []string {
 	if ttl < MinGatewayTTL {
 		ttl = MinGatewayTTL
 	}
+	failures := 0
+	if err != nil || len(addrs) == 0 {
+		// retry sooner than the TTL, backing off while the resolution keeps failing. Retries only trigger a push
+		// once the resolution changes.
+		failures = entry.failures + 1
+		ttl = gatewayResolveRetryDelay(failures)
+		log.Debugf("network gateways: resolution %d of %s failed, retrying in %v: %v", failures, name, ttl, err)
+	}
 	expiry := time.Now().Add(ttl)
 	if err != nil {
 		// gracefully retain old addresses in case the DNS server is unavailable
@@ -440,12 +525,25 @@ func (n *networkGatewayNameCache) resolveAndCache(name string) []string {
 		value:  addrs,
 		expiry: expiry,
 		// TTL expires, try to refresh TODO should this be < ttl?
-		timer: time.AfterFunc(ttl, n.refreshAndNotify(name)),
+		timer:    time.AfterFunc(ttl, n.refreshAndNotify(name)),
+		failures: failures,
 	}
 
 	return addrs
 }
 
+// gatewayResolveRetryDelay returns the delay before retrying a resolution which failed the given number of times.
+func gatewayResolveRetryDelay(failures int) time.Duration {
+	delay := GatewayResolveRetryDelay
+	for i := 1; i < failures && delay < MinGatewayTTL; i++ {
+		delay *= 2
+	}
+	if delay > MinGatewayTTL {
+		delay = MinGatewayTTL
+	}
+	return delay
+}
+
 // refreshAndNotify is triggered via time.AfterFunc and will recursively schedule itself that way until timer is cleaned
 // up via cleanupWatches.
 func (n *networkGatewayNameCache) refreshAndNotify(name string) func() {
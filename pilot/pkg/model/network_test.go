@@ -126,6 +126,9 @@ func TestGatewayHostnames(t *testing.T) {
 			if env.NetworkManager.IsMultiNetworkEnabled() {
 				t.Fatalf("multi network should not be enabled when there are no gateways")
 			}
+			if !env.NetworkManager.Unresolved.IsUnresolvable(model.NetworkGateway{Addr: gwHost}) {
+				t.Fatalf("expected the gateway hostname to be unresolvable")
+			}
 		})
 
 		workingDNSServer.setHosts(sets.New(gwHost))
@@ -135,6 +138,9 @@ func TestGatewayHostnames(t *testing.T) {
 					!reflect.DeepEqual(env.NetworkManager.AllGateways(), gateways)
 			}, retry.Timeout(2*model.MinGatewayTTL), retry.Delay(time.Millisecond*10))
 			xdsUpdater.WaitOrFail(t, "xds full")
+			if env.NetworkManager.Unresolved.IsUnresolvable(model.NetworkGateway{Addr: gwHost}) {
+				t.Fatalf("expected the gateway hostname to be resolvable")
+			}
 		})
 	}
 
@@ -73,6 +73,9 @@ type serviceIndex struct {
 	// to avoid recomputations during push. This caches instanceByPort calls with empty labels.
 	// Call InstancesByPort directly when instances need to be filtered by actual labels.
 	instancesByPort map[string]map[int][]*IstioEndpoint
+
+	// subsetEndpoints caches the instances by port filtered by the labels of subsets.
+	subsetEndpoints *subsetEndpointsCache
 }
 
 func newServiceIndex() serviceIndex {
@@ -82,6 +85,7 @@ func newServiceIndex() serviceIndex {
 		exportedToNamespace:  map[string][]*Service{},
 		HostnameAndNamespace: map[host.Name]map[string]*Service{},
 		instancesByPort:      map[string]map[int][]*IstioEndpoint{},
+		subsetEndpoints:      newSubsetEndpointsCache(),
 	}
 }
 
@@ -240,6 +244,10 @@ type PushContext struct {
 	// Telemetry stores the existing Telemetry resources for the cluster.
 	Telemetry *Telemetries `json:"-"`
 
+	// telemetryEndpointMetadataChanged is true if the endpoint metadata requested by the Telemetries changed since
+	// the previous push context.
+	telemetryEndpointMetadataChanged bool
+
 	// ProxyConfig stores the existing ProxyConfig resources for the cluster.
 	ProxyConfigs *ProxyConfigs `json:"-"`
 
@@ -270,6 +278,9 @@ type PushContext struct {
 	InitDone        atomic.Bool
 	initializeMutex sync.Mutex
 	ambientIndex    AmbientIndexes
+
+	// mtlsModes caches the PeerAuthentication mTLS mode of workloads.
+	mtlsModes *mtlsModeCache
 }
 
 type consolidatedDestRules struct {
@@ -289,6 +300,8 @@ type ConsolidatedDestRule struct {
 	rule *config.Config
 	// the original dest rules from which above rule is merged.
 	from []types.NamespacedName
+	// selectors are the workload selectors of the rules merged for a single proxy, if any.
+	selectors []string
 }
 
 // XDSUpdater is used for direct updates of the xDS model and incremental push.
@@ -353,6 +366,15 @@ type PushRequest struct {
 	// The kind of resources are defined in pkg/config/schemas.
 	ConfigsUpdated sets.Set[ConfigKey]
 
+	// EndpointNetworks holds, for services of ConfigsUpdated updated by endpoint updates, the networks of the
+	// endpoints that changed. The endpoints of the services of ConfigsUpdated missing from it may have changed on
+	// any network.
+	EndpointNetworks map[ConfigKey]sets.Set[network.ID]
+
+	// Immediate skips the debounce period, so that the push starts once the previous one completes. It is set by the
+	// endpoint updates of services with an immediate EndpointPushPolicy.
+	Immediate bool
+
 	// Push stores the push context to use for the update. This may initially be nil, as we will
 	// debounce changes before a PushContext is eventually created.
 	Push *PushContext
@@ -463,6 +485,8 @@ const (
 	NamespaceUpdate TriggerReason = "namespace"
 	// ClusterUpdate describes a push triggered by a Cluster change
 	ClusterUpdate TriggerReason = "cluster"
+	// RolloutUpdate describes a push triggered by a change of the rollout weights of the Deployments
+	RolloutUpdate TriggerReason = "rollout"
 )
 
 // Merge two update requests together
@@ -489,12 +513,15 @@ func (pr *PushRequest) Merge(other *PushRequest) *PushRequest {
 
 	// If either is full we need a full push
 	pr.Full = pr.Full || other.Full
+	pr.Immediate = pr.Immediate || other.Immediate
 
 	// The other push context is presumed to be later and more up to date
 	if other.Push != nil {
 		pr.Push = other.Push
 	}
 
+	pr.EndpointNetworks = mergeEndpointNetworks(pr, other)
+
 	// Do not merge when any one is empty
 	if len(pr.ConfigsUpdated) == 0 || len(other.ConfigsUpdated) == 0 {
 		pr.ConfigsUpdated = nil
@@ -543,11 +570,43 @@ func (pr *PushRequest) CopyMerge(other *PushRequest) *PushRequest {
 		merged.ConfigsUpdated = make(sets.Set[ConfigKey], len(pr.ConfigsUpdated)+len(other.ConfigsUpdated))
 		merged.ConfigsUpdated.Merge(pr.ConfigsUpdated)
 		merged.ConfigsUpdated.Merge(other.ConfigsUpdated)
+		merged.EndpointNetworks = mergeEndpointNetworks(pr, other)
 	}
 
 	return merged
 }
 
+// mergeEndpointNetworks returns the EndpointNetworks of two merged requests, without mutating them. The networks
+// of a service are only known if they are known in each of the requests updating it.
+func mergeEndpointNetworks(pr, other *PushRequest) map[ConfigKey]sets.Set[network.ID] {
+	if len(pr.ConfigsUpdated) == 0 || len(other.ConfigsUpdated) == 0 ||
+		(len(pr.EndpointNetworks) == 0 && len(other.EndpointNetworks) == 0) {
+		return nil
+	}
+	out := map[ConfigKey]sets.Set[network.ID]{}
+	unknown := sets.New[ConfigKey]()
+	for _, r := range []*PushRequest{pr, other} {
+		for key := range r.ConfigsUpdated {
+			networks, f := r.EndpointNetworks[key]
+			if !f {
+				unknown.Insert(key)
+				continue
+			}
+			if out[key] == nil {
+				out[key] = sets.New[network.ID]()
+			}
+			out[key].Merge(networks)
+		}
+	}
+	for key := range unknown {
+		delete(out, key)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
 func (pr *PushRequest) IsRequest() bool {
 	return len(pr.Reason) == 1 && pr.Reason.Has(ProxyRequest)
 }
@@ -649,6 +708,12 @@ var (
 		"Number of clusters without instances.",
 	)
 
+	// ProxyStatusClusterMtlsExcluded tracks SNI-DNAT clusters with endpoints excluded because they do not use mTLS.
+	ProxyStatusClusterMtlsExcluded = monitoring.NewGauge(
+		"pilot_eds_mtls_excluded",
+		"Number of SNI-DNAT clusters with endpoints excluded because they do not use mTLS.",
+	)
+
 	// DuplicatedDomains tracks rejected VirtualServices due to duplicated hostname.
 	DuplicatedDomains = monitoring.NewGauge(
 		"pilot_vservice_dup_domain",
@@ -683,6 +748,7 @@ var (
 		ProxyStatusConflictInboundListener,
 		DuplicatedClusters,
 		ProxyStatusClusterNoInstances,
+		ProxyStatusClusterMtlsExcluded,
 		DuplicatedDomains,
 		DuplicatedSubsets,
 	}
@@ -699,6 +765,7 @@ func NewPushContext() *PushContext {
 		gatewayIndex:            newGatewayIndex(),
 		ProxyStatus:             map[string]map[string]ProxyPushStatus{},
 		serviceAccounts:         map[serviceAccountKey][]string{},
+		mtlsModes:               newMtlsModeCache(),
 	}
 }
 
@@ -716,6 +783,7 @@ func (ps *PushContext) AddServiceInstances(service *Service, instances map[int][
 		}
 		ps.ServiceIndex.instancesByPort[svcKey][port] = append(ps.ServiceIndex.instancesByPort[svcKey][port], inst...)
 	}
+	ps.ServiceIndex.subsetEndpoints.clear(svcKey)
 }
 
 // StatusJSON implements json.Marshaller, with a lock.
@@ -1203,6 +1271,20 @@ func (ps *PushContext) IsClusterLocal(service *Service) bool {
 	return ps.clusterLocalHosts.IsClusterLocal(service.Hostname)
 }
 
+// ClusterLocalHosts returns the cluster-local hosts of the meshConfig of the push context.
+func (ps *PushContext) ClusterLocalHosts() ClusterLocalHosts {
+	return ps.clusterLocalHosts
+}
+
+// EndpointMeshSettings returns the meshConfig settings of the push context changing the endpoints.
+func (ps *PushContext) EndpointMeshSettings() *EndpointMeshSettings {
+	return &EndpointMeshSettings{
+		LocalityLbSetting: ps.Mesh.GetLocalityLbSetting(),
+		ClusterLocalHosts: ps.clusterLocalHosts,
+		Version:           ps.PushVersion,
+	}
+}
+
 // InitContext will initialize the data structures used for code generation.
 // This should be called before starting the push, from the thread creating
 // the push context.
@@ -1356,6 +1438,7 @@ func (ps *PushContext) updateContext(
 
 	if telemetryChanged {
 		ps.initTelemetry(env)
+		ps.telemetryEndpointMetadataChanged = ps.Telemetry.EndpointMetadataChanged(oldPushContext.Telemetry)
 	} else {
 		ps.Telemetry = oldPushContext.Telemetry
 	}
@@ -1405,6 +1488,9 @@ func (ps *PushContext) updateContext(
 func (ps *PushContext) initServiceRegistry(env *Environment) {
 	// Sort the services in order of creation.
 	allServices := SortServicesByCreationTime(env.Services())
+	if features.EnableClusterSetServiceResolution {
+		allServices = append(allServices, clusterSetServices(env, allServices)...)
+	}
 	for _, s := range allServices {
 		portMap := map[string]int{}
 		for _, port := range s.Ports {
@@ -1822,6 +1908,12 @@ func (ps *PushContext) initTelemetry(env *Environment) {
 	ps.Telemetry = getTelemetries(env)
 }
 
+// TelemetryEndpointMetadataChanged returns true if the endpoint metadata requested by the Telemetries changed since
+// the previous push context, in which case the endpoints must be rebuilt.
+func (ps *PushContext) TelemetryEndpointMetadataChanged() bool {
+	return ps.telemetryEndpointMetadataChanged
+}
+
 func (ps *PushContext) initProxyConfigs(env *Environment) {
 	ps.ProxyConfigs = GetProxyConfigs(env.ConfigStore, env.Mesh())
 }
@@ -2130,23 +2222,40 @@ func (ps *PushContext) BestEffortInferServiceMTLSMode(tp *networking.TrafficPoli
 }
 
 // ServiceEndpointsByPort returns the cached instances by port if it exists.
+// The instances filtered by labels are cached as well, per subset.
 func (ps *PushContext) ServiceEndpointsByPort(svc *Service, port int, labels labels.Instance) []*IstioEndpoint {
-	var out []*IstioEndpoint
-	if instances, exists := ps.ServiceIndex.instancesByPort[svc.Key()][port]; exists {
-		// Use cached version of instances by port when labels are empty.
-		if len(labels) == 0 {
-			return instances
-		}
-		// If there are labels,	we will filter instances by pod labels.
-		for _, instance := range instances {
-			// check that one of the input labels is a subset of the labels
-			if labels.SubsetOf(instance.Labels) {
-				out = append(out, instance)
-			}
-		}
+	svcKey := svc.Key()
+	instances, exists := ps.ServiceIndex.instancesByPort[svcKey][port]
+	if !exists {
+		return nil
+	}
+	// Use cached version of instances by port when labels are empty.
+	if len(labels) == 0 {
+		return instances
 	}
+	// If there are labels, we will filter instances by pod labels.
+	return ps.ServiceIndex.subsetEndpoints.get(svcKey, port, labels, instances)
+}
 
-	return out
+// DirectPodAddresses returns the sorted addresses of the endpoints of a service port reached through direct pod
+// clusters, if the service has the networking.istio.io/direct-pod-clusters annotation. As each address gets a
+// cluster and a virtual host, none is returned beyond features.MaxDirectPodClusters addresses.
+func (ps *PushContext) DirectPodAddresses(svc *Service, port int) []string {
+	if !svc.Attributes.DirectPodClusters {
+		return nil
+	}
+	addresses := sets.New[string]()
+	for _, ep := range ps.ServiceEndpointsByPort(svc, port, nil) {
+		if ep.Address != "" {
+			addresses.Insert(ep.Address)
+		}
+	}
+	if addresses.Len() > features.MaxDirectPodClusters {
+		log.Debugf("service %s port %d has %d endpoints, more than the %d direct pod clusters allowed",
+			svc.Hostname, port, addresses.Len(), features.MaxDirectPodClusters)
+		return nil
+	}
+	return sets.SortedList(addresses)
 }
 
 // ServiceEndpoints returns the cached instances by svc if exists.
@@ -2206,8 +2315,14 @@ func (ps *PushContext) SupportsTunnel(n network.ID, ip string) bool {
 	return false
 }
 
-func (ps *PushContext) WaypointsFor(scope WaypointScope) []netip.Addr {
-	return ps.ambientIndex.Waypoint(scope)
+// WaypointsFor returns the addresses of the waypoints on the network serving the scope.
+func (ps *PushContext) WaypointsFor(n network.ID, scope WaypointScope) []netip.Addr {
+	return ps.ambientIndex.Waypoint(n, scope)
+}
+
+// WaypointScopeFor returns the scope of the waypoint owning the workload, if any.
+func (ps *PushContext) WaypointScopeFor(namespace, serviceAccount string, workloadLabels labels.Instance) (WaypointScope, bool) {
+	return ps.ambientIndex.WaypointScopeFor(namespace, serviceAccount, workloadLabels)
 }
 
 // WorkloadsForWaypoint returns all workloads associated with a given WaypointScope
@@ -49,10 +49,12 @@ import (
 	"istio.io/istio/pkg/config/schema/kind"
 	"istio.io/istio/pkg/config/visibility"
 	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/network"
 	"istio.io/istio/pkg/slices"
 	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/test/util/assert"
 	"istio.io/istio/pkg/util/sets"
+	"istio.io/istio/pkg/workloadapi"
 )
 
 func TestMergeUpdateRequest(t *testing.T) {
@@ -136,6 +138,28 @@ func TestMergeUpdateRequest(t *testing.T) {
 	}
 }
 
+func TestMergeEndpointNetworks(t *testing.T) {
+	a := ConfigKey{Kind: kind.ServiceEntry, Name: "a.com", Namespace: "ns"}
+	b := ConfigKey{Kind: kind.ServiceEntry, Name: "b.com", Namespace: "ns"}
+	c := ConfigKey{Kind: kind.ServiceEntry, Name: "c.com", Namespace: "ns"}
+	left := &PushRequest{
+		ConfigsUpdated:   sets.New(a, b),
+		EndpointNetworks: map[ConfigKey]sets.Set[network.ID]{a: sets.New[network.ID]("n1"), b: sets.New[network.ID]("n1")},
+	}
+	right := &PushRequest{
+		ConfigsUpdated:   sets.New(a, b, c),
+		EndpointNetworks: map[ConfigKey]sets.Set[network.ID]{a: sets.New[network.ID]("n2"), c: sets.New[network.ID]("n3")},
+	}
+	// The networks of b are unknown in the right request.
+	assert.Equal(t, left.CopyMerge(right).EndpointNetworks, map[ConfigKey]sets.Set[network.ID]{
+		a: sets.New[network.ID]("n1", "n2"),
+		c: sets.New[network.ID]("n3"),
+	})
+	assert.Equal(t, left.EndpointNetworks[a], sets.New[network.ID]("n1"))
+	// Requests updating everything may update the endpoints on any network.
+	assert.Equal(t, left.CopyMerge(&PushRequest{}).EndpointNetworks, nil)
+}
+
 func TestConcurrentMerge(t *testing.T) {
 	reqA := &PushRequest{Reason: make(ReasonStats)}
 	reqB := &PushRequest{Reason: NewReasonStats(ServiceUpdate, ProxyUpdate)}
@@ -1482,7 +1506,8 @@ func TestInitPushContext(t *testing.T) {
 			AuthenticationPolicies{}, NetworkManager{}, sidecarIndex{}, Telemetries{}, ProxyConfigs{}, ConsolidatedDestRule{},
 			ClusterLocalHosts{}),
 		// These are not feasible/worth comparing
-		cmpopts.IgnoreTypes(sync.RWMutex{}, localServiceDiscovery{}, FakeStore{}, atomic.Bool{}, sync.Mutex{}),
+		cmpopts.IgnoreTypes(sync.RWMutex{}, localServiceDiscovery{}, FakeStore{}, atomic.Bool{}, sync.Mutex{}, mtlsModeCache{},
+			subsetEndpointsCache{}),
 		cmpopts.IgnoreUnexported(IstioEndpoint{}),
 		cmpopts.IgnoreInterfaces(struct{ mesh.Holder }{}),
 		protocmp.Transform(),
@@ -1492,6 +1517,53 @@ func TestInitPushContext(t *testing.T) {
 	}
 }
 
+func TestServiceEndpointsByPortSubsets(t *testing.T) {
+	svc := &Service{Hostname: "svc.default.svc.cluster.local", Attributes: ServiceAttributes{Namespace: "default"}}
+	v1 := &IstioEndpoint{Address: "10.0.0.1", Labels: labels.Instance{"app": "svc", "version": "v1"}}
+	v2 := &IstioEndpoint{Address: "10.0.0.2", Labels: labels.Instance{"app": "svc", "version": "v2"}}
+	ps := NewPushContext()
+	ps.AddServiceInstances(svc, map[int][]*IstioEndpoint{80: {v1, v2}})
+
+	assert.Equal(t, ps.ServiceEndpointsByPort(svc, 80, nil), []*IstioEndpoint{v1, v2})
+	assert.Equal(t, ps.ServiceEndpointsByPort(svc, 80, labels.Instance{"version": "v1"}), []*IstioEndpoint{v1})
+	assert.Equal(t, ps.ServiceEndpointsByPort(svc, 80, labels.Instance{"version": "v3"}), nil)
+	assert.Equal(t, ps.ServiceEndpointsByPort(svc, 81, labels.Instance{"version": "v1"}), nil)
+	assert.Equal(t, len(ps.ServiceIndex.subsetEndpoints.endpoints), 2)
+
+	// Cached subsets are dropped when the instances of the service change.
+	v1b := &IstioEndpoint{Address: "10.0.0.3", Labels: labels.Instance{"app": "svc", "version": "v1"}}
+	ps.AddServiceInstances(svc, map[int][]*IstioEndpoint{80: {v1b}})
+	assert.Equal(t, ps.ServiceEndpointsByPort(svc, 80, labels.Instance{"version": "v1"}), []*IstioEndpoint{v1, v1b})
+}
+
+// BenchmarkServiceEndpointsByPortSubsets looks up the endpoints of 20 subsets of a service with 5000 endpoints,
+// as done when building the clusters of the subsets.
+func BenchmarkServiceEndpointsByPortSubsets(b *testing.B) {
+	const subsets, endpoints = 20, 5000
+	svc := &Service{Hostname: "svc.default.svc.cluster.local", Attributes: ServiceAttributes{Namespace: "default"}}
+	instances := make([]*IstioEndpoint, 0, endpoints)
+	for i := 0; i < endpoints; i++ {
+		instances = append(instances, &IstioEndpoint{
+			Address: fmt.Sprintf("10.0.%d.%d", i/256, i%256),
+			Labels:  labels.Instance{"app": "svc", "version": fmt.Sprintf("v%d", i%subsets)},
+		})
+	}
+	subsetLabels := make([]labels.Instance, 0, subsets)
+	for i := 0; i < subsets; i++ {
+		subsetLabels = append(subsetLabels, labels.Instance{"version": fmt.Sprintf("v%d", i)})
+	}
+	ps := NewPushContext()
+	ps.AddServiceInstances(svc, map[int][]*IstioEndpoint{80: instances})
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, l := range subsetLabels {
+			if got := ps.ServiceEndpointsByPort(svc, 80, l); len(got) != endpoints/subsets {
+				b.Fatalf("expected %d endpoints, got %d", endpoints/subsets, len(got))
+			}
+		}
+	}
+}
+
 func TestSidecarScope(t *testing.T) {
 	ps := NewPushContext()
 	env := &Environment{Watcher: mesh.NewFixedWatcher(&meshconfig.MeshConfig{RootNamespace: "istio-system"})}
@@ -1975,6 +2047,67 @@ func TestSetDestinationRuleWithWorkloadSelector(t *testing.T) {
 	}
 }
 
+func TestSidecarScopeDestinationRuleMultipleSelectors(t *testing.T) {
+	ps := NewPushContext()
+	ps.Mesh = &meshconfig.MeshConfig{RootNamespace: "istio-system"}
+	testhost := "httpbin.org"
+	selectorRule := func(name string, selector map[string]string, subset string, policy *networking.TrafficPolicy) config.Config {
+		return config.Config{
+			Meta: config.Meta{Name: name, Namespace: "test"},
+			Spec: &networking.DestinationRule{
+				Host:             testhost,
+				WorkloadSelector: &selectorpb.WorkloadSelector{MatchLabels: selector},
+				TrafficPolicy:    policy,
+				Subsets:          []*networking.Subset{{Name: subset, Labels: map[string]string{"version": subset}}},
+			},
+		}
+	}
+	roundRobin := &networking.TrafficPolicy{LoadBalancer: &networking.LoadBalancerSettings{
+		LbPolicy: &networking.LoadBalancerSettings_Simple{Simple: networking.LoadBalancerSettings_ROUND_ROBIN},
+	}}
+	ps.setDestinationRules([]config.Config{
+		selectorRule("by-app", map[string]string{"app": "a"}, "v1", roundRobin),
+		selectorRule("by-tier", map[string]string{"tier": "front"}, "v2", nil),
+		{
+			Meta: config.Meta{Name: "catch-all", Namespace: "test"},
+			Spec: &networking.DestinationRule{Host: testhost},
+		},
+	})
+	svc := &Service{Hostname: host.Name(testhost), Attributes: ServiceAttributes{Namespace: "test"}}
+	sc := &SidecarScope{
+		Namespace:        "test",
+		destinationRules: map[host.Name][]*ConsolidatedDestRule{svc.Hostname: ps.destinationRule("test", svc)},
+	}
+	sc.precomputeSelectedDestinationRules()
+	get := func(l map[string]string) *ConsolidatedDestRule {
+		return sc.DestinationRule(TrafficDirectionOutbound, &Proxy{Labels: l}, svc.Hostname)
+	}
+
+	both := get(map[string]string{"app": "a", "tier": "front"})
+	assert.Equal(t, both.GetFrom(), []types.NamespacedName{
+		{Name: "by-app", Namespace: "test"},
+		{Name: "catch-all", Namespace: "test"},
+		{Name: "by-tier", Namespace: "test"},
+	})
+	assert.Equal(t, both.SelectorFingerprints(), []string{"app=a", "tier=front"})
+	rule := both.GetRule().Spec.(*networking.DestinationRule)
+	assert.Equal(t, len(rule.Subsets), 2)
+	assert.Equal(t, rule.TrafficPolicy, roundRobin)
+	// The rules are merged once, when the scope is built.
+	assert.Equal(t, get(map[string]string{"app": "a", "tier": "front"}) == both, true)
+
+	single := get(map[string]string{"tier": "front"})
+	assert.Equal(t, single.GetFrom(), []types.NamespacedName{{Name: "by-tier", Namespace: "test"}, {Name: "catch-all", Namespace: "test"}})
+	assert.Equal(t, single.SelectorFingerprints(), []string{"tier=front"})
+
+	none := get(map[string]string{"app": "b"})
+	assert.Equal(t, none.GetFrom(), []types.NamespacedName{{Name: "catch-all", Namespace: "test"}})
+	assert.Equal(t, len(none.SelectorFingerprints()), 0)
+
+	// The merged rule must not alter the rules it was merged from.
+	assert.Equal(t, len(get(map[string]string{"app": "a"}).GetRule().Spec.(*networking.DestinationRule).Subsets), 1)
+}
+
 func TestSetDestinationRuleMerging(t *testing.T) {
 	ps := NewPushContext()
 	ps.exportToDefaults.destinationRule = sets.New(visibility.Public)
@@ -2943,3 +3076,97 @@ func (l *localServiceDiscovery) NetworkGateways() []NetworkGateway {
 func (l *localServiceDiscovery) MCSServices() []MCSServiceInfo {
 	return nil
 }
+
+// networkAmbientIndex serves the HBONE workloads of each network, which may use the same IPs.
+type networkAmbientIndex struct {
+	NoopAmbientIndexes
+	hbone sets.String
+}
+
+func (n networkAmbientIndex) AddressInformation(keys sets.String) ([]*AddressInfo, []string) {
+	var out []*AddressInfo
+	for k := range keys {
+		if n.hbone.Contains(k) {
+			out = append(out, &AddressInfo{Address: &workloadapi.Address{Type: &workloadapi.Address_Workload{
+				Workload: &workloadapi.Workload{TunnelProtocol: workloadapi.TunnelProtocol_HBONE},
+			}}})
+		}
+	}
+	return out, nil
+}
+
+func TestSupportsTunnelOverlappingNetworks(t *testing.T) {
+	ps := NewPushContext()
+	// 10.0.0.1 is an HBONE workload on nw1, and a workload without HBONE support on nw2.
+	ps.ambientIndex = networkAmbientIndex{hbone: sets.New("nw1/10.0.0.1")}
+	if !ps.SupportsTunnel("nw1", "10.0.0.1") {
+		t.Fatalf("expected 10.0.0.1 on nw1 to support tunneling")
+	}
+	if ps.SupportsTunnel("nw2", "10.0.0.1") {
+		t.Fatalf("expected 10.0.0.1 on nw2 not to support tunneling")
+	}
+}
+
+func TestMutualTLSModeForService(t *testing.T) {
+	ps := NewPushContext()
+	computed := 0
+	compute := func(mode MutualTLSMode) func() MutualTLSMode {
+		return func() MutualTLSMode {
+			computed++
+			return mode
+		}
+	}
+	svc := host.Name("a.ns.svc.cluster.local")
+	lbls := labels.Instance{"app": "a", "version": "v1"}
+	if got := ps.MutualTLSModeForService(svc, "ns", 8080, lbls, compute(MTLSStrict)); got != MTLSStrict {
+		t.Fatalf("expected %v, got %v", MTLSStrict, got)
+	}
+	// Without workload PeerAuthentications, the endpoints of the service share the mode.
+	if got := ps.MutualTLSModeForService(svc, "ns", 8080, labels.Instance{"app": "a"}, compute(MTLSDisable)); got != MTLSStrict {
+		t.Fatalf("expected cached %v, got %v", MTLSStrict, got)
+	}
+	if computed != 1 {
+		t.Fatalf("expected 1 computation, got %d", computed)
+	}
+	// Different service, port or namespace are computed separately.
+	ps.MutualTLSModeForService("b.ns.svc.cluster.local", "ns", 8080, lbls, compute(MTLSDisable))
+	ps.MutualTLSModeForService(svc, "ns", 9090, lbls, compute(MTLSDisable))
+	ps.MutualTLSModeForService(svc, "other", 8080, lbls, compute(MTLSDisable))
+	if computed != 4 {
+		t.Fatalf("expected 4 computations, got %d", computed)
+	}
+
+	// With a PeerAuthentication selecting workloads of the namespace, the mode is cached per workload labels.
+	ps = NewPushContext()
+	ps.AuthnPolicies = &AuthenticationPolicies{
+		peerAuthentications: map[string][]config.Config{
+			"ns": {{
+				Meta: config.Meta{GroupVersionKind: gvk.PeerAuthentication, Name: "v1", Namespace: "ns"},
+				Spec: &securityBeta.PeerAuthentication{Selector: &selectorpb.WorkloadSelector{MatchLabels: map[string]string{"version": "v1"}}},
+			}},
+		},
+		rootNamespace: "istio-system",
+	}
+	computed = 0
+	ps.MutualTLSModeForService(svc, "ns", 8080, lbls, compute(MTLSStrict))
+	if got := ps.MutualTLSModeForService(svc, "ns", 8080, labels.Instance{"version": "v1", "app": "a"}, compute(MTLSDisable)); got != MTLSStrict {
+		t.Fatalf("expected cached %v, got %v", MTLSStrict, got)
+	}
+	if got := ps.MutualTLSModeForService(svc, "ns", 8080, labels.Instance{"app": "a"}, compute(MTLSDisable)); got != MTLSDisable {
+		t.Fatalf("expected %v, got %v", MTLSDisable, got)
+	}
+	if computed != 2 {
+		t.Fatalf("expected 2 computations, got %d", computed)
+	}
+	// The other namespaces still share the mode.
+	ps.MutualTLSModeForService("c.other.svc.cluster.local", "other", 8080, lbls, compute(MTLSStrict))
+	ps.MutualTLSModeForService("c.other.svc.cluster.local", "other", 8080, labels.Instance{"app": "a"}, compute(MTLSDisable))
+	if computed != 3 {
+		t.Fatalf("expected 3 computations, got %d", computed)
+	}
+
+	// A new push context, as created on PeerAuthentication changes, starts empty.
+	if got := NewPushContext().MutualTLSModeForService(svc, "ns", 8080, lbls, compute(MTLSPermissive)); got != MTLSPermissive {
+		t.Fatalf("expected %v, got %v", MTLSPermissive, got)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"bytes"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	"istio.io/istio/pkg/util/hash"
+)
+
+// resourceStore is a content-addressed store of xDS resources. Resources with the same name and
+// byte-identical content share a single copy, which is reference counted by its holders.
+// It is not thread safe; the xds cache calls it under its lock.
+type resourceStore struct {
+	entries map[uint64]*storedResource
+}
+
+type storedResource struct {
+	value *discovery.Resource
+	refs  int
+}
+
+func newResourceStore() *resourceStore {
+	return &resourceStore{entries: map[uint64]*storedResource{}}
+}
+
+func resourceHash(r *discovery.Resource) uint64 {
+	h := hash.New()
+	h.Write([]byte(r.Name))
+	h.Write([]byte{0})
+	h.Write([]byte(r.GetResource().GetTypeUrl()))
+	h.Write([]byte{0})
+	h.Write(r.GetResource().GetValue())
+	return h.Sum64()
+}
+
+// intern returns the stored copy of the resource, storing it if there is none, and takes a reference on it.
+// The reference must be given back with release.
+func (s *resourceStore) intern(r *discovery.Resource) *discovery.Resource {
+	if s == nil || r == nil {
+		return r
+	}
+	key := resourceHash(r)
+	e, f := s.entries[key]
+	if !f {
+		s.entries[key] = &storedResource{value: r, refs: 1}
+		return r
+	}
+	if e.value == r {
+		e.refs++
+		return r
+	}
+	stored := e.value
+	if stored.Name != r.Name || stored.GetResource().GetTypeUrl() != r.GetResource().GetTypeUrl() ||
+		!bytes.Equal(stored.GetResource().GetValue(), r.GetResource().GetValue()) {
+		// Hash collision, the resource is not deduplicated.
+		return r
+	}
+	e.refs++
+	xdsCacheDeduplicated.Increment()
+	return stored
+}
+
+// release gives back a reference taken by intern. The stored copy is dropped with its last reference.
+func (s *resourceStore) release(r *discovery.Resource) {
+	if s == nil || r == nil {
+		return
+	}
+	key := resourceHash(r)
+	e, f := s.entries[key]
+	if !f || e.value != r {
+		// Not interned, because of a hash collision.
+		return
+	}
+	e.refs--
+	if e.refs <= 0 {
+		delete(s.entries, key)
+	}
+}
+
+// reset drops all the stored resources.
+func (s *resourceStore) reset() {
+	if s == nil {
+		return
+	}
+	s.entries = map[uint64]*storedResource{}
+}
+
+// len returns the number of distinct stored resources.
+func (s *resourceStore) len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.entries)
+}
@@ -0,0 +1,107 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"strconv"
+	"sync"
+
+	"istio.io/istio/pkg/cluster"
+)
+
+// RolloutWeights indexes the percentage of the traffic of their services sent to the workloads being rolled out,
+// as set by the networking.istio.io/rollout-weight annotation of their Deployment. It is shared by the registries
+// of all the clusters. All operations are thread safe.
+type RolloutWeights struct {
+	mu      sync.RWMutex
+	weights map[rolloutKey]uint32
+	version uint64
+}
+
+type rolloutKey struct {
+	cluster   cluster.ID
+	namespace string
+	workload  string
+}
+
+func NewRolloutWeights() *RolloutWeights {
+	return &RolloutWeights{weights: map[rolloutKey]uint32{}}
+}
+
+// Set sets the percentage of the workload, or removes it if ok is false. It returns true if the weights changed.
+func (r *RolloutWeights) Set(clusterID cluster.ID, namespace, workload string, percentage uint32, ok bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := rolloutKey{cluster: clusterID, namespace: namespace, workload: workload}
+	cur, f := r.weights[key]
+	if ok == f && cur == percentage {
+		return false
+	}
+	if ok {
+		r.weights[key] = percentage
+	} else {
+		delete(r.weights, key)
+	}
+	r.version++
+	return true
+}
+
+// DeleteCluster removes the workloads of the cluster. It returns true if the weights changed.
+func (r *RolloutWeights) DeleteCluster(clusterID cluster.ID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := false
+	for key := range r.weights {
+		if key.cluster == clusterID {
+			delete(r.weights, key)
+			changed = true
+		}
+	}
+	if changed {
+		r.version++
+	}
+	return changed
+}
+
+// Weight returns the percentage of the traffic sent to the workload of the endpoint, if it is being rolled out.
+func (r *RolloutWeights) Weight(e *IstioEndpoint) (uint32, bool) {
+	if r == nil || e.WorkloadName == "" {
+		return 0, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	w, f := r.weights[rolloutKey{cluster: e.Locality.ClusterID, namespace: e.Namespace, workload: e.WorkloadName}]
+	return w, f
+}
+
+// Empty returns true if no workload is being rolled out.
+func (r *RolloutWeights) Empty() bool {
+	if r == nil {
+		return true
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.weights) == 0
+}
+
+// Version changes whenever the weights change. It is part of the cache key of the endpoints.
+func (r *RolloutWeights) Version() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return strconv.FormatUint(r.version, 10)
+}
@@ -29,6 +29,7 @@ import (
 	"strings"
 	"sync/atomic"
 	"time"
+	"unsafe"
 
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	"github.com/google/go-cmp/cmp"
@@ -196,6 +197,12 @@ const (
 	// TLSModeLabelShortname name used for determining endpoint level tls transport socket configuration
 	TLSModeLabelShortname = "tlsMode"
 
+	// ProxyProtocolShortname name used for determining the endpoints expecting the PROXY protocol
+	ProxyProtocolShortname = "proxyProtocol"
+
+	// SNIClusterShortname name used for determining the network gateway endpoints whose cluster overrides the SNI
+	SNIClusterShortname = "sniCluster"
+
 	// DisabledTLSModeLabel implies that this endpoint should receive traffic as is (mostly plaintext)
 	DisabledTLSModeLabel = "disabled"
 
@@ -355,6 +362,8 @@ type WorkloadInstance struct {
 	Kind     workloadKind      `json:"kind"`
 	Endpoint *IstioEndpoint    `json:"endpoint,omitempty"`
 	PortMap  map[string]uint32 `json:"portMap,omitempty"`
+	// PortLabels are additional labels of the endpoints of some ports, by port name.
+	PortLabels map[string]labels.Instance `json:"portLabels,omitempty"`
 	// Can only be selected by service entry of DNS type.
 	DNSServiceEntryOnly bool `json:"dnsServiceEntryOnly,omitempty"`
 }
@@ -369,13 +378,31 @@ func (instance *WorkloadInstance) DeepCopy() *WorkloadInstance {
 	for k, v := range instance.PortMap {
 		pmap[k] = v
 	}
+	var plabels map[string]labels.Instance
+	if instance.PortLabels != nil {
+		plabels = make(map[string]labels.Instance, len(instance.PortLabels))
+		for k, v := range instance.PortLabels {
+			plabels[k] = maps.Clone(v)
+		}
+	}
 	return &WorkloadInstance{
-		Name:      instance.Name,
-		Namespace: instance.Namespace,
-		Kind:      instance.Kind,
-		PortMap:   pmap,
-		Endpoint:  instance.Endpoint.DeepCopy(),
+		Name:       instance.Name,
+		Namespace:  instance.Namespace,
+		Kind:       instance.Kind,
+		PortMap:    pmap,
+		PortLabels: plabels,
+		Endpoint:   instance.Endpoint.DeepCopy(),
+	}
+}
+
+// EndpointLabels returns the labels of the endpoints of the named port of the workload instance, that is the labels
+// of the workload instance along with the labels declared for the port, if any.
+func (instance *WorkloadInstance) EndpointLabels(portName string) labels.Instance {
+	portLabels := instance.PortLabels[portName]
+	if len(portLabels) == 0 {
+		return instance.Endpoint.Labels
 	}
+	return maps.MergeCopy(instance.Endpoint.Labels, portLabels)
 }
 
 // WorkloadInstancesEqual is a custom comparison of workload instances based on the fields that we need.
@@ -405,6 +432,9 @@ func WorkloadInstancesEqual(first, second *WorkloadInstance) bool {
 	if first.Endpoint.GetLoadBalancingWeight() != second.Endpoint.GetLoadBalancingWeight() {
 		return false
 	}
+	if !first.Endpoint.SocketOptions.Equals(second.Endpoint.SocketOptions) {
+		return false
+	}
 	if first.Namespace != second.Namespace {
 		return false
 	}
@@ -417,6 +447,14 @@ func WorkloadInstancesEqual(first, second *WorkloadInstance) bool {
 	if !maps.Equal(first.PortMap, second.PortMap) {
 		return false
 	}
+	if len(first.PortLabels) != len(second.PortLabels) {
+		return false
+	}
+	for port, l := range first.PortLabels {
+		if !l.Equals(second.PortLabels[port]) {
+			return false
+		}
+	}
 	return true
 }
 
@@ -498,6 +536,11 @@ type IstioEndpoint struct {
 	// from the service port.
 	EndpointPort uint32
 
+	// AdditionalPorts maps the names of the other service ports served by the endpoint to their endpoint port, so
+	// that a single endpoint stands for the workload on all the ports of a multi-port service. ServicePortName and
+	// EndpointPort are the primary port of the endpoint. Use ForPort to get the endpoint of a given port.
+	AdditionalPorts map[string]uint32
+
 	// The load balancing weight associated with this endpoint.
 	LbWeight uint32
 
@@ -510,6 +553,10 @@ type IstioEndpoint struct {
 	// Name of the workload that this endpoint belongs to. This is for telemetry purpose.
 	WorkloadName string
 
+	// WorkloadKind is the kind of the workload that this endpoint belongs to, such as Deployment or StatefulSet,
+	// if known.
+	WorkloadKind string
+
 	// Specifies the hostname of the Pod, empty for vm workload.
 	HostName string
 
@@ -525,9 +572,65 @@ type IstioEndpoint struct {
 	// If in k8s, the node where the pod resides
 	NodeName string
 
+	// DataResidency is the jurisdiction the data of the endpoint must stay in, from the
+	// constants.DataResidencyLabel of its workload or node. features.DataResidencyPolicy restricts the
+	// proxies it is sent to.
+	DataResidency string
+
+	// ZoneHints are the zones the Kubernetes EndpointSlice controller allocated the endpoint to, for the
+	// services with topology aware routing. It is empty if the endpoint has no hints.
+	ZoneHints []string
+
+	// InstanceName identifies the workload instance of the endpoint, such as its pod or WorkloadEntry, across
+	// changes of its address. It is empty if unknown.
+	InstanceName string
+
+	// PreviousAddress is the address the workload instance had before it was re-addressed. It is set by the
+	// EndpointIndex, until the next update of the shard of the endpoint.
+	PreviousAddress string
+
+	// MigratedTo is the new address of a re-addressed workload instance. It is set by the EndpointIndex on the
+	// draining endpoint of the previous address, kept until the next update of the shard of the endpoint.
+	MigratedTo string
+
+	// WorkloadAddress is the address of the workload instance when the endpoint is reached through another address,
+	// such as the address of the node of a pod. It is empty otherwise.
+	WorkloadAddress string
+
+	// Annotations holds the annotations of the workload mapped to endpoint metadata by
+	// features.EndpointMetadataAnnotations.
+	Annotations map[string]string
+
+	// TTL, if set, is how long the endpoint remains valid without being refreshed by a new endpoint update.
+	// Once expired the endpoint is marked unhealthy, and it is removed after another TTL period.
+	// This is intended for endpoints pushed by external registries, which may stop sending updates.
+	TTL time.Duration
+
+	// Completed is true for the endpoints of pods which completed, kept unhealthy as set by
+	// features.CompletedPodEndpoints.
+	Completed bool
+
+	// CapacityRPS is the requests per second budget of the workload, from its constants.CapacityRPSAnnotation. It is
+	// zero if unknown.
+	CapacityRPS uint32
+
+	// SocketOptions are the socket options of the upstream connections to the endpoint, if any.
+	SocketOptions *SocketOptions
+
+	// FirstSeen is when the endpoint was first added to its shard, set by the EndpointIndex when
+	// features.EnableScaleUpRamp is enabled. It is zero otherwise.
+	FirstSeen time.Time
+
+	// DrainingSince is when the endpoint started draining, set by the EndpointIndex on Draining endpoints when
+	// features.PersistentSessionDrainTTL is set. It is zero otherwise.
+	DrainingSince time.Time
+
 	// precomputedEnvoyEndpoint is a cached LbEndpoint, converted from the data, to
 	// avoid recomputation
 	precomputedEnvoyEndpoint atomic.Pointer[endpoint.LbEndpoint]
+
+	// portEndpoints holds the endpoints of the AdditionalPorts, built once by PrecomputePorts.
+	portEndpoints map[string]*IstioEndpoint
 }
 
 func (ep *IstioEndpoint) EnvoyEndpoint() *endpoint.LbEndpoint {
@@ -538,6 +641,30 @@ func (ep *IstioEndpoint) ComputeEnvoyEndpoint(now *endpoint.LbEndpoint) {
 	ep.precomputedEnvoyEndpoint.Store(now)
 }
 
+// sizeEstimate returns an estimate of the memory used by the endpoint, including its precomputed Envoy endpoint.
+func (ep *IstioEndpoint) sizeEstimate() int {
+	size := int(unsafe.Sizeof(*ep)) + len(ep.Address) + len(ep.ServicePortName) + len(ep.ServiceAccount) +
+		len(ep.Network) + len(ep.Locality.Label) + len(ep.Locality.ClusterID) + len(ep.TLSMode) + len(ep.Namespace) +
+		len(ep.WorkloadName) + len(ep.WorkloadKind) + len(ep.HostName) + len(ep.SubDomain) + len(ep.NodeName) + len(ep.InstanceName) +
+		len(ep.PreviousAddress) + len(ep.MigratedTo) + len(ep.WorkloadAddress) + len(ep.DataResidency)
+	for k, v := range ep.Labels {
+		size += len(k) + len(v)
+	}
+	for k := range ep.AdditionalPorts {
+		size += len(k) + 4
+	}
+	for _, z := range ep.ZoneHints {
+		size += len(z)
+	}
+	for k, v := range ep.Annotations {
+		size += len(k) + len(v)
+	}
+	if eep := ep.EnvoyEndpoint(); eep != nil {
+		size += proto.Size(eep)
+	}
+	return size
+}
+
 func (ep *IstioEndpoint) SupportsTunnel(tunnelType string) bool {
 	return SupportsTunnel(ep.Labels, tunnelType)
 }
@@ -552,6 +679,9 @@ func (ep *IstioEndpoint) GetLoadBalancingWeight() uint32 {
 
 // IsDiscoverableFromProxy indicates whether this endpoint is discoverable from the given Proxy.
 func (ep *IstioEndpoint) IsDiscoverableFromProxy(p *Proxy) bool {
+	if !ep.matchesProxyMetadata(p) {
+		return false
+	}
 	if ep == nil || ep.DiscoverabilityPolicy == nil {
 		// If no policy was assigned, default to discoverable mesh-wide.
 		// TODO(nmittler): Will need to re-think this default when cluster.local is actually cluster-local.
@@ -560,6 +690,25 @@ func (ep *IstioEndpoint) IsDiscoverableFromProxy(p *Proxy) bool {
 	return ep.DiscoverabilityPolicy.IsDiscoverableFromProxy(ep, p)
 }
 
+// matchesProxyMetadata applies the mesh wide features.EndpointDiscoverabilityMetadataKeys: an endpoint labeled
+// with one of the keys is only discoverable from proxies whose metadata has the same value for it.
+// Endpoints without these labels are discoverable from all proxies.
+func (ep *IstioEndpoint) matchesProxyMetadata(p *Proxy) bool {
+	if ep == nil {
+		return true
+	}
+	for _, key := range features.EndpointDiscoverabilityMetadataKeys {
+		want, f := ep.Labels[key]
+		if !f {
+			continue
+		}
+		if got, _ := p.DiscoverabilityMetadata(key); got != want {
+			return false
+		}
+	}
+	return true
+}
+
 // MetadataClone returns the cloned endpoint metadata used for telemetry purposes.
 // This should be used when the endpoint labels should be updated.
 func (ep *IstioEndpoint) MetadataClone() *EndpointMetadata {
@@ -570,6 +719,7 @@ func (ep *IstioEndpoint) MetadataClone() *EndpointMetadata {
 		Namespace:    ep.Namespace,
 		Labels:       maps.Clone(ep.Labels),
 		ClusterID:    ep.Locality.ClusterID,
+		Annotations:  ep.Annotations,
 	}
 }
 
@@ -582,6 +732,7 @@ func (ep *IstioEndpoint) Metadata() *EndpointMetadata {
 		Namespace:    ep.Namespace,
 		Labels:       ep.Labels,
 		ClusterID:    ep.Locality.ClusterID,
+		Annotations:  ep.Annotations,
 	}
 }
 
@@ -610,6 +761,26 @@ type EndpointMetadata struct {
 
 	// ClusterID where the endpoint is located
 	ClusterID cluster.ID
+
+	// Annotations of the workload to add to the endpoint metadata, see features.EndpointMetadataAnnotations.
+	Annotations map[string]string
+}
+
+// EndpointMetadataAnnotations returns the annotations mapped to endpoint metadata by
+// features.EndpointMetadataAnnotations, or nil if there are none.
+func EndpointMetadataAnnotations(annotations map[string]string) map[string]string {
+	var out map[string]string
+	for k := range features.EndpointMetadataAnnotations {
+		v, f := annotations[k]
+		if !f {
+			continue
+		}
+		if out == nil {
+			out = map[string]string{}
+		}
+		out[k] = v
+	}
+	return out
 }
 
 // EndpointDiscoverabilityPolicy determines the discoverability of an endpoint throughout the mesh.
@@ -705,9 +876,63 @@ type K8sAttributes struct {
 	// spec.ExternalName
 	ExternalName string
 
+	// ResolveExternalName means istiod resolves the ExternalName, following its CNAME chain, and serves the
+	// resulting addresses through EDS instead of using a DNS cluster.
+	ResolveExternalName bool
+
 	// NodeLocal means the proxy will only forward traffic to node local endpoints
 	// spec.InternalTrafficPolicy == Local
 	NodeLocal bool
+
+	// PreferNodeLocal means the proxy prefers the node local endpoints, spilling over to the other endpoints at a lower
+	// priority. It is set by the networking.istio.io/internal-traffic-policy: PreferLocal annotation of the service.
+	PreferNodeLocal bool
+
+	// PublishNotReadyAddresses means the endpoints which are not ready are published, as for
+	// spec.PublishNotReadyAddresses. They are kept with an unhealthy status.
+	PublishNotReadyAddresses bool
+
+	// EndpointPushPolicy controls how endpoint updates of the service are pushed to proxies.
+	EndpointPushPolicy EndpointPushPolicy
+
+	// IncludeTerminating is the networking.istio.io/include-terminating annotation of the service, if valid.
+	IncludeTerminating string
+
+	// CrossNetworkFailover is the networking.istio.io/cross-network-failover annotation of the service, if valid.
+	CrossNetworkFailover string
+
+	// DirectPodClusters is set by the networking.istio.io/direct-pod-clusters annotation of the service.
+	DirectPodClusters bool
+
+	// DisablePrecomputedEndpoints is set by the networking.istio.io/precompute-endpoints annotation of the service.
+	DisablePrecomputedEndpoints bool
+
+	// TopologyModeAuto is set by the service.kubernetes.io/topology-mode: Auto annotation of the service, or its
+	// deprecated service.kubernetes.io/topology-aware-hints form. Its endpoints are then filtered by their zone hints.
+	TopologyModeAuto bool
+
+	// AddressType is the traffic.istio.io/address-type annotation of the service, if valid.
+	AddressType string
+
+	// ScaleUpRamp is the window of the networking.istio.io/scale-up-ramp annotation of the service, if valid.
+	ScaleUpRamp time.Duration
+
+	// SessionAffinityClientIP is set for the ClusterSet host of a ServiceImport with the ClientIP session affinity.
+	SessionAffinityClientIP bool
+
+	// ClusterSetOrigin is the hostname of the exported Kubernetes Service the service of a ClusterSet host was copied
+	// from, when no cluster of the mesh imports it. See PILOT_ENABLE_CLUSTERSET_SERVICE_RESOLUTION.
+	ClusterSetOrigin host.Name
+}
+
+// EndpointPushPolicy controls how incremental endpoint updates of a service are pushed.
+// The zero value uses the global debounce settings.
+type EndpointPushPolicy struct {
+	// Immediate pushes endpoint updates without waiting for the debounce period.
+	Immediate bool
+	// Debounce, if set, coalesces endpoint updates of the service for the given period before
+	// they are pushed, in addition to the global debounce.
+	Debounce time.Duration
 }
 
 // DeepCopy creates a deep copy of ServiceAttributes, but skips internal mutexes.
@@ -846,8 +1071,10 @@ type AmbientIndexes interface {
 		currentSubs sets.String,
 	) sets.Set[string]
 	Policies(requested sets.Set[ConfigKey]) []*security.Authorization
-	Waypoint(scope WaypointScope) []netip.Addr
+	Waypoint(n network.ID, scope WaypointScope) []netip.Addr
 	WorkloadsForWaypoint(scope WaypointScope) []*WorkloadInfo
+	// WaypointScopeFor returns the scope of the waypoint owning the workload, if any.
+	WaypointScopeFor(namespace, serviceAccount string, workloadLabels labels.Instance) (WaypointScope, bool)
 }
 
 // NoopAmbientIndexes provides an implementation of AmbientIndexes that always returns nil, to easily "skip" it.
@@ -869,7 +1096,7 @@ func (u NoopAmbientIndexes) Policies(sets.Set[ConfigKey]) []*security.Authorizat
 	return nil
 }
 
-func (u NoopAmbientIndexes) Waypoint(WaypointScope) []netip.Addr {
+func (u NoopAmbientIndexes) Waypoint(network.ID, WaypointScope) []netip.Addr {
 	return nil
 }
 
@@ -877,6 +1104,10 @@ func (u NoopAmbientIndexes) WorkloadsForWaypoint(scope WaypointScope) []*Workloa
 	return nil
 }
 
+func (u NoopAmbientIndexes) WaypointScopeFor(string, string, labels.Instance) (WaypointScope, bool) {
+	return WaypointScope{}, false
+}
+
 var _ AmbientIndexes = NoopAmbientIndexes{}
 
 type AddressInfo struct {
@@ -1029,6 +1260,60 @@ func (s *Service) External() bool {
 	return s.MeshExternal
 }
 
+// PersistentSession returns whether the service uses cookie or header based persistent sessions. The draining
+// endpoints of such services are kept, so that the sessions pinned to them can still reach them.
+func (s *Service) PersistentSession() bool {
+	return features.PersistentSessionLabel != "" && s.Attributes.Labels[features.PersistentSessionLabel] != "" ||
+		features.PersistentSessionHeaderLabel != "" && s.Attributes.Labels[features.PersistentSessionHeaderLabel] != ""
+}
+
+// CrossNetworkFailover returns how the endpoints of remote networks of the service, reached through their network
+// gateways, are load balanced: constants.CrossNetworkFailoverWeighted or constants.CrossNetworkFailoverPriority.
+func (s *Service) CrossNetworkFailover() string {
+	if s.Attributes.CrossNetworkFailover != "" {
+		return s.Attributes.CrossNetworkFailover
+	}
+	if features.CrossNetworkFailover == constants.CrossNetworkFailoverPriority {
+		return constants.CrossNetworkFailoverPriority
+	}
+	return constants.CrossNetworkFailoverWeighted
+}
+
+// TopologyAwareHints returns true if the endpoints of the service are filtered by their zone hints.
+func (s *Service) TopologyAwareHints() bool {
+	return features.EnableTopologyAwareHints && s.Attributes.TopologyModeAuto
+}
+
+// IncludeTerminating returns whether the endpoints of terminating pods which are still serving are sent for the
+// service: constants.IncludeTerminatingAlways, constants.IncludeTerminatingWhenNoReady or
+// constants.IncludeTerminatingNever.
+func (s *Service) IncludeTerminating() string {
+	if s.Attributes.IncludeTerminating != "" {
+		return s.Attributes.IncludeTerminating
+	}
+	switch features.IncludeTerminatingEndpoints {
+	case constants.IncludeTerminatingAlways, constants.IncludeTerminatingWhenNoReady:
+		return features.IncludeTerminatingEndpoints
+	default:
+		return constants.IncludeTerminatingNever
+	}
+}
+
+// DirectPodSubsetPrefix prefixes the subset of the clusters reaching a single endpoint of a service with the
+// networking.istio.io/direct-pod-clusters annotation, followed by the address of the endpoint.
+const DirectPodSubsetPrefix = "istio-pod-"
+
+// DirectPodSubset returns the subset of the cluster reaching the endpoint with the address.
+func DirectPodSubset(address string) string {
+	return DirectPodSubsetPrefix + address
+}
+
+// DirectPodAddress returns the endpoint address of a direct pod cluster subset.
+func DirectPodAddress(subset string) (string, bool) {
+	address, ok := strings.CutPrefix(subset, DirectPodSubsetPrefix)
+	return address, ok && address != ""
+}
+
 // BuildSubsetKey generates a unique string referencing service instances for a given service name, a subset and a port.
 // The proxy queries Pilot with this key to obtain the list of instances in a subset.
 func BuildSubsetKey(direction TrafficDirection, subsetName string, hostname host.Name, port int) string {
@@ -1048,6 +1333,38 @@ func BuildDNSSrvSubsetKey(direction TrafficDirection, subsetName string, hostnam
 	return string(direction) + "_." + strconv.Itoa(port) + "_." + subsetName + "_." + string(hostname)
 }
 
+// BuildDNSSrvSubsetKeyPortRange is like BuildDNSSrvSubsetKey, for a cluster covering the service ports from minPort
+// to maxPort, such as outbound_.9092-9094_._.kafka.ns.svc.cluster.local.
+// This is used only for the SNI-DNAT router. Do not use for other purposes.
+func BuildDNSSrvSubsetKeyPortRange(direction TrafficDirection, subsetName string, hostname host.Name, minPort, maxPort int) string {
+	return string(direction) + "_." + strconv.Itoa(minPort) + "-" + strconv.Itoa(maxPort) + "_." + subsetName + "_." + string(hostname)
+}
+
+// ParseDNSSrvSubsetKeyPortRange returns the port range of a key built by BuildDNSSrvSubsetKeyPortRange.
+// It returns false for other keys.
+func ParseDNSSrvSubsetKeyPortRange(s string) (minPort, maxPort int, ok bool) {
+	if !IsDNSSrvSubsetKey(s) {
+		return 0, 0, false
+	}
+	parts := strings.SplitN(s, ".", 3)
+	if len(parts) < 3 {
+		return 0, 0, false
+	}
+	low, high, found := strings.Cut(strings.TrimSuffix(parts[1], "_"), "-")
+	if !found {
+		return 0, 0, false
+	}
+	minPort, err := strconv.Atoi(low)
+	if err != nil {
+		return 0, 0, false
+	}
+	maxPort, err = strconv.Atoi(high)
+	if err != nil || maxPort < minPort {
+		return 0, 0, false
+	}
+	return minPort, maxPort, true
+}
+
 // IsValidSubsetKey checks if a string is valid for subset key parsing.
 func IsValidSubsetKey(s string) bool {
 	return strings.Count(s, "|") == 3
@@ -1087,6 +1404,10 @@ func ParseSubsetKey(s string) (direction TrafficDirection, subsetName string, ho
 
 	if dnsSrvMode {
 		subsetName = strings.TrimSuffix(parts[2], "_")
+		// For port ranges, the first port of the range is returned.
+		if low, _, found := strings.Cut(strings.TrimSuffix(parts[1], "_"), "-"); found {
+			port, _ = strconv.Atoi(low)
+		}
 	}
 
 	hostname = host.Name(parts[3])
@@ -1235,6 +1556,8 @@ func (ep *IstioEndpoint) DeepCopy() *IstioEndpoint {
 func (ep *IstioEndpoint) ShallowCopy() *IstioEndpoint {
 	// nolint: govet
 	cpy := *ep
+	// The endpoints of the additional ports are copies of the original, which the caller may be about to modify.
+	cpy.portEndpoints = nil
 	return &cpy
 }
 
@@ -21,10 +21,12 @@ import (
 	"github.com/google/go-cmp/cmp/cmpopts"
 	fuzz "github.com/google/gofuzz"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/config/visibility"
+	"istio.io/istio/pkg/test"
 )
 
 func TestGetByPort(t *testing.T) {
@@ -61,6 +63,7 @@ func TestParseSubsetKey(t *testing.T) {
 		{"|||", "", "", "", 0},
 		{"outbound_.8080_.v1_.foo.example.org", TrafficDirectionOutbound, "v1", "foo.example.org", 8080},
 		{"inbound_.8080_.v1_.foo.example.org", TrafficDirectionInbound, "v1", "foo.example.org", 8080},
+		{"outbound_.9092-9094_._.kafka.example.org", TrafficDirectionOutbound, "", "kafka.example.org", 9092},
 	}
 
 	for _, tt := range tests {
@@ -82,6 +85,30 @@ func TestParseSubsetKey(t *testing.T) {
 	}
 }
 
+func TestDNSSrvSubsetKeyPortRange(t *testing.T) {
+	key := BuildDNSSrvSubsetKeyPortRange(TrafficDirectionOutbound, "v1", "kafka.example.org", 9092, 9094)
+	if key != "outbound_.9092-9094_.v1_.kafka.example.org" {
+		t.Fatalf("unexpected key %s", key)
+	}
+	if !IsDNSSrvSubsetKey(key) {
+		t.Fatalf("expected %s to be a DNS SRV key", key)
+	}
+	minPort, maxPort, ok := ParseDNSSrvSubsetKeyPortRange(key)
+	if !ok || minPort != 9092 || maxPort != 9094 {
+		t.Fatalf("unexpected range %d-%d (%v)", minPort, maxPort, ok)
+	}
+	for _, k := range []string{
+		"outbound_.9092_.v1_.kafka.example.org",
+		"outbound|9092|v1|kafka.example.org",
+		"outbound_.9094-9092_.v1_.kafka.example.org",
+		"outbound_.a-b_.v1_.kafka.example.org",
+	} {
+		if _, _, ok := ParseDNSSrvSubsetKeyPortRange(k); ok {
+			t.Errorf("expected %s to not be a port range key", k)
+		}
+	}
+}
+
 func TestIsValidSubsetKey(t *testing.T) {
 	cases := []struct {
 		subsetkey string
@@ -183,6 +210,8 @@ func TestWorkloadInstanceEqual(t *testing.T) {
 	}
 	differingLbWeight := exampleInstance.DeepCopy()
 	differingLbWeight.Endpoint.LbWeight = 0
+	differingPortLabels := exampleInstance.DeepCopy()
+	differingPortLabels.PortLabels = map[string]labels.Instance{"service-port-name": {"role": "admin"}}
 
 	cases := []struct {
 		comparer *WorkloadInstance
@@ -244,6 +273,12 @@ func TestWorkloadInstanceEqual(t *testing.T) {
 			shouldEq: false,
 			name:     "different LbWeight",
 		},
+		{
+			comparer: exampleInstance.DeepCopy(),
+			comparee: differingPortLabels.DeepCopy(),
+			shouldEq: false,
+			name:     "different PortLabels",
+		},
 	}
 
 	for _, testCase := range cases {
@@ -563,3 +598,44 @@ func TestFuzzServiceDeepCopy(t *testing.T) {
 		t.Errorf("unexpected diff %v", diff)
 	}
 }
+
+func TestIsDiscoverableFromProxyMetadata(t *testing.T) {
+	test.SetForTest(t, &features.EndpointDiscoverabilityMetadataKeys, []string{"tenant"})
+	proxy := func(tenant string) *Proxy {
+		raw := map[string]any{}
+		if tenant != "" {
+			raw["tenant"] = tenant
+		}
+		return &Proxy{Metadata: &NodeMetadata{Raw: raw}}
+	}
+	cases := []struct {
+		name     string
+		labels   map[string]string
+		proxy    *Proxy
+		expected bool
+	}{
+		{name: "shared endpoint", labels: nil, proxy: proxy("a"), expected: true},
+		{name: "shared endpoint, proxy without tenant", labels: nil, proxy: proxy(""), expected: true},
+		{name: "same tenant", labels: map[string]string{"tenant": "a"}, proxy: proxy("a"), expected: true},
+		{name: "other tenant", labels: map[string]string{"tenant": "a"}, proxy: proxy("b"), expected: false},
+		{name: "proxy without tenant", labels: map[string]string{"tenant": "a"}, proxy: proxy(""), expected: false},
+		{
+			name:     "policy still applies",
+			labels:   map[string]string{"tenant": "a"},
+			proxy:    &Proxy{Metadata: &NodeMetadata{ClusterID: "c2", Raw: map[string]any{"tenant": "a"}}},
+			expected: false,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ep := &IstioEndpoint{
+				Labels:                tc.labels,
+				Locality:              Locality{ClusterID: "c1"},
+				DiscoverabilityPolicy: DiscoverableFromSameCluster,
+			}
+			if got := ep.IsDiscoverableFromProxy(tc.proxy); got != tc.expected {
+				t.Fatalf("expected discoverable %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -109,6 +109,9 @@ type SidecarScope struct {
 	// destination rule.
 	destinationRules        map[host.Name][]*ConsolidatedDestRule
 	destinationRulesByNames map[types.NamespacedName]*config.Config
+	// selectedDestinationRules holds the merges of the destination rules with workload selectors of a host that
+	// several of them may select together, keyed by the bitmask of their indexes in destinationRules.
+	selectedDestinationRules map[host.Name]map[uint64]*ConsolidatedDestRule
 
 	// OutboundTrafficPolicy defines the outbound traffic policy for this sidecar.
 	// If OutboundTrafficPolicy is ALLOW_ANY traffic to unknown destinations will
@@ -125,6 +128,10 @@ type SidecarScope struct {
 	//
 	// Changes to Sidecar resources in this namespace will trigger a push.
 	RootNamespace string
+
+	// EndpointSelection restricts the endpoints sent to the proxies, if set by the
+	// networking.istio.io/endpoint-selection annotation of the Sidecar.
+	EndpointSelection *EndpointSelection
 }
 
 // MarshalJSON implements json.Marshaller
@@ -139,6 +146,7 @@ func (sc *SidecarScope) MarshalJSON() ([]byte, error) {
 		"services":              sc.services,
 		"sidecar":               sc.Sidecar,
 		"destinationRules":      sc.destinationRules,
+		"endpointSelection":     sc.EndpointSelection,
 	}, "", "  ")
 }
 
@@ -249,6 +257,8 @@ func DefaultSidecarScopeForNamespace(ps *PushContext, configNamespace string) *S
 		}
 	}
 
+	out.precomputeSelectedDestinationRules()
+
 	if ps.Mesh.OutboundTrafficPolicy != nil {
 		out.OutboundTrafficPolicy = &networking.OutboundTrafficPolicy{
 			Mode: networking.OutboundTrafficPolicy_Mode(ps.Mesh.OutboundTrafficPolicy.Mode),
@@ -280,6 +290,15 @@ func ConvertToSidecarScope(ps *PushContext, sidecarConfig *config.Config, config
 		Namespace: sidecarConfig.Namespace,
 	}.HashCode())
 
+	if v, f := sidecarConfig.Annotations[constants.EndpointSelection]; f {
+		sel, err := ParseEndpointSelection(v, sidecarConfig.Namespace)
+		if err != nil {
+			log.Warnf("ignoring %s annotation of sidecar %s/%s: %v", constants.EndpointSelection, sidecarConfig.Namespace, sidecarConfig.Name, err)
+		} else {
+			out.EndpointSelection = sel
+		}
+	}
+
 	egressConfigs := sidecar.Egress
 	// If egress not set, setup a default listener
 	if len(egressConfigs) == 0 {
@@ -432,6 +451,8 @@ func ConvertToSidecarScope(ps *PushContext, sidecarConfig *config.Config, config
 		}
 	}
 
+	out.precomputeSelectedDestinationRules()
+
 	if sidecar.OutboundTrafficPolicy == nil {
 		if ps.Mesh.OutboundTrafficPolicy != nil {
 			out.OutboundTrafficPolicy = &networking.OutboundTrafficPolicy{
@@ -585,11 +606,64 @@ func (sc *SidecarScope) AddConfigDependencies(dependencies ...ConfigHash) {
 	}
 }
 
+// maxMergedSelectedDestinationRules bounds the destination rules with workload selectors of a host whose combinations
+// are merged when the scope is built. Beyond it, the rules selected by a proxy are merged on each call.
+const maxMergedSelectedDestinationRules = 4
+
+// precomputeSelectedDestinationRules merges, for each host, every combination of the destination rules with workload
+// selectors that DestinationRule may select together, so that it does not merge them on each call.
+func (sc *SidecarScope) precomputeSelectedDestinationRules() {
+	for hostname, destinationRules := range sc.destinationRules {
+		// The combinations are keyed by the bitmask of the indexes of their rules.
+		if len(destinationRules) > 64 {
+			continue
+		}
+		var indexes []int
+		for i, destRule := range destinationRules {
+			if sc.selectsDestinationRule(destRule) {
+				indexes = append(indexes, i)
+			}
+		}
+		if len(indexes) < 2 || len(indexes) > maxMergedSelectedDestinationRules {
+			continue
+		}
+		merged := map[uint64]*ConsolidatedDestRule{}
+		for combination := 1; combination < 1<<len(indexes); combination++ {
+			var mask uint64
+			var selected []*ConsolidatedDestRule
+			for bit, i := range indexes {
+				if combination&(1<<bit) != 0 {
+					mask |= 1 << i
+					selected = append(selected, destinationRules[i])
+				}
+			}
+			if len(selected) > 1 {
+				merged[mask] = mergeSelectedDestinationRules(selected)
+			}
+		}
+		if sc.selectedDestinationRules == nil {
+			sc.selectedDestinationRules = map[host.Name]map[uint64]*ConsolidatedDestRule{}
+		}
+		sc.selectedDestinationRules[hostname] = merged
+	}
+}
+
+// selectsDestinationRule returns whether the destination rule applies to the outbound traffic of the proxies of the
+// scope matching its workload selector.
+func (sc *SidecarScope) selectsDestinationRule(destRule *ConsolidatedDestRule) bool {
+	return sc.Namespace == destRule.rule.Namespace &&
+		destRule.rule.Spec.(*networking.DestinationRule).GetWorkloadSelector() != nil
+}
+
 // DestinationRule returns a destinationrule for a svc.
+// When several destination rules with different workload selectors match the proxy, they are
+// merged in order, in the same way destination rules without a workload selector are.
 func (sc *SidecarScope) DestinationRule(direction TrafficDirection, proxy *Proxy, svc host.Name) *ConsolidatedDestRule {
 	destinationRules := sc.destinationRules[svc]
 	var catchAllDr *ConsolidatedDestRule
-	for _, destRule := range destinationRules {
+	var selected []*ConsolidatedDestRule
+	var mask uint64
+	for i, destRule := range destinationRules {
 		destinationRule := destRule.rule.Spec.(*networking.DestinationRule)
 		if destinationRule.GetWorkloadSelector() == nil {
 			catchAllDr = destRule
@@ -597,15 +671,25 @@ func (sc *SidecarScope) DestinationRule(direction TrafficDirection, proxy *Proxy
 		// filter DestinationRule based on workloadSelector for outbound configs.
 		// WorkloadSelector configuration is honored only for outbound configuration, because
 		// for inbound configuration, the settings at sidecar would be more explicit and the preferred way forward.
-		if sc.Namespace == destRule.rule.Namespace &&
-			destinationRule.GetWorkloadSelector() != nil && direction == TrafficDirectionOutbound {
+		if direction == TrafficDirectionOutbound && sc.selectsDestinationRule(destRule) {
 			workloadSelector := labels.Instance(destinationRule.GetWorkloadSelector().GetMatchLabels())
-			// return destination rule if workload selector matches
+			// select destination rule if workload selector matches
 			if workloadSelector.SubsetOf(proxy.Labels) {
-				return destRule
+				selected = append(selected, destRule)
+				mask |= 1 << i
 			}
 		}
 	}
+	switch len(selected) {
+	case 0:
+	case 1:
+		return selected[0]
+	default:
+		if merged, f := sc.selectedDestinationRules[svc][mask]; f {
+			return merged
+		}
+		return mergeSelectedDestinationRules(selected)
+	}
 	// If there is no workload specific destinationRule, return the wild carded dr if present.
 	if catchAllDr != nil {
 		return catchAllDr
@@ -0,0 +1,81 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SocketOptions are the TCP socket options of the upstream connections to an endpoint, set by the
+// constants.UpstreamSocketOptionsAnnotation of its WorkloadEntry or ServiceEntry. Zero values are unset.
+type SocketOptions struct {
+	// KeepaliveTime is the idle time before keepalive probes are sent.
+	KeepaliveTime time.Duration
+	// KeepaliveInterval is the time between keepalive probes.
+	KeepaliveInterval time.Duration
+	// KeepaliveProbes is the number of unanswered keepalive probes before the connection is dropped.
+	KeepaliveProbes uint32
+}
+
+// ParseSocketOptions parses the value of a constants.UpstreamSocketOptionsAnnotation, such as
+// "keepalive-time=30s,keepalive-probes=3". It returns nil if the value is empty.
+func ParseSocketOptions(v string) (*SocketOptions, error) {
+	if strings.TrimSpace(v) == "" {
+		return nil, nil
+	}
+	out := &SocketOptions{}
+	for _, option := range strings.Split(v, ",") {
+		name, value, f := strings.Cut(strings.TrimSpace(option), "=")
+		if !f {
+			return nil, fmt.Errorf("invalid socket option %q, expected <option>=<value>", option)
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		switch name {
+		case "keepalive-time", "keepalive-interval":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %v", name, err)
+			}
+			if d < time.Second || d%time.Second != 0 {
+				return nil, fmt.Errorf("invalid %s %q: must be a positive whole number of seconds", name, value)
+			}
+			if name == "keepalive-time" {
+				out.KeepaliveTime = d
+			} else {
+				out.KeepaliveInterval = d
+			}
+		case "keepalive-probes":
+			n, err := strconv.ParseUint(value, 10, 32)
+			if err != nil || n == 0 {
+				return nil, fmt.Errorf("invalid keepalive-probes %q: must be a positive count", value)
+			}
+			out.KeepaliveProbes = uint32(n)
+		default:
+			return nil, fmt.Errorf("unknown socket option %q", name)
+		}
+	}
+	return out, nil
+}
+
+// Equals returns whether the socket options are the same, a nil value being equal to another nil value only.
+func (o *SocketOptions) Equals(other *SocketOptions) bool {
+	if o == nil || other == nil {
+		return o == other
+	}
+	return *o == *other
+}
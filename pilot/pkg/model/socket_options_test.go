@@ -0,0 +1,55 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestParseSocketOptions(t *testing.T) {
+	cases := []struct {
+		in   string
+		want *SocketOptions
+		err  bool
+	}{
+		{in: "", want: nil},
+		{in: "keepalive-time=30s", want: &SocketOptions{KeepaliveTime: 30 * time.Second}},
+		{
+			in:   "keepalive-time=1m, keepalive-interval=10s ,keepalive-probes=3",
+			want: &SocketOptions{KeepaliveTime: time.Minute, KeepaliveInterval: 10 * time.Second, KeepaliveProbes: 3},
+		},
+		{in: "keepalive-time", err: true},
+		{in: "keepalive-time=500ms", err: true},
+		{in: "keepalive-time=1.5s", err: true},
+		{in: "keepalive-interval=-10s", err: true},
+		{in: "keepalive-probes=0", err: true},
+		{in: "keepalive-probes=many", err: true},
+		{in: "linger=10s", err: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSocketOptions(tt.in)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
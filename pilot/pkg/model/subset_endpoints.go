@@ -0,0 +1,85 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"sync"
+
+	"istio.io/istio/pkg/config/labels"
+)
+
+type subsetEndpointsKey struct {
+	svcKey string
+	port   int
+	// labels is the canonical form of the subset labels.
+	labels string
+}
+
+// subsetEndpointsCache indexes the endpoints of the serviceIndex by subset labels. The endpoints of a
+// serviceIndex never change, so each subset is filtered once, the first time it is looked up, and then
+// shared by all the pushes using the serviceIndex. This avoids re-scanning all the endpoints of a large
+// service for each of its subsets when building clusters.
+type subsetEndpointsCache struct {
+	mu        sync.RWMutex
+	endpoints map[subsetEndpointsKey][]*IstioEndpoint
+}
+
+func newSubsetEndpointsCache() *subsetEndpointsCache {
+	return &subsetEndpointsCache{endpoints: map[subsetEndpointsKey][]*IstioEndpoint{}}
+}
+
+// get returns the instances matching the labels, filtering them on the first lookup of the subset.
+func (c *subsetEndpointsCache) get(svcKey string, port int, lbls labels.Instance, instances []*IstioEndpoint) []*IstioEndpoint {
+	if c == nil {
+		return filterEndpointsByLabels(instances, lbls)
+	}
+	key := subsetEndpointsKey{svcKey: svcKey, port: port, labels: lbls.String()}
+	c.mu.RLock()
+	out, f := c.endpoints[key]
+	c.mu.RUnlock()
+	if f {
+		return out
+	}
+	out = filterEndpointsByLabels(instances, lbls)
+	c.mu.Lock()
+	c.endpoints[key] = out
+	c.mu.Unlock()
+	return out
+}
+
+// clear drops the subsets of the service, after its endpoints changed.
+func (c *subsetEndpointsCache) clear(svcKey string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.endpoints {
+		if k.svcKey == svcKey {
+			delete(c.endpoints, k)
+		}
+	}
+}
+
+func filterEndpointsByLabels(instances []*IstioEndpoint, lbls labels.Instance) []*IstioEndpoint {
+	var out []*IstioEndpoint
+	for _, instance := range instances {
+		// check that one of the input labels is a subset of the labels
+		if lbls.SubsetOf(instance.Labels) {
+			out = append(out, instance)
+		}
+	}
+	return out
+}
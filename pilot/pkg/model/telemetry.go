@@ -41,9 +41,11 @@ import (
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/networking"
 	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/config/xds"
+	"istio.io/istio/pkg/maps"
 	"istio.io/istio/pkg/ptr"
 	"istio.io/istio/pkg/util/protomarshal"
 	"istio.io/istio/pkg/util/sets"
@@ -54,6 +56,9 @@ type Telemetry struct {
 	Name      string         `json:"name"`
 	Namespace string         `json:"namespace"`
 	Spec      *tpb.Telemetry `json:"spec"`
+	// EndpointMetadataKeys is the telemetry.istio.io/endpoint-metadata annotation of the Telemetry, the comma
+	// separated endpoint label keys requested by its providers.
+	EndpointMetadataKeys string `json:"endpointMetadataKeys,omitempty"`
 }
 
 // Telemetries organizes Telemetry configuration by namespace.
@@ -121,9 +126,10 @@ func getTelemetries(env *Environment) *Telemetries {
 	sortConfigByCreationTime(fromEnv)
 	for _, config := range fromEnv {
 		telemetry := Telemetry{
-			Name:      config.Name,
-			Namespace: config.Namespace,
-			Spec:      config.Spec.(*tpb.Telemetry),
+			Name:                 config.Name,
+			Namespace:            config.Namespace,
+			Spec:                 config.Spec.(*tpb.Telemetry),
+			EndpointMetadataKeys: config.Annotations[constants.TelemetryEndpointMetadataAnnotation],
 		}
 		telemetries.NamespaceToTelemetries[config.Namespace] = append(telemetries.NamespaceToTelemetries[config.Namespace], telemetry)
 	}
@@ -186,6 +192,8 @@ type computedTelemetries struct {
 	Metrics []*tpb.Metrics
 	Logging []*computedAccessLogging
 	Tracing []*tpb.Tracing
+	// EndpointMetadataKeys are the endpoint metadata keys requested by the Telemetries, in their raw form.
+	EndpointMetadataKeys []string
 }
 
 // computedAccessLogging contains the various AccessLogging configurations in scope for a given proxy,
@@ -400,6 +408,7 @@ func (t *Telemetries) applicableTelemetries(proxy *Proxy) computedTelemetries {
 	ms := []*tpb.Metrics{}
 	ls := []*computedAccessLogging{}
 	ts := []*tpb.Tracing{}
+	var es []string
 	key := telemetryKey{}
 	if t.RootNamespace != "" {
 		telemetry := t.namespaceWideTelemetryConfig(t.RootNamespace)
@@ -415,6 +424,9 @@ func (t *Telemetries) applicableTelemetries(proxy *Proxy) computedTelemetries {
 				})
 			}
 			ts = append(ts, telemetry.Spec.GetTracing()...)
+			if telemetry.EndpointMetadataKeys != "" {
+				es = append(es, telemetry.EndpointMetadataKeys)
+			}
 		}
 	}
 
@@ -432,6 +444,9 @@ func (t *Telemetries) applicableTelemetries(proxy *Proxy) computedTelemetries {
 				})
 			}
 			ts = append(ts, telemetry.Spec.GetTracing()...)
+			if telemetry.EndpointMetadataKeys != "" {
+				es = append(es, telemetry.EndpointMetadataKeys)
+			}
 		}
 	}
 
@@ -453,6 +468,9 @@ func (t *Telemetries) applicableTelemetries(proxy *Proxy) computedTelemetries {
 				})
 			}
 			ts = append(ts, spec.GetTracing()...)
+			if telemetry.EndpointMetadataKeys != "" {
+				es = append(es, telemetry.EndpointMetadataKeys)
+			}
 			break
 		}
 	}
@@ -462,7 +480,48 @@ func (t *Telemetries) applicableTelemetries(proxy *Proxy) computedTelemetries {
 		Metrics:      ms,
 		Logging:      ls,
 		Tracing:      ts,
+
+		EndpointMetadataKeys: es,
+	}
+}
+
+// EndpointMetadataKeys returns the endpoint label keys requested by the telemetry providers of the proxy, with the
+// telemetry.istio.io/endpoint-metadata annotation of the root namespace, namespace and workload Telemetries applying
+// to it, sorted and without duplicates.
+func (t *Telemetries) EndpointMetadataKeys(proxy *Proxy) []string {
+	keys := sets.New[string]()
+	for _, requested := range t.applicableTelemetries(proxy).EndpointMetadataKeys {
+		for _, key := range strings.Split(requested, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				keys.Insert(key)
+			}
+		}
+	}
+	if keys.IsEmpty() {
+		return nil
+	}
+	return sets.SortedList(keys)
+}
+
+// EndpointMetadataChanged returns true if the telemetry.istio.io/endpoint-metadata annotations of the Telemetries
+// differ from those of prev.
+func (t *Telemetries) EndpointMetadataChanged(prev *Telemetries) bool {
+	return !maps.Equal(t.endpointMetadataAnnotations(), prev.endpointMetadataAnnotations())
+}
+
+func (t *Telemetries) endpointMetadataAnnotations() map[types.NamespacedName]string {
+	out := map[types.NamespacedName]string{}
+	if t == nil {
+		return out
+	}
+	for _, telemetries := range t.NamespaceToTelemetries {
+		for _, telemetry := range telemetries {
+			if telemetry.EndpointMetadataKeys != "" {
+				out[types.NamespacedName{Name: telemetry.Name, Namespace: telemetry.Namespace}] = telemetry.EndpointMetadataKeys
+			}
+		}
 	}
+	return out
 }
 
 // telemetryFilters computes the filters for the given proxy/class and protocol. This computes the
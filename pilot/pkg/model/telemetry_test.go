@@ -33,8 +33,10 @@ import (
 	"istio.io/api/envoy/extensions/stats"
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	tpb "istio.io/api/telemetry/v1alpha1"
+	"istio.io/api/type/v1beta1"
 	"istio.io/istio/pilot/pkg/networking"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/config/schema/collection"
 	"istio.io/istio/pkg/config/schema/gvk"
@@ -1028,6 +1030,36 @@ func TestTelemetryFilters(t *testing.T) {
 	}
 }
 
+func TestEndpointMetadataKeys(t *testing.T) {
+	withKeys := func(cfg config.Config, name, keys string) config.Config {
+		cfg.Name = name
+		cfg.Annotations = map[string]string{constants.TelemetryEndpointMetadataAnnotation: keys}
+		return cfg
+	}
+	root := withKeys(newTelemetry("istio-system", &tpb.Telemetry{}), "root", "team, version")
+	namespace := withKeys(newTelemetry("default", &tpb.Telemetry{}), "namespace", "")
+	workload := withKeys(newTelemetry("default", &tpb.Telemetry{
+		Selector: &v1beta1.WorkloadSelector{MatchLabels: map[string]string{"app": "wasm"}},
+	}), "workload", "cost-center,,version")
+	telemetries, _ := createTestTelemetries([]config.Config{root, namespace, workload}, t)
+
+	proxy := func(labels map[string]string) *Proxy {
+		return &Proxy{ConfigNamespace: "default", Labels: labels, Metadata: &NodeMetadata{Labels: labels}}
+	}
+	assert.Equal(t, telemetries.EndpointMetadataKeys(proxy(nil)), []string{"team", "version"})
+	assert.Equal(t, telemetries.EndpointMetadataKeys(proxy(map[string]string{"app": "wasm"})),
+		[]string{"cost-center", "team", "version"})
+
+	none, _ := createTestTelemetries([]config.Config{newTelemetry("istio-system", &tpb.Telemetry{})}, t)
+	assert.Equal(t, none.EndpointMetadataKeys(proxy(nil)), nil)
+
+	// Only the changes of the annotations change the endpoint metadata.
+	assert.Equal(t, telemetries.EndpointMetadataChanged(none), true)
+	assert.Equal(t, none.EndpointMetadataChanged(nil), false)
+	same, _ := createTestTelemetries([]config.Config{root, namespace, workload, newTelemetry("other", &tpb.Telemetry{})}, t)
+	assert.Equal(t, same.EndpointMetadataChanged(telemetries), false)
+}
+
 func TestGetInterval(t *testing.T) {
 	cases := []struct {
 		name              string
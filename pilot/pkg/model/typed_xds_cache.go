@@ -16,6 +16,8 @@ package model
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"sync"
 	"time"
 
@@ -25,6 +27,8 @@ import (
 	"google.golang.org/protobuf/testing/protocmp"
 
 	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/maps"
 	"istio.io/istio/pkg/monitoring"
 	"istio.io/istio/pkg/slices"
 	"istio.io/istio/pkg/util/sets"
@@ -53,26 +57,31 @@ var (
 		monitoring.WithEnabled(enableStats),
 	)
 
+	xdsCacheDeduplicated = monitoring.NewSum(
+		"xds_cache_deduplicated",
+		"Total number of xds cache entries sharing the copy of an identical cached resource.",
+		monitoring.WithEnabled(enableStats),
+	)
+
+	xdsCacheInvalidations = monitoring.NewSum(
+		"xds_cache_invalidations",
+		"Total number of xds cache entries invalidated by config changes, by kind of config.",
+		monitoring.WithEnabled(enableStats),
+	)
+
 	dependentConfigSize = monitoring.NewGauge(
 		"xds_cache_dependent_config_size",
 		"Current size of dependent configs",
 		monitoring.WithEnabled(enableStats),
 	)
 
-	xdsCacheHits             = xdsCacheReads.With(typeTag.Value("hit"))
-	xdsCacheMisses           = xdsCacheReads.With(typeTag.Value("miss"))
+	cacheTag = monitoring.CreateLabel("cache")
+	kindTag  = monitoring.CreateLabel("kind")
+
 	xdsCacheEvictionsOnClear = xdsCacheEvictions.With(typeTag.Value("clear"))
 	xdsCacheEvictionsOnSize  = xdsCacheEvictions.With(typeTag.Value("size"))
 )
 
-func hit() {
-	xdsCacheHits.Increment()
-}
-
-func miss() {
-	xdsCacheMisses.Increment()
-}
-
 func size(cs int) {
 	xdsCacheSize.Record(float64(cs))
 }
@@ -103,8 +112,37 @@ type typedXdsCache[K comparable] interface {
 	Keys() []K
 	// Snapshot returns a snapshot of all keys and values. This is for testing/debug only
 	Snapshot() []*discovery.Resource
+	// Stats returns the reads and invalidations of the cache. This is for debug only
+	Stats() XdsCacheStats
+}
+
+// XdsCacheStats summarizes the reads of a cache and the invalidations of its entries, to find the configs
+// destroying its cacheability.
+type XdsCacheStats struct {
+	Hits    uint64  `json:"hits"`
+	Misses  uint64  `json:"misses"`
+	HitRate float64 `json:"hitRate"`
+	// Invalidations is the number of entries invalidated by kind of config, or "all" for full invalidations.
+	Invalidations map[string]uint64 `json:"invalidations,omitempty"`
+	// TopInvalidations are the configs which invalidated the most entries.
+	TopInvalidations []ConfigInvalidations `json:"topInvalidations,omitempty"`
+}
+
+// ConfigInvalidations is the number of cache entries invalidated by the changes of a config.
+type ConfigInvalidations struct {
+	Config  string `json:"config"`
+	Entries uint64 `json:"entries"`
 }
 
+const (
+	// invalidationsOfAll is the cause of the invalidations of the whole cache, such as full pushes.
+	invalidationsOfAll = "all"
+	// maxTrackedConfigs bounds the number of configs whose invalidations are tracked.
+	maxTrackedConfigs = 1000
+	// topInvalidations is the number of configs reported by XdsCacheStats.TopInvalidations.
+	topInvalidations = 10
+)
+
 // newTypedXdsCache returns an instance of a cache.
 func newTypedXdsCache[K comparable]() typedXdsCache[K] {
 	cache := &lruCache[K]{
@@ -113,6 +151,30 @@ func newTypedXdsCache[K comparable]() typedXdsCache[K] {
 		evictQueue:       make([]evictKeyConfigs[K], 0, 1000),
 	}
 	cache.store = newLru(cache.onEvict)
+	cache.setName("")
+	return cache
+}
+
+// setName sets the name of the cache, labeling its metrics.
+func (l *lruCache[K]) setName(name string) {
+	l.name = name
+	l.hits = xdsCacheReads.With(typeTag.Value("hit"), cacheTag.Value(name))
+	l.misses = xdsCacheReads.With(typeTag.Value("miss"), cacheTag.Value(name))
+}
+
+// namedTypedXdsCache names the cache, and sets the kinds of config invalidating all its entries.
+func namedTypedXdsCache[K comparable](cache typedXdsCache[K], name string, clearAllKinds ...kind.Kind) typedXdsCache[K] {
+	if l, ok := cache.(*lruCache[K]); ok {
+		l.setName(name)
+		l.clearAllKinds = sets.New(clearAllKinds...)
+	}
+	return cache
+}
+
+// newDedupTypedXdsCache returns an instance of a cache where entries with byte-identical values share a single copy.
+func newDedupTypedXdsCache[K comparable]() typedXdsCache[K] {
+	cache := newTypedXdsCache[K]().(*lruCache[K])
+	cache.resources = newResourceStore()
 	return cache
 }
 
@@ -132,8 +194,27 @@ type lruCache[K comparable] struct {
 
 	evictQueue []evictKeyConfigs[K]
 
+	// resources deduplicates the cached values, if set.
+	resources *resourceStore
+
 	// mark whether a key is evicted on Clear call, passively.
 	evictedOnClear bool
+
+	// name labels the metrics of the cache.
+	name   string
+	hits   monitoring.Metric
+	misses monitoring.Metric
+	// clearAllKinds are the kinds of config invalidating all the entries, as they affect the entries without
+	// being part of their dependent configs.
+	clearAllKinds sets.Set[kind.Kind]
+	stats         cacheStats
+}
+
+// cacheStats records the reads and invalidations of a cache.
+type cacheStats struct {
+	hits, misses uint64
+	byKind       map[string]uint64
+	byConfig     map[ConfigKey]uint64
 }
 
 var _ typedXdsCache[uint64] = &lruCache[uint64]{}
@@ -185,6 +266,7 @@ func (l *lruCache[K]) onEvict(k K, v cacheValue) {
 		xdsCacheEvictionsOnSize.Increment()
 	}
 
+	l.resources.release(v.value)
 	// async clearing indexes
 	l.evictQueue = append(l.evictQueue, evictKeyConfigs[K]{k, v.dependentConfigs})
 }
@@ -261,6 +343,8 @@ func (l *lruCache[K]) Add(k K, entry dependents, pushReq *PushRequest, value *di
 		}
 	}
 
+	// The value replaced, if any, is released by onEvict.
+	value = l.resources.intern(value)
 	dependentConfigs := entry.DependentConfigs()
 	toWrite := cacheValue{value: value, token: token, dependentConfigs: dependentConfigs}
 	l.store.Add(k, toWrite)
@@ -291,17 +375,58 @@ func (l *lruCache[K]) get(key K, token CacheToken) *discovery.Resource {
 	defer l.mu.Unlock()
 	cv, ok := l.store.Get(key)
 	if !ok || cv.value == nil {
-		miss()
+		l.miss()
 		return nil
 	}
 	if cv.token >= token {
-		hit()
+		l.hit()
 		return cv.value
 	}
-	miss()
+	l.miss()
 	return nil
 }
 
+func (l *lruCache[K]) hit() {
+	l.stats.hits++
+	l.hits.Increment()
+}
+
+func (l *lruCache[K]) miss() {
+	l.stats.misses++
+	l.misses.Increment()
+}
+
+// recordInvalidations records the entries invalidated by the change of the config, if any. It must be called with
+// the lock held.
+func (l *lruCache[K]) recordInvalidations(cause string, config *ConfigKey, entries int) {
+	if entries == 0 {
+		return
+	}
+	xdsCacheInvalidations.With(cacheTag.Value(l.name), kindTag.Value(cause)).RecordInt(int64(entries))
+	if l.stats.byKind == nil {
+		l.stats.byKind = map[string]uint64{}
+	}
+	l.stats.byKind[cause] += uint64(entries)
+	if config == nil {
+		return
+	}
+	if l.stats.byConfig == nil {
+		l.stats.byConfig = map[ConfigKey]uint64{}
+	}
+	if _, f := l.stats.byConfig[*config]; !f && len(l.stats.byConfig) >= maxTrackedConfigs {
+		// Make room by forgetting the config with the fewest invalidations.
+		var least ConfigKey
+		fewest := uint64(math.MaxUint64)
+		for k, v := range l.stats.byConfig {
+			if v < fewest {
+				least, fewest = k, v
+			}
+		}
+		delete(l.stats.byConfig, least)
+	}
+	l.stats.byConfig[*config] += uint64(entries)
+}
+
 func (l *lruCache[K]) Clear(configs sets.Set[ConfigKey]) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -310,13 +435,32 @@ func (l *lruCache[K]) Clear(configs sets.Set[ConfigKey]) {
 	defer func() {
 		l.evictedOnClear = false
 	}()
+	var clearAllCauses []ConfigKey
+	for ckey := range configs {
+		if l.clearAllKinds.Contains(ckey.Kind) {
+			clearAllCauses = append(clearAllCauses, ckey)
+		}
+	}
+	if len(clearAllCauses) > 0 {
+		entries := l.store.Len()
+		l.clearAll()
+		for i, cause := range clearAllCauses {
+			l.recordInvalidations(cause.Kind.String(), &clearAllCauses[i], entries)
+		}
+		return
+	}
 	for ckey := range configs {
 		hc := ckey.HashCode()
 		referenced := l.configIndex[hc]
 		delete(l.configIndex, hc)
+		removed := 0
 		for key := range referenced {
-			l.store.Remove(key)
+			if l.store.Remove(key) {
+				removed++
+			}
 		}
+		ckey := ckey
+		l.recordInvalidations(ckey.Kind.String(), &ckey, removed)
 	}
 	size(l.store.Len())
 }
@@ -324,11 +468,18 @@ func (l *lruCache[K]) Clear(configs sets.Set[ConfigKey]) {
 func (l *lruCache[K]) ClearAll() {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	l.recordInvalidations(invalidationsOfAll, nil, l.store.Len())
+	l.clearAll()
+}
+
+// clearAll clears the entire cache. It must be called with the lock held.
+func (l *lruCache[K]) clearAll() {
 	l.token = CacheToken(time.Now().UnixNano())
 	// Purge with an evict function would turn up to be pretty slow since
 	// it runs the function for every key in the store, might be better to just
 	// create a new store.
 	l.store = newLru(l.onEvict)
+	l.resources.reset()
 	l.configIndex = map[ConfigHash]sets.Set[K]{}
 	l.evictQueue = l.evictQueue[:0:1000]
 	size(l.store.Len())
@@ -356,6 +507,33 @@ func (l *lruCache[K]) Snapshot() []*discovery.Resource {
 	return res
 }
 
+func (l *lruCache[K]) Stats() XdsCacheStats {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	stats := XdsCacheStats{
+		Hits:          l.stats.hits,
+		Misses:        l.stats.misses,
+		Invalidations: maps.Clone(l.stats.byKind),
+	}
+	if reads := stats.Hits + stats.Misses; reads > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(reads)
+	}
+	for config, entries := range l.stats.byConfig {
+		stats.TopInvalidations = append(stats.TopInvalidations, ConfigInvalidations{Config: config.String(), Entries: entries})
+	}
+	sort.Slice(stats.TopInvalidations, func(i, j int) bool {
+		a, b := stats.TopInvalidations[i], stats.TopInvalidations[j]
+		if a.Entries != b.Entries {
+			return a.Entries > b.Entries
+		}
+		return a.Config < b.Config
+	})
+	if len(stats.TopInvalidations) > topInvalidations {
+		stats.TopInvalidations = stats.TopInvalidations[:topInvalidations]
+	}
+	return stats
+}
+
 func (l *lruCache[K]) indexLength() int {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
@@ -394,3 +572,5 @@ func (d disabledCache[K]) ClearAll() {}
 func (d disabledCache[K]) Keys() []K { return nil }
 
 func (d disabledCache[K]) Snapshot() []*discovery.Resource { return nil }
+
+func (d disabledCache[K]) Stats() XdsCacheStats { return XdsCacheStats{} }
@@ -19,6 +19,7 @@ import (
 	"time"
 
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/types/known/anypb"
 
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pkg/config/schema/kind"
@@ -381,3 +382,87 @@ func TestCacheClearAll(t *testing.T) {
 	assert.Equal(t, cache.indexLength(), 0)
 	assert.Equal(t, cache.store.Len(), 0)
 }
+
+func TestDedupCache(t *testing.T) {
+	zeroTime := time.Time{}
+	req := &PushRequest{Start: zeroTime.Add(time.Duration(1))}
+	newer := &PushRequest{Start: zeroTime.Add(time.Duration(2))}
+	svc := ConfigKey{Kind: kind.Service, Name: "name", Namespace: "namespace"}
+	resource := func(value string) *discovery.Resource {
+		return &discovery.Resource{Name: "outbound|80||foo", Resource: &anypb.Any{TypeUrl: "eds", Value: []byte(value)}}
+	}
+	first := entry{key: "key1", dependentConfigs: []ConfigHash{svc.HashCode()}}
+	second := entry{key: "key2", dependentConfigs: []ConfigHash{svc.HashCode()}}
+	third := entry{key: "key3"}
+
+	c := newDedupTypedXdsCache[uint64]()
+	cache := c.(*lruCache[uint64])
+
+	r1 := resource("a")
+	c.Add(first.Key(), first, req, r1)
+	// Identical resources built for other keys share the first copy.
+	c.Add(second.Key(), second, req, resource("a"))
+	if c.Get(second.Key()) != r1 {
+		t.Fatalf("expected identical resources to be deduplicated")
+	}
+	c.Add(third.Key(), third, req, resource("b"))
+	assert.Equal(t, cache.resources.len(), 2)
+	assert.Equal(t, cache.resources.entries[resourceHash(r1)].refs, 2)
+
+	// Replacing an entry releases its reference.
+	c.Add(first.Key(), first, newer, resource("b"))
+	assert.Equal(t, cache.resources.entries[resourceHash(r1)].refs, 1)
+	assert.Equal(t, c.Get(first.Key()), c.Get(third.Key()))
+
+	// The copy is dropped with its last holder.
+	c.Clear(sets.New(svc))
+	assert.Equal(t, cache.resources.len(), 1)
+	assert.Equal(t, cache.resources.entries[resourceHash(r1)] == nil, true)
+
+	c.ClearAll()
+	assert.Equal(t, cache.resources.len(), 0)
+}
+
+func TestCacheStats(t *testing.T) {
+	zeroTime := time.Time{}
+	req := &PushRequest{Start: zeroTime.Add(time.Duration(1))}
+	newer := &PushRequest{Start: zeroTime.Add(time.Duration(2))}
+	res := &discovery.Resource{Name: "test"}
+	dr := ConfigKey{Kind: kind.DestinationRule, Name: "name", Namespace: "namespace"}
+	se := ConfigKey{Kind: kind.ServiceEntry, Name: "name", Namespace: "namespace"}
+	pa := ConfigKey{Kind: kind.PeerAuthentication, Name: "name", Namespace: "namespace"}
+	first := entry{key: "key1", dependentConfigs: []ConfigHash{dr.HashCode(), se.HashCode()}}
+	second := entry{key: "key2", dependentConfigs: []ConfigHash{se.HashCode()}}
+
+	c := namedTypedXdsCache(newTypedXdsCache[uint64](), EDSType, kind.PeerAuthentication)
+	c.Add(first.Key(), first, req, res)
+	c.Add(second.Key(), second, req, res)
+	c.Get(first.Key())
+	c.Get(first.Key())
+	c.Get(entry{key: "unknown"}.Key())
+
+	c.Clear(sets.New(se))
+	c.Add(first.Key(), first, newer, res)
+	c.Clear(sets.New(dr))
+	c.Add(first.Key(), first, newer, res)
+	c.Add(second.Key(), second, newer, res)
+	// The authentication policies affect all the entries.
+	c.Clear(sets.New(pa))
+	assert.Equal(t, c.Get(second.Key()) == nil, true)
+
+	assert.Equal(t, c.Stats(), XdsCacheStats{
+		Hits:    2,
+		Misses:  2,
+		HitRate: 0.5,
+		Invalidations: map[string]uint64{
+			kind.ServiceEntry.String():       2,
+			kind.DestinationRule.String():    1,
+			kind.PeerAuthentication.String(): 2,
+		},
+		TopInvalidations: []ConfigInvalidations{
+			{Config: pa.String(), Entries: 2},
+			{Config: se.String(), Entries: 2},
+			{Config: dr.String(), Entries: 1},
+		},
+	})
+}
@@ -0,0 +1,142 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"strings"
+
+	"istio.io/istio/pkg/config/labels"
+)
+
+// WaypointSelector returns the canonical form of the istio.io/for-selector annotation of a waypoint, a comma separated
+// list of key=value labels, so that equivalent selectors result in the same WaypointScope. Invalid selectors are
+// returned unchanged, and select no workload.
+func WaypointSelector(annotation string) string {
+	if annotation == "" {
+		return ""
+	}
+	selector, ok := parseWaypointSelector(annotation)
+	if !ok {
+		return annotation
+	}
+	return selector.String()
+}
+
+func parseWaypointSelector(selector string) (labels.Instance, bool) {
+	out := labels.Instance{}
+	for _, kv := range strings.Split(selector, ",") {
+		k, v, found := strings.Cut(strings.TrimSpace(kv), "=")
+		if !found || k == "" {
+			return nil, false
+		}
+		out[k] = v
+	}
+	return out, true
+}
+
+// Matches returns true if the scope includes the workload of the given namespace, service account and labels,
+// regardless of the other waypoints of the namespace.
+func (s WaypointScope) Matches(namespace, serviceAccount string, workloadLabels labels.Instance) bool {
+	if s.Selector == "" {
+		return s.matches(nil, namespace, serviceAccount, workloadLabels)
+	}
+	selector, ok := parseWaypointSelector(s.Selector)
+	return ok && s.matches(selector, namespace, serviceAccount, workloadLabels)
+}
+
+// matches is Matches with the parsed selector of the scope.
+func (s WaypointScope) matches(selector labels.Instance, namespace, serviceAccount string, workloadLabels labels.Instance) bool {
+	if s.Namespace != namespace {
+		return false
+	}
+	if s.ServiceAccount != "" && s.ServiceAccount != serviceAccount {
+		return false
+	}
+	return selector.SubsetOf(workloadLabels)
+}
+
+// WaypointScopes indexes the scopes of the waypoints by namespace, with their parsed selectors, so that the waypoint
+// owning a workload is resolved among the waypoints of its namespace only, without parsing their selectors again.
+// The zero value is ready to use. It is not safe for concurrent use.
+type WaypointScopes struct {
+	// byNamespace holds the parsed selectors of the scopes, nil for the scopes without selector. The scopes with an
+	// invalid selector are not indexed, as they select no workload.
+	byNamespace map[string]map[WaypointScope]labels.Instance
+}
+
+// Insert adds the scope of a waypoint to the index.
+func (w *WaypointScopes) Insert(scope WaypointScope) {
+	var selector labels.Instance
+	if scope.Selector != "" {
+		var ok bool
+		if selector, ok = parseWaypointSelector(scope.Selector); !ok {
+			return
+		}
+	}
+	if w.byNamespace == nil {
+		w.byNamespace = map[string]map[WaypointScope]labels.Instance{}
+	}
+	scopes := w.byNamespace[scope.Namespace]
+	if scopes == nil {
+		scopes = map[WaypointScope]labels.Instance{}
+		w.byNamespace[scope.Namespace] = scopes
+	}
+	scopes[scope] = selector
+}
+
+// Delete removes the scope of a waypoint from the index.
+func (w *WaypointScopes) Delete(scope WaypointScope) {
+	scopes := w.byNamespace[scope.Namespace]
+	delete(scopes, scope)
+	if len(scopes) == 0 {
+		delete(w.byNamespace, scope.Namespace)
+	}
+}
+
+// Matches is WaypointScope.Matches, with the parsed selector of the scope if it is indexed.
+func (w *WaypointScopes) Matches(scope WaypointScope, namespace, serviceAccount string, workloadLabels labels.Instance) bool {
+	if selector, f := w.byNamespace[scope.Namespace][scope]; f {
+		return scope.matches(selector, namespace, serviceAccount, workloadLabels)
+	}
+	return scope.Matches(namespace, serviceAccount, workloadLabels)
+}
+
+// Resolve returns the scope of the waypoint owning the workload of the given namespace, service account and labels.
+// When several waypoints match, the waypoint of the service account of the workload wins, then the waypoint with the
+// most specific selector, the one with the most labels, ties being broken by the order of the selectors, and finally
+// the namespace-wide waypoint.
+func (w *WaypointScopes) Resolve(namespace, serviceAccount string, workloadLabels labels.Instance) (WaypointScope, bool) {
+	scopes := w.byNamespace[namespace]
+	if _, f := scopes[WaypointScope{Namespace: namespace, ServiceAccount: serviceAccount}]; f && serviceAccount != "" {
+		return WaypointScope{Namespace: namespace, ServiceAccount: serviceAccount}, true
+	}
+	var best WaypointScope
+	bestLabels := 0
+	for scope, selector := range scopes {
+		if selector == nil || scope.ServiceAccount != "" || !scope.matches(selector, namespace, serviceAccount, workloadLabels) {
+			continue
+		}
+		if n := len(selector); n > bestLabels || (n == bestLabels && scope.Selector < best.Selector) {
+			best, bestLabels = scope, n
+		}
+	}
+	if bestLabels > 0 {
+		return best, true
+	}
+	if _, f := scopes[WaypointScope{Namespace: namespace}]; f {
+		return WaypointScope{Namespace: namespace}, true
+	}
+	return WaypointScope{}, false
+}
@@ -0,0 +1,83 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestWaypointSelector(t *testing.T) {
+	assert.Equal(t, WaypointSelector(""), "")
+	assert.Equal(t, WaypointSelector("version=v2, app=a"), "app=a,version=v2")
+	// Invalid selectors are kept as is, and match nothing.
+	assert.Equal(t, WaypointSelector("app"), "app")
+	assert.Equal(t, WaypointScope{Namespace: "ns", Selector: "app"}.Matches("ns", "sa", labels.Instance{"app": ""}), false)
+}
+
+func TestWaypointScopesResolve(t *testing.T) {
+	namespace := WaypointScope{Namespace: "ns"}
+	sa := WaypointScope{Namespace: "ns", ServiceAccount: "sa1"}
+	app := WaypointScope{Namespace: "ns", Selector: "app=a"}
+	version := WaypointScope{Namespace: "ns", Selector: "version=v2"}
+	appVersion := WaypointScope{Namespace: "ns", Selector: "app=a,version=v2"}
+	resolve := func(waypoints []WaypointScope, serviceAccount string, workloadLabels labels.Instance) WaypointScope {
+		scopes := &WaypointScopes{}
+		for _, w := range waypoints {
+			scopes.Insert(w)
+		}
+		scope, _ := scopes.Resolve("ns", serviceAccount, workloadLabels)
+		return scope
+	}
+	cases := []struct {
+		name      string
+		waypoints []WaypointScope
+		sa        string
+		labels    labels.Instance
+		want      WaypointScope
+	}{
+		{"no waypoint", nil, "sa1", labels.Instance{"app": "a"}, WaypointScope{}},
+		{"namespace", []WaypointScope{namespace}, "sa1", labels.Instance{"app": "a"}, namespace},
+		{"selector over namespace", []WaypointScope{namespace, app}, "sa2", labels.Instance{"app": "a"}, app},
+		{"selector not matching", []WaypointScope{namespace, app}, "sa2", labels.Instance{"app": "b"}, namespace},
+		{"service account over selector", []WaypointScope{namespace, app, sa}, "sa1", labels.Instance{"app": "a"}, sa},
+		{"most specific selector", []WaypointScope{app, appVersion}, "sa2", labels.Instance{"app": "a", "version": "v2"}, appVersion},
+		{"ties broken by selector", []WaypointScope{version, app}, "sa2", labels.Instance{"app": "a", "version": "v2"}, app},
+		{"other namespace", []WaypointScope{{Namespace: "other"}}, "sa1", nil, WaypointScope{}},
+		{"invalid selector", []WaypointScope{namespace, {Namespace: "ns", Selector: "app"}}, "sa2", labels.Instance{"app": ""}, namespace},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, resolve(tt.waypoints, tt.sa, tt.labels), tt.want)
+		})
+	}
+}
+
+func TestWaypointScopesDelete(t *testing.T) {
+	app := WaypointScope{Namespace: "ns", Selector: "app=a"}
+	scopes := &WaypointScopes{}
+	scopes.Insert(WaypointScope{Namespace: "ns"})
+	scopes.Insert(app)
+	owner, _ := scopes.Resolve("ns", "sa", labels.Instance{"app": "a"})
+	assert.Equal(t, owner, app)
+
+	// Once the waypoint of the selector is deleted, the namespace-wide waypoint owns its workloads.
+	scopes.Delete(app)
+	owner, _ = scopes.Resolve("ns", "sa", labels.Instance{"app": "a"})
+	assert.Equal(t, owner, WaypointScope{Namespace: "ns"})
+	assert.Equal(t, scopes.Matches(app, "ns", "sa", labels.Instance{"app": "a"}), true)
+}
@@ -51,6 +51,8 @@ type XdsCache interface {
 	Keys(t string) []any
 	// Snapshot returns a snapshot of all values. This is for testing/debug only
 	Snapshot() []*discovery.Resource
+	// Stats returns the reads and invalidations of the cache for the type. This is for debug only
+	Stats(t string) XdsCacheStats
 }
 
 // XdsCacheEntry interface defines functions that should be implemented by
@@ -80,18 +82,23 @@ func NewXdsCache() XdsCache {
 	cache := XdsCacheImpl{
 		eds: newTypedXdsCache[uint64](),
 	}
+	if features.EnableEDSDeduplication {
+		cache.eds = newDedupTypedXdsCache[uint64]()
+	}
+	// The EDS cache is keyed by the version of the authentication policies, which are not dependent configs.
+	cache.eds = namedTypedXdsCache(cache.eds, EDSType, kind.PeerAuthentication)
 	if features.EnableCDSCaching {
-		cache.cds = newTypedXdsCache[uint64]()
+		cache.cds = namedTypedXdsCache(newTypedXdsCache[uint64](), CDSType)
 	} else {
 		cache.cds = disabledCache[uint64]{}
 	}
 	if features.EnableRDSCaching {
-		cache.rds = newTypedXdsCache[uint64]()
+		cache.rds = namedTypedXdsCache(newTypedXdsCache[uint64](), RDSType)
 	} else {
 		cache.rds = disabledCache[uint64]{}
 	}
 
-	cache.sds = newTypedXdsCache[string]()
+	cache.sds = namedTypedXdsCache(newTypedXdsCache[string](), SDSType)
 
 	return cache
 }
@@ -165,12 +172,7 @@ func (x XdsCacheImpl) Get(entry XdsCacheEntry) *discovery.Resource {
 
 func (x XdsCacheImpl) Clear(s sets.Set[ConfigKey]) {
 	x.cds.Clear(s)
-	// clear all EDS cache for PA change
-	if HasConfigsOfKind(s, kind.PeerAuthentication) {
-		x.eds.ClearAll()
-	} else {
-		x.eds.Clear(s)
-	}
+	x.eds.Clear(s)
 	x.rds.Clear(s)
 	x.sds.Clear(s)
 }
@@ -201,6 +203,21 @@ func (x XdsCacheImpl) Keys(t string) []any {
 	}
 }
 
+func (x XdsCacheImpl) Stats(t string) XdsCacheStats {
+	switch t {
+	case CDSType:
+		return x.cds.Stats()
+	case EDSType:
+		return x.eds.Stats()
+	case SDSType:
+		return x.sds.Stats()
+	case RDSType:
+		return x.rds.Stats()
+	default:
+		return XdsCacheStats{}
+	}
+}
+
 func convertToAnySlices[K comparable](in []K) []any {
 	out := make([]any, len(in))
 	for i, k := range in {
@@ -245,4 +262,8 @@ func (d DisabledCache) Snapshot() []*discovery.Resource {
 	return nil
 }
 
+func (d DisabledCache) Stats(t string) XdsCacheStats {
+	return XdsCacheStats{}
+}
+
 var _ XdsCache = &DisabledCache{}
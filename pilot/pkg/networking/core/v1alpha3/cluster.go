@@ -36,6 +36,7 @@ import (
 	"istio.io/istio/pilot/pkg/serviceregistry/provider"
 	"istio.io/istio/pilot/pkg/util/protoconv"
 	"istio.io/istio/pilot/pkg/xds/endpoints"
+	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/protocol"
 	"istio.io/istio/pkg/config/schema/kind"
@@ -214,6 +215,9 @@ func (configgen *ConfigGeneratorImpl) buildClusters(proxy *model.Proxy, req *mod
 		resources = append(resources, ob...)
 		// Add a blackhole and passthrough cluster for catching traffic to unresolved routes
 		clusters = outboundPatcher.conditionallyAppend(clusters, nil, cb.buildBlackHoleCluster(), cb.buildDefaultPassthroughCluster())
+		if passthroughEndpointsEnabled(proxy) {
+			clusters = outboundPatcher.conditionallyAppend(clusters, nil, cb.buildPassthroughEndpointsCluster())
+		}
 		clusters = append(clusters, outboundPatcher.insertedClusters()...)
 		// Setup inbound clusters
 		inboundPatcher := clusterPatcher{efw: envoyFilterPatches, pctx: networking.EnvoyFilter_SIDECAR_INBOUND}
@@ -319,7 +323,7 @@ func (configgen *ConfigGeneratorImpl) buildOutboundClusters(cb *ClusterBuilder,
 			// if the service uses persistent sessions, override status allows
 			// DRAINING endpoints to be kept as 'UNHEALTHY' coarse status in envoy.
 			// Will not be used for normal traffic, only when explicit override.
-			if service.Attributes.Labels[features.PersistentSessionLabel] != "" {
+			if service.PersistentSession() {
 				// Default is UNKNOWN, HEALTHY, DEGRADED. Without this change, Envoy will drop endpoints with any other
 				// status received in EDS. With this setting, the DRAINING and UNHEALTHY endpoints are kept - both marked
 				// as UNHEALTHY ('coarse state'), which is what will show in config dumps.
@@ -413,15 +417,12 @@ func (configgen *ConfigGeneratorImpl) buildOutboundSniDnatClusters(proxy *model.
 		}
 
 		destRule := proxy.SidecarScope.DestinationRule(model.TrafficDirectionOutbound, proxy, service.Hostname)
-		for _, port := range service.Ports {
-			if port.Protocol == protocol.UDP {
-				continue
-			}
+		for _, ports := range sniDnatPortRanges(service, destRule.GetRule()) {
+			port := ports[0]
 
 			// create default cluster
 			discoveryType := convertResolution(cb.proxyType, service)
-			clusterName := model.BuildDNSSrvSubsetKey(model.TrafficDirectionOutbound, "",
-				service.Hostname, port.Port)
+			clusterName := sniDnatClusterName(service.Hostname, ports)
 
 			var lbEndpoints []*endpoint.LocalityLbEndpoints
 			var endpointBuilder *endpoints.EndpointBuilder
@@ -437,6 +438,10 @@ func (configgen *ConfigGeneratorImpl) buildOutboundSniDnatClusters(proxy *model.
 			if defaultCluster == nil {
 				continue
 			}
+			if len(ports) > 1 {
+				// The listeners select the endpoints of the port through their load balancer metadata.
+				defaultCluster.cluster.LbSubsetConfig = sniDnatPortRangeSubsetConfig
+			}
 			subsetClusters := cb.applyDestinationRule(defaultCluster, SniDnatClusterMode, service, port, endpointBuilder, destRule.GetRule(), nil)
 			clusters = cp.conditionallyAppend(clusters, nil, defaultCluster.build())
 			clusters = cp.conditionallyAppend(clusters, nil, subsetClusters...)
@@ -446,6 +451,58 @@ func (configgen *ConfigGeneratorImpl) buildOutboundSniDnatClusters(proxy *model.
 	return clusters
 }
 
+var sniDnatPortRangeSubsetConfig = &cluster.Cluster_LbSubsetConfig{
+	FallbackPolicy: cluster.Cluster_LbSubsetConfig_NO_FALLBACK,
+	SubsetSelectors: []*cluster.Cluster_LbSubsetConfig_LbSubsetSelector{{
+		Keys: []string{util.LbPortMetadataKey},
+	}},
+}
+
+// sniDnatPortRanges groups the ports of the service, excluding UDP, into the ports served by each SNI-DNAT cluster.
+// Each port has its own cluster, unless features.EnableSNIDNATPortRanges is set: then consecutive ports with the
+// same protocol share a cluster. Services resolved by DNS, or with DestinationRule subsets or port level settings
+// that could not be applied to a shared cluster, keep one cluster per port.
+func sniDnatPortRanges(service *model.Service, destRule *config.Config) [][]*model.Port {
+	ports := slices.Filter(service.Ports, func(p *model.Port) bool {
+		return p.Protocol != protocol.UDP
+	})
+	perPort := !features.EnableSNIDNATPortRanges || service.Resolution == model.DNSLB || service.Resolution == model.DNSRoundRobinLB
+	if destRule != nil {
+		if dr, ok := destRule.Spec.(*networking.DestinationRule); ok &&
+			(len(dr.GetSubsets()) > 0 || len(dr.GetTrafficPolicy().GetPortLevelSettings()) > 0) {
+			perPort = true
+		}
+	}
+	if perPort {
+		return slices.Map(ports, func(p *model.Port) []*model.Port {
+			return []*model.Port{p}
+		})
+	}
+	ports = slices.SortFunc(slices.Clone(ports), func(a, b *model.Port) bool {
+		return a.Port < b.Port
+	})
+	var out [][]*model.Port
+	for _, p := range ports {
+		if n := len(out); n > 0 {
+			last := out[n-1][len(out[n-1])-1]
+			if p.Port == last.Port+1 && p.Protocol == last.Protocol {
+				out[n-1] = append(out[n-1], p)
+				continue
+			}
+		}
+		out = append(out, []*model.Port{p})
+	}
+	return out
+}
+
+// sniDnatClusterName returns the name of the SNI-DNAT cluster serving the ports, as grouped by sniDnatPortRanges.
+func sniDnatClusterName(hostname host.Name, ports []*model.Port) string {
+	if len(ports) == 1 {
+		return model.BuildDNSSrvSubsetKey(model.TrafficDirectionOutbound, "", hostname, ports[0].Port)
+	}
+	return model.BuildDNSSrvSubsetKeyPortRange(model.TrafficDirectionOutbound, "", hostname, ports[0].Port, ports[len(ports)-1].Port)
+}
+
 func buildInboundLocalityLbEndpoints(bind string, port uint32) []*endpoint.LocalityLbEndpoints {
 	if bind == "" {
 		return nil
@@ -692,6 +749,12 @@ type buildClusterOpts struct {
 	serviceRegistry provider.ID
 	// Indicates if the destionationRule has a workloadSelector
 	isDrWithSelector bool
+	// Indicates if the destinationRule requests client side weighted round robin load balancing
+	clientSideWRR bool
+	// panicThreshold overrides the healthy panic threshold, if set.
+	panicThreshold *float64
+	// overprovisioningFactor overrides the overprovisioning factor of the endpoints inlined in the cluster, if set.
+	overprovisioningFactor *uint32
 }
 
 func applyTCPKeepalive(mesh *meshconfig.MeshConfig, c *cluster.Cluster, tcp *networking.ConnectionPoolSettings_TCPSettings) {
@@ -28,6 +28,7 @@ import (
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/structpb"
 
+	meshconfig "istio.io/api/mesh/v1alpha1"
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
@@ -39,6 +40,7 @@ import (
 	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/security"
@@ -93,9 +95,11 @@ type ClusterBuilder struct {
 	hbone              bool                  // Does the proxy support HBONE
 	locality           *core.Locality        // Locality information of proxy.
 	proxyLabels        map[string]string     // Proxy labels.
-	proxyView          model.ProxyView       // Proxy view of endpoints.
-	proxyIPAddresses   []string              // IP addresses on which proxy is listening on.
-	configNamespace    string                // Proxy config namespace.
+	// meshSettings replaces the meshConfig settings changing the endpoints during a progressive rollout.
+	meshSettings     *model.EndpointMeshSettings
+	proxyView        model.ProxyView // Proxy view of endpoints.
+	proxyIPAddresses []string        // IP addresses on which proxy is listening on.
+	configNamespace  string          // Proxy config namespace.
 	// PushRequest to look for updates.
 	req                   *model.PushRequest
 	cache                 model.XdsCache
@@ -116,6 +120,7 @@ func NewClusterBuilder(proxy *model.Proxy, req *model.PushRequest, cache model.X
 		hbone:              proxy.EnableHBONE() || proxy.IsWaypointProxy(),
 		locality:           proxy.Locality,
 		proxyLabels:        proxy.Labels,
+		meshSettings:       proxy.EndpointMeshSettings,
 		proxyView:          proxy.GetView(),
 		proxyIPAddresses:   proxy.IPAddresses,
 		configNamespace:    proxy.ConfigNamespace,
@@ -149,6 +154,15 @@ func newClusterWrapper(cluster *cluster.Cluster) *clusterWrapper {
 	}
 }
 
+// meshLocalityLbSetting returns the locality load balancing settings of the meshConfig, or the ones the proxy keeps
+// while a change to them is rolled out progressively.
+func (cb *ClusterBuilder) meshLocalityLbSetting(mesh *meshconfig.MeshConfig) *networking.LocalityLoadBalancerSetting {
+	if cb.meshSettings != nil {
+		return cb.meshSettings.LocalityLbSetting
+	}
+	return mesh.GetLocalityLbSetting()
+}
+
 // sidecarProxy returns true if the clusters are being built for sidecar proxy otherwise false.
 func (cb *ClusterBuilder) sidecarProxy() bool {
 	return cb.proxyType == model.SidecarProxy
@@ -207,8 +221,10 @@ func (cb *ClusterBuilder) buildSubsetCluster(
 	cb.applyMetadataExchange(opts.mutable.cluster)
 
 	// Add the DestinationRule+subsets metadata. Metadata here is generated on a per-cluster
-	// basis in buildCluster, so we can just insert without a copy.
-	subsetCluster.cluster.Metadata = util.AddConfigInfoMetadata(subsetCluster.cluster.Metadata, destRule.Meta)
+	// basis in buildCluster, so we can just insert without a copy. Direct pod clusters may not have a DestinationRule.
+	if destRule != nil {
+		subsetCluster.cluster.Metadata = util.AddConfigInfoMetadata(subsetCluster.cluster.Metadata, destRule.Meta)
+	}
 	util.AddSubsetToMetadata(subsetCluster.cluster.Metadata, subset.Name)
 	subsetCluster.cluster.Metadata = util.AddALPNOverrideToMetadata(subsetCluster.cluster.Metadata, opts.policy.GetTls().GetMode())
 	return subsetCluster.build()
@@ -221,7 +237,12 @@ func (cb *ClusterBuilder) applyDestinationRule(mc *clusterWrapper, clusterMode C
 ) []*cluster.Cluster {
 	destinationRule := CastDestinationRule(destRule)
 	// merge applicable port level traffic policy settings
-	trafficPolicy := util.MergeTrafficPolicy(nil, destinationRule.GetTrafficPolicy(), port)
+	trafficPolicy := util.EffectiveTrafficPolicy(destinationRule, "", port)
+	if trafficPolicy.GetLoadBalancer() == nil {
+		if lb := model.SessionAffinityLoadBalancer(service); lb != nil {
+			trafficPolicy = util.MergeTrafficPolicy(trafficPolicy, &networking.TrafficPolicy{LoadBalancer: lb}, port)
+		}
+	}
 	opts := buildClusterOpts{
 		mesh:           cb.req.Push.Mesh,
 		serviceTargets: cb.serviceTargets,
@@ -242,6 +263,13 @@ func (cb *ClusterBuilder) applyDestinationRule(mc *clusterWrapper, clusterMode C
 
 	if destRule != nil {
 		opts.isDrWithSelector = destinationRule.GetWorkloadSelector() != nil
+		opts.clientSideWRR = destRule.Annotations[constants.ClientSideWeightedRoundRobinAnnotation] == "true"
+		if threshold, ok := model.PanicThreshold(destRule); ok {
+			opts.panicThreshold = &threshold
+		}
+		if factor, ok := model.OverprovisioningFactor(destRule); ok {
+			opts.overprovisioningFactor = &factor
+		}
 	}
 	// Apply traffic policy for the main default cluster.
 	cb.applyTrafficPolicy(opts)
@@ -266,12 +294,36 @@ func (cb *ClusterBuilder) applyDestinationRule(mc *clusterWrapper, clusterMode C
 		mc.cluster.Metadata = util.AddALPNOverrideToMetadata(mc.cluster.Metadata, opts.policy.GetTls().GetMode())
 	}
 	subsetClusters := make([]*cluster.Cluster, 0)
+	if clusterMode == DefaultClusterMode && mc.cluster.GetType() == cluster.Cluster_EDS &&
+		model.SubsetLoadBalancer(destRule, service, port) {
+		// The subsets are selected by the HTTP routes through the metadata of the endpoints of the default cluster.
+		mc.cluster.LbSubsetConfig = withSubsetSelectors(mc.cluster.LbSubsetConfig, destinationRule.GetSubsets())
+	}
+	// The subset clusters are kept for the references which cannot select the subsets through metadata, such as
+	// mirrors, TCP routes and proxyless gRPC.
 	for _, subset := range destinationRule.GetSubsets() {
 		subsetCluster := cb.buildSubsetCluster(opts, destRule, subset, service, eb)
 		if subsetCluster != nil {
 			subsetClusters = append(subsetClusters, subsetCluster)
 		}
 	}
+	if subset, _, ok := model.MirrorTo(destRule); ok {
+		// The shadow cluster receiving the mirrored traffic selects the same endpoints as the subset.
+		shadow := proto.Clone(subset).(*networking.Subset)
+		shadow.Name = model.MirrorSubsetName
+		if shadowCluster := cb.buildSubsetCluster(opts, destRule, shadow, service, eb); shadowCluster != nil {
+			subsetClusters = append(subsetClusters, shadowCluster)
+		}
+	}
+	if clusterMode == DefaultClusterMode && mc.cluster.GetType() == cluster.Cluster_EDS {
+		// Each endpoint of services with direct pod clusters gets its own cluster, with the policy of the destination.
+		for _, address := range cb.req.Push.DirectPodAddresses(service, port.Port) {
+			pod := &networking.Subset{Name: model.DirectPodSubset(address)}
+			if podCluster := cb.buildSubsetCluster(opts, destRule, pod, service, eb); podCluster != nil {
+				subsetClusters = append(subsetClusters, podCluster)
+			}
+		}
+	}
 	return subsetClusters
 }
 
@@ -335,10 +387,49 @@ func (cb *ClusterBuilder) buildCluster(name string, discoveryType cluster.Cluste
 				string(service.Hostname), "", port, 0, &service.Attributes)
 		}
 	}
+	if direction == model.TrafficDirectionOutbound && discoveryType == cluster.Cluster_EDS && hasEndpointCohorts(service) {
+		c.LbSubsetConfig = proto.Clone(endpointCohortSubsetConfig).(*cluster.Cluster_LbSubsetConfig)
+	}
 
 	return ec
 }
 
+// endpointCohortSubsetConfig allows requests to select the endpoints of a cohort through their load balancer
+// metadata. Requests without a cohort, or with an unknown one, are sent to any endpoint.
+var endpointCohortSubsetConfig = &cluster.Cluster_LbSubsetConfig{
+	FallbackPolicy: cluster.Cluster_LbSubsetConfig_ANY_ENDPOINT,
+	SubsetSelectors: []*cluster.Cluster_LbSubsetConfig_LbSubsetSelector{{
+		Keys: []string{util.LbCohortMetadataKey},
+	}},
+}
+
+// withSubsetSelectors returns the subset load balancer config with a selector per DestinationRule subset added.
+// Requests without a subset are sent to any endpoint, while requests to a subset without endpoints fail.
+func withSubsetSelectors(config *cluster.Cluster_LbSubsetConfig, subsets []*networking.Subset) *cluster.Cluster_LbSubsetConfig {
+	if len(subsets) == 0 {
+		return config
+	}
+	if config == nil {
+		config = &cluster.Cluster_LbSubsetConfig{FallbackPolicy: cluster.Cluster_LbSubsetConfig_ANY_ENDPOINT}
+	} else {
+		// The config may be shared with other clusters.
+		config = proto.Clone(config).(*cluster.Cluster_LbSubsetConfig)
+	}
+	for _, subset := range subsets {
+		config.SubsetSelectors = append(config.SubsetSelectors, &cluster.Cluster_LbSubsetConfig_LbSubsetSelector{
+			Keys:           []string{util.LbSubsetMetadataPrefix + subset.GetName()},
+			FallbackPolicy: cluster.Cluster_LbSubsetConfig_LbSubsetSelector_NO_FALLBACK,
+		})
+	}
+	return config
+}
+
+// hasEndpointCohorts returns true if the endpoints of the service are partitioned into cohorts.
+func hasEndpointCohorts(service *model.Service) bool {
+	return features.EnableEndpointCohorts && features.EndpointCohortLabel != "" && service != nil &&
+		service.Attributes.Labels[features.EndpointCohortLabel] != ""
+}
+
 // buildInboundCluster constructs a single inbound cluster. The cluster will be bound to
 // `inbound|clusterPort||`, and send traffic to <bind>:<instance.Endpoint.EndpointPort>. A workload
 // will have a single inbound cluster per port. In general this works properly, with the exception of
@@ -492,6 +583,34 @@ func (cb *ClusterBuilder) buildDefaultPassthroughCluster() *cluster.Cluster {
 	return cluster
 }
 
+// buildPassthroughEndpointsCluster generates a cluster holding the endpoints of the ServiceEntries, each selected
+// through its address by the filter chain of the virtual outbound listener forwarding traffic to it, so passthrough
+// traffic to them carries their metadata.
+func (cb *ClusterBuilder) buildPassthroughEndpointsCluster() *cluster.Cluster {
+	c := &cluster.Cluster{
+		Name:                 util.PassthroughEndpointsCluster,
+		ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_EDS},
+		ConnectTimeout:       proto.Clone(cb.req.Push.Mesh.ConnectTimeout).(*durationpb.Duration),
+		LbPolicy:             cluster.Cluster_ROUND_ROBIN,
+		LbSubsetConfig:       passthroughEndpointsSubsetConfig,
+	}
+	maybeApplyEdsConfig(c)
+	cb.applyConnectionPool(cb.req.Push.Mesh, newClusterWrapper(c), &networking.ConnectionPoolSettings{})
+	cb.applyMetadataExchange(c)
+	return c
+}
+
+// passthroughEndpointsSubsetConfig selects the endpoint of the filter chain of its address. The filter chains and the
+// endpoints of a push are built from the same endpoints, and the endpoints are always healthy, so the subset of a
+// filter chain is only empty between the removal of its endpoint and that of the filter chain, pushed right after.
+// Falling back to another endpoint would send the traffic to the wrong address.
+var passthroughEndpointsSubsetConfig = &cluster.Cluster_LbSubsetConfig{
+	FallbackPolicy: cluster.Cluster_LbSubsetConfig_NO_FALLBACK,
+	SubsetSelectors: []*cluster.Cluster_LbSubsetConfig_LbSubsetSelector{{
+		Keys: []string{util.LbAddressMetadataKey},
+	}},
+}
+
 // setH2Options make the cluster an h2 cluster by setting http2ProtocolOptions.
 func setH2Options(mc *clusterWrapper) {
 	if mc == nil {
@@ -56,6 +56,7 @@ import (
 	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/network"
 	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/slices"
 	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/test/util/assert"
 )
@@ -1048,6 +1049,95 @@ func TestBuildDefaultCluster(t *testing.T) {
 	}
 }
 
+func TestApplyDestinationRuleMirrorTo(t *testing.T) {
+	cg := NewConfigGenTest(t, TestOptions{})
+	proxy := cg.SetupProxy(nil)
+	cb := NewClusterBuilder(proxy, &model.PushRequest{Push: cg.PushContext()}, nil)
+	port := &model.Port{Name: "http", Port: 8080, Protocol: protocol.HTTP}
+	service := &model.Service{
+		Ports:      model.PortList{port},
+		Hostname:   "reviews.default.svc.cluster.local",
+		Attributes: model.ServiceAttributes{Name: "reviews", Namespace: "default"},
+	}
+	dr := &config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.DestinationRule,
+			Name:             "reviews",
+			Namespace:        "default",
+			Annotations:      map[string]string{constants.MirrorToAnnotation: "canary"},
+		},
+		Spec: &networking.DestinationRule{
+			Host:    "reviews.default.svc.cluster.local",
+			Subsets: []*networking.Subset{{Name: "canary", Labels: map[string]string{"track": "canary"}}},
+		},
+	}
+	clusterName := "outbound|8080||reviews.default.svc.cluster.local"
+	defaultCluster := cb.buildCluster(clusterName, cluster.Cluster_EDS, nil, model.TrafficDirectionOutbound, port, service, nil)
+	eb := endpoints.NewCDSEndpointBuilder(proxy, cb.req.Push, clusterName, model.TrafficDirectionOutbound, "",
+		service.Hostname, port.Port, service, model.ConvertConsolidatedDestRule(dr))
+	subsetClusters := cb.applyDestinationRule(defaultCluster, DefaultClusterMode, service, port, eb, dr, nil)
+	names := slices.Map(subsetClusters, func(c *cluster.Cluster) string { return c.Name })
+	assert.Equal(t, names, []string{
+		"outbound|8080|canary|reviews.default.svc.cluster.local",
+		"outbound|8080|istio-mirror|reviews.default.svc.cluster.local",
+	})
+}
+
+func TestClusterBuilderEndpointMeshSettings(t *testing.T) {
+	cg := NewConfigGenTest(t, TestOptions{})
+	proxy := cg.SetupProxy(nil)
+	push := cg.PushContext()
+	cb := NewClusterBuilder(proxy, &model.PushRequest{Push: push}, nil)
+	assert.Equal(t, cb.meshLocalityLbSetting(push.Mesh), push.Mesh.GetLocalityLbSetting())
+
+	// The proxies kept on the previous settings during a rollout get clusters built and cached with them.
+	baseline := &model.EndpointMeshSettings{
+		LocalityLbSetting: &networking.LocalityLoadBalancerSetting{Enabled: wrappers.Bool(false)},
+		Version:           "baseline/v1",
+	}
+	proxy.EndpointMeshSettings = baseline
+	baselineCb := NewClusterBuilder(proxy, &model.PushRequest{Push: push}, nil)
+	assert.Equal(t, baselineCb.meshLocalityLbSetting(push.Mesh), baseline.LocalityLbSetting)
+
+	port := &model.Port{Name: "http", Port: 8080, Protocol: protocol.HTTP}
+	service := &model.Service{
+		Ports:      model.PortList{port},
+		Hostname:   "host",
+		Attributes: model.ServiceAttributes{Name: "svc", Namespace: "default"},
+	}
+	key, baselineKey := buildClusterKey(service, port, cb, proxy, nil), buildClusterKey(service, port, baselineCb, proxy, nil)
+	if key.Key() == baselineKey.Key() {
+		t.Fatalf("expected different cache keys for the clusters built with different mesh settings")
+	}
+}
+
+func TestBuildClusterEndpointCohorts(t *testing.T) {
+	test.SetForTest(t, &features.EnableEndpointCohorts, true)
+	cg := NewConfigGenTest(t, TestOptions{})
+	cb := NewClusterBuilder(cg.SetupProxy(nil), &model.PushRequest{Push: cg.PushContext()}, nil)
+	port := &model.Port{Name: "http", Port: 8080, Protocol: protocol.HTTP}
+	service := func(labels map[string]string) *model.Service {
+		return &model.Service{
+			Ports:      model.PortList{port},
+			Hostname:   "host",
+			Attributes: model.ServiceAttributes{Name: "svc", Namespace: "default", Labels: labels},
+		}
+	}
+	cohorts := service(map[string]string{features.EndpointCohortLabel: "enabled"})
+
+	c := cb.buildCluster("outbound|8080||host", cluster.Cluster_EDS, nil, model.TrafficDirectionOutbound, port, cohorts, nil)
+	assert.Equal(t, c.cluster.LbSubsetConfig, endpointCohortSubsetConfig)
+	if c.cluster.LbSubsetConfig == endpointCohortSubsetConfig {
+		t.Fatalf("expected a copy of the endpoint cohort subset config")
+	}
+
+	c = cb.buildCluster("outbound|8080||host", cluster.Cluster_EDS, nil, model.TrafficDirectionOutbound, port, service(nil), nil)
+	assert.Equal(t, c.cluster.LbSubsetConfig, nil)
+
+	c = cb.buildCluster("inbound|8080||", cluster.Cluster_ORIGINAL_DST, nil, model.TrafficDirectionInbound, port, cohorts, nil)
+	assert.Equal(t, c.cluster.LbSubsetConfig, nil)
+}
+
 func TestBuildLocalityLbEndpoints(t *testing.T) {
 	proxy := &model.Proxy{
 		Metadata: &model.NodeMetadata{
@@ -1698,6 +1788,57 @@ func TestConcurrentBuildLocalityLbEndpoints(t *testing.T) {
 	}
 }
 
+func TestBuildLocalityLbEndpointsPriorities(t *testing.T) {
+	proxy := &model.Proxy{Metadata: &model.NodeMetadata{ClusterID: "cluster-1"}}
+	servicePort := &model.Port{Name: "default", Port: 8080, Protocol: protocol.HTTP}
+	service := &model.Service{
+		Hostname:     host.Name("static.example.org"),
+		Ports:        model.PortList{servicePort},
+		Attributes:   model.ServiceAttributes{Name: "static", Namespace: "test-ns"},
+		MeshExternal: true,
+		Resolution:   model.DNSLB,
+	}
+	instance := func(address string, labels map[string]string) *model.ServiceInstance {
+		return &model.ServiceInstance{
+			Service:     service,
+			ServicePort: servicePort,
+			Endpoint: &model.IstioEndpoint{
+				Address:      address,
+				EndpointPort: 10001,
+				Namespace:    "test-ns",
+				Labels:       labels,
+				Locality:     model.Locality{ClusterID: "cluster-1", Label: "region1/zone1/subzone1"},
+			},
+		}
+	}
+	cg := NewConfigGenTest(t, TestOptions{
+		MeshConfig: testMesh(),
+		Services:   []*model.Service{service},
+		Instances: []*model.ServiceInstance{
+			instance("192.168.1.1", nil),
+			instance("192.168.1.2", map[string]string{constants.StandbyLabel: "true"}),
+		},
+	})
+	cb := NewClusterBuilder(cg.SetupProxy(proxy), &model.PushRequest{Push: cg.PushContext()}, nil)
+	eb := endpoints.NewCDSEndpointBuilder(
+		proxy, cb.req.Push,
+		"outbound|8080||static.example.org",
+		model.TrafficDirectionOutbound, "", "static.example.org", 8080,
+		service, nil,
+	)
+
+	// The standby endpoints of static clusters follow the other endpoints, at the next priority.
+	var got []string
+	for _, llb := range eb.FromServiceEndpoints() {
+		for _, lb := range llb.LbEndpoints {
+			got = append(got, fmt.Sprintf("%s:%d", lb.GetEndpoint().GetAddress().GetSocketAddress().GetAddress(), llb.Priority))
+		}
+	}
+	if want := []string{"192.168.1.1:0", "192.168.1.2:1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
 func TestBuildPassthroughClusters(t *testing.T) {
 	cases := []struct {
 		name         string
@@ -45,6 +45,7 @@ type clusterCache struct {
 	hbone           bool
 	proxyView       model.ProxyView
 	metadataCerts   *metadataCerts // metadata certificates of proxy
+	meshSettings    *model.EndpointMeshSettings
 	endpointBuilder *endpoints.EndpointBuilder
 
 	// service attributes
@@ -97,6 +98,11 @@ func (t *clusterCache) Key() any {
 	}
 	h.Write(Separator)
 
+	if t.meshSettings != nil {
+		h.Write([]byte(t.meshSettings.Version))
+	}
+	h.Write(Separator)
+
 	if t.service != nil {
 		h.Write([]byte(t.service.Hostname))
 		h.Write(Slash)
@@ -135,7 +141,7 @@ func (t *clusterCache) Key() any {
 
 func (t *clusterCache) DependentConfigs() []model.ConfigHash {
 	drs := t.destinationRule.GetFrom()
-	configs := make([]model.ConfigHash, 0, len(drs)+1+len(t.envoyFilterKeys))
+	configs := make([]model.ConfigHash, 0, len(drs)+2+len(t.envoyFilterKeys))
 	if t.destinationRule != nil {
 		for _, dr := range drs {
 			configs = append(configs, model.ConfigKey{Kind: kind.DestinationRule, Name: dr.Name, Namespace: dr.Namespace}.HashCode())
@@ -143,6 +149,9 @@ func (t *clusterCache) DependentConfigs() []model.ConfigHash {
 	}
 	if t.service != nil {
 		configs = append(configs, model.ConfigKey{Kind: kind.ServiceEntry, Name: string(t.service.Hostname), Namespace: t.service.Attributes.Namespace}.HashCode())
+		if origin := t.service.Attributes.ClusterSetOrigin; origin != "" {
+			configs = append(configs, model.ConfigKey{Kind: kind.ServiceEntry, Name: string(origin), Namespace: t.service.Attributes.Namespace}.HashCode())
+		}
 	}
 	for _, efKey := range t.envoyFilterKeys {
 		items := strings.Split(efKey, "/")
@@ -202,6 +211,7 @@ func buildClusterKey(service *model.Service, port *model.Port, cb *ClusterBuilde
 		destinationRule: dr,
 		envoyFilterKeys: efKeys,
 		metadataCerts:   cb.metadataCerts,
+		meshSettings:    cb.meshSettings,
 		peerAuthVersion: cb.req.Push.AuthnPolicies.GetVersion(),
 		serviceAccounts: cb.req.Push.ServiceAccounts(service.Hostname, service.Attributes.Namespace, port.Port),
 		endpointBuilder: eb,
@@ -27,6 +27,7 @@ import (
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	http "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
+	xdstype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/google/go-cmp/cmp"
 	. "github.com/onsi/gomega"
 	"google.golang.org/protobuf/proto"
@@ -51,6 +52,7 @@ import (
 	"istio.io/istio/pkg/config/schema/kind"
 	"istio.io/istio/pkg/security"
 	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
 	"istio.io/istio/pkg/util/sets"
 )
 
@@ -1953,7 +1955,7 @@ func TestApplyLoadBalancer(t *testing.T) {
 				test.SetForTest(t, &features.EnableRedisFilter, true)
 			}
 
-			applyLoadBalancer(c, tt.lbSettings, tt.port, proxy.Locality, nil, &meshconfig.MeshConfig{})
+			applyLoadBalancer(c, tt.lbSettings, tt.port, proxy.Locality, nil, nil)
 
 			if c.LbPolicy != tt.expectedLbPolicy {
 				t.Errorf("cluster LbPolicy %s != expected %s", c.LbPolicy, tt.expectedLbPolicy)
@@ -1973,6 +1975,123 @@ func TestApplyLoadBalancer(t *testing.T) {
 	}
 }
 
+func TestApplyClientSideWeightedRoundRobin(t *testing.T) {
+	testcases := []struct {
+		name             string
+		lbPolicy         cluster.Cluster_LbPolicy
+		discoveryType    cluster.Cluster_DiscoveryType
+		localityWeighted bool
+		expectedPolicy   string
+	}{
+		{
+			name:           "least request",
+			lbPolicy:       cluster.Cluster_LEAST_REQUEST,
+			discoveryType:  cluster.Cluster_EDS,
+			expectedPolicy: clientSideWeightedRoundRobinPolicy,
+		},
+		{
+			name:             "locality weighted",
+			lbPolicy:         cluster.Cluster_ROUND_ROBIN,
+			discoveryType:    cluster.Cluster_EDS,
+			localityWeighted: true,
+			expectedPolicy:   wrrLocalityPolicy,
+		},
+		{
+			name:          "ring hash is preserved",
+			lbPolicy:      cluster.Cluster_RING_HASH,
+			discoveryType: cluster.Cluster_EDS,
+		},
+		{
+			name:          "original dst is preserved",
+			lbPolicy:      cluster.Cluster_CLUSTER_PROVIDED,
+			discoveryType: cluster.Cluster_ORIGINAL_DST,
+		},
+	}
+	for _, tt := range testcases {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &cluster.Cluster{
+				ClusterDiscoveryType: &cluster.Cluster_Type{Type: tt.discoveryType},
+				LbPolicy:             tt.lbPolicy,
+				CommonLbConfig:       &cluster.Cluster_CommonLbConfig{},
+			}
+			if tt.localityWeighted {
+				c.CommonLbConfig.LocalityConfigSpecifier = &cluster.Cluster_CommonLbConfig_LocalityWeightedLbConfig_{
+					LocalityWeightedLbConfig: &cluster.Cluster_CommonLbConfig_LocalityWeightedLbConfig{},
+				}
+			}
+			applyClientSideWeightedRoundRobin(c)
+			policies := c.GetLoadBalancingPolicy().GetPolicies()
+			if tt.expectedPolicy == "" {
+				if len(policies) != 0 {
+					t.Fatalf("expected no load balancing policy, got %v", policies)
+				}
+				return
+			}
+			if len(policies) != 1 || policies[0].GetTypedExtensionConfig().GetName() != tt.expectedPolicy {
+				t.Fatalf("expected load balancing policy %s, got %v", tt.expectedPolicy, policies)
+			}
+			if c.CommonLbConfig.GetLocalityWeightedLbConfig() != nil {
+				t.Fatalf("expected locality weighted config to be moved into the load balancing policy")
+			}
+		})
+	}
+}
+
+func TestApplyPanicThreshold(t *testing.T) {
+	zero, half := 0.0, 50.0
+	testcases := []struct {
+		name            string
+		threshold       *float64
+		sendUnhealthy   bool
+		expectThreshold *xdstype.Percent
+	}{
+		{
+			name:            "outlier detection threshold is kept",
+			expectThreshold: &xdstype.Percent{Value: 30},
+		},
+		{
+			name:            "fail fast",
+			threshold:       &zero,
+			expectThreshold: &xdstype.Percent{Value: 0},
+		},
+		{
+			name:            "spray everywhere",
+			threshold:       &half,
+			expectThreshold: &xdstype.Percent{Value: 50},
+		},
+		{
+			name:            "unhealthy endpoints sent",
+			threshold:       &half,
+			sendUnhealthy:   true,
+			expectThreshold: &xdstype.Percent{Value: 30},
+		},
+	}
+	for _, tt := range testcases {
+		t.Run(tt.name, func(t *testing.T) {
+			test.SetAtomicBoolForTest(t, features.SendUnhealthyEndpoints, tt.sendUnhealthy)
+			c := &cluster.Cluster{
+				CommonLbConfig: &cluster.Cluster_CommonLbConfig{HealthyPanicThreshold: &xdstype.Percent{Value: 30}},
+			}
+			applyPanicThreshold(c, tt.threshold)
+			assert.Equal(t, c.CommonLbConfig.HealthyPanicThreshold, tt.expectThreshold)
+		})
+	}
+}
+
+func TestApplyOverprovisioningFactor(t *testing.T) {
+	factor := uint32(200)
+	// The EDS clusters are left to their ClusterLoadAssignment.
+	eds := &cluster.Cluster{}
+	applyOverprovisioningFactor(eds, &factor)
+	assert.Equal(t, eds.LoadAssignment, nil)
+
+	static := &cluster.Cluster{LoadAssignment: &endpoint.ClusterLoadAssignment{ClusterName: "static"}}
+	applyOverprovisioningFactor(static, nil)
+	assert.Equal(t, static.LoadAssignment.Policy, nil)
+	applyOverprovisioningFactor(static, &factor)
+	assert.Equal(t, static.LoadAssignment.Policy.GetOverprovisioningFactor().GetValue(), factor)
+}
+
 func TestBuildStaticClusterWithNoEndPoint(t *testing.T) {
 	g := NewWithT(t)
 
@@ -3154,3 +3273,45 @@ func TestBuildStaticClusterWithCredentialSocket(t *testing.T) {
 		"BlackHoleCluster", "InboundPassthroughClusterIpv4", "PassthroughCluster",
 	}))
 }
+
+func TestSniDnatPortRanges(t *testing.T) {
+	svc := &model.Service{
+		Hostname:   "kafka.ns.svc.cluster.local",
+		Resolution: model.ClientSideLB,
+		Ports: model.PortList{
+			{Name: "broker-2", Port: 9094, Protocol: protocol.TCP},
+			{Name: "broker-0", Port: 9092, Protocol: protocol.TCP},
+			{Name: "broker-1", Port: 9093, Protocol: protocol.TCP},
+			{Name: "http", Port: 9095, Protocol: protocol.HTTP},
+			{Name: "dns", Port: 9096, Protocol: protocol.UDP},
+			{Name: "metrics", Port: 15020, Protocol: protocol.TCP},
+		},
+	}
+	names := func(dr *config.Config) []string {
+		var out []string
+		for _, ports := range sniDnatPortRanges(svc, dr) {
+			out = append(out, sniDnatClusterName(svc.Hostname, ports))
+		}
+		return out
+	}
+	perPort := []string{
+		"outbound_.9094_._.kafka.ns.svc.cluster.local",
+		"outbound_.9092_._.kafka.ns.svc.cluster.local",
+		"outbound_.9093_._.kafka.ns.svc.cluster.local",
+		"outbound_.9095_._.kafka.ns.svc.cluster.local",
+		"outbound_.15020_._.kafka.ns.svc.cluster.local",
+	}
+	assert.Equal(t, names(nil), perPort)
+
+	test.SetForTest(t, &features.EnableSNIDNATPortRanges, true)
+	assert.Equal(t, names(nil), []string{
+		"outbound_.9092-9094_._.kafka.ns.svc.cluster.local",
+		"outbound_.9095_._.kafka.ns.svc.cluster.local",
+		"outbound_.15020_._.kafka.ns.svc.cluster.local",
+	})
+	subsets := &config.Config{Spec: &networking.DestinationRule{
+		Host:    "kafka.ns.svc.cluster.local",
+		Subsets: []*networking.Subset{{Name: "v1", Labels: map[string]string{"version": "v1"}}},
+	}}
+	assert.Equal(t, names(subsets), perPort)
+}
@@ -16,10 +16,12 @@ package v1alpha3
 
 import (
 	"fmt"
+	"strings"
 
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	internalupstream "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/internal_upstream/v3"
+	proxyprotocol "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/proxy_protocol/v3"
 	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	http "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
 	metadata "github.com/envoyproxy/go-control-plane/envoy/type/metadata/v3"
@@ -35,8 +37,11 @@ import (
 	"istio.io/istio/pilot/pkg/serviceregistry/provider"
 	"istio.io/istio/pilot/pkg/util/protoconv"
 	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/maps"
 	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/slices"
 )
 
 var istioMtlsTransportSocketMatch = &structpb.Struct{
@@ -380,6 +385,119 @@ func defaultUpstreamCommonTLSContext() *tlsv3.CommonTlsContext {
 	}
 }
 
+// applyProxyProtocolTransportSocketMatches sends the PROXY protocol header to the endpoints labeled with
+// networking.istio.io/proxy-protocol. For each version, every transport socket match of the cluster is copied
+// ahead of the original ones, additionally matching the proxyProtocol endpoint metadata, with its transport socket
+// wrapped in the upstream PROXY protocol transport socket. HBONE endpoints are left alone.
+func applyProxyProtocolTransportSocketMatches(c *cluster.Cluster) {
+	if c.GetType() != cluster.Cluster_EDS {
+		return
+	}
+	matches := c.TransportSocketMatches
+	if len(matches) == 0 {
+		ts := c.TransportSocket
+		if ts == nil {
+			ts = xdsfilters.RawBufferTransportSocket
+		}
+		c.TransportSocket = nil
+		matches = []*cluster.Cluster_TransportSocketMatch{{
+			Name:            "default",
+			Match:           &structpb.Struct{},
+			TransportSocket: ts,
+		}}
+	}
+	out := make([]*cluster.Cluster_TransportSocketMatch, 0, 3*len(matches))
+	for _, version := range []string{constants.ProxyProtocolV1, constants.ProxyProtocolV2} {
+		for _, m := range matches {
+			if _, f := m.GetMatch().GetFields()[model.TunnelLabelShortName]; f {
+				continue
+			}
+			match := &structpb.Struct{Fields: map[string]*structpb.Value{
+				model.ProxyProtocolShortname: structpb.NewStringValue(version),
+			}}
+			for k, v := range m.GetMatch().GetFields() {
+				match.Fields[k] = v
+			}
+			out = append(out, &cluster.Cluster_TransportSocketMatch{
+				Name:            m.Name + "-proxy-protocol-" + version,
+				Match:           match,
+				TransportSocket: proxyProtocolTransportSocket(version, m.TransportSocket),
+			})
+		}
+	}
+	c.TransportSocketMatches = append(out, matches...)
+}
+
+// applyClusterSNITransportSocketMatches overrides the SNI of the Istio mutual TLS connections to the network gateways
+// of other clusters, following the SNI formats of PILOT_CROSS_NETWORK_SNI_OVERRIDES. For each cluster, every TLS
+// transport socket match of the cluster is copied ahead of the original ones, additionally matching the sniCluster
+// endpoint metadata, with the SNI of its TLS context rewritten.
+func applyClusterSNITransportSocketMatches(c *cluster.Cluster, formats map[string]string) {
+	if c.GetType() != cluster.Cluster_EDS || len(formats) == 0 {
+		return
+	}
+	matches := c.TransportSocketMatches
+	if len(matches) == 0 {
+		if c.TransportSocket == nil {
+			return
+		}
+		matches = []*cluster.Cluster_TransportSocketMatch{{
+			Name:            "default",
+			Match:           &structpb.Struct{},
+			TransportSocket: c.TransportSocket,
+		}}
+	}
+	var out []*cluster.Cluster_TransportSocketMatch
+	for _, clusterID := range slices.Sort(maps.Keys(formats)) {
+		for _, m := range matches {
+			if m.GetTransportSocket().GetName() != wellknown.TransportSocketTls {
+				continue
+			}
+			tlsContext := &tlsv3.UpstreamTlsContext{}
+			if err := m.TransportSocket.GetTypedConfig().UnmarshalTo(tlsContext); err != nil {
+				continue
+			}
+			tlsContext.Sni = strings.ReplaceAll(formats[clusterID], "{sni}", tlsContext.Sni)
+			match := &structpb.Struct{Fields: map[string]*structpb.Value{
+				model.SNIClusterShortname: structpb.NewStringValue(clusterID),
+			}}
+			for k, v := range m.GetMatch().GetFields() {
+				match.Fields[k] = v
+			}
+			out = append(out, &cluster.Cluster_TransportSocketMatch{
+				Name:  m.Name + "-sni-" + clusterID,
+				Match: match,
+				TransportSocket: &core.TransportSocket{
+					Name:       wellknown.TransportSocketTls,
+					ConfigType: &core.TransportSocket_TypedConfig{TypedConfig: protoconv.MessageToAny(tlsContext)},
+				},
+			})
+		}
+	}
+	if len(out) == 0 {
+		return
+	}
+	c.TransportSocket = nil
+	c.TransportSocketMatches = append(out, matches...)
+}
+
+func proxyProtocolTransportSocket(version string, ts *core.TransportSocket) *core.TransportSocket {
+	if ts == nil {
+		ts = xdsfilters.RawBufferTransportSocket
+	}
+	v := core.ProxyProtocolConfig_V1
+	if version == constants.ProxyProtocolV2 {
+		v = core.ProxyProtocolConfig_V2
+	}
+	return &core.TransportSocket{
+		Name: "envoy.transport_sockets.upstream_proxy_protocol",
+		ConfigType: &core.TransportSocket_TypedConfig{TypedConfig: protoconv.MessageToAny(&proxyprotocol.ProxyProtocolUpstreamTransport{
+			Config:          &core.ProxyProtocolConfig{Version: v},
+			TransportSocket: ts,
+		})},
+	}
+}
+
 // defaultTransportSocketMatch applies to endpoints that have no security.istio.io/tlsMode label
 // or those whose label value does not match "istio"
 func defaultTransportSocketMatch() *cluster.Cluster_TransportSocketMatch {
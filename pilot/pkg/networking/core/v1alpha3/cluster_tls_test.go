@@ -23,6 +23,7 @@ import (
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"github.com/google/go-cmp/cmp"
 	"google.golang.org/protobuf/testing/protocmp"
 	"google.golang.org/protobuf/types/known/durationpb"
@@ -34,6 +35,7 @@ import (
 	"istio.io/istio/pilot/pkg/networking/util"
 	authn_model "istio.io/istio/pilot/pkg/security/model"
 	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+	"istio.io/istio/pilot/pkg/util/protoconv"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/test/util/assert"
@@ -276,6 +278,89 @@ type expectedResult struct {
 	err        error
 }
 
+func TestApplyProxyProtocolTransportSocketMatches(t *testing.T) {
+	names := func(c *cluster.Cluster) []string {
+		var out []string
+		for _, m := range c.TransportSocketMatches {
+			out = append(out, m.Name)
+		}
+		return out
+	}
+
+	// A single transport socket is turned into matches.
+	c := &cluster.Cluster{ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_EDS}}
+	applyProxyProtocolTransportSocketMatches(c)
+	assert.Equal(t, names(c), []string{"default-proxy-protocol-v1", "default-proxy-protocol-v2", "default"})
+	assert.Equal(t, c.TransportSocket, nil)
+	assert.Equal(t, c.TransportSocketMatches[1].Match.Fields[model.ProxyProtocolShortname].GetStringValue(), "v2")
+
+	// Auto mTLS matches are preserved in order, and HBONE is left alone.
+	c = &cluster.Cluster{
+		ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_EDS},
+		TransportSocketMatches: []*cluster.Cluster_TransportSocketMatch{
+			hboneTransportSocket,
+			{Name: "tlsMode-istio", Match: istioMtlsTransportSocketMatch},
+			defaultTransportSocketMatch(),
+		},
+	}
+	applyProxyProtocolTransportSocketMatches(c)
+	assert.Equal(t, names(c), []string{
+		"tlsMode-istio-proxy-protocol-v1", "tlsMode-disabled-proxy-protocol-v1",
+		"tlsMode-istio-proxy-protocol-v2", "tlsMode-disabled-proxy-protocol-v2",
+		"hbone", "tlsMode-istio", "tlsMode-disabled",
+	})
+	assert.Equal(t, len(c.TransportSocketMatches[0].Match.Fields), 2)
+	assert.Equal(t, len(istioMtlsTransportSocketMatch.Fields), 1)
+
+	// Endpoints of clusters not using EDS carry no metadata.
+	c = &cluster.Cluster{ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_ORIGINAL_DST}}
+	applyProxyProtocolTransportSocketMatches(c)
+	assert.Equal(t, len(c.TransportSocketMatches), 0)
+}
+
+func TestApplyClusterSNITransportSocketMatches(t *testing.T) {
+	mtls := &core.TransportSocket{
+		Name:       wellknown.TransportSocketTls,
+		ConfigType: &core.TransportSocket_TypedConfig{TypedConfig: protoconv.MessageToAny(&tls.UpstreamTlsContext{Sni: "outbound_.80_._.foo"})},
+	}
+	sni := func(m *cluster.Cluster_TransportSocketMatch) string {
+		tlsContext := &tls.UpstreamTlsContext{}
+		assert.NoError(t, m.TransportSocket.GetTypedConfig().UnmarshalTo(tlsContext))
+		return tlsContext.Sni
+	}
+	formats := map[string]string{"cluster-b": "{sni}.v2", "cluster-a": "a.{sni}"}
+
+	// Auto mTLS: only the TLS matches are copied, per cluster.
+	c := &cluster.Cluster{
+		ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_EDS},
+		TransportSocketMatches: []*cluster.Cluster_TransportSocketMatch{
+			{Name: "tlsMode-istio", Match: istioMtlsTransportSocketMatch, TransportSocket: mtls},
+			defaultTransportSocketMatch(),
+		},
+	}
+	applyClusterSNITransportSocketMatches(c, formats)
+	assert.Equal(t, len(c.TransportSocketMatches), 4)
+	assert.Equal(t, c.TransportSocketMatches[0].Name, "tlsMode-istio-sni-cluster-a")
+	assert.Equal(t, sni(c.TransportSocketMatches[0]), "a.outbound_.80_._.foo")
+	assert.Equal(t, c.TransportSocketMatches[1].Match.Fields[model.SNIClusterShortname].GetStringValue(), "cluster-b")
+	assert.Equal(t, c.TransportSocketMatches[1].Match.Fields[model.TLSModeLabelShortname].GetStringValue(), model.IstioMutualTLSModeLabel)
+	assert.Equal(t, sni(c.TransportSocketMatches[1]), "outbound_.80_._.foo.v2")
+	assert.Equal(t, sni(c.TransportSocketMatches[2]), "outbound_.80_._.foo")
+	assert.Equal(t, len(istioMtlsTransportSocketMatch.Fields), 1)
+
+	// An explicit transport socket is turned into matches.
+	c = &cluster.Cluster{ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_EDS}, TransportSocket: mtls}
+	applyClusterSNITransportSocketMatches(c, map[string]string{"cluster-b": "{sni}.v2"})
+	assert.Equal(t, c.TransportSocket, nil)
+	assert.Equal(t, len(c.TransportSocketMatches), 2)
+	assert.Equal(t, c.TransportSocketMatches[1].Name, "default")
+
+	// Plaintext clusters are left alone.
+	c = &cluster.Cluster{ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_EDS}}
+	applyClusterSNITransportSocketMatches(c, formats)
+	assert.Equal(t, len(c.TransportSocketMatches), 0)
+}
+
 // TestBuildUpstreamClusterTLSContext tests the buildUpstreamClusterTLSContext function
 func TestBuildUpstreamClusterTLSContext(t *testing.T) {
 	clientCert := "/path/to/cert"
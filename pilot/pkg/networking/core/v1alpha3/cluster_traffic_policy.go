@@ -19,6 +19,9 @@ import (
 
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	cswrr "github.com/envoyproxy/go-control-plane/envoy/extensions/load_balancing_policies/client_side_weighted_round_robin/v3"
+	wrrlocality "github.com/envoyproxy/go-control-plane/envoy/extensions/load_balancing_policies/wrr_locality/v3"
 	http "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
 	xdstype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/golang/protobuf/ptypes/duration"
@@ -32,6 +35,7 @@ import (
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/loadbalancer"
+	"istio.io/istio/pilot/pkg/util/protoconv"
 	"istio.io/istio/pkg/config/protocol"
 	"istio.io/istio/pkg/log"
 )
@@ -48,12 +52,23 @@ func (cb *ClusterBuilder) applyTrafficPolicy(opts buildClusterOpts) {
 	if opts.direction != model.TrafficDirectionInbound {
 		cb.applyH2Upgrade(opts.mutable, opts.port, opts.mesh, connectionPool)
 		applyOutlierDetection(opts.mutable.cluster, outlierDetection)
-		applyLoadBalancer(opts.mutable.cluster, loadBalancer, opts.port, cb.locality, cb.proxyLabels, opts.mesh)
+		applyLoadBalancer(opts.mutable.cluster, loadBalancer, opts.port, cb.locality, cb.proxyLabels, cb.meshLocalityLbSetting(opts.mesh))
+		if opts.clientSideWRR {
+			applyClientSideWeightedRoundRobin(opts.mutable.cluster)
+		}
+		applyPanicThreshold(opts.mutable.cluster, opts.panicThreshold)
+		applyOverprovisioningFactor(opts.mutable.cluster, opts.overprovisioningFactor)
 		if opts.clusterMode != SniDnatClusterMode {
 			autoMTLSEnabled := opts.mesh.GetEnableAutoMtls().Value
 			tls, mtlsCtxType := cb.buildUpstreamTLSSettings(tls, opts.serviceAccounts, opts.istioMtlsSni,
 				autoMTLSEnabled, opts.meshExternal, opts.serviceMTLSMode)
 			cb.applyUpstreamTLSSettings(&opts, tls, mtlsCtxType)
+			if tls.GetMode() == networking.ClientTLSSettings_ISTIO_MUTUAL {
+				applyClusterSNITransportSocketMatches(opts.mutable.cluster, features.CrossNetworkSNIOverrides)
+			}
+			if features.EnableEndpointProxyProtocol {
+				applyProxyProtocolTransportSocketMatches(opts.mutable.cluster)
+			}
 		}
 	}
 
@@ -209,14 +224,14 @@ func (cb *ClusterBuilder) applyDefaultConnectionPool(cluster *cluster.Cluster) {
 }
 
 func applyLoadBalancer(c *cluster.Cluster, lb *networking.LoadBalancerSettings, port *model.Port,
-	locality *core.Locality, proxyLabels map[string]string, meshConfig *meshconfig.MeshConfig,
+	locality *core.Locality, proxyLabels map[string]string, meshLocalityLbSetting *networking.LocalityLoadBalancerSetting,
 ) {
 	// Disable panic threshold when SendUnhealthyEndpoints is enabled as enabling it "may" send traffic to unready
 	// end points when load balancer is in panic mode.
 	if features.SendUnhealthyEndpoints.Load() {
 		c.CommonLbConfig.HealthyPanicThreshold = &xdstype.Percent{Value: 0}
 	}
-	localityLbSetting := loadbalancer.GetLocalityLbSetting(meshConfig.GetLocalityLbSetting(), lb.GetLocalityLbSetting())
+	localityLbSetting := loadbalancer.GetLocalityLbSetting(meshLocalityLbSetting, lb.GetLocalityLbSetting())
 	if localityLbSetting != nil {
 		c.CommonLbConfig.LocalityConfigSpecifier = &cluster.Cluster_CommonLbConfig_LocalityWeightedLbConfig_{
 			LocalityWeightedLbConfig: &cluster.Cluster_CommonLbConfig_LocalityWeightedLbConfig{},
@@ -268,6 +283,67 @@ func applyLocalityLBSetting(locality *core.Locality, proxyLabels map[string]stri
 	}
 }
 
+const (
+	clientSideWeightedRoundRobinPolicy = "envoy.load_balancing_policies.client_side_weighted_round_robin"
+	wrrLocalityPolicy                  = "envoy.load_balancing_policies.wrr_locality"
+)
+
+// applyClientSideWeightedRoundRobin configures the cluster to use Envoy's client side weighted round robin load balancer,
+// which weights endpoints based on the utilization they report in ORCA load reports. When locality weighted load balancing
+// is in use, the policy is wrapped in the wrr_locality policy, as the common locality weighted config is ignored with
+// load_balancing_policy.
+func applyClientSideWeightedRoundRobin(c *cluster.Cluster) {
+	if c.GetType() == cluster.Cluster_ORIGINAL_DST {
+		return
+	}
+	if c.LbPolicy != cluster.Cluster_ROUND_ROBIN && c.LbPolicy != cluster.Cluster_LEAST_REQUEST {
+		// Only override the simple, weight agnostic policies; consistent hashing must keep its semantics.
+		return
+	}
+	policy := &cluster.LoadBalancingPolicy{
+		Policies: []*cluster.LoadBalancingPolicy_Policy{{
+			TypedExtensionConfig: &core.TypedExtensionConfig{
+				Name:        clientSideWeightedRoundRobinPolicy,
+				TypedConfig: protoconv.MessageToAny(&cswrr.ClientSideWeightedRoundRobin{}),
+			},
+		}},
+	}
+	if c.GetCommonLbConfig().GetLocalityWeightedLbConfig() != nil {
+		c.CommonLbConfig.LocalityConfigSpecifier = nil
+		policy = &cluster.LoadBalancingPolicy{
+			Policies: []*cluster.LoadBalancingPolicy_Policy{{
+				TypedExtensionConfig: &core.TypedExtensionConfig{
+					Name:        wrrLocalityPolicy,
+					TypedConfig: protoconv.MessageToAny(&wrrlocality.WrrLocality{EndpointPickingPolicy: policy}),
+				},
+			}},
+		}
+	}
+	c.LbConfig = nil
+	c.LoadBalancingPolicy = policy
+}
+
+// applyPanicThreshold overrides the healthy panic threshold of the cluster, including the one derived from the
+// outlier detection minHealthPercent. Panic mode stays disabled when unhealthy endpoints are sent to the proxies.
+func applyPanicThreshold(c *cluster.Cluster, threshold *float64) {
+	if threshold == nil || features.SendUnhealthyEndpoints.Load() {
+		return
+	}
+	if c.CommonLbConfig == nil {
+		c.CommonLbConfig = &cluster.Cluster_CommonLbConfig{}
+	}
+	c.CommonLbConfig.HealthyPanicThreshold = &xdstype.Percent{Value: *threshold}
+}
+
+// applyOverprovisioningFactor sets the overprovisioning factor of the endpoints inlined in the STATIC and DNS
+// clusters. The EDS clusters get it from their ClusterLoadAssignment, see EndpointBuilder.
+func applyOverprovisioningFactor(c *cluster.Cluster, factor *uint32) {
+	if factor == nil || c.LoadAssignment == nil {
+		return
+	}
+	c.LoadAssignment.Policy = &endpoint.ClusterLoadAssignment_Policy{OverprovisioningFactor: wrapperspb.UInt32(*factor)}
+}
+
 // applySimpleDefaultLoadBalancer will set the DefaultLBPolicy and create an LbConfig if used in LoadBalancerSettings
 func applySimpleDefaultLoadBalancer(c *cluster.Cluster, loadbalancer *networking.LoadBalancerSettings) {
 	c.LbPolicy = defaultLBAlgorithm()
@@ -255,6 +255,7 @@ func (f *ConfigGenTest) SetupProxy(p *model.Proxy) *model.Proxy {
 	p.SetSidecarScope(pc)
 	p.SetServiceTargets(f.env.ServiceDiscovery)
 	p.SetGatewaysForProxy(pc)
+	p.SetTelemetryEndpointMetadataKeys(pc)
 	p.DiscoverIPMode()
 	return p
 }
@@ -31,6 +31,7 @@ import (
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	anypb "github.com/golang/protobuf/ptypes/any"
 	"github.com/hashicorp/go-multierror"
+	"google.golang.org/protobuf/types/known/structpb"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	networking "istio.io/api/networking/v1alpha3"
@@ -459,6 +460,9 @@ func (configgen *ConfigGeneratorImpl) buildGatewayHTTPRouteConfig(node *model.Pr
 					log.Debugf("%s omitting routes for virtual service %v/%v due to error: %v", node.ID, virtualService.Namespace, virtualService.Name, err)
 					continue
 				}
+				istio_route.ApplyMirrorTo(node, routes)
+				istio_route.ApplyRetryHostPredicates(node, routes)
+				istio_route.ApplySubsetLoadBalancer(node, routes)
 				gatewayRoutes[gatewayName][vskey] = routes
 			}
 			// This is the service that is exposed on gateway using VirtualService.
@@ -949,41 +953,51 @@ func builtAutoPassthroughFilterChains(push *model.PushContext, proxy *model.Prox
 		if service.MeshExternal {
 			continue
 		}
-		for _, port := range service.Ports {
-			if port.Protocol == protocol.UDP {
-				continue
+		matchFound := false
+		for _, h := range hosts {
+			if service.Hostname.SubsetOf(host.Name(h)) {
+				matchFound = true
+				break
 			}
-			matchFound := false
-			for _, h := range hosts {
-				if service.Hostname.SubsetOf(host.Name(h)) {
-					matchFound = true
-					break
+		}
+		if !matchFound {
+			continue
+		}
+		rawDestinationRule := proxy.SidecarScope.DestinationRule(model.TrafficDirectionOutbound, proxy, service.Hostname).GetRule()
+		destinationRule := CastDestinationRule(rawDestinationRule)
+		for _, ports := range sniDnatPortRanges(service, rawDestinationRule) {
+			// Clusters serving a port range select the endpoints of the port through their load balancer metadata.
+			clusterName := sniDnatClusterName(service.Hostname, ports)
+			for _, port := range ports {
+				sni := model.BuildDNSSrvSubsetKey(model.TrafficDirectionOutbound, "", service.Hostname, port.Port)
+				statPrefix := sni
+				if len(push.Mesh.OutboundClusterStatName) != 0 {
+					statPrefix = telemetry.BuildStatPrefix(push.Mesh.OutboundClusterStatName, string(service.Hostname), "", port, 0, &service.Attributes)
 				}
+				var metadataMatch *core.Metadata
+				if len(ports) > 1 {
+					metadataMatch = lbPortMetadataMatch(port.Port)
+				}
+
+				// First, we build the standard cluster. We match on the SNI matching the cluster name
+				// (per the spec of AUTO_PASSTHROUGH), as well as all possible Istio mTLS ALPNs. This,
+				// along with filtering out plaintext destinations in EDS, ensures that our requests will
+				// always hit an Istio mTLS filter chain on the inbound side. As a result, it should not
+				// be possible for anyone to access a cluster without mTLS. Note that we cannot actually
+				// check for mTLS here, as we are doing passthrough TLS.
+				filterChains = append(filterChains, &filterChainOpts{
+					sniHosts:             []string{sni},
+					applicationProtocols: allIstioMtlsALPNs,
+					tlsContext:           nil, // NO TLS context because this is passthrough
+					networkFilters: buildOutboundNetworkFiltersWithSingleDestination(
+						push, proxy, statPrefix, clusterName, "", port, destinationRule, tunnelingconfig.Skip, metadataMatch),
+				})
 			}
-			if !matchFound {
+			if len(ports) > 1 {
+				// Port ranges are not used for services with subsets.
 				continue
 			}
-			clusterName := model.BuildDNSSrvSubsetKey(model.TrafficDirectionOutbound, "", service.Hostname, port.Port)
-			statPrefix := clusterName
-			if len(push.Mesh.OutboundClusterStatName) != 0 {
-				statPrefix = telemetry.BuildStatPrefix(push.Mesh.OutboundClusterStatName, string(service.Hostname), "", port, 0, &service.Attributes)
-			}
-			destinationRule := CastDestinationRule(proxy.SidecarScope.DestinationRule(
-				model.TrafficDirectionOutbound, proxy, service.Hostname).GetRule())
-
-			// First, we build the standard cluster. We match on the SNI matching the cluster name
-			// (per the spec of AUTO_PASSTHROUGH), as well as all possible Istio mTLS ALPNs. This,
-			// along with filtering out plaintext destinations in EDS, ensures that our requests will
-			// always hit an Istio mTLS filter chain on the inbound side. As a result, it should not
-			// be possible for anyone to access a cluster without mTLS. Note that we cannot actually
-			// check for mTLS here, as we are doing passthrough TLS.
-			filterChains = append(filterChains, &filterChainOpts{
-				sniHosts:             []string{clusterName},
-				applicationProtocols: allIstioMtlsALPNs,
-				tlsContext:           nil, // NO TLS context because this is passthrough
-				networkFilters: buildOutboundNetworkFiltersWithSingleDestination(
-					push, proxy, statPrefix, clusterName, "", port, destinationRule, tunnelingconfig.Skip),
-			})
+			port := ports[0]
 
 			// Do the same, but for each subset
 			for _, subset := range destinationRule.GetSubsets() {
@@ -998,7 +1012,7 @@ func builtAutoPassthroughFilterChains(push *model.PushContext, proxy *model.Prox
 					applicationProtocols: allIstioMtlsALPNs,
 					tlsContext:           nil, // NO TLS context because this is passthrough
 					networkFilters: buildOutboundNetworkFiltersWithSingleDestination(
-						push, proxy, subsetStatPrefix, subsetClusterName, subset.Name, port, destinationRule, tunnelingconfig.Skip),
+						push, proxy, subsetStatPrefix, subsetClusterName, subset.Name, port, destinationRule, tunnelingconfig.Skip, nil),
 				})
 			}
 		}
@@ -1006,6 +1020,20 @@ func builtAutoPassthroughFilterChains(push *model.PushContext, proxy *model.Prox
 	return filterChains
 }
 
+// lbPortMetadataMatch returns the metadata match restricting the TCP proxy to the endpoints of the port, within a
+// SNI-DNAT cluster serving a port range.
+func lbPortMetadataMatch(port int) *core.Metadata {
+	return &core.Metadata{
+		FilterMetadata: map[string]*structpb.Struct{
+			util.EnvoyLbMetadataKey: {
+				Fields: map[string]*structpb.Value{
+					util.LbPortMetadataKey: structpb.NewStringValue(strconv.Itoa(port)),
+				},
+			},
+		},
+	}
+}
+
 // Select the virtualService's hosts that match the ones specified in the gateway server's hosts
 // based on the wildcard hostname match and the namespace match
 func pickMatchingGatewayHosts(gatewayServerHosts sets.Set[host.Name], virtualService config.Config) map[string]host.Name {
@@ -407,6 +407,7 @@ func BuildSidecarOutboundVirtualHosts(node *model.Proxy, push *model.PushContext
 					Namespace:       svc.Attributes.Namespace,
 					ServiceRegistry: svc.Attributes.ServiceRegistry,
 					Labels:          svc.Attributes.Labels,
+					K8sAttributes:   svc.Attributes.K8sAttributes,
 				},
 			}
 			if features.EnableDualStack {
@@ -548,6 +549,13 @@ func BuildSidecarOutboundVirtualHosts(node *model.Proxy, push *model.PushContext
 			if vhost := buildVirtualHost(string(svc.Hostname), virtualHostWrapper, svc); vhost != nil {
 				virtualHosts = append(virtualHosts, vhost)
 			}
+			// Requests addressing an endpoint of services with direct pod clusters go to the cluster of the endpoint.
+			for _, address := range push.DirectPodAddresses(svc, virtualHostWrapper.Port) {
+				podWrapper := istio_route.BuildDirectPodVirtualHostWrapper(svc, address, virtualHostWrapper.Port, push.Mesh)
+				if vhost := buildVirtualHost(address, podWrapper, nil); vhost != nil {
+					virtualHosts = append(virtualHosts, vhost)
+				}
+			}
 		}
 		vHostPortMap[virtualHostWrapper.Port] = append(vHostPortMap[virtualHostWrapper.Port], virtualHosts...)
 	}
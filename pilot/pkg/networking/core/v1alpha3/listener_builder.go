@@ -24,6 +24,7 @@ import (
 	tcp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
 	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
 
 	extensions "istio.io/api/extensions/v1alpha1"
@@ -39,6 +40,7 @@ import (
 	"istio.io/istio/pilot/pkg/networking/plugin/authz"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pilot/pkg/xds/endpoints"
 	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
 	"istio.io/istio/pilot/pkg/xds/requestidextension"
 	"istio.io/istio/pkg/config/protocol"
@@ -269,6 +271,54 @@ func buildOutboundCatchAllNetworkFilterChains(node *model.Proxy, push *model.Pus
 		Name:    model.VirtualOutboundCatchAllTCPFilterChainName,
 		Filters: filterStack,
 	})
+	if passthroughEndpointsEnabled(node) {
+		chains = append(chains, passthroughEndpointFilterChains(push, node)...)
+	}
+	return chains
+}
+
+// passthroughEndpointsEnabled returns true if the traffic of the proxy matching no listener is forwarded to the
+// endpoints of ServiceEntries through the PassthroughEndpointsCluster, rather than the PassthroughCluster.
+func passthroughEndpointsEnabled(node *model.Proxy) bool {
+	return features.EnablePassthroughEndpointMetadata && node.Type == model.SidecarProxy &&
+		util.IsAllowAnyOutbound(node) && node.SidecarScope.OutboundTrafficPolicy.EgressProxy == nil
+}
+
+// passthroughEndpointFilterChains builds a filter chain for each endpoint of the ServiceEntries visible to the proxy,
+// forwarding the traffic sent to its address and port to it through the PassthroughEndpointsCluster, so the
+// telemetry of the connection has the metadata of the endpoint instead of being unknown.
+func passthroughEndpointFilterChains(push *model.PushContext, node *model.Proxy) []*listener.FilterChain {
+	peps := endpoints.PassthroughEndpoints(node, push)
+	chains := make([]*listener.FilterChain, 0, len(peps))
+	for _, pep := range peps {
+		tcpProxy := &tcp.TcpProxy{
+			StatPrefix:       util.PassthroughEndpointsCluster,
+			ClusterSpecifier: &tcp.TcpProxy_Cluster{Cluster: util.PassthroughEndpointsCluster},
+			IdleTimeout:      parseDuration(node.Metadata.IdleTimeout),
+			MetadataMatch: &core.Metadata{
+				FilterMetadata: map[string]*structpb.Struct{
+					util.EnvoyLbMetadataKey: {
+						Fields: map[string]*structpb.Value{
+							util.LbAddressMetadataKey: structpb.NewStringValue(pep.Key()),
+						},
+					},
+				},
+			},
+		}
+		accessLogBuilder.setTCPAccessLog(push, node, tcpProxy, istionetworking.ListenerClassSidecarOutbound)
+		filters := append(buildMetricsNetworkFilters(push, node, istionetworking.ListenerClassSidecarOutbound), &listener.Filter{
+			Name:       wellknown.TCPProxy,
+			ConfigType: &listener.Filter_TypedConfig{TypedConfig: protoconv.MessageToAny(tcpProxy)},
+		})
+		chains = append(chains, &listener.FilterChain{
+			Name: model.VirtualOutboundPassthroughEndpointFilterChainPrefix + pep.Key(),
+			FilterChainMatch: &listener.FilterChainMatch{
+				PrefixRanges:    []*core.CidrRange{util.ConvertAddressToCidr(pep.Endpoint.Address)},
+				DestinationPort: &wrappers.UInt32Value{Value: pep.Endpoint.EndpointPort},
+			},
+			Filters: filters,
+		})
+	}
 	return chains
 }
 
@@ -294,6 +344,9 @@ func blackholeFilterChain(push *model.PushContext, node *model.Proxy) *listener.
 	}
 }
 
+// endpointCohortFilter pins requests to the endpoint cohort named by their cohort header.
+var endpointCohortFilter = xdsfilters.BuildEndpointCohortFilter(features.EndpointCohortHeader)
+
 func (lb *ListenerBuilder) buildHTTPConnectionManager(httpOpts *httpListenerOpts) *hcm.HttpConnectionManager {
 	if httpOpts.connectionManager == nil {
 		httpOpts.connectionManager = &hcm.HttpConnectionManager{}
@@ -424,6 +477,10 @@ func (lb *ListenerBuilder) buildHTTPConnectionManager(httpOpts *httpListenerOpts
 	if features.EnablePersistentSessionFilter && httpOpts.class != istionetworking.ListenerClassSidecarInbound {
 		filters = append(filters, xdsfilters.EmptySessionFilter)
 	}
+	if features.EnableEndpointCohorts && features.EndpointCohortHeader != "" &&
+		httpOpts.class != istionetworking.ListenerClassSidecarInbound {
+		filters = append(filters, endpointCohortFilter)
+	}
 	filters = append(filters, xdsfilters.BuildRouterFilter(xdsfilters.RouterFilterContext{
 		StartChildSpan:       startChildSpan,
 		SuppressDebugHeaders: httpOpts.suppressEnvoyDebugHeaders,
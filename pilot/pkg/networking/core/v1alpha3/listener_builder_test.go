@@ -52,6 +52,63 @@ func TestVirtualListenerBuilder(t *testing.T) {
 	}
 }
 
+func TestPassthroughEndpointFilterChains(t *testing.T) {
+	test.SetForTest(t, &features.EnablePassthroughEndpointMetadata, true)
+	cg := NewConfigGenTest(t, TestOptions{ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: db
+  namespace: default
+spec:
+  hosts:
+  - db.example.com
+  addresses:
+  - 240.240.0.1
+  ports:
+  - number: 5432
+    name: tcp
+    protocol: TCP
+  resolution: STATIC
+  endpoints:
+  - address: 10.1.0.1
+    labels:
+      app: db
+  - address: 10.1.0.2
+    labels:
+      app: db
+`})
+	proxy := cg.SetupProxy(nil)
+
+	vo := xdstest.ExtractListener(model.VirtualOutboundListenerName, cg.Listeners(proxy))
+	chain := xdstest.ExtractFilterChain(model.VirtualOutboundPassthroughEndpointFilterChainPrefix+"10.1.0.1:5432", vo)
+	if chain == nil {
+		t.Fatalf("expected a filter chain for the endpoint, got %v", xdstest.ExtractFilterChainNames(vo))
+	}
+	assert.Equal(t, chain.FilterChainMatch.PrefixRanges[0].AddressPrefix, "10.1.0.1")
+	assert.Equal(t, chain.FilterChainMatch.DestinationPort.GetValue(), uint32(5432))
+	tcpProxy := xdstest.ExtractTCPProxy(t, chain)
+	assert.Equal(t, tcpProxy.GetCluster(), util.PassthroughEndpointsCluster)
+	assert.Equal(t, tcpProxy.MetadataMatch.FilterMetadata[util.EnvoyLbMetadataKey].Fields[util.LbAddressMetadataKey].GetStringValue(),
+		"10.1.0.1:5432")
+
+	c := xdstest.ExtractCluster(util.PassthroughEndpointsCluster, cg.Clusters(proxy))
+	if c == nil {
+		t.Fatalf("expected the %s cluster", util.PassthroughEndpointsCluster)
+	}
+	assert.Equal(t, c.LbSubsetConfig.SubsetSelectors[0].Keys, []string{util.LbAddressMetadataKey})
+
+	// Without the feature, passthrough traffic goes to the PassthroughCluster only.
+	test.SetForTest(t, &features.EnablePassthroughEndpointMetadata, false)
+	vo = xdstest.ExtractListener(model.VirtualOutboundListenerName, cg.Listeners(proxy))
+	assert.Equal(t, xdstest.ExtractFilterChainNames(vo), []string{
+		model.VirtualOutboundBlackholeFilterChainName, model.VirtualOutboundCatchAllTCPFilterChainName,
+	})
+	if xdstest.ExtractCluster(util.PassthroughEndpointsCluster, cg.Clusters(proxy)) != nil {
+		t.Fatalf("unexpected %s cluster", util.PassthroughEndpointsCluster)
+	}
+}
+
 var (
 	testServices = []*model.Service{
 		buildService("test.com", wildcardIPv4, protocol.HTTP, tnow),
@@ -572,14 +572,12 @@ func (lb *ListenerBuilder) routeDestination(out *route.Route, in *networking.HTT
 
 	if in.Mirror != nil {
 		if mp := istio_route.MirrorPercent(in); mp != nil {
-			action.RequestMirrorPolicies = append(action.RequestMirrorPolicies,
-				istio_route.TranslateRequestMirrorPolicy(in.Mirror, lb.serviceForHostname(host.Name(in.Mirror.Host)), listenerPort, mp))
+			action.RequestMirrorPolicies = append(action.RequestMirrorPolicies, lb.translateWaypointMirrorPolicy(in.Mirror, listenerPort, mp))
 		}
 	}
 	for _, mirror := range in.Mirrors {
 		if mp := istio_route.MirrorPercentByPolicy(mirror); mp != nil && mirror.Destination != nil {
-			action.RequestMirrorPolicies = append(action.RequestMirrorPolicies,
-				istio_route.TranslateRequestMirrorPolicy(mirror.Destination, lb.serviceForHostname(host.Name(mirror.Destination.Host)), listenerPort, mp))
+			action.RequestMirrorPolicies = append(action.RequestMirrorPolicies, lb.translateWaypointMirrorPolicy(mirror.Destination, listenerPort, mp))
 		}
 	}
 
@@ -644,6 +642,21 @@ func (lb *ListenerBuilder) routeDestination(out *route.Route, in *networking.HTT
 	}
 }
 
+// translateWaypointMirrorPolicy builds the mirror policy for a waypoint route. Unlike sidecars, a waypoint
+// mirrors to the inbound VIP cluster of services it owns, and to the outbound cluster for any other service;
+// the endpoints of the latter are reached through their own waypoint, if they have one.
+func (lb *ListenerBuilder) translateWaypointMirrorPolicy(
+	dst *networking.Destination,
+	listenerPort int,
+	mp *core.RuntimeFractionalPercent,
+) *route.RouteAction_RequestMirrorPolicy {
+	return &route.RouteAction_RequestMirrorPolicy{
+		Cluster:         lb.GetDestinationCluster(dst, lb.serviceForHostname(host.Name(dst.Host)), listenerPort),
+		RuntimeFraction: mp,
+		TraceSampled:    &wrappers.BoolValue{Value: false},
+	}
+}
+
 // GetDestinationCluster generates a cluster name for the route, or error if no cluster
 // can be found. Called by translateRule to determine if
 func (lb *ListenerBuilder) GetDestinationCluster(destination *networking.Destination, service *model.Service, listenerPort int) string {
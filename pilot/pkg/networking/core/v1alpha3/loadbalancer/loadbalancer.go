@@ -179,6 +179,9 @@ func applyLocalityFailover(
 				}
 			}
 		}
+		if fn := localityPriority.Load(); fn != nil {
+			priority = (*fn)(locality, localityEndpoint.Locality, priority)
+		}
 		loadAssignment.Endpoints[i].Priority = uint32(priority)
 		priorityMap[priority] = append(priorityMap[priority], i)
 	}
@@ -203,6 +206,91 @@ func applyLocalityFailover(
 	}
 }
 
+// ApplyFailoverPrewarm moves the localities of priority 1 to priority 0, weighted so that they receive the given
+// percentage of the requests of priority 0, and moves the next priorities up. This keeps the connections to the
+// failover targets warm without listing their hosts twice: once the localities of priority 0 are unhealthy, the
+// locality weighted load balancing of priority 0 fails over to them, before the next priorities.
+// It must be applied after the failover priorities are set.
+func ApplyFailoverPrewarm(loadAssignment *endpoint.ClusterLoadAssignment, percentage float64) {
+	if loadAssignment == nil || percentage <= 0 || percentage >= 100 {
+		return
+	}
+	var primaryWeight, failoverWeight uint64
+	for _, llb := range loadAssignment.Endpoints {
+		switch llb.Priority {
+		case 0:
+			primaryWeight += uint64(localityWeight(llb))
+		case 1:
+			if len(llb.LbEndpoints) > 0 {
+				failoverWeight += uint64(localityWeight(llb))
+			}
+		}
+	}
+	if primaryWeight == 0 || failoverWeight == 0 {
+		return
+	}
+	// The weight of the failover localities is the share of the requests they receive among the weight of priority 0.
+	prewarmWeight := float64(primaryWeight) * percentage / (100 - percentage)
+	for _, llb := range loadAssignment.Endpoints {
+		if llb.Priority == 0 {
+			continue
+		}
+		if llb.Priority == 1 {
+			w := math.Ceil(prewarmWeight * float64(localityWeight(llb)) / float64(failoverWeight))
+			if w > math.MaxUint32 {
+				w = math.MaxUint32
+			}
+			llb.LoadBalancingWeight = &wrappers.UInt32Value{Value: uint32(w)}
+		}
+		llb.Priority--
+	}
+}
+
+// maxSpilloverPercentage caps the share of the requests of priority 0 spilled over, so that the localities of
+// priority 0 always keep some of the traffic.
+const maxSpilloverPercentage = 99
+
+// SpilloverPercentage returns the percentage of the requests of priority 0 that must be spilled over to the first
+// failover priority to keep the estimated utilization of priority 0 under threshold percent. Every locality with
+// endpoints is assumed to originate an equal share of the requests, while serving a share proportional to its
+// weight. It must be applied after the failover priorities are set, and its result passed to ApplyFailoverPrewarm.
+func SpilloverPercentage(loadAssignment *endpoint.ClusterLoadAssignment, threshold float64) float64 {
+	if loadAssignment == nil || threshold <= 0 {
+		return 0
+	}
+	var primaryWeight, totalWeight uint64
+	localities := 0
+	for _, llb := range loadAssignment.Endpoints {
+		if len(llb.LbEndpoints) == 0 {
+			continue
+		}
+		localities++
+		totalWeight += uint64(localityWeight(llb))
+		if llb.Priority == 0 {
+			primaryWeight += uint64(localityWeight(llb))
+		}
+	}
+	if primaryWeight == 0 || primaryWeight == totalWeight {
+		return 0
+	}
+	capacity := float64(primaryWeight) / float64(totalWeight)
+	demand := 1 / float64(localities)
+	// The share of its demand priority 0 can keep without exceeding the threshold.
+	kept := threshold / 100 * capacity / demand
+	if kept >= 1 {
+		return 0
+	}
+	return math.Min((1-kept)*100, maxSpilloverPercentage)
+}
+
+// localityWeight returns the load balancing weight of the locality, defaulting to 1.
+func localityWeight(llb *endpoint.LocalityLbEndpoints) uint32 {
+	if llb.LoadBalancingWeight == nil {
+		return 1
+	}
+	return llb.LoadBalancingWeight.Value
+}
+
 // WrappedLocalityLbEndpoints contain an envoy LocalityLbEndpoints
 // and the original IstioEndpoints used to generate it.
 // It is used to do failover priority label match with proxy labels.
@@ -1108,3 +1108,100 @@ func buildWrappedLocalityLbEndpoints() []*WrappedLocalityLbEndpoints {
 		},
 	}
 }
+
+func TestApplyFailoverPrewarm(t *testing.T) {
+	g := NewWithT(t)
+	locality := func(zone string, priority, weight uint32) *endpoint.LocalityLbEndpoints {
+		return &endpoint.LocalityLbEndpoints{
+			Locality:            &core.Locality{Region: "region1", Zone: zone},
+			LbEndpoints:         []*endpoint.LbEndpoint{{}},
+			LoadBalancingWeight: &wrappers.UInt32Value{Value: weight},
+			Priority:            priority,
+		}
+	}
+	cla := &endpoint.ClusterLoadAssignment{Endpoints: []*endpoint.LocalityLbEndpoints{
+		locality("zone1", 0, 99),
+		locality("zone2", 1, 2),
+		locality("zone3", 1, 1),
+		locality("zone4", 2, 1),
+	}}
+	ApplyFailoverPrewarm(cla, 1)
+
+	// The failover localities are moved to priority 0, not duplicated.
+	g.Expect(cla.Endpoints).To(HaveLen(4))
+	for _, llb := range cla.Endpoints[1:3] {
+		g.Expect(llb.Priority).To(Equal(uint32(0)))
+		g.Expect(llb.LbEndpoints).To(HaveLen(1))
+	}
+	// Priority 0 has a weight of 99, so the failover localities get 1 out of 100.
+	g.Expect(cla.Endpoints[1].LoadBalancingWeight.Value).To(Equal(uint32(1)))
+	g.Expect(cla.Endpoints[2].LoadBalancingWeight.Value).To(Equal(uint32(1)))
+	g.Expect(cla.Endpoints[0].LoadBalancingWeight.Value).To(Equal(uint32(99)))
+	// The next priorities move up.
+	g.Expect(cla.Endpoints[3].Priority).To(Equal(uint32(1)))
+
+	// Without a failover priority, nothing changes.
+	single := &endpoint.ClusterLoadAssignment{Endpoints: []*endpoint.LocalityLbEndpoints{locality("zone1", 0, 1)}}
+	ApplyFailoverPrewarm(single, 1)
+	g.Expect(single.Endpoints).To(HaveLen(1))
+}
+
+func TestSpilloverPercentage(t *testing.T) {
+	g := NewWithT(t)
+	locality := func(zone string, priority, weight uint32) *endpoint.LocalityLbEndpoints {
+		return &endpoint.LocalityLbEndpoints{
+			Locality:            &core.Locality{Region: "region1", Zone: zone},
+			LbEndpoints:         []*endpoint.LbEndpoint{{}},
+			LoadBalancingWeight: &wrappers.UInt32Value{Value: weight},
+			Priority:            priority,
+		}
+	}
+	// zone1 originates a third of the requests, but only has a sixth of the capacity: keeping all its traffic local
+	// would load it at 200%.
+	cla := &endpoint.ClusterLoadAssignment{Endpoints: []*endpoint.LocalityLbEndpoints{
+		locality("zone1", 0, 1),
+		locality("zone2", 1, 3),
+		locality("zone3", 1, 2),
+	}}
+	g.Expect(SpilloverPercentage(cla, 100)).To(Equal(50.0))
+	g.Expect(SpilloverPercentage(cla, 150)).To(Equal(25.0))
+	g.Expect(SpilloverPercentage(cla, 200)).To(Equal(0.0))
+	// Priority 0 always keeps some traffic.
+	g.Expect(SpilloverPercentage(cla, 0.01)).To(Equal(float64(maxSpilloverPercentage)))
+
+	// Spilling over goes through the failover prewarm localities.
+	ApplyFailoverPrewarm(cla, SpilloverPercentage(cla, 100))
+	g.Expect(cla.Endpoints).To(HaveLen(3))
+	g.Expect(cla.Endpoints[1].LoadBalancingWeight.Value + cla.Endpoints[2].LoadBalancingWeight.Value).To(Equal(uint32(2)))
+
+	// Without a failover priority, nothing spills over.
+	single := &endpoint.ClusterLoadAssignment{Endpoints: []*endpoint.LocalityLbEndpoints{locality("zone1", 0, 1)}}
+	g.Expect(SpilloverPercentage(single, 100)).To(Equal(0.0))
+}
+
+func TestLocalityPriorityFunc(t *testing.T) {
+	g := NewWithT(t)
+	_, err := NewCELLocalityPriorityFunc(`proxy.region`)
+	g.Expect(err).To(HaveOccurred())
+	_, err = NewCELLocalityPriorityFunc(`priority +`)
+	g.Expect(err).To(HaveOccurred())
+
+	// us-west is paired with us-east: it comes right after the other zones of us-east.
+	fn, err := NewCELLocalityPriorityFunc(`proxy.region == "us-east" && endpoint.region == "us-west" ? 5 : priority * 2`)
+	g.Expect(err).NotTo(HaveOccurred())
+	SetLocalityPriorityFunc(fn)
+	t.Cleanup(func() { SetLocalityPriorityFunc(nil) })
+
+	cla := &endpoint.ClusterLoadAssignment{Endpoints: []*endpoint.LocalityLbEndpoints{
+		{Locality: &core.Locality{Region: "eu-west", Zone: "a"}},
+		{Locality: &core.Locality{Region: "us-west", Zone: "a"}},
+		{Locality: &core.Locality{Region: "us-east", Zone: "b"}},
+		{Locality: &core.Locality{Region: "us-east", Zone: "a"}},
+	}}
+	ApplyLocalityLBSetting(cla, nil, &core.Locality{Region: "us-east", Zone: "a"}, nil, &networking.LocalityLoadBalancerSetting{}, true)
+	var priorities []uint32
+	for _, llb := range cla.Endpoints {
+		priorities = append(priorities, llb.Priority)
+	}
+	g.Expect(priorities).To(Equal([]uint32{3, 2, 1, 0}))
+}
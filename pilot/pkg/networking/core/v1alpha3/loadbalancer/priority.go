@@ -0,0 +1,90 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadbalancer
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/google/cel-go/cel"
+
+	"istio.io/istio/pkg/log"
+)
+
+// LocalityPriorityFunc assigns the failover priority of the endpoints of a locality for a proxy. It receives the
+// priority assigned by the region/zone/subzone match and the failover settings, from 0 to 4, and returns the
+// priority to use instead. Priorities are relative: they are renumbered from 0 without gaps afterwards.
+type LocalityPriorityFunc func(proxy, endpoints *core.Locality, priority int) int
+
+// localityPriority replaces the priorities of the failover settings, if set.
+var localityPriority atomic.Pointer[LocalityPriorityFunc]
+
+// SetLocalityPriorityFunc replaces the priorities assigned by the locality failover settings, for example to prefer
+// paired regions. A nil function restores the default priorities. It applies to all the clusters built afterwards,
+// so it should be set before serving proxies.
+func SetLocalityPriorityFunc(fn LocalityPriorityFunc) {
+	if fn == nil {
+		localityPriority.Store(nil)
+		return
+	}
+	localityPriority.Store(&fn)
+}
+
+// NewCELLocalityPriorityFunc returns a LocalityPriorityFunc evaluating a CEL expression of type int, for example
+// `proxy.region == "us-east" && endpoint.region == "us-west" ? 3 : priority * 2`. The expression can use the
+// `proxy` and `endpoint` localities, as maps with the "region", "zone" and "subzone" keys, and the default
+// `priority`. The default priority is kept if the evaluation fails.
+func NewCELLocalityPriorityFunc(expression string) (LocalityPriorityFunc, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("proxy", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("endpoint", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("priority", cel.IntType),
+	)
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expression)
+	if issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if ast.OutputType() != cel.IntType {
+		return nil, fmt.Errorf("the expression must be of type int, got %v", ast.OutputType())
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	return func(proxy, endpoints *core.Locality, priority int) int {
+		out, _, err := prg.Eval(map[string]any{
+			"proxy":    celLocality(proxy),
+			"endpoint": celLocality(endpoints),
+			"priority": priority,
+		})
+		if err != nil {
+			log.Debugf("failed to evaluate the locality priority of %v for %v: %v", endpoints, proxy, err)
+			return priority
+		}
+		p, ok := out.Value().(int64)
+		if !ok {
+			return priority
+		}
+		return int(p)
+	}, nil
+}
+
+func celLocality(l *core.Locality) map[string]string {
+	return map[string]string{"region": l.GetRegion(), "zone": l.GetZone(), "subzone": l.GetSubZone()}
+}
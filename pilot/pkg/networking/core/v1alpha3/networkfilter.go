@@ -18,6 +18,7 @@ import (
 	"time"
 
 	mysql "github.com/envoyproxy/go-control-plane/contrib/envoy/extensions/filters/network/mysql_proxy/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	mongo "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/mongo_proxy/v3"
 	redis "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/redis_proxy/v3"
@@ -33,6 +34,7 @@ import (
 	istioroute "istio.io/istio/pilot/pkg/networking/core/v1alpha3/route"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/tunnelingconfig"
 	"istio.io/istio/pilot/pkg/networking/telemetry"
+	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/util/protoconv"
 	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
 	"istio.io/istio/pkg/config"
@@ -80,9 +82,10 @@ func setAccessLogAndBuildTCPFilter(push *model.PushContext, node *model.Proxy, c
 }
 
 // buildOutboundNetworkFiltersWithSingleDestination takes a single cluster name
-// and builds a stack of network filters.
+// and builds a stack of network filters. The metadata match, if any, restricts the endpoints of the cluster used.
 func buildOutboundNetworkFiltersWithSingleDestination(push *model.PushContext, node *model.Proxy,
 	statPrefix, clusterName, subsetName string, port *model.Port, destinationRule *networking.DestinationRule, applyTunnelingConfig tunnelingconfig.ApplyFunc,
+	metadataMatch *core.Metadata,
 ) []*listener.Filter {
 	tcpProxy := &tcp.TcpProxy{
 		StatPrefix:       statPrefix,
@@ -94,7 +97,9 @@ func buildOutboundNetworkFiltersWithSingleDestination(push *model.PushContext, n
 		tcpProxy.MaxDownstreamConnectionDuration = maxConnectionDuration
 	}
 	maybeSetHashPolicy(destinationRule, tcpProxy, subsetName)
+	maybeSetSessionAffinityHashPolicy(push, node, destinationRule, tcpProxy, clusterName, subsetName, port)
 	applyTunnelingConfig(tcpProxy, destinationRule, subsetName)
+	tcpProxy.MetadataMatch = metadataMatch
 	class := model.OutboundListenerClass(node.Type)
 	tcpFilter := setAccessLogAndBuildTCPFilter(push, node, tcpProxy, class)
 
@@ -187,6 +192,26 @@ func maybeSetHashPolicy(destinationRule *networking.DestinationRule, tcpProxy *t
 	}
 }
 
+// maybeSetSessionAffinityHashPolicy hashes the source IP of the connections to a service with the ClientIP session
+// affinity, as long as the destination rule sets no load balancer for the cluster.
+func maybeSetSessionAffinityHashPolicy(push *model.PushContext, node *model.Proxy, destinationRule *networking.DestinationRule,
+	tcpProxy *tcp.TcpProxy, clusterName, subsetName string, port *model.Port,
+) {
+	if tcpProxy.HashPolicy != nil {
+		return
+	}
+	_, _, hostname, _ := model.ParseSubsetKey(clusterName)
+	if model.SessionAffinityLoadBalancer(push.ServiceForHostname(node, hostname)) == nil {
+		return
+	}
+	if util.EffectiveTrafficPolicy(destinationRule, subsetName, port).GetLoadBalancer() != nil {
+		return
+	}
+	tcpProxy.HashPolicy = []*hashpolicy.HashPolicy{{PolicySpecifier: &hashpolicy.HashPolicy_SourceIp_{
+		SourceIp: &hashpolicy.HashPolicy_SourceIp{},
+	}}}
+}
+
 // buildNetworkFiltersStack builds a slice of network filters based on
 // the protocol in use and the given TCP filter instance.
 func buildNetworkFiltersStack(p protocol.Instance, tcpFilter *listener.Filter, statPrefix string, clusterName string) []*listener.Filter {
@@ -239,7 +264,7 @@ func buildOutboundNetworkFilters(node *model.Proxy,
 		}
 
 		return buildOutboundNetworkFiltersWithSingleDestination(
-			push, node, statPrefix, clusterName, routes[0].Destination.Subset, port, destinationRule, tunnelingconfig.Apply)
+			push, node, statPrefix, clusterName, routes[0].Destination.Subset, port, destinationRule, tunnelingconfig.Apply, nil)
 	}
 	return buildOutboundNetworkFiltersWithWeightedClusters(node, routes, push, port, configMeta, destinationRule)
 }
@@ -19,6 +19,8 @@ import (
 	"testing"
 	"time"
 
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	redis "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/redis_proxy/v3"
 	tcp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
@@ -29,13 +31,16 @@ import (
 	"istio.io/api/security/v1beta1"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/listenertest"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/tunnelingconfig"
 	"istio.io/istio/pilot/pkg/networking/telemetry"
+	"istio.io/istio/pilot/pkg/networking/util"
 	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
 	"istio.io/istio/pilot/test/xdstest"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/config/protocol"
 	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/test/util/assert"
 )
 
 func TestBuildRedisFilter(t *testing.T) {
@@ -711,6 +716,53 @@ func TestOutboundNetworkFilterWithSourceIPHashing(t *testing.T) {
 	}
 }
 
+func TestOutboundNetworkFilterWithSessionAffinity(t *testing.T) {
+	affinity := buildService("affinity.com", "10.10.0.0/24", protocol.TCP, tnow)
+	affinity.Attributes.SessionAffinityClientIP = true
+	overridden := buildService("overridden.com", "10.10.0.0/24", protocol.TCP, tnow)
+	overridden.Attributes.SessionAffinityClientIP = true
+	cg := NewConfigGenTest(t, TestOptions{
+		Services: []*model.Service{affinity, overridden, buildService("test.com", "10.10.0.0/24", protocol.TCP, tnow)},
+		ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: overridden
+  namespace: default
+spec:
+  host: overridden.com
+  trafficPolicy:
+    loadBalancer:
+      simple: ROUND_ROBIN
+`,
+	})
+	proxy := cg.SetupProxy(nil)
+	clusters := cg.Clusters(proxy)
+
+	cases := []struct {
+		host        string
+		useSourceIP bool
+	}{
+		{"affinity.com", true},
+		// The load balancer of the destination rule takes precedence.
+		{"overridden.com", false},
+		{"test.com", false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.host, func(t *testing.T) {
+			routes := []*networking.RouteDestination{{Destination: &networking.Destination{Host: tt.host}}}
+			filters := buildOutboundNetworkFilters(proxy, routes, cg.PushContext(), &model.Port{Port: 8080}, config.Meta{})
+			tcpProxy := &tcp.TcpProxy{}
+			filters[len(filters)-1].GetTypedConfig().UnmarshalTo(tcpProxy)
+			hasSourceIP := len(tcpProxy.HashPolicy) == 1 && tcpProxy.HashPolicy[0].GetSourceIp() != nil
+			assert.Equal(t, hasSourceIP, tt.useSourceIP)
+
+			c := xdstest.ExtractCluster("outbound|8080||"+tt.host, clusters)
+			assert.Equal(t, c.GetLbPolicy() == cluster.Cluster_RING_HASH, tt.useSourceIP)
+		})
+	}
+}
+
 func getAuthorizationPolicies() *model.AuthorizationPolicies {
 	return &model.AuthorizationPolicies{
 		NamespaceToPolicies: map[string][]model.AuthorizationPolicy{
@@ -759,3 +811,18 @@ func node(version *model.IstioVersion) *model.Proxy {
 		IstioVersion: version,
 	}
 }
+
+func TestOutboundNetworkFilterLbPortMetadataMatch(t *testing.T) {
+	cg := NewConfigGenTest(t, TestOptions{})
+	proxy := cg.SetupProxy(nil)
+	port := &model.Port{Name: "broker-1", Port: 9093, Protocol: protocol.TCP}
+	tcpProxy := func(metadataMatch *core.Metadata) *tcp.TcpProxy {
+		filters := buildOutboundNetworkFiltersWithSingleDestination(cg.PushContext(), proxy, "stats",
+			"outbound_.9092-9094_._.kafka.ns.svc.cluster.local", "", port, nil, tunnelingconfig.Skip, metadataMatch)
+		return xdstest.ExtractTCPProxy(t, &listener.FilterChain{Filters: filters})
+	}
+
+	assert.Equal(t, tcpProxy(nil).MetadataMatch, nil)
+	match := tcpProxy(lbPortMetadataMatch(port.Port)).MetadataMatch
+	assert.Equal(t, match.FilterMetadata[util.EnvoyLbMetadataKey].Fields[util.LbPortMetadataKey].GetStringValue(), "9093")
+}
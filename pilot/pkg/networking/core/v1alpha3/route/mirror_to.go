@@ -0,0 +1,89 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package route
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/host"
+)
+
+// ApplyMirrorTo mirrors the traffic of the routes to the shadow cluster of the subset named by the
+// networking.istio.io/mirror-to annotation of the DestinationRule of their destination. Routes that already
+// mirror their traffic, or that split it between several hosts or ports, are left unchanged.
+// It returns the DestinationRules the routes depend on.
+func ApplyMirrorTo(node *model.Proxy, routes []*route.Route) []*model.ConsolidatedDestRule {
+	if node.SidecarScope == nil {
+		return nil
+	}
+	var dependencies []*model.ConsolidatedDestRule
+	for _, r := range routes {
+		action := r.GetRoute()
+		if action == nil || len(action.RequestMirrorPolicies) > 0 {
+			continue
+		}
+		hostname, port, ok := routeDestination(action)
+		if !ok {
+			continue
+		}
+		dr := node.SidecarScope.DestinationRule(model.TrafficDirectionOutbound, node, hostname)
+		_, percentage, ok := model.MirrorTo(dr.GetRule())
+		if !ok {
+			continue
+		}
+		dependencies = append(dependencies, dr)
+		if percentage == 0 {
+			continue
+		}
+		action.RequestMirrorPolicies = append(action.RequestMirrorPolicies, &route.RouteAction_RequestMirrorPolicy{
+			Cluster: model.BuildSubsetKey(model.TrafficDirectionOutbound, model.MirrorSubsetName, hostname, port),
+			RuntimeFraction: &core.RuntimeFractionalPercent{
+				DefaultValue: translatePercentToFractionalPercent(&networking.Percent{Value: percentage}),
+			},
+			TraceSampled: &wrappers.BoolValue{Value: false},
+		})
+	}
+	return dependencies
+}
+
+// routeDestination returns the host and port of the outbound clusters of the route, if they all share them.
+func routeDestination(action *route.RouteAction) (host.Name, int, bool) {
+	var clusters []string
+	switch c := action.ClusterSpecifier.(type) {
+	case *route.RouteAction_Cluster:
+		clusters = []string{c.Cluster}
+	case *route.RouteAction_WeightedClusters:
+		for _, wc := range c.WeightedClusters.GetClusters() {
+			clusters = append(clusters, wc.Name)
+		}
+	}
+	var hostname host.Name
+	var port int
+	for i, cluster := range clusters {
+		dir, subset, h, p := model.ParseSubsetKey(cluster)
+		if dir != model.TrafficDirectionOutbound || h == "" || subset == model.MirrorSubsetName {
+			return "", 0, false
+		}
+		if i > 0 && (h != hostname || p != port) {
+			return "", 0, false
+		}
+		hostname, port = h, p
+	}
+	return hostname, port, hostname != ""
+}
@@ -19,11 +19,15 @@ import (
 	"strconv"
 	"strings"
 
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	omithostmetadata "github.com/envoyproxy/go-control-plane/envoy/extensions/retry/host/omit_host_metadata/v3"
 	previouspriorities "github.com/envoyproxy/go-control-plane/envoy/extensions/retry/priority/previous_priorities/v3"
+	"google.golang.org/protobuf/types/known/structpb"
 	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
 
 	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/util/protoconv"
 	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
 )
@@ -102,6 +106,46 @@ func ConvertPolicy(in *networking.HTTPRetry) *route.RetryPolicy {
 	return out
 }
 
+// HostPredicates parses the host predicates set with the networking.istio.io/retry-host-predicates annotation of a
+// DestinationRule. The value is ignored if any of the predicates is invalid.
+func HostPredicates(value string) ([]*route.RetryPolicy_RetryHostPredicate, bool) {
+	var predicates []*route.RetryPolicy_RetryHostPredicate
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "previous-hosts":
+			predicates = append(predicates, xdsfilters.RetryPreviousHosts)
+		case strings.HasPrefix(part, "omit-host-metadata:"):
+			key, val, ok := strings.Cut(strings.TrimPrefix(part, "omit-host-metadata:"), "=")
+			if !ok || key == "" || val == "" {
+				return nil, false
+			}
+			predicates = append(predicates, buildOmitHostMetadata(key, val))
+		default:
+			return nil, false
+		}
+	}
+	return predicates, true
+}
+
+// buildOmitHostMetadata builds a predicate rejecting the hosts with the given envoy.lb metadata.
+func buildOmitHostMetadata(key, value string) *route.RetryPolicy_RetryHostPredicate {
+	return &route.RetryPolicy_RetryHostPredicate{
+		Name: "envoy.retry_host_predicates.omit_host_metadata",
+		ConfigType: &route.RetryPolicy_RetryHostPredicate_TypedConfig{
+			TypedConfig: protoconv.MessageToAny(&omithostmetadata.OmitHostMetadataConfig{
+				MetadataMatch: &core.Metadata{
+					FilterMetadata: map[string]*structpb.Struct{
+						util.EnvoyLbMetadataKey: {
+							Fields: map[string]*structpb.Value{key: structpb.NewStringValue(value)},
+						},
+					},
+				},
+			}),
+		},
+	}
+}
+
 func parseRetryOn(retryOn string) (string, []uint32) {
 	codes := make([]uint32, 0)
 	tojoin := make([]string, 0)
@@ -20,6 +20,7 @@ import (
 	"time"
 
 	envoyroute "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	omithostmetadata "github.com/envoyproxy/go-control-plane/envoy/extensions/retry/host/omit_host_metadata/v3"
 	previouspriorities "github.com/envoyproxy/go-control-plane/envoy/extensions/retry/priority/previous_priorities/v3"
 	. "github.com/onsi/gomega"
 	"google.golang.org/protobuf/types/known/durationpb"
@@ -225,3 +226,21 @@ func TestRetry(t *testing.T) {
 		})
 	}
 }
+
+func TestHostPredicates(t *testing.T) {
+	g := NewWithT(t)
+
+	predicates, ok := retry.HostPredicates("previous-hosts,omit-host-metadata:istio.io/cohort=canary")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(predicates).To(HaveLen(2))
+	g.Expect(predicates[0]).To(Equal(retry.DefaultPolicy().RetryHostPredicate[0]))
+	g.Expect(predicates[1].Name).To(Equal("envoy.retry_host_predicates.omit_host_metadata"))
+	cfg := &omithostmetadata.OmitHostMetadataConfig{}
+	g.Expect(predicates[1].GetTypedConfig().UnmarshalTo(cfg)).To(Succeed())
+	g.Expect(cfg.MetadataMatch.FilterMetadata["envoy.lb"].Fields["istio.io/cohort"].GetStringValue()).To(Equal("canary"))
+
+	for _, invalid := range []string{"", "unknown", "omit-host-metadata:istio.io/cohort", "previous-hosts,omit-host-metadata:=canary"} {
+		_, ok := retry.HostPredicates(invalid)
+		g.Expect(ok).To(BeFalse(), invalid)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package route
+
+import (
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/route/retry"
+	"istio.io/istio/pkg/config/constants"
+)
+
+// ApplyRetryHostPredicates replaces the retry host predicates of the routes with the ones set by the
+// networking.istio.io/retry-host-predicates annotation of the DestinationRule of their destination. Routes without
+// retries, or that split their traffic between several hosts or ports, are left unchanged.
+// It returns the DestinationRules the routes depend on.
+func ApplyRetryHostPredicates(node *model.Proxy, routes []*route.Route) []*model.ConsolidatedDestRule {
+	if node.SidecarScope == nil {
+		return nil
+	}
+	var dependencies []*model.ConsolidatedDestRule
+	for _, r := range routes {
+		action := r.GetRoute()
+		if action.GetRetryPolicy() == nil {
+			continue
+		}
+		hostname, _, ok := routeDestination(action)
+		if !ok {
+			continue
+		}
+		dr := node.SidecarScope.DestinationRule(model.TrafficDirectionOutbound, node, hostname)
+		rule := dr.GetRule()
+		if rule == nil {
+			continue
+		}
+		value, f := rule.Annotations[constants.RetryHostPredicatesAnnotation]
+		if !f {
+			continue
+		}
+		dependencies = append(dependencies, dr)
+		predicates, ok := retry.HostPredicates(value)
+		if !ok {
+			continue
+		}
+		action.RetryPolicy.RetryHostPredicate = predicates
+	}
+	return dependencies
+}
@@ -121,7 +121,7 @@ func BuildSidecarVirtualHostWrapper(routeCache *Cache, node *model.Proxy, push *
 		for _, port := range svc.Ports {
 			if port.Protocol.IsHTTPOrSniffed() {
 				hash, destinationRule := hashForService(push, node, svc, port)
-				if hash != nil {
+				if hash != nil && destinationRule != nil {
 					dependentDestinationRules = append(dependentDestinationRules, destinationRule)
 				}
 				// append default hosts for the service missing virtual Services.
@@ -130,6 +130,12 @@ func BuildSidecarVirtualHostWrapper(routeCache *Cache, node *model.Proxy, push *
 		}
 	}
 
+	for _, wrapper := range out {
+		dependentDestinationRules = append(dependentDestinationRules, ApplyMirrorTo(node, wrapper.Routes)...)
+		dependentDestinationRules = append(dependentDestinationRules, ApplyRetryHostPredicates(node, wrapper.Routes)...)
+		dependentDestinationRules = append(dependentDestinationRules, ApplySubsetLoadBalancer(node, wrapper.Routes)...)
+	}
+
 	if routeCache != nil {
 		routeCache.DestinationRules = dependentDestinationRules
 	}
@@ -284,6 +290,17 @@ func buildSidecarVirtualHostForService(svc *model.Service,
 	}
 }
 
+// BuildDirectPodVirtualHostWrapper returns the virtual host routing the requests for the address of an endpoint of a
+// service with direct pod clusters to the cluster of the endpoint.
+func BuildDirectPodVirtualHostWrapper(svc *model.Service, address string, port int, mesh *meshconfig.MeshConfig) VirtualHostWrapper {
+	cluster := model.BuildSubsetKey(model.TrafficDirectionOutbound, model.DirectPodSubset(address), svc.Hostname, port)
+	traceOperation := telemetry.TraceOperation(string(svc.Hostname), port)
+	return VirtualHostWrapper{
+		Port:   port,
+		Routes: []*route.Route{BuildDefaultHTTPOutboundRoute(cluster, traceOperation, mesh)},
+	}
+}
+
 // GetDestinationCluster generates a cluster name for the route, or error if no cluster
 // can be found. Called by translateRule to determine if
 func GetDestinationCluster(destination *networking.Destination, service *model.Service, listenerPort int) string {
@@ -1336,7 +1353,7 @@ func hashForService(push *model.PushContext,
 	mergedDR := node.SidecarScope.DestinationRule(model.TrafficDirectionOutbound, node, svc.Hostname)
 	destinationRule := mergedDR.GetRule()
 	if destinationRule == nil {
-		return nil, nil
+		return model.SessionAffinityLoadBalancer(svc).GetConsistentHash(), nil
 	}
 	rule := destinationRule.Spec.(*networking.DestinationRule)
 	consistentHash := rule.GetTrafficPolicy().GetLoadBalancer().GetConsistentHash()
@@ -1350,6 +1367,10 @@ func hashForService(push *model.PushContext,
 			break
 		}
 	}
+	if consistentHash == nil && util.EffectiveTrafficPolicy(rule, "", port).GetLoadBalancer() == nil {
+		// The session affinity of the service applies as long as the rule sets no load balancer, as for the cluster.
+		consistentHash = model.SessionAffinityLoadBalancer(svc).GetConsistentHash()
+	}
 
 	return consistentHash, mergedDR
 }
@@ -1365,7 +1386,9 @@ func hashForVirtualService(push *model.PushContext,
 			hash, dr := hashForHTTPDestination(push, node, destination)
 			if hash != nil {
 				hashByDestination[destination] = hash
-				destinationRules = append(destinationRules, dr)
+				if dr != nil {
+					destinationRules = append(destinationRules, dr)
+				}
 			}
 		}
 	}
@@ -1389,7 +1412,8 @@ func hashForHTTPDestination(push *model.PushContext, node *model.Proxy,
 	mergedDR := node.SidecarScope.DestinationRule(model.TrafficDirectionOutbound, node, host.Name(destination.Host))
 	destinationRule := mergedDR.GetRule()
 	if destinationRule == nil {
-		return nil, nil
+		svc := push.ServiceForHostname(node, host.Name(destination.Host))
+		return model.SessionAffinityLoadBalancer(svc).GetConsistentHash(), nil
 	}
 
 	rule := destinationRule.Spec.(*networking.DestinationRule)
@@ -1416,6 +1440,19 @@ func hashForHTTPDestination(push *model.PushContext, node *model.Proxy,
 	case plsHash != nil:
 		consistentHash = plsHash
 	}
+	if consistentHash == nil {
+		// The session affinity of the service applies as long as the rule sets no load balancer, as for the cluster.
+		svc := push.ServiceForHostname(node, host.Name(destination.Host))
+		if lb := model.SessionAffinityLoadBalancer(svc); lb != nil {
+			var port *model.Port
+			if destination.GetPort() != nil {
+				port, _ = svc.Ports.GetByPort(int(destination.GetPort().GetNumber()))
+			}
+			if util.EffectiveTrafficPolicy(rule, destination.GetSubset(), port).GetLoadBalancer() == nil {
+				consistentHash = lb.GetConsistentHash()
+			}
+		}
+	}
 	return consistentHash, mergedDR
 }
 
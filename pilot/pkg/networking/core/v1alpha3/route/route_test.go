@@ -1152,6 +1152,67 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		}
 		g.Expect(vhosts[0].Routes[0].Action.(*envoyroute.Route_Route).Route.HashPolicy).To(gomega.ConsistOf(hashPolicy))
 	})
+	t.Run("for destinationrule with mirror-to annotation", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{
+			Configs: []config.Config{
+				{
+					Meta: config.Meta{
+						GroupVersionKind: gvk.DestinationRule,
+						Name:             "acme",
+						Namespace:        "istio-system",
+						Annotations: map[string]string{
+							constants.MirrorToAnnotation:         "canary",
+							constants.MirrorPercentageAnnotation: "10",
+						},
+					},
+					Spec: &networking.DestinationRule{
+						Host:    "*.example.org",
+						Subsets: []*networking.Subset{{Name: "canary", Labels: map[string]string{"track": "canary"}}},
+					},
+				},
+			},
+			Services: exampleService,
+		})
+		routeCache := &route.Cache{}
+		vhosts := route.BuildSidecarVirtualHostWrapper(routeCache, node(cg), cg.PushContext(), serviceRegistry, []config.Config{}, 8080)
+		mirrors := vhosts[0].Routes[0].Action.(*envoyroute.Route_Route).Route.RequestMirrorPolicies
+		g.Expect(mirrors).To(gomega.HaveLen(1))
+		g.Expect(mirrors[0].Cluster).To(gomega.Equal("outbound|8080|istio-mirror|*.example.org"))
+		g.Expect(mirrors[0].RuntimeFraction.DefaultValue.Numerator).To(gomega.Equal(uint32(100000)))
+		// The routes must be rebuilt when the DestinationRule changes.
+		g.Expect(routeCache.DestinationRules).To(gomega.HaveLen(1))
+	})
+
+	t.Run("for destinationrule with retry-host-predicates annotation", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{
+			Configs: []config.Config{
+				{
+					Meta: config.Meta{
+						GroupVersionKind: gvk.DestinationRule,
+						Name:             "acme",
+						Namespace:        "istio-system",
+						Annotations: map[string]string{
+							constants.RetryHostPredicatesAnnotation: "previous-hosts, omit-host-metadata:istio.io/cohort=canary",
+						},
+					},
+					Spec: &networking.DestinationRule{
+						Host: "*.example.org",
+					},
+				},
+			},
+			Services: exampleService,
+		})
+		routeCache := &route.Cache{}
+		vhosts := route.BuildSidecarVirtualHostWrapper(routeCache, node(cg), cg.PushContext(), serviceRegistry, []config.Config{}, 8080)
+		predicates := vhosts[0].Routes[0].Action.(*envoyroute.Route_Route).Route.RetryPolicy.RetryHostPredicate
+		g.Expect(predicates).To(gomega.HaveLen(2))
+		g.Expect(predicates[0].Name).To(gomega.Equal("envoy.retry_host_predicates.previous_hosts"))
+		g.Expect(predicates[1].Name).To(gomega.Equal("envoy.retry_host_predicates.omit_host_metadata"))
+		// The routes must be rebuilt when the DestinationRule changes.
+		g.Expect(routeCache.DestinationRules).To(gomega.HaveLen(1))
+	})
 }
 
 func loadBalancerPolicy(name string) *networking.LoadBalancerSettings_ConsistentHash {
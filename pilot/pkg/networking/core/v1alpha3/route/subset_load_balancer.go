@@ -0,0 +1,113 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package route
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// ApplySubsetLoadBalancer replaces the subset clusters of the routes with the default cluster of their destination
+// and a metadata match on the subset, when the DestinationRule of the destination selects its subsets with the
+// subset load balancer, as set by the networking.istio.io/subset-load-balancer annotation. Each cluster of a weighted
+// split is replaced on its own. The subset clusters are still built for the references which cannot carry a metadata
+// match, such as mirrors and TCP routes.
+// It returns the DestinationRules the routes depend on.
+func ApplySubsetLoadBalancer(node *model.Proxy, routes []*route.Route) []*model.ConsolidatedDestRule {
+	if node.SidecarScope == nil {
+		return nil
+	}
+	var dependencies []*model.ConsolidatedDestRule
+	rewrite := func(cluster string) (string, *core.Metadata) {
+		dr, subsets := subsetLoadBalancerRule(node, cluster)
+		if dr == nil {
+			return cluster, nil
+		}
+		dependencies = append(dependencies, dr)
+		return subsetMetadataMatch(cluster, subsets)
+	}
+	for _, r := range routes {
+		action := r.GetRoute()
+		if action == nil {
+			continue
+		}
+		switch c := action.ClusterSpecifier.(type) {
+		case *route.RouteAction_Cluster:
+			if name, match := rewrite(c.Cluster); match != nil {
+				c.Cluster = name
+				action.MetadataMatch = match
+			}
+		case *route.RouteAction_WeightedClusters:
+			for _, wc := range c.WeightedClusters.GetClusters() {
+				if name, match := rewrite(wc.Name); match != nil {
+					wc.Name = name
+					wc.MetadataMatch = match
+				}
+			}
+		}
+	}
+	return dependencies
+}
+
+// subsetLoadBalancerRule returns the DestinationRule of the destination of the outbound cluster and the names of its
+// subsets, if it selects them with the subset load balancer.
+func subsetLoadBalancerRule(node *model.Proxy, cluster string) (*model.ConsolidatedDestRule, sets.String) {
+	dir, subset, hostname, port := model.ParseSubsetKey(cluster)
+	if dir != model.TrafficDirectionOutbound || hostname == "" || subset == "" || subset == model.MirrorSubsetName {
+		return nil, nil
+	}
+	dr := node.SidecarScope.DestinationRule(model.TrafficDirectionOutbound, node, hostname)
+	if dr.GetRule() == nil {
+		return nil, nil
+	}
+	svc := node.SidecarScope.GetService(hostname)
+	if svc == nil {
+		return nil, nil
+	}
+	svcPort, _ := svc.Ports.GetByPort(port)
+	if !model.SubsetLoadBalancer(dr.GetRule(), svc, svcPort) {
+		return nil, nil
+	}
+	rule, _ := dr.GetRule().Spec.(*networking.DestinationRule)
+	subsets := sets.New[string]()
+	for _, s := range rule.GetSubsets() {
+		subsets.Insert(s.GetName())
+	}
+	return dr, subsets
+}
+
+// subsetMetadataMatch returns the default cluster of the subset cluster, and the metadata match selecting the
+// endpoints of the subset. It returns a nil match for clusters of other subsets, such as the direct pod clusters.
+func subsetMetadataMatch(cluster string, subsets sets.String) (string, *core.Metadata) {
+	dir, subset, hostname, port := model.ParseSubsetKey(cluster)
+	if !subsets.Contains(subset) {
+		return cluster, nil
+	}
+	return model.BuildSubsetKey(dir, "", hostname, port), &core.Metadata{
+		FilterMetadata: map[string]*structpb.Struct{
+			util.EnvoyLbMetadataKey: {
+				Fields: map[string]*structpb.Value{
+					util.LbSubsetMetadataPrefix + subset: structpb.NewBoolValue(true),
+				},
+			},
+		},
+	}
+}
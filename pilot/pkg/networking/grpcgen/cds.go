@@ -28,6 +28,7 @@ import (
 	corexds "istio.io/istio/pilot/pkg/networking/core/v1alpha3"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/util/protoconv"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/util/sets"
 )
@@ -130,7 +131,7 @@ func (b *clusterBuilder) build() []*cluster.Cluster {
 	var defaultCluster *cluster.Cluster
 	if b.filter.Contains(b.defaultClusterName) {
 		defaultCluster = edsCluster(b.defaultClusterName)
-		if b.svc.Attributes.Labels[features.PersistentSessionLabel] != "" {
+		if b.svc.PersistentSession() {
 			// see core/v1alpha3/cluster.go
 			defaultCluster.CommonLbConfig.OverrideHostStatus = &core.HealthStatusSet{
 				Statuses: []core.HealthStatus{
@@ -155,7 +156,7 @@ func edsCluster(name string) *cluster.Cluster {
 		Name:                 name,
 		ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_EDS},
 		EdsClusterConfig: &cluster.Cluster_EdsClusterConfig{
-			ServiceName: name,
+			ServiceName: edsServiceName(name),
 			EdsConfig: &core.ConfigSource{
 				ConfigSourceSpecifier: &core.ConfigSource_Ads{
 					Ads: &core.AggregatedConfigSource{},
@@ -165,6 +166,15 @@ func edsCluster(name string) *cluster.Cluster {
 	}
 }
 
+// edsServiceName returns the name under which the endpoints of the cluster are requested: an xdstp:// name if an
+// authority is configured for xDS federation, else the cluster name.
+func edsServiceName(name string) string {
+	if features.GRPCXdstpAuthority == "" {
+		return name
+	}
+	return v3.XdstpResourceName(features.GRPCXdstpAuthority, v3.EndpointType, name)
+}
+
 // applyDestinationRule mutates the default cluster to reflect traffic policies, and returns a set of additional
 // subset clusters if specified by a destination rule
 func (b *clusterBuilder) applyDestinationRule(defaultCluster *cluster.Cluster) (subsetClusters []*cluster.Cluster) {
@@ -175,7 +185,7 @@ func (b *clusterBuilder) applyDestinationRule(defaultCluster *cluster.Cluster) (
 	// resolve policy from context
 	destinationRule := corexds.CastDestinationRule(b.node.SidecarScope.DestinationRule(
 		model.TrafficDirectionOutbound, b.node, b.svc.Hostname).GetRule())
-	trafficPolicy := util.MergeTrafficPolicy(nil, destinationRule.GetTrafficPolicy(), b.port)
+	trafficPolicy := util.EffectiveTrafficPolicy(destinationRule, "", b.port)
 
 	// setup default cluster
 	b.applyTrafficPolicy(defaultCluster, trafficPolicy)
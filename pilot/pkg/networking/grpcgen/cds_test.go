@@ -0,0 +1,44 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcgen
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/features"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/test"
+)
+
+func TestEdsServiceName(t *testing.T) {
+	const cluster = "outbound|80|v1|foo.ns.svc.cluster.local"
+	if got := edsCluster(cluster).GetEdsClusterConfig().GetServiceName(); got != cluster {
+		t.Fatalf("expected the legacy endpoints name %q, got %q", cluster, got)
+	}
+
+	test.SetForTest(t, &features.GRPCXdstpAuthority, "istiod.istio-system")
+	got := edsCluster(cluster).GetEdsClusterConfig().GetServiceName()
+	want := "xdstp://istiod.istio-system/envoy.config.endpoint.v3.ClusterLoadAssignment/" + cluster
+	if got != want {
+		t.Fatalf("expected the xdstp endpoints name %q, got %q", want, got)
+	}
+	authority, id, ok := v3.ParseXdstpResourceName(v3.EndpointType, got+"?param=value")
+	if !ok || authority != "istiod.istio-system" || id != cluster {
+		t.Fatalf("failed to parse %q: %v %v %v", got, authority, id, ok)
+	}
+	if _, _, ok := v3.ParseXdstpResourceName(v3.ClusterType, got); ok {
+		t.Fatalf("parsed %q as a cluster name", got)
+	}
+}
@@ -17,6 +17,7 @@ package util
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"net"
 	"net/netip"
 	"sort"
@@ -65,6 +66,10 @@ const (
 	// PassthroughCluster
 	Passthrough = "allow_any"
 
+	// PassthroughEndpointsCluster forwards traffic matching no listener to the endpoints of ServiceEntries, like the
+	// PassthroughCluster but with the metadata of the endpoints. See features.EnablePassthroughEndpointMetadata.
+	PassthroughEndpointsCluster = "PassthroughEndpointsCluster"
+
 	// PassthroughFilterChain to catch traffic that doesn't match other filter chains.
 	PassthroughFilterChain = "PassthroughFilterChain"
 
@@ -80,6 +85,66 @@ const (
 	// which determines the endpoint level transport socket configuration.
 	EnvoyTransportSocketMetadataKey = "envoy.transport_socket_match"
 
+	// EnvoyLbMetadataKey is the key under which endpoint metadata used by the subset load balancer
+	// and the metadata match of routes is added.
+	EnvoyLbMetadataKey = "envoy.lb"
+
+	// LbPortMetadataKey is the EnvoyLbMetadataKey field holding the service port of an endpoint in
+	// SNI-DNAT clusters covering a range of ports.
+	LbPortMetadataKey = "istio.io/port"
+
+	// LbCostMetadataKey is the EnvoyLbMetadataKey field holding the cost of an endpoint, for cost-aware load
+	// balancer extensions.
+	LbCostMetadataKey = "istio.io/cost"
+
+	// LbCohortMetadataKey is the EnvoyLbMetadataKey field holding the cohort of an endpoint. Requests select a
+	// cohort through the same field of their dynamic metadata.
+	LbCohortMetadataKey = "istio.io/cohort"
+
+	// LbCapacityRPSMetadataKey is the EnvoyLbMetadataKey field holding the requests per second budget of an endpoint,
+	// for adaptive concurrency and client-side weighted round robin extensions.
+	LbCapacityRPSMetadataKey = "istio.io/capacity-rps"
+
+	// LbPreviousAddressMetadataKey is the EnvoyLbMetadataKey field holding the previous address of a re-addressed
+	// endpoint, and LbMigratedToMetadataKey the field holding the new address on the draining endpoint of the
+	// previous address.
+	LbPreviousAddressMetadataKey = "istio.io/previous-address"
+	LbMigratedToMetadataKey      = "istio.io/migrated-to"
+
+	// LbHostnameMetadataKey is the EnvoyLbMetadataKey field holding the hostname that produced an endpoint of a
+	// ServiceEntry.
+	LbHostnameMetadataKey = "istio.io/hostname"
+
+	// IstioInstanceMetadataKey and IstioWorkloadKindMetadataKey are the IstioMetadataKey fields holding the workload
+	// instance of an endpoint, such as its pod, and the kind of its owning workload, recorded by access logs and
+	// tracing along the name and revision of the workload in the "workload" field.
+	IstioInstanceMetadataKey     = "instance"
+	IstioWorkloadKindMetadataKey = "workload_kind"
+
+	// IstioTruncatedFromMetadataKey is the IstioMetadataKey field of the endpoints of a ClusterLoadAssignment
+	// truncated to its size limit, holding the number of endpoints before truncation.
+	IstioTruncatedFromMetadataKey = "truncated_from"
+
+	// IstioSocketOptionsMetadataKey is the IstioMetadataKey field holding the socket options of the upstream
+	// connections to an endpoint, with the keepalive_time, keepalive_interval and keepalive_probes fields.
+	IstioSocketOptionsMetadataKey = "socket_options"
+
+	// LbAddressMetadataKey is the EnvoyLbMetadataKey field holding the <address>:<port> of an endpoint of the
+	// PassthroughEndpointsCluster, selected by the filter chain of the address.
+	LbAddressMetadataKey = "istio.io/address"
+
+	// IstioTelemetryMetadataKey is the key of the endpoint metadata requested by the telemetry providers of the proxy,
+	// with the telemetry.istio.io/endpoint-metadata annotation of a Telemetry, holding endpoint label values by key.
+	IstioTelemetryMetadataKey = "istio.telemetry"
+
+	// IstioServiceMetadataKey is the IstioMetadataKey field holding the hostname of the service of an endpoint of the
+	// PassthroughEndpointsCluster, which has no service of its own.
+	IstioServiceMetadataKey = "service"
+
+	// LbSubsetMetadataPrefix prefixes the EnvoyLbMetadataKey fields marking the DestinationRule subsets an endpoint
+	// belongs to, when the subsets are selected by the subset load balancer.
+	LbSubsetMetadataPrefix = "istio.io/subset."
+
 	// Well-known header names
 	AltSvcHeader = "alt-svc"
 
@@ -352,6 +417,19 @@ func CloneLocalityLbEndpoint(ep *endpoint.LocalityLbEndpoints) *endpoint.Localit
 	return clone
 }
 
+// ScaleWeight returns the weight multiplied by the factor, rounded and kept within the range of the endpoint weights:
+// at least 1, and at most math.MaxUint32, the weights being normalized afterwards.
+func ScaleWeight(weight, factor float64) uint32 {
+	w := math.Round(weight * factor)
+	switch {
+	case w < 1:
+		return 1
+	case w > math.MaxUint32:
+		return math.MaxUint32
+	}
+	return uint32(w)
+}
+
 // BuildConfigInfoMetadata builds core.Metadata struct containing the
 // name.namespace of the config, the type, etc.
 func BuildConfigInfoMetadata(config config.Meta) *core.Metadata {
@@ -386,7 +464,7 @@ func AddConfigInfoMetadata(metadata *core.Metadata, config config.Meta) *core.Me
 // defined, the subset information will not be added (to prevent adding this information where not
 // needed). This is used for telemetry reporting.
 func AddSubsetToMetadata(md *core.Metadata, subset string) {
-	if istioMeta, ok := md.FilterMetadata[IstioMetadataKey]; ok {
+	if istioMeta, ok := md.GetFilterMetadata()[IstioMetadataKey]; ok {
 		istioMeta.Fields["subset"] = &structpb.Value{
 			Kind: &structpb.Value_StringValue{
 				StringValue: subset,
@@ -463,6 +541,8 @@ func MergeAnyWithAny(dst *anypb.Any, src *anypb.Any) (*anypb.Any, error) {
 // AppendLbEndpointMetadata adds metadata values to a lb endpoint using the passed in metadata as base.
 func AppendLbEndpointMetadata(istioMetadata *model.EndpointMetadata, envoyMetadata *core.Metadata,
 ) {
+	appendAnnotationMetadata(istioMetadata.Annotations, envoyMetadata)
+
 	if !features.EndpointTelemetryLabel || !features.EnableTelemetryLabel {
 		return
 	}
@@ -507,6 +587,28 @@ func AppendLbEndpointMetadata(istioMetadata *model.EndpointMetadata, envoyMetada
 	}
 }
 
+// appendAnnotationMetadata adds the workload annotations to the filter metadata namespaces they are mapped to
+// by features.EndpointMetadataAnnotations, keyed by the annotation name.
+func appendAnnotationMetadata(annotations map[string]string, envoyMetadata *core.Metadata) {
+	for k, v := range annotations {
+		ns, f := features.EndpointMetadataAnnotations[k]
+		if !f {
+			continue
+		}
+		if envoyMetadata.FilterMetadata == nil {
+			envoyMetadata.FilterMetadata = map[string]*structpb.Struct{}
+		}
+		st, f := envoyMetadata.FilterMetadata[ns]
+		if !f {
+			st = &structpb.Struct{Fields: map[string]*structpb.Value{}}
+			envoyMetadata.FilterMetadata[ns] = st
+		} else if st.Fields == nil {
+			st.Fields = map[string]*structpb.Value{}
+		}
+		st.Fields[k] = structpb.NewStringValue(v)
+	}
+}
+
 func addIstioEndpointLabel(metadata *core.Metadata, key string, val *structpb.Value) {
 	if _, ok := metadata.FilterMetadata[IstioMetadataKey]; !ok {
 		metadata.FilterMetadata[IstioMetadataKey] = &structpb.Struct{
@@ -759,9 +861,30 @@ func MaybeBuildStatefulSessionFilterConfig(svc *model.Service) *statefulsession.
 	return nil
 }
 
+// EffectiveTrafficPolicy returns the traffic policy of a destination rule applying to a port of a subset, or of the
+// whole destination if the subset name is empty. From lowest to highest precedence, it merges the top-level policy
+// of the destination rule, its port level settings, the policy of the subset and the port level settings of the
+// subset.
+func EffectiveTrafficPolicy(dr *networking.DestinationRule, subsetName string, port *model.Port) *networking.TrafficPolicy {
+	policy := MergeTrafficPolicy(nil, dr.GetTrafficPolicy(), port)
+	if subsetName == "" {
+		return policy
+	}
+	for _, subset := range dr.GetSubsets() {
+		if subset.Name == subsetName {
+			return MergeTrafficPolicy(policy, subset.TrafficPolicy, port)
+		}
+	}
+	return policy
+}
+
 // MergeTrafficPolicy returns the merged TrafficPolicy for a destination-level and subset-level policy on a given port.
 func MergeTrafficPolicy(original, subsetPolicy *networking.TrafficPolicy, port *model.Port) *networking.TrafficPolicy {
 	if subsetPolicy == nil {
+		// The port level settings of the original policy still apply without a subset policy.
+		if original != nil && len(original.PortLevelSettings) != 0 {
+			return MergeTrafficPolicy(nil, original, port)
+		}
 		return original
 	}
 
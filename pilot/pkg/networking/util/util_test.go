@@ -16,6 +16,7 @@ package util
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 	"testing"
 
@@ -1249,6 +1250,32 @@ func TestEndpointMetadata(t *testing.T) {
 	}
 }
 
+func TestAppendLbEndpointMetadataAnnotations(t *testing.T) {
+	test.SetForTest(t, &features.EndpointTelemetryLabel, false)
+	test.SetForTest(t, &features.EndpointMetadataAnnotations, map[string]string{
+		"my.org/billing-tier": "envoy.lb",
+		"my.org/owner":        "my.org.filter",
+	})
+	input := &core.Metadata{
+		FilterMetadata: map[string]*structpb.Struct{
+			"envoy.lb": {Fields: map[string]*structpb.Value{"version": structpb.NewStringValue("v1")}},
+		},
+	}
+	AppendLbEndpointMetadata(&model.EndpointMetadata{
+		Annotations: map[string]string{"my.org/billing-tier": "gold", "my.org/owner": "team-a", "other": "ignored"},
+	}, input)
+	want := &core.Metadata{
+		FilterMetadata: map[string]*structpb.Struct{
+			"envoy.lb": {Fields: map[string]*structpb.Value{
+				"version":             structpb.NewStringValue("v1"),
+				"my.org/billing-tier": structpb.NewStringValue("gold"),
+			}},
+			"my.org.filter": {Fields: map[string]*structpb.Value{"my.org/owner": structpb.NewStringValue("team-a")}},
+		},
+	}
+	assert.Equal(t, input, want)
+}
+
 func TestByteCount(t *testing.T) {
 	cases := []struct {
 		in  int
@@ -1402,6 +1429,33 @@ func TestMergeTrafficPolicy(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "no subset policy, port level settings of the parent policy",
+			original: &networking.TrafficPolicy{
+				ConnectionPool: &networking.ConnectionPoolSettings{
+					Http: &networking.ConnectionPoolSettings_HTTPSettings{
+						MaxRetries: 10,
+					},
+				},
+				PortLevelSettings: []*networking.TrafficPolicy_PortTrafficPolicy{
+					{
+						Port: &networking.PortSelector{
+							Number: 8080,
+						},
+						OutlierDetection: &networking.OutlierDetection{
+							ConsecutiveErrors: 13,
+						},
+					},
+				},
+			},
+			subset: nil,
+			port:   &model.Port{Port: 8080},
+			expected: &networking.TrafficPolicy{
+				OutlierDetection: &networking.OutlierDetection{
+					ConsecutiveErrors: 13,
+				},
+			},
+		},
 		{
 			name:     "no parent policy",
 			original: nil,
@@ -1633,3 +1687,88 @@ func TestMergeTrafficPolicy(t *testing.T) {
 		})
 	}
 }
+
+func TestEffectiveTrafficPolicy(t *testing.T) {
+	failover := func(labels ...string) *networking.LoadBalancerSettings {
+		return &networking.LoadBalancerSettings{
+			LocalityLbSetting: &networking.LocalityLoadBalancerSetting{FailoverPriority: labels},
+		}
+	}
+	outlier := &networking.OutlierDetection{ConsecutiveErrors: 5}
+	dr := &networking.DestinationRule{
+		TrafficPolicy: &networking.TrafficPolicy{
+			LoadBalancer:     failover("topology.kubernetes.io/region"),
+			OutlierDetection: outlier,
+			PortLevelSettings: []*networking.TrafficPolicy_PortTrafficPolicy{{
+				Port:             &networking.PortSelector{Number: 9090},
+				LoadBalancer:     failover("topology.kubernetes.io/zone"),
+				OutlierDetection: outlier,
+			}},
+		},
+		Subsets: []*networking.Subset{
+			{Name: "v1"},
+			{
+				Name: "v2",
+				TrafficPolicy: &networking.TrafficPolicy{
+					PortLevelSettings: []*networking.TrafficPolicy_PortTrafficPolicy{{
+						Port:         &networking.PortSelector{Number: 9090},
+						LoadBalancer: failover("app.kubernetes.io/version"),
+					}},
+				},
+			},
+		},
+	}
+	cases := []struct {
+		name     string
+		subset   string
+		port     int
+		expected *networking.TrafficPolicy
+	}{
+		{
+			name:     "destination",
+			port:     8080,
+			expected: &networking.TrafficPolicy{LoadBalancer: failover("topology.kubernetes.io/region"), OutlierDetection: outlier},
+		},
+		{
+			name:     "destination port level",
+			port:     9090,
+			expected: &networking.TrafficPolicy{LoadBalancer: failover("topology.kubernetes.io/zone"), OutlierDetection: outlier},
+		},
+		{
+			name:     "subset without policy inherits the port level settings",
+			subset:   "v1",
+			port:     9090,
+			expected: &networking.TrafficPolicy{LoadBalancer: failover("topology.kubernetes.io/zone"), OutlierDetection: outlier},
+		},
+		{
+			name:     "subset port level",
+			subset:   "v2",
+			port:     9090,
+			expected: &networking.TrafficPolicy{LoadBalancer: failover("app.kubernetes.io/version")},
+		},
+		{
+			name:     "subset other port",
+			subset:   "v2",
+			port:     8080,
+			expected: &networking.TrafficPolicy{LoadBalancer: failover("topology.kubernetes.io/region"), OutlierDetection: outlier},
+		},
+		{
+			name:     "unknown subset",
+			subset:   "v3",
+			port:     8080,
+			expected: &networking.TrafficPolicy{LoadBalancer: failover("topology.kubernetes.io/region"), OutlierDetection: outlier},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, EffectiveTrafficPolicy(dr, tt.subset, &model.Port{Port: tt.port}), tt.expected)
+		})
+	}
+}
+
+func TestScaleWeight(t *testing.T) {
+	assert.Equal(t, ScaleWeight(4, 2.5), uint32(10))
+	assert.Equal(t, ScaleWeight(4, 0.1), uint32(1))
+	assert.Equal(t, ScaleWeight(4, 0), uint32(1))
+	assert.Equal(t, ScaleWeight(math.MaxUint32, 2), uint32(math.MaxUint32))
+}
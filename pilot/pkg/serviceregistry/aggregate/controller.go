@@ -28,6 +28,7 @@ import (
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/network"
 	"istio.io/istio/pkg/util/sets"
 	"istio.io/istio/pkg/workloadapi/security"
 )
@@ -55,17 +56,29 @@ type Controller struct {
 	model.NetworkGatewaysHandler
 }
 
-func (c *Controller) Waypoint(scope model.WaypointScope) []netip.Addr {
+func (c *Controller) Waypoint(nw network.ID, scope model.WaypointScope) []netip.Addr {
 	if !features.EnableAmbientControllers {
 		return nil
 	}
 	var res []netip.Addr
 	for _, p := range c.GetRegistries() {
-		res = append(res, p.Waypoint(scope)...)
+		res = append(res, p.Waypoint(nw, scope)...)
 	}
 	return res
 }
 
+func (c *Controller) WaypointScopeFor(namespace, serviceAccount string, workloadLabels labels.Instance) (model.WaypointScope, bool) {
+	if !features.EnableAmbientControllers {
+		return model.WaypointScope{}, false
+	}
+	for _, p := range c.GetRegistries() {
+		if scope, f := p.WaypointScopeFor(namespace, serviceAccount, workloadLabels); f {
+			return scope, true
+		}
+	}
+	return model.WaypointScope{}, false
+}
+
 func (c *Controller) WorkloadsForWaypoint(scope model.WaypointScope) []*model.WorkloadInfo {
 	if !features.EnableAmbientControllers {
 		return nil
@@ -349,6 +362,21 @@ func (c *Controller) GetProxyServiceTargets(node *model.Proxy) []model.ServiceTa
 	return out
 }
 
+// RecordProxyLocality records the locality asserted by the proxy in the registries of its cluster applying it.
+func (c *Controller) RecordProxyLocality(proxy *model.Proxy, locality string, override bool) {
+	nodeClusterID := nodeClusterID(proxy)
+	for _, r := range c.GetRegistries() {
+		if skipSearchingRegistryForProxy(nodeClusterID, r) {
+			continue
+		}
+		if recorder, ok := r.(*registryEntry).Instance.(model.ProxyLocalityRecorder); ok {
+			recorder.RecordProxyLocality(proxy, locality, override)
+		}
+	}
+}
+
+var _ model.ProxyLocalityRecorder = &Controller{}
+
 func (c *Controller) GetProxyWorkloadLabels(proxy *model.Proxy) labels.Instance {
 	clusterID := nodeClusterID(proxy)
 	for _, r := range c.GetRegistries() {
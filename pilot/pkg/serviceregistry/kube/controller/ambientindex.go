@@ -41,6 +41,7 @@ import (
 	"istio.io/istio/pkg/kube/controllers"
 	kubelabels "istio.io/istio/pkg/kube/labels"
 	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/network"
 	"istio.io/istio/pkg/spiffe"
 	"istio.io/istio/pkg/util/sets"
 	"istio.io/istio/pkg/workloadapi"
@@ -50,7 +51,8 @@ type AmbientIndex interface {
 	Lookup(key string) []*model.AddressInfo
 	All() []*model.AddressInfo
 	WorkloadsForWaypoint(scope model.WaypointScope) []*model.WorkloadInfo
-	Waypoint(scope model.WaypointScope) []netip.Addr
+	Waypoint(nw network.ID, scope model.WaypointScope) []netip.Addr
+	WaypointScopeFor(namespace, serviceAccount string, workloadLabels labels.Instance) (model.WaypointScope, bool)
 	CalculateUpdatedWorkloads(pods map[string]*v1.Pod, workloadEntries map[networkAddress]*apiv1alpha3.WorkloadEntry, c *Controller) map[model.ConfigKey]struct{}
 	HandleSelectedNamespace(ns string, pods []*v1.Pod, c *Controller)
 }
@@ -80,6 +82,8 @@ type AmbientIndexImpl struct {
 
 	// Map of Scope -> address
 	waypoints map[model.WaypointScope]*workloadapi.GatewayAddress
+	// waypointScopes indexes the scopes of the waypoints, to resolve the waypoint owning a workload.
+	waypointScopes model.WaypointScopes
 
 	// map of service entry name/namespace to the service entry.
 	// used on pod updates to add VIPs to pods from service entries.
@@ -243,63 +247,79 @@ func (c *Controller) WorkloadsForWaypoint(scope model.WaypointScope) []*model.Wo
 	return c.ambientIndex.WorkloadsForWaypoint(scope)
 }
 
-// Waypoint returns the addresses of the waypoints matching the scope.
+// Waypoint returns the addresses of the waypoints on the network matching the scope.
+// Pod IP ranges may overlap across networks, so waypoints of other networks are never returned.
 //
 // NOTE: As an interface method of AmbientIndex, this locks the index.
-func (a *AmbientIndexImpl) Waypoint(scope model.WaypointScope) []netip.Addr {
+func (a *AmbientIndexImpl) Waypoint(nw network.ID, scope model.WaypointScope) []netip.Addr {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 	// TODO need to handle case where waypoints are dualstack/have multiple addresses
-	if addr, f := a.waypoints[scope]; f {
-		switch address := addr.Destination.(type) {
-		case *workloadapi.GatewayAddress_Address:
-			if ip, ok := netip.AddrFromSlice(address.Address.GetAddress()); ok {
-				return []netip.Addr{ip}
-			}
-		case *workloadapi.GatewayAddress_Hostname:
-			// TODO
-		}
+	if ip, f := waypointAddress(a.waypoints[scope], nw); f {
+		return []netip.Addr{ip}
 	}
 
 	// Now look for namespace-wide
 	scope.ServiceAccount = ""
-	if addr, f := a.waypoints[scope]; f {
-		switch address := addr.Destination.(type) {
-		case *workloadapi.GatewayAddress_Address:
-			if ip, ok := netip.AddrFromSlice(address.Address.GetAddress()); ok {
-				return []netip.Addr{ip}
-			}
-		case *workloadapi.GatewayAddress_Hostname:
-			// TODO
-		}
+	if ip, f := waypointAddress(a.waypoints[scope], nw); f {
+		return []netip.Addr{ip}
 	}
 
 	return nil
 }
 
-// Waypoint finds all waypoint IP addresses for a given scope.  Performs first a Namespace+ServiceAccount
+// waypointAddress returns the IP of the waypoint, if it is on the network.
+func waypointAddress(addr *workloadapi.GatewayAddress, nw network.ID) (netip.Addr, bool) {
+	switch address := addr.GetDestination().(type) {
+	case *workloadapi.GatewayAddress_Address:
+		if address.Address.GetNetwork() != nw.String() {
+			return netip.Addr{}, false
+		}
+		return netip.AddrFromSlice(address.Address.GetAddress())
+	case *workloadapi.GatewayAddress_Hostname:
+		// TODO
+	}
+	return netip.Addr{}, false
+}
+
+// Waypoint finds all waypoint IP addresses on the network for a given scope.  Performs first a Namespace+ServiceAccount
 // then falls back to any Namespace wide waypoints
-func (c *Controller) Waypoint(scope model.WaypointScope) []netip.Addr {
-	return c.ambientIndex.Waypoint(scope)
+func (c *Controller) Waypoint(nw network.ID, scope model.WaypointScope) []netip.Addr {
+	return c.ambientIndex.Waypoint(nw, scope)
 }
 
-func (a *AmbientIndexImpl) matchesScope(scope model.WaypointScope, w *model.WorkloadInfo) bool {
-	if w.Namespace != scope.Namespace {
-		return false
+// WaypointScopeFor returns the scope of the waypoint owning the workload, among the waypoints of its namespace.
+//
+// NOTE: As an interface method of AmbientIndex, this locks the index.
+func (a *AmbientIndexImpl) WaypointScopeFor(namespace, serviceAccount string, workloadLabels labels.Instance) (model.WaypointScope, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.waypointScopes.Resolve(namespace, serviceAccount, workloadLabels)
+}
+
+func (c *Controller) WaypointScopeFor(namespace, serviceAccount string, workloadLabels labels.Instance) (model.WaypointScope, bool) {
+	return c.ambientIndex.WaypointScopeFor(namespace, serviceAccount, workloadLabels)
+}
+
+// waypointFor returns the address of the waypoint owning the workload, if any. Must be called with the lock held.
+func (a *AmbientIndexImpl) waypointFor(namespace, serviceAccount string, workloadLabels labels.Instance) *workloadapi.GatewayAddress {
+	scope, found := a.waypointScopes.Resolve(namespace, serviceAccount, workloadLabels)
+	if !found {
+		return nil
 	}
+	return a.waypoints[scope]
+}
+
+func (a *AmbientIndexImpl) matchesScope(scope model.WaypointScope, w *model.WorkloadInfo) bool {
 	// Filter out waypoints.
 	if w.Labels[constants.ManagedGatewayLabel] == constants.ManagedGatewayMeshControllerLabel {
 		return false
 	}
-	if len(scope.ServiceAccount) == 0 {
-		// We are a namespace wide waypoint. SA scope take precedence.
-		// Check if there is one for this workloads service account
-		if _, f := a.waypoints[model.WaypointScope{Namespace: scope.Namespace, ServiceAccount: w.ServiceAccount}]; f {
-			return false
-		}
-		return true
+	// Service account scopes take precedence, then the most specific selector scopes, over namespace wide waypoints.
+	if owner, found := a.waypointScopes.Resolve(w.Namespace, w.ServiceAccount, w.Labels); found {
+		return owner == scope
 	}
-	return w.ServiceAccount == scope.ServiceAccount
+	return a.waypointScopes.Matches(scope, w.Namespace, w.ServiceAccount, w.Labels)
 }
 
 func (c *Controller) constructService(svc *v1.Service) *model.ServiceInfo {
@@ -353,12 +373,7 @@ func (a *AmbientIndexImpl) extractWorkload(p *v1.Pod, c *Controller) *model.Work
 	if p.Labels[constants.ManagedGatewayLabel] == constants.ManagedGatewayMeshControllerLabel {
 		// Waypoints do not have waypoints
 	} else {
-		// First check for a waypoint for our SA explicit
-		found := false
-		if waypoint, found = a.waypoints[model.WaypointScope{Namespace: p.Namespace, ServiceAccount: p.Spec.ServiceAccountName}]; !found {
-			// if there are none, check namespace wide waypoints
-			waypoint = a.waypoints[model.WaypointScope{Namespace: p.Namespace}]
-		}
+		waypoint = a.waypointFor(p.Namespace, p.Spec.ServiceAccountName, p.Labels)
 	}
 
 	policies := c.selectorAuthorizationPolicies(p.Namespace, p.Labels)
@@ -723,7 +738,11 @@ func (a *AmbientIndexImpl) handleKubeGateway(_, newObj any, isDelete bool, c *Co
 	// ignore Kubernetes Gateways which aren't waypoints
 	// TODO: should this be WaypointGatewayClass or matches a label?
 	if gateway.Spec.GatewayClassName == constants.WaypointGatewayClassName && len(gateway.Status.Addresses) > 0 {
-		scope := model.WaypointScope{Namespace: gateway.Namespace, ServiceAccount: gateway.Annotations[constants.WaypointServiceAccount]}
+		scope := model.WaypointScope{
+			Namespace:      gateway.Namespace,
+			ServiceAccount: gateway.Annotations[constants.WaypointServiceAccount],
+			Selector:       model.WaypointSelector(gateway.Annotations[constants.WaypointSelector]),
+		}
 
 		waypointPort := uint32(15008)
 		for _, l := range gateway.Spec.Listeners {
@@ -753,9 +772,11 @@ func (a *AmbientIndexImpl) handleKubeGateway(_, newObj any, isDelete bool, c *Co
 		defer a.mu.Unlock()
 		if isDelete {
 			delete(a.waypoints, scope)
+			a.waypointScopes.Delete(scope)
 			updates.Merge(a.updateWaypoint(scope, addr, true))
 		} else if !proto.Equal(a.waypoints[scope], addr) {
 			a.waypoints[scope] = addr
+			a.waypointScopes.Insert(scope)
 			updates.Merge(a.updateWaypoint(scope, addr, false))
 		}
 
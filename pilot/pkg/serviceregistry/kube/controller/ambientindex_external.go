@@ -17,6 +17,7 @@ package controller
 import (
 	"fmt"
 	"net/netip"
+	"strings"
 
 	"golang.org/x/exp/maps"
 	"google.golang.org/protobuf/proto"
@@ -225,13 +226,8 @@ func (a *AmbientIndexImpl) extractWorkloadEntrySpec(w *v1alpha3.WorkloadEntry, n
 	if w.Labels[constants.ManagedGatewayLabel] == constants.ManagedGatewayMeshControllerLabel {
 		// Waypoints do not have waypoints
 	} else {
-		// First check for a waypoint for our SA explicit
 		// TODO: this is not robust against temporary waypoint downtime. We also need the users intent (Gateway).
-		found := false
-		if waypoint, found = a.waypoints[model.WaypointScope{Namespace: ns, ServiceAccount: w.ServiceAccount}]; !found {
-			// if there are none, check namespace wide waypoints
-			waypoint = a.waypoints[model.WaypointScope{Namespace: ns}]
-		}
+		waypoint = a.waypointFor(ns, w.ServiceAccount, w.Labels)
 	}
 	policies := c.selectorAuthorizationPolicies(ns, w.Labels)
 	wl := a.constructWorkloadFromWorkloadEntry(w, ns, name, parentServiceEntry, waypoint, policies, c)
@@ -429,20 +425,23 @@ func (a *AmbientIndexImpl) updateWaypointForWorkload(byWorkload map[string]*mode
 		if wl.Labels[constants.ManagedGatewayLabel] == constants.ManagedGatewayMeshControllerLabel {
 			continue
 		}
-		if wl.Namespace != scope.Namespace || (scope.ServiceAccount != "" && wl.ServiceAccount != scope.ServiceAccount) {
+		if !a.waypointScopes.Matches(scope, wl.Namespace, wl.ServiceAccount, wl.Labels) {
 			continue
 		}
-		if isDelete {
-			if wl.Waypoint != nil && proto.Equal(wl.Waypoint, addr) {
-				wl.Waypoint = nil
-				// If there was a change, also update the VIPs and record for a push
-				updates.Insert(model.ConfigKey{Kind: kind.Address, Name: wl.ResourceName()})
-			}
-		} else {
-			if wl.Waypoint == nil || !proto.Equal(wl.Waypoint, addr) {
-				wl.Waypoint = addr
-				// If there was a change, also update the VIPs and record for a push
-				updates.Insert(model.ConfigKey{Kind: kind.Address, Name: wl.ResourceName()})
+		if isDelete && (wl.Waypoint == nil || !proto.Equal(wl.Waypoint, addr)) {
+			continue
+		}
+		// The workload may be owned by another waypoint of the namespace, more specific than this one or, once this
+		// one is deleted, less specific.
+		waypoint := a.waypointFor(wl.Namespace, wl.ServiceAccount, wl.Labels)
+		if !proto.Equal(wl.Waypoint, waypoint) {
+			wl.Waypoint = waypoint
+			// If there was a change, also update the VIPs and record for a push
+			updates.Insert(model.ConfigKey{Kind: kind.Address, Name: wl.ResourceName()})
+			// The inbound VIP clusters of the waypoints, previous and new, select the endpoints they own.
+			for nsHostname := range wl.Services {
+				namespace, hostname, _ := strings.Cut(nsHostname, "/")
+				updates.Insert(model.ConfigKey{Kind: kind.ServiceEntry, Name: hostname, Namespace: namespace})
 			}
 		}
 	}
@@ -51,6 +51,7 @@ import (
 	"istio.io/istio/pkg/kube/kclient/clienttest"
 	"istio.io/istio/pkg/kube/kubetypes"
 	"istio.io/istio/pkg/network"
+	"istio.io/istio/pkg/slices"
 	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/test/util/assert"
 	"istio.io/istio/pkg/test/util/file"
@@ -357,10 +358,12 @@ func TestAmbientIndex_WaypointAddressAddedToWorkloads(t *testing.T) {
 	assert.Equal(t,
 		s.lookup(s.addrXdsName("127.0.0.200"))[0].Address.GetWorkload().Waypoint,
 		nil)
-	assert.Equal(t, len(s.controller.Waypoint(model.WaypointScope{Namespace: testNS, ServiceAccount: "namespace-wide"})), 1)
-	for _, k := range s.controller.Waypoint(model.WaypointScope{Namespace: testNS, ServiceAccount: "namespace-wide"}) {
+	assert.Equal(t, len(s.controller.Waypoint(testNW, model.WaypointScope{Namespace: testNS, ServiceAccount: "namespace-wide"})), 1)
+	for _, k := range s.controller.Waypoint(testNW, model.WaypointScope{Namespace: testNS, ServiceAccount: "namespace-wide"}) {
 		assert.Equal(t, k.AsSlice(), netip.MustParseAddr("10.0.0.2").AsSlice())
 	}
+	// Pod IP ranges may overlap across networks, waypoints of other networks must not be used.
+	assert.Equal(t, len(s.controller.Waypoint("other-network", model.WaypointScope{Namespace: testNS, ServiceAccount: "namespace-wide"})), 0)
 
 	s.addService(t, "svc1",
 		map[string]string{},
@@ -416,7 +419,8 @@ func TestAmbientIndex_WaypointAddressAddedToWorkloads(t *testing.T) {
 	s.assertEvent(t, s.podXdsName("pod2"))
 
 	s.deleteWaypoint(t, "waypoint-ns")
-	s.assertEvent(t, s.podXdsName("pod1"))
+	// The inbound VIP clusters of the services of the workloads are also pushed.
+	s.assertEvent(t, s.podXdsName("pod1"), s.hostnameForService("svc1"))
 	s.deleteService(t, "waypoint-ns")
 	s.assertEvent(t,
 		s.podXdsName("waypoint-ns-pod"),
@@ -434,6 +438,59 @@ func TestAmbientIndex_WaypointAddressAddedToWorkloads(t *testing.T) {
 		nil)
 }
 
+func TestAmbientIndex_SelectorWaypoints(t *testing.T) {
+	test.SetForTest(t, &features.EnableAmbientControllers, true)
+	s := newAmbientTestServer(t, testC, testNW)
+	waypointOf := func(ip string) []byte {
+		t.Helper()
+		return s.lookup(s.addrXdsName(ip))[0].Address.GetWorkload().Waypoint.GetAddress().GetAddress()
+	}
+
+	s.addPods(t, "127.0.0.1", "pod1", "sa1", map[string]string{"app": "a"}, nil, true, corev1.PodRunning)
+	s.assertEvent(t, s.podXdsName("pod1"))
+	s.addPods(t, "127.0.0.2", "pod2", "sa1", map[string]string{"app": "a", "version": "v2"}, nil, true, corev1.PodRunning)
+	s.assertEvent(t, s.podXdsName("pod2"))
+	s.addPods(t, "127.0.0.3", "pod3", "sa2", map[string]string{"app": "b"}, nil, true, corev1.PodRunning)
+	s.assertEvent(t, s.podXdsName("pod3"))
+
+	s.addWaypoint(t, "10.0.0.2", "waypoint-ns", "", true)
+	s.assertEvent(t, s.podXdsName("pod1"), s.podXdsName("pod2"), s.podXdsName("pod3"))
+	s.addWaypointWithAnnotations(t, "10.0.0.3", "waypoint-a", map[string]string{constants.WaypointSelector: "app=a"}, true)
+	s.assertEvent(t, s.podXdsName("pod1"), s.podXdsName("pod2"))
+	// The selectors overlap: the most specific one owns the workloads matching both.
+	s.addWaypointWithAnnotations(t, "10.0.0.4", "waypoint-a-v2", map[string]string{constants.WaypointSelector: "version=v2, app=a"}, true)
+	s.assertEvent(t, s.podXdsName("pod2"))
+
+	assert.Equal(t, waypointOf("127.0.0.1"), netip.MustParseAddr("10.0.0.3").AsSlice())
+	assert.Equal(t, waypointOf("127.0.0.2"), netip.MustParseAddr("10.0.0.4").AsSlice())
+	assert.Equal(t, waypointOf("127.0.0.3"), netip.MustParseAddr("10.0.0.2").AsSlice())
+
+	names := func(scope model.WaypointScope) []string {
+		var out []string
+		for _, w := range s.controller.WorkloadsForWaypoint(scope) {
+			out = append(out, w.Name)
+		}
+		return slices.Sort(out)
+	}
+	assert.Equal(t, names(model.WaypointScope{Namespace: testNS}), []string{"pod3"})
+	assert.Equal(t, names(model.WaypointScope{Namespace: testNS, Selector: "app=a"}), []string{"pod1"})
+	assert.Equal(t, names(model.WaypointScope{Namespace: testNS, Selector: "app=a,version=v2"}), []string{"pod2"})
+	scope, _ := s.controller.WaypointScopeFor(testNS, "sa1", map[string]string{"app": "a", "version": "v2"})
+	assert.Equal(t, scope, model.WaypointScope{Namespace: testNS, Selector: "app=a,version=v2"})
+
+	// Once the most specific waypoint is deleted, its workloads fall back to the next one.
+	s.deleteWaypoint(t, "waypoint-a-v2")
+	s.assertEvent(t, s.podXdsName("pod2"))
+	assert.Equal(t, waypointOf("127.0.0.2"), netip.MustParseAddr("10.0.0.3").AsSlice())
+
+	// A waypoint taking over workloads also pushes the inbound VIP clusters of their services, which select the
+	// endpoints owned by each waypoint.
+	s.addService(t, "svc-b", nil, nil, []int32{80}, map[string]string{"app": "b"}, "10.0.0.10")
+	s.assertEvent(t, s.podXdsName("pod3"), s.svcXdsName("svc-b"))
+	s.addWaypointWithAnnotations(t, "10.0.0.5", "waypoint-b", map[string]string{constants.WaypointSelector: "app=b"}, true)
+	s.assertEvent(t, s.podXdsName("pod3"), s.hostnameForService("svc-b"))
+}
+
 // TODO(nmittler): Consider splitting this into multiple, smaller tests.
 func TestAmbientIndex_Policy(t *testing.T) {
 	test.SetForTest(t, &features.EnableAmbientControllers, true)
@@ -978,6 +1035,15 @@ func newAmbientTestServer(t *testing.T, clusterID cluster.ID, networkID network.
 
 func (s *ambientTestServer) addWaypoint(t *testing.T, ip, name, sa string, ready bool) {
 	t.Helper()
+	var annotations map[string]string
+	if sa != "" {
+		annotations = map[string]string{constants.WaypointServiceAccount: sa}
+	}
+	s.addWaypointWithAnnotations(t, ip, name, annotations, ready)
+}
+
+func (s *ambientTestServer) addWaypointWithAnnotations(t *testing.T, ip, name string, annotations map[string]string, ready bool) {
+	t.Helper()
 
 	fromSame := k8sbeta.NamespacesFromSame
 	gatewaySpec := k8sbeta.GatewaySpec{
@@ -1002,17 +1068,13 @@ func (s *ambientTestServer) addWaypoint(t *testing.T, ip, name, sa string, ready
 			APIVersion: gvk.KubernetesGateway.GroupVersion(),
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: testNS,
+			Name:        name,
+			Namespace:   testNS,
+			Annotations: annotations,
 		},
 		Spec:   gatewaySpec,
 		Status: k8sbeta.GatewayStatus{},
 	}
-	if sa != "" {
-		annotations := make(map[string]string, 1)
-		annotations[constants.WaypointServiceAccount] = sa
-		gateway.Annotations = annotations
-	}
 	if ready {
 		addrType := k8sbeta.IPAddressType
 		gateway.Status = k8sbeta.GatewayStatus{
@@ -206,11 +206,12 @@ func TestAmbientIndex_WorkloadEntries(t *testing.T) {
 	s.assertEvent(t, s.wleXdsName("name6"))
 
 	s.deleteWaypoint(t, "waypoint-ns")
-	// all affected addresses with the waypoint should be updated
+	// all affected addresses with the waypoint, and the inbound VIP clusters of their services, should be updated
 	s.assertEvent(t,
 		s.wleXdsName("name1"),
 		s.wleXdsName("name2"),
-		s.wleXdsName("name3"))
+		s.wleXdsName("name3"),
+		s.hostnameForService("svc1"))
 
 	s.deleteService(t, "waypoint-ns")
 	s.assertEvent(t, s.podXdsName("waypoint-ns-pod"),
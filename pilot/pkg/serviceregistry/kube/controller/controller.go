@@ -22,6 +22,7 @@ import (
 
 	"github.com/hashicorp/go-multierror"
 	"go.uber.org/atomic"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	klabels "k8s.io/apimachinery/pkg/labels"
@@ -38,6 +39,7 @@ import (
 	"istio.io/istio/pilot/pkg/serviceregistry/util/workloadinstances"
 	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/config/mesh"
@@ -140,6 +142,9 @@ type Options struct {
 
 	ConfigController model.ConfigStoreController
 	ConfigCluster    bool
+
+	// RolloutWeights records the rollout weights of the Deployments, when PILOT_ENABLE_ROLLOUT_WEIGHTS is set.
+	RolloutWeights *model.RolloutWeights
 }
 
 func (o *Options) GetFilter() namespace.DiscoveryFilter {
@@ -158,13 +163,16 @@ type kubernetesNode struct {
 // controllerInterface is a simplified interface for the Controller used for testing.
 type controllerInterface interface {
 	getPodLocality(pod *v1.Pod) string
+	getPodDataResidency(pod *v1.Pod) string
+	getNodeAddress(nodeName string) string
 	Network(endpointIP string, labels labels.Instance) network.ID
 	Cluster() cluster.ID
 }
 
 var (
-	_ controllerInterface      = &Controller{}
-	_ serviceregistry.Instance = &Controller{}
+	_ controllerInterface         = &Controller{}
+	_ serviceregistry.Instance    = &Controller{}
+	_ model.ProxyLocalityRecorder = &Controller{}
 )
 
 // Controller is a collection of synchronized resource watchers
@@ -190,6 +198,9 @@ type Controller struct {
 	imports serviceImportCache
 	pods    *PodCache
 
+	// deployments is only set when the rollout weights of the Deployments are watched.
+	deployments kclient.Client[*appsv1.Deployment]
+
 	crdHandlers                []func(name string)
 	handlers                   model.ControllerHandlers
 	namespaceDiscoveryHandlers []func(ns string, event model.Event)
@@ -200,6 +211,8 @@ type Controller struct {
 	sync.RWMutex
 	// servicesMap stores hostname ==> service, it is used to reduce convertService calls.
 	servicesMap map[host.Name]*model.Service
+	// externalNames tracks the ExternalName services resolved by istiod.
+	externalNames *externalNames
 	// nodeSelectorsForServices stores hostname => label selectors that can be used to
 	// refine the set of node port IPs for a service.
 	nodeSelectorsForServices map[host.Name]labels.Instance
@@ -232,6 +245,7 @@ func NewController(kubeClient kubelib.Client, options Options) *Controller {
 		client:                   kubeClient,
 		queue:                    queue.NewQueueWithID(1*time.Second, string(options.ClusterID)),
 		servicesMap:              make(map[host.Name]*model.Service),
+		externalNames:            newExternalNames(lookupExternalName),
 		nodeSelectorsForServices: make(map[host.Name]labels.Instance),
 		nodeInfoMap:              make(map[string]kubernetesNode),
 		workloadInstancesIndex:   workloadinstances.NewIndex(),
@@ -292,6 +306,9 @@ func NewController(kubeClient kubelib.Client, options Options) *Controller {
 	}
 	c.exports = newServiceExportCache(c)
 	c.imports = newServiceImportCache(c)
+	if features.EnableRolloutWeights && c.opts.RolloutWeights != nil {
+		c.initRollouts()
+	}
 
 	c.meshWatcher = options.MeshWatcher
 	if c.opts.MeshNetworksWatcher != nil {
@@ -361,15 +378,46 @@ func (c *Controller) Network(endpointIP string, labels labels.Instance) network.
 }
 
 func (c *Controller) Cleanup() error {
-	if err := queue.WaitForClose(c.queue, 30*time.Second); err != nil {
-		log.Warnf("queue for removed kube registry %q may not be done processing: %v", c.Cluster(), err)
-	}
+	c.waitForClose()
 	if c.opts.XDSUpdater != nil {
 		c.opts.XDSUpdater.RemoveShard(model.ShardKeyFromRegistry(c))
 	}
+	if c.deployments != nil && c.opts.RolloutWeights.DeleteCluster(c.Cluster()) {
+		c.pushRolloutWeights()
+	}
 	return nil
 }
 
+// Detach is like Cleanup, but keeps the endpoint shard of the cluster for the registry replacing this one to resume.
+func (c *Controller) Detach() {
+	c.waitForClose()
+}
+
+func (c *Controller) waitForClose() {
+	if err := queue.WaitForClose(c.queue, 30*time.Second); err != nil {
+		log.Warnf("queue for removed kube registry %q may not be done processing: %v", c.Cluster(), err)
+	}
+}
+
+// resumeShard reconciles the endpoint shard kept from the previous registry of the cluster, once this registry is
+// synced: the services that are gone are deleted and the ones left without endpoint slices are emptied. The other
+// services had their endpoints replaced by the initial sync already.
+func (c *Controller) resumeShard(prev []*model.Service) {
+	if c.opts.XDSUpdater == nil {
+		return
+	}
+	shard := model.ShardKeyFromRegistry(c)
+	for _, svc := range prev {
+		if c.GetService(svc.Hostname) == nil {
+			c.opts.XDSUpdater.SvcUpdate(shard, string(svc.Hostname), svc.Attributes.Namespace, model.EventDelete)
+			continue
+		}
+		if !c.endpoints.endpointCache.Has(svc.Hostname) {
+			c.endpoints.updateEDS([]host.Name{svc.Hostname}, svc.Attributes.Namespace)
+		}
+	}
+}
+
 func (c *Controller) onServiceEvent(_, curr *v1.Service, event model.Event) error {
 	log.Debugf("Handle event %s for service %s in namespace %s", event, curr.Name, curr.Namespace)
 
@@ -393,6 +441,7 @@ func (c *Controller) deleteService(svc *model.Service) {
 	_, isNetworkGateway := c.networkGatewaysBySvc[svc.Hostname]
 	delete(c.networkGatewaysBySvc, svc.Hostname)
 	c.Unlock()
+	c.externalNames.untrack(svc.Hostname)
 
 	if isNetworkGateway {
 		c.NotifyGatewayHandlers()
@@ -435,6 +484,14 @@ func (c *Controller) addOrUpdateService(curr *v1.Service, currConv *model.Servic
 	prevConv = c.servicesMap[currConv.Hostname]
 	c.servicesMap[currConv.Hostname] = currConv
 	c.Unlock()
+	c.trackExternalName(currConv)
+	// The not ready endpoints are only kept for services publishing them, and the terminating endpoints for services
+	// including them. The address of the endpoints depends on the address type of the service.
+	if prevConv != nil && (prevConv.Attributes.PublishNotReadyAddresses != currConv.Attributes.PublishNotReadyAddresses ||
+		prevConv.IncludeTerminating() != currConv.IncludeTerminating() ||
+		prevConv.Attributes.AddressType != currConv.Attributes.AddressType) {
+		updateEDSCache = true
+	}
 
 	// This full push needed to update ALL ends endpoints, even though we do a full push on service add/update
 	// as that full push is only triggered for the specific service.
@@ -466,10 +523,20 @@ func (c *Controller) buildEndpointsForService(svc *model.Service, updateCache bo
 		endpoints = append(endpoints, fep...)
 	}
 	endpoints = append(endpoints, kube.ExternalNameEndpoints(svc)...)
+	endpoints = append(endpoints, c.externalNames.endpoints(svc)...)
 	return endpoints
 }
 
-func (c *Controller) onNodeEvent(_, node *v1.Node, event model.Event) error {
+func (c *Controller) onNodeEvent(prev, node *v1.Node, event model.Event) error {
+	// The endpoints reached through the address of their node follow its changes.
+	if event == model.EventUpdate && prev != nil && nodeAddress(prev) != nodeAddress(node) {
+		for _, key := range c.endpoints.slicesOnNode(node.Name) {
+			if err := c.endpoints.sync(key.Name, key.Namespace, model.EventUpdate, true); err != nil {
+				log.Errorf("failed to resync endpoint slice %v of node %s: %v", key, node.Name, err)
+			}
+		}
+	}
+
 	var updatedNeeded bool
 	if event == model.EventDelete {
 		updatedNeeded = true
@@ -595,6 +662,7 @@ func (c *Controller) Run(stop <-chan struct{}) {
 
 	go c.imports.Run(stop)
 	go c.exports.Run(stop)
+	go c.resolveExternalNames(stop, features.ExternalNameResolutionInterval)
 
 	kubelib.WaitForCacheSync("kube controller", stop, c.informersSynced)
 	log.Infof("kube controller for %s synced after %v", c.opts.ClusterID, time.Since(st))
@@ -630,8 +698,49 @@ func (c *Controller) GetService(hostname host.Name) *model.Service {
 	return svc
 }
 
+// assertedLocality is the locality asserted by the proxy of a pod, see model.Proxy.AssertedLocality.
+type assertedLocality struct {
+	locality string
+	// override is true if the locality replaces the one of the pod, rather than only filling it in.
+	override bool
+}
+
+// RecordProxyLocality applies the locality asserted by a proxy to the endpoints of its pod.
+// The proxy must hold the identity of the pod, so that it cannot assert the locality of another workload.
+func (c *Controller) RecordProxyLocality(proxy *model.Proxy, locality string, override bool) {
+	if proxy.VerifiedIdentity == nil || len(proxy.IPAddresses) == 0 {
+		return
+	}
+	pod := c.pods.getPodByProxy(proxy)
+	if pod == nil || pod.Namespace != proxy.VerifiedIdentity.Namespace ||
+		pod.Spec.ServiceAccountName != proxy.VerifiedIdentity.ServiceAccount {
+		return
+	}
+	if !c.pods.setAssertedLocality(config.NamespacedName(pod), assertedLocality{locality: locality, override: override}) {
+		return
+	}
+	for _, key := range c.endpoints.slicesForPod(pod) {
+		if err := c.endpoints.sync(key.Name, key.Namespace, model.EventUpdate, true); err != nil {
+			log.Errorf("failed to resync endpoint slice %v of pod %s/%s: %v", key, pod.Namespace, pod.Name, err)
+		}
+	}
+}
+
 // getPodLocality retrieves the locality for a pod.
+// The locality asserted by its proxy overrides it, or fills it in when the pod has none.
 func (c *Controller) getPodLocality(pod *v1.Pod) string {
+	asserted, f := c.pods.getAssertedLocality(config.NamespacedName(pod))
+	if f && asserted.override {
+		return asserted.locality
+	}
+	if locality := c.podLocality(pod); locality != "" || !f {
+		return locality
+	}
+	return asserted.locality
+}
+
+// podLocality retrieves the locality for a pod from its label or else from the labels of its node.
+func (c *Controller) podLocality(pod *v1.Pod) string {
 	// if pod has `istio-locality` label, skip below ops
 	if len(pod.Labels[model.LocalityLabel]) > 0 {
 		return model.GetLocalityLabel(pod.Labels[model.LocalityLabel])
@@ -658,6 +767,40 @@ func (c *Controller) getPodLocality(pod *v1.Pod) string {
 	return region + "/" + zone + "/" + subzone // Format: "%s/%s/%s"
 }
 
+// getPodDataResidency retrieves the data residency of a pod, from its label or else from the label of its node.
+func (c *Controller) getPodDataResidency(pod *v1.Pod) string {
+	if residency := pod.Labels[constants.DataResidencyLabel]; residency != "" {
+		return residency
+	}
+	node := c.nodes.Get(pod.Spec.NodeName, "")
+	if node == nil {
+		return ""
+	}
+	return node.Labels[constants.DataResidencyLabel]
+}
+
+// getNodeAddress returns the internal address of a node, or else its first address.
+func (c *Controller) getNodeAddress(nodeName string) string {
+	node := c.nodes.Get(nodeName, "")
+	if node == nil {
+		return ""
+	}
+	return nodeAddress(node)
+}
+
+// nodeAddress returns the internal address of a node, or else its first address.
+func nodeAddress(node *v1.Node) string {
+	if len(node.Status.Addresses) == 0 {
+		return ""
+	}
+	for _, address := range node.Status.Addresses {
+		if address.Type == v1.NodeInternalIP && address.Address != "" {
+			return address.Address
+		}
+	}
+	return node.Status.Addresses[0].Address
+}
+
 func (c *Controller) serviceInstancesFromWorkloadInstances(svc *model.Service, reqSvcPort int) []*model.ServiceInstance {
 	// Run through all the workload instances, select ones that match the service labels
 	// only if this is a kubernetes internal service and of ClientSideLB (eds) type
@@ -738,6 +881,9 @@ func serviceInstanceFromWorkloadInstance(svc *model.Service, servicePort *model.
 		}
 	}
 
+	if targetPort.name != "" {
+		istioEndpoint.Labels = wi.EndpointLabels(targetPort.name)
+	}
 	istioEndpoint.ServicePortName = servicePort.Name
 	return &model.ServiceInstance{
 		Service:     svc,
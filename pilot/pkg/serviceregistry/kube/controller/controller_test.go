@@ -45,6 +45,7 @@ import (
 	"istio.io/istio/pilot/pkg/serviceregistry/util/xdsfake"
 	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/config/protocol"
@@ -53,6 +54,7 @@ import (
 	"istio.io/istio/pkg/kube/kclient/clienttest"
 	filter "istio.io/istio/pkg/kube/namespace"
 	"istio.io/istio/pkg/network"
+	"istio.io/istio/pkg/spiffe"
 	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/test/util/assert"
 	"istio.io/istio/pkg/test/util/retry"
@@ -302,6 +304,53 @@ func TestController_GetPodLocality(t *testing.T) {
 	}
 }
 
+func TestController_GetPodDataResidency(t *testing.T) {
+	controller, fx := NewFakeControllerWithOptions(t, FakeControllerOptions{})
+	addNodes(t, controller,
+		generateNode("node1", map[string]string{constants.DataResidencyLabel: "eu"}),
+		generateNode("node2", map[string]string{}))
+	fromNode := generatePod("128.0.1.1", "pod1", "nsA", "", "node1", map[string]string{"app": "prod-app"}, nil)
+	override := generatePod("128.0.1.2", "pod2", "nsA", "", "node1",
+		map[string]string{"app": "prod-app", constants.DataResidencyLabel: "ch"}, nil)
+	unlabeled := generatePod("128.0.1.3", "pod3", "nsA", "", "node2", map[string]string{"app": "prod-app"}, nil)
+	addPods(t, controller, fx, fromNode, override, unlabeled)
+
+	assert.Equal(t, controller.getPodDataResidency(fromNode), "eu")
+	assert.Equal(t, controller.getPodDataResidency(override), "ch")
+	assert.Equal(t, controller.getPodDataResidency(unlabeled), "")
+}
+
+func TestController_RecordProxyLocality(t *testing.T) {
+	controller, fx := NewFakeControllerWithOptions(t, FakeControllerOptions{})
+	addNodes(t, controller, generateNode("node1", map[string]string{NodeRegionLabel: "region1", NodeZoneLabel: "zone1"}))
+	onNode := generatePod("128.0.1.1", "pod1", "nsA", "sa", "node1", nil, nil)
+	noNode := generatePod("128.0.1.2", "pod2", "nsA", "sa", "", nil, nil)
+	addPods(t, controller, fx, onNode, noNode)
+	proxy := func(pod *corev1.Pod, sa string) *model.Proxy {
+		return &model.Proxy{
+			ID:               pod.Name + "." + pod.Namespace,
+			IPAddresses:      []string{pod.Status.PodIP},
+			Metadata:         &model.NodeMetadata{Namespace: pod.Namespace},
+			VerifiedIdentity: &spiffe.Identity{Namespace: pod.Namespace, ServiceAccount: sa},
+		}
+	}
+
+	// The asserted locality only fills in the locality of the pod, unless it overrides it.
+	controller.RecordProxyLocality(proxy(onNode, "sa"), "edge/site1", false)
+	controller.RecordProxyLocality(proxy(noNode, "sa"), "edge/site1", false)
+	assert.Equal(t, controller.getPodLocality(onNode), "region1/zone1/")
+	assert.Equal(t, controller.getPodLocality(noNode), "edge/site1")
+	controller.RecordProxyLocality(proxy(onNode, "sa"), "edge/site1", true)
+	assert.Equal(t, controller.getPodLocality(onNode), "edge/site1")
+
+	// A proxy not holding the identity of the pod cannot assert its locality.
+	controller.RecordProxyLocality(proxy(onNode, "other"), "edge/site2", true)
+	assert.Equal(t, controller.getPodLocality(onNode), "edge/site1")
+
+	controller.RecordProxyLocality(proxy(onNode, "sa"), "", false)
+	assert.Equal(t, controller.getPodLocality(onNode), "region1/zone1/")
+}
+
 func TestProxyK8sHostnameLabel(t *testing.T) {
 	clusterID := cluster.ID("fakeCluster")
 	controller, fx := NewFakeControllerWithOptions(t, FakeControllerOptions{
@@ -22,6 +22,7 @@ import (
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/serviceregistry/kube"
 	labelutil "istio.io/istio/pilot/pkg/serviceregistry/util/label"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/labels"
 	kubeUtil "istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/network"
@@ -35,9 +36,14 @@ type EndpointBuilder struct {
 	metaNetwork    network.ID
 	serviceAccount string
 	locality       model.Locality
+	dataResidency  string
 	tlsMode        string
 	workloadName   string
+	workloadKind   string
 	namespace      string
+	annotations    map[string]string
+	// The name of the pod, identifying the endpoints of the pod across changes of its address.
+	podName string
 
 	// Values used to build dns name tables per pod.
 	// The hostname of the Pod, by default equals to pod name.
@@ -46,13 +52,30 @@ type EndpointBuilder struct {
 	subDomain string
 	// If in k8s, the node where the pod resides
 	nodeName string
+
+	// addressType is the traffic.istio.io/address-type annotation of the pod, if valid.
+	addressType string
+	// capacityRPS is the networking.istio.io/capacity-rps annotation of the pod, if valid.
+	capacityRPS uint32
+	// hostIP is the host IP reported in the status of the pod, used if the address of its node is unknown.
+	hostIP string
+	// hostNetwork is set if the pod uses the network of its node, hostPorts maps its container ports to their host ports.
+	hostNetwork bool
+	hostPorts   map[int32]int32
 }
 
 func NewEndpointBuilder(c controllerInterface, pod *v1.Pod) *EndpointBuilder {
-	var locality, sa, namespace, hostname, subdomain, ip, node string
+	var locality, residency, sa, namespace, hostname, subdomain, ip, node, addressType, hostIP string
+	var hostNetwork bool
+	var hostPorts map[int32]int32
+	var capacityRPS uint32
 	var podLabels labels.Instance
+	var annotations map[string]string
+	var podName string
 	if pod != nil {
+		podName = pod.Name
 		locality = c.getPodLocality(pod)
+		residency = c.getPodDataResidency(pod)
 		sa = kube.SecureNamingSAN(pod)
 		podLabels = pod.Labels
 		namespace = pod.Namespace
@@ -65,8 +88,14 @@ func NewEndpointBuilder(c controllerInterface, pod *v1.Pod) *EndpointBuilder {
 		}
 		ip = pod.Status.PodIP
 		node = pod.Spec.NodeName
+		annotations = model.EndpointMetadataAnnotations(pod.Annotations)
+		addressType = kube.ConvertAddressType(pod.Annotations[constants.AddressTypeAnnotation])
+		capacityRPS = kube.ConvertCapacityRPS(pod.Annotations[constants.CapacityRPSAnnotation])
+		hostIP = pod.Status.HostIP
+		hostNetwork = pod.Spec.HostNetwork
+		hostPorts = podHostPorts(pod)
 	}
-	dm, _ := kubeUtil.GetDeployMetaFromPod(pod)
+	dm, tm := kubeUtil.GetDeployMetaFromPod(pod)
 	out := &EndpointBuilder{
 		controller:     c,
 		serviceAccount: sa,
@@ -74,13 +103,22 @@ func NewEndpointBuilder(c controllerInterface, pod *v1.Pod) *EndpointBuilder {
 			Label:     locality,
 			ClusterID: c.Cluster(),
 		},
-		tlsMode:      kube.PodTLSMode(pod),
-		workloadName: dm.Name,
-		namespace:    namespace,
-		hostname:     hostname,
-		subDomain:    subdomain,
-		labels:       podLabels,
-		nodeName:     node,
+		dataResidency: residency,
+		tlsMode:       kube.PodTLSMode(pod),
+		workloadName:  dm.Name,
+		workloadKind:  tm.Kind,
+		namespace:     namespace,
+		hostname:      hostname,
+		subDomain:     subdomain,
+		labels:        podLabels,
+		nodeName:      node,
+		annotations:   annotations,
+		podName:       podName,
+		addressType:   addressType,
+		capacityRPS:   capacityRPS,
+		hostIP:        hostIP,
+		hostNetwork:   hostNetwork,
+		hostPorts:     hostPorts,
 	}
 	networkID := out.endpointNetwork(ip)
 	out.labels = labelutil.AugmentLabels(podLabels, c.Cluster(), locality, node, networkID)
@@ -97,8 +135,9 @@ func NewEndpointBuilderFromMetadata(c controllerInterface, proxy *model.Proxy) *
 			Label:     locality,
 			ClusterID: c.Cluster(),
 		},
-		tlsMode:  model.GetTLSModeFromEndpointLabels(proxy.Labels),
-		nodeName: proxy.GetNodeName(),
+		dataResidency: proxy.Labels[constants.DataResidencyLabel],
+		tlsMode:       model.GetTLSModeFromEndpointLabels(proxy.Labels),
+		nodeName:      proxy.GetNodeName(),
 	}
 	var networkID network.ID
 	if len(proxy.IPAddresses) > 0 {
@@ -136,12 +175,17 @@ func (b *EndpointBuilder) buildIstioEndpoint(
 		ServicePortName:       svcPortName,
 		Network:               networkID,
 		WorkloadName:          b.workloadName,
+		WorkloadKind:          b.workloadKind,
 		Namespace:             b.namespace,
 		HostName:              b.hostname,
 		SubDomain:             b.subDomain,
 		DiscoverabilityPolicy: discoverabilityPolicy,
 		HealthStatus:          healthStatus,
 		NodeName:              b.nodeName,
+		DataResidency:         b.dataResidency,
+		Annotations:           b.annotations,
+		InstanceName:          b.podName,
+		CapacityRPS:           b.capacityRPS,
 	}
 }
 
@@ -154,3 +198,53 @@ func (b *EndpointBuilder) endpointNetwork(endpointIP string) network.ID {
 
 	return b.controller.Network(endpointIP, b.labels)
 }
+
+// podHostPorts returns the host ports of the container ports of the pod.
+func podHostPorts(pod *v1.Pod) map[int32]int32 {
+	var out map[int32]int32
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.HostPort == 0 {
+				continue
+			}
+			if out == nil {
+				out = map[int32]int32{}
+			}
+			out[p.ContainerPort] = p.HostPort
+		}
+	}
+	return out
+}
+
+// endpointAddress returns the address and port through which the endpoint of the pod on the given address and port
+// is reached, depending on the address type of the pod or else of its service. With constants.AddressTypeHostIP,
+// this is the address of the node of the pod and the host port of the port; the pod address is kept if either is
+// unknown.
+func (b *EndpointBuilder) endpointAddress(svcAddressType, address string, port int32) (string, int32) {
+	addressType := b.addressType
+	if addressType == "" {
+		addressType = svcAddressType
+	}
+	if addressType != constants.AddressTypeHostIP {
+		return address, port
+	}
+	// Pods using the network of their node are reached on their own ports.
+	hostPort := port
+	if !b.hostNetwork {
+		hp, f := b.hostPorts[port]
+		if !f {
+			log.Debugf("port %d of pod %s/%s has no host port, using its pod address", port, b.namespace, b.podName)
+			return address, port
+		}
+		hostPort = hp
+	}
+	nodeAddress := b.controller.getNodeAddress(b.nodeName)
+	if nodeAddress == "" {
+		nodeAddress = b.hostIP
+	}
+	if nodeAddress == "" {
+		log.Debugf("address of node %q of pod %s/%s is unknown, using its pod address", b.nodeName, b.namespace, b.podName)
+		return address, port
+	}
+	return nodeAddress, hostPort
+}
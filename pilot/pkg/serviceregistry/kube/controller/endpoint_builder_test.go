@@ -21,10 +21,13 @@ import (
 	v1 "k8s.io/api/core/v1"
 
 	"istio.io/api/label"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	cluster2 "istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/network"
+	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/test/util/assert"
 )
 
@@ -134,6 +137,66 @@ func TestNewEndpointBuilderTopologyLabels(t *testing.T) {
 	}
 }
 
+func TestNewEndpointBuilderAnnotations(t *testing.T) {
+	test.SetForTest(t, &features.EndpointMetadataAnnotations, map[string]string{"my.org/billing-tier": "envoy.lb"})
+	pod := v1.Pod{}
+	pod.Name = "testpod"
+	pod.Namespace = "testns"
+	pod.Annotations = map[string]string{"my.org/billing-tier": "gold", "other": "ignored"}
+
+	ep := NewEndpointBuilder(testController{}, &pod).buildIstioEndpoint("1.1.1.1", 80, "http", model.AlwaysDiscoverable, model.Healthy)
+	assert.Equal(t, ep.Annotations, map[string]string{"my.org/billing-tier": "gold"})
+}
+
+func TestNewEndpointBuilderCapacity(t *testing.T) {
+	for annotation, want := range map[string]uint32{"500": 500, "": 0, "-1": 0, "fast": 0} {
+		pod := v1.Pod{}
+		pod.Name = "testpod"
+		pod.Namespace = "testns"
+		pod.Annotations = map[string]string{constants.CapacityRPSAnnotation: annotation}
+
+		ep := NewEndpointBuilder(testController{}, &pod).buildIstioEndpoint("1.1.1.1", 80, "http", model.AlwaysDiscoverable, model.Healthy)
+		assert.Equal(t, ep.CapacityRPS, want)
+	}
+}
+
+func TestEndpointBuilderAddressType(t *testing.T) {
+	pod := func(annotation string, hostNetwork bool) *v1.Pod {
+		p := &v1.Pod{}
+		p.Name, p.Namespace = "testpod", "testns"
+		p.Annotations = map[string]string{constants.AddressTypeAnnotation: annotation}
+		p.Spec.HostNetwork = hostNetwork
+		p.Spec.Containers = []v1.Container{{Ports: []v1.ContainerPort{{ContainerPort: 8080, HostPort: 30080}}}}
+		p.Status.HostIP = "192.168.0.2"
+		return p
+	}
+	node := testController{nodeAddress: "192.168.0.1"}
+	cases := []struct {
+		name           string
+		ctl            testController
+		pod            *v1.Pod
+		svcAddressType string
+		port           int32
+		wantAddress    string
+		wantPort       int32
+	}{
+		{"pod address by default", node, pod("", false), "", 8080, "10.0.0.1", 8080},
+		{"host address of the service", node, pod("", false), constants.AddressTypeHostIP, 8080, "192.168.0.1", 30080},
+		{"host address of the pod", node, pod(constants.AddressTypeHostIP, false), "", 8080, "192.168.0.1", 30080},
+		{"pod overrides service", node, pod(constants.AddressTypePodIP, false), constants.AddressTypeHostIP, 8080, "10.0.0.1", 8080},
+		{"no host port", node, pod(constants.AddressTypeHostIP, false), "", 9090, "10.0.0.1", 9090},
+		{"host network", node, pod(constants.AddressTypeHostIP, true), "", 9090, "192.168.0.1", 9090},
+		{"unknown node", testController{}, pod(constants.AddressTypeHostIP, false), "", 8080, "192.168.0.2", 30080},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			address, port := NewEndpointBuilder(c.ctl, c.pod).endpointAddress(c.svcAddressType, "10.0.0.1", c.port)
+			assert.Equal(t, address, c.wantAddress)
+			assert.Equal(t, port, c.wantPort)
+		})
+	}
+}
+
 func TestNewEndpointBuilderFromMetadataTopologyLabels(t *testing.T) {
 	cases := []struct {
 		name     string
@@ -244,15 +307,25 @@ func TestNewEndpointBuilderFromMetadataTopologyLabels(t *testing.T) {
 var _ controllerInterface = testController{}
 
 type testController struct {
-	locality string
-	cluster  cluster2.ID
-	network  network.ID
+	locality    string
+	residency   string
+	nodeAddress string
+	cluster     cluster2.ID
+	network     network.ID
 }
 
 func (c testController) getPodLocality(*v1.Pod) string {
 	return c.locality
 }
 
+func (c testController) getPodDataResidency(*v1.Pod) string {
+	return c.residency
+}
+
+func (c testController) getNodeAddress(string) string {
+	return c.nodeAddress
+}
+
 func (c testController) Network(ip string, instance labels.Instance) network.ID {
 	if n := instance[label.TopologyNetwork.Name]; n != "" {
 		return network.ID(n)
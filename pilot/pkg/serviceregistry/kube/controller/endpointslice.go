@@ -15,6 +15,8 @@
 package controller
 
 import (
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/hashicorp/go-multierror"
@@ -30,9 +32,11 @@ import (
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/schema/kind"
 	"istio.io/istio/pkg/kube/kclient"
+	"istio.io/istio/pkg/slices"
 	"istio.io/istio/pkg/util/sets"
 )
 
@@ -102,6 +106,34 @@ func (esc *endpointSliceController) GetProxyServiceTargets(proxy *model.Proxy) [
 	return out
 }
 
+// slicesForPod returns the EndpointSlices with an endpoint of the pod.
+func (esc *endpointSliceController) slicesForPod(pod *corev1.Pod) []types.NamespacedName {
+	var out []types.NamespacedName
+	for _, slice := range esc.slices.List(pod.Namespace, endpointSliceSelector) {
+		for _, e := range slice.Endpoints {
+			if e.TargetRef != nil && e.TargetRef.Kind == "Pod" && e.TargetRef.Name == pod.Name {
+				out = append(out, config.NamespacedName(slice))
+				break
+			}
+		}
+	}
+	return out
+}
+
+// slicesOnNode returns the EndpointSlices with an endpoint on the given node.
+func (esc *endpointSliceController) slicesOnNode(nodeName string) []types.NamespacedName {
+	var out []types.NamespacedName
+	for _, slice := range esc.slices.List(metav1.NamespaceAll, endpointSliceSelector) {
+		for _, e := range slice.Endpoints {
+			if e.NodeName != nil && *e.NodeName == nodeName {
+				out = append(out, config.NamespacedName(slice))
+				break
+			}
+		}
+	}
+	return out
+}
+
 func serviceNameForEndpointSlice(labels map[string]string) string {
 	return labels[v1beta1.LabelServiceName]
 }
@@ -111,7 +143,7 @@ func (esc *endpointSliceController) sliceServiceInstances(ep *v1.EndpointSlice,
 	esc.endpointCache.mu.RLock()
 	defer esc.endpointCache.mu.RUnlock()
 	for _, svc := range esc.c.servicesForNamespacedName(getServiceNamespacedName(ep)) {
-		for _, instance := range esc.endpointCache.get(svc.Hostname) {
+		for _, instance := range expandEndpointPorts(esc.endpointCache.get(svc.Hostname)) {
 			port, f := svc.Ports.Get(instance.ServicePortName)
 			if !f {
 				log.Warnf("unexpected state, svc %v missing port %v", svc.Hostname, instance.ServicePortName)
@@ -134,6 +166,18 @@ func (esc *endpointSliceController) sliceServiceInstances(ep *v1.EndpointSlice,
 	return out
 }
 
+// expandEndpointPorts returns one endpoint per port of the endpoints carrying several ports.
+func expandEndpointPorts(endpoints []*model.IstioEndpoint) []*model.IstioEndpoint {
+	if !features.MergeEndpointPorts {
+		return endpoints
+	}
+	out := make([]*model.IstioEndpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		out = append(out, ep.ExpandPorts()...)
+	}
+	return out
+}
+
 func (esc *endpointSliceController) deleteEndpointSlice(slice *v1.EndpointSlice) {
 	key := config.NamespacedName(slice)
 	for _, e := range slice.Endpoints {
@@ -159,13 +203,22 @@ func (esc *endpointSliceController) updateEndpointSlice(slice *v1.EndpointSlice)
 }
 
 func endpointHealthStatus(svc *model.Service, e v1.Endpoint) model.HealthStatus {
-	if e.Conditions.Ready == nil || *e.Conditions.Ready {
+	ready := e.Conditions.Ready == nil || *e.Conditions.Ready
+	if svc != nil && svc.Attributes.PublishNotReadyAddresses {
+		// Kubernetes reports the endpoints of these services as ready, even when terminating; serving holds their
+		// actual readiness, and the terminating endpoints are handled as for the other services.
+		ready = ready && (e.Conditions.Serving == nil || *e.Conditions.Serving) &&
+			(e.Conditions.Terminating == nil || !*e.Conditions.Terminating)
+	}
+	if ready {
 		return model.Healthy
 	}
 
-	if features.PersistentSessionLabel != "" &&
-		svc != nil &&
-		svc.Attributes.Labels[features.PersistentSessionLabel] != "" &&
+	// Terminating endpoints still serving are kept as draining for services with persistent sessions or including
+	// terminating endpoints; the EDS builder decides how they are sent.
+	persistentSession := svc != nil && svc.PersistentSession()
+	includeTerminating := svc != nil && svc.IncludeTerminating() != constants.IncludeTerminatingNever
+	if (persistentSession || includeTerminating) &&
 		(e.Conditions.Serving == nil || *e.Conditions.Serving) &&
 		(e.Conditions.Terminating == nil || *e.Conditions.Terminating) {
 		return model.Draining
@@ -182,12 +235,23 @@ func (esc *endpointSliceController) updateEndpointCacheForSlice(hostName host.Na
 	}
 	svc := esc.c.GetService(hostName)
 	discoverabilityPolicy := esc.c.exports.EndpointDiscoverabilityPolicy(svc)
+	if svc == nil && isClusterSetLocalHost(hostName) {
+		// The Service is not imported in this cluster: its endpoints are aggregated with those of the other
+		// clusters under the clusterset.local host if it is exported.
+		discoverabilityPolicy = esc.c.exports.ClusterSetEndpointDiscoverabilityPolicy(getServiceNamespacedName(slice))
+	}
+	var svcAddressType string
+	if svc != nil {
+		svcAddressType = svc.Attributes.AddressType
+	}
 
 	for _, e := range slice.Endpoints {
 		// Draining tracking is only enabled if persistent sessions is enabled.
 		// If we start using them for other features, this can be adjusted.
 		healthStatus := endpointHealthStatus(svc, e)
-		if !features.SendUnhealthyEndpoints.Load() {
+		// The not ready endpoints of services publishing them are kept, the EDS builder decides how they are sent.
+		publishNotReady := svc != nil && svc.Attributes.PublishNotReadyAddresses
+		if !features.SendUnhealthyEndpoints.Load() && !publishNotReady {
 			if healthStatus == model.UnHealthy {
 				// Ignore not ready endpoints. Draining endpoints are tracked, but not returned
 				// except for persistent-session clusters.
@@ -199,7 +263,19 @@ func (esc *endpointSliceController) updateEndpointCacheForSlice(hostName host.Na
 			if pod == nil && expectedPod {
 				continue
 			}
+			addressHealth := healthStatus
+			// Completed pods, such as the pods of Jobs, may linger in EndpointSlices until they are garbage collected.
+			completed := pod != nil && isPodPhaseTerminal(pod.Status.Phase)
+			if completed {
+				if features.CompletedPodEndpoints != constants.CompletedPodEndpointsUnhealthy ||
+					(!features.SendUnhealthyEndpoints.Load() && !publishNotReady) {
+					continue
+				}
+				addressHealth = model.UnHealthy
+			}
 			builder := NewEndpointBuilder(esc.c, pod)
+			// With PILOT_MERGE_ENDPOINT_PORTS, the endpoint of the first port carries the other ports of the address.
+			var merged *model.IstioEndpoint
 			// EDS and ServiceEntry use name for service port - ADS will need to map to numbers.
 			for _, port := range slice.Ports {
 				var portNum int32
@@ -211,14 +287,48 @@ func (esc *endpointSliceController) updateEndpointCacheForSlice(hostName host.Na
 					portName = *port.Name
 				}
 
-				istioEndpoint := builder.buildIstioEndpoint(a, portNum, portName, discoverabilityPolicy, healthStatus)
+				if merged != nil {
+					if merged.AdditionalPorts == nil {
+						merged.AdditionalPorts = map[string]uint32{}
+					}
+					_, hostPort := builder.endpointAddress(svcAddressType, a, portNum)
+					merged.AdditionalPorts[portName] = uint32(hostPort)
+					continue
+				}
+				istioEndpoint := builder.buildIstioEndpoint(a, portNum, portName, discoverabilityPolicy, addressHealth)
+				istioEndpoint.Completed = completed
+				istioEndpoint.ZoneHints = zoneHints(e.Hints)
+				// The endpoint keeps the network of the pod address, even if it is reached through its node.
+				address, hostPort := builder.endpointAddress(svcAddressType, a, portNum)
+				istioEndpoint.Address, istioEndpoint.EndpointPort = address, uint32(hostPort)
+				if address != a {
+					istioEndpoint.WorkloadAddress = a
+				}
+				if features.MergeEndpointPorts {
+					merged = istioEndpoint
+				}
 				endpoints = append(endpoints, istioEndpoint)
 			}
+			if merged != nil {
+				merged.PrecomputePorts()
+			}
 		}
 	}
 	esc.endpointCache.Update(hostName, slice.Name, endpoints)
 }
 
+// zoneHints returns the zones of the hints of an EndpointSlice endpoint, or nil if it has none.
+func zoneHints(hints *v1.EndpointHints) []string {
+	if hints == nil || len(hints.ForZones) == 0 {
+		return nil
+	}
+	zones := make([]string, 0, len(hints.ForZones))
+	for _, z := range hints.ForZones {
+		zones = append(zones, z.Name)
+	}
+	return zones
+}
+
 func (esc *endpointSliceController) buildIstioEndpointsWithService(name, namespace string, hostName host.Name, updateCache bool) []*model.IstioEndpoint {
 	esLabelSelector := endpointSliceSelectorForService(name)
 	slices := esc.slices.List(namespace, esLabelSelector)
@@ -244,11 +354,29 @@ func getServiceNamespacedName(slice *v1.EndpointSlice) types.NamespacedName {
 	}
 }
 
-// endpointKey unique identifies an endpoint by IP and port name
+// endpointKey unique identifies an endpoint by IP, port name and port number. The port number tells apart the
+// endpoints of pods sharing the address of their node. The additional ports tell apart merged endpoints of slices
+// sharing their first port but not the others.
 // This is used for deduping endpoints across slices.
 type endpointKey struct {
-	ip   string
-	port string
+	ip              string
+	port            string
+	portNumber      uint32
+	additionalPorts string
+}
+
+// newEndpointKey returns the key of the endpoint.
+func newEndpointKey(ep *model.IstioEndpoint) endpointKey {
+	key := endpointKey{ip: ep.Address, port: ep.ServicePortName, portNumber: ep.EndpointPort}
+	if len(ep.AdditionalPorts) == 0 {
+		return key
+	}
+	ports := make([]string, 0, len(ep.AdditionalPorts))
+	for name, port := range ep.AdditionalPorts {
+		ports = append(ports, name+"="+strconv.FormatUint(uint64(port), 10))
+	}
+	key.additionalPorts = strings.Join(slices.Sort(ports), ",")
+	return key
 }
 
 type endpointSliceCache struct {
@@ -309,7 +437,7 @@ func (e *endpointSliceCache) get(hostname host.Name) []*model.IstioEndpoint {
 	found := sets.New[endpointKey]()
 	for _, eps := range e.endpointsByServiceAndSlice[hostname] {
 		for _, ep := range eps {
-			key := endpointKey{ep.Address, ep.ServicePortName}
+			key := newEndpointKey(ep)
 			if found.InsertContains(key) {
 				// This a duplicate. Update() already handles conflict resolution, so we don't
 				// need to pick the "right" one here.
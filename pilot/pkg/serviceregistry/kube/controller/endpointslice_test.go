@@ -20,12 +20,18 @@ import (
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	mcs "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
 
 	"istio.io/api/label"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/serviceregistry/kube"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/kube/kclient/clienttest"
+	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/test/util/assert"
 )
 
@@ -73,6 +79,50 @@ func TestEndpointSliceFromMCSShouldBeIgnored(t *testing.T) {
 	assert.Equal(t, len(endpoints), 0)
 }
 
+func TestEndpointSliceMergePorts(t *testing.T) {
+	test.SetForTest(t, &features.MergeEndpointPorts, true)
+	controller, fx := NewFakeControllerWithOptions(t, FakeControllerOptions{})
+	createServiceWait(controller, "svc1", "nsa", nil, nil, []int32{8080}, map[string]string{"app": "prod-app"}, t)
+	createEndpoints(t, controller, "svc1", "nsa", []string{"http", "grpc"}, []string{"10.0.0.1", "10.0.0.2"}, nil, nil)
+	fx.WaitOrFail(t, "eds")
+
+	svc := controller.GetService(kube.ServiceHostname("svc1", "nsa", controller.opts.DomainSuffix))
+	got := map[string]map[string]uint32{}
+	for _, ep := range GetEndpoints(svc, controller.Endpoints) {
+		ports := map[string]uint32{ep.ServicePortName: ep.EndpointPort}
+		for name, port := range ep.AdditionalPorts {
+			ports[name] = port
+		}
+		got[ep.Address] = ports
+		// The endpoints of the additional ports are built once, when the slice is processed.
+		assert.Equal(t, ep.ForPort("grpc") == ep.ForPort("grpc"), true)
+	}
+	// A single endpoint per address carries all the ports.
+	assert.Equal(t, got, map[string]map[string]uint32{
+		"10.0.0.1": {"http": 1001, "grpc": 1001},
+		"10.0.0.2": {"http": 1001, "grpc": 1001},
+	})
+}
+
+func TestEndpointSliceCacheAdditionalPorts(t *testing.T) {
+	cache := newEndpointSliceCache()
+	hostname := host.Name("foo")
+	ep1 := &model.IstioEndpoint{Address: "1.2.3.4", ServicePortName: "http", AdditionalPorts: map[string]uint32{"grpc": 9090}}
+	ep2 := &model.IstioEndpoint{Address: "1.2.3.4", ServicePortName: "http", AdditionalPorts: map[string]uint32{"tcp": 9000}}
+	cache.Update(hostname, "slice1", []*model.IstioEndpoint{ep1})
+	cache.Update(hostname, "slice2", []*model.IstioEndpoint{ep2})
+	// Merged endpoints sharing their first port but not the others are not duplicates.
+	if !testEndpointsEqual(cache.Get(hostname), []*model.IstioEndpoint{ep1, ep2}) {
+		t.Fatalf("unexpected endpoints")
+	}
+}
+
+func TestZoneHints(t *testing.T) {
+	assert.Equal(t, zoneHints(nil), nil)
+	assert.Equal(t, zoneHints(&v1.EndpointHints{}), nil)
+	assert.Equal(t, zoneHints(&v1.EndpointHints{ForZones: []v1.ForZone{{Name: "zone-a"}, {Name: "zone-b"}}}), []string{"zone-a", "zone-b"})
+}
+
 func TestEndpointSliceCache(t *testing.T) {
 	cache := newEndpointSliceCache()
 	hostname := host.Name("foo")
@@ -147,10 +197,161 @@ func testEndpointsEqual(a, b []*model.IstioEndpoint) bool {
 	m1 := make(map[endpointKey]int)
 	m2 := make(map[endpointKey]int)
 	for _, i := range a {
-		m1[endpointKey{i.Address, i.ServicePortName}]++
+		m1[newEndpointKey(i)]++
 	}
 	for _, i := range b {
-		m2[endpointKey{i.Address, i.ServicePortName}]++
+		m2[newEndpointKey(i)]++
 	}
 	return reflect.DeepEqual(m1, m2)
 }
+
+func TestEndpointHealthStatusPublishNotReady(t *testing.T) {
+	yes, no := true, false
+	published := &model.Service{Attributes: model.ServiceAttributes{K8sAttributes: model.K8sAttributes{PublishNotReadyAddresses: true}}}
+	publishedPersistent := &model.Service{Attributes: model.ServiceAttributes{K8sAttributes: model.K8sAttributes{
+		PublishNotReadyAddresses: true,
+		IncludeTerminating:       constants.IncludeTerminatingWhenNoReady,
+	}}}
+	tests := []struct {
+		name       string
+		svc        *model.Service
+		conditions v1.EndpointConditions
+		want       model.HealthStatus
+	}{
+		{name: "ready", svc: published, conditions: v1.EndpointConditions{Ready: &yes, Serving: &yes}, want: model.Healthy},
+		// Kubernetes reports the endpoints of services publishing not ready addresses as ready.
+		{name: "published not serving", svc: published, conditions: v1.EndpointConditions{Ready: &yes, Serving: &no}, want: model.UnHealthy},
+		{name: "published not ready", svc: published, conditions: v1.EndpointConditions{Ready: &no}, want: model.UnHealthy},
+		{name: "not published", svc: &model.Service{}, conditions: v1.EndpointConditions{Ready: &yes, Serving: &no}, want: model.Healthy},
+		// The terminating endpoints are drained as for the other services.
+		{
+			name: "published terminating", svc: published,
+			conditions: v1.EndpointConditions{Ready: &yes, Serving: &yes, Terminating: &yes}, want: model.UnHealthy,
+		},
+		{
+			name: "published terminating included", svc: publishedPersistent,
+			conditions: v1.EndpointConditions{Ready: &yes, Serving: &yes, Terminating: &yes}, want: model.Draining,
+		},
+		{
+			name: "published terminating not serving", svc: publishedPersistent,
+			conditions: v1.EndpointConditions{Ready: &yes, Serving: &no, Terminating: &yes}, want: model.UnHealthy,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, endpointHealthStatus(tt.svc, v1.Endpoint{Conditions: tt.conditions}), tt.want)
+		})
+	}
+}
+
+func TestEndpointHealthStatusIncludeTerminating(t *testing.T) {
+	yes, no := true, false
+	including := &model.Service{Attributes: model.ServiceAttributes{K8sAttributes: model.K8sAttributes{
+		IncludeTerminating: constants.IncludeTerminatingWhenNoReady,
+	}}}
+	terminating := v1.EndpointConditions{Ready: &no, Serving: &yes, Terminating: &yes}
+	assert.Equal(t, endpointHealthStatus(including, v1.Endpoint{Conditions: terminating}), model.Draining)
+	assert.Equal(t, endpointHealthStatus(including, v1.Endpoint{Conditions: v1.EndpointConditions{Ready: &no, Serving: &no, Terminating: &yes}}),
+		model.UnHealthy)
+	assert.Equal(t, endpointHealthStatus(&model.Service{}, v1.Endpoint{Conditions: terminating}), model.UnHealthy)
+}
+
+func TestEndpointSliceCompletedPods(t *testing.T) {
+	type status struct {
+		Health    model.HealthStatus
+		Completed bool
+	}
+	cases := []struct {
+		name   string
+		policy string
+		want   map[string]status
+	}{
+		{
+			name:   "exclude",
+			policy: constants.CompletedPodEndpointsExclude,
+			want:   map[string]status{"128.0.0.1": {Health: model.Healthy}},
+		},
+		{
+			name:   "unhealthy",
+			policy: constants.CompletedPodEndpointsUnhealthy,
+			want: map[string]status{
+				"128.0.0.1": {Health: model.Healthy},
+				"128.0.0.2": {Health: model.UnHealthy, Completed: true},
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			test.SetForTest(t, &features.CompletedPodEndpoints, tt.policy)
+			test.SetAtomicBoolForTest(t, features.SendUnhealthyEndpoints, true)
+			controller, fx := NewFakeControllerWithOptions(t, FakeControllerOptions{})
+			running := generatePod("128.0.0.1", "running", "nsa", "sa", "", map[string]string{"app": "job"}, nil)
+			job := generatePod("128.0.0.2", "job", "nsa", "sa", "", map[string]string{"app": "job"}, nil)
+			addPods(t, controller, fx, running, job)
+			createServiceWait(controller, "svc1", "nsa", nil, nil, []int32{8080}, map[string]string{"app": "job"}, t)
+			createEndpoints(t, controller, "svc1", "nsa", []string{"tcp-port"}, []string{"128.0.0.1", "128.0.0.2"},
+				[]*corev1.ObjectReference{
+					{Kind: "Pod", Namespace: "nsa", Name: "running"},
+					{Kind: "Pod", Namespace: "nsa", Name: "job"},
+				}, nil)
+			svc := controller.GetService(kube.ServiceHostname("svc1", "nsa", controller.opts.DomainSuffix))
+			statuses := func() map[string]status {
+				out := map[string]status{}
+				for _, ep := range GetEndpoints(svc, controller.Endpoints) {
+					out[ep.Address] = status{Health: ep.HealthStatus, Completed: ep.Completed}
+				}
+				return out
+			}
+			assert.EventuallyEqual(t, statuses, map[string]status{
+				"128.0.0.1": {Health: model.Healthy},
+				"128.0.0.2": {Health: model.Healthy},
+			})
+
+			// The job completes, while the EndpointSlice still lists its pod as ready.
+			pc := clienttest.Wrap(t, controller.podsClient)
+			completed := pc.Get("job", "nsa").DeepCopy()
+			completed.Status.Phase = corev1.PodSucceeded
+			pc.UpdateStatus(completed)
+			assert.EventuallyEqual(t, statuses, tt.want)
+		})
+	}
+}
+
+func TestEndpointSliceNodeAddressChange(t *testing.T) {
+	controller, fx := NewFakeControllerWithOptions(t, FakeControllerOptions{})
+	node := generateNode("node1", nil)
+	node.Status.Addresses = []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "192.168.0.1"}}
+	addNodes(t, controller, node)
+	annotations := map[string]string{constants.AddressTypeAnnotation: constants.AddressTypeHostIP}
+	pod1 := generatePod("128.0.0.1", "pod1", "nsa", "sa", "node1", map[string]string{"app": "prod-app"}, annotations)
+	pod1.Spec.HostNetwork = true
+	addPods(t, controller, fx, pod1)
+	createServiceWait(controller, "svc1", "nsa", nil, nil, []int32{8080}, map[string]string{"app": "prod-app"}, t)
+	portName, portNum := "tcp-port", int32(8080)
+	clienttest.NewWriter[*v1.EndpointSlice](t, controller.client).Create(&v1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{Name: "svc1", Namespace: "nsa", Labels: map[string]string{v1.LabelServiceName: "svc1"}},
+		Endpoints: []v1.Endpoint{{
+			Addresses: []string{"128.0.0.1"},
+			NodeName:  &node.Name,
+			TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "nsa", Name: "pod1"},
+		}},
+		Ports: []v1.EndpointPort{{Name: &portName, Port: &portNum}},
+	})
+
+	svc := controller.GetService(kube.ServiceHostname("svc1", "nsa", controller.opts.DomainSuffix))
+	addresses := func() map[string]string {
+		out := map[string]string{}
+		for _, ep := range GetEndpoints(svc, controller.Endpoints) {
+			out[ep.Address] = ep.WorkloadAddress
+		}
+		return out
+	}
+	// The endpoint is reached through the address of its node, and keeps the address of its pod.
+	assert.EventuallyEqual(t, addresses, map[string]string{"192.168.0.1": "128.0.0.1"})
+
+	// The endpoint follows the changes of the address of its node.
+	node = node.DeepCopy()
+	node.Status.Addresses = []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "192.168.0.2"}}
+	clienttest.Wrap(t, controller.nodes).UpdateStatus(node)
+	assert.EventuallyEqual(t, addresses, map[string]string{"192.168.0.2": "128.0.0.1"})
+}
@@ -0,0 +1,178 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/kube"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/slices"
+)
+
+const externalNameLookupTimeout = 5 * time.Second
+
+// externalNameLookup returns the canonical name the name resolves to, following its CNAME chain, and its addresses.
+type externalNameLookup func(ctx context.Context, name string) (string, []string, error)
+
+func lookupExternalName(ctx context.Context, name string) (string, []string, error) {
+	cname, err := net.DefaultResolver.LookupCNAME(ctx, name)
+	if err != nil {
+		return "", nil, err
+	}
+	addrs, err := net.DefaultResolver.LookupHost(ctx, cname)
+	if err != nil {
+		return "", nil, err
+	}
+	return cname, addrs, nil
+}
+
+// externalNames tracks the ExternalName Services resolved by istiod, and the last result of their resolution.
+type externalNames struct {
+	mu       sync.Mutex
+	lookup   externalNameLookup
+	services map[host.Name]*resolvedExternalName
+}
+
+type resolvedExternalName struct {
+	svc       *model.Service
+	cname     string
+	addresses []string
+}
+
+func newExternalNames(lookup externalNameLookup) *externalNames {
+	return &externalNames{lookup: lookup, services: map[host.Name]*resolvedExternalName{}}
+}
+
+// track starts tracking the service, and returns true if it was not tracked yet.
+func (e *externalNames) track(svc *model.Service) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if r, f := e.services[svc.Hostname]; f {
+		r.svc = svc
+		return false
+	}
+	e.services[svc.Hostname] = &resolvedExternalName{svc: svc}
+	return true
+}
+
+func (e *externalNames) untrack(hostname host.Name) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.services, hostname)
+}
+
+func (e *externalNames) hostnames() []host.Name {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := make([]host.Name, 0, len(e.services))
+	for h := range e.services {
+		out = append(out, h)
+	}
+	return out
+}
+
+// endpoints returns the endpoints of the service from its last resolution.
+func (e *externalNames) endpoints(svc *model.Service) []*model.IstioEndpoint {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	r, f := e.services[svc.Hostname]
+	if !f {
+		return nil
+	}
+	return kube.ResolvedExternalNameEndpoints(svc, r.addresses)
+}
+
+// resolve resolves the external name of the service. It returns the service and its new endpoints if the
+// resolution changed, or a nil service otherwise.
+func (e *externalNames) resolve(hostname host.Name) (*model.Service, []*model.IstioEndpoint) {
+	e.mu.Lock()
+	r, f := e.services[hostname]
+	if !f {
+		e.mu.Unlock()
+		return nil, nil
+	}
+	svc := r.svc
+	e.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), externalNameLookupTimeout)
+	defer cancel()
+	cname, addrs, err := e.lookup(ctx, svc.Attributes.ExternalName)
+	if err != nil {
+		// Keep the last known addresses: a transient DNS failure should not remove the endpoints.
+		log.Warnf("failed to resolve external name %s of service %s: %v", svc.Attributes.ExternalName, hostname, err)
+		return nil, nil
+	}
+	sort.Strings(addrs)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	r, f = e.services[hostname]
+	if !f || r.svc != svc {
+		// Untracked or updated in the meantime; the service update resolves it again.
+		return nil, nil
+	}
+	if r.cname == cname && slices.Equal(r.addresses, addrs) {
+		return nil, nil
+	}
+	if r.cname != "" && r.cname != cname {
+		log.Infof("external name %s of service %s now resolves to %s (was %s)", svc.Attributes.ExternalName, hostname, cname, r.cname)
+	}
+	r.cname = cname
+	r.addresses = addrs
+	return svc, kube.ResolvedExternalNameEndpoints(svc, addrs)
+}
+
+// resolveExternalName resolves the external name of the service, and updates its endpoints if the resolution changed.
+func (c *Controller) resolveExternalName(hostname host.Name) {
+	svc, endpoints := c.externalNames.resolve(hostname)
+	if svc == nil {
+		return
+	}
+	shard := model.ShardKeyFromRegistry(c)
+	c.opts.XDSUpdater.EDSUpdate(shard, string(hostname), svc.Attributes.Namespace, endpoints)
+}
+
+// trackExternalName starts or stops resolving the external name of the service, depending on its configuration.
+func (c *Controller) trackExternalName(svc *model.Service) {
+	if !svc.Attributes.ResolveExternalName {
+		c.externalNames.untrack(svc.Hostname)
+		return
+	}
+	c.externalNames.track(svc)
+	// Resolve asynchronously, so that DNS lookups do not block the processing of Services.
+	go c.resolveExternalName(svc.Hostname)
+}
+
+// resolveExternalNames periodically resolves the tracked external names, until stop is closed.
+func (c *Controller) resolveExternalNames(stop <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, h := range c.externalNames.hostnames() {
+				c.resolveExternalName(h)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
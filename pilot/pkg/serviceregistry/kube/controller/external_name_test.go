@@ -0,0 +1,94 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/kube"
+	"istio.io/istio/pilot/pkg/serviceregistry/util/xdsfake"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/kube/kclient/clienttest"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+type fakeExternalNameLookup struct {
+	mu    sync.Mutex
+	cname string
+	addrs []string
+}
+
+func (f *fakeExternalNameLookup) set(cname string, addrs ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cname, f.addrs = cname, addrs
+}
+
+func (f *fakeExternalNameLookup) lookup(context.Context, string) (string, []string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.cname, slices.Clone(f.addrs), nil
+}
+
+func TestResolvedExternalNameService(t *testing.T) {
+	controller, fx := NewFakeControllerWithOptions(t, FakeControllerOptions{})
+	dns := &fakeExternalNameLookup{}
+	dns.set("lb-1.example.com.", "10.0.0.2", "10.0.0.1")
+	controller.externalNames = newExternalNames(dns.lookup)
+
+	clienttest.Wrap(t, controller.services).Create(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "ext",
+			Namespace:   "nsA",
+			Annotations: map[string]string{constants.ExternalNameResolutionAnnotation: constants.ExternalNameResolutionEDS},
+		},
+		Spec: corev1.ServiceSpec{
+			Ports:        []corev1.ServicePort{{Name: "tcp", Port: 8080}},
+			Type:         corev1.ServiceTypeExternalName,
+			ExternalName: "ext.example.com",
+		},
+	})
+	hostname := kube.ServiceHostname("ext", "nsA", defaultFakeDomainSuffix)
+	addresses := func(ev *xdsfake.Event) []string {
+		return slices.Map(ev.Endpoints, func(e *model.IstioEndpoint) string { return e.Address })
+	}
+
+	ev := fx.WaitOrFail(t, "eds")
+	assert.Equal(t, ev.ID, string(hostname))
+	assert.Equal(t, addresses(ev), []string{"10.0.0.1", "10.0.0.2"})
+	assert.Equal(t, controller.GetService(hostname).Resolution, model.ClientSideLB)
+
+	// Unchanged resolutions do not update the endpoints.
+	controller.resolveExternalName(hostname)
+	fx.AssertEmpty(t, 0)
+
+	// The CNAME target changed.
+	dns.set("lb-2.example.com.", "10.0.1.1")
+	controller.resolveExternalName(hostname)
+	ev = fx.WaitOrFail(t, "eds")
+	assert.Equal(t, addresses(ev), []string{"10.0.1.1"})
+
+	clienttest.Wrap(t, controller.services).Delete("ext", "nsA")
+	fx.WaitOrFail(t, "service")
+	controller.resolveExternalName(hostname)
+	fx.AssertEmpty(t, 0)
+}
@@ -52,6 +52,7 @@ type FakeControllerOptions struct {
 	SkipRun                   bool
 	ConfigController          model.ConfigStoreController
 	ConfigCluster             bool
+	RolloutWeights            *model.RolloutWeights
 }
 
 type FakeController struct {
@@ -92,6 +93,7 @@ func NewFakeControllerWithOptions(t test.Failer, opts FakeControllerOptions) (*F
 		MeshServiceController:     meshServiceController,
 		ConfigCluster:             opts.ConfigCluster,
 		ConfigController:          opts.ConfigController,
+		RolloutWeights:            opts.RolloutWeights,
 	}
 	c := NewController(opts.Client, options)
 	meshServiceController.AddRegistry(c)
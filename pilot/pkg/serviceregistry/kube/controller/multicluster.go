@@ -167,7 +167,14 @@ func (m *Multicluster) ClusterAdded(cluster *multicluster.Cluster, clusterStopCh
 // when a remote cluster is updated.
 func (m *Multicluster) ClusterUpdated(cluster *multicluster.Cluster, stop <-chan struct{}) {
 	m.m.Lock()
-	m.deleteCluster(cluster.ID)
+	var prev []*model.Service
+	if kc, ok := m.remoteKubeControllers[cluster.ID]; ok && features.ResumeRemoteClusterShards {
+		// Keep the endpoint shard, so proxies are not left without the endpoints of the cluster while it resyncs.
+		prev = kc.Services()
+		m.detachCluster(cluster.ID)
+	} else {
+		m.deleteCluster(cluster.ID)
+	}
 	kubeController, kubeRegistry, options, configCluster := m.addCluster(cluster)
 	if kubeController == nil {
 		// m.closing was true, nothing to do.
@@ -177,6 +184,13 @@ func (m *Multicluster) ClusterUpdated(cluster *multicluster.Cluster, stop <-chan
 	m.m.Unlock()
 	// clusterStopCh is a channel that will be closed when this cluster removed.
 	m.initializeCluster(cluster, kubeController, kubeRegistry, *options, configCluster, stop)
+	if prev != nil {
+		go func() {
+			if kubelib.WaitForCacheSync("resumed cluster "+string(cluster.ID), stop, kubeRegistry.HasSynced) {
+				kubeRegistry.resumeShard(prev)
+			}
+		}()
+	}
 }
 
 // ClusterDeleted is passed to the secret controller as a callback to be called
@@ -389,20 +403,36 @@ func (m *Multicluster) checkShouldLead(client kubelib.Client, systemNamespace st
 // deleteCluster deletes cluster resources and does not trigger push.
 // This call is not thread safe.
 func (m *Multicluster) deleteCluster(clusterID cluster.ID) {
+	kc := m.removeRegistries(clusterID)
+	if kc == nil {
+		return
+	}
+	if err := kc.Cleanup(); err != nil {
+		log.Warnf("failed cleaning up services in %s: %v", clusterID, err)
+	}
+}
+
+// detachCluster deletes cluster resources like deleteCluster, except for the endpoint shard of the cluster.
+// This call is not thread safe.
+func (m *Multicluster) detachCluster(clusterID cluster.ID) {
+	if kc := m.removeRegistries(clusterID); kc != nil {
+		kc.Detach()
+	}
+}
+
+func (m *Multicluster) removeRegistries(clusterID cluster.ID) *kubeController {
 	m.opts.MeshServiceController.UnRegisterHandlersForCluster(clusterID)
 	m.opts.MeshServiceController.DeleteRegistry(clusterID, provider.Kubernetes)
 	kc, ok := m.remoteKubeControllers[clusterID]
 	if !ok {
 		log.Infof("cluster %s does not exist, maybe caused by invalid kubeconfig", clusterID)
-		return
+		return nil
 	}
 	if kc.workloadEntryController != nil {
 		m.opts.MeshServiceController.DeleteRegistry(clusterID, provider.External)
 	}
-	if err := kc.Cleanup(); err != nil {
-		log.Warnf("failed cleaning up services in %s: %v", clusterID, err)
-	}
 	delete(m.remoteKubeControllers, clusterID)
+	return kc
 }
 
 func createWleConfigStore(client kubelib.Client, revision string, opts Options) model.ConfigStoreController {
@@ -25,9 +25,12 @@ import (
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/keycertbundle"
+	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/server"
 	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
+	"istio.io/istio/pilot/pkg/serviceregistry/util/xdsfake"
 	"istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/kube/multicluster"
@@ -193,3 +196,20 @@ func Test_KubeSecretController_ExternalIstiod_MultipleClusters(t *testing.T) {
 	// Test - Verify that the remote controller has been removed.
 	verifyControllers(t, mc, 1, "delete remote controller 2")
 }
+
+func TestResumeShard(t *testing.T) {
+	controller, fx := NewFakeControllerWithOptions(t, FakeControllerOptions{})
+	createServiceWait(controller, "svc1", "nsA", nil, nil, []int32{8080}, map[string]string{"app": "a"}, t)
+	fx.Clear()
+
+	kept := controller.Services()[0]
+	gone := &model.Service{
+		Hostname:   host.Name("svc2.nsA.svc." + defaultFakeDomainSuffix),
+		Attributes: model.ServiceAttributes{Namespace: "nsA"},
+	}
+	controller.resumeShard([]*model.Service{kept, gone})
+	fx.StrictMatchOrFail(t,
+		xdsfake.Event{Type: "eds", ID: string(kept.Hostname), Namespace: "nsA"},
+		xdsfake.Event{Type: "service", ID: string(gone.Hostname), Namespace: "nsA"},
+	)
+}
@@ -33,6 +33,7 @@ import (
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/protocol"
 	"istio.io/istio/pkg/config/schema/gvr"
 	"istio.io/istio/pkg/kube/kclient"
 	"istio.io/istio/pkg/kube/kubetypes"
@@ -297,6 +298,9 @@ func (n *networkManager) extractGatewaysInner(svc *model.Service) bool {
 			if nodePort, exists := nodePortMap[gw.Port]; exists {
 				gw.Port = nodePort
 			}
+			if nodePort, exists := nodePortMap[gw.HBONEPort]; exists && gw.HBONEPort != 0 {
+				gw.HBONEPort = nodePort
+			}
 
 			gw.Cluster = n.clusterID
 			gw.Addr = addr
@@ -314,8 +318,19 @@ func (n *networkManager) extractGatewaysInner(svc *model.Service) bool {
 	return gatewaysChanged
 }
 
-// getGatewayDetails returns gateways without the address populated, only the network and (unmapped) port for a given service.
+// getGatewayDetails returns gateways without the address populated, only the network and (unmapped) ports for a given service.
 func (n *networkManager) getGatewayDetails(svc *model.Service) []model.NetworkGateway {
+	gws := n.getGatewayDetailsInner(svc)
+	// A gateway Service exposing the HBONE port can tunnel to the workloads of its network.
+	if _, f := svc.Ports.GetByPort(model.HBoneInboundListenPort); f {
+		for i := range gws {
+			gws[i].HBONEPort = model.HBoneInboundListenPort
+		}
+	}
+	return gws
+}
+
+func (n *networkManager) getGatewayDetailsInner(svc *model.Service) []model.NetworkGateway {
 	// TODO should we start checking if svc's Ports contain the gateway port?
 
 	// label based gateways
@@ -380,6 +395,12 @@ func (n *networkManager) handleGatewayResource(_ *v1beta1.Gateway, gw *v1beta1.G
 		Network: network.ID(gw.GetLabels()[label.TopologyNetwork.Name]),
 		Cluster: n.clusterID,
 	}
+	for _, l := range gw.Spec.Listeners {
+		if string(l.Protocol) == string(protocol.HBONE) {
+			base.HBONEPort = uint32(l.Port)
+			break
+		}
+	}
 	newGateways := model.NetworkGatewaySet{}
 	for _, addr := range gw.Spec.Addresses {
 		if addr.Type == nil {
@@ -16,6 +16,7 @@ package controller
 
 import (
 	"fmt"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -229,3 +230,68 @@ func addMeshNetworksFromRegistryGateway(t *testing.T, c *FakeController, watcher
 		},
 	}})
 }
+
+func TestNetworkGatewayHBONEPort(t *testing.T) {
+	test.SetForTest(t, &features.MultiNetworkGatewayAPI, true)
+	c, _ := NewFakeControllerWithOptions(t, FakeControllerOptions{
+		ClusterID:       "Kubernetes",
+		NetworksWatcher: mesh.NewFixedNetworksWatcher(nil),
+		DomainSuffix:    "cluster.local",
+		CRDs:            []schema.GroupVersionResource{gvr.KubernetesGateway},
+	})
+	expectHBONEPorts := func(t *testing.T, want map[string]uint32) {
+		t.Helper()
+		retry.UntilSuccessOrFail(t, func() error {
+			got := map[string]uint32{}
+			for _, gw := range c.NetworkGateways() {
+				got[fmt.Sprintf("%s:%d", gw.Addr, gw.Port)] = gw.HBONEPort
+			}
+			if !reflect.DeepEqual(got, want) {
+				return fmt.Errorf("expected gateways %v, got %v", want, got)
+			}
+			return nil
+		}, retry.Timeout(5*time.Second), retry.Delay(10*time.Millisecond))
+	}
+
+	t.Run("service without hbone port", func(t *testing.T) {
+		addLabeledServiceGateway(t, c, "nw1")
+		expectHBONEPorts(t, map[string]uint32{"2.3.4.6:15443": 0})
+	})
+	t.Run("service with hbone port", func(t *testing.T) {
+		clienttest.Wrap(t, c.services).CreateOrUpdate(&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "istio-labeled-gw", Namespace: "arbitrary-ns", Labels: map[string]string{
+				label.TopologyNetwork.Name: "nw1",
+			}},
+			Spec: corev1.ServiceSpec{
+				Type: corev1.ServiceTypeLoadBalancer,
+				Ports: []corev1.ServicePort{
+					{Name: "tls", Port: 15443, Protocol: corev1.ProtocolTCP},
+					{Name: "tls-hbone", Port: 15008, Protocol: corev1.ProtocolTCP},
+				},
+			},
+			Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{Ingress: []corev1.LoadBalancerIngress{{IP: "2.3.4.6"}}}},
+		})
+		expectHBONEPorts(t, map[string]uint32{"2.3.4.6:15443": 15008})
+	})
+	t.Run("gateway with hbone listener", func(t *testing.T) {
+		removeLabeledServiceGateway(t, c)
+		passthroughMode := v1beta1.TLSModePassthrough
+		ipType := v1beta1.IPAddressType
+		clienttest.Wrap(t, kclient.New[*v1beta1.Gateway](c.client)).CreateOrUpdate(&v1beta1.Gateway{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "eastwest-gwapi",
+				Namespace: "istio-system",
+				Labels:    map[string]string{label.TopologyNetwork.Name: "nw2"},
+			},
+			Spec: v1beta1.GatewaySpec{
+				GatewayClassName: "istio",
+				Addresses:        []v1beta1.GatewayAddress{{Type: &ipType, Value: "1.2.3.4"}},
+				Listeners: []v1beta1.Listener{
+					{Name: "mtls", TLS: &v1beta1.GatewayTLSConfig{Mode: &passthroughMode}, Port: 15443},
+					{Name: "hbone", Protocol: "HBONE", Port: 15008},
+				},
+			},
+		})
+		expectHBONEPorts(t, map[string]uint32{"1.2.3.4:15443": 15008})
+	})
+}
@@ -46,6 +46,9 @@ type PodCache struct {
 	needResync         map[string]sets.Set[types.NamespacedName]
 	queueEndpointEvent func(types.NamespacedName)
 
+	// assertedLocalities are the localities asserted by the proxies of the pods, see Controller.RecordProxyLocality.
+	assertedLocalities map[types.NamespacedName]assertedLocality
+
 	c *Controller
 }
 
@@ -57,6 +60,7 @@ func newPodCache(c *Controller, pods kclient.Client[*v1.Pod], queueEndpointEvent
 		IPByPods:           make(map[types.NamespacedName]string),
 		needResync:         make(map[string]sets.Set[types.NamespacedName]),
 		queueEndpointEvent: queueEndpointEvent,
+		assertedLocalities: make(map[types.NamespacedName]assertedLocality),
 	}
 
 	return out
@@ -164,6 +168,9 @@ func (pc *PodCache) onEvent(_, pod *v1.Pod, ev model.Event) error {
 			if !pc.deleteIP(ip, key) {
 				return nil
 			}
+			if isPodPhaseTerminal(pod.Status.Phase) {
+				pc.resyncCompletedPod(pod)
+			}
 			ev = model.EventDelete
 		} else if shouldPodBeInEndpoints(pod) && IsPodReady(pod) {
 			pc.update(ip, key)
@@ -171,6 +178,7 @@ func (pc *PodCache) onEvent(_, pod *v1.Pod, ev model.Event) error {
 			return nil
 		}
 	case model.EventDelete:
+		pc.setAssertedLocality(key, assertedLocality{})
 		// delete only if this pod was in the cache,
 		// in most case it has already been deleted in `UPDATE` with `DeletionTimestamp` set.
 		if !pc.deleteIP(ip, key) {
@@ -253,6 +261,17 @@ func (pc *PodCache) update(ip string, key types.NamespacedName) {
 	pc.proxyUpdates(ip)
 }
 
+// resyncCompletedPod queues an endpoint event for the EndpointSlices of a pod which completed, so that its endpoints
+// are handled as set by features.CompletedPodEndpoints even if the EndpointSlices are not updated.
+func (pc *PodCache) resyncCompletedPod(pod *v1.Pod) {
+	if pc.c == nil || pc.c.endpoints == nil {
+		return
+	}
+	for _, key := range pc.c.endpoints.slicesForPod(pod) {
+		pc.queueEndpointEvent(key)
+	}
+}
+
 // queueEndpointEventOnPodArrival registers this endpoint and queues endpoint event
 // when the corresponding pod arrives.
 func (pc *PodCache) queueEndpointEventOnPodArrival(key types.NamespacedName, ip string) {
@@ -297,6 +316,32 @@ func (pc *PodCache) getPodByKey(key types.NamespacedName) *v1.Pod {
 	return pc.pods.Get(key.Name, key.Namespace)
 }
 
+// setAssertedLocality records the locality asserted by the proxy of the pod, and returns true if it changed.
+func (pc *PodCache) setAssertedLocality(key types.NamespacedName, asserted assertedLocality) bool {
+	if asserted.locality == "" {
+		asserted = assertedLocality{}
+	}
+	pc.Lock()
+	defer pc.Unlock()
+	if pc.assertedLocalities[key] == asserted {
+		return false
+	}
+	if asserted.locality == "" {
+		delete(pc.assertedLocalities, key)
+	} else {
+		pc.assertedLocalities[key] = asserted
+	}
+	return true
+}
+
+// getAssertedLocality returns the locality asserted by the proxy of the pod, if any.
+func (pc *PodCache) getAssertedLocality(key types.NamespacedName) (assertedLocality, bool) {
+	pc.RLock()
+	defer pc.RUnlock()
+	asserted, f := pc.assertedLocalities[key]
+	return asserted, f
+}
+
 // getPodByKey returns the pod of the proxy
 func (pc *PodCache) getPodByProxy(proxy *model.Proxy) *v1.Pod {
 	var pod *v1.Pod
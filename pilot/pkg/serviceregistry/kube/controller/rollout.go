@@ -0,0 +1,74 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/kube/kclient"
+)
+
+// initRollouts watches the Deployments for the rollout weight annotation, recording the weights of their workloads
+// in the shared RolloutWeights.
+func (c *Controller) initRollouts() {
+	c.deployments = kclient.NewFiltered[*appsv1.Deployment](c.client, kclient.Filter{
+		ObjectFilter: c.opts.DiscoveryNamespacesFilter.Filter,
+	})
+	registerHandlers[*appsv1.Deployment](c, c.deployments, "Deployments", c.onDeploymentEvent, func(old, cur *appsv1.Deployment) bool {
+		return old.Annotations[constants.RolloutWeightAnnotation] == cur.Annotations[constants.RolloutWeightAnnotation]
+	})
+}
+
+func (c *Controller) onDeploymentEvent(_, deployment *appsv1.Deployment, event model.Event) error {
+	percentage, ok := rolloutWeight(deployment)
+	if event == model.EventDelete {
+		ok = false
+	}
+	if c.opts.RolloutWeights.Set(c.Cluster(), deployment.Namespace, deployment.Name, percentage, ok) {
+		c.pushRolloutWeights()
+	}
+	return nil
+}
+
+// rolloutWeight returns the percentage of the traffic sent to the Deployment. Invalid values are ignored.
+func rolloutWeight(deployment *appsv1.Deployment) (uint32, bool) {
+	v, f := deployment.Annotations[constants.RolloutWeightAnnotation]
+	if !f {
+		return 0, false
+	}
+	percentage, err := strconv.ParseUint(v, 10, 32)
+	if err != nil || percentage > 100 {
+		log.Warnf("invalid %s annotation %q of deployment %s/%s", constants.RolloutWeightAnnotation, v,
+			deployment.Namespace, deployment.Name)
+		return 0, false
+	}
+	return uint32(percentage), true
+}
+
+// pushRolloutWeights pushes the endpoints reweighted by a change of the rollout weights. The weights are part of the
+// cache key of the endpoints, which are all rebuilt.
+func (c *Controller) pushRolloutWeights() {
+	if c.opts.XDSUpdater == nil {
+		return
+	}
+	c.opts.XDSUpdater.ConfigUpdate(&model.PushRequest{
+		Full:   true,
+		Reason: model.NewReasonStats(model.RolloutUpdate),
+	})
+}
@@ -0,0 +1,73 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/kube/kclient/clienttest"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func TestRolloutWeights(t *testing.T) {
+	test.SetForTest(t, &features.EnableRolloutWeights, true)
+	rollouts := model.NewRolloutWeights()
+	c, fx := NewFakeControllerWithOptions(t, FakeControllerOptions{ClusterID: "cluster-1", RolloutWeights: rollouts})
+	deployments := clienttest.Wrap(t, c.deployments)
+	canary := &model.IstioEndpoint{
+		Namespace:    "default",
+		WorkloadName: "reviews-v2",
+		Locality:     model.Locality{ClusterID: "cluster-1"},
+	}
+	weightOf := func() any {
+		w, ok := rollouts.Weight(canary)
+		if !ok {
+			return "none"
+		}
+		return w
+	}
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Name:        "reviews-v2",
+		Namespace:   "default",
+		Annotations: map[string]string{constants.RolloutWeightAnnotation: "10"},
+	}}
+
+	deployments.Create(deployment)
+	retry.UntilOrFail(t, func() bool { return weightOf() == uint32(10) })
+	fx.WaitOrFail(t, "xds full")
+
+	deployment.Annotations[constants.RolloutWeightAnnotation] = "invalid"
+	deployments.Update(deployment)
+	retry.UntilOrFail(t, func() bool { return weightOf() == "none" })
+	fx.WaitOrFail(t, "xds full")
+
+	deployment.Annotations[constants.RolloutWeightAnnotation] = "50"
+	deployments.Update(deployment)
+	retry.UntilOrFail(t, func() bool { return weightOf() == uint32(50) })
+	fx.WaitOrFail(t, "xds full")
+
+	deployments.Delete(deployment.Name, deployment.Namespace)
+	retry.UntilOrFail(t, func() bool { return weightOf() == "none" })
+	fx.WaitOrFail(t, "xds full")
+	assert.Equal(t, rollouts.Empty(), true)
+}
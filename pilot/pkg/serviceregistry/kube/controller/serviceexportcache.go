@@ -26,7 +26,6 @@ import (
 	"istio.io/istio/pilot/pkg/model"
 	kubesr "istio.io/istio/pilot/pkg/serviceregistry/kube"
 	"istio.io/istio/pkg/config"
-	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/kube/controllers"
 	"istio.io/istio/pkg/kube/kclient"
@@ -45,6 +44,10 @@ type serviceExportCache interface {
 	// EndpointDiscoverabilityPolicy returns the policy for Service endpoints residing within the current cluster.
 	EndpointDiscoverabilityPolicy(svc *model.Service) model.EndpointDiscoverabilityPolicy
 
+	// ClusterSetEndpointDiscoverabilityPolicy returns the policy for the endpoints residing within the current cluster
+	// of the clusterset.local host of the Service, when this cluster has no service for that host.
+	ClusterSetEndpointDiscoverabilityPolicy(name types.NamespacedName) model.EndpointDiscoverabilityPolicy
+
 	// ExportedServices returns the list of services that are exported in this cluster. Used for debugging.
 	ExportedServices() []exportedService
 	Run(stop <-chan struct{})
@@ -68,14 +71,7 @@ func newServiceExportCache(c *Controller) serviceExportCache {
 
 		// Set the discoverability policy for the clusterset.local host.
 		ec.clusterSetLocalPolicySelector = func(svc *model.Service) (policy model.EndpointDiscoverabilityPolicy) {
-			// If the service is exported in this cluster, allow the endpoints in this cluster to be discoverable
-			// anywhere in the mesh.
-			if ec.isExported(namespacedNameForService(svc)) {
-				return model.AlwaysDiscoverable
-			}
-
-			// Otherwise, endpoints are only discoverable from within the same cluster.
-			return model.DiscoverableFromSameCluster
+			return ec.ClusterSetEndpointDiscoverabilityPolicy(namespacedNameForService(svc))
 		}
 
 		// Set the discoverability policy for the cluster.local host.
@@ -129,12 +125,22 @@ func (ec *serviceExportCacheImpl) onServiceExportEvent(_, obj controllers.Object
 }
 
 func (ec *serviceExportCacheImpl) updateXDS(se metav1.Object) {
-	for _, svc := range ec.servicesForNamespacedName(config.NamespacedName(se)) {
+	name := config.NamespacedName(se)
+	shard := model.ShardKeyFromRegistry(ec)
+	for _, hostName := range ec.hostNamesForNamespacedName(name) {
 		// Re-build the endpoints for this service with a new discoverability policy.
 		// Also update any internal caching.
-		endpoints := ec.buildEndpointsForService(svc, true)
-		shard := model.ShardKeyFromRegistry(ec)
-		ec.opts.XDSUpdater.EDSUpdate(shard, svc.Hostname.String(), se.GetNamespace(), endpoints)
+		var endpoints []*model.IstioEndpoint
+		if svc := ec.GetService(hostName); svc != nil {
+			endpoints = ec.buildEndpointsForService(svc, true)
+		} else if isClusterSetLocalHost(hostName) {
+			// The Service is not imported in this cluster, but its exported endpoints are still aggregated
+			// with those of the other clusters under the clusterset.local host.
+			endpoints = ec.endpoints.buildIstioEndpointsWithService(name.Name, name.Namespace, hostName, true)
+		} else {
+			continue
+		}
+		ec.opts.XDSUpdater.EDSUpdate(shard, hostName.String(), name.Namespace, endpoints)
 	}
 }
 
@@ -144,13 +150,28 @@ func (ec *serviceExportCacheImpl) EndpointDiscoverabilityPolicy(svc *model.Servi
 		return model.DiscoverableFromSameCluster
 	}
 
-	if strings.HasSuffix(svc.Hostname.String(), "."+constants.DefaultClusterSetLocalDomain) {
+	if isClusterSetLocalHost(svc.Hostname) {
 		return ec.clusterSetLocalPolicySelector(svc)
 	}
 
 	return ec.clusterLocalPolicySelector(svc)
 }
 
+func (ec *serviceExportCacheImpl) ClusterSetEndpointDiscoverabilityPolicy(name types.NamespacedName) model.EndpointDiscoverabilityPolicy {
+	// If the service is exported in this cluster, allow the endpoints in this cluster to be discoverable
+	// anywhere in the mesh.
+	if ec.isExported(name) {
+		return model.AlwaysDiscoverable
+	}
+
+	// Otherwise, endpoints are only discoverable from within the same cluster.
+	return model.DiscoverableFromSameCluster
+}
+
+func isClusterSetLocalHost(hostName host.Name) bool {
+	return strings.HasSuffix(hostName.String(), mcsDomainSuffix)
+}
+
 func (ec *serviceExportCacheImpl) isExported(name types.NamespacedName) bool {
 	return ec.serviceExports.Get(name.Name, name.Namespace) != nil
 }
@@ -201,6 +222,10 @@ func (c disabledServiceExportCache) EndpointDiscoverabilityPolicy(*model.Service
 	return model.AlwaysDiscoverable
 }
 
+func (c disabledServiceExportCache) ClusterSetEndpointDiscoverabilityPolicy(types.NamespacedName) model.EndpointDiscoverabilityPolicy {
+	return model.AlwaysDiscoverable
+}
+
 func (c disabledServiceExportCache) Run(stop <-chan struct{}) {}
 
 func (c disabledServiceExportCache) HasSynced() bool {
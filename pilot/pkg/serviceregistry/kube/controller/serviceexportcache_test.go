@@ -34,6 +34,7 @@ import (
 	"istio.io/istio/pkg/maps"
 	"istio.io/istio/pkg/slices"
 	istiotest "istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
 	"istio.io/istio/pkg/test/util/retry"
 )
 
@@ -102,6 +103,44 @@ func TestServiceUnexported(t *testing.T) {
 	}
 }
 
+func TestClusterSetEndpointsExported(t *testing.T) {
+	istiotest.SetForTest(t, &features.EnableMCSHost, true)
+	ec, endpoints := newTestServiceExportCache(t, alwaysClusterLocal)
+	clusterSetHost := serviceClusterSetLocalHostname(serviceExportNamespacedName)
+	getClusterSetEndpoint := func() *model.IstioEndpoint {
+		shards, ok := endpoints.ShardsForService(clusterSetHost.String(), serviceExportNamespace)
+		if !ok {
+			return nil
+		}
+		shards.RLock()
+		defer shards.RUnlock()
+		if eps := slices.Flatten(maps.Values(shards.Shards)); len(eps) > 0 {
+			return eps[0]
+		}
+		return nil
+	}
+
+	// The Service is not imported in this cluster, so the clusterset.local host has no service; its endpoints are
+	// only discoverable from this cluster until the Service is exported.
+	assert.Equal(t, ec.GetService(clusterSetHost) == nil, true)
+	retry.UntilSuccessOrFail(t, func() error {
+		ep := getClusterSetEndpoint()
+		if ep == nil {
+			return fmt.Errorf("no endpoint for %s", clusterSetHost)
+		}
+		return ec.checkNotDiscoverableFromDifferentCluster(ep)
+	}, serviceExportTimeout)
+
+	ec.export(t)
+	retry.UntilSuccessOrFail(t, func() error {
+		ep := getClusterSetEndpoint()
+		if ep == nil {
+			return fmt.Errorf("no endpoint for %s", clusterSetHost)
+		}
+		return ec.checkDiscoverableFromDifferentCluster(ep)
+	}, serviceExportTimeout)
+}
+
 func newServiceExport() *unstructured.Unstructured {
 	se := &mcsapi.ServiceExport{
 		TypeMeta: metav1.TypeMeta{
@@ -18,10 +18,13 @@ import (
 	"sort"
 	"strings"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	klabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	mcsapi "sigs.k8s.io/mcs-api/pkg/apis/v1alpha1"
 
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
@@ -30,6 +33,7 @@ import (
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
+	kubeconfig "istio.io/istio/pkg/config/kube"
 	"istio.io/istio/pkg/config/schema/kind"
 	"istio.io/istio/pkg/kube/controllers"
 	"istio.io/istio/pkg/kube/kclient"
@@ -116,7 +120,11 @@ func (ic *serviceImportCacheImpl) onServiceEvent(_, curr *model.Service, event m
 
 		// Get the ClusterSet VIPs for this service in this cluster. Will only be populated if the
 		// service has a ServiceImport in this cluster.
-		vips := ic.getClusterSetIPs(namespacedName)
+		si := ic.lookupServiceImport(namespacedName)
+		var vips []string
+		if si != nil {
+			vips = GetServiceImportIPs(si)
+		}
 		name := namespacedName.Name
 		ns := namespacedName.Namespace
 
@@ -135,7 +143,7 @@ func (ic *serviceImportCacheImpl) onServiceEvent(_, curr *model.Service, event m
 			event = model.EventAdd
 		}
 
-		mcsService := ic.genMCSService(curr, mcsHost, vips)
+		mcsService := ic.genMCSService(curr, mcsHost, si)
 		ic.addOrUpdateService(nil, mcsService, event, false)
 		return nil
 	})
@@ -174,19 +182,31 @@ func (ic *serviceImportCacheImpl) onServiceImportEvent(_, obj controllers.Object
 		}
 
 		// Create the MCS service from the cluster.local service.
-		mcsService = ic.genMCSService(realService, mcsHost, ips)
+		mcsService = ic.genMCSService(realService, mcsHost, si)
 	} else {
 		if event == model.EventDelete || len(ips) == 0 {
 			ic.deleteService(mcsService)
+			// The endpoints of this cluster are still aggregated under the clusterset.local host while the
+			// Service is exported.
+			name := config.NamespacedName(si)
+			if ic.exports.ClusterSetEndpointDiscoverabilityPolicy(name) == model.AlwaysDiscoverable {
+				endpoints := ic.endpoints.buildIstioEndpointsWithService(name.Name, name.Namespace, mcsHost, true)
+				ic.opts.XDSUpdater.EDSUpdate(model.ShardKeyFromRegistry(ic), mcsHost.String(), name.Namespace, endpoints)
+			}
 			return nil
 		}
 
-		// The service already existed. Treat it as an update.
+		// The service already existed. Treat it as an update. The service is shared with the readers of the
+		// registry, so the update is made on a copy.
 		event = model.EventUpdate
+		mcsService = mcsService.DeepCopy()
 
 		if ic.updateIPs(mcsService, ips) {
 			needsFullPush = true
 		}
+		if updateServiceImportProperties(mcsService, si) {
+			needsFullPush = true
+		}
 	}
 
 	// Always force a rebuild of the endpoint cache in case this import caused
@@ -238,22 +258,66 @@ func GetServiceImportIPs(si *unstructured.Unstructured) []string {
 	return ips
 }
 
-// genMCSService generates an MCS service based on the given real k8s service. The list of vips must be non-empty.
-func (ic *serviceImportCacheImpl) genMCSService(realService *model.Service, mcsHost host.Name, vips []string) *model.Service {
+// genMCSService generates an MCS service based on the given real k8s service. The ServiceImport must have
+// ClusterSet IPs.
+func (ic *serviceImportCacheImpl) genMCSService(realService *model.Service, mcsHost host.Name, si *unstructured.Unstructured) *model.Service {
+	vips := GetServiceImportIPs(si)
 	mcsService := realService.DeepCopy()
 	mcsService.Hostname = mcsHost
 	mcsService.DefaultAddress = vips[0]
 	mcsService.ClusterVIPs.Addresses = map[cluster.ID][]string{
 		ic.Cluster(): vips,
 	}
+	updateServiceImportProperties(mcsService, si)
 
 	return mcsService
 }
 
-func (ic *serviceImportCacheImpl) getClusterSetIPs(name types.NamespacedName) []string {
+// updateServiceImportProperties sets the ports and the session affinity of the MCS service from the ServiceImport,
+// when features.EnableMCSServiceImportProperties is enabled. The exported Services of the clusters may conflict,
+// for instance on the protocol of a port; the MCS controller resolves the conflicts into the ServiceImport, so its
+// properties apply to the endpoints of all the clusters rather than those of the Service the MCS service was
+// generated from. The endpoints are still selected by port name. Returns true if the service was updated.
+func updateServiceImportProperties(mcsService *model.Service, si *unstructured.Unstructured) (updated bool) {
+	if !features.EnableMCSServiceImportProperties {
+		return false
+	}
+	spec := mcsapi.ServiceImportSpec{}
+	if rawSpec, ok := si.Object["spec"].(map[string]any); ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rawSpec, &spec); err != nil {
+			log.Warnf("failed decoding ServiceImport %s/%s: %v", si.GetNamespace(), si.GetName(), err)
+			return false
+		}
+	}
+
+	if ports := getServiceImportPorts(spec); len(ports) > 0 && !ports.Equals(mcsService.Ports) {
+		mcsService.Ports = ports
+		updated = true
+	}
+	affinity := spec.SessionAffinity == corev1.ServiceAffinityClientIP
+	if mcsService.Attributes.SessionAffinityClientIP != affinity {
+		mcsService.Attributes.SessionAffinityClientIP = affinity
+		updated = true
+	}
+	return
+}
+
+func getServiceImportPorts(spec mcsapi.ServiceImportSpec) model.PortList {
+	ports := make(model.PortList, 0, len(spec.Ports))
+	for _, port := range spec.Ports {
+		ports = append(ports, &model.Port{
+			Name:     port.Name,
+			Port:     int(port.Port),
+			Protocol: kubeconfig.ConvertProtocol(port.Port, port.Name, port.Protocol, port.AppProtocol),
+		})
+	}
+	return ports
+}
+
+func (ic *serviceImportCacheImpl) lookupServiceImport(name types.NamespacedName) *unstructured.Unstructured {
 	si := ic.serviceImports.Get(name.Name, name.Namespace)
 	if si != nil {
-		return GetServiceImportIPs(si.(*unstructured.Unstructured))
+		return si.(*unstructured.Unstructured)
 	}
 	return nil
 }
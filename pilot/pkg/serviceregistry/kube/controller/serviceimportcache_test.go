@@ -35,7 +35,9 @@ import (
 	"istio.io/istio/pilot/pkg/serviceregistry/kube"
 	"istio.io/istio/pilot/pkg/serviceregistry/util/xdsfake"
 	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/protocol"
 	"istio.io/istio/pkg/kube/mcs"
+	"istio.io/istio/pkg/ptr"
 	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/test/util/assert"
 	"istio.io/istio/pkg/test/util/retry"
@@ -165,6 +167,53 @@ func TestUpdateServiceImportVIPs(t *testing.T) {
 	ic.setServiceImportVIPs(t, updatedVIPs)
 }
 
+func TestServiceImportProperties(t *testing.T) {
+	test.SetForTest(t, &features.EnableMCSServiceImportProperties, true)
+	c, ic := newTestServiceImportCache(t)
+
+	ic.createKubeService(t, c)
+	ic.createServiceImport(t, mcsapi.ClusterSetIP, serviceImportVIPs)
+	ic.checkServiceInstances(t)
+
+	// The ServiceImport has no ports, so the ports of the k8s service are kept.
+	svc := ic.GetService(serviceImportClusterSetHost)
+	assert.Equal(t, svc.Ports.GetNames(), []string{"tcp-port"})
+	assert.Equal(t, svc.Attributes.SessionAffinityClientIP, false)
+
+	si := ic.getServiceImport(t)
+	si.Spec.Ports = []mcsapi.ServicePort{{Name: "tcp-port", Port: 8080, Protocol: "TCP", AppProtocol: ptr.Of("http")}}
+	si.Spec.SessionAffinity = "ClientIP"
+	if _, err := ic.client.Dynamic().Resource(mcs.ServiceImportGVR).Namespace(serviceImportNamespace).Update(
+		context.TODO(), toUnstructured(si), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	retry.UntilSuccessOrFail(t, func() error {
+		svc := ic.GetService(serviceImportClusterSetHost)
+		if svc == nil {
+			return fmt.Errorf("failed to find service for %s", serviceImportClusterSetHost)
+		}
+		if !svc.Attributes.SessionAffinityClientIP {
+			return fmt.Errorf("session affinity not set for %s", serviceImportClusterSetHost)
+		}
+		if p, _ := svc.Ports.Get("tcp-port"); p == nil || p.Protocol != protocol.HTTP {
+			return fmt.Errorf("unexpected ports %v for %s", svc.Ports, serviceImportClusterSetHost)
+		}
+		return nil
+	}, serviceImportTimeout)
+	ic.checkXDS(t)
+	// The service read before the update is left unchanged.
+	assert.Equal(t, svc.Attributes.SessionAffinityClientIP, false)
+
+	// The properties of the ServiceImport still apply when the k8s service is updated.
+	ic.updateKubeService(t)
+	svc = ic.GetService(serviceImportClusterSetHost)
+	assert.Equal(t, svc.Attributes.SessionAffinityClientIP, true)
+	p, _ := svc.Ports.Get("tcp-port")
+	assert.Equal(t, p.Protocol, protocol.HTTP)
+	// The k8s service is not affected.
+	assert.Equal(t, ic.GetService(ic.clusterLocalHost()).Attributes.SessionAffinityClientIP, false)
+}
+
 func newTestServiceImportCache(t test.Failer) (*FakeController, *serviceImportCacheImpl) {
 	test.SetForTest(t, &features.EnableMCSHost, true)
 
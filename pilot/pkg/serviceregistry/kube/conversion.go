@@ -16,7 +16,9 @@ package kube
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -49,14 +51,25 @@ func ConvertService(svc corev1.Service, domainSuffix string, clusterID cluster.I
 	resolution := model.ClientSideLB
 	externalName := ""
 	nodeLocal := false
+	preferNodeLocal := false
 
+	resolveExternalName := false
 	if svc.Spec.Type == corev1.ServiceTypeExternalName && svc.Spec.ExternalName != "" {
 		resolution = model.DNSLB
 		externalName = svc.Spec.ExternalName
+		if svc.Annotations[constants.ExternalNameResolutionAnnotation] == constants.ExternalNameResolutionEDS {
+			// The addresses of the external name are resolved by istiod and served through EDS.
+			resolution = model.ClientSideLB
+			resolveExternalName = true
+		}
 	}
 	if svc.Spec.InternalTrafficPolicy != nil && *svc.Spec.InternalTrafficPolicy == corev1.ServiceInternalTrafficPolicyLocal {
 		nodeLocal = true
 	}
+	if svc.Annotations[constants.InternalTrafficPolicyAnnotation] == constants.InternalTrafficPolicyPreferLocal {
+		nodeLocal = false
+		preferNodeLocal = true
+	}
 
 	if svc.Spec.ClusterIP == corev1.ClusterIPNone { // headless services should not be load balanced
 		resolution = model.Passthrough
@@ -153,7 +166,18 @@ func ConvertService(svc corev1.Service, domainSuffix string, clusterID cluster.I
 
 	istioService.Attributes.Type = string(svc.Spec.Type)
 	istioService.Attributes.ExternalName = externalName
+	istioService.Attributes.ResolveExternalName = resolveExternalName
 	istioService.Attributes.NodeLocal = nodeLocal
+	istioService.Attributes.PreferNodeLocal = preferNodeLocal
+	istioService.Attributes.PublishNotReadyAddresses = svc.Spec.PublishNotReadyAddresses
+	istioService.Attributes.EndpointPushPolicy = convertEndpointPushPolicy(svc.Annotations[constants.EndpointPushPolicyAnnotation])
+	istioService.Attributes.IncludeTerminating = convertIncludeTerminating(svc.Annotations[constants.IncludeTerminatingAnnotation])
+	istioService.Attributes.CrossNetworkFailover = convertCrossNetworkFailover(svc.Annotations[constants.CrossNetworkFailoverAnnotation])
+	istioService.Attributes.DirectPodClusters = svc.Annotations[constants.DirectPodClustersAnnotation] == "true"
+	istioService.Attributes.DisablePrecomputedEndpoints = svc.Annotations[constants.PrecomputeEndpointsAnnotation] == "false"
+	istioService.Attributes.TopologyModeAuto = topologyModeAuto(svc.Annotations)
+	istioService.Attributes.AddressType = ConvertAddressType(svc.Annotations[constants.AddressTypeAnnotation])
+	istioService.Attributes.ScaleUpRamp = convertScaleUpRamp(svc.Annotations[constants.ScaleUpRampAnnotation])
 	if len(svc.Spec.ExternalIPs) > 0 {
 		if istioService.Attributes.ClusterExternalAddresses == nil {
 			istioService.Attributes.ClusterExternalAddresses = &model.AddressMap{}
@@ -164,11 +188,105 @@ func ConvertService(svc corev1.Service, domainSuffix string, clusterID cluster.I
 	return istioService
 }
 
+// convertEndpointPushPolicy parses the value of the endpoint push policy annotation. Invalid values
+// fall back to the default policy.
+func convertEndpointPushPolicy(value string) model.EndpointPushPolicy {
+	if value == "" {
+		return model.EndpointPushPolicy{}
+	}
+	if value == "immediate" {
+		return model.EndpointPushPolicy{Immediate: true}
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return model.EndpointPushPolicy{}
+	}
+	return model.EndpointPushPolicy{Debounce: d}
+}
+
+func convertIncludeTerminating(value string) string {
+	switch value {
+	case constants.IncludeTerminatingAlways, constants.IncludeTerminatingWhenNoReady, constants.IncludeTerminatingNever:
+		return value
+	default:
+		return ""
+	}
+}
+
+// convertCrossNetworkFailover returns the value of a networking.istio.io/cross-network-failover annotation, or "" if
+// invalid.
+func convertCrossNetworkFailover(value string) string {
+	switch value {
+	case constants.CrossNetworkFailoverWeighted, constants.CrossNetworkFailoverPriority:
+		return value
+	default:
+		return ""
+	}
+}
+
+// topologyModeAuto returns true if the annotations enable topology aware routing, as for the Kubernetes EndpointSlice
+// controller: the service.kubernetes.io/topology-mode annotation takes precedence over the deprecated
+// service.kubernetes.io/topology-aware-hints annotation.
+func topologyModeAuto(annotations map[string]string) bool {
+	mode, ok := annotations[corev1.AnnotationTopologyMode]
+	if !ok {
+		mode = annotations[corev1.DeprecatedAnnotationTopologyAwareHints]
+	}
+	return mode == "Auto" || mode == "auto"
+}
+
+// ConvertAddressType returns the address type of a traffic.istio.io/address-type annotation, or "" if invalid.
+func ConvertAddressType(value string) string {
+	switch value {
+	case constants.AddressTypePodIP, constants.AddressTypeHostIP:
+		return value
+	default:
+		return ""
+	}
+}
+
+// convertScaleUpRamp parses the window of the scale-up ramp annotation. Invalid values disable the ramp.
+func convertScaleUpRamp(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return d
+}
+
+// ConvertCapacityRPS parses the requests per second budget of the capacity annotation, or 0 if invalid.
+func ConvertCapacityRPS(value string) uint32 {
+	if value == "" {
+		return 0
+	}
+	rps, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0
+	}
+	return uint32(rps)
+}
+
 func ExternalNameEndpoints(svc *model.Service) []*model.IstioEndpoint {
-	if svc.Attributes.ExternalName == "" {
+	if svc.Attributes.ExternalName == "" || svc.Attributes.ResolveExternalName {
+		return nil
+	}
+	return externalNameEndpoints(svc, []string{svc.Attributes.ExternalName})
+}
+
+// ResolvedExternalNameEndpoints returns the endpoints of an ExternalName Service resolved by istiod, given the
+// addresses the external name resolves to.
+func ResolvedExternalNameEndpoints(svc *model.Service, addresses []string) []*model.IstioEndpoint {
+	if !svc.Attributes.ResolveExternalName {
 		return nil
 	}
-	out := make([]*model.IstioEndpoint, 0, len(svc.Ports))
+	return externalNameEndpoints(svc, addresses)
+}
+
+func externalNameEndpoints(svc *model.Service, addresses []string) []*model.IstioEndpoint {
+	out := make([]*model.IstioEndpoint, 0, len(svc.Ports)*len(addresses))
 
 	discoverabilityPolicy := model.AlwaysDiscoverable
 	if features.EnableMCSServiceDiscovery {
@@ -176,14 +294,16 @@ func ExternalNameEndpoints(svc *model.Service) []*model.IstioEndpoint {
 		// See https://github.com/kubernetes/enhancements/tree/master/keps/sig-multicluster/1645-multi-cluster-services-api#exporting-services.
 		discoverabilityPolicy = model.DiscoverableFromSameCluster
 	}
-	for _, portEntry := range svc.Ports {
-		out = append(out, &model.IstioEndpoint{
-			Address:               svc.Attributes.ExternalName,
-			EndpointPort:          uint32(portEntry.Port),
-			ServicePortName:       portEntry.Name,
-			Labels:                svc.Attributes.Labels,
-			DiscoverabilityPolicy: discoverabilityPolicy,
-		})
+	for _, address := range addresses {
+		for _, portEntry := range svc.Ports {
+			out = append(out, &model.IstioEndpoint{
+				Address:               address,
+				EndpointPort:          uint32(portEntry.Port),
+				ServicePortName:       portEntry.Name,
+				Labels:                svc.Attributes.Labels,
+				DiscoverabilityPolicy: discoverabilityPolicy,
+			})
+		}
 	}
 	return out
 }
@@ -25,7 +25,9 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"istio.io/api/annotation"
+	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/kube"
 	"istio.io/istio/pkg/config/protocol"
 	"istio.io/istio/pkg/spiffe"
@@ -457,6 +459,127 @@ func TestInternalTrafficPolicyServiceConversion(t *testing.T) {
 	}
 }
 
+func TestPreferLocalInternalTrafficPolicyServiceConversion(t *testing.T) {
+	local := corev1.ServiceInternalTrafficPolicyLocal
+	svc := corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "service1",
+			Namespace:   "default",
+			Annotations: map[string]string{constants.InternalTrafficPolicyAnnotation: constants.InternalTrafficPolicyPreferLocal},
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{
+				Name:     "http",
+				Port:     80,
+				Protocol: corev1.ProtocolTCP,
+			}},
+			InternalTrafficPolicy: &local,
+		},
+	}
+
+	service := ConvertService(svc, domainSuffix, clusterID)
+	if service == nil {
+		t.Fatalf("could not convert service")
+	}
+	if service.Attributes.NodeLocal || !service.Attributes.PreferNodeLocal {
+		t.Fatalf("expected node local endpoints to be preferred, got NodeLocal=%v PreferNodeLocal=%v",
+			service.Attributes.NodeLocal, service.Attributes.PreferNodeLocal)
+	}
+}
+
+func TestEndpointPushPolicyServiceConversion(t *testing.T) {
+	cases := []struct {
+		annotation string
+		expected   model.EndpointPushPolicy
+	}{
+		{annotation: "", expected: model.EndpointPushPolicy{}},
+		{annotation: "immediate", expected: model.EndpointPushPolicy{Immediate: true}},
+		{annotation: "30s", expected: model.EndpointPushPolicy{Debounce: 30 * time.Second}},
+		{annotation: "-1s", expected: model.EndpointPushPolicy{}},
+		{annotation: "invalid", expected: model.EndpointPushPolicy{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.annotation, func(t *testing.T) {
+			svc := corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "service1",
+					Namespace:   "default",
+					Annotations: map[string]string{constants.EndpointPushPolicyAnnotation: tc.annotation},
+				},
+				Spec: corev1.ServiceSpec{
+					Ports: []corev1.ServicePort{{Name: "http", Port: 80, Protocol: corev1.ProtocolTCP}},
+				},
+			}
+			service := ConvertService(svc, domainSuffix, clusterID)
+			if service.Attributes.EndpointPushPolicy != tc.expected {
+				t.Fatalf("expected push policy %+v, got %+v", tc.expected, service.Attributes.EndpointPushPolicy)
+			}
+		})
+	}
+}
+
+func TestTopologyModeServiceConversion(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		expected    bool
+	}{
+		{name: "none", expected: false},
+		{name: "auto", annotations: map[string]string{corev1.AnnotationTopologyMode: "Auto"}, expected: true},
+		{name: "lowercase", annotations: map[string]string{corev1.AnnotationTopologyMode: "auto"}, expected: true},
+		{name: "disabled", annotations: map[string]string{corev1.AnnotationTopologyMode: "Disabled"}, expected: false},
+		{name: "deprecated", annotations: map[string]string{corev1.DeprecatedAnnotationTopologyAwareHints: "Auto"}, expected: true},
+		{
+			name: "mode takes precedence",
+			annotations: map[string]string{
+				corev1.AnnotationTopologyMode:                 "Disabled",
+				corev1.DeprecatedAnnotationTopologyAwareHints: "Auto",
+			},
+			expected: false,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "service1", Namespace: "default", Annotations: tc.annotations},
+				Spec: corev1.ServiceSpec{
+					Ports: []corev1.ServicePort{{Name: "http", Port: 80, Protocol: corev1.ProtocolTCP}},
+				},
+			}
+			service := ConvertService(svc, domainSuffix, clusterID)
+			if service.Attributes.TopologyModeAuto != tc.expected {
+				t.Fatalf("expected topology mode auto %v, got %v", tc.expected, service.Attributes.TopologyModeAuto)
+			}
+		})
+	}
+}
+
+func TestResolvedExternalNameServiceConversion(t *testing.T) {
+	svc := corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "service1",
+			Namespace:   "default",
+			Annotations: map[string]string{constants.ExternalNameResolutionAnnotation: constants.ExternalNameResolutionEDS},
+		},
+		Spec: corev1.ServiceSpec{
+			Type:         corev1.ServiceTypeExternalName,
+			ExternalName: "foo.example.com",
+			Ports:        []corev1.ServicePort{{Name: "http", Port: 80, Protocol: corev1.ProtocolTCP}},
+		},
+	}
+	service := ConvertService(svc, domainSuffix, clusterID)
+	if service.Resolution != model.ClientSideLB || !service.Attributes.ResolveExternalName {
+		t.Fatalf("expected the external name to be resolved by istiod, got resolution %v", service.Resolution)
+	}
+	if eps := ExternalNameEndpoints(service); len(eps) != 0 {
+		t.Fatalf("expected no hostname endpoints, got %v", eps)
+	}
+	eps := ResolvedExternalNameEndpoints(service, []string{"10.0.0.1", "10.0.0.2"})
+	if len(eps) != 2 || eps[0].Address != "10.0.0.1" || eps[1].EndpointPort != 80 {
+		t.Fatalf("unexpected endpoints %v", eps)
+	}
+}
+
 func TestSecureNamingSAN(t *testing.T) {
 	pod := &corev1.Pod{}
 
@@ -46,6 +46,7 @@ type ServiceDiscovery struct {
 	// We generally expect a single instance - conflicting services need to be reported.
 	ip2instance                map[string][]*model.ServiceInstance
 	WantGetProxyServiceTargets []model.ServiceTarget
+	WantMCSServices            []model.MCSServiceInfo
 	InstancesError             error
 	Controller                 model.Controller
 	ClusterID                  cluster.ID
@@ -311,7 +312,7 @@ func (sd *ServiceDiscovery) NetworkGateways() []model.NetworkGateway {
 }
 
 func (sd *ServiceDiscovery) MCSServices() []model.MCSServiceInfo {
-	return nil
+	return sd.WantMCSServices
 }
 
 // Memory does not support workload handlers; everything is done in terms of instances
@@ -15,6 +15,7 @@
 package serviceentry
 
 import (
+	"encoding/json"
 	"fmt"
 	"hash/fnv"
 	"strconv"
@@ -116,6 +117,9 @@ type Controller struct {
 	// Indicates whether this controller is for workload entries.
 	workloadEntryController bool
 
+	// batchMutex serializes the application of WorkloadEntry batches.
+	batchMutex sync.Mutex
+
 	model.NoopAmbientIndexes
 	model.NetworkGatewaysHandler
 }
@@ -211,9 +215,62 @@ func ConvertWorkloadEntry(cfg config.Config) *networking.WorkloadEntry {
 	copied := &networking.WorkloadEntry{}
 	protomarshal.ShallowCopy(copied, wle)
 	copied.Labels = labels
+	if copied.Locality == "" {
+		copied.Locality = localityOverride(cfg.Annotations, labels)
+	}
 	return copied
 }
 
+// localityOverride returns the locality set by the topology.istio.io/locality annotation or label, if any.
+func localityOverride(annotations, labels map[string]string) string {
+	if l := annotations[constants.LocalityOverride]; l != "" {
+		return model.GetLocalityLabel(l)
+	}
+	return model.GetLocalityLabel(labels[constants.LocalityOverride])
+}
+
+// workloadEntryPortLabels returns the labels of the ports of the WorkloadEntry set by the
+// networking.istio.io/port-labels annotation, if any.
+func workloadEntryPortLabels(cfg config.Config) map[string]labels.Instance {
+	v := cfg.Annotations[constants.WorkloadEntryPortLabelsAnnotation]
+	if v == "" {
+		return nil
+	}
+	var out map[string]labels.Instance
+	if err := json.Unmarshal([]byte(v), &out); err != nil {
+		log.Warnf("invalid %s annotation on workload entry %s/%s: %v", constants.WorkloadEntryPortLabelsAnnotation,
+			cfg.Namespace, cfg.Name, err)
+		return nil
+	}
+	return out
+}
+
+// upstreamSocketOptions returns the socket options of the upstream connections to the endpoints of the WorkloadEntry
+// or ServiceEntry set by the networking.istio.io/upstream-socket-options annotation, if any.
+func upstreamSocketOptions(cfg config.Config) *model.SocketOptions {
+	out, err := model.ParseSocketOptions(cfg.Annotations[constants.UpstreamSocketOptionsAnnotation])
+	if err != nil {
+		log.Warnf("invalid %s annotation on %s/%s: %v", constants.UpstreamSocketOptionsAnnotation, cfg.Namespace, cfg.Name, err)
+		return nil
+	}
+	return out
+}
+
+// endpointTTL returns the TTL of the endpoints of the WorkloadEntry or of the inline endpoints of the ServiceEntry,
+// set by the networking.istio.io/endpoint-ttl annotation.
+func endpointTTL(cfg config.Config) time.Duration {
+	v, f := cfg.Annotations[constants.EndpointTTLAnnotation]
+	if !f {
+		return 0
+	}
+	ttl, err := time.ParseDuration(v)
+	if err != nil || ttl <= 0 {
+		log.Warnf("invalid %s annotation on %s/%s: %q", constants.EndpointTTLAnnotation, cfg.Namespace, cfg.Name, v)
+		return 0
+	}
+	return ttl
+}
+
 // workloadEntryHandler defines the handler for workload entries
 func (s *Controller) workloadEntryHandler(old, curr config.Config, event model.Event) {
 	log.Debugf("Handle event %s for workload entry %s/%s", event, curr.Namespace, curr.Name)
@@ -271,6 +328,7 @@ func (s *Controller) workloadEntryHandler(old, curr config.Config, event model.E
 		}
 	}
 	unSelected := difference(oldSes, currSes)
+	annotations := model.EndpointMetadataAnnotations(curr.Annotations)
 	log.Debugf("workloadEntry %s/%s selected %v, unSelected %v serviceEntry", curr.Namespace, curr.Name, currSes, unSelected)
 	s.mutex.Lock()
 	for namespacedName, cfg := range currSes {
@@ -282,6 +340,12 @@ func (s *Controller) workloadEntryHandler(old, curr config.Config, event model.E
 			continue
 		}
 		instance := s.convertWorkloadEntryToServiceInstances(wle, services, se, &key, s.Cluster())
+		for _, si := range instance {
+			si.Endpoint.Annotations = annotations
+			si.Endpoint.SocketOptions = wi.Endpoint.SocketOptions
+			si.Endpoint.TTL = wi.Endpoint.TTL
+			si.Endpoint.Labels = wi.EndpointLabels(si.Endpoint.ServicePortName)
+		}
 		instancesUpdated = append(instancesUpdated, instance...)
 		if event == model.EventDelete {
 			s.serviceInstances.deleteServiceEntryInstances(namespacedName, key)
@@ -544,6 +544,7 @@ func TestServiceDiscoveryWorkloadUpdate(t *testing.T) {
 		}
 		for _, i := range instances {
 			i.Endpoint.WorkloadName = "wl"
+			i.Endpoint.InstanceName = "wl"
 			i.Endpoint.Namespace = selector.Name
 		}
 		expectProxyInstances(t, sd, instances, "2.2.2.2")
@@ -572,6 +573,7 @@ func TestServiceDiscoveryWorkloadUpdate(t *testing.T) {
 		}
 		for _, i := range instances {
 			i.Endpoint.WorkloadName = "wl"
+			i.Endpoint.InstanceName = "wl"
 			i.Endpoint.Namespace = updated.Name
 		}
 
@@ -598,6 +600,7 @@ func TestServiceDiscoveryWorkloadUpdate(t *testing.T) {
 		}
 		for _, i := range instances {
 			i.Endpoint.WorkloadName = "wl"
+			i.Endpoint.InstanceName = "wl"
 			i.Endpoint.Namespace = selector.Name
 		}
 		updated := func() *config.Config {
@@ -644,6 +647,7 @@ func TestServiceDiscoveryWorkloadUpdate(t *testing.T) {
 		}
 		for _, i := range instances {
 			i.Endpoint.WorkloadName = "dnswl"
+			i.Endpoint.InstanceName = "dnswl"
 			i.Endpoint.Namespace = dnsSelector.Namespace
 		}
 		expectProxyInstances(t, sd, instances, "4.4.4.4")
@@ -664,6 +668,7 @@ func TestServiceDiscoveryWorkloadUpdate(t *testing.T) {
 		}
 		for _, i := range instances {
 			i.Endpoint.WorkloadName = "wl"
+			i.Endpoint.InstanceName = "wl"
 			i.Endpoint.Namespace = selector.Name
 		}
 		expectProxyInstances(t, sd, instances, "2.2.2.2")
@@ -674,6 +679,7 @@ func TestServiceDiscoveryWorkloadUpdate(t *testing.T) {
 				selector.Spec.(*networking.ServiceEntry).Ports[1], map[string]string{"app": "wle"}, "default"))
 		for _, i := range instances[2:] {
 			i.Endpoint.WorkloadName = "wl2"
+			i.Endpoint.InstanceName = "wl2"
 			i.Endpoint.Namespace = selector.Name
 		}
 		expectServiceInstances(t, sd, selector, 0, instances)
@@ -694,6 +700,7 @@ func TestServiceDiscoveryWorkloadUpdate(t *testing.T) {
 		}
 		for _, i := range instances {
 			i.Endpoint.WorkloadName = "wl"
+			i.Endpoint.InstanceName = "wl"
 			i.Endpoint.Namespace = selector.Name
 		}
 		expectProxyInstances(t, sd, instances, "2.2.2.2")
@@ -704,6 +711,7 @@ func TestServiceDiscoveryWorkloadUpdate(t *testing.T) {
 				selector.Spec.(*networking.ServiceEntry).Ports[1], map[string]string{"app": "wle"}, "default"))
 		for _, i := range instances[2:] {
 			i.Endpoint.WorkloadName = "wl2"
+			i.Endpoint.InstanceName = "wl2"
 			i.Endpoint.Namespace = selector.Name
 		}
 		expectServiceInstances(t, sd, selector, 0, instances)
@@ -723,6 +731,7 @@ func TestServiceDiscoveryWorkloadUpdate(t *testing.T) {
 		}
 		for _, i := range instances {
 			i.Endpoint.WorkloadName = "wl"
+			i.Endpoint.InstanceName = "wl"
 			i.Endpoint.Namespace = selector.Name
 		}
 		expectProxyInstances(t, sd, instances, "2.2.2.2")
@@ -746,6 +755,7 @@ func TestServiceDiscoveryWorkloadUpdate(t *testing.T) {
 		}
 		for _, i := range instances {
 			i.Endpoint.WorkloadName = "wl"
+			i.Endpoint.InstanceName = "wl"
 			i.Endpoint.Namespace = selector.Name
 		}
 		expectProxyInstances(t, sd, instances, "2.2.2.2")
@@ -805,6 +815,7 @@ func TestServiceDiscoveryWorkloadChangeLabel(t *testing.T) {
 		}
 		for _, i := range instances {
 			i.Endpoint.WorkloadName = "wl"
+			i.Endpoint.InstanceName = "wl"
 			i.Endpoint.Namespace = selector.Name
 		}
 		expectProxyInstances(t, sd, instances, "2.2.2.2")
@@ -848,10 +859,12 @@ func TestServiceDiscoveryWorkloadChangeLabel(t *testing.T) {
 		}
 		for _, i := range instances[:2] {
 			i.Endpoint.WorkloadName = "wl"
+			i.Endpoint.InstanceName = "wl"
 			i.Endpoint.Namespace = selector.Name
 		}
 		for _, i := range instances[2:] {
 			i.Endpoint.WorkloadName = "wl3"
+			i.Endpoint.InstanceName = "wl3"
 			i.Endpoint.Namespace = selector.Name
 		}
 		expectProxyInstances(t, sd, instances[:2], "2.2.2.2")
@@ -873,6 +886,7 @@ func TestServiceDiscoveryWorkloadChangeLabel(t *testing.T) {
 		}
 		for _, i := range instances {
 			i.Endpoint.WorkloadName = "wl3"
+			i.Endpoint.InstanceName = "wl3"
 			i.Endpoint.Namespace = selector.Name
 		}
 		expectServiceInstances(t, sd, selector, 0, instances)
@@ -942,6 +956,7 @@ func TestWorkloadInstanceFullPush(t *testing.T) {
 		}
 		for _, i := range instances {
 			i.Endpoint.WorkloadName = "wl"
+			i.Endpoint.InstanceName = "wl"
 			i.Endpoint.Namespace = selectorDNS.Name
 		}
 		expectProxyInstances(t, sd, instances, "postman-echo.com")
@@ -967,6 +982,7 @@ func TestWorkloadInstanceFullPush(t *testing.T) {
 
 		for _, i := range instances[2:] {
 			i.Endpoint.WorkloadName = "wl"
+			i.Endpoint.InstanceName = "wl"
 			i.Endpoint.Namespace = selectorDNS.Name
 		}
 
@@ -1000,6 +1016,7 @@ func TestWorkloadInstanceFullPush(t *testing.T) {
 
 		for _, i := range instances[2:] {
 			i.Endpoint.WorkloadName = "wl"
+			i.Endpoint.InstanceName = "wl"
 			i.Endpoint.Namespace = selectorDNS.Name
 		}
 
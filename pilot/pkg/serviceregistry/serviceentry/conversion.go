@@ -296,8 +296,10 @@ func (s *Controller) convertEndpoint(service *model.Service, servicePort *networ
 			ServiceAccount: sa,
 			// Workload entry config name is used as workload name, which will appear in metric label.
 			// After VM auto registry is introduced, workload group annotation should be used for workload name.
-			WorkloadName: configKey.name,
-			Namespace:    configKey.namespace,
+			WorkloadName:  configKey.name,
+			Namespace:     configKey.namespace,
+			InstanceName:  configKey.name,
+			DataResidency: wle.Labels[constants.DataResidencyLabel],
 		},
 		Service:     service,
 		ServicePort: convertPort(servicePort),
@@ -327,6 +329,8 @@ func (s *Controller) convertServiceEntryToInstances(cfg config.Config, services
 	if services == nil {
 		services = convertServices(cfg)
 	}
+	socketOptions := upstreamSocketOptions(cfg)
+	ttl := endpointTTL(cfg)
 	for _, service := range services {
 		for _, serviceEntryPort := range serviceEntry.Ports {
 			if len(serviceEntry.Endpoints) == 0 && serviceEntry.WorkloadSelector == nil &&
@@ -353,7 +357,10 @@ func (s *Controller) convertServiceEntryToInstances(cfg config.Config, services
 				})
 			} else {
 				for _, endpoint := range serviceEntry.Endpoints {
-					out = append(out, s.convertEndpoint(service, serviceEntryPort, endpoint, &configKey{}, s.clusterID))
+					instance := s.convertEndpoint(service, serviceEntryPort, endpoint, &configKey{}, s.clusterID)
+					instance.Endpoint.SocketOptions = socketOptions
+					instance.Endpoint.TTL = ttl
+					out = append(out, instance)
 				}
 			}
 		}
@@ -397,6 +404,7 @@ func convertWorkloadInstanceToServiceInstance(workloadInstance *model.WorkloadIn
 			ep := workloadInstance.Endpoint.ShallowCopy()
 			ep.ServicePortName = serviceEntryPort.Name
 			ep.EndpointPort = targetPort
+			ep.Labels = workloadInstance.EndpointLabels(serviceEntryPort.Name)
 			ep.ComputeEnvoyEndpoint(nil)
 			out = append(out, &model.ServiceInstance{
 				Endpoint:    ep,
@@ -450,8 +458,14 @@ func (s *Controller) convertWorkloadEntryToWorkloadInstance(cfg config.Config, c
 			Labels:         labels,
 			TLSMode:        tlsMode,
 			ServiceAccount: sa,
+			Annotations:    model.EndpointMetadataAnnotations(cfg.Annotations),
+			InstanceName:   cfg.Name,
+			DataResidency:  we.Labels[constants.DataResidencyLabel],
+			SocketOptions:  upstreamSocketOptions(cfg),
+			TTL:            endpointTTL(cfg),
 		},
 		PortMap:             we.Ports,
+		PortLabels:          workloadEntryPortLabels(cfg),
 		Namespace:           cfg.Namespace,
 		Name:                cfg.Name,
 		Kind:                model.WorkloadEntryKind,
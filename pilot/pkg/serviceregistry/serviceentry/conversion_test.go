@@ -37,6 +37,7 @@ import (
 	"istio.io/istio/pkg/network"
 	"istio.io/istio/pkg/spiffe"
 	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
 )
 
 var (
@@ -1229,6 +1230,86 @@ func TestConvertWorkloadEntryToWorkloadInstance(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "locality override annotation",
+			wle: config.Config{
+				Meta: config.Meta{
+					Namespace:   "ns1",
+					Annotations: map[string]string{constants.LocalityOverride: "region1/zone1"},
+				},
+				Spec: &networking.WorkloadEntry{
+					Address: "1.1.1.1",
+					Labels:  workloadLabel,
+					Ports: map[string]uint32{
+						"http": 80,
+					},
+					ServiceAccount: "scooby",
+				},
+			},
+			out: &model.WorkloadInstance{
+				Namespace: "ns1",
+				Kind:      model.WorkloadEntryKind,
+				Endpoint: &model.IstioEndpoint{
+					Labels: map[string]string{
+						"app":                           "wle",
+						"topology.kubernetes.io/region": "region1",
+						"topology.kubernetes.io/zone":   "zone1",
+						"topology.istio.io/cluster":     clusterID,
+					},
+					Address: "1.1.1.1",
+					Locality: model.Locality{
+						Label:     "region1/zone1",
+						ClusterID: cluster.ID(clusterID),
+					},
+					ServiceAccount: "spiffe://cluster.local/ns/ns1/sa/scooby",
+					TLSMode:        "istio",
+					Namespace:      "ns1",
+				},
+				PortMap: map[string]uint32{
+					"http": 80,
+				},
+			},
+		},
+		{
+			name: "locality override label",
+			wle: config.Config{
+				Meta: config.Meta{
+					Namespace: "ns1",
+					Labels:    map[string]string{constants.LocalityOverride: "region1.zone1.subzone1"},
+				},
+				Spec: &networking.WorkloadEntry{
+					Address: "1.1.1.1",
+					Ports: map[string]uint32{
+						"http": 80,
+					},
+					ServiceAccount: "scooby",
+				},
+			},
+			out: &model.WorkloadInstance{
+				Namespace: "ns1",
+				Kind:      model.WorkloadEntryKind,
+				Endpoint: &model.IstioEndpoint{
+					Labels: map[string]string{
+						constants.LocalityOverride:      "region1.zone1.subzone1",
+						"topology.kubernetes.io/region": "region1",
+						"topology.kubernetes.io/zone":   "zone1",
+						"topology.istio.io/subzone":     "subzone1",
+						"topology.istio.io/cluster":     clusterID,
+					},
+					Address: "1.1.1.1",
+					Locality: model.Locality{
+						Label:     "region1/zone1/subzone1",
+						ClusterID: cluster.ID(clusterID),
+					},
+					ServiceAccount: "spiffe://cluster.local/ns/ns1/sa/scooby",
+					TLSMode:        "istio",
+					Namespace:      "ns1",
+				},
+				PortMap: map[string]uint32{
+					"http": 80,
+				},
+			},
+		},
 		{
 			name: "augment labels: networkID get from cb",
 			wle: config.Config{
@@ -1287,6 +1368,102 @@ func TestConvertWorkloadEntryToWorkloadInstance(t *testing.T) {
 	}
 }
 
+func TestConvertWorkloadEntryPortLabels(t *testing.T) {
+	wle := config.Config{
+		Meta: config.Meta{
+			Name:      "wle",
+			Namespace: "selector",
+			Annotations: map[string]string{
+				constants.WorkloadEntryPortLabelsAnnotation: `{"http-445": {"role": "admin", "app": "wle-admin"}}`,
+			},
+		},
+		Spec: &networking.WorkloadEntry{
+			Address: "1.1.1.1",
+			Labels:  map[string]string{"app": "wle"},
+		},
+	}
+	s := &Controller{}
+	wi := s.convertWorkloadEntryToWorkloadInstance(wle, "")
+	instances := convertWorkloadInstanceToServiceInstance(wi, convertServices(*selector), selector.Spec.(*networking.ServiceEntry))
+	got := map[string]labels.Instance{}
+	for _, instance := range instances {
+		got[instance.Endpoint.ServicePortName] = instance.Endpoint.Labels
+	}
+	assert.Equal(t, got, map[string]labels.Instance{
+		"tcp-444":  {"app": "wle"},
+		"http-445": {"app": "wle-admin", "role": "admin"},
+	})
+	// The workload instance keeps the labels of the workload entry, which are used for selection.
+	assert.Equal(t, wi.Endpoint.Labels, labels.Instance{"app": "wle"})
+
+	wle.Annotations[constants.WorkloadEntryPortLabelsAnnotation] = "invalid"
+	assert.Equal(t, s.convertWorkloadEntryToWorkloadInstance(wle, "").PortLabels, nil)
+}
+
+func TestConvertUpstreamSocketOptions(t *testing.T) {
+	wle := config.Config{
+		Meta: config.Meta{
+			Name:      "wle",
+			Namespace: "selector",
+			Annotations: map[string]string{
+				constants.UpstreamSocketOptionsAnnotation: "keepalive-time=30s,keepalive-probes=3",
+			},
+		},
+		Spec: &networking.WorkloadEntry{
+			Address: "1.1.1.1",
+			Labels:  map[string]string{"app": "wle"},
+		},
+	}
+	want := &model.SocketOptions{KeepaliveTime: 30 * time.Second, KeepaliveProbes: 3}
+	s := &Controller{}
+	wi := s.convertWorkloadEntryToWorkloadInstance(wle, "")
+	for _, instance := range convertWorkloadInstanceToServiceInstance(wi, convertServices(*selector), selector.Spec.(*networking.ServiceEntry)) {
+		assert.Equal(t, instance.Endpoint.SocketOptions, want)
+	}
+
+	se := httpStatic.DeepCopy()
+	se.Annotations = map[string]string{constants.UpstreamSocketOptionsAnnotation: "keepalive-time=30s,keepalive-probes=3"}
+	instances := s.convertServiceEntryToInstances(se, nil)
+	assert.Equal(t, len(instances) > 0, true)
+	for _, instance := range instances {
+		assert.Equal(t, instance.Endpoint.SocketOptions, want)
+	}
+
+	wle.Annotations[constants.UpstreamSocketOptionsAnnotation] = "keepalive-time=0s"
+	assert.Equal(t, s.convertWorkloadEntryToWorkloadInstance(wle, "").Endpoint.SocketOptions, nil)
+}
+
+func TestConvertEndpointTTL(t *testing.T) {
+	wle := config.Config{
+		Meta: config.Meta{
+			Name:        "wle",
+			Namespace:   "selector",
+			Annotations: map[string]string{constants.EndpointTTLAnnotation: "5m"},
+		},
+		Spec: &networking.WorkloadEntry{
+			Address: "1.1.1.1",
+			Labels:  map[string]string{"app": "wle"},
+		},
+	}
+	s := &Controller{}
+	wi := s.convertWorkloadEntryToWorkloadInstance(wle, "")
+	assert.Equal(t, wi.Endpoint.TTL, 5*time.Minute)
+	for _, instance := range convertWorkloadInstanceToServiceInstance(wi, convertServices(*selector), selector.Spec.(*networking.ServiceEntry)) {
+		assert.Equal(t, instance.Endpoint.TTL, 5*time.Minute)
+	}
+
+	se := httpStatic.DeepCopy()
+	se.Annotations = map[string]string{constants.EndpointTTLAnnotation: "30s"}
+	instances := s.convertServiceEntryToInstances(se, nil)
+	assert.Equal(t, len(instances) > 0, true)
+	for _, instance := range instances {
+		assert.Equal(t, instance.Endpoint.TTL, 30*time.Second)
+	}
+
+	wle.Annotations[constants.EndpointTTLAnnotation] = "soon"
+	assert.Equal(t, s.convertWorkloadEntryToWorkloadInstance(wle, "").Endpoint.TTL, 0)
+}
+
 func compare[T any](t testing.TB, actual, expected T) error {
 	return util.Compare(jsonBytes(t, actual), jsonBytes(t, expected))
 }
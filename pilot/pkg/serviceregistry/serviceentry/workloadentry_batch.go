@@ -0,0 +1,116 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serviceentry
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/slices"
+)
+
+// WorkloadEntryFleetLabel is the label of the WorkloadEntries created by a batch, holding the name of their fleet.
+const WorkloadEntryFleetLabel = "networking.istio.io/workload-entry-fleet"
+
+// WorkloadEntryBatchResult summarizes the changes applied by a WorkloadEntry batch.
+type WorkloadEntryBatchResult struct {
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Deleted   int `json:"deleted"`
+	Unchanged int `json:"unchanged"`
+}
+
+// ApplyWorkloadEntryBatch replaces all the workload entries of a fleet in the namespace with entries.
+// The entries are diffed against the WorkloadEntries of the fleet in the config store, and only the
+// entries that were added, changed or removed are written back to it. The resulting events are then
+// handled, and debounced, like any other WorkloadEntry change. An empty batch removes the fleet.
+//
+// The WorkloadEntries of a fleet carry the WorkloadEntryFleetLabel; a batch never modifies an entry of
+// another fleet, or one not created by a batch.
+func (s *Controller) ApplyWorkloadEntryBatch(namespace, fleet string, entries []config.Config) (WorkloadEntryBatchResult, error) {
+	res := WorkloadEntryBatchResult{}
+	if namespace == "" || fleet == "" {
+		return res, fmt.Errorf("a namespace and a fleet are required")
+	}
+	curr := make(map[string]config.Config, len(entries))
+	for _, e := range entries {
+		if e.Name == "" {
+			return res, fmt.Errorf("workload entry in fleet %s/%s has no name", namespace, fleet)
+		}
+		if _, ok := e.Spec.(*networking.WorkloadEntry); !ok {
+			return res, fmt.Errorf("%s is not a workload entry", e.Name)
+		}
+		if _, f := curr[e.Name]; f {
+			return res, fmt.Errorf("duplicate workload entry %s in fleet %s/%s", e.Name, namespace, fleet)
+		}
+		e.Meta.GroupVersionKind = gvk.WorkloadEntry
+		e.Namespace = namespace
+		e.Labels = maps.MergeCopy(e.Labels, map[string]string{WorkloadEntryFleetLabel: fleet})
+		curr[e.Name] = e
+	}
+
+	s.batchMutex.Lock()
+	defer s.batchMutex.Unlock()
+	prev := map[string]config.Config{}
+	for _, e := range s.store.List(gvk.WorkloadEntry, namespace) {
+		if e.Labels[WorkloadEntryFleetLabel] == fleet {
+			prev[e.Name] = e
+		} else if _, f := curr[e.Name]; f {
+			return res, fmt.Errorf("workload entry %s/%s does not belong to fleet %s", namespace, e.Name, fleet)
+		}
+	}
+
+	for _, name := range slices.Sort(maps.Keys(curr)) {
+		e := curr[name]
+		old, f := prev[name]
+		switch {
+		case !f:
+			if _, err := s.store.Create(e); err != nil {
+				return res, fmt.Errorf("failed to create workload entry %s/%s: %v", namespace, name, err)
+			}
+			res.Added++
+		case !batchEntryEqual(old, e):
+			e.ResourceVersion = old.ResourceVersion
+			if _, err := s.store.Update(e); err != nil {
+				return res, fmt.Errorf("failed to update workload entry %s/%s: %v", namespace, name, err)
+			}
+			res.Updated++
+		default:
+			res.Unchanged++
+		}
+	}
+	for _, name := range slices.Sort(maps.Keys(prev)) {
+		if _, f := curr[name]; !f {
+			old := prev[name]
+			if err := s.store.Delete(gvk.WorkloadEntry, name, namespace, &old.ResourceVersion); err != nil {
+				return res, fmt.Errorf("failed to delete workload entry %s/%s: %v", namespace, name, err)
+			}
+			res.Deleted++
+		}
+	}
+	log.Infof("applied workload entry batch %s/%s: %+v", namespace, fleet, res)
+	return res, nil
+}
+
+func batchEntryEqual(a, b config.Config) bool {
+	return maps.Equal(a.Labels, b.Labels) &&
+		maps.Equal(a.Annotations, b.Annotations) &&
+		proto.Equal(a.Spec.(*networking.WorkloadEntry), b.Spec.(*networking.WorkloadEntry))
+}
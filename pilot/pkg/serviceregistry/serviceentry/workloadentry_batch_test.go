@@ -0,0 +1,97 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serviceentry
+
+import (
+	"testing"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestApplyWorkloadEntryBatch(t *testing.T) {
+	store, sd, events := initServiceDiscovery(t)
+	createConfigs([]*config.Config{selector}, store, t)
+	expectEvents(t, events,
+		Event{Type: "service", ID: "selector.com", Namespace: selector.Namespace},
+		Event{Type: "eds cache", ID: "selector.com", Namespace: selector.Namespace},
+		Event{Type: "xds full", ID: "selector.com"})
+
+	entry := func(name, address string, labels map[string]string) config.Config {
+		return config.Config{
+			Meta: config.Meta{Name: name, Labels: labels},
+			Spec: &networking.WorkloadEntry{Address: address, Labels: map[string]string{"app": "wle"}},
+		}
+	}
+	stored := func() []string {
+		return slices.Sort(slices.Map(store.List(gvk.WorkloadEntry, selector.Namespace), func(c config.Config) string {
+			return c.Name + "=" + c.Spec.(*networking.WorkloadEntry).Address
+		}))
+	}
+	instances := func() int {
+		return len(sd.serviceInstances.getByKey(instancesKey{hostname: "selector.com", namespace: selector.Namespace}))
+	}
+
+	res, err := sd.ApplyWorkloadEntryBatch(selector.Namespace, "vms", []config.Config{
+		entry("vm-1", "2.2.2.2", nil),
+		entry("vm-2", "3.3.3.3", nil),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, res, WorkloadEntryBatchResult{Added: 2})
+	assert.Equal(t, stored(), []string{"vm-1=2.2.2.2", "vm-2=3.3.3.3"})
+	assert.Equal(t, store.Get(gvk.WorkloadEntry, "vm-1", selector.Namespace).Labels[WorkloadEntryFleetLabel], "vms")
+	assert.EventuallyEqual(t, instances, 4)
+
+	res, err = sd.ApplyWorkloadEntryBatch(selector.Namespace, "vms", []config.Config{
+		entry("vm-2", "3.3.3.3", nil),
+		entry("vm-1", "2.2.2.2", nil),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, res, WorkloadEntryBatchResult{Unchanged: 2})
+
+	res, err = sd.ApplyWorkloadEntryBatch(selector.Namespace, "vms", []config.Config{
+		entry("vm-1", "2.2.2.2", map[string]string{"version": "v2"}),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, res, WorkloadEntryBatchResult{Updated: 1, Deleted: 1})
+	assert.Equal(t, stored(), []string{"vm-1=2.2.2.2"})
+	assert.Equal(t, store.Get(gvk.WorkloadEntry, "vm-1", selector.Namespace).Labels["version"], "v2")
+	assert.EventuallyEqual(t, instances, 2)
+
+	// Entries of another fleet, or not created by a batch, are never modified.
+	_, err = sd.ApplyWorkloadEntryBatch(selector.Namespace, "other", []config.Config{
+		entry("vm-1", "4.4.4.4", nil),
+	})
+	assert.Error(t, err)
+	res, err = sd.ApplyWorkloadEntryBatch(selector.Namespace, "other", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, res, WorkloadEntryBatchResult{})
+	assert.Equal(t, stored(), []string{"vm-1=2.2.2.2"})
+
+	res, err = sd.ApplyWorkloadEntryBatch(selector.Namespace, "vms", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, res, WorkloadEntryBatchResult{Deleted: 1})
+	assert.Equal(t, len(stored()), 0)
+	assert.EventuallyEqual(t, instances, 0)
+
+	_, err = sd.ApplyWorkloadEntryBatch(selector.Namespace, "vms", []config.Config{
+		entry("vm-1", "2.2.2.2", nil),
+		entry("vm-1", "3.3.3.3", nil),
+	})
+	assert.Error(t, err)
+}
@@ -34,6 +34,7 @@ import (
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
 	labelutil "istio.io/istio/pilot/pkg/serviceregistry/util/label"
+	"istio.io/istio/pilot/pkg/xds/endpoints"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config/schema/kind"
@@ -107,6 +108,9 @@ type Connection struct {
 
 	// errorChan is used to process error during discovery request processing.
 	errorChan chan error
+
+	// wireStats counts the bytes sent on the stream, if the gRPC server records them.
+	wireStats *wireStats
 }
 
 // Event represents a config or registry event that results in a push.
@@ -128,6 +132,7 @@ func newConnection(peerAddr string, stream DiscoveryStream) *Connection {
 		peerAddr:    peerAddr,
 		connectedAt: time.Now(),
 		stream:      stream,
+		wireStats:   wireStatsFromContext(stream.Context()),
 	}
 }
 
@@ -363,6 +368,9 @@ func (s *DiscoveryServer) shouldRespond(con *Connection, request *discovery.Disc
 		errCode := codes.Code(request.ErrorDetail.Code)
 		log.Warnf("ADS:%s: ACK ERROR %s %s:%s", stype, con.conID, errCode.String(), request.ErrorDetail.GetMessage())
 		incrementXDSRejects(request.TypeUrl, con.proxy.ID, errCode.String())
+		if request.TypeUrl == v3.EndpointType {
+			s.edsMeshCanary.recordReject(con.proxy)
+		}
 		if s.StatusGen != nil {
 			s.StatusGen.OnNack(con.proxy, request)
 		}
@@ -567,6 +575,8 @@ func (s *DiscoveryServer) closeConnection(con *Connection) {
 		return
 	}
 	s.removeCon(con.conID)
+	s.edsPrecomputer.forgetProxy(con.proxy)
+	endpoints.ForgetMtlsExcludedProxy(con.proxy.ID)
 	if s.StatusGen != nil {
 		s.StatusGen.OnDisconnect(con)
 	}
@@ -606,7 +616,16 @@ func setTopologyLabels(proxy *model.Proxy) {
 	// locality information, as they can read from various sources (Node on Kubernetes, for example). They will take this
 	// information and add it to the labels. So while the proxy may not originally have these labels,
 	// it will by the time we get here (as a result of calling this after SetWorkloadLabels).
-	proxy.Locality = localityFromProxyLabels(proxy)
+	asserted, override := proxy.AssertedLocality()
+	if override {
+		// The proxy is trusted to know its own locality better than the registry, for example on edge nodes.
+		proxy.Locality = util.ConvertLocality(asserted)
+	} else {
+		proxy.Locality = localityFromProxyLabels(proxy)
+	}
+	if proxy.Locality == nil && asserted != "" {
+		proxy.Locality = util.ConvertLocality(asserted)
+	}
 	if proxy.Locality == nil {
 		// If there is no locality in the registry then use the one sent as part of the discovery request.
 		// This is not preferable as only the connected Pilot is aware of this proxies location, but it
@@ -678,12 +697,19 @@ func (s *DiscoveryServer) computeProxyState(proxy *model.Proxy, request *model.P
 	if recomputeLabels {
 		proxy.SetWorkloadLabels(s.Env)
 		setTopologyLabels(proxy)
+		// The locality asserted by the proxy also applies to the endpoints of its workload.
+		if r, ok := s.Env.ServiceDiscovery.(model.ProxyLocalityRecorder); ok {
+			asserted, override := proxy.AssertedLocality()
+			r.RecordProxyLocality(proxy, asserted, override)
+		}
 	}
 	// Precompute the sidecar scope and merged gateways associated with this proxy.
 	// Saves compute cycles in networking code. Though this might be redundant sometimes, we still
 	// have to compute this because as part of a config change, a new Sidecar could become
 	// applicable to this proxy
 	var sidecar, gateway bool
+	// The endpoint metadata requested by Telemetries depends on the workload labels.
+	telemetry := recomputeLabels
 	push := proxy.LastPushContext
 	if request == nil {
 		sidecar = true
@@ -693,6 +719,7 @@ func (s *DiscoveryServer) computeProxyState(proxy *model.Proxy, request *model.P
 		if len(request.ConfigsUpdated) == 0 {
 			sidecar = true
 			gateway = true
+			telemetry = true
 		}
 		for conf := range request.ConfigsUpdated {
 			switch conf.Kind {
@@ -703,8 +730,10 @@ func (s *DiscoveryServer) computeProxyState(proxy *model.Proxy, request *model.P
 			case kind.Ingress:
 				sidecar = true
 				gateway = true
+			case kind.Telemetry:
+				telemetry = true
 			}
-			if sidecar && gateway {
+			if sidecar && gateway && telemetry {
 				break
 			}
 		}
@@ -713,6 +742,11 @@ func (s *DiscoveryServer) computeProxyState(proxy *model.Proxy, request *model.P
 	if sidecar {
 		proxy.SetSidecarScope(push)
 	}
+	if telemetry {
+		proxy.SetTelemetryEndpointMetadataKeys(push)
+	}
+	// The meshConfig settings changing the endpoints may be rolled out to a part of the proxies only.
+	proxy.EndpointMeshSettings, _ = s.edsMeshCanary.settingsFor(proxy)
 	// only compute gateways for "router" type proxy.
 	if gateway && proxy.Type == model.Router {
 		proxy.SetGatewaysForProxy(push)
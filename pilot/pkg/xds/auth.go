@@ -43,6 +43,9 @@ func (s *DiscoveryServer) authorize(con *Connection, identities []string) error
 	if con == nil || con.proxy == nil {
 		return nil
 	}
+	if con.proxy.Metadata.Generator == ReadOnlyGenerator {
+		return authorizeReadOnly(con.proxy, identities)
+	}
 
 	if features.EnableXDSIdentityCheck && identities != nil {
 		// TODO: allow locking down, rejecting unauthenticated requests.
@@ -0,0 +1,82 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pilot/test/xdstest"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestClusterSetServiceResolution(t *testing.T) {
+	test.SetForTest(t, &features.EnableClusterSetServiceResolution, true)
+	// The Service only exists in a remote cluster, and no cluster imports its ClusterSet host.
+	s := NewFakeDiscoveryServer(t, FakeOptions{
+		Services: []*model.Service{{
+			Hostname:   "example.ns.svc.cluster.local",
+			Attributes: model.ServiceAttributes{Name: "example", Namespace: "ns"},
+			Ports:      model.PortList{{Port: 80, Protocol: protocol.HTTP, Name: "http"}},
+		}},
+	})
+	s.Discovery.EDSUpdate(model.ShardKey{Cluster: "remote", Provider: provider.Kubernetes}, "example.ns.svc.clusterset.local", "ns",
+		[]*model.IstioEndpoint{{
+			Address: "10.0.0.1", EndpointPort: 8080, ServicePortName: "http", Namespace: "ns",
+			Locality: model.Locality{ClusterID: "remote"}, HealthStatus: model.Healthy,
+		}})
+	s.EnsureSynced(t)
+	cn := "outbound|80||example.ns.svc.clusterset.local"
+	clusters := func() []string {
+		return xdstest.MapKeys(xdstest.ExtractClusters(s.Clusters(s.SetupProxy(nil))))
+	}
+	addresses := func() []string {
+		var out []string
+		for _, cla := range s.Endpoints(s.SetupProxy(nil)) {
+			if cla.ClusterName != cn {
+				continue
+			}
+			for _, llb := range cla.Endpoints {
+				for _, ep := range llb.LbEndpoints {
+					out = append(out, ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress())
+				}
+			}
+		}
+		return out
+	}
+
+	// Services which are not exported are not exposed under their ClusterSet host.
+	assert.Equal(t, slices.Contains(clusters(), cn), false)
+	assert.Equal(t, addresses(), nil)
+
+	s.MemRegistry.WantMCSServices = []model.MCSServiceInfo{{Cluster: "remote", Name: "example", Namespace: "ns", Exported: true}}
+	s.Discovery.ConfigUpdate(&model.PushRequest{Full: true})
+	s.EnsureSynced(t)
+	assert.Equal(t, slices.Contains(clusters(), cn), true)
+	assert.Equal(t, addresses(), []string{"10.0.0.1"})
+	svc := s.PushContext().ServiceForHostname(s.SetupProxy(nil), "example.ns.svc.clusterset.local")
+	assert.Equal(t, svc.Attributes.ClusterSetOrigin, host.Name("example.ns.svc.cluster.local"))
+	assert.Equal(t, svc.DefaultAddress, "0.0.0.0")
+	// The clusters are invalidated by changes to the Kubernetes Service as well as to the ClusterSet host.
+	b := s.Discovery.Generators[v3.EndpointType].(*EdsGenerator).newEndpointBuilder(cn, s.SetupProxy(nil), s.PushContext())
+	assert.Equal(t, len(b.DependentConfigs()), 2)
+}
@@ -24,6 +24,7 @@ import (
 	"net/netip"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -36,6 +37,8 @@ import (
 	"google.golang.org/protobuf/proto"
 	anypb "google.golang.org/protobuf/types/known/anypb"
 
+	networking "istio.io/api/networking/v1alpha3"
+
 	"istio.io/istio/pilot/pkg/config/kube/crd"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
@@ -43,11 +46,15 @@ import (
 	"istio.io/istio/pilot/pkg/util/protoconv"
 	"istio.io/istio/pilot/pkg/xds/endpoints"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/schema/resource"
 	"istio.io/istio/pkg/config/xds"
 	istiolog "istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/network"
 	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/spiffe"
 	"istio.io/istio/pkg/util/protomarshal"
 	"istio.io/istio/pkg/util/sets"
 )
@@ -173,10 +180,34 @@ func (s *DiscoveryServer) AddDebugHandlers(mux, internalMux *http.ServeMux, enab
 
 	if features.EnableUnsafeAdminEndpoints {
 		s.addDebugHandler(mux, internalMux, "/debug/force_disconnect", "Disconnects a proxy from this Pilot", s.forceDisconnect)
+		// Only the identities of the namespace of the service can pause its pushes.
+		s.addNamespaceDebugHandler(mux, internalMux, "/debug/eds_pause", "Lists, pauses or resumes EDS pushes for a service", s.edsPausez)
+		s.addDebugHandler(mux, internalMux, "/debug/eds_mesh_canary",
+			"Shows, changes the percentage of or promotes the rollout of meshConfig changes affecting the endpoints", s.edsMeshCanaryz)
+		s.addDebugHandler(mux, internalMux, "/debug/memz",
+			"Shows the estimated memory used by the subsystems of istiod, or changes the GC percentage, memory limit or ballast",
+			s.memoryz)
+	}
+
+	if features.EnableWorkloadEntryBatchAPI {
+		// Writes are only allowed to identities of the namespace of the batch.
+		s.addNamespaceDebugHandler(mux, internalMux, "/debug/workloadentry_batch",
+			"Replaces all the WorkloadEntries of the fleet and namespace in the query string with the posted ones", s.workloadEntryBatchz)
 	}
 
 	s.addDebugHandler(mux, internalMux, "/debug/ecdsz", "Status and debug interface for ECDS", s.ecdsz)
 	s.addDebugHandler(mux, internalMux, "/debug/edsz", "Status and debug interface for EDS", s.Edsz)
+	s.addDebugHandler(mux, internalMux, "/debug/edsz?proxy=<pod>&cluster=<name>",
+		"Builds the ClusterLoadAssignment of a cluster for a proxy, with the trace of the filtered endpoints, the merged traffic policy "+
+			"and the number of endpoints of each locality",
+		s.Edsz)
+	s.addDebugHandler(mux, internalMux, "/debug/edsz?proxyID=<pod>&canonical&ip=<address>",
+		"Canonical ClusterLoadAssignments of the services visible to a proxy, for comparison across istiod revisions. "+
+			"The ip, cluster and network parameters describe a proxy connected to another istiod", s.Edsz)
+	s.addDebugHandler(mux, internalMux, "/debug/mtlsz?service=<hostname>&port=<port>&subset=<subset>&proxy=<pod>",
+		"Explains the TLS mode computed for each endpoint of a service, from the DestinationRule and PeerAuthentications", s.Mtlsz)
+	s.addDebugHandler(mux, internalMux, "/debug/mtls_excluded",
+		"SNI-DNAT clusters with endpoints excluded because they do not use mTLS", s.mtlsExcludedz)
 	s.addDebugHandler(mux, internalMux, "/debug/ndsz", "Status and debug interface for NDS", s.ndsz)
 	s.addDebugHandler(mux, internalMux, "/debug/adsz", "Status and debug interface for ADS", s.adsz)
 	s.addDebugHandler(mux, internalMux, "/debug/adsz?push=true", "Initiates push of the current state to all connected endpoints", s.adsz)
@@ -187,11 +218,24 @@ func (s *DiscoveryServer) AddDebugHandlers(mux, internalMux *http.ServeMux, enab
 	s.addDebugHandler(mux, internalMux, "/debug/registryz", "Debug support for registry", s.registryz)
 	s.addDebugHandler(mux, internalMux, "/debug/endpointz", "Obsolete, use endpointShardz", s.endpointShardz)
 	s.addDebugHandler(mux, internalMux, "/debug/endpointShardz", "Info about the endpoint shards", s.endpointShardz)
+	s.addDebugHandler(mux, internalMux, "/debug/endpointShardz?namespace=<ns>", "Info about the endpoint shards in a namespace", s.endpointShardz)
+	s.addDebugHandler(mux, internalMux, "/debug/endpoint_quarantine", "Endpoints rejected because of an invalid address", s.endpointQuarantinez)
+	// endpoint_references walks the endpoint index and the clusters of all the proxies, so it is only served on the
+	// internal mux, used by istioctl through debug over XDS, which is authenticated by the XDS server.
+	s.debugHandlers["/debug/endpoint_references"] =
+		"Services and clusters of the connected proxies including the endpoint of the ip parameter, with its health and weight"
+	if internalMux != nil {
+		internalMux.HandleFunc("/debug/endpoint_references", s.endpointReferencez)
+	}
 	s.addDebugHandler(mux, internalMux, "/debug/cachez", "Info about the internal XDS caches", s.cachez)
 	s.addDebugHandler(mux, internalMux, "/debug/cachez?sizes=true", "Info about the size of the internal XDS caches", s.cachez)
 	s.addDebugHandler(mux, internalMux, "/debug/cachez?clear=true", "Clear the XDS caches", s.cachez)
+	s.addDebugHandler(mux, internalMux, "/debug/cachez?stats=eds",
+		"Hit rate and invalidations by config of an XDS cache: cds, eds, rds or sds", s.cachez)
 	s.addDebugHandler(mux, internalMux, "/debug/configz", "Debug support for config", s.configz)
 	s.addDebugHandler(mux, internalMux, "/debug/sidecarz", "Debug sidecar scope for a proxy", s.sidecarz)
+	s.addDebugHandler(mux, internalMux, "/debug/sidecar_suggestions",
+		"Suggested Sidecar egress hosts per namespace, from the clusters the sidecars send requests to", s.sidecarSuggestionsz)
 	s.addDebugHandler(mux, internalMux, "/debug/resourcesz", "Debug support for watched resources", s.resourcez)
 	s.addDebugHandler(mux, internalMux, "/debug/instancesz", "Debug support for service instances", s.instancesz)
 
@@ -201,6 +245,8 @@ func (s *DiscoveryServer) AddDebugHandlers(mux, internalMux *http.ServeMux, enab
 	s.addDebugHandler(mux, internalMux, "/debug/push_status", "Last PushContext Details", s.pushStatusHandler)
 	s.addDebugHandler(mux, internalMux, "/debug/pushcontext", "Debug support for current push context", s.pushContextHandler)
 	s.addDebugHandler(mux, internalMux, "/debug/connections", "Info about the connected XDS clients", s.connectionsHandler)
+	s.addDebugHandler(mux, internalMux, "/debug/wire_bytes", "Connected XDS clients which were sent the most bytes, per type", s.wireBytesz)
+	s.addDebugHandler(mux, internalMux, "/debug/wire_bytes?top=<n>", "Top n connected XDS clients which were sent the most bytes", s.wireBytesz)
 
 	s.addDebugHandler(mux, internalMux, "/debug/inject", "Active inject template", s.injectTemplateHandler(webhook))
 	s.addDebugHandler(mux, internalMux, "/debug/mesh", "Active mesh config", s.meshHandler)
@@ -223,6 +269,17 @@ func (s *DiscoveryServer) addDebugHandler(mux *http.ServeMux, internalMux *http.
 	mux.HandleFunc(path, s.allowAuthenticatedOrLocalhost(http.HandlerFunc(handler)))
 }
 
+// addNamespaceDebugHandler adds a handler only allowed to the identities of the namespace in the query string.
+func (s *DiscoveryServer) addNamespaceDebugHandler(mux *http.ServeMux, internalMux *http.ServeMux,
+	path string, help string, handler func(http.ResponseWriter, *http.Request),
+) {
+	s.debugHandlers[path] = help
+	if internalMux != nil {
+		internalMux.HandleFunc(path, handler)
+	}
+	mux.HandleFunc(path, s.allowNamespaceOrLocalhost(http.HandlerFunc(handler)))
+}
+
 func (s *DiscoveryServer) allowAuthenticatedOrLocalhost(next http.Handler) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		// Request is from localhost, no need to authenticate
@@ -230,21 +287,8 @@ func (s *DiscoveryServer) allowAuthenticatedOrLocalhost(next http.Handler) http.
 			next.ServeHTTP(w, req)
 			return
 		}
-		// Authenticate request with the same method as XDS
-		authFailMsgs := make([]string, 0)
-		var ids []string
-		authRequest := security.AuthContext{Request: req}
-		for _, authn := range s.Authenticators {
-			u, err := authn.Authenticate(authRequest)
-			// If one authenticator passes, return
-			if u != nil && u.Identities != nil && err == nil {
-				ids = u.Identities
-				break
-			}
-			authFailMsgs = append(authFailMsgs, fmt.Sprintf("Authenticator %s: %v", authn.AuthenticatorType(), err))
-		}
+		ids := s.authenticateDebugRequest(req)
 		if ids == nil {
-			istiolog.Errorf("Failed to authenticate %s %v", req.URL, authFailMsgs)
 			// Not including detailed info in the response, XDS doesn't either (returns a generic "authentication failure).
 			w.WriteHeader(http.StatusUnauthorized)
 			return
@@ -255,6 +299,47 @@ func (s *DiscoveryServer) allowAuthenticatedOrLocalhost(next http.Handler) http.
 	}
 }
 
+// allowNamespaceOrLocalhost only allows requests from localhost, or authenticated with an identity of the
+// namespace in the query string.
+func (s *DiscoveryServer) allowNamespaceOrLocalhost(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if isRequestFromLocalhost(req) {
+			next.ServeHTTP(w, req)
+			return
+		}
+		ids := s.authenticateDebugRequest(req)
+		if ids == nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		namespace := req.URL.Query().Get("namespace")
+		for _, id := range ids {
+			if identity, err := spiffe.ParseIdentity(id); err == nil && identity.Namespace == namespace {
+				next.ServeHTTP(w, req)
+				return
+			}
+		}
+		istiolog.Warnf("Denied %s to %v: not an identity of namespace %q", req.URL.Path, ids, namespace)
+		w.WriteHeader(http.StatusForbidden)
+	}
+}
+
+// authenticateDebugRequest authenticates req with the same method as XDS, returning nil if it fails.
+func (s *DiscoveryServer) authenticateDebugRequest(req *http.Request) []string {
+	authFailMsgs := make([]string, 0)
+	authRequest := security.AuthContext{Request: req}
+	for _, authn := range s.Authenticators {
+		u, err := authn.Authenticate(authRequest)
+		// If one authenticator passes, return
+		if u != nil && u.Identities != nil && err == nil {
+			return u.Identities
+		}
+		authFailMsgs = append(authFailMsgs, fmt.Sprintf("Authenticator %s: %v", authn.AuthenticatorType(), err))
+	}
+	istiolog.Errorf("Failed to authenticate %s %v", req.URL, authFailMsgs)
+	return nil
+}
+
 func isRequestFromLocalhost(r *http.Request) bool {
 	ip, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
@@ -305,7 +390,61 @@ func (s *DiscoveryServer) registryz(w http.ResponseWriter, req *http.Request) {
 // Legacy registry provides are synced to the new data structure as well, during
 // the full push.
 func (s *DiscoveryServer) endpointShardz(w http.ResponseWriter, req *http.Request) {
-	writeJSON(w, s.Env.EndpointIndex.Shardz(), req)
+	writeJSON(w, namespaceShardz(s.Env.EndpointIndex, req.URL.Query().Get("namespace")), req)
+}
+
+// endpointQuarantinez lists the endpoints that were rejected from the endpoint index because of an invalid address.
+func (s *DiscoveryServer) endpointQuarantinez(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, s.Env.EndpointIndex.Quarantined(), req)
+}
+
+// EndpointReferences lists the services and the clusters of the connected proxies including an endpoint.
+type EndpointReferences struct {
+	Address  string                               `json:"address"`
+	Services []endpoints.ServiceEndpointReference `json:"services"`
+	Clusters []endpoints.ClusterEndpointReference `json:"clusters"`
+}
+
+// endpointReferencez lists the services and the clusters of the connected proxies including the endpoint with the
+// address, as found in the endpoint index and in the ClusterLoadAssignments built for the proxies.
+func (s *DiscoveryServer) endpointReferencez(w http.ResponseWriter, req *http.Request) {
+	address := req.URL.Query().Get("ip")
+	if address == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("You must provide an ip parameter\n"))
+		return
+	}
+	eds, ok := s.Generators[v3.EndpointType].(*EdsGenerator)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("EDS generator not found\n"))
+		return
+	}
+	inspector := endpoints.NewInspector(s.Env.EndpointIndex, address)
+	for _, con := range s.Clients() {
+		for _, clusterName := range con.Clusters() {
+			builder := eds.newEndpointBuilder(clusterName, con.proxy, con.proxy.LastPushContext)
+			if !builder.ServiceFound() {
+				continue
+			}
+			// Only the clusters of the services including the endpoint are read, preferably from the cache as
+			// building all of them is expensive.
+			svc := builder.Service()
+			if !inspector.IncludesService(string(svc.Hostname), svc.Attributes.Namespace) {
+				continue
+			}
+			inspector.AddClusterLoadAssignment(con.proxy.ID, eds.clusterLoadAssignment(&builder))
+		}
+	}
+	writeJSON(w, EndpointReferences{
+		Address:  address,
+		Services: inspector.Services(),
+		Clusters: inspector.Clusters(),
+	}, req)
+}
+
+func (s *DiscoveryServer) mtlsExcludedz(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, endpoints.MtlsExcluded(), req)
 }
 
 func (s *DiscoveryServer) cachez(w http.ResponseWriter, req *http.Request) {
@@ -319,6 +458,10 @@ func (s *DiscoveryServer) cachez(w http.ResponseWriter, req *http.Request) {
 		_, _ = w.Write([]byte("Cache cleared\n"))
 		return
 	}
+	if t := req.Form.Get("stats"); t != "" {
+		writeJSON(w, s.Cache.Stats(t), req)
+		return
+	}
 	if req.Form.Get("sizes") != "" {
 		snapshot := s.Cache.Snapshot()
 		raw := make(map[string]int, len(snapshot))
@@ -991,28 +1134,255 @@ func (s *DiscoveryServer) ndsz(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// EDSSimulation is the ClusterLoadAssignment a proxy would receive for a cluster, with the trace of the
+// endpoints dropped while building it.
+type EDSSimulation struct {
+	Proxy                 string                 `json:"proxy"`
+	Cluster               string                 `json:"cluster"`
+	Paused                bool                   `json:"paused,omitempty"`
+	ClusterLoadAssignment jsonMarshalProto       `json:"clusterLoadAssignment"`
+	Trace                 *endpoints.FilterTrace `json:"trace"`
+	// TrafficPolicy is the destination rule policy merged for the port and subset of the cluster.
+	TrafficPolicy *jsonMarshalProto `json:"trafficPolicy,omitempty"`
+	// LocalityLbSetting is the locality load balancing setting in effect, from the policy or the mesh default.
+	LocalityLbSetting *jsonMarshalProto `json:"localityLbSetting,omitempty"`
+	// Failover is true if the endpoints fail over across localities, which requires outlier detection.
+	Failover bool `json:"failover,omitempty"`
+	// Localities are the number of endpoints of each locality of the ClusterLoadAssignment.
+	Localities []endpoints.LocalityEndpointCount `json:"localities,omitempty"`
+}
+
 // Edsz implements a status and debug interface for EDS.
 // It is mapped to /debug/edsz on the monitor port (15014).
+// With the `proxy` and `cluster` query parameters, it builds the ClusterLoadAssignment of the cluster for
+// the proxy, whether or not the proxy watches it, and returns it with the trace of the dropped endpoints.
 func (s *DiscoveryServer) Edsz(w http.ResponseWriter, req *http.Request) {
 	if s.handlePushRequest(w, req) {
 		return
 	}
+	if clusterName := req.URL.Query().Get("cluster"); clusterName != "" {
+		s.simulateEds(w, req, clusterName)
+		return
+	}
 
 	proxyID, con := s.getDebugConnection(req)
+	// In canonical mode, a proxy connected to another istiod, such as one of another revision, is rebuilt
+	// from its address so the endpoints sent by both can be compared.
+	canonical := req.URL.Query().Has("canonical")
+	if con == nil && canonical && proxyID != "" && req.URL.Query().Get("ip") != "" {
+		var err error
+		if con, err = s.debugProxyConnection(proxyID, req.URL.Query().Get("ip"), req.URL.Query().Get("cluster"),
+			req.URL.Query().Get("network")); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(w, "%v\n", err)
+			return
+		}
+	}
 	if con == nil {
 		s.errorHandler(w, proxyID, con)
 		return
 	}
 
 	clusters := con.Clusters()
+	if canonical {
+		// The clusters watched depend on the connection, so the clusters visible to the proxy are used instead.
+		clusters = edsClusters(con.proxy)
+	}
 	eps := make([]jsonMarshalProto, 0, len(clusters))
 	for _, clusterName := range clusters {
 		builder := endpoints.NewEndpointBuilder(clusterName, con.proxy, con.proxy.LastPushContext)
-		eps = append(eps, jsonMarshalProto{builder.BuildClusterLoadAssignment(s.Env.EndpointIndex)})
+		cla := builder.BuildClusterLoadAssignment(s.Env.EndpointIndex)
+		if canonical {
+			cla = endpoints.CanonicalizeClusterLoadAssignment(cla)
+		}
+		eps = append(eps, jsonMarshalProto{cla})
 	}
 	writeJSON(w, eps, req)
 }
 
+// debugProxyConnection builds a connection for a sidecar that is not connected to this istiod, from its
+// <pod>.<namespace> ID and address. Its labels, locality and services are looked up in the registries,
+// as they would be if it connected.
+func (s *DiscoveryServer) debugProxyConnection(proxyID, ip, clusterID, nw string) (*Connection, error) {
+	_, ns, _ := strings.Cut(proxyID, ".")
+	if ns == "" {
+		return nil, fmt.Errorf("proxy %q is not of the form <pod>.<namespace>", proxyID)
+	}
+	nodeID := strings.Join([]string{string(model.SidecarProxy), ip, proxyID, ns + ".svc." + s.Env.DomainSuffix}, "~")
+	proxy, err := model.ParseServiceNodeWithMetadata(nodeID, &model.NodeMetadata{
+		Namespace: ns,
+		ClusterID: cluster.ID(clusterID),
+		Network:   network.ID(nw),
+	})
+	if err != nil {
+		return nil, err
+	}
+	proxy.ConfigNamespace = model.GetProxyConfigNamespace(proxy)
+	proxy.XdsNode = &core.Node{Id: nodeID}
+	proxy.LastPushContext = s.globalPushContext()
+	s.computeProxyState(proxy, nil)
+	proxy.DiscoverIPMode()
+	return &Connection{proxy: proxy}, nil
+}
+
+// edsClusters returns the names of the EDS clusters of the services visible to the proxy, including the
+// clusters of their subsets, sorted.
+func edsClusters(proxy *model.Proxy) []string {
+	if proxy.SidecarScope == nil {
+		return nil
+	}
+	var out []string
+	for _, svc := range proxy.SidecarScope.Services() {
+		if svc.Resolution != model.ClientSideLB {
+			continue
+		}
+		var subsets []string
+		if dr := proxy.SidecarScope.DestinationRule(model.TrafficDirectionOutbound, proxy, svc.Hostname).GetRule(); dr != nil {
+			for _, subset := range dr.Spec.(*networking.DestinationRule).GetSubsets() {
+				subsets = append(subsets, subset.GetName())
+			}
+		}
+		for _, port := range svc.Ports {
+			out = append(out, model.BuildSubsetKey(model.TrafficDirectionOutbound, "", svc.Hostname, port.Port))
+			for _, subset := range subsets {
+				out = append(out, model.BuildSubsetKey(model.TrafficDirectionOutbound, subset, svc.Hostname, port.Port))
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (s *DiscoveryServer) simulateEds(w http.ResponseWriter, req *http.Request, clusterName string) {
+	proxyID := req.URL.Query().Get("proxy")
+	if proxyID == "" {
+		proxyID = req.URL.Query().Get("proxyID")
+	}
+	var con *Connection
+	if proxyID != "" {
+		con = s.getProxyConnection(proxyID)
+	}
+	if con == nil {
+		s.errorHandler(w, proxyID, con)
+		return
+	}
+
+	builder := endpoints.NewEndpointBuilder(clusterName, con.proxy, con.proxy.LastPushContext)
+	if !builder.ServiceFound() {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = fmt.Fprintf(w, "Cluster %s does not match any service visible to proxy %s\n", clusterName, con.proxy.ID)
+		return
+	}
+	endpointIndex, paused := s.edsPauses.endpointIndexFor(builder.Service(), s.Env.EndpointIndex)
+	cla, trace := builder.BuildClusterLoadAssignmentWithTrace(endpointIndex)
+	simulation := EDSSimulation{
+		Proxy:                 con.proxy.ID,
+		Cluster:               clusterName,
+		Paused:                paused,
+		ClusterLoadAssignment: jsonMarshalProto{cla},
+		Trace:                 trace,
+		Localities:            endpoints.LocalityEndpointCounts(cla),
+	}
+	policy, lbSetting, failover := builder.TrafficPolicy()
+	if policy != nil {
+		simulation.TrafficPolicy = &jsonMarshalProto{policy}
+	}
+	if lbSetting != nil {
+		simulation.LocalityLbSetting = &jsonMarshalProto{lbSetting}
+	}
+	simulation.Failover = failover && lbSetting != nil
+	writeJSON(w, simulation, req)
+}
+
+// MtlsDecisions explains the TLS mode computed for the endpoints of a cluster.
+type MtlsDecisions struct {
+	Proxy   string `json:"proxy,omitempty"`
+	Cluster string `json:"cluster"`
+	// DestinationRules are the DestinationRules merged for the service, whose TLS settings take precedence.
+	DestinationRules []string                 `json:"destinationRules,omitempty"`
+	Endpoints        []endpoints.MtlsDecision `json:"endpoints"`
+}
+
+// Mtlsz explains the TLS mode computed for each endpoint of the clusters of a service, as auto mTLS misdetection is a
+// frequent source of 503s. The DestinationRules are the ones visible to the proxy, if set, or to the namespace of
+// the service otherwise. Without a port, all the ports of the service are explained.
+func (s *DiscoveryServer) Mtlsz(w http.ResponseWriter, req *http.Request) {
+	hostname := req.URL.Query().Get("service")
+	if hostname == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("You must provide a service\n"))
+		return
+	}
+	var port int
+	if p := req.URL.Query().Get("port"); p != "" {
+		var err error
+		if port, err = strconv.Atoi(p); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(w, "Invalid port %q\n", p)
+			return
+		}
+	}
+	subset := req.URL.Query().Get("subset")
+
+	var proxy *model.Proxy
+	if proxyID := req.URL.Query().Get("proxy"); proxyID != "" {
+		con := s.getProxyConnection(proxyID)
+		if con == nil {
+			s.errorHandler(w, proxyID, con)
+			return
+		}
+		proxy = con.proxy
+	} else {
+		push := s.globalPushContext()
+		var svc *model.Service
+		for _, sv := range push.GetAllServices() {
+			if string(sv.Hostname) == hostname {
+				svc = sv
+				break
+			}
+		}
+		if svc == nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprintf(w, "Service %s not found\n", hostname)
+			return
+		}
+		proxy = &model.Proxy{
+			Type:            model.SidecarProxy,
+			ConfigNamespace: svc.Attributes.Namespace,
+			Metadata:        &model.NodeMetadata{},
+			SidecarScope:    model.DefaultSidecarScopeForNamespace(push, svc.Attributes.Namespace),
+			LastPushContext: push,
+		}
+	}
+
+	push := proxy.LastPushContext
+	svc := push.ServiceForHostname(proxy, host.Name(hostname))
+	if svc == nil {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = fmt.Fprintf(w, "Service %s not found\n", hostname)
+		return
+	}
+	var drs []string
+	for _, dr := range proxy.SidecarScope.DestinationRule(model.TrafficDirectionOutbound, proxy, svc.Hostname).GetFrom() {
+		drs = append(drs, dr.String())
+	}
+	out := []MtlsDecisions{}
+	for _, p := range svc.Ports {
+		if port != 0 && p.Port != port {
+			continue
+		}
+		clusterName := model.BuildSubsetKey(model.TrafficDirectionOutbound, subset, svc.Hostname, p.Port)
+		builder := endpoints.NewEndpointBuilder(clusterName, proxy, push)
+		out = append(out, MtlsDecisions{
+			Proxy:            proxy.ID,
+			Cluster:          clusterName,
+			DestinationRules: drs,
+			Endpoints:        builder.MtlsDecisions(s.Env.EndpointIndex),
+		})
+	}
+	writeJSON(w, out, req)
+}
+
 func (s *DiscoveryServer) forceDisconnect(w http.ResponseWriter, req *http.Request) {
 	proxyID, con := s.getDebugConnection(req)
 	if con == nil {
@@ -19,14 +19,19 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 
 	"istio.io/istio/istioctl/pkg/util/configdump"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/xds"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/pkg/test"
 )
 
 func TestSyncz(t *testing.T) {
@@ -232,3 +237,70 @@ func TestDebugHandlers(t *testing.T) {
 		t.Errorf("Error in generatating debug endpoint list")
 	}
 }
+
+// namespaceAuthenticator authenticates every HTTP request as the default service account of a namespace.
+type namespaceAuthenticator string
+
+func (a namespaceAuthenticator) Authenticate(security.AuthContext) (*security.Caller, error) {
+	id := spiffe.Identity{TrustDomain: "cluster.local", Namespace: string(a), ServiceAccount: "default"}
+	return &security.Caller{AuthSource: security.AuthSourceIDToken, Identities: []string{id.String()}}, nil
+}
+
+func (a namespaceAuthenticator) AuthenticatorType() string {
+	return "namespace"
+}
+
+func TestWorkloadEntryBatchAuthorization(t *testing.T) {
+	test.SetForTest(t, &features.EnableWorkloadEntryBatchAPI, true)
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{})
+	s.Discovery.Authenticators = []security.Authenticator{namespaceAuthenticator("vms")}
+	mux := http.NewServeMux()
+	s.Discovery.AddDebugHandlers(mux, nil, false, nil)
+
+	post := func(namespace, remote string) int {
+		req := httptest.NewRequest(http.MethodPost, "/debug/workloadentry_batch?fleet=fleet&namespace="+namespace, strings.NewReader("[]"))
+		req.RemoteAddr = remote
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	// The fake server has no WorkloadEntry registry, so authorized batches are rejected as unsupported.
+	if got := post("vms", "10.0.0.1:1234"); got != http.StatusServiceUnavailable {
+		t.Errorf("batch of the caller's namespace: got %v", got)
+	}
+	if got := post("other", "10.0.0.1:1234"); got != http.StatusForbidden {
+		t.Errorf("batch of another namespace: got %v", got)
+	}
+	if got := post("other", "127.0.0.1:1234"); got != http.StatusServiceUnavailable {
+		t.Errorf("batch from localhost: got %v", got)
+	}
+}
+
+func TestEdsPauseAuthorization(t *testing.T) {
+	test.SetForTest(t, &features.EnableUnsafeAdminEndpoints, true)
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{})
+	s.Discovery.Authenticators = []security.Authenticator{namespaceAuthenticator("ns")}
+	mux := http.NewServeMux()
+	s.Discovery.AddDebugHandlers(mux, nil, false, nil)
+
+	call := func(method, namespace, remote string) int {
+		req := httptest.NewRequest(method, "/debug/eds_pause?action=resume&hostname=a.ns.svc.cluster.local&namespace="+namespace, nil)
+		req.RemoteAddr = remote
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	// The service is not paused, so authorized requests are rejected as not found.
+	if got := call(http.MethodPost, "ns", "10.0.0.1:1234"); got != http.StatusNotFound {
+		t.Errorf("resume of the caller's namespace: got %v", got)
+	}
+	if got := call(http.MethodPost, "other", "10.0.0.1:1234"); got != http.StatusForbidden {
+		t.Errorf("resume of another namespace: got %v", got)
+	}
+	if got := call(http.MethodPost, "other", "127.0.0.1:1234"); got != http.StatusNotFound {
+		t.Errorf("resume from localhost: got %v", got)
+	}
+	if got := call(http.MethodGet, "ns", "10.0.0.1:1234"); got != http.StatusMethodNotAllowed {
+		t.Errorf("resume with GET: got %v", got)
+	}
+}
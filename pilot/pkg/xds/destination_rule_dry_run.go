@@ -0,0 +1,109 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"sort"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds/endpoints"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/validation"
+)
+
+const (
+	// DestinationRuleDryRunWarn and DestinationRuleDryRunReject are the modes of PILOT_DESTINATION_RULE_DRY_RUN.
+	DestinationRuleDryRunWarn   = "warn"
+	DestinationRuleDryRunReject = "reject"
+
+	// destinationRuleDryRunProxies is the number of connected proxies the endpoints are built for.
+	destinationRuleDryRunProxies = 10
+)
+
+// DryRunDestinationRule builds the endpoints of the subsets of a DestinationRule about to be applied, for a sample of
+// the connected proxies the service is visible to. Subsets selecting no endpoints for any of the sampled proxies,
+// while the service has endpoints, are reported as a warning or, in reject mode, as an error.
+func (s *DiscoveryServer) DryRunDestinationRule(cfg config.Config) (validation.Warning, error) {
+	dr, ok := cfg.Spec.(*networking.DestinationRule)
+	if !ok || len(dr.GetSubsets()) == 0 {
+		return nil, nil
+	}
+	hostname := model.ResolveShortnameToFQDN(dr.GetHost(), cfg.Meta)
+	if hostname.IsWildCarded() {
+		return nil, nil
+	}
+	push := s.globalPushContext()
+	rule := model.ConvertConsolidatedDestRule(&cfg)
+
+	var proxies []*model.Proxy
+	var svc *model.Service
+	for _, con := range s.Clients() {
+		proxy := con.proxy
+		if proxy.SidecarScope == nil {
+			continue
+		}
+		if found := push.ServiceForHostname(proxy, hostname); found != nil {
+			proxies = append(proxies, proxy)
+			svc = found
+		}
+	}
+	if len(proxies) == 0 {
+		return nil, nil
+	}
+	sort.Slice(proxies, func(i, j int) bool {
+		return proxies[i].ID < proxies[j].ID
+	})
+	if len(proxies) > destinationRuleDryRunProxies {
+		proxies = proxies[:destinationRuleDryRunProxies]
+	}
+
+	// hasEndpoints returns true if the subset has endpoints on any port for any of the sampled proxies.
+	hasEndpoints := func(subset string) bool {
+		for _, proxy := range proxies {
+			for _, port := range svc.Ports {
+				clusterName := model.BuildSubsetKey(model.TrafficDirectionOutbound, subset, hostname, port.Port)
+				b := endpoints.New(clusterName, endpoints.WithProxy(proxy), endpoints.WithPushContext(push),
+					endpoints.WithDestinationRule(rule))
+				for _, llb := range b.BuildClusterLoadAssignment(s.Env.EndpointIndex).GetEndpoints() {
+					if len(llb.GetLbEndpoints()) > 0 {
+						return true
+					}
+				}
+			}
+		}
+		return false
+	}
+	// A service without endpoints is not an issue of the DestinationRule.
+	if !hasEndpoints("") {
+		return nil, nil
+	}
+	var empty []string
+	for _, subset := range dr.GetSubsets() {
+		if !hasEndpoints(subset.GetName()) {
+			empty = append(empty, subset.GetName())
+		}
+	}
+	if len(empty) == 0 {
+		return nil, nil
+	}
+	err := fmt.Errorf("subsets %v of %s select no endpoints for any of the %d sampled proxies", empty, hostname, len(proxies))
+	if features.DestinationRuleDryRun == DestinationRuleDryRunReject {
+		return nil, err
+	}
+	return err, nil
+}
@@ -0,0 +1,96 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+const dryRunServices = `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: api
+  namespace: default
+spec:
+  hosts: [api.example.com]
+  ports: [{number: 80, name: http, protocol: HTTP}]
+  resolution: STATIC
+  endpoints: [{address: 1.1.1.1, labels: {version: v1}}]
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: empty
+  namespace: default
+spec:
+  hosts: [empty.example.com]
+  ports: [{number: 80, name: http, protocol: HTTP}]
+  resolution: STATIC
+`
+
+func TestDryRunDestinationRule(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{ConfigString: dryRunServices})
+	dr := func(host string, subsets ...string) config.Config {
+		spec := &networking.DestinationRule{Host: host}
+		for _, name := range subsets {
+			spec.Subsets = append(spec.Subsets, &networking.Subset{Name: name, Labels: map[string]string{"version": name}})
+		}
+		return config.Config{
+			Meta: config.Meta{GroupVersionKind: gvk.DestinationRule, Name: "dr", Namespace: "default"},
+			Spec: spec,
+		}
+	}
+	check := func(cfg config.Config) (warning string, err string) {
+		w, e := s.Discovery.DryRunDestinationRule(cfg)
+		if w != nil {
+			warning = w.Error()
+		}
+		if e != nil {
+			err = e.Error()
+		}
+		return warning, err
+	}
+
+	// Nothing is checked until proxies are connected.
+	w, e := check(dr("api.example.com", "v2"))
+	assert.Equal(t, w, "")
+	assert.Equal(t, e, "")
+
+	s.Connect(nil, nil, []string{v3.ClusterType})
+	w, e = check(dr("api.example.com", "v1"))
+	assert.Equal(t, w, "")
+	assert.Equal(t, e, "")
+	w, e = check(dr("api.example.com", "v1", "v2"))
+	assert.Equal(t, w, "subsets [v2] of api.example.com select no endpoints for any of the 1 sampled proxies")
+	assert.Equal(t, e, "")
+	// Services without endpoints are not an issue of the DestinationRule.
+	w, e = check(dr("empty.example.com", "v2"))
+	assert.Equal(t, w, "")
+	assert.Equal(t, e, "")
+
+	test.SetForTest(t, &features.DestinationRuleDryRun, DestinationRuleDryRunReject)
+	w, e = check(dr("api.example.com", "v2"))
+	assert.Equal(t, w, "")
+	assert.Equal(t, e, "subsets [v2] of api.example.com select no endpoints for any of the 1 sampled proxies")
+}
@@ -0,0 +1,139 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds_test
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pilot/test/xdstest"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/ptr"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/util/sets"
+)
+
+func TestDirectPodClusters(t *testing.T) {
+	labels := map[string]string{"app": "metrics"}
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "metrics",
+			Namespace:   "default",
+			Annotations: map[string]string{constants.DirectPodClustersAnnotation: "true"},
+		},
+		Spec: v1.ServiceSpec{
+			Ports:     []v1.ServicePort{{Name: "http-metrics", Port: 9090}},
+			Selector:  labels,
+			ClusterIP: "9.9.9.9",
+		},
+	}
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      service.Name,
+			Namespace: service.Namespace,
+			Labels:    map[string]string{discoveryv1.LabelServiceName: service.Name},
+		},
+		Endpoints: []discoveryv1.Endpoint{
+			{Addresses: []string{"1.2.3.4"}},
+			{Addresses: []string{"1.2.3.5"}},
+		},
+		Ports: []discoveryv1.EndpointPort{{Name: ptr.Of("http-metrics"), Port: ptr.Of(int32(9090))}},
+	}
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{
+		KubernetesObjects: []runtime.Object{service, slice},
+	})
+	proxy := s.SetupProxy(&model.Proxy{ConfigNamespace: "default"})
+
+	podClusters := sets.New(
+		"outbound|9090|istio-pod-1.2.3.4|metrics.default.svc.cluster.local",
+		"outbound|9090|istio-pod-1.2.3.5|metrics.default.svc.cluster.local",
+	)
+	clusters := xdstest.ExtractClusters(s.Clusters(proxy))
+	for c := range podClusters {
+		if clusters[c] == nil {
+			t.Fatalf("cluster %s not found in %v", c, xdstest.MapKeys(clusters))
+		}
+	}
+
+	for _, cla := range s.Endpoints(proxy) {
+		if !podClusters.Contains(cla.ClusterName) {
+			continue
+		}
+		addresses := xdstest.ExtractEndpoints(cla)
+		_, subset, _, _ := model.ParseSubsetKey(cla.ClusterName)
+		address, _ := model.DirectPodAddress(subset)
+		assert.Equal(t, addresses, []string{address + ":9090"})
+	}
+
+	vhosts := xdstest.ExtractRouteConfigurations(s.Routes(proxy))["9090"].GetVirtualHosts()
+	found := false
+	for _, vh := range vhosts {
+		if vh.Name == "1.2.3.4:9090" {
+			found = true
+			assert.Equal(t, vh.Routes[0].GetRoute().GetCluster(), "outbound|9090|istio-pod-1.2.3.4|metrics.default.svc.cluster.local")
+		}
+	}
+	if !found {
+		t.Fatalf("virtual host of the pod not found in %v", vhosts)
+	}
+}
+
+func TestDirectPodClustersLimit(t *testing.T) {
+	test.SetForTest(t, &features.MaxDirectPodClusters, 1)
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "metrics",
+			Namespace:   "default",
+			Annotations: map[string]string{constants.DirectPodClustersAnnotation: "true"},
+		},
+		Spec: v1.ServiceSpec{
+			Ports:     []v1.ServicePort{{Name: "http-metrics", Port: 9090}},
+			Selector:  map[string]string{"app": "metrics"},
+			ClusterIP: "9.9.9.9",
+		},
+	}
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      service.Name,
+			Namespace: service.Namespace,
+			Labels:    map[string]string{discoveryv1.LabelServiceName: service.Name},
+		},
+		Endpoints: []discoveryv1.Endpoint{
+			{Addresses: []string{"1.2.3.4"}},
+			{Addresses: []string{"1.2.3.5"}},
+		},
+		Ports: []discoveryv1.EndpointPort{{Name: ptr.Of("http-metrics"), Port: ptr.Of(int32(9090))}},
+	}
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{
+		KubernetesObjects: []runtime.Object{service, slice},
+	})
+	proxy := s.SetupProxy(&model.Proxy{ConfigNamespace: "default"})
+
+	// The port has more endpoints than allowed: its pods get no cluster.
+	for c := range xdstest.ExtractClusters(s.Clusters(proxy)) {
+		_, subset, _, _ := model.ParseSubsetKey(c)
+		if _, ok := model.DirectPodAddress(subset); ok {
+			t.Fatalf("unexpected pod cluster %s", c)
+		}
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"time"
 
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	lrs "github.com/envoyproxy/go-control-plane/envoy/service/load_stats/v3"
 	"github.com/google/uuid"
 	"go.uber.org/atomic"
 	"golang.org/x/time/rate"
@@ -36,8 +37,11 @@ import (
 	"istio.io/istio/pilot/pkg/networking/core"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/envoyfilter"
 	"istio.io/istio/pilot/pkg/networking/grpcgen"
+	"istio.io/istio/pilot/pkg/serviceregistry/serviceentry"
+	"istio.io/istio/pilot/pkg/xds/endpoints"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/schema/kind"
 	"istio.io/istio/pkg/maps"
 	"istio.io/istio/pkg/security"
@@ -116,6 +120,9 @@ type DiscoveryServer struct {
 	// Authenticators for XDS requests. Should be same/subset of the CA authenticators.
 	Authenticators []security.Authenticator
 
+	// CostProvider provides the endpoint costs added to the EDS endpoints, if set.
+	CostProvider endpoints.CostProvider
+
 	// StatusGen is notified of connect/disconnect/nack on all connections
 	StatusGen               *StatusGen
 	WorkloadEntryController *autoregistration.Controller
@@ -134,6 +141,9 @@ type DiscoveryServer struct {
 	// ListRemoteClusters collects debug information about other clusters this istiod reads from.
 	ListRemoteClusters func() []cluster.DebugInfo
 
+	// ApplyWorkloadEntryBatch replaces the WorkloadEntries of a fleet, see serviceentry.Controller.
+	ApplyWorkloadEntryBatch func(namespace, fleet string, entries []config.Config) (serviceentry.WorkloadEntryBatchResult, error)
+
 	// ClusterAliases are alias names for cluster. When a proxy connects with a cluster ID
 	// and if it has a different alias we should use that a cluster ID for proxy.
 	ClusterAliases map[cluster.ID]cluster.ID
@@ -143,6 +153,32 @@ type DiscoveryServer struct {
 
 	// discoveryStartTime is the time since the binary started
 	discoveryStartTime time.Time
+
+	// edsPauses tracks the services for which EDS pushes are currently paused.
+	edsPauses *edsPauses
+
+	// edsMeshCanary tracks the progressive rollout of meshConfig changes affecting the endpoints.
+	edsMeshCanary *edsMeshCanary
+
+	// edsDebouncer holds the pending endpoint pushes of services with a debounce push policy.
+	edsDebouncer *edsDebouncer
+
+	// endpointTTLs tracks the refreshes of endpoints with a TTL.
+	endpointTTLs *endpointTTLs
+
+	// scheduledPushes tracks when the endpoints of services changing over time, as they ramp up after a scale-up or
+	// their draining endpoints expire, are to be pushed again.
+	scheduledPushes *scheduledPushes
+
+	// edsPrecomputer rebuilds the ClusterLoadAssignments of services in the background after their endpoints change.
+	edsPrecomputer *edsPrecomputer
+
+	// lastKnownGood holds the last non-empty endpoints of the clusters, when PILOT_EDS_LAST_KNOWN_GOOD_GRACE is set.
+	lastKnownGood *lastKnownGood
+
+	// loadReports holds the endpoint load reported by the proxies, when PILOT_ENABLE_LOAD_REPORT_WEIGHTS is enabled
+	// or PILOT_SIDECAR_SUGGESTIONS_INTERVAL is set.
+	loadReports *loadReports
 }
 
 // NewDiscoveryServer creates DiscoveryServer that sources data from Pilot's internal mesh data structures
@@ -166,6 +202,14 @@ func NewDiscoveryServer(env *model.Environment, clusterAliases map[string]string
 		},
 		Cache:              env.Cache,
 		discoveryStartTime: processStartTime,
+		edsPauses:          newEdsPauses(),
+		edsMeshCanary:      newEdsMeshCanary(),
+		edsDebouncer:       newEdsDebouncer(),
+		endpointTTLs:       newEndpointTTLs(),
+		scheduledPushes:    newScheduledPushes(),
+		edsPrecomputer:     newEdsPrecomputer(),
+		lastKnownGood:      newLastKnownGood(lastKnownGoodMaxEntries),
+		loadReports:        newLoadReports(),
 	}
 
 	out.ClusterAliases = make(map[cluster.ID]cluster.ID)
@@ -206,6 +250,9 @@ func (s *DiscoveryServer) closeJwksResolver() {
 func (s *DiscoveryServer) Register(rpcs *grpc.Server) {
 	// Register v3 server
 	discovery.RegisterAggregatedDiscoveryServiceServer(rpcs, s)
+	if features.EnableLoadReportWeights || features.SidecarSuggestionsInterval > 0 {
+		lrs.RegisterLoadReportingServiceServer(rpcs, s)
+	}
 }
 
 var processStartTime = time.Now()
@@ -224,6 +271,20 @@ func (s *DiscoveryServer) Start(stopCh <-chan struct{}) {
 	go s.WorkloadEntryController.Run(stopCh)
 	go s.handleUpdates(stopCh)
 	go s.periodicRefreshMetrics(stopCh)
+	go s.periodicScheduledPushes(stopCh)
+	if features.EnableLoadReportWeights {
+		go s.periodicAdjustLoadFactors(stopCh)
+	}
+	if features.EDSLastKnownGoodGrace > 0 {
+		go s.periodicPushLastKnownGood(stopCh)
+	}
+	if edsPrecomputationEnabled() {
+		go s.edsPrecomputer.run(features.EDSPrecomputationWorkers, s.precomputeEndpoints, stopCh)
+	}
+	setMemoryBallast(features.MemoryBallastBytes)
+	if features.MemoryAccountingInterval > 0 {
+		go s.periodicMemoryAccounting(stopCh)
+	}
 	go s.sendPushes(stopCh)
 	go s.Cache.Run(stopCh)
 }
@@ -268,6 +329,7 @@ func (s *DiscoveryServer) Push(req *model.PushRequest) {
 	if !req.Full {
 		req.Push = s.globalPushContext()
 		s.dropCacheForRequest(req)
+		s.edsPrecomputer.enqueueUpdated(req)
 		s.AdsPushAll(req)
 		return
 	}
@@ -291,6 +353,7 @@ func (s *DiscoveryServer) Push(req *model.PushRequest) {
 	pushContextInitTime.Record(initContextTime.Seconds())
 
 	req.Push = push
+	s.edsPrecomputer.enqueueUpdated(req)
 	s.AdsPushAll(req)
 }
 
@@ -352,8 +415,8 @@ func debounce(ch chan *model.PushRequest, stopCh <-chan struct{}, opts debounceO
 	pushWorker := func() {
 		eventDelay := time.Since(startDebounce)
 		quietTime := time.Since(lastConfigUpdateTime)
-		// it has been too long or quiet enough
-		if eventDelay >= opts.debounceMax || quietTime >= opts.debounceAfter {
+		// it has been too long or quiet enough, or the request skips the debounce
+		if eventDelay >= opts.debounceMax || quietTime >= opts.debounceAfter || (req != nil && req.Immediate) {
 			if req != nil {
 				pushCounter++
 				if req.ConfigsUpdated == nil {
@@ -402,6 +465,9 @@ func debounce(ch chan *model.PushRequest, stopCh <-chan struct{}, opts debounceO
 			debouncedEvents++
 
 			req = req.Merge(r)
+			if r.Immediate && free {
+				pushWorker()
+			}
 		case <-timeChan:
 			if free {
 				pushWorker()
@@ -516,6 +582,7 @@ func (s *DiscoveryServer) initPushContext(req *model.PushRequest, oldPushContext
 		return nil, err
 	}
 
+	s.edsMeshCanary.observe(oldPushContext, push)
 	s.dropCacheForRequest(req)
 	s.Env.SetPushContext(push)
 
@@ -556,11 +623,13 @@ func (s *DiscoveryServer) InitGenerators(env *model.Environment, systemNameSpace
 
 	s.Generators["api"] = apigen.NewGenerator(env.ConfigStore)
 	s.Generators["api/"+v3.EndpointType] = edsGen
+	s.Generators[ReadOnlyGenerator+"/"+v3.EndpointType] = &ReadOnlyEdsGenerator{EDS: edsGen}
 
 	s.Generators["api/"+TypeURLConnect] = s.StatusGen
 
 	s.Generators["event"] = s.StatusGen
 	s.Generators[v3.DebugType] = NewDebugGen(s, systemNameSpace, internalDebugMux)
+	s.Generators[TypeURLEndpointShards] = &EndpointShardsGen{Server: s, SystemNamespace: systemNameSpace}
 	s.Generators[v3.BootstrapType] = &BootstrapGenerator{Server: s}
 }
 
@@ -23,12 +23,19 @@ import (
 	"testing"
 	"time"
 
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	uatomic "go.uber.org/atomic"
 	"google.golang.org/grpc"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	labelutil "istio.io/istio/pilot/pkg/serviceregistry/util/label"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
 	"istio.io/istio/pkg/test/util/retry"
 )
 
@@ -326,6 +333,36 @@ func TestDebounce(t *testing.T) {
 	}
 }
 
+func TestDebounceImmediate(t *testing.T) {
+	opts := debounceOptions{
+		debounceAfter:     time.Hour,
+		debounceMax:       time.Hour,
+		enableEDSDebounce: true,
+	}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	updateCh := make(chan *model.PushRequest)
+	pushed := make(chan *model.PushRequest, 2)
+	go debounce(updateCh, stopCh, opts, func(req *model.PushRequest) { pushed <- req }, uatomic.NewInt64(0))
+
+	// The updates received before the immediate one are pushed with it.
+	updateCh <- &model.PushRequest{Reason: model.NewReasonStats(model.EndpointUpdate)}
+	updateCh <- &model.PushRequest{Immediate: true, Reason: model.NewReasonStats(model.EndpointUpdate)}
+	select {
+	case req := <-pushed:
+		assert.Equal(t, req.Reason.Count(), 2)
+	case <-time.After(10 * time.Second):
+		t.Fatal("immediate request was debounced")
+	}
+
+	updateCh <- &model.PushRequest{Reason: model.NewReasonStats(model.EndpointUpdate)}
+	select {
+	case <-pushed:
+		t.Fatal("request without immediate was not debounced")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
 func TestShouldRespond(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -492,3 +529,43 @@ func TestShouldRespond(t *testing.T) {
 		})
 	}
 }
+
+func TestSetTopologyLabelsAssertedLocality(t *testing.T) {
+	newProxy := func(registryZone string) *model.Proxy {
+		p := &model.Proxy{
+			Metadata:         &model.NodeMetadata{Locality: "edge/site1/rack1"},
+			Labels:           map[string]string{},
+			XdsNode:          &core.Node{Locality: &core.Locality{Region: "node"}},
+			VerifiedIdentity: &spiffe.Identity{Namespace: "ns", ServiceAccount: "sa"},
+		}
+		if registryZone != "" {
+			p.Labels[labelutil.LabelTopologyRegion] = "registry"
+			p.Labels[labelutil.LabelTopologyZone] = registryZone
+		}
+		return p
+	}
+	cases := []struct {
+		policy       string
+		registryZone string
+		want         string
+	}{
+		{policy: model.ProxyLocalityIgnore, want: "node"},
+		{policy: model.ProxyLocalityIgnore, registryZone: "zone1", want: "registry/zone1"},
+		{policy: model.ProxyLocalityFallback, want: "edge/site1/rack1"},
+		{policy: model.ProxyLocalityFallback, registryZone: "zone1", want: "registry/zone1"},
+		{policy: model.ProxyLocalityOverride, registryZone: "zone1", want: "edge/site1/rack1"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.policy+"/"+tt.registryZone, func(t *testing.T) {
+			test.SetForTest(t, &features.ProxyLocalityPolicy, tt.policy)
+			proxy := newProxy(tt.registryZone)
+			setTopologyLabels(proxy)
+			if got := util.LocalityToString(proxy.Locality); got != tt.want {
+				t.Fatalf("expected locality %q, got %q", tt.want, got)
+			}
+			if tt.want == "edge/site1/rack1" && proxy.Labels[labelutil.LabelTopologyZone] != "site1" {
+				t.Fatalf("expected the asserted locality in the proxy labels, got %v", proxy.Labels)
+			}
+		})
+	}
+}
@@ -16,16 +16,22 @@ package xds
 
 import (
 	"fmt"
+	"time"
 
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	anypb "google.golang.org/protobuf/types/known/anypb"
 
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
 	"istio.io/istio/pilot/pkg/util/protoconv"
 	"istio.io/istio/pilot/pkg/xds/endpoints"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/network"
 	"istio.io/istio/pkg/util/sets"
 )
 
@@ -36,6 +42,9 @@ func (s *DiscoveryServer) SvcUpdate(shard model.ShardKey, hostname string, names
 	if event == model.EventDelete {
 		inboundServiceDeletes.Increment()
 		s.Env.EndpointIndex.DeleteServiceShard(shard, hostname, namespace, false)
+		s.edsPrecomputer.forgetService(hostname, namespace)
+		s.lastKnownGood.forgetService(hostname, namespace)
+		endpoints.ForgetMtlsExcludedService(hostname, namespace)
 	} else {
 		inboundServiceUpdates.Increment()
 	}
@@ -50,16 +59,89 @@ func (s *DiscoveryServer) EDSUpdate(shard model.ShardKey, serviceName string, na
 	istioEndpoints []*model.IstioEndpoint,
 ) {
 	inboundEDSUpdates.Increment()
+	// Record the refresh of endpoints with a TTL before updating the shards, see endpointTTLs.expire.
+	s.endpointTTLs.refresh(shard, serviceName, namespace, istioEndpoints, time.Now())
+	passthrough := features.EnablePassthroughEndpointMetadata && shard.Provider == provider.External
+	var passthroughKeys sets.String
+	if passthrough {
+		passthroughKeys = s.shardPassthroughKeys(shard, serviceName, namespace)
+	}
+	directPodClusters := s.hasDirectPodClusters(serviceName, namespace)
+	var podAddresses sets.String
+	if directPodClusters {
+		podAddresses = s.shardAddresses(shard, serviceName, namespace)
+	}
 	// Update the endpoint shards
-	pushType := s.Env.EndpointIndex.UpdateServiceEndpoints(shard, serviceName, namespace, istioEndpoints)
+	pushType, changedNetworks := s.Env.EndpointIndex.UpdateServiceEndpointsByNetwork(shard, serviceName, namespace, istioEndpoints)
+	if pushType == model.IncrementalPush && directPodClusters && !podAddresses.Equals(endpointAddresses(istioEndpoints)) {
+		// The clusters and routes of direct pod clusters follow the endpoint addresses.
+		pushType = model.FullPush
+	}
+	if pushType == model.IncrementalPush && passthrough && !passthroughKeys.Equals(endpoints.PassthroughKeys(istioEndpoints)) {
+		// The filter chains forwarding passthrough traffic to the endpoints of ServiceEntries follow their addresses.
+		pushType = model.FullPush
+	}
+	if pushType == model.IncrementalPush && s.edsPauses.isPaused(serviceName, namespace) {
+		// Endpoints of paused services are frozen; the update is picked up when the service is resumed.
+		return
+	}
 	if pushType == model.IncrementalPush || pushType == model.FullPush {
 		// Trigger a push
-		s.ConfigUpdate(&model.PushRequest{
+		key := model.ConfigKey{Kind: kind.ServiceEntry, Name: serviceName, Namespace: namespace}
+		req := &model.PushRequest{
 			Full:           pushType == model.FullPush,
-			ConfigsUpdated: sets.New(model.ConfigKey{Kind: kind.ServiceEntry, Name: serviceName, Namespace: namespace}),
+			ConfigsUpdated: sets.New(key),
 			Reason:         model.NewReasonStats(model.EndpointUpdate),
-		})
+		}
+		if changedNetworks != nil {
+			req.EndpointNetworks = map[model.ConfigKey]sets.Set[network.ID]{key: changedNetworks}
+		}
+		if req.Full {
+			s.ConfigUpdate(req)
+		} else {
+			s.edsPush(serviceName, namespace, req)
+		}
+	}
+}
+
+// shardPassthroughKeys returns the keys of the endpoints of the shard reached through the PassthroughEndpointsCluster.
+func (s *DiscoveryServer) shardPassthroughKeys(shard model.ShardKey, hostname, namespace string) sets.String {
+	shards, ok := s.Env.EndpointIndex.ShardsForService(hostname, namespace)
+	if !ok {
+		return sets.New[string]()
+	}
+	shards.RLock()
+	defer shards.RUnlock()
+	return endpoints.PassthroughKeys(shards.Shards[shard])
+}
+
+// shardAddresses returns the addresses of the endpoints of the shard.
+func (s *DiscoveryServer) shardAddresses(shard model.ShardKey, hostname, namespace string) sets.String {
+	shards, ok := s.Env.EndpointIndex.ShardsForService(hostname, namespace)
+	if !ok {
+		return sets.New[string]()
 	}
+	shards.RLock()
+	defer shards.RUnlock()
+	return endpointAddresses(shards.Shards[shard])
+}
+
+func endpointAddresses(istioEndpoints []*model.IstioEndpoint) sets.String {
+	out := sets.NewWithLength[string](len(istioEndpoints))
+	for _, ep := range istioEndpoints {
+		out.Insert(ep.Address)
+	}
+	return out
+}
+
+// hasDirectPodClusters returns true if the service has the networking.istio.io/direct-pod-clusters annotation.
+func (s *DiscoveryServer) hasDirectPodClusters(hostname, namespace string) bool {
+	push := s.globalPushContext()
+	if push == nil {
+		return false
+	}
+	svc := push.ServiceIndex.HostnameAndNamespace[host.Name(hostname)][namespace]
+	return svc != nil && svc.Attributes.DirectPodClusters
 }
 
 // EDSCacheUpdate computes destination address membership across all clusters and networks.
@@ -73,6 +155,7 @@ func (s *DiscoveryServer) EDSCacheUpdate(shard model.ShardKey, serviceName strin
 	istioEndpoints []*model.IstioEndpoint,
 ) {
 	inboundEDSUpdates.Increment()
+	s.endpointTTLs.refresh(shard, serviceName, namespace, istioEndpoints, time.Now())
 	// Update the endpoint shards
 	s.Env.EndpointIndex.UpdateServiceEndpoints(shard, serviceName, namespace, istioEndpoints)
 }
@@ -102,13 +185,23 @@ var skippedEdsConfigs = map[kind.Kind]struct{}{
 	kind.ProxyConfig:           {},
 }
 
-func edsNeedsPush(updates model.XdsUpdates) bool {
+// edsSkipsConfig returns true if the update of a config of kind k does not impact EDS. Telemetry only impacts EDS
+// when it changes the endpoint metadata requested by the telemetry providers.
+func edsSkipsConfig(req *model.PushRequest, k kind.Kind) bool {
+	if k == kind.Telemetry && req.Push != nil && req.Push.TelemetryEndpointMetadataChanged() {
+		return false
+	}
+	_, f := skippedEdsConfigs[k]
+	return f
+}
+
+func edsNeedsPush(req *model.PushRequest) bool {
 	// If none set, we will always push
-	if len(updates) == 0 {
+	if len(req.ConfigsUpdated) == 0 {
 		return true
 	}
-	for config := range updates {
-		if _, f := skippedEdsConfigs[config.Kind]; !f {
+	for config := range req.ConfigsUpdated {
+		if !edsSkipsConfig(req, config.Kind) {
 			return true
 		}
 	}
@@ -116,7 +209,7 @@ func edsNeedsPush(updates model.XdsUpdates) bool {
 }
 
 func (eds *EdsGenerator) Generate(proxy *model.Proxy, w *model.WatchedResource, req *model.PushRequest) (model.Resources, model.XdsLogDetails, error) {
-	if !edsNeedsPush(req.ConfigsUpdated) {
+	if !edsNeedsPush(req) {
 		return nil, model.DefaultXdsLogDetails, nil
 	}
 	resources, logDetails := eds.buildEndpoints(proxy, req, w)
@@ -141,7 +234,7 @@ func endpointDiscoveryResponse(loadAssignments []*anypb.Any, version, noncePrefi
 func (eds *EdsGenerator) GenerateDeltas(proxy *model.Proxy, req *model.PushRequest,
 	w *model.WatchedResource,
 ) (model.Resources, model.DeletedResources, model.XdsLogDetails, bool, error) {
-	if !edsNeedsPush(req.ConfigsUpdated) {
+	if !edsNeedsPush(req) {
 		return nil, nil, model.DefaultXdsLogDetails, false, nil
 	}
 	if !shouldUseDeltaEds(req) {
@@ -168,7 +261,7 @@ func canSendPartialFullPushes(req *model.PushRequest) bool {
 		return false
 	}
 	for cfg := range req.ConfigsUpdated {
-		if _, f := skippedEdsConfigs[cfg.Kind]; f {
+		if edsSkipsConfig(req, cfg.Kind) {
 			// the updated config does not impact EDS, skip it
 			// this happens when push requests are merged due to debounce
 			continue
@@ -194,11 +287,21 @@ func (eds *EdsGenerator) buildEndpoints(proxy *model.Proxy,
 	if !req.Full || canSendPartialFullPushes(req) {
 		edsUpdatedServices = model.ConfigNamesOfKind(req.ConfigsUpdated, kind.ServiceEntry)
 	}
+	_, cohort := eds.Server.edsMeshCanary.settingsFor(proxy)
+	eds.Server.edsMeshCanary.recordPush(cohort)
 	var resources model.Resources
 	empty := 0
 	cached := 0
 	regenerated := 0
-	for _, clusterName := range w.ResourceNames {
+	for _, name := range w.ResourceNames {
+		clusterName := edsClusterName(name)
+		if clusterName == util.PassthroughEndpointsCluster {
+			if passthroughEndpointsUpdated(req.Push, edsUpdatedServices) {
+				resources = append(resources, buildPassthroughEndpoints(proxy, req.Push, name))
+				regenerated++
+			}
+			continue
+		}
 		if edsUpdatedServices != nil {
 			_, _, hostname, _ := model.ParseSubsetKey(clusterName)
 			if _, ok := edsUpdatedServices[string(hostname)]; !ok {
@@ -206,11 +309,17 @@ func (eds *EdsGenerator) buildEndpoints(proxy *model.Proxy,
 				// specific Hostname. On connect or for full push edsUpdatedServices will be empty.
 				continue
 			}
+			if endpointsInvisible(proxy, req, clusterName) {
+				edsInvisibleUpdatesSkipped.Increment()
+				continue
+			}
 		}
-		builder := endpoints.NewEndpointBuilder(clusterName, proxy, req.Push)
+		builder := eds.newEndpointBuilder(name, proxy, req.Push)
+		// Paused services are built from their frozen snapshot and bypass the cache.
+		endpointIndex, paused := eds.Server.edsPauses.endpointIndexFor(builder.Service(), eds.Server.Env.EndpointIndex)
 
 		// We skip cache if assertions are enabled, so that the cache will assert our eviction logic is correct
-		if !features.EnableUnsafeAssertions {
+		if !features.EnableUnsafeAssertions && !paused {
 			cachedEndpoint := eds.Server.Cache.Get(&builder)
 			if cachedEndpoint != nil {
 				resources = append(resources, cachedEndpoint)
@@ -221,23 +330,19 @@ func (eds *EdsGenerator) buildEndpoints(proxy *model.Proxy,
 
 		// generate eds from beginning
 		{
-			l := builder.BuildClusterLoadAssignment(eds.Server.Env.EndpointIndex)
-			if l == nil {
+			resource, isEmpty := eds.generateEndpoints(&builder, endpointIndex, paused, req)
+			if resource == nil {
 				continue
 			}
 			regenerated++
-
-			if len(l.Endpoints) == 0 {
+			if isEmpty {
 				empty++
 			}
-			resource := &discovery.Resource{
-				Name:     l.ClusterName,
-				Resource: protoconv.MessageToAny(l),
-			}
+			eds.Server.edsPrecomputer.record(&builder, proxy, name)
 			resources = append(resources, resource)
-			eds.Server.Cache.Add(&builder, req, resource)
 		}
 	}
+	recordClaSizes(resources)
 	return resources, model.XdsLogDetails{
 		Incremental:    len(edsUpdatedServices) != 0,
 		AdditionalInfo: fmt.Sprintf("empty:%v cached:%v/%v", empty, cached, cached+regenerated),
@@ -250,28 +355,44 @@ func (eds *EdsGenerator) buildDeltaEndpoints(proxy *model.Proxy,
 	w *model.WatchedResource,
 ) (model.Resources, []string, model.XdsLogDetails) {
 	edsUpdatedServices := model.ConfigNamesOfKind(req.ConfigsUpdated, kind.ServiceEntry)
+	_, cohort := eds.Server.edsMeshCanary.settingsFor(proxy)
+	eds.Server.edsMeshCanary.recordPush(cohort)
 	var resources model.Resources
 	var removed []string
 	empty := 0
 	cached := 0
 	regenerated := 0
 
-	for _, clusterName := range w.ResourceNames {
+	for _, name := range w.ResourceNames {
+		clusterName := edsClusterName(name)
+		if clusterName == util.PassthroughEndpointsCluster {
+			if passthroughEndpointsUpdated(req.Push, edsUpdatedServices) {
+				resources = append(resources, buildPassthroughEndpoints(proxy, req.Push, name))
+				regenerated++
+			}
+			continue
+		}
 		// filter out eds that are not updated for clusters
 		_, _, hostname, _ := model.ParseSubsetKey(clusterName)
 		if _, ok := edsUpdatedServices[string(hostname)]; !ok {
 			continue
 		}
+		if endpointsInvisible(proxy, req, clusterName) {
+			edsInvisibleUpdatesSkipped.Increment()
+			continue
+		}
 
-		builder := endpoints.NewEndpointBuilder(clusterName, proxy, req.Push)
+		builder := eds.newEndpointBuilder(name, proxy, req.Push)
 		// if a service is not found, it means the cluster is removed
 		if !builder.ServiceFound() {
-			removed = append(removed, clusterName)
+			removed = append(removed, name)
 			continue
 		}
 
+		endpointIndex, paused := eds.Server.edsPauses.endpointIndexFor(builder.Service(), eds.Server.Env.EndpointIndex)
+
 		// We skip cache if assertions are enabled, so that the cache will assert our eviction logic is correct
-		if !features.EnableUnsafeAssertions {
+		if !features.EnableUnsafeAssertions && !paused {
 			cachedEndpoint := eds.Server.Cache.Get(&builder)
 			if cachedEndpoint != nil {
 				resources = append(resources, cachedEndpoint)
@@ -281,25 +402,112 @@ func (eds *EdsGenerator) buildDeltaEndpoints(proxy *model.Proxy,
 		}
 		// generate new eds cache
 		{
-			l := builder.BuildClusterLoadAssignment(eds.Server.Env.EndpointIndex)
-			if l == nil {
-				removed = append(removed, clusterName)
+			resource, isEmpty := eds.generateEndpoints(&builder, endpointIndex, paused, req)
+			if resource == nil {
+				removed = append(removed, name)
 				continue
 			}
 			regenerated++
-			if len(l.Endpoints) == 0 {
+			if isEmpty {
 				empty++
 			}
-			resource := &discovery.Resource{
-				Name:     l.ClusterName,
-				Resource: protoconv.MessageToAny(l),
-			}
+			eds.Server.edsPrecomputer.record(&builder, proxy, name)
 			resources = append(resources, resource)
-			eds.Server.Cache.Add(&builder, req, resource)
 		}
 	}
+	recordClaSizes(resources)
 	return resources, removed, model.XdsLogDetails{
 		Incremental:    len(edsUpdatedServices) != 0,
 		AdditionalInfo: fmt.Sprintf("empty:%v cached:%v/%v", empty, cached, cached+regenerated),
 	}
 }
+
+// newEndpointBuilder returns the builder of the ClusterLoadAssignment requested by the proxy under the given name,
+// the cluster name or, for xDS federation clients, an xdstp:// name.
+func (eds *EdsGenerator) newEndpointBuilder(name string, proxy *model.Proxy, push *model.PushContext) endpoints.EndpointBuilder {
+	clusterName := edsClusterName(name)
+	builder := endpoints.NewEndpointBuilder(clusterName, proxy, push)
+	builder.WithResourceName(name)
+	builder.WithLoadFactors(eds.Server.loadReports.loadFactors(clusterName))
+	builder.WithCostProvider(eds.Server.CostProvider)
+	builder.WithRolloutWeights(eds.Server.Env.RolloutWeights)
+	return builder
+}
+
+// clusterLoadAssignment returns the ClusterLoadAssignment of the builder from the cache, or builds it without caching
+// it if it is not found, for debugging. It has none of the side effects of generateEndpoints.
+func (eds *EdsGenerator) clusterLoadAssignment(builder *endpoints.EndpointBuilder) *endpoint.ClusterLoadAssignment {
+	if cached := eds.Server.Cache.Get(builder); cached != nil {
+		cla := &endpoint.ClusterLoadAssignment{}
+		if err := cached.Resource.UnmarshalTo(cla); err == nil {
+			return cla
+		}
+	}
+	return builder.BuildClusterLoadAssignment(eds.Server.Env.EndpointIndex)
+}
+
+// generateEndpoints builds the ClusterLoadAssignment of the builder from the endpoint index and adds it to the cache,
+// unless the service is paused or its last known good endpoints are retained. It returns nil if there is no
+// ClusterLoadAssignment for the cluster, and whether the ClusterLoadAssignment has no endpoints.
+func (eds *EdsGenerator) generateEndpoints(builder *endpoints.EndpointBuilder, endpointIndex *model.EndpointIndex,
+	paused bool, req *model.PushRequest,
+) (*discovery.Resource, bool) {
+	l := builder.BuildClusterLoadAssignment(endpointIndex)
+	if l == nil {
+		return nil, false
+	}
+	eds.Server.scheduleEndpointsPush(builder.Service(), builder.ScaleUpRampUntil(), builder.SessionDrainExpiry(), time.Now())
+	cla := protoconv.MessageToAny(l)
+	resource := &discovery.Resource{
+		Name:     l.ClusterName,
+		Version:  endpoints.ResourceVersion(cla),
+		Resource: cla,
+	}
+	resource, retained := eds.retainLastKnownGood(builder, l, resource)
+	if !paused && !retained {
+		eds.Server.Cache.Add(builder, req, resource)
+	}
+	return resource, len(l.Endpoints) == 0
+}
+
+// passthroughEndpointsUpdated returns true if the ClusterLoadAssignment of the PassthroughEndpointsCluster may have
+// changed: on full pushes, and on incremental pushes updating the endpoints of a ServiceEntry.
+func passthroughEndpointsUpdated(push *model.PushContext, edsUpdatedServices map[string]struct{}) bool {
+	if edsUpdatedServices == nil {
+		return true
+	}
+	for hostname := range edsUpdatedServices {
+		for _, svc := range push.ServiceIndex.HostnameAndNamespace[host.Name(hostname)] {
+			if svc.Attributes.ServiceRegistry == provider.External {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// buildPassthroughEndpoints builds the ClusterLoadAssignment of the PassthroughEndpointsCluster, named after the
+// requested resource. It depends on the endpoints of all the ServiceEntries visible to the proxy, so it is not cached.
+func buildPassthroughEndpoints(proxy *model.Proxy, push *model.PushContext, name string) *discovery.Resource {
+	cla := endpoints.BuildPassthroughClusterLoadAssignment(proxy, push, name)
+	return &discovery.Resource{
+		Name:     name,
+		Resource: protoconv.MessageToAny(cla),
+	}
+}
+
+// recordClaSizes records the size of the ClusterLoadAssignments sent to a proxy.
+func recordClaSizes(resources model.Resources) {
+	for _, r := range resources {
+		edsClaBytes.Record(float64(len(r.Resource.GetValue())))
+	}
+}
+
+// edsClusterName returns the cluster of an EDS resource name, which is the cluster name or, for xDS federation
+// clients, an xdstp:// name.
+func edsClusterName(name string) string {
+	if _, clusterName, ok := v3.ParseXdstpResourceName(v3.EndpointType, name); ok {
+		return clusterName
+	}
+	return name
+}
@@ -0,0 +1,186 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"sync"
+	"time"
+
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds/endpoints"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/util/sets"
+)
+
+const (
+	// lastKnownGoodMaxEntries bounds the number of ClusterLoadAssignments held by lastKnownGood. The keys of the
+	// endpoint builders change with the proxies connected, so the least recently built ones are evicted first.
+	lastKnownGoodMaxEntries = 10000
+	// lastKnownGoodCheckInterval is how often the end of the retention of the last known good endpoints is checked.
+	lastKnownGoodCheckInterval = 5 * time.Second
+)
+
+type lastKnownGoodService struct {
+	hostname  string
+	namespace string
+}
+
+// lastKnownGood holds the last non-empty ClusterLoadAssignment built for each endpoint builder, keyed by the cache
+// key of the builder. While no registry has reported the endpoints of a service, as during a resync, the empty
+// ClusterLoadAssignments of its clusters are replaced by the last non-empty ones for up to
+// PILOT_EDS_LAST_KNOWN_GOOD_GRACE, so that the proxies do not drop their endpoints in the meantime.
+type lastKnownGood struct {
+	mu    sync.Mutex
+	byKey *simplelru.LRU[any, *lastKnownGoodCLA]
+	// byService holds the keys of the ClusterLoadAssignments of each service, so they are dropped with it.
+	byService map[lastKnownGoodService]sets.Set[any]
+}
+
+type lastKnownGoodCLA struct {
+	service  lastKnownGoodService
+	resource *discovery.Resource
+	// missingSince is when the endpoints of the service were first found missing, zero while they are indexed.
+	missingSince time.Time
+	// until is when the retention of the resource ends, zero while it is not retained.
+	until time.Time
+}
+
+func newLastKnownGood(size int) *lastKnownGood {
+	l := &lastKnownGood{byService: map[lastKnownGoodService]sets.Set[any]{}}
+	l.byKey, _ = simplelru.NewLRU[any, *lastKnownGoodCLA](size, func(key any, cla *lastKnownGoodCLA) {
+		if keys := l.byService[cla.service]; keys != nil {
+			keys.Delete(key)
+			if keys.IsEmpty() {
+				delete(l.byService, cla.service)
+			}
+		}
+	})
+	return l
+}
+
+// resolve returns the resource to send for the ClusterLoadAssignment built for the key of a service. Non-empty
+// resources are recorded and returned as is. Empty resources are replaced by the last non-empty one if the endpoints
+// of the service are missing from the index since less than grace, in which case true is returned as well.
+func (l *lastKnownGood) resolve(key any, svc lastKnownGoodService, resource *discovery.Resource, empty, missing bool,
+	grace time.Duration, now time.Time,
+) (*discovery.Resource, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !empty {
+		l.byKey.Add(key, &lastKnownGoodCLA{service: svc, resource: resource})
+		if l.byService[svc] == nil {
+			l.byService[svc] = sets.New[any]()
+		}
+		l.byService[svc].Insert(key)
+		return resource, false
+	}
+	cur, f := l.byKey.Get(key)
+	if !f {
+		return resource, false
+	}
+	if !missing {
+		// The registries of the service report no endpoints.
+		l.byKey.Remove(key)
+		return resource, false
+	}
+	if cur.missingSince.IsZero() {
+		cur.missingSince = now
+		cur.until = now.Add(grace)
+	}
+	if !now.Before(cur.until) {
+		l.byKey.Remove(key)
+		return resource, false
+	}
+	edsLastKnownGoodRetained.Increment()
+	return cur.resource, true
+}
+
+// forgetService drops the ClusterLoadAssignments recorded for the service, once it is deleted.
+func (l *lastKnownGood) forgetService(hostname, namespace string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key := range l.byService[lastKnownGoodService{hostname: hostname, namespace: namespace}] {
+		l.byKey.Remove(key)
+	}
+}
+
+// due returns the services whose retained ClusterLoadAssignments expired at the given time, and drops them. Their
+// endpoints must be pushed again, now that their empty ClusterLoadAssignments are sent.
+func (l *lastKnownGood) due(now time.Time) sets.Set[lastKnownGoodService] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := sets.New[lastKnownGoodService]()
+	for _, key := range l.byKey.Keys() {
+		cur, _ := l.byKey.Peek(key)
+		if !cur.until.IsZero() && !now.Before(cur.until) {
+			out.Insert(cur.service)
+			l.byKey.Remove(key)
+		}
+	}
+	return out
+}
+
+// retainLastKnownGood returns the resource to send for the ClusterLoadAssignment built by the builder, and whether it
+// is the last known good one, which must not be cached. The endpoints are pushed again once the retention ends.
+func (eds *EdsGenerator) retainLastKnownGood(builder *endpoints.EndpointBuilder, l *endpoint.ClusterLoadAssignment,
+	resource *discovery.Resource,
+) (*discovery.Resource, bool) {
+	if features.EDSLastKnownGoodGrace <= 0 {
+		return resource, false
+	}
+	svc := builder.Service()
+	if svc == nil {
+		return resource, false
+	}
+	return eds.Server.lastKnownGood.resolve(builder.Key(),
+		lastKnownGoodService{hostname: string(svc.Hostname), namespace: svc.Attributes.Namespace},
+		resource, len(l.Endpoints) == 0, builder.ShardsMissing(), features.EDSLastKnownGoodGrace, time.Now())
+}
+
+// pushLastKnownGood pushes the endpoints of the services whose last known good endpoints are no longer retained.
+func (s *DiscoveryServer) pushLastKnownGood(now time.Time) {
+	due := s.lastKnownGood.due(now)
+	if len(due) == 0 {
+		return
+	}
+	configs := sets.NewWithLength[model.ConfigKey](len(due))
+	for svc := range due {
+		configs.Insert(model.ConfigKey{Kind: kind.ServiceEntry, Name: svc.hostname, Namespace: svc.namespace})
+	}
+	s.ConfigUpdate(&model.PushRequest{
+		Full:           false,
+		ConfigsUpdated: configs,
+		Reason:         model.NewReasonStats(model.EndpointUpdate),
+	})
+}
+
+// periodicPushLastKnownGood checks the end of the retention of the last known good endpoints periodically.
+func (s *DiscoveryServer) periodicPushLastKnownGood(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(lastKnownGoodCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.pushLastKnownGood(now)
+		case <-stopCh:
+			return
+		}
+	}
+}
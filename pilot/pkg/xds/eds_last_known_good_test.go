@@ -0,0 +1,98 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/util/sets"
+)
+
+func TestLastKnownGood(t *testing.T) {
+	now := time.Unix(10000, 0)
+	grace := time.Minute
+	svc := lastKnownGoodService{hostname: "a.ns.svc.cluster.local", namespace: "ns"}
+	good := &discovery.Resource{Name: "outbound|80||a.ns.svc.cluster.local", Version: "1"}
+	empty := &discovery.Resource{Name: "outbound|80||a.ns.svc.cluster.local", Version: "2"}
+	l := newLastKnownGood(10)
+	// Without a last known good resource, the empty one is sent.
+	r, retained := l.resolve(uint64(1), svc, empty, true, true, grace, now)
+	assert.Equal(t, r, empty)
+	assert.Equal(t, retained, false)
+
+	r, _ = l.resolve(uint64(1), svc, good, false, false, grace, now)
+	assert.Equal(t, r, good)
+	// While the endpoints are missing from the index, the last known good resource is retained for the grace period,
+	// starting when they were first found missing.
+	r, retained = l.resolve(uint64(1), svc, empty, true, true, grace, now)
+	assert.Equal(t, r, good)
+	assert.Equal(t, retained, true)
+	r, _ = l.resolve(uint64(1), svc, empty, true, true, grace, now.Add(30*time.Second))
+	assert.Equal(t, r, good)
+	r, _ = l.resolve(uint64(1), svc, empty, true, true, grace, now.Add(grace))
+	assert.Equal(t, r, empty)
+	r, _ = l.resolve(uint64(1), svc, empty, true, true, grace, now.Add(30*time.Second))
+	assert.Equal(t, r, empty)
+
+	// Once the registries of the service report no endpoints, the empty resource is sent.
+	l.resolve(uint64(1), svc, good, false, false, grace, now)
+	r, retained = l.resolve(uint64(1), svc, empty, true, false, grace, now)
+	assert.Equal(t, r, empty)
+	assert.Equal(t, retained, false)
+	r, _ = l.resolve(uint64(1), svc, empty, true, true, grace, now)
+	assert.Equal(t, r, empty)
+}
+
+func TestLastKnownGoodDue(t *testing.T) {
+	now := time.Unix(10000, 0)
+	grace := time.Minute
+	svc := lastKnownGoodService{hostname: "a.ns.svc.cluster.local", namespace: "ns"}
+	good := &discovery.Resource{Version: "1"}
+	empty := &discovery.Resource{Version: "2"}
+	l := newLastKnownGood(10)
+
+	l.resolve(uint64(1), svc, good, false, false, grace, now)
+	l.resolve(uint64(2), svc, good, false, false, grace, now)
+	l.resolve(uint64(1), svc, empty, true, true, grace, now)
+	assert.Equal(t, l.due(now.Add(30*time.Second)), sets.New[lastKnownGoodService]())
+	// The service is pushed once the retention ends, and its expired resource is dropped.
+	assert.Equal(t, l.due(now.Add(grace)), sets.New(svc))
+	assert.Equal(t, l.byKey.Len(), 1)
+	assert.Equal(t, l.due(now.Add(2*grace)), sets.New[lastKnownGoodService]())
+}
+
+func TestLastKnownGoodEviction(t *testing.T) {
+	now := time.Unix(10000, 0)
+	a := lastKnownGoodService{hostname: "a.ns.svc.cluster.local", namespace: "ns"}
+	b := lastKnownGoodService{hostname: "b.ns.svc.cluster.local", namespace: "ns"}
+	good := &discovery.Resource{Version: "1"}
+	l := newLastKnownGood(2)
+
+	l.resolve(uint64(1), a, good, false, false, time.Minute, now)
+	l.resolve(uint64(2), a, good, false, false, time.Minute, now)
+	l.resolve(uint64(3), b, good, false, false, time.Minute, now)
+	// The least recently built resource is evicted.
+	assert.Equal(t, l.byKey.Len(), 2)
+	assert.Equal(t, l.byService[a], sets.New[any](uint64(2)))
+
+	// The resources of deleted services are dropped.
+	l.forgetService(a.hostname, a.namespace)
+	assert.Equal(t, l.byKey.Keys(), []any{uint64(3)})
+	assert.Equal(t, l.byService[a] == nil, true)
+}
@@ -0,0 +1,301 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/util/hash"
+)
+
+const (
+	canaryCohort   = "canary"
+	baselineCohort = "baseline"
+)
+
+// edsMeshCanary rolls out the meshConfig changes affecting the endpoints progressively. When such a change is
+// seen and PILOT_EDS_MESH_CANARY_PERCENT is set, only a percentage of the proxies receive endpoints and clusters
+// built with the new settings; the others keep the settings in effect before the change until the rollout is promoted.
+type edsMeshCanary struct {
+	mu sync.RWMutex
+	// state is the last rollout state stored, nil if none.
+	state *EDSMeshCanaryState
+	// store shares the state with the other istiod replicas. Without it, the state is kept in memory.
+	store EDSMeshCanaryStore
+}
+
+// EDSMeshCanaryState is the state of the rollouts of the meshConfig endpoint settings. It is shared by the istiod
+// replicas, so that a proxy receives the same settings from all of them, and kept across restarts.
+type EDSMeshCanaryState struct {
+	// Settings are the last endpoint settings of the meshConfig handled by a replica.
+	Settings *model.EndpointMeshSettings
+	// Baseline holds the settings served to the baseline proxies, nil when no rollout is in progress.
+	Baseline *model.EndpointMeshSettings
+	Percent  int
+	Since    time.Time
+}
+
+// EDSMeshCanaryStore updates the rollout state shared by the istiod replicas. The update returns the new state from
+// the stored one, without modifying it, and is called again on conflicting updates.
+type EDSMeshCanaryStore func(update func(*EDSMeshCanaryState) *EDSMeshCanaryState) error
+
+func (s *EDSMeshCanaryState) active() bool {
+	return s != nil && s.Baseline != nil
+}
+
+func (s *EDSMeshCanaryState) equals(other *EDSMeshCanaryState) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	return s.Settings.Equals(other.Settings) && s.Baseline.Equals(other.Baseline) &&
+		s.Baseline.GetVersion() == other.Baseline.GetVersion() && s.Percent == other.Percent && s.Since.Equal(other.Since)
+}
+
+// EDSMeshCanaryStatus describes the rollout in progress, for debugging.
+type EDSMeshCanaryStatus struct {
+	Active  bool      `json:"active"`
+	Percent int       `json:"percent,omitempty"`
+	Since   time.Time `json:"since,omitempty"`
+}
+
+func newEdsMeshCanary() *edsMeshCanary {
+	return &edsMeshCanary{}
+}
+
+// SetEDSMeshCanaryStore shares the rollouts of the meshConfig endpoint settings with the other istiod replicas through
+// the store, which passes the state it holds to LoadEDSMeshCanary. It must be called before the server starts.
+func (s *DiscoveryServer) SetEDSMeshCanaryStore(store EDSMeshCanaryStore) {
+	s.edsMeshCanary.store = store
+}
+
+// LoadEDSMeshCanary applies the rollout state stored by the istiod replicas, pushing to all proxies if it changed.
+func (s *DiscoveryServer) LoadEDSMeshCanary(state *EDSMeshCanaryState) {
+	if s.edsMeshCanary.load(state) {
+		s.ConfigUpdate(&model.PushRequest{
+			Full:   true,
+			Reason: model.NewReasonStats(model.GlobalUpdate),
+		})
+	}
+}
+
+// observe starts a rollout if the endpoint settings of the meshConfig differ between the old and new push contexts.
+// A rollout in progress keeps its baseline, and ends if the settings are reverted to it.
+func (c *edsMeshCanary) observe(old, push *model.PushContext) {
+	if c == nil || old == nil || old.Mesh == nil || push.Mesh == nil || features.EDSMeshCanaryPercent <= 0 {
+		return
+	}
+	current, previous := push.EndpointMeshSettings(), old.EndpointMeshSettings()
+	c.mu.RLock()
+	state := c.state
+	c.mu.RUnlock()
+	if (state != nil && state.Settings.Equals(current)) || (state == nil && previous.Equals(current)) {
+		return
+	}
+	previous.Version = "baseline/" + old.PushVersion
+	if _, err := c.update(func(stored *EDSMeshCanaryState) *EDSMeshCanaryState {
+		return nextEDSMeshCanaryState(stored, previous, current)
+	}); err != nil {
+		log.Warnf("failed to store the EDS canary rollout, only applying it to this istiod: %v", err)
+		c.load(nextEDSMeshCanaryState(state, previous, current))
+	}
+}
+
+// nextEDSMeshCanaryState returns the rollout state once the endpoint settings of the meshConfig changed from previous
+// to current.
+func nextEDSMeshCanaryState(stored *EDSMeshCanaryState, previous, current *model.EndpointMeshSettings) *EDSMeshCanaryState {
+	if stored != nil && stored.Settings.Equals(current) {
+		// Another istiod replica handled the change already.
+		return stored
+	}
+	if stored.active() {
+		if stored.Baseline.Equals(current) {
+			log.Infof("meshConfig endpoint settings reverted, ending the EDS canary rollout")
+			return &EDSMeshCanaryState{Settings: current}
+		}
+		next := *stored
+		next.Settings = current
+		return &next
+	}
+	if previous.Equals(current) {
+		return &EDSMeshCanaryState{Settings: current}
+	}
+	log.Infof("meshConfig endpoint settings changed, rolling them out to %d%% of the proxies", features.EDSMeshCanaryPercent)
+	return &EDSMeshCanaryState{Settings: current, Baseline: previous, Percent: features.EDSMeshCanaryPercent, Since: time.Now()}
+}
+
+// update applies the update to the rollout state, through the store if any, and returns the new state.
+func (c *edsMeshCanary) update(update func(*EDSMeshCanaryState) *EDSMeshCanaryState) (*EDSMeshCanaryState, error) {
+	if c.store == nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.state = update(c.state)
+		return c.state, nil
+	}
+	var stored *EDSMeshCanaryState
+	if err := c.store(func(s *EDSMeshCanaryState) *EDSMeshCanaryState {
+		stored = update(s)
+		return stored
+	}); err != nil {
+		return nil, err
+	}
+	c.load(stored)
+	return stored, nil
+}
+
+// load sets the rollout state, returning whether it changed.
+func (c *edsMeshCanary) load(state *EDSMeshCanaryState) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state.equals(state) {
+		return false
+	}
+	c.state = state
+	return true
+}
+
+// settingsFor returns the settings to build the endpoints and clusters of the proxy with, nil meaning the ones of the
+// push context, and the cohort of the proxy, empty when no rollout is in progress.
+func (c *edsMeshCanary) settingsFor(proxy *model.Proxy) (*model.EndpointMeshSettings, string) {
+	if c == nil {
+		return nil, ""
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.state.active() {
+		return nil, ""
+	}
+	if inCanary(proxy.ID, c.state.Percent) {
+		return nil, canaryCohort
+	}
+	return c.state.Baseline, baselineCohort
+}
+
+// inCanary returns whether the proxy is part of the percentage of proxies receiving the new settings.
+func inCanary(proxyID string, percent int) bool {
+	h := hash.New()
+	h.Write([]byte(proxyID))
+	return int(h.Sum64()%100) < percent
+}
+
+// recordPush records a push of endpoints to a proxy of the cohort.
+func (c *edsMeshCanary) recordPush(cohort string) {
+	if cohort != "" {
+		edsMeshCanaryPushes.With(cohortTag.Value(cohort)).Increment()
+	}
+}
+
+// recordReject records a rejection of the endpoints by the proxy.
+func (c *edsMeshCanary) recordReject(proxy *model.Proxy) {
+	if _, cohort := c.settingsFor(proxy); cohort != "" {
+		edsMeshCanaryRejects.With(cohortTag.Value(cohort)).Increment()
+	}
+}
+
+// setPercent changes the percentage of proxies receiving the new settings, returning false if no rollout is in progress.
+func (c *edsMeshCanary) setPercent(percent int) (bool, error) {
+	state, err := c.update(func(s *EDSMeshCanaryState) *EDSMeshCanaryState {
+		if !s.active() {
+			return s
+		}
+		next := *s
+		next.Percent = percent
+		return &next
+	})
+	return state.active(), err
+}
+
+// promote ends the rollout, serving the new settings to all proxies. It returns false if no rollout is in progress.
+func (c *edsMeshCanary) promote() (bool, error) {
+	promoted := false
+	_, err := c.update(func(s *EDSMeshCanaryState) *EDSMeshCanaryState {
+		promoted = s.active()
+		if !promoted {
+			return s
+		}
+		return &EDSMeshCanaryState{Settings: s.Settings}
+	})
+	return promoted, err
+}
+
+func (c *edsMeshCanary) status() EDSMeshCanaryStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.state.active() {
+		return EDSMeshCanaryStatus{}
+	}
+	return EDSMeshCanaryStatus{Active: true, Percent: c.state.Percent, Since: c.state.Since}
+}
+
+// edsMeshCanaryz shows or changes the rollout of the meshConfig endpoint settings.
+// It is mapped to /debug/eds_mesh_canary, and accepts a `percent` query parameter to change the percentage of
+// proxies receiving the new settings, or `action=promote` to serve them to all proxies. The changes are shared with
+// the other istiod replicas.
+func (s *DiscoveryServer) edsMeshCanaryz(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	percent, action := q.Get("percent"), q.Get("action")
+	switch {
+	case percent == "" && action == "":
+		writeJSON(w, s.edsMeshCanary.status(), req)
+		return
+	case percent != "":
+		p, err := strconv.Atoi(percent)
+		if err != nil || p < 0 || p > 100 {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(w, "Invalid percent %q, expected a number between 0 and 100\n", percent)
+			return
+		}
+		found, err := s.edsMeshCanary.setPercent(p)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = fmt.Fprintf(w, "Failed to store the EDS canary rollout: %v\n", err)
+			return
+		}
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("No EDS canary rollout in progress\n"))
+			return
+		}
+		log.WithLabels("audit", "eds", "percent", p, "remote", req.RemoteAddr).Warnf("EDS canary rollout percentage changed")
+	case action == "promote":
+		found, err := s.edsMeshCanary.promote()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = fmt.Fprintf(w, "Failed to store the EDS canary rollout: %v\n", err)
+			return
+		}
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("No EDS canary rollout in progress\n"))
+			return
+		}
+		log.WithLabels("audit", "eds", "remote", req.RemoteAddr).Warnf("EDS canary rollout promoted")
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(w, "Unknown action %q, expected promote\n", action)
+		return
+	}
+	// The proxies moving between cohorts need their endpoints and clusters rebuilt.
+	s.ConfigUpdate(&model.PushRequest{
+		Full:   true,
+		Reason: model.NewReasonStats(model.DebugTrigger),
+	})
+	_, _ = w.Write([]byte("OK"))
+}
@@ -0,0 +1,169 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func pushWithLocalityLb(version string, enabled bool) *model.PushContext {
+	push := model.NewPushContext()
+	push.PushVersion = version
+	push.Mesh = &meshconfig.MeshConfig{
+		LocalityLbSetting: &v1alpha3.LocalityLoadBalancerSetting{Enabled: wrapperspb.Bool(enabled)},
+	}
+	return push
+}
+
+func TestEdsMeshCanary(t *testing.T) {
+	c := newEdsMeshCanary()
+	v1, v2 := pushWithLocalityLb("v1", false), pushWithLocalityLb("v2", true)
+
+	// Disabled by default
+	c.observe(v1, v2)
+	assert.Equal(t, c.status().Active, false)
+
+	test.SetForTest(t, &features.EDSMeshCanaryPercent, 20)
+	// Unrelated changes do not start a rollout.
+	c.observe(v1, pushWithLocalityLb("v1b", false))
+	assert.Equal(t, c.status().Active, false)
+
+	c.observe(v1, v2)
+	assert.Equal(t, c.status().Active, true)
+	assert.Equal(t, c.status().Percent, 20)
+
+	canary, baseline := 0, 0
+	for i := 0; i < 1000; i++ {
+		settings, cohort := c.settingsFor(&model.Proxy{ID: fmt.Sprintf("proxy-%d.ns", i)})
+		switch cohort {
+		case canaryCohort:
+			canary++
+			assert.Equal(t, settings, nil)
+		case baselineCohort:
+			baseline++
+			assert.Equal(t, settings.LocalityLbSetting.GetEnabled().GetValue(), false)
+			assert.Equal(t, settings.Version, "baseline/v1")
+		default:
+			t.Fatalf("unexpected cohort %q", cohort)
+		}
+	}
+	if canary < 100 || canary > 300 {
+		t.Fatalf("expected about 20%% of the proxies in the canary, got %d/%d", canary, canary+baseline)
+	}
+
+	// A later change keeps the baseline of the rollout
+	c.observe(v2, pushWithLocalityLb("v3", true))
+	settings, _ := c.settingsFor(&model.Proxy{ID: "baseline-proxy"})
+	if settings != nil {
+		assert.Equal(t, settings.Version, "baseline/v1")
+	}
+
+	found, err := c.setPercent(100)
+	assert.NoError(t, err)
+	assert.Equal(t, found, true)
+	settings, cohort := c.settingsFor(&model.Proxy{ID: "proxy"})
+	assert.Equal(t, settings, nil)
+	assert.Equal(t, cohort, canaryCohort)
+
+	found, err = c.promote()
+	assert.NoError(t, err)
+	assert.Equal(t, found, true)
+	found, _ = c.promote()
+	assert.Equal(t, found, false)
+	found, _ = c.setPercent(50)
+	assert.Equal(t, found, false)
+	settings, cohort = c.settingsFor(&model.Proxy{ID: "proxy"})
+	assert.Equal(t, settings, nil)
+	assert.Equal(t, cohort, "")
+}
+
+func TestEdsMeshCanaryRevert(t *testing.T) {
+	test.SetForTest(t, &features.EDSMeshCanaryPercent, 50)
+	c := newEdsMeshCanary()
+	v1, v2 := pushWithLocalityLb("v1", false), pushWithLocalityLb("v2", true)
+	c.observe(v1, v2)
+	assert.Equal(t, c.status().Active, true)
+	// Reverting the meshConfig ends the rollout
+	c.observe(v2, pushWithLocalityLb("v3", false))
+	assert.Equal(t, c.status().Active, false)
+}
+
+// memoryEDSMeshCanaryStore is the store shared by the istiod replicas, notifying them of the changes as the ConfigMap
+// watch does.
+type memoryEDSMeshCanaryStore struct {
+	mu       sync.Mutex
+	state    *EDSMeshCanaryState
+	replicas []*edsMeshCanary
+}
+
+func (m *memoryEDSMeshCanaryStore) update(update func(*EDSMeshCanaryState) *EDSMeshCanaryState) error {
+	m.mu.Lock()
+	m.state = update(m.state)
+	state := m.state
+	m.mu.Unlock()
+	for _, r := range m.replicas {
+		r.load(state)
+	}
+	return nil
+}
+
+func (m *memoryEDSMeshCanaryStore) replica() *edsMeshCanary {
+	c := newEdsMeshCanary()
+	c.store = m.update
+	m.replicas = append(m.replicas, c)
+	return c
+}
+
+func TestEdsMeshCanaryReplicas(t *testing.T) {
+	test.SetForTest(t, &features.EDSMeshCanaryPercent, 50)
+	store := &memoryEDSMeshCanaryStore{}
+	a, b := store.replica(), store.replica()
+	v1, v2, v3 := pushWithLocalityLb("v1", false), pushWithLocalityLb("v2", true), pushWithLocalityLb("v3", false)
+
+	a.observe(v1, v2)
+	b.observe(v1, v2)
+	assert.Equal(t, a.status(), b.status())
+	assert.Equal(t, b.status().Active, true)
+
+	// The rollout changes made through one replica apply to all of them.
+	found, err := a.setPercent(20)
+	assert.NoError(t, err)
+	assert.Equal(t, found, true)
+	assert.Equal(t, b.status().Percent, 20)
+
+	// A replica seeing the revert after another one ended the rollout does not start a new one.
+	a.observe(v2, v3)
+	b.observe(v2, v3)
+	assert.Equal(t, a.status().Active, false)
+	assert.Equal(t, b.status().Active, false)
+
+	// A restarted replica loads the rollout in progress.
+	a.observe(v3, v2)
+	restarted := store.replica()
+	restarted.load(store.state)
+	assert.Equal(t, restarted.status(), a.status())
+	assert.Equal(t, restarted.status().Active, true)
+}
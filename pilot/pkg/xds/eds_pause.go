@@ -0,0 +1,185 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// edsPauses tracks services whose EDS pushes are paused. While a service is paused, its endpoints
+// are served from a snapshot taken when the pause started, so clients see a frozen ClusterLoadAssignment
+// regardless of endpoint churn. This is intended as an emergency brake only.
+type edsPauses struct {
+	mu sync.RWMutex
+	// paused maps the service to a single-service EndpointIndex holding the snapshot of its shards.
+	paused map[types.NamespacedName]pausedService
+}
+
+type pausedService struct {
+	index *model.EndpointIndex
+	since time.Time
+}
+
+// EDSPauseStatus describes a paused service, for debugging.
+type EDSPauseStatus struct {
+	Hostname  string    `json:"hostname"`
+	Namespace string    `json:"namespace"`
+	Since     time.Time `json:"since"`
+}
+
+func newEdsPauses() *edsPauses {
+	return &edsPauses{paused: map[types.NamespacedName]pausedService{}}
+}
+
+// pause snapshots the current shards of the service. It returns false if the service is already paused
+// or has no endpoints.
+func (p *edsPauses) pause(index *model.EndpointIndex, hostname, namespace string) bool {
+	shards, f := index.ShardsForService(hostname, namespace)
+	if !f {
+		return false
+	}
+	snapshot := shards.DeepCopy()
+	frozen := model.NewEndpointIndex(model.DisabledCache{})
+	fs, _ := frozen.GetOrCreateEndpointShard(hostname, namespace)
+	fs.Shards = snapshot.Shards
+	fs.ServiceAccounts = snapshot.ServiceAccounts
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := types.NamespacedName{Name: hostname, Namespace: namespace}
+	if _, f := p.paused[key]; f {
+		return false
+	}
+	p.paused[key] = pausedService{index: frozen, since: time.Now()}
+	return true
+}
+
+// resume drops the snapshot of the service. It returns false if the service was not paused.
+func (p *edsPauses) resume(hostname, namespace string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := types.NamespacedName{Name: hostname, Namespace: namespace}
+	if _, f := p.paused[key]; !f {
+		return false
+	}
+	delete(p.paused, key)
+	return true
+}
+
+func (p *edsPauses) isPaused(hostname, namespace string) bool {
+	if p == nil {
+		return false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	_, f := p.paused[types.NamespacedName{Name: hostname, Namespace: namespace}]
+	return f
+}
+
+// endpointIndexFor returns the EndpointIndex to build the service's endpoints from, and whether the service is paused.
+func (p *edsPauses) endpointIndexFor(svc *model.Service, index *model.EndpointIndex) (*model.EndpointIndex, bool) {
+	if p == nil || svc == nil {
+		return index, false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	ps, f := p.paused[types.NamespacedName{Name: string(svc.Hostname), Namespace: svc.Attributes.Namespace}]
+	if !f {
+		return index, false
+	}
+	return ps.index, true
+}
+
+// list returns the paused services of the namespace, or of all namespaces if empty.
+func (p *edsPauses) list(namespace string) []EDSPauseStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	out := make([]EDSPauseStatus, 0, len(p.paused))
+	for k, v := range p.paused {
+		if namespace != "" && k.Namespace != namespace {
+			continue
+		}
+		out = append(out, EDSPauseStatus{Hostname: k.Name, Namespace: k.Namespace, Since: v.since})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Namespace != out[j].Namespace {
+			return out[i].Namespace < out[j].Namespace
+		}
+		return out[i].Hostname < out[j].Hostname
+	})
+	return out
+}
+
+// edsPausez lists, pauses or resumes EDS pushes for a service.
+// It is mapped to /debug/eds_pause, and expects `hostname`, `namespace` and `action` (pause or resume) query parameters
+// in a POST request. Without an action, the paused services of the namespace, or of all namespaces, are listed.
+func (s *DiscoveryServer) edsPausez(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	action := q.Get("action")
+	if action == "" {
+		writeJSON(w, s.edsPauses.list(q.Get("namespace")), req)
+		return
+	}
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = w.Write([]byte("EDS pushes can only be paused or resumed with POST\n"))
+		return
+	}
+	hostname, namespace := q.Get("hostname"), q.Get("namespace")
+	if hostname == "" || namespace == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("You must provide a hostname and namespace in the query string\n"))
+		return
+	}
+	switch action {
+	case "pause":
+		if !s.edsPauses.pause(s.Env.EndpointIndex, hostname, namespace) {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = fmt.Fprintf(w, "Service %s/%s is already paused or has no endpoints\n", namespace, hostname)
+			return
+		}
+		edsPauseEvents.With(typeTag.Value("pause")).Increment()
+		log.WithLabels("audit", "eds", "service", namespace+"/"+hostname, "remote", req.RemoteAddr).Warnf("EDS pushes paused")
+	case "resume":
+		if !s.edsPauses.resume(hostname, namespace) {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprintf(w, "Service %s/%s is not paused\n", namespace, hostname)
+			return
+		}
+		edsPauseEvents.With(typeTag.Value("resume")).Increment()
+		log.WithLabels("audit", "eds", "service", namespace+"/"+hostname, "remote", req.RemoteAddr).Warnf("EDS pushes resumed")
+		// Catch up with the endpoint changes that happened while paused.
+		s.ConfigUpdate(&model.PushRequest{
+			Full:           false,
+			ConfigsUpdated: sets.New(model.ConfigKey{Kind: kind.ServiceEntry, Name: hostname, Namespace: namespace}),
+			Reason:         model.NewReasonStats(model.DebugTrigger),
+		})
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(w, "Unknown action %q, expected pause or resume\n", action)
+		return
+	}
+	_, _ = w.Write([]byte("OK"))
+}
@@ -0,0 +1,54 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestEdsPauses(t *testing.T) {
+	index := model.NewEndpointIndex(model.DisabledCache{})
+	shard := model.ShardKey{Cluster: "c1", Provider: provider.Kubernetes}
+	index.UpdateServiceEndpoints(shard, "a.ns.svc.cluster.local", "ns", []*model.IstioEndpoint{{Address: "10.0.0.1"}})
+	svc := &model.Service{Hostname: "a.ns.svc.cluster.local", Attributes: model.ServiceAttributes{Namespace: "ns"}}
+
+	p := newEdsPauses()
+	assert.Equal(t, p.pause(index, "unknown", "ns"), false)
+	assert.Equal(t, p.pause(index, "a.ns.svc.cluster.local", "ns"), true)
+	assert.Equal(t, p.pause(index, "a.ns.svc.cluster.local", "ns"), false)
+	assert.Equal(t, p.isPaused("a.ns.svc.cluster.local", "ns"), true)
+
+	// Endpoint changes after the pause are not visible through the frozen index.
+	index.UpdateServiceEndpoints(shard, "a.ns.svc.cluster.local", "ns", []*model.IstioEndpoint{{Address: "10.0.0.2"}})
+	frozen, paused := p.endpointIndexFor(svc, index)
+	assert.Equal(t, paused, true)
+	shards, _ := frozen.ShardsForService("a.ns.svc.cluster.local", "ns")
+	assert.Equal(t, shards.Shards[shard][0].Address, "10.0.0.1")
+	assert.Equal(t, len(p.list("")), 1)
+	assert.Equal(t, len(p.list("ns")), 1)
+	assert.Equal(t, len(p.list("other")), 0)
+
+	assert.Equal(t, p.resume("a.ns.svc.cluster.local", "ns"), true)
+	assert.Equal(t, p.resume("a.ns.svc.cluster.local", "ns"), false)
+	live, paused := p.endpointIndexFor(svc, index)
+	assert.Equal(t, paused, false)
+	if live != index {
+		t.Fatalf("expected the live EndpointIndex after resume")
+	}
+}
@@ -0,0 +1,302 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"sync"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pilot/pkg/xds/endpoints"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/util/sets"
+)
+
+type edsPrecomputeKey struct {
+	hostname  string
+	namespace string
+}
+
+// edsPrecomputeClass is a ClusterLoadAssignment recently built for a proxy. The proxies for which the builder has the
+// same cache key share the ClusterLoadAssignment, so the fields of one of them are enough to rebuild it.
+type edsPrecomputeClass struct {
+	// name is the resource name requested by the proxy.
+	name string
+	// proxy holds the fields of the proxy read by the endpoint builder, copied when the class is recorded.
+	proxy *model.Proxy
+	// owner is the ID of the proxy which recorded the class.
+	owner string
+}
+
+// edsPrecomputeRef identifies a recorded ClusterLoadAssignment.
+type edsPrecomputeRef struct {
+	key      edsPrecomputeKey
+	cacheKey any
+}
+
+// edsPrecomputer moves the construction of the ClusterLoadAssignments off the push path, when
+// PILOT_ENABLE_EDS_PRECOMPUTATION is enabled. It records the distinct ClusterLoadAssignments built for each service,
+// and when a push clears the cache entries of a service, rebuilds them in background workers and adds them back to
+// the XDS cache, so that the proxies of the push are served from the cache. The records are kept across push
+// contexts and dropped when their proxy disconnects or their service is deleted.
+type edsPrecomputer struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	classes map[edsPrecomputeKey]map[any]edsPrecomputeClass
+	// owners indexes the recorded ClusterLoadAssignments by the ID of the proxy which recorded them.
+	owners map[string]sets.Set[edsPrecomputeRef]
+	// pending are the services whose ClusterLoadAssignments must be rebuilt, in order, without duplicates.
+	pending    []edsPrecomputeKey
+	pendingSet sets.Set[edsPrecomputeKey]
+	stopped    bool
+}
+
+func newEdsPrecomputer() *edsPrecomputer {
+	p := &edsPrecomputer{
+		classes:    map[edsPrecomputeKey]map[any]edsPrecomputeClass{},
+		owners:     map[string]sets.Set[edsPrecomputeRef]{},
+		pendingSet: sets.New[edsPrecomputeKey](),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// edsPrecomputationEnabled returns whether the ClusterLoadAssignments are precomputed. The precomputation is disabled
+// when PILOT_EDS_LAST_KNOWN_GOOD_GRACE is set, as the last known good ClusterLoadAssignments are retained by the
+// pushes building them.
+func edsPrecomputationEnabled() bool {
+	return features.EnableEDSPrecomputation && features.EDSLastKnownGoodGrace <= 0
+}
+
+// record records the ClusterLoadAssignment built by the builder for the proxy, so that it is rebuilt in the background
+// when the endpoints of its service change. The record is refreshed when the sidecar scope of the proxy changes.
+func (p *edsPrecomputer) record(builder *endpoints.EndpointBuilder, proxy *model.Proxy, name string) {
+	if !edsPrecomputationEnabled() || !builder.ServiceFound() {
+		return
+	}
+	svc := builder.Service()
+	ref := edsPrecomputeRef{
+		key:      edsPrecomputeKey{hostname: string(svc.Hostname), namespace: svc.Attributes.Namespace},
+		cacheKey: builder.Key(),
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if class, f := p.classes[ref.key][ref.cacheKey]; f {
+		if class.proxy.SidecarScope == proxy.SidecarScope {
+			return
+		}
+		p.dropOwner(class.owner, ref)
+	}
+	if p.classes[ref.key] == nil {
+		p.classes[ref.key] = map[any]edsPrecomputeClass{}
+	}
+	p.classes[ref.key][ref.cacheKey] = edsPrecomputeClass{name: name, proxy: precomputeProxy(proxy), owner: proxy.ID}
+	if p.owners[proxy.ID] == nil {
+		p.owners[proxy.ID] = sets.New[edsPrecomputeRef]()
+	}
+	p.owners[proxy.ID].Insert(ref)
+}
+
+// dropOwner removes the recorded ClusterLoadAssignment from the index of its owner. It must be called with the lock
+// held.
+func (p *edsPrecomputer) dropOwner(owner string, ref edsPrecomputeRef) {
+	refs := p.owners[owner]
+	refs.Delete(ref)
+	if len(refs) == 0 {
+		delete(p.owners, owner)
+	}
+}
+
+// precomputeProxy copies the fields of the proxy read by the endpoint builder, so that the record neither retains the
+// state of the connection nor races with its updates.
+func precomputeProxy(proxy *model.Proxy) *model.Proxy {
+	proxy.RLock()
+	defer proxy.RUnlock()
+	out := &model.Proxy{
+		Type:                          proxy.Type,
+		IPAddresses:                   proxy.IPAddresses,
+		ID:                            proxy.ID,
+		Locality:                      proxy.Locality,
+		DNSDomain:                     proxy.DNSDomain,
+		ConfigNamespace:               proxy.ConfigNamespace,
+		Labels:                        proxy.Labels,
+		Metadata:                      proxy.Metadata,
+		SidecarScope:                  proxy.SidecarScope,
+		IstioVersion:                  proxy.IstioVersion,
+		VerifiedIdentity:              proxy.VerifiedIdentity,
+		TelemetryEndpointMetadataKeys: proxy.TelemetryEndpointMetadataKeys,
+		EndpointMeshSettings:          proxy.EndpointMeshSettings,
+	}
+	out.DiscoverIPMode()
+	return out
+}
+
+// enqueueUpdated schedules the ClusterLoadAssignments of the services updated by the push request to be rebuilt. It
+// must be called once the push has cleared their cache entries and set its push context, otherwise the rebuilt
+// ClusterLoadAssignments would be dropped by the push.
+func (p *edsPrecomputer) enqueueUpdated(req *model.PushRequest) {
+	if !edsPrecomputationEnabled() {
+		return
+	}
+	for key := range model.ConfigsOfKind(req.ConfigsUpdated, kind.ServiceEntry) {
+		p.enqueue(key.Name, key.Namespace)
+	}
+}
+
+// enqueue schedules the ClusterLoadAssignments of the service to be rebuilt.
+func (p *edsPrecomputer) enqueue(hostname, namespace string) {
+	if !edsPrecomputationEnabled() {
+		return
+	}
+	key := edsPrecomputeKey{hostname: hostname, namespace: namespace}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.classes[key]) == 0 || p.pendingSet.InsertContains(key) {
+		return
+	}
+	p.pending = append(p.pending, key)
+	p.cond.Signal()
+}
+
+// next blocks until a service must be rebuilt, and returns it. It returns false once the precomputer is stopped.
+func (p *edsPrecomputer) next() (edsPrecomputeKey, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.pending) == 0 && !p.stopped {
+		p.cond.Wait()
+	}
+	if p.stopped {
+		return edsPrecomputeKey{}, false
+	}
+	key := p.pending[0]
+	p.pending = p.pending[1:]
+	p.pendingSet.Delete(key)
+	return key, true
+}
+
+// classesFor returns the ClusterLoadAssignments recorded for the service.
+func (p *edsPrecomputer) classesFor(key edsPrecomputeKey) []edsPrecomputeClass {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]edsPrecomputeClass, 0, len(p.classes[key]))
+	for _, class := range p.classes[key] {
+		out = append(out, class)
+	}
+	return out
+}
+
+// forgetProxy drops the ClusterLoadAssignments recorded for the proxy, once it disconnects. Another connection of the
+// proxy records them again on its next push.
+func (p *edsPrecomputer) forgetProxy(proxy *model.Proxy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for ref := range p.owners[proxy.ID] {
+		classes := p.classes[ref.key]
+		delete(classes, ref.cacheKey)
+		if len(classes) == 0 {
+			delete(p.classes, ref.key)
+		}
+	}
+	delete(p.owners, proxy.ID)
+}
+
+// forgetService drops the ClusterLoadAssignments recorded for the service, once it is deleted.
+func (p *edsPrecomputer) forgetService(hostname, namespace string) {
+	key := edsPrecomputeKey{hostname: hostname, namespace: namespace}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for cacheKey, class := range p.classes[key] {
+		p.dropOwner(class.owner, edsPrecomputeRef{key: key, cacheKey: cacheKey})
+	}
+	delete(p.classes, key)
+}
+
+// run rebuilds the ClusterLoadAssignments of the enqueued services with the given number of workers, until the stop
+// channel is closed.
+func (p *edsPrecomputer) run(workers int, precompute func(key edsPrecomputeKey), stopCh <-chan struct{}) {
+	for i := 0; i < workers; i++ {
+		go func() {
+			for {
+				key, ok := p.next()
+				if !ok {
+					return
+				}
+				precompute(key)
+			}
+		}()
+	}
+	<-stopCh
+	p.mu.Lock()
+	p.stopped = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+}
+
+// precomputeEndpoints rebuilds the recorded ClusterLoadAssignments of the service missing from the XDS cache with the
+// current push context, and adds them to the cache.
+func (s *DiscoveryServer) precomputeEndpoints(key edsPrecomputeKey) {
+	eds, ok := s.Generators[v3.EndpointType].(*EdsGenerator)
+	if !ok || features.EnableUnsafeAssertions {
+		return
+	}
+	// The start of the rebuild is taken before the push context, so that the ClusterLoadAssignments are dropped by
+	// the cache if it is invalidated meanwhile, as for pushes.
+	req := &model.PushRequest{Start: time.Now(), Reason: model.NewReasonStats(model.EndpointUpdate)}
+	req.Push = s.globalPushContext()
+	if req.Push == nil {
+		return
+	}
+	for _, class := range s.edsPrecomputer.classesFor(key) {
+		if class.proxy.SidecarScope == nil {
+			continue
+		}
+		builder := eds.newEndpointBuilder(class.name, class.proxy, req.Push)
+		if !builder.ServiceFound() {
+			continue
+		}
+		endpointIndex, paused := s.edsPauses.endpointIndexFor(builder.Service(), s.Env.EndpointIndex)
+		if paused || s.Cache.Get(&builder) != nil {
+			continue
+		}
+		if eds.cacheEndpoints(&builder, endpointIndex, req) {
+			edsPrecomputations.Increment()
+		}
+	}
+}
+
+// cacheEndpoints builds the ClusterLoadAssignment of the builder from the endpoint index and adds it to the cache,
+// returning whether it was added. Unlike generateEndpoints, it has no side effects: the ClusterLoadAssignments
+// depending on the time, during a scale-up ramp or a session drain, are left to the pushes, which schedule the next
+// ones.
+func (eds *EdsGenerator) cacheEndpoints(builder *endpoints.EndpointBuilder, endpointIndex *model.EndpointIndex,
+	req *model.PushRequest,
+) bool {
+	l := builder.BuildClusterLoadAssignment(endpointIndex)
+	if l == nil || !builder.ScaleUpRampUntil().IsZero() || !builder.SessionDrainExpiry().IsZero() {
+		return false
+	}
+	cla := protoconv.MessageToAny(l)
+	eds.Server.Cache.Add(builder, req, &discovery.Resource{
+		Name:     l.ClusterName,
+		Version:  endpoints.ResourceVersion(cla),
+		Resource: cla,
+	})
+	return true
+}
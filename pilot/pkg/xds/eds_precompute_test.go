@@ -0,0 +1,138 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+	"time"
+
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/util/sets"
+)
+
+func TestEdsPrecomputation(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{
+		ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se
+  namespace: default
+spec:
+  hosts:
+  - example.com
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: STATIC
+  endpoints:
+  - address: 1.2.3.4
+`,
+	})
+	// The workers are not started, the ClusterLoadAssignments are rebuilt by the test.
+	test.SetForTest(t, &features.EnableEDSPrecomputation, true)
+	shard := model.ShardKey{Cluster: "remote", Provider: provider.External}
+	s.Discovery.EDSUpdate(shard, "example.com", "default", []*model.IstioEndpoint{{Address: "10.0.0.1", EndpointPort: 80, ServicePortName: "http"}})
+	s.EnsureSynced(t)
+	eds := s.Discovery.Generators[v3.EndpointType].(*EdsGenerator)
+	clusterName := model.BuildSubsetKey(model.TrafficDirectionOutbound, "", host.Name("example.com"), 80)
+	key := edsPrecomputeKey{hostname: "example.com", namespace: "default"}
+	proxy := s.SetupProxy(nil)
+	push := s.PushContext()
+	cached := func() []string {
+		builder := eds.newEndpointBuilder(clusterName, proxy, push)
+		resource := s.Discovery.Cache.Get(&builder)
+		if resource == nil {
+			return nil
+		}
+		cla := &endpoint.ClusterLoadAssignment{}
+		if err := resource.Resource.UnmarshalTo(cla); err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for _, llb := range cla.Endpoints {
+			for _, lbEp := range llb.LbEndpoints {
+				out = append(out, lbEp.GetEndpoint().GetAddress().GetSocketAddress().GetAddress())
+			}
+		}
+		return out
+	}
+
+	// The ClusterLoadAssignments built for the proxy are recorded.
+	eds.Generate(proxy, &model.WatchedResource{ResourceNames: []string{clusterName}},
+		&model.PushRequest{Full: true, Push: push, Start: time.Now()})
+	classes := s.Discovery.edsPrecomputer.classesFor(key)
+	assert.Equal(t, len(classes), 1)
+	assert.Equal(t, cached(), []string{"1.2.3.4", "10.0.0.1"})
+
+	// The record holds a copy of the fields of the proxy, not the connected proxy.
+	if classes[0].proxy == proxy || classes[0].proxy.SidecarScope != proxy.SidecarScope {
+		t.Fatal("the fields of the proxy are not recorded")
+	}
+	assert.Equal(t, classes[0].owner, proxy.ID)
+
+	// The push following an endpoint update clears the cache, then schedules the rebuild of the service.
+	p := s.Discovery.edsPrecomputer
+	s.Discovery.EDSUpdate(shard, "example.com", "default", []*model.IstioEndpoint{{Address: "10.0.0.2", EndpointPort: 80, ServicePortName: "http"}})
+	s.EnsureSynced(t)
+	assert.Equal(t, cached(), nil)
+	assert.Equal(t, p.pendingSet.Contains(key), true)
+
+	// Once rebuilt, the ClusterLoadAssignment of the proxy is served from the cache.
+	s.Discovery.precomputeEndpoints(key)
+	assert.Equal(t, cached(), []string{"1.2.3.4", "10.0.0.2"})
+	_, details, err := eds.Generate(proxy, &model.WatchedResource{ResourceNames: []string{clusterName}},
+		&model.PushRequest{
+			Push:           push,
+			Start:          time.Now(),
+			ConfigsUpdated: sets.New(model.ConfigKey{Kind: kind.ServiceEntry, Name: "example.com", Namespace: "default"}),
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, details.AdditionalInfo, "empty:0 cached:1/1")
+
+	// The records survive full pushes.
+	s.Discovery.ConfigUpdate(&model.PushRequest{Full: true})
+	s.EnsureSynced(t)
+	assert.Equal(t, len(p.classesFor(key)), 1)
+
+	// The services are rebuilt once, and only if they have recorded ClusterLoadAssignments.
+	p.pending, p.pendingSet = nil, sets.New[edsPrecomputeKey]()
+	p.enqueue("example.com", "default")
+	p.enqueue("example.com", "default")
+	p.enqueue("other.com", "default")
+	assert.Equal(t, len(p.pending), 1)
+	assert.Equal(t, p.pendingSet.Contains(key), true)
+
+	// The ClusterLoadAssignments of disconnected proxies are no longer rebuilt.
+	p.forgetProxy(proxy)
+	assert.Equal(t, len(p.classesFor(key)), 0)
+	assert.Equal(t, len(p.owners), 0)
+
+	// The precomputation is disabled when the last known good ClusterLoadAssignments are retained.
+	test.SetForTest(t, &features.EDSLastKnownGoodGrace, time.Minute)
+	eds.Generate(proxy, &model.WatchedResource{ResourceNames: []string{clusterName}},
+		&model.PushRequest{Full: true, Push: push, Start: time.Now()})
+	assert.Equal(t, len(p.classesFor(key)), 0)
+}
@@ -0,0 +1,91 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/host"
+)
+
+// edsDebouncer coalesces the incremental endpoint pushes of services with a per-service debounce period.
+// Only the first update of a window schedules a push: the push reads the latest endpoints, so the
+// updates received while the push is pending are folded into it.
+type edsDebouncer struct {
+	mu      sync.Mutex
+	pending map[types.NamespacedName]struct{}
+}
+
+func newEdsDebouncer() *edsDebouncer {
+	return &edsDebouncer{pending: map[types.NamespacedName]struct{}{}}
+}
+
+// schedule calls push after the period, unless a push for the service is already pending.
+// It returns false if the update was folded into a pending push.
+func (d *edsDebouncer) schedule(hostname, namespace string, period time.Duration, push func()) bool {
+	key := types.NamespacedName{Name: hostname, Namespace: namespace}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, f := d.pending[key]; f {
+		return false
+	}
+	d.pending[key] = struct{}{}
+	time.AfterFunc(period, func() {
+		d.mu.Lock()
+		delete(d.pending, key)
+		d.mu.Unlock()
+		push()
+	})
+	return true
+}
+
+// endpointPushPolicy returns the push policy of the service, based on the current push context.
+func (s *DiscoveryServer) endpointPushPolicy(hostname, namespace string) model.EndpointPushPolicy {
+	push := s.globalPushContext()
+	if push == nil {
+		return model.EndpointPushPolicy{}
+	}
+	svc := push.ServiceIndex.HostnameAndNamespace[host.Name(hostname)][namespace]
+	if svc == nil {
+		return model.EndpointPushPolicy{}
+	}
+	return svc.Attributes.EndpointPushPolicy
+}
+
+// edsPush triggers an incremental endpoint push for the service, honoring its EndpointPushPolicy.
+func (s *DiscoveryServer) edsPush(hostname, namespace string, req *model.PushRequest) {
+	policy := s.endpointPushPolicy(hostname, namespace)
+	switch {
+	case policy.Immediate:
+		// Skip the debounce period, still pushing through the push queue and its concurrency limit.
+		req.Immediate = true
+		edsPushPolicyEvents.With(typeTag.Value("immediate")).Increment()
+		s.ConfigUpdate(req)
+	case policy.Debounce > 0:
+		// The pending push also covers the updates folded into it, whose networks are not tracked.
+		req.EndpointNetworks = nil
+		if s.edsDebouncer.schedule(hostname, namespace, policy.Debounce, func() { s.ConfigUpdate(req) }) {
+			edsPushPolicyEvents.With(typeTag.Value("debounced")).Increment()
+		} else {
+			edsPushPolicyEvents.With(typeTag.Value("coalesced")).Increment()
+		}
+	default:
+		s.ConfigUpdate(req)
+	}
+}
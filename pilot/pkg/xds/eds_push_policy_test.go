@@ -0,0 +1,42 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func TestEdsDebouncer(t *testing.T) {
+	d := newEdsDebouncer()
+	pushes := atomic.NewInt32(0)
+	push := func() { pushes.Inc() }
+
+	assert.Equal(t, d.schedule("a.ns.svc.cluster.local", "ns", 50*time.Millisecond, push), true)
+	// Updates within the window are folded into the pending push.
+	assert.Equal(t, d.schedule("a.ns.svc.cluster.local", "ns", 50*time.Millisecond, push), false)
+	// Other services are debounced independently.
+	assert.Equal(t, d.schedule("b.ns.svc.cluster.local", "ns", 50*time.Millisecond, push), true)
+
+	retry.UntilOrFail(t, func() bool { return pushes.Load() == 2 }, retry.Timeout(time.Second))
+	// Once the push happened, a new window starts.
+	assert.Equal(t, d.schedule("a.ns.svc.cluster.local", "ns", 50*time.Millisecond, push), true)
+	retry.UntilOrFail(t, func() bool { return pushes.Load() == 3 }, retry.Timeout(time.Second))
+}
@@ -0,0 +1,82 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// ReadOnlyGenerator is the GENERATOR node metadata of read-only xDS clients. These are clients outside of the mesh,
+// such as external load balancers, that only subscribe to the endpoints of the services exported to their
+// namespace, in order to reuse the endpoint discovery of Istio.
+const ReadOnlyGenerator = "readonly"
+
+// ReadOnlyEdsGenerator generates the endpoints for read-only xDS clients. Only the ClusterLoadAssignments of the
+// services exported to the verified namespace of the client are generated, other resources are ignored.
+type ReadOnlyEdsGenerator struct {
+	EDS *EdsGenerator
+}
+
+var _ model.XdsDeltaResourceGenerator = &ReadOnlyEdsGenerator{}
+
+func (g *ReadOnlyEdsGenerator) Generate(proxy *model.Proxy, w *model.WatchedResource, req *model.PushRequest) (model.Resources, model.XdsLogDetails, error) {
+	return g.EDS.Generate(proxy, readOnlyAuthorized(proxy, req.Push, w), req)
+}
+
+func (g *ReadOnlyEdsGenerator) GenerateDeltas(proxy *model.Proxy, req *model.PushRequest,
+	w *model.WatchedResource,
+) (model.Resources, model.DeletedResources, model.XdsLogDetails, bool, error) {
+	return g.EDS.GenerateDeltas(proxy, req, readOnlyAuthorized(proxy, req.Push, w))
+}
+
+// readOnlyAuthorized returns the watched resources restricted to the ClusterLoadAssignments of the services
+// exported to the verified namespace of the client.
+func readOnlyAuthorized(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource) *model.WatchedResource {
+	authorized := *w
+	authorized.ResourceNames = nil
+	for _, name := range w.ResourceNames {
+		_, _, hostname, _ := model.ParseSubsetKey(edsClusterName(name))
+		svc := push.ServiceForHostname(proxy, hostname)
+		if proxy.VerifiedIdentity == nil || !push.IsServiceVisible(svc, proxy.VerifiedIdentity.Namespace) {
+			log.Debugf("read-only client %v is not authorized for endpoints %v", proxy.ID, name)
+			readOnlyEdsDenied.Increment()
+			continue
+		}
+		authorized.ResourceNames = append(authorized.ResourceNames, name)
+	}
+	return &authorized
+}
+
+// authorizeReadOnly authorizes a read-only xDS client. Unlike proxies, read-only clients must always be
+// authenticated, since their namespace determines the services they can read.
+func authorizeReadOnly(proxy *model.Proxy, identities []string) error {
+	if !features.EnableReadOnlyEDS {
+		return status.New(codes.PermissionDenied, "read-only xDS clients are not enabled").Err()
+	}
+	if len(identities) == 0 {
+		return status.New(codes.Unauthenticated, "read-only xDS clients must be authenticated").Err()
+	}
+	id, err := checkConnectionIdentity(proxy, identities)
+	if err != nil {
+		log.Warnf("Unauthorized read-only XDS: %v with identity %v: %v", proxy.ID, identities, err)
+		return status.Newf(codes.PermissionDenied, "authorization failed: %v", err).Err()
+	}
+	proxy.VerifiedIdentity = id
+	return nil
+}
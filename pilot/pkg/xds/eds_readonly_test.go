@@ -0,0 +1,101 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+const readOnlyServices = `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: public
+  namespace: other
+spec:
+  hosts: [public.example.com]
+  ports: [{number: 80, name: http, protocol: HTTP}]
+  resolution: STATIC
+  endpoints: [{address: 1.1.1.1}]
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: private
+  namespace: other
+spec:
+  hosts: [private.example.com]
+  exportTo: ["."]
+  ports: [{number: 80, name: http, protocol: HTTP}]
+  resolution: STATIC
+  endpoints: [{address: 2.2.2.2}]
+`
+
+func TestReadOnlyAuthorized(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{ConfigString: readOnlyServices})
+	proxy := s.SetupProxy(&model.Proxy{
+		ConfigNamespace: "client",
+		Metadata:        &model.NodeMetadata{Generator: ReadOnlyGenerator, Namespace: "client"},
+	})
+	w := &model.WatchedResource{TypeUrl: v3.EndpointType, ResourceNames: []string{
+		"outbound|80||public.example.com",
+		"outbound|80||private.example.com",
+		"outbound|80||unknown.example.com",
+	}}
+
+	// Without verified identity, nothing is authorized.
+	assert.Equal(t, readOnlyAuthorized(proxy, s.PushContext(), w).ResourceNames, nil)
+
+	proxy.VerifiedIdentity = &spiffe.Identity{Namespace: "client", ServiceAccount: "lb"}
+	assert.Equal(t, readOnlyAuthorized(proxy, s.PushContext(), w).ResourceNames, []string{"outbound|80||public.example.com"})
+	// The watched resources of the client are left untouched.
+	assert.Equal(t, len(w.ResourceNames), 3)
+}
+
+func TestAuthorizeReadOnly(t *testing.T) {
+	proxy := &model.Proxy{ConfigNamespace: "client", Metadata: &model.NodeMetadata{Generator: ReadOnlyGenerator}}
+	code := func(err error) codes.Code {
+		return status.Code(err)
+	}
+
+	assert.Equal(t, code(authorizeReadOnly(proxy, []string{"spiffe://cluster.local/ns/client/sa/lb"})), codes.PermissionDenied)
+
+	test.SetForTest(t, &features.EnableReadOnlyEDS, true)
+	assert.Equal(t, code(authorizeReadOnly(proxy, nil)), codes.Unauthenticated)
+	assert.Equal(t, code(authorizeReadOnly(proxy, []string{"spiffe://cluster.local/ns/other/sa/lb"})), codes.PermissionDenied)
+	assert.NoError(t, authorizeReadOnly(proxy, []string{"spiffe://cluster.local/ns/client/sa/lb"}))
+	assert.Equal(t, proxy.VerifiedIdentity.Namespace, "client")
+}
+
+func TestReadOnlyFindGenerator(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	con := &Connection{proxy: &model.Proxy{Metadata: &model.NodeMetadata{Generator: ReadOnlyGenerator}}}
+	if _, ok := s.Discovery.findGenerator(v3.EndpointType, con).(*ReadOnlyEdsGenerator); !ok {
+		t.Fatalf("expected the read-only generator for endpoints")
+	}
+	if g := s.Discovery.findGenerator(v3.ClusterType, con); g != nil {
+		t.Fatalf("expected no generator for clusters, got %T", g)
+	}
+}
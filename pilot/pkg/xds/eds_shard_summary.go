@@ -0,0 +1,51 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/schema/kind"
+)
+
+// endpointsInvisible returns true if the ClusterLoadAssignment of the cluster cannot have changed for the proxy in an
+// incremental push, because the endpoints of its service only changed on networks the proxy cannot view. The
+// endpoints of these networks are dropped from the ClusterLoadAssignments of the proxy, when multi-network is enabled.
+func endpointsInvisible(proxy *model.Proxy, req *model.PushRequest, clusterName string) bool {
+	if req.Full || len(req.EndpointNetworks) == 0 {
+		return false
+	}
+	if mgr := req.Push.NetworkManager(); mgr == nil || !mgr.IsMultiNetworkEnabled() {
+		return false
+	}
+	_, _, hostname, _ := model.ParseSubsetKey(clusterName)
+	view := proxy.GetView()
+	found := false
+	for key := range req.ConfigsUpdated {
+		if key.Kind != kind.ServiceEntry || key.Name != string(hostname) {
+			continue
+		}
+		networks, f := req.EndpointNetworks[key]
+		if !f {
+			return false
+		}
+		for n := range networks {
+			if view.IsVisible(&model.IstioEndpoint{Network: n}) {
+				return false
+			}
+		}
+		found = true
+	}
+	return found
+}
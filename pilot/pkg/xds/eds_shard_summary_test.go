@@ -0,0 +1,60 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/network"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/util/sets"
+)
+
+func TestEndpointsInvisible(t *testing.T) {
+	const clusterName = "outbound|80||a.com"
+	key := model.ConfigKey{Kind: kind.ServiceEntry, Name: "a.com", Namespace: "ns"}
+	request := func(push *model.PushContext, networks ...network.ID) *model.PushRequest {
+		return &model.PushRequest{
+			ConfigsUpdated:   sets.New(key),
+			EndpointNetworks: map[model.ConfigKey]sets.Set[network.ID]{key: sets.New(networks...)},
+			Push:             push,
+		}
+	}
+	proxy := &model.Proxy{Metadata: &model.NodeMetadata{RequestedNetworkView: []string{"network-0"}}}
+
+	// Without multi-network, the endpoints of all the networks are sent.
+	single := NewFakeDiscoveryServer(t, FakeOptions{}).PushContext()
+	assert.Equal(t, endpointsInvisible(proxy, request(single, "network-1"), clusterName), false)
+
+	multi := NewFakeDiscoveryServer(t, FakeOptions{
+		NetworksWatcher: mesh.NewFixedNetworksWatcher(&meshconfig.MeshNetworks{Networks: createGateways(2)}),
+	}).PushContext()
+	assert.Equal(t, endpointsInvisible(proxy, request(multi, "network-1"), clusterName), true)
+	assert.Equal(t, endpointsInvisible(proxy, request(multi, "network-0", "network-1"), clusterName), false)
+	assert.Equal(t, endpointsInvisible(proxy, request(multi, "network-1"), "outbound|80||b.com"), false)
+	// Proxies without a restricted view see all the networks.
+	assert.Equal(t, endpointsInvisible(&model.Proxy{Metadata: &model.NodeMetadata{}}, request(multi, "network-1"), clusterName), false)
+	// Full pushes and services with unknown networks are always built.
+	full := request(multi, "network-1")
+	full.Full = true
+	assert.Equal(t, endpointsInvisible(proxy, full, clusterName), false)
+	unknown := request(multi, "network-1")
+	unknown.ConfigsUpdated.Insert(model.ConfigKey{Kind: kind.ServiceEntry, Name: "a.com", Namespace: "other"})
+	assert.Equal(t, endpointsInvisible(proxy, unknown, clusterName), false)
+}
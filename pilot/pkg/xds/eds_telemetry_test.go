@@ -0,0 +1,68 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+
+	tpb "istio.io/api/telemetry/v1alpha1"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/util/sets"
+)
+
+func TestEdsNeedsPushTelemetry(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	push := s.PushContext()
+	update := func(name string, annotations map[string]string) *model.PushRequest {
+		t.Helper()
+		cfg := config.Config{
+			Meta: config.Meta{
+				GroupVersionKind: gvk.Telemetry,
+				Name:             name,
+				Namespace:        "default",
+				Annotations:      annotations,
+			},
+			Spec: &tpb.Telemetry{},
+		}
+		if _, err := s.Store().Create(cfg); err != nil {
+			t.Fatal(err)
+		}
+		req := &model.PushRequest{
+			Full:           true,
+			ConfigsUpdated: sets.New(model.ConfigKey{Kind: kind.Telemetry, Name: name, Namespace: "default"}),
+		}
+		req.Push = model.NewPushContext()
+		if err := req.Push.InitContext(s.Env(), push, req); err != nil {
+			t.Fatal(err)
+		}
+		push = req.Push
+		return req
+	}
+
+	// A Telemetry not requesting endpoint metadata does not impact EDS.
+	req := update("logging", nil)
+	assert.Equal(t, edsNeedsPush(req), false)
+	assert.Equal(t, canSendPartialFullPushes(req), true)
+
+	// A Telemetry requesting endpoint metadata rebuilds the endpoints.
+	req = update("metadata", map[string]string{constants.TelemetryEndpointMetadataAnnotation: "team"})
+	assert.Equal(t, edsNeedsPush(req), true)
+	assert.Equal(t, canSendPartialFullPushes(req), false)
+}
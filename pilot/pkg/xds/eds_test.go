@@ -14,11 +14,13 @@
 package xds_test
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -31,6 +33,7 @@ import (
 
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	uatomic "go.uber.org/atomic"
 
 	"istio.io/istio/pilot/pkg/features"
@@ -38,6 +41,7 @@ import (
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/serviceregistry/memory"
 	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pilot/pkg/xds/endpoints"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pilot/test/xdstest"
 	"istio.io/istio/pkg/adsc"
@@ -232,6 +236,15 @@ func TestEds(t *testing.T) {
 	t.Run("edsz", func(t *testing.T) {
 		testEdsz(t, s, "test-1.default")
 	})
+	t.Run("edsz simulate", func(t *testing.T) {
+		testEdszSimulate(t, s, "test-1.default")
+	})
+	t.Run("edsz canonical", func(t *testing.T) {
+		testEdszCanonical(t, s)
+	})
+	t.Run("endpoint references", func(t *testing.T) {
+		testEndpointReferences(t, s)
+	})
 	t.Run("LocalityPrioritizedEndpoints", func(t *testing.T) {
 		testLocalityPrioritizedEndpoints(adscConn, adscConn2, t)
 	})
@@ -339,6 +352,73 @@ func TestEdsWeightedServiceEntry(t *testing.T) {
 	}
 }
 
+func TestEdsSidecarEndpointSelection(t *testing.T) {
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{ConfigString: mustReadFile(t, "tests/testdata/config/static-weighted-se.yaml") + `
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: Sidecar
+metadata:
+  name: sidecar
+  namespace: app
+  annotations:
+    networking.istio.io/endpoint-selection: '{"excludeLocalities":["b"]}'
+spec:
+  egress:
+  - hosts: ["*/*"]
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: Sidecar
+metadata:
+  name: sidecar
+  namespace: app2
+  annotations:
+    networking.istio.io/endpoint-selection: '{"egress":[{"hosts":["*/weighted.static.svc.cluster.local"],"excludeLocalities":["a"]},{"hosts":["*/other.static.svc.cluster.local"],"excludeLocalities":["b"]}]}'
+spec:
+  egress:
+  - hosts: ["*/*"]
+`})
+	addresses := func(ns string) []string {
+		var got []string
+		for _, cla := range s.Endpoints(s.SetupProxy(&model.Proxy{ConfigNamespace: ns})) {
+			if cla.ClusterName != "outbound|80||weighted.static.svc.cluster.local" {
+				continue
+			}
+			for _, lbe := range cla.Endpoints {
+				for _, e := range lbe.LbEndpoints {
+					got = append(got, e.GetEndpoint().Address.GetSocketAddress().Address)
+				}
+			}
+		}
+		return slices.Sort(got)
+	}
+	assert.Equal(t, addresses("default"), []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"})
+	// Endpoints of the excluded locality are not sent to the proxies of the Sidecar.
+	assert.Equal(t, addresses("app"), []string{"2.2.2.2", "3.3.3.3"})
+	// Only the egress selections matching the service apply.
+	assert.Equal(t, addresses("app2"), []string{"1.1.1.1"})
+}
+
+func TestEdsXdstpResourceNames(t *testing.T) {
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{ConfigString: mustReadFile(t, "tests/testdata/config/static-weighted-se.yaml")})
+	const cluster = "outbound|80||weighted.static.svc.cluster.local"
+	xdstpName := v3.XdstpResourceName("istiod", v3.EndpointType, cluster)
+	ads := s.ConnectADS().WithType(v3.EndpointType)
+	// Request the endpoints under both names: the second response uses the cached endpoints.
+	for i := 0; i < 2; i++ {
+		res := ads.RequestResponseAck(t, &discovery.DiscoveryRequest{ResourceNames: []string{xdstpName, cluster}})
+		names := []string{}
+		for _, r := range res.Resources {
+			cla := &endpoint.ClusterLoadAssignment{}
+			assert.NoError(t, r.UnmarshalTo(cla))
+			if len(cla.Endpoints) == 0 {
+				t.Fatalf("expected endpoints for %v", cla.ClusterName)
+			}
+			names = append(names, cla.ClusterName)
+		}
+		assert.Equal(t, slices.Sort(names), []string{cluster, xdstpName})
+	}
+}
+
 var (
 	watchEds = []string{v3.ClusterType, v3.EndpointType}
 	watchAll = []string{v3.ClusterType, v3.EndpointType, v3.ListenerType, v3.RouteType}
@@ -1280,3 +1360,110 @@ func testEdsz(t *testing.T, s *xds.FakeDiscoveryServer, proxyID string) {
 		t.Fatal("Mock eds service not found ", statusStr)
 	}
 }
+
+func testEdszCanonical(t *testing.T, s *xds.FakeDiscoveryServer) {
+	edsz := func(query string) (int, string) {
+		rr := httptest.NewRecorder()
+		s.Discovery.Edsz(rr, httptest.NewRequest(http.MethodGet, "/debug/edsz?"+query, nil))
+		return rr.Code, rr.Body.String()
+	}
+
+	// A proxy connected to another istiod is only built from its address.
+	if code, _ := edsz("proxyID=other.default&canonical"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 without the address of a proxy which is not connected, got %d", code)
+	}
+	for _, query := range []string{"proxyID=test-1.default&canonical", "proxyID=other.default&canonical&ip=10.10.10.99"} {
+		code, body := edsz(query)
+		if code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", query, code, body)
+		}
+		clas := []json.RawMessage{}
+		if err := json.Unmarshal([]byte(body), &clas); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(body, "\"outbound|8080||eds.test.svc.cluster.local\"") || !strings.Contains(body, "127.0.0.1") {
+			t.Fatalf("%s: expected the endpoints of the eds service, got %s", query, body)
+		}
+	}
+}
+
+func testEndpointReferences(t *testing.T, s *xds.FakeDiscoveryServer) {
+	mux := http.NewServeMux()
+	internalMux := s.Discovery.InitDebug(mux, false, nil)
+	// Only served through debug over XDS.
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/endpoint_references?ip=127.0.0.1", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 from the debug mux, got %d", rr.Code)
+	}
+	references := func(query string) (int, xds.EndpointReferences) {
+		rr := httptest.NewRecorder()
+		internalMux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/endpoint_references"+query, nil))
+		out := xds.EndpointReferences{}
+		if rr.Code == http.StatusOK {
+			if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rr.Code, out
+	}
+
+	if code, _ := references(""); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without an address, got %d", code)
+	}
+	code, out := references("?ip=127.0.0.1")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if slices.FindFunc(out.Services, func(ref endpoints.ServiceEndpointReference) bool {
+		return ref.Service == "eds.test.svc.cluster.local"
+	}) == nil {
+		t.Fatalf("expected the endpoint of the eds service, got %+v", out.Services)
+	}
+	if slices.FindFunc(out.Clusters, func(ref endpoints.ClusterEndpointReference) bool {
+		return ref.Cluster == "outbound|8080||eds.test.svc.cluster.local"
+	}) == nil {
+		t.Fatalf("expected the cluster of the eds service, got %+v", out.Clusters)
+	}
+	if _, out := references("?ip=10.99.99.99"); len(out.Services) != 0 || len(out.Clusters) != 0 {
+		t.Fatalf("expected no references to an unknown endpoint, got %+v", out)
+	}
+}
+
+func testEdszSimulate(t *testing.T, s *xds.FakeDiscoveryServer, proxyID string) {
+	type simulation struct {
+		Cluster               string                 `json:"cluster"`
+		ClusterLoadAssignment json.RawMessage        `json:"clusterLoadAssignment"`
+		Trace                 *endpoints.FilterTrace `json:"trace"`
+		TrafficPolicy         json.RawMessage        `json:"trafficPolicy"`
+	}
+	simulate := func(cluster string) (int, simulation) {
+		req := httptest.NewRequest(http.MethodGet, "/debug/edsz?proxy="+proxyID+"&cluster="+url.QueryEscape(cluster), nil)
+		rr := httptest.NewRecorder()
+		s.Discovery.Edsz(rr, req)
+		out := simulation{}
+		if rr.Code == http.StatusOK {
+			if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rr.Code, out
+	}
+
+	code, out := simulate("outbound|8080||eds.test.svc.cluster.local")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if out.Cluster != "outbound|8080||eds.test.svc.cluster.local" || out.Trace == nil || len(out.Trace.Stages) == 0 {
+		t.Fatalf("unexpected simulation %+v", out)
+	}
+	if !strings.Contains(string(out.ClusterLoadAssignment), "127.0.0.1") {
+		t.Fatalf("expected the endpoint in the ClusterLoadAssignment, got %s", out.ClusterLoadAssignment)
+	}
+	if out.TrafficPolicy != nil {
+		t.Fatalf("expected no traffic policy without a destination rule, got %s", out.TrafficPolicy)
+	}
+	if code, _ := simulate("outbound|8080||unknown.svc.cluster.local"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown service, got %d", code)
+	}
+}
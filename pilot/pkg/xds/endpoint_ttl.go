@@ -0,0 +1,158 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"sync"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/util/sets"
+)
+
+type endpointTTLKey struct {
+	shard     model.ShardKey
+	hostname  string
+	namespace string
+}
+
+// endpointTTLs tracks when the endpoints of a service shard carrying a TTL were last refreshed.
+// As each endpoint update replaces all the endpoints of the shard, the refresh time is tracked per shard.
+type endpointTTLs struct {
+	mu        sync.Mutex
+	refreshed map[endpointTTLKey]time.Time
+}
+
+func newEndpointTTLs() *endpointTTLs {
+	return &endpointTTLs{refreshed: map[endpointTTLKey]time.Time{}}
+}
+
+// refresh records an endpoint update of the service shard.
+func (t *endpointTTLs) refresh(shard model.ShardKey, hostname, namespace string, istioEndpoints []*model.IstioEndpoint, now time.Time) {
+	key := endpointTTLKey{shard: shard, hostname: hostname, namespace: namespace}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, ep := range istioEndpoints {
+		if ep.TTL > 0 {
+			t.refreshed[key] = now
+			return
+		}
+	}
+	delete(t.refreshed, key)
+}
+
+func (t *endpointTTLs) forget(key endpointTTLKey) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.refreshed, key)
+}
+
+// expire calls fn unless the service shard was refreshed after the given time. As updates record the refresh
+// before changing the endpoint index, this ensures expired endpoints never overwrite a newer update.
+func (t *endpointTTLs) expire(key endpointTTLKey, refreshed time.Time, forget bool, fn func()) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if cur, f := t.refreshed[key]; !f || !cur.Equal(refreshed) {
+		return false
+	}
+	if forget {
+		delete(t.refreshed, key)
+	}
+	fn()
+	return true
+}
+
+func (t *endpointTTLs) snapshot() map[endpointTTLKey]time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[endpointTTLKey]time.Time, len(t.refreshed))
+	for k, v := range t.refreshed {
+		out[k] = v
+	}
+	return out
+}
+
+// expireEndpoints returns the endpoints after applying their TTL, and whether any endpoint changed.
+// Endpoints not refreshed within their TTL are marked unhealthy, and removed after twice their TTL.
+func expireEndpoints(istioEndpoints []*model.IstioEndpoint, refreshed, now time.Time) ([]*model.IstioEndpoint, bool) {
+	age := now.Sub(refreshed)
+	changed := false
+	out := make([]*model.IstioEndpoint, 0, len(istioEndpoints))
+	for _, ep := range istioEndpoints {
+		switch {
+		case ep.TTL <= 0 || age <= ep.TTL:
+			out = append(out, ep)
+		case age <= 2*ep.TTL:
+			if ep.HealthStatus != model.UnHealthy {
+				ep = ep.ShallowCopy()
+				ep.HealthStatus = model.UnHealthy
+				// The precomputed endpoint carries the previous health status.
+				ep.ComputeEnvoyEndpoint(nil)
+				changed = true
+			}
+			out = append(out, ep)
+		default:
+			changed = true
+		}
+	}
+	return out, changed
+}
+
+// expireEndpointTTLs marks unhealthy or removes the endpoints whose TTL expired, and pushes the changes.
+func (s *DiscoveryServer) expireEndpointTTLs(now time.Time) {
+	for key, refreshed := range s.endpointTTLs.snapshot() {
+		shards, f := s.Env.EndpointIndex.ShardsForService(key.hostname, key.namespace)
+		if !f {
+			s.endpointTTLs.forget(key)
+			continue
+		}
+		shards.RLock()
+		current := shards.Shards[key.shard]
+		shards.RUnlock()
+		if len(current) == 0 {
+			s.endpointTTLs.forget(key)
+			continue
+		}
+		istioEndpoints, changed := expireEndpoints(current, refreshed, now)
+		if !changed {
+			continue
+		}
+		// Stop tracking the shard once no endpoint with a TTL remains.
+		forget := true
+		for _, ep := range istioEndpoints {
+			if ep.TTL > 0 {
+				forget = false
+				break
+			}
+		}
+		var pushType model.PushType
+		if !s.endpointTTLs.expire(key, refreshed, forget, func() {
+			pushType = s.Env.EndpointIndex.UpdateServiceEndpoints(key.shard, key.hostname, key.namespace, istioEndpoints)
+		}) {
+			// Refreshed in the meantime.
+			continue
+		}
+		log.Infof("expired endpoints of service %s/%s from shard %v not refreshed since %v",
+			key.namespace, key.hostname, key.shard, refreshed)
+		if pushType == model.IncrementalPush || pushType == model.FullPush {
+			s.ConfigUpdate(&model.PushRequest{
+				Full:           pushType == model.FullPush,
+				ConfigsUpdated: sets.New(model.ConfigKey{Kind: kind.ServiceEntry, Name: key.hostname, Namespace: key.namespace}),
+				Reason:         model.NewReasonStats(model.EndpointUpdate),
+			})
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestExpireEndpointTTLs(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	shard := model.ShardKey{Cluster: "external", Provider: provider.External}
+	hostname, namespace := "ext.example.com", "ns"
+	s.Discovery.EDSUpdate(shard, hostname, namespace, []*model.IstioEndpoint{
+		{Address: "10.0.0.1", EndpointPort: 80, TTL: time.Minute, HealthStatus: model.Healthy},
+		{Address: "10.0.0.2", EndpointPort: 80, HealthStatus: model.Healthy},
+	})
+	refreshed := s.Discovery.endpointTTLs.snapshot()[endpointTTLKey{shard: shard, hostname: hostname, namespace: namespace}]
+	if refreshed.IsZero() {
+		t.Fatalf("expected the shard to be tracked")
+	}
+	current := func() map[string]model.HealthStatus {
+		shards, _ := s.Env().EndpointIndex.ShardsForService(hostname, namespace)
+		out := map[string]model.HealthStatus{}
+		for _, ep := range shards.Shards[shard] {
+			out[ep.Address] = ep.HealthStatus
+		}
+		return out
+	}
+
+	// Within the TTL, nothing changes.
+	s.Discovery.expireEndpointTTLs(refreshed.Add(30 * time.Second))
+	assert.Equal(t, current(), map[string]model.HealthStatus{"10.0.0.1": model.Healthy, "10.0.0.2": model.Healthy})
+
+	// After the TTL, the endpoint is marked unhealthy.
+	s.Discovery.expireEndpointTTLs(refreshed.Add(90 * time.Second))
+	assert.Equal(t, current(), map[string]model.HealthStatus{"10.0.0.1": model.UnHealthy, "10.0.0.2": model.Healthy})
+
+	// After twice the TTL, the endpoint is removed. Endpoints without a TTL are kept.
+	s.Discovery.expireEndpointTTLs(refreshed.Add(3 * time.Minute))
+	assert.Equal(t, current(), map[string]model.HealthStatus{"10.0.0.2": model.Healthy})
+	assert.Equal(t, len(s.Discovery.endpointTTLs.snapshot()), 0)
+}
@@ -0,0 +1,62 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+// accessLogMetadata returns the fields identifying the workload instance of the endpoint for access logs and tracing,
+// in the order they are added to the endpoint. The name and revision of the owning workload are already in the
+// istio workload metadata of the endpoint, only its kind is added.
+func accessLogMetadata(e *model.IstioEndpoint) [][2]string {
+	return [][2]string{
+		{util.IstioInstanceMetadataKey, e.InstanceName},
+		{util.IstioWorkloadKindMetadataKey, e.WorkloadKind},
+	}
+}
+
+// applyAccessLogMetadata adds the name of the workload instance of the endpoint and the kind of its owning workload to
+// its istio metadata, within PILOT_ENDPOINT_ACCESS_LOG_METADATA_MAX_BYTES: the fields which do not fit in the budget
+// are left out.
+func applyAccessLogMetadata(e *model.IstioEndpoint, eep *lbEndpointCopy) {
+	budget := features.EndpointAccessLogMetadataMaxBytes
+	if budget <= 0 {
+		return
+	}
+	var fields map[string]*structpb.Value
+	for _, kv := range accessLogMetadata(e) {
+		size := len(kv[0]) + len(kv[1])
+		if kv[1] == "" || size > budget {
+			continue
+		}
+		budget -= size
+		if fields == nil {
+			fields = map[string]*structpb.Value{}
+		}
+		fields[kv[0]] = structpb.NewStringValue(kv[1])
+	}
+	if len(fields) == 0 {
+		return
+	}
+	istio := istioMetadata(eep.mutable())
+	for k, v := range fields {
+		istio.Fields[k] = v
+	}
+}
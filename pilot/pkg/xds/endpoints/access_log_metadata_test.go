@@ -0,0 +1,62 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestAccessLogMetadata(t *testing.T) {
+	e := &model.IstioEndpoint{
+		InstanceName: "reviews-v2-5d8f7c9b4-x2x7k",
+		WorkloadName: "reviews-v2",
+		WorkloadKind: "Deployment",
+	}
+	build := func(e *model.IstioEndpoint) map[string]string {
+		eep := &lbEndpointCopy{lbEp: &endpoint.LbEndpoint{}}
+		applyAccessLogMetadata(e, eep)
+		out := map[string]string{}
+		for k, v := range eep.lbEp.GetMetadata().GetFilterMetadata()[util.IstioMetadataKey].GetFields() {
+			out[k] = v.GetStringValue()
+		}
+		return out
+	}
+
+	assert.Equal(t, build(e), map[string]string{})
+
+	test.SetForTest(t, &features.EndpointAccessLogMetadataMaxBytes, 1024)
+	assert.Equal(t, build(e), map[string]string{
+		"instance":      "reviews-v2-5d8f7c9b4-x2x7k",
+		"workload_kind": "Deployment",
+	})
+	// Empty fields are left out.
+	assert.Equal(t, build(&model.IstioEndpoint{InstanceName: "vm-1", WorkloadName: "vm"}), map[string]string{
+		"instance": "vm-1",
+	})
+
+	// The fields which do not fit are left out, the next ones are still added if they fit.
+	test.SetForTest(t, &features.EndpointAccessLogMetadataMaxBytes, len("workload_kind")+len("Deployment"))
+	assert.Equal(t, build(e), map[string]string{
+		"workload_kind": "Deployment",
+	})
+}
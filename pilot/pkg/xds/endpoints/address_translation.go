@@ -0,0 +1,65 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"net/netip"
+
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/network"
+)
+
+// translate returns the address at the same offset within the public prefix of the translation. The address must be
+// within its private prefix.
+func translate(t mesh.NetworkAddressTranslation, addr netip.Addr) netip.Addr {
+	out := addr.AsSlice()
+	to := t.Public.Addr().AsSlice()
+	for i := range out {
+		var mask byte
+		switch bits := t.Private.Bits() - i*8; {
+		case bits >= 8:
+			mask = 0xff
+		case bits > 0:
+			mask = byte(0xff << (8 - bits))
+		}
+		out[i] = out[i]&^mask | to[i]&mask
+	}
+	translated, _ := netip.AddrFromSlice(out)
+	return translated
+}
+
+// translateAddress returns the address the proxy connects to for an address of an endpoint, or a waypoint, on the
+// given network. The addresses are translated by the address translations of the network in MeshNetworks, for the
+// proxies of other networks only: the proxies of the network reach the private addresses directly.
+func (b *EndpointBuilder) translateAddress(nw network.ID, address string) string {
+	if nw == "" || nw == b.network || b.push == nil {
+		return address
+	}
+	translations := b.push.NetworkManager().AddressTranslations(nw)
+	if len(translations) == 0 {
+		return address
+	}
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return address
+	}
+	addr = addr.Unmap()
+	for _, t := range translations {
+		if t.Private.Contains(addr) {
+			return translate(t, addr).String()
+		}
+	}
+	return address
+}
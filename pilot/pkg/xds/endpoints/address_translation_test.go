@@ -0,0 +1,92 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"net/netip"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestTranslate(t *testing.T) {
+	cases := []struct {
+		private, public string
+		address, want   string
+	}{
+		{"10.0.0.0/24", "203.0.113.0/24", "10.0.0.2", "203.0.113.2"},
+		{"10.1.0.0/20", "198.51.96.0/20", "10.1.15.7", "198.51.111.7"},
+		{"fd00::/64", "2001:db8::/64", "fd00::1:2", "2001:db8::1:2"},
+	}
+	for _, c := range cases {
+		tr := mesh.NetworkAddressTranslation{Private: netip.MustParsePrefix(c.private), Public: netip.MustParsePrefix(c.public)}
+		assert.Equal(t, translate(tr, netip.MustParseAddr(c.address)).String(), c.want)
+	}
+}
+
+func translatedMesh(t *testing.T) *endpointstest.Mesh {
+	return endpointstest.NewMesh(t, endpointstest.MeshOptions{
+		Services:  1,
+		Endpoints: 2,
+		AddressTranslations: map[string][]mesh.NetworkAddressTranslation{string(endpointstest.Network(0)): {{
+			Private: netip.MustParsePrefix("10.0.0.0/24"),
+			Public:  netip.MustParsePrefix("203.0.113.0/24"),
+		}}},
+	})
+}
+
+func TestTranslateAddress(t *testing.T) {
+	m := translatedMesh(t)
+	addresses := func(proxy *model.Proxy) []string {
+		b := NewEndpointBuilder(endpointstest.ClusterName(0), proxy, m.Push)
+		var out []string
+		for _, llb := range b.BuildClusterLoadAssignment(m.EndpointIndex).Endpoints {
+			for _, lbEp := range llb.LbEndpoints {
+				out = append(out, lbEp.GetEndpoint().GetAddress().GetSocketAddress().GetAddress())
+			}
+		}
+		return out
+	}
+	// The proxies of the network of the endpoints reach them directly.
+	assert.Equal(t, addresses(m.Proxy(0)), []string{"10.0.0.0", "10.0.0.1"})
+	// The proxies of other networks reach them through the NAT.
+	assert.Equal(t, addresses(m.Proxy(1)), []string{"203.0.113.0", "203.0.113.1"})
+}
+
+func TestTranslateTunnelAddress(t *testing.T) {
+	test.SetForTest(t, &features.EnableHBONE, true)
+	m := translatedMesh(t)
+	svc := endpointstest.NewService()
+	proxy := &model.Proxy{Type: model.SidecarProxy, Metadata: &model.NodeMetadata{EnableHBONE: true, Network: "other"}}
+	b := New(endpointstest.OutboundCluster(svc), WithService(svc),
+		WithProxy(proxy), WithPushContext(m.Push)).(*EndpointBuilder)
+
+	direct := buildEnvoyLbEndpoint(b, &model.IstioEndpoint{Address: "10.0.0.1", EndpointPort: 8080, Network: endpointstest.Network(0)}, false)
+	assert.Equal(t, direct.GetEndpoint().GetAddress().GetSocketAddress().GetAddress(), "203.0.113.1")
+
+	tunneled := buildEnvoyLbEndpoint(b, &model.IstioEndpoint{
+		Address: "10.0.0.1", EndpointPort: 8080, Network: endpointstest.Network(0),
+		Labels: map[string]string{model.TunnelLabel: model.TunnelHTTP},
+	}, true)
+	assert.Equal(t, tunneled.GetEndpoint().GetAddress().GetEnvoyInternalAddress().GetEndpointId(), "203.0.113.1:8080")
+	tunnel := tunneled.GetMetadata().GetFilterMetadata()[model.TunnelLabelShortName].GetFields()
+	assert.Equal(t, tunnel["address"].GetStringValue(), "203.0.113.1:15008")
+	assert.Equal(t, tunnel["destination"].GetStringValue(), "203.0.113.1:8080")
+}
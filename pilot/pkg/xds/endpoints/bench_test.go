@@ -0,0 +1,155 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"fmt"
+	"testing"
+
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+var benchmarkMeshes = []endpointstest.MeshOptions{
+	{Services: 100, Endpoints: 1, Networks: 1},
+	{Services: 10, Endpoints: 100, Networks: 1},
+	{Services: 10, Endpoints: 100, Networks: 4},
+	{Services: 1, Endpoints: 1000, Networks: 1},
+	{Services: 1, Endpoints: 1000, Networks: 4},
+}
+
+func TestSyntheticMesh(t *testing.T) {
+	m := endpointstest.NewMesh(t, endpointstest.MeshOptions{Services: 2, Endpoints: 4, Networks: 2})
+	b := NewEndpointBuilder(endpointstest.ClusterName(1), m.Proxy(0), m.Push)
+	cla := b.BuildClusterLoadAssignment(m.EndpointIndex)
+	got := map[string][]string{}
+	for _, llb := range cla.Endpoints {
+		for _, lb := range llb.LbEndpoints {
+			sa := lb.GetEndpoint().GetAddress().GetSocketAddress()
+			got[util.LocalityToString(llb.Locality)] = append(got[util.LocalityToString(llb.Locality)],
+				fmt.Sprintf("%s:%d", sa.GetAddress(), sa.GetPortValue()))
+		}
+	}
+	// The endpoints of the other network are reached through its gateway.
+	assert.Equal(t, got, map[string][]string{
+		endpointstest.Locality(0): {"10.0.0.4:8080", "10.0.0.6:8080"},
+		endpointstest.Locality(1): {"10.255.0.1:15443"},
+	})
+}
+
+// runEndpointBenchmark runs the benchmark for each mesh size, with a builder for each service of the mesh
+// built for a proxy of the first network.
+func runEndpointBenchmark(b *testing.B, f func(b *testing.B, m *endpointstest.Mesh, builders []*EndpointBuilder)) {
+	for _, opts := range benchmarkMeshes {
+		b.Run(fmt.Sprintf("%d/%d/%d", opts.Services, opts.Endpoints, opts.Networks), func(b *testing.B) {
+			m := endpointstest.NewMesh(b, opts)
+			proxy := m.Proxy(0)
+			builders := make([]*EndpointBuilder, 0, opts.Services)
+			for s := 0; s < opts.Services; s++ {
+				builder := NewEndpointBuilder(endpointstest.ClusterName(s), proxy, m.Push)
+				builders = append(builders, &builder)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			f(b, m, builders)
+		})
+	}
+}
+
+func BenchmarkBuildClusterLoadAssignment(b *testing.B) {
+	runEndpointBenchmark(b, func(b *testing.B, m *endpointstest.Mesh, builders []*EndpointBuilder) {
+		for n := 0; n < b.N; n++ {
+			for _, builder := range builders {
+				builder.BuildClusterLoadAssignment(m.EndpointIndex)
+			}
+		}
+	})
+}
+
+func BenchmarkGenerate(b *testing.B) {
+	runEndpointBenchmark(b, func(b *testing.B, m *endpointstest.Mesh, builders []*EndpointBuilder) {
+		eps := make([][]*model.IstioEndpoint, len(builders))
+		for i, builder := range builders {
+			eps[i] = builder.snapshotShards(m.EndpointIndex)
+		}
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			for i, builder := range builders {
+				builder.generate(eps[i], false)
+			}
+		}
+	})
+}
+
+func BenchmarkFilterReason(b *testing.B) {
+	runEndpointBenchmark(b, func(b *testing.B, m *endpointstest.Mesh, builders []*EndpointBuilder) {
+		eps := make([][]*model.IstioEndpoint, len(builders))
+		for i, builder := range builders {
+			eps[i] = builder.snapshotShards(m.EndpointIndex)
+		}
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			for i, builder := range builders {
+				svcPort := builder.servicePort(builder.port)
+				for _, ep := range eps[i] {
+					builder.filterReason(ep, svcPort)
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkEndpointsByNetworkFilter(b *testing.B) {
+	runEndpointBenchmark(b, func(b *testing.B, m *endpointstest.Mesh, builders []*EndpointBuilder) {
+		locEps := make([][]*LocalityEndpoints, len(builders))
+		for i, builder := range builders {
+			locEps[i] = localityEndpoints(builder, builder.snapshotShards(m.EndpointIndex))
+		}
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			for i, builder := range builders {
+				builder.EndpointsByNetworkFilter(locEps[i])
+			}
+		}
+	})
+}
+
+// localityEndpoints groups the endpoints by locality, as generate does before the network filter.
+func localityEndpoints(b *EndpointBuilder, eps []*model.IstioEndpoint) []*LocalityEndpoints {
+	byLocality := map[string]*LocalityEndpoints{}
+	var out []*LocalityEndpoints
+	for _, ep := range eps {
+		eep := buildEnvoyLbEndpoint(b, ep, true)
+		if eep == nil {
+			continue
+		}
+		locEps, f := byLocality[ep.Locality.Label]
+		if !f {
+			locEps = &LocalityEndpoints{
+				llbEndpoints: endpoint.LocalityLbEndpoints{
+					Locality: util.ConvertLocality(ep.Locality.Label),
+				},
+			}
+			byLocality[ep.Locality.Label] = locEps
+			out = append(out, locEps)
+		}
+		locEps.append(ep, eep)
+	}
+	return out
+}
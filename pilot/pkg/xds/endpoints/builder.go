@@ -0,0 +1,221 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"net/netip"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/network"
+)
+
+// Builder builds Envoy endpoints for a single cluster. It is the public entry point for consumers outside of
+// istiod, such as external xDS servers and tests, that want to reuse the endpoint generation logic.
+type Builder interface {
+	model.XdsCacheEntry
+
+	// BuildClusterLoadAssignment builds the ClusterLoadAssignment for the cluster from the endpoints of the source.
+	BuildClusterLoadAssignment(source EndpointSource) *endpoint.ClusterLoadAssignment
+
+	// FromServiceEndpoints builds the LocalityLbEndpoints for the cluster from the push context's ServiceIndex.
+	FromServiceEndpoints() []*endpoint.LocalityLbEndpoints
+
+	// SelectEndpoints returns the endpoints of the cluster from the source, filtered like the
+	// ClusterLoadAssignment and grouped by locality, without their Envoy representation.
+	SelectEndpoints(source EndpointSource) []SelectedLocality
+}
+
+var _ Builder = &EndpointBuilder{}
+
+// EndpointSource provides the endpoints of the services, sharded by registry and cluster.
+type EndpointSource interface {
+	// ShardsForService returns the endpoint shards of the service, if any.
+	ShardsForService(serviceName, namespace string) (*model.EndpointShards, bool)
+}
+
+var _ EndpointSource = &model.EndpointIndex{}
+
+// AmbientLookup is the subset of the push context used to determine whether an endpoint
+// should be reached over an HBONE tunnel.
+type AmbientLookup interface {
+	// SupportsTunnel returns true if the workload with the given network and address supports tunneling.
+	SupportsTunnel(n network.ID, ip string) bool
+	// WaypointsFor returns the addresses of the waypoints on the given network serving the given scope.
+	WaypointsFor(n network.ID, scope model.WaypointScope) []netip.Addr
+	// WaypointScopeFor returns the scope of the waypoint owning the workload, if any.
+	WaypointScopeFor(namespace, serviceAccount string, workloadLabels labels.Instance) (model.WaypointScope, bool)
+}
+
+var _ AmbientLookup = &model.PushContext{}
+
+// noAmbient is used when there is no ambient information available.
+type noAmbient struct{}
+
+func (noAmbient) SupportsTunnel(network.ID, string) bool { return false }
+
+func (noAmbient) WaypointsFor(network.ID, model.WaypointScope) []netip.Addr { return nil }
+
+func (noAmbient) WaypointScopeFor(string, string, labels.Instance) (model.WaypointScope, bool) {
+	return model.WaypointScope{}, false
+}
+
+// Option configures a Builder created by New.
+type Option func(*options)
+
+type options struct {
+	proxy     *model.Proxy
+	push      *model.PushContext
+	ambient   AmbientLookup
+	service   *model.Service
+	dr        *model.ConsolidatedDestRule
+	network   network.ID
+	clusterID cluster.ID
+	locality  *corev3.Locality
+	labels    map[string]string
+	nodeType  model.NodeType
+}
+
+// WithProxy sets the proxy the endpoints are generated for. When set, the proxy identity options
+// (WithNetwork, WithClusterID, WithLocality, WithLabels, WithNodeType) are ignored.
+func WithProxy(proxy *model.Proxy) Option {
+	return func(o *options) {
+		o.proxy = proxy
+	}
+}
+
+// WithPushContext sets the push context used to look up the Service, DestinationRule and policies.
+// If not set, the Service and DestinationRule must be set with WithService and WithDestinationRule, the endpoints
+// have no PeerAuthentication and the default mesh config applies.
+func WithPushContext(push *model.PushContext) Option {
+	return func(o *options) {
+		o.push = push
+	}
+}
+
+// WithAmbient overrides the ambient lookups used to decide whether endpoints support tunneling.
+func WithAmbient(ambient AmbientLookup) Option {
+	return func(o *options) {
+		o.ambient = ambient
+	}
+}
+
+// WithService sets the Service for the cluster, skipping the lookup in the push context.
+func WithService(svc *model.Service) Option {
+	return func(o *options) {
+		o.service = svc
+	}
+}
+
+// WithDestinationRule sets the DestinationRule for the cluster, skipping the lookup in the sidecar scope.
+func WithDestinationRule(dr *model.ConsolidatedDestRule) Option {
+	return func(o *options) {
+		o.dr = dr
+	}
+}
+
+// WithNetwork sets the network of the requesting client.
+func WithNetwork(nw network.ID) Option {
+	return func(o *options) {
+		o.network = nw
+	}
+}
+
+// WithClusterID sets the cluster of the requesting client.
+func WithClusterID(id cluster.ID) Option {
+	return func(o *options) {
+		o.clusterID = id
+	}
+}
+
+// WithLocality sets the locality of the requesting client.
+func WithLocality(locality *corev3.Locality) Option {
+	return func(o *options) {
+		o.locality = locality
+	}
+}
+
+// WithLabels sets the labels of the requesting client, used for failover priority.
+func WithLabels(labels map[string]string) Option {
+	return func(o *options) {
+		o.labels = labels
+	}
+}
+
+// WithNodeType sets the type of the requesting client. Defaults to model.SidecarProxy.
+func WithNodeType(nodeType model.NodeType) Option {
+	return func(o *options) {
+		o.nodeType = nodeType
+	}
+}
+
+// New creates a Builder for the given cluster name. Unlike NewEndpointBuilder, it does not require a
+// connected model.Proxy or an initialized model.PushContext: the client is described by the options instead.
+func New(clusterName string, opts ...Option) Builder {
+	o := &options{nodeType: model.SidecarProxy}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	dir, subsetName, hostname, port := model.ParseSubsetKey(clusterName)
+	svc := o.service
+	if svc == nil && o.push != nil {
+		svc = o.push.ServiceForHostname(o.proxy, hostname)
+	}
+	dr := o.dr
+	if dr == nil && svc != nil && o.proxy != nil && o.proxy.SidecarScope != nil {
+		dr = o.proxy.SidecarScope.DestinationRule(model.TrafficDirectionOutbound, o.proxy, svc.Hostname)
+	}
+
+	var b *EndpointBuilder
+	if o.proxy != nil {
+		b = NewCDSEndpointBuilder(o.proxy, o.push, clusterName, dir, subsetName, hostname, port, svc, dr)
+	} else {
+		b = &EndpointBuilder{
+			clusterName:        clusterName,
+			network:            o.network,
+			proxyView:          model.ProxyViewAll,
+			clusterID:          o.clusterID,
+			locality:           o.locality,
+			service:            svc,
+			clusterLocal:       o.push.IsClusterLocal(svc),
+			nodeType:           o.nodeType,
+			proxyLabels:        o.labels,
+			destinationRule:    dr,
+			cacheKeyExtensions: registeredCacheKeyExtensions(),
+			push:               o.push,
+			subsetName:         subsetName,
+			hostname:           hostname,
+			port:               port,
+			dir:                dir,
+			ambient:            noAmbient{},
+		}
+		if o.push != nil {
+			b.ambient = o.push
+		} else {
+			b.meshSettings = &model.EndpointMeshSettings{LocalityLbSetting: mesh.DefaultMeshConfig().GetLocalityLbSetting()}
+		}
+		b.populate()
+	}
+	if o.ambient != nil {
+		b.ambient = o.ambient
+	}
+	return b
+}
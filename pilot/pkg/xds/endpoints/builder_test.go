@@ -0,0 +1,77 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+)
+
+func TestNew(t *testing.T) {
+	svc := endpointstest.NewService()
+	index := endpointstest.NewIndex(svc, endpointstest.NewEndpoint("10.0.0.1"), endpointstest.NewEndpoint("10.0.0.2"))
+
+	b := New(endpointstest.OutboundCluster(svc),
+		WithService(svc),
+		WithClusterID(endpointstest.ClusterID),
+	)
+	if !b.Cacheable() {
+		t.Fatalf("expected builder with a service to be cacheable")
+	}
+	cla := b.BuildClusterLoadAssignment(index)
+	if len(cla.Endpoints) != 1 {
+		t.Fatalf("expected 1 locality, got %d", len(cla.Endpoints))
+	}
+	if got := len(cla.Endpoints[0].LbEndpoints); got != 2 {
+		t.Fatalf("expected 2 endpoints, got %d", got)
+	}
+
+	// The endpoints may come from any EndpointSource.
+	shards, _ := index.ShardsForService(string(svc.Hostname), "ns")
+	if cla := b.BuildClusterLoadAssignment(staticSource{shards}); len(cla.Endpoints[0].LbEndpoints) != 2 {
+		t.Fatalf("expected 2 endpoints from the static source, got %v", cla.Endpoints)
+	}
+
+	// Without a proxy, the endpoints discoverable from their cluster only are filtered by the cluster of the client.
+	remote := endpointstest.NewEndpoint("10.0.0.3")
+	remote.Locality.ClusterID = "c2"
+	remote.DiscoverabilityPolicy = model.DiscoverableFromSameCluster
+	index.UpdateServiceEndpoints(model.ShardKey{Cluster: "c2", Provider: provider.Kubernetes}, string(svc.Hostname), "ns",
+		[]*model.IstioEndpoint{remote})
+	cla = b.BuildClusterLoadAssignment(index)
+	if got := len(cla.Endpoints[0].LbEndpoints); got != 2 {
+		t.Fatalf("expected 2 endpoints, got %d", got)
+	}
+
+	// Without a service, nothing is generated.
+	empty := New("outbound|80||unknown.ns.svc.cluster.local")
+	if empty.Cacheable() {
+		t.Fatalf("expected builder without a service to not be cacheable")
+	}
+	if cla := empty.BuildClusterLoadAssignment(index); len(cla.Endpoints) != 0 {
+		t.Fatalf("expected no endpoints, got %v", cla.Endpoints)
+	}
+}
+
+type staticSource struct {
+	shards *model.EndpointShards
+}
+
+func (s staticSource) ShardsForService(string, string) (*model.EndpointShards, bool) {
+	return s.shards, s.shards != nil
+}
@@ -0,0 +1,83 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/util/hash"
+)
+
+// CacheKeyExtension extends the cache key of the ClusterLoadAssignments, for extended builds generating the endpoints
+// from inputs the EndpointBuilder does not know of, such as additional node metadata of the proxy. Without it, the
+// proxies differing only by those inputs would share the same cached ClusterLoadAssignments.
+type CacheKeyExtension interface {
+	// WriteHash writes the inputs the endpoints of the service generated for the proxy depend on. The service may
+	// be nil, and so may the proxy for the builders created by New without one.
+	WriteHash(h hash.Hash, proxy *model.Proxy, svc *model.Service)
+	// DependentConfigs returns the additional configs whose updates invalidate the endpoints of the service
+	// generated for the proxy.
+	DependentConfigs(proxy *model.Proxy, svc *model.Service) []model.ConfigHash
+}
+
+// cacheKeyExtensionRegistry holds the registered cache key extensions, by name, and their snapshot ordered by name
+// so the cache key is stable. The snapshot is taken at registration, as the extensions are read by every builder.
+var cacheKeyExtensionRegistry = struct {
+	sync.Mutex
+	byName map[string]CacheKeyExtension
+	sorted atomic.Pointer[[]CacheKeyExtension]
+}{
+	byName: map[string]CacheKeyExtension{},
+}
+
+// RegisterCacheKeyExtension registers an extension of the cache key of the ClusterLoadAssignments, replacing the
+// extension previously registered with the same name. It is meant to be called at initialization, before the
+// endpoints are generated: the ClusterLoadAssignments already cached are not invalidated.
+func RegisterCacheKeyExtension(name string, extension CacheKeyExtension) {
+	cacheKeyExtensionRegistry.Lock()
+	defer cacheKeyExtensionRegistry.Unlock()
+	cacheKeyExtensionRegistry.byName[name] = extension
+	snapshotCacheKeyExtensions()
+}
+
+// unregisterCacheKeyExtension removes the extension registered with the name.
+func unregisterCacheKeyExtension(name string) {
+	cacheKeyExtensionRegistry.Lock()
+	defer cacheKeyExtensionRegistry.Unlock()
+	delete(cacheKeyExtensionRegistry.byName, name)
+	snapshotCacheKeyExtensions()
+}
+
+// snapshotCacheKeyExtensions snapshots the registered extensions ordered by name. The registry must be locked.
+func snapshotCacheKeyExtensions() {
+	var extensions []CacheKeyExtension
+	for _, name := range slices.Sort(maps.Keys(cacheKeyExtensionRegistry.byName)) {
+		extensions = append(extensions, cacheKeyExtensionRegistry.byName[name])
+	}
+	cacheKeyExtensionRegistry.sorted.Store(&extensions)
+}
+
+// registeredCacheKeyExtensions returns the registered cache key extensions, ordered by name. The slice is shared and
+// must not be modified.
+func registeredCacheKeyExtensions() []CacheKeyExtension {
+	if extensions := cacheKeyExtensionRegistry.sorted.Load(); extensions != nil {
+		return *extensions
+	}
+	return nil
+}
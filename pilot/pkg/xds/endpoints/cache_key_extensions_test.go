@@ -0,0 +1,63 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/util/hash"
+)
+
+// labelCacheKeyExtension is an extension generating the endpoints from a label of the proxy.
+type labelCacheKeyExtension struct {
+	label string
+}
+
+func (e labelCacheKeyExtension) WriteHash(h hash.Hash, proxy *model.Proxy, _ *model.Service) {
+	h.Write([]byte(proxy.Labels[e.label]))
+}
+
+func (e labelCacheKeyExtension) DependentConfigs(_ *model.Proxy, svc *model.Service) []model.ConfigHash {
+	return []model.ConfigHash{model.ConfigKey{Kind: kind.EnvoyFilter, Name: e.label, Namespace: svc.Attributes.Namespace}.HashCode()}
+}
+
+func TestCacheKeyExtensions(t *testing.T) {
+	svc := endpointstest.NewService()
+	build := func(labels map[string]string) *EndpointBuilder {
+		proxy := &model.Proxy{Labels: labels, Metadata: &model.NodeMetadata{ClusterID: endpointstest.ClusterID}}
+		return New(endpointstest.OutboundCluster(svc), WithService(svc), WithProxy(proxy)).(*EndpointBuilder)
+	}
+	a := map[string]string{"app": "client", "tier": "a"}
+	b := map[string]string{"app": "client", "tier": "b"}
+	// Without extension, the proxies share the cache key.
+	assert.Equal(t, build(a).Key(), build(b).Key())
+	before := build(a).DependentConfigs()
+
+	RegisterCacheKeyExtension("tier", labelCacheKeyExtension{label: "tier"})
+	t.Cleanup(func() {
+		unregisterCacheKeyExtension("tier")
+	})
+	assert.Equal(t, build(a).Key() != build(b).Key(), true)
+	assert.Equal(t, build(a).Key(), build(map[string]string{"app": "other", "tier": "a"}).Key())
+	configs := build(a).DependentConfigs()
+	assert.Equal(t, len(configs), len(before)+1)
+	assert.Equal(t, slices.Contains(configs,
+		model.ConfigKey{Kind: kind.EnvoyFilter, Name: "tier", Namespace: "ns"}.HashCode()), true)
+}
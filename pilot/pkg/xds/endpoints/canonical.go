@@ -0,0 +1,116 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"bytes"
+	"cmp"
+	"sort"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"google.golang.org/protobuf/proto"
+
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+// CanonicalizeClusterLoadAssignment returns the canonical form of a ClusterLoadAssignment, whose localities are
+// sorted by priority and locality, and whose endpoints are sorted by address. Empty filter metadata is dropped.
+// Two ClusterLoadAssignments with the same endpoints have the same canonical form, whatever the order the
+// endpoints were discovered in or the istiod revision that built them.
+// The input is not modified: localities are copied, and endpoints are cloned only when their metadata changes.
+func CanonicalizeClusterLoadAssignment(cla *endpoint.ClusterLoadAssignment) *endpoint.ClusterLoadAssignment {
+	if cla == nil {
+		return nil
+	}
+	out := util.CloneClusterLoadAssignment(cla)
+	for _, llb := range out.Endpoints {
+		lbEps := make([]*endpoint.LbEndpoint, 0, len(llb.LbEndpoints))
+		for _, lbEp := range llb.LbEndpoints {
+			lbEps = append(lbEps, canonicalMetadata(lbEp))
+		}
+		sort.SliceStable(lbEps, func(i, j int) bool {
+			return compareLbEndpoints(lbEps[i], lbEps[j]) < 0
+		})
+		llb.LbEndpoints = lbEps
+	}
+	sort.SliceStable(out.Endpoints, func(i, j int) bool {
+		a, b := out.Endpoints[i], out.Endpoints[j]
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		return util.LocalityToString(a.Locality) < util.LocalityToString(b.Locality)
+	})
+	return out
+}
+
+// canonicalMetadata returns the endpoint without its empty filter metadata. The ClusterLoadAssignment is not
+// modified, so the endpoint is cloned before being modified.
+func canonicalMetadata(lbEp *endpoint.LbEndpoint) *endpoint.LbEndpoint {
+	md := lbEp.GetMetadata()
+	if md == nil {
+		return lbEp
+	}
+	changed := len(md.FilterMetadata) == 0 && len(md.TypedFilterMetadata) == 0
+	for _, s := range md.FilterMetadata {
+		if len(s.GetFields()) == 0 {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return lbEp
+	}
+	lbEp = proto.Clone(lbEp).(*endpoint.LbEndpoint)
+	for key, s := range lbEp.Metadata.FilterMetadata {
+		if len(s.GetFields()) == 0 {
+			delete(lbEp.Metadata.FilterMetadata, key)
+		}
+	}
+	if len(lbEp.Metadata.FilterMetadata) == 0 && len(lbEp.Metadata.TypedFilterMetadata) == 0 {
+		lbEp.Metadata = nil
+	}
+	return lbEp
+}
+
+// compareLbEndpoints orders endpoints by address and port. Endpoints with the same address, such as the endpoints
+// tunneled through the same gateway, are ordered by their deterministic encoding.
+func compareLbEndpoints(a, b *endpoint.LbEndpoint) int {
+	addrA, addrB := a.GetEndpoint().GetAddress(), b.GetEndpoint().GetAddress()
+	if c := cmp.Compare(addressKey(addrA), addressKey(addrB)); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(addrA.GetSocketAddress().GetPortValue(), addrB.GetSocketAddress().GetPortValue()); c != 0 {
+		return c
+	}
+	return bytes.Compare(deterministicBytes(a), deterministicBytes(b))
+}
+
+func addressKey(addr *core.Address) string {
+	switch a := addr.GetAddress().(type) {
+	case *core.Address_SocketAddress:
+		return a.SocketAddress.GetAddress()
+	case *core.Address_Pipe:
+		return a.Pipe.GetPath()
+	case *core.Address_EnvoyInternalAddress:
+		return a.EnvoyInternalAddress.GetServerListenerName() + "/" + a.EnvoyInternalAddress.GetEndpointId()
+	}
+	return ""
+}
+
+func deterministicBytes(m proto.Message) []byte {
+	b, _ := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	return b
+}
@@ -0,0 +1,86 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"fmt"
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestCanonicalizeClusterLoadAssignment(t *testing.T) {
+	lbEp := func(addr string, port uint32, md *core.Metadata) *endpoint.LbEndpoint {
+		return &endpoint.LbEndpoint{
+			HostIdentifier: &endpoint.LbEndpoint_Endpoint{Endpoint: &endpoint.Endpoint{Address: util.BuildAddress(addr, port)}},
+			Metadata:       md,
+		}
+	}
+	emptyMetadata := &core.Metadata{FilterMetadata: map[string]*structpb.Struct{util.IstioMetadataKey: {}}}
+	shared := lbEp("10.0.0.2", 80, emptyMetadata)
+	cla := &endpoint.ClusterLoadAssignment{
+		ClusterName: "outbound|80||reviews.default.svc.cluster.local",
+		Endpoints: []*endpoint.LocalityLbEndpoints{
+			{
+				Locality:    &core.Locality{Region: "us-west"},
+				Priority:    1,
+				LbEndpoints: []*endpoint.LbEndpoint{lbEp("10.0.0.3", 80, nil)},
+			},
+			{
+				Locality:    &core.Locality{Region: "us-east"},
+				LbEndpoints: []*endpoint.LbEndpoint{shared, lbEp("10.0.0.10", 80, nil), lbEp("10.0.0.1", 8080, nil), lbEp("10.0.0.1", 80, nil)},
+			},
+			{
+				Locality:    &core.Locality{Region: "eu-west"},
+				LbEndpoints: []*endpoint.LbEndpoint{lbEp("10.0.0.4", 80, &core.Metadata{})},
+			},
+		},
+	}
+	original := proto.Clone(cla)
+
+	got := CanonicalizeClusterLoadAssignment(cla)
+	// The input may hold precomputed endpoints, it must not be modified.
+	assert.Equal(t, cla, original.(*endpoint.ClusterLoadAssignment))
+
+	var eps []string
+	for _, llb := range got.Endpoints {
+		for _, lbEp := range llb.LbEndpoints {
+			addr := lbEp.GetEndpoint().GetAddress().GetSocketAddress()
+			eps = append(eps, fmt.Sprintf("%s/%d %s:%d metadata=%v",
+				llb.Locality.Region, llb.Priority, addr.GetAddress(), addr.GetPortValue(), lbEp.Metadata != nil))
+		}
+	}
+	assert.Equal(t, eps, []string{
+		"eu-west/0 10.0.0.4:80 metadata=false",
+		"us-east/0 10.0.0.1:80 metadata=false",
+		"us-east/0 10.0.0.1:8080 metadata=false",
+		"us-east/0 10.0.0.10:80 metadata=false",
+		"us-east/0 10.0.0.2:80 metadata=false",
+		"us-west/1 10.0.0.3:80 metadata=false",
+	})
+
+	// The canonical form does not depend on the order of the input.
+	reversed := proto.Clone(cla).(*endpoint.ClusterLoadAssignment)
+	for i, j := 0, len(reversed.Endpoints)-1; i < j; i, j = i+1, j-1 {
+		reversed.Endpoints[i], reversed.Endpoints[j] = reversed.Endpoints[j], reversed.Endpoints[i]
+	}
+	assert.Equal(t, CanonicalizeClusterLoadAssignment(reversed), got)
+}
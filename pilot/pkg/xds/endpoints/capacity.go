@@ -0,0 +1,56 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+// applyCapacity adds the requests per second budget of the workload of the endpoint to its load balancer metadata, if
+// it has one.
+func applyCapacity(e *model.IstioEndpoint, eep *lbEndpointCopy) {
+	if e.CapacityRPS == 0 {
+		return
+	}
+	lbMetadata(eep.mutable()).Fields[util.LbCapacityRPSMetadataKey] = structpb.NewNumberValue(float64(e.CapacityRPS))
+}
+
+// applyCapacityLocalityWeights sets the weight of each locality to the sum of the requests per second budgets of its
+// endpoints, if all the endpoints have one. Otherwise, the budgets are not comparable to the weights of the endpoints
+// without one, and the localities are left untouched.
+func applyCapacityLocalityWeights(locEps []*LocalityEndpoints) {
+	weights := make([]uint32, len(locEps))
+	for i, locLbEps := range locEps {
+		for j, ep := range locLbEps.istioEndpoints {
+			if ep.CapacityRPS == 0 {
+				return
+			}
+			// The alternate waypoint endpoints of a workload follow it, its budget is only counted once.
+			if j > 0 && locLbEps.istioEndpoints[j-1] == ep {
+				continue
+			}
+			weights[i], _ = addUint32(weights[i], ep.CapacityRPS)
+		}
+	}
+	for i, locLbEps := range locEps {
+		if weights[i] > 0 {
+			locLbEps.llbEndpoints.LoadBalancingWeight = &wrapperspb.UInt32Value{Value: weights[i]}
+		}
+	}
+}
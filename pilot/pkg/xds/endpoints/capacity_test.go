@@ -0,0 +1,77 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestCapacity(t *testing.T) {
+	type capacity struct {
+		// Weights is the weight of each locality, CapacityRPS the budget of each endpoint in its metadata.
+		Weights     map[string]uint32
+		CapacityRPS map[string]float64
+	}
+	build := func(capacities map[string]uint32) capacity {
+		svc := endpointstest.NewService()
+		var eps []*model.IstioEndpoint
+		for address, locality := range map[string]string{"10.0.0.1": "r1/z1", "10.0.0.2": "r1/z1", "10.0.0.3": "r1/z2"} {
+			ep := endpointstest.NewEndpoint(address)
+			ep.Locality.Label = locality
+			ep.HealthStatus = model.Healthy
+			ep.CapacityRPS = capacities[address]
+			eps = append(eps, ep)
+		}
+		index := endpointstest.NewIndex(svc, eps...)
+		b := New(endpointstest.OutboundCluster(svc), WithService(svc), WithClusterID(endpointstest.ClusterID))
+		out := capacity{Weights: map[string]uint32{}, CapacityRPS: map[string]float64{}}
+		for _, llb := range b.BuildClusterLoadAssignment(index).Endpoints {
+			out.Weights[llb.GetLocality().GetZone()] = llb.GetLoadBalancingWeight().GetValue()
+			for _, lbEp := range llb.LbEndpoints {
+				lb := lbEp.GetMetadata().GetFilterMetadata()[util.EnvoyLbMetadataKey].GetFields()
+				if v, f := lb[util.LbCapacityRPSMetadataKey]; f {
+					out.CapacityRPS[lbEp.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()] = v.GetNumberValue()
+				}
+			}
+		}
+		return out
+	}
+	all := map[string]uint32{"10.0.0.1": 100, "10.0.0.2": 300, "10.0.0.3": 50}
+	partial := map[string]uint32{"10.0.0.1": 100, "10.0.0.3": 50}
+
+	// The budgets are always added to the metadata.
+	assert.Equal(t, build(all), capacity{
+		Weights:     map[string]uint32{"z1": 2, "z2": 1},
+		CapacityRPS: map[string]float64{"10.0.0.1": 100, "10.0.0.2": 300, "10.0.0.3": 50},
+	})
+
+	test.SetForTest(t, &features.EnableCapacityLocalityWeights, true)
+	assert.Equal(t, build(all), capacity{
+		Weights:     map[string]uint32{"z1": 400, "z2": 50},
+		CapacityRPS: map[string]float64{"10.0.0.1": 100, "10.0.0.2": 300, "10.0.0.3": 50},
+	})
+	// The budgets are only aggregated if all the endpoints have one.
+	assert.Equal(t, build(partial), capacity{
+		Weights:     map[string]uint32{"z1": 2, "z2": 1},
+		CapacityRPS: map[string]float64{"10.0.0.1": 100, "10.0.0.3": 50},
+	})
+}
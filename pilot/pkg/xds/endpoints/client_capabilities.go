@@ -0,0 +1,111 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"strings"
+	"sync"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// ClientCapability is a feature of the ClusterLoadAssignments that xDS clients other than Envoy, such as gRPC-Go or
+// gRPC-Java, may not support. The endpoints are generated without the features the client does not support.
+type ClientCapability string
+
+const (
+	// CapabilityTunnel is the support of HBONE: endpoints with internal addresses and tunnel metadata.
+	CapabilityTunnel ClientCapability = "tunnel"
+	// CapabilityEndpointMetadata is the support of the endpoint metadata, holding the telemetry labels, the transport
+	// socket matches and the load balancer metadata, such as the ring hash keys and subsets.
+	CapabilityEndpointMetadata ClientCapability = "endpoint_metadata"
+)
+
+// allClientCapabilities are the capabilities of Envoy, the default client.
+var allClientCapabilities = []ClientCapability{CapabilityTunnel, CapabilityEndpointMetadata}
+
+// capabilityRegistry holds the capabilities of the xDS clients, by the GENERATOR of their node metadata.
+var capabilityRegistry = struct {
+	sync.RWMutex
+	byGenerator map[string][]ClientCapability
+}{
+	byGenerator: map[string][]ClientCapability{
+		// Proxyless gRPC does not support tunneling.
+		"grpc": {CapabilityEndpointMetadata},
+	},
+}
+
+// RegisterClientCapabilities sets the capabilities of the xDS clients with the given GENERATOR node metadata. Clients
+// can also list their capabilities in their XDS_CAPABILITIES node metadata, which takes precedence.
+func RegisterClientCapabilities(generator string, capabilities ...ClientCapability) {
+	capabilityRegistry.Lock()
+	defer capabilityRegistry.Unlock()
+	capabilityRegistry.byGenerator[generator] = capabilities
+}
+
+// clientCapabilities are the capabilities of a client. The zero value supports all the capabilities.
+type clientCapabilities struct {
+	unsupported sets.Set[ClientCapability]
+	// key identifies the unsupported capabilities in the cache key of the endpoints.
+	key string
+}
+
+func (c clientCapabilities) has(capability ClientCapability) bool {
+	return !c.unsupported.Contains(capability)
+}
+
+// clientCapabilitiesFor returns the capabilities of the proxy, from its node metadata or the registry.
+func clientCapabilitiesFor(proxy *model.Proxy) clientCapabilities {
+	if proxy == nil || proxy.Metadata == nil {
+		return clientCapabilities{}
+	}
+	var supported []ClientCapability
+	if len(proxy.Metadata.XdsCapabilities) > 0 {
+		for _, c := range proxy.Metadata.XdsCapabilities {
+			supported = append(supported, ClientCapability(c))
+		}
+	} else {
+		capabilityRegistry.RLock()
+		registered, f := capabilityRegistry.byGenerator[proxy.Metadata.Generator]
+		capabilityRegistry.RUnlock()
+		if !f {
+			return clientCapabilities{}
+		}
+		supported = registered
+	}
+	unsupported := sets.New(allClientCapabilities...).DeleteAll(supported...)
+	if unsupported.IsEmpty() {
+		return clientCapabilities{}
+	}
+	keys := slices.Map(sets.SortedList(unsupported), func(c ClientCapability) string { return string(c) })
+	return clientCapabilities{unsupported: unsupported, key: strings.Join(keys, ",")}
+}
+
+// applyClientCapabilities removes the endpoint metadata if the client does not support it. It is applied once the
+// endpoints are filtered, as the filters rely on the metadata.
+func (b *EndpointBuilder) applyClientCapabilities(locEps []*LocalityEndpoints) {
+	if b.capabilities.has(CapabilityEndpointMetadata) {
+		return
+	}
+	for _, locLbEps := range locEps {
+		for i, eep := range locLbEps.llbEndpoints.LbEndpoints {
+			if eep.Metadata != nil {
+				locLbEps.mutable(i).Metadata = nil
+			}
+		}
+	}
+}
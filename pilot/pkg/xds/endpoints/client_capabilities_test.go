@@ -0,0 +1,65 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestClientCapabilities(t *testing.T) {
+	RegisterClientCapabilities("custom")
+	t.Cleanup(func() {
+		capabilityRegistry.Lock()
+		delete(capabilityRegistry.byGenerator, "custom")
+		capabilityRegistry.Unlock()
+	})
+	cases := []struct {
+		name       string
+		metadata   *model.NodeMetadata
+		key        string
+		tunnel     bool
+		lbMetadata bool
+	}{
+		{name: "envoy", metadata: &model.NodeMetadata{}, key: "", tunnel: true, lbMetadata: true},
+		{name: "grpc", metadata: &model.NodeMetadata{Generator: "grpc"}, key: "tunnel", tunnel: false, lbMetadata: true},
+		{name: "registered", metadata: &model.NodeMetadata{Generator: "custom"}, key: "endpoint_metadata,tunnel"},
+		{
+			name:     "explicit",
+			metadata: &model.NodeMetadata{Generator: "grpc", XdsCapabilities: []string{"tunnel", "unknown"}},
+			key:      "endpoint_metadata", tunnel: true,
+		},
+	}
+	svc := endpointstest.NewService()
+	ep := endpointstest.NewEndpoint("10.0.0.1")
+	ep.HealthStatus = model.Healthy
+	index := endpointstest.NewIndex(svc, ep)
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := &model.Proxy{Type: model.SidecarProxy, Metadata: tt.metadata}
+			b := New(endpointstest.OutboundCluster(svc), WithProxy(proxy), WithService(svc)).(*EndpointBuilder)
+			assert.Equal(t, b.capabilities.key, tt.key)
+			assert.Equal(t, b.capabilities.has(CapabilityTunnel), tt.tunnel)
+			assert.Equal(t, b.capabilities.has(CapabilityEndpointMetadata), tt.lbMetadata)
+
+			lbEps := b.BuildClusterLoadAssignment(index).Endpoints[0].LbEndpoints
+			assert.Equal(t, len(lbEps), 1)
+			assert.Equal(t, lbEps[0].Metadata != nil, tt.lbMetadata)
+		})
+	}
+}
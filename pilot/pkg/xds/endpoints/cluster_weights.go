@@ -0,0 +1,71 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/cluster"
+)
+
+// applyClusterWeights weights the endpoints so that, in each endpoint group, the endpoints of each cluster receive the
+// share of the traffic set by the networking.istio.io/cluster-weights annotation of the DestinationRule. Within each
+// cluster, the endpoints keep their relative weights, across localities too.
+func (b *EndpointBuilder) applyClusterWeights(locEps []*LocalityEndpoints) {
+	weights, ok := model.ClusterWeights(b.destinationRule.GetRule())
+	if !ok {
+		return
+	}
+	byGroup := map[endpointGroup][]*LocalityEndpoints{}
+	for _, locLbEps := range locEps {
+		byGroup[locLbEps.group] = append(byGroup[locLbEps.group], locLbEps)
+	}
+	for _, groupEps := range byGroup {
+		applyPriorityClusterWeights(groupEps, weights)
+	}
+}
+
+func applyPriorityClusterWeights(locEps []*LocalityEndpoints, weights map[cluster.ID]uint32) {
+	clusterWeights := map[cluster.ID]float64{}
+	var total float64
+	for _, locLbEps := range locEps {
+		for i, ep := range locLbEps.istioEndpoints {
+			w := float64(locLbEps.llbEndpoints.LbEndpoints[i].GetLoadBalancingWeight().GetValue())
+			clusterWeights[ep.Locality.ClusterID] += w
+			total += w
+		}
+	}
+	var shares float64
+	for clusterID := range clusterWeights {
+		shares += float64(weights[clusterID])
+	}
+	if shares == 0 {
+		// None of the weighted clusters has endpoints in this group, keep their weights.
+		return
+	}
+	for _, locLbEps := range locEps {
+		for i, eep := range locLbEps.llbEndpoints.LbEndpoints {
+			clusterID := locLbEps.istioEndpoints[i].Locality.ClusterID
+			weight := uint32(1)
+			if cw := clusterWeights[clusterID]; cw > 0 {
+				weight = util.ScaleWeight(float64(eep.GetLoadBalancingWeight().GetValue()),
+					total*rolloutResolution*float64(weights[clusterID])/shares/cw)
+			}
+			locLbEps.mutable(i).LoadBalancingWeight = &wrapperspb.UInt32Value{Value: weight}
+		}
+	}
+}
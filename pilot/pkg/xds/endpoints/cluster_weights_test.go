@@ -0,0 +1,71 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestApplyClusterWeights(t *testing.T) {
+	ep := func(clusterID cluster.ID) *model.IstioEndpoint {
+		return &model.IstioEndpoint{Locality: model.Locality{ClusterID: clusterID}}
+	}
+	// weights builds the endpoints of the given clusters, a locality per element of localities, and returns their
+	// weights by locality.
+	weights := func(annotation string, localities ...[]cluster.ID) [][]uint32 {
+		var locEps []*LocalityEndpoints
+		for i, clusters := range localities {
+			locLbEps := &LocalityEndpoints{}
+			locLbEps.group = endpointGroup(i / 2)
+			for _, c := range clusters {
+				locLbEps.append(ep(c), lbEndpoint("10.0.0.1", 8080, 1))
+			}
+			locEps = append(locEps, locLbEps)
+		}
+		b := &EndpointBuilder{destinationRule: model.ConvertConsolidatedDestRule(&config.Config{
+			Meta: config.Meta{Name: "dr", Namespace: "default", Annotations: map[string]string{constants.ClusterWeightsAnnotation: annotation}},
+			Spec: &networking.DestinationRule{Host: "reviews"},
+		})}
+		b.applyClusterWeights(locEps)
+		var out [][]uint32
+		for _, locLbEps := range locEps {
+			var w []uint32
+			for _, eep := range locLbEps.llbEndpoints.LbEndpoints {
+				w = append(w, eep.GetLoadBalancingWeight().GetValue())
+			}
+			out = append(out, w)
+		}
+		return out
+	}
+
+	// Without the annotation, the weights are kept.
+	assert.Equal(t, weights("", []cluster.ID{"a", "b", "b", "b"}), [][]uint32{{1, 1, 1, 1}})
+	// Cluster a gets 80% of the traffic, even though cluster b has more endpoints.
+	assert.Equal(t, weights("a=80,b=20", []cluster.ID{"a", "b", "b", "b"}), [][]uint32{{3200, 267, 267, 267}})
+	// The share of a cluster is spread across its localities.
+	assert.Equal(t, weights("a=80,b=20", []cluster.ID{"a", "b"}, []cluster.ID{"a"}), [][]uint32{{1200, 600}, {1200}})
+	// The clusters weighted zero, or not listed, keep a marginal weight.
+	assert.Equal(t, weights("a=100,b=0", []cluster.ID{"a", "b", "c"}), [][]uint32{{3000, 1, 1}})
+	// Each endpoint group is split independently, and kept if none of its clusters is weighted.
+	assert.Equal(t, weights("a=50,b=50", []cluster.ID{"a", "b", "b"}, nil, []cluster.ID{"c", "c"}),
+		[][]uint32{{1500, 750, 750}, nil, {1, 1}})
+}
@@ -0,0 +1,66 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+// applyCohort adds the cohort of the endpoint to its load balancer metadata, if the service partitions
+// its endpoints into cohorts. The cohort of an endpoint is the value of PILOT_ENDPOINT_COHORT_LABEL on its workload;
+// the clusters of the service select the cohort requested through the PILOT_ENDPOINT_COHORT_HEADER header.
+func (b *EndpointBuilder) applyCohort(e *model.IstioEndpoint, eep *lbEndpointCopy) {
+	if !features.EnableEndpointCohorts || features.EndpointCohortLabel == "" ||
+		b.service.Attributes.Labels[features.EndpointCohortLabel] == "" {
+		return
+	}
+	cohort := e.Labels[features.EndpointCohortLabel]
+	if cohort == "" {
+		return
+	}
+	lbMetadata(eep.mutable()).Fields[util.LbCohortMetadataKey] = structpb.NewStringValue(cohort)
+}
+
+// lbMetadata returns the load balancer metadata of the endpoint, adding it if missing.
+func lbMetadata(eep *endpoint.LbEndpoint) *structpb.Struct {
+	return filterMetadata(eep, util.EnvoyLbMetadataKey)
+}
+
+// istioMetadata returns the istio metadata of the endpoint, adding it if missing.
+func istioMetadata(eep *endpoint.LbEndpoint) *structpb.Struct {
+	return filterMetadata(eep, util.IstioMetadataKey)
+}
+
+// filterMetadata returns the filter metadata of the endpoint under the given key, adding it if missing.
+func filterMetadata(eep *endpoint.LbEndpoint, key string) *structpb.Struct {
+	if eep.Metadata == nil {
+		eep.Metadata = &corev3.Metadata{}
+	}
+	if eep.Metadata.FilterMetadata == nil {
+		eep.Metadata.FilterMetadata = map[string]*structpb.Struct{}
+	}
+	md := eep.Metadata.FilterMetadata[key]
+	if md == nil {
+		md = &structpb.Struct{Fields: map[string]*structpb.Value{}}
+		eep.Metadata.FilterMetadata[key] = md
+	}
+	return md
+}
@@ -0,0 +1,51 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestApplyCohort(t *testing.T) {
+	cohortOf := func(b *EndpointBuilder, labels map[string]string) string {
+		c := &lbEndpointCopy{lbEp: lbEndpoint("10.0.0.1", 8080, 1)}
+		withLbPortMetadata(c.mutable(), 80)
+		b.applyCohort(&model.IstioEndpoint{Labels: labels}, c)
+		eep := c.lbEp
+		// Other load balancer metadata is kept.
+		assert.Equal(t, eep.Metadata.FilterMetadata[util.EnvoyLbMetadataKey].Fields[util.LbPortMetadataKey].GetStringValue(), "80")
+		return eep.Metadata.FilterMetadata[util.EnvoyLbMetadataKey].Fields[util.LbCohortMetadataKey].GetStringValue()
+	}
+	cohortService := &EndpointBuilder{service: &model.Service{
+		Attributes: model.ServiceAttributes{Labels: map[string]string{"istio.io/cohort": "enabled"}},
+	}}
+	plainService := &EndpointBuilder{service: &model.Service{}}
+	experiment := map[string]string{"istio.io/cohort": "treatment"}
+
+	test.SetForTest(t, &features.EndpointCohortLabel, "istio.io/cohort")
+	test.SetForTest(t, &features.EnableEndpointCohorts, false)
+	assert.Equal(t, cohortOf(cohortService, experiment), "")
+
+	test.SetForTest(t, &features.EnableEndpointCohorts, true)
+	assert.Equal(t, cohortOf(cohortService, experiment), "treatment")
+	assert.Equal(t, cohortOf(cohortService, nil), "")
+	assert.Equal(t, cohortOf(plainService, experiment), "")
+}
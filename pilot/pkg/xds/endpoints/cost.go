@@ -0,0 +1,62 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+// CostProvider provides the cost of sending traffic to endpoints, for example from spot instance pricing or carbon
+// intensity. The costs are added to the load balancer metadata of the endpoints, for custom Envoy load balancer
+// extensions, and folded into their weights when PILOT_ENABLE_ENDPOINT_COST_WEIGHTS is set.
+// Providers trigger an EDS push when the costs change.
+type CostProvider interface {
+	// Cost returns the cost of the endpoint relative to the other endpoints, 1 being the baseline.
+	// It returns false if the cost of the endpoint is unknown.
+	Cost(e *model.IstioEndpoint) (float64, bool)
+	// Version changes whenever the costs change. It is part of the cache key of the clusters.
+	Version() string
+}
+
+// WithCostProvider sets the provider of the endpoint costs.
+func (b *EndpointBuilder) WithCostProvider(costs CostProvider) *EndpointBuilder {
+	b.costs = costs
+	return b
+}
+
+// applyCost adds the cost of the endpoint to its load balancer metadata and, if enabled, divides its weight by its
+// cost.
+func (b *EndpointBuilder) applyCost(e *model.IstioEndpoint, eep *lbEndpointCopy) {
+	if b.costs == nil {
+		return
+	}
+	cost, f := b.costs.Cost(e)
+	if !f || cost < 0 || math.IsNaN(cost) || math.IsInf(cost, 0) {
+		return
+	}
+	lbMetadata(eep.mutable()).Fields[util.LbCostMetadataKey] = structpb.NewNumberValue(cost)
+
+	if features.EnableEndpointCostWeights && cost > 0 && cost != 1 {
+		weight := util.ScaleWeight(float64(eep.lbEp.GetLoadBalancingWeight().GetValue()), 1/cost)
+		eep.mutable().LoadBalancingWeight = &wrapperspb.UInt32Value{Value: weight}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/test"
+)
+
+type fakeCosts struct {
+	version string
+	costs   map[string]float64
+}
+
+func (f fakeCosts) Cost(e *model.IstioEndpoint) (float64, bool) {
+	c, ok := f.costs[e.Address]
+	return c, ok
+}
+
+func (f fakeCosts) Version() string {
+	return f.version
+}
+
+func TestApplyCost(t *testing.T) {
+	costs := fakeCosts{version: "1", costs: map[string]float64{
+		"10.0.0.1": 2,
+		"10.0.0.2": 0.5,
+		"10.0.0.3": 1000,
+		"10.0.0.4": -1,
+	}}
+	tests := []struct {
+		address    string
+		weights    bool
+		wantCost   float64
+		wantWeight uint32
+	}{
+		{address: "10.0.0.1", wantCost: 2, wantWeight: 4},
+		{address: "10.0.0.1", weights: true, wantCost: 2, wantWeight: 2},
+		{address: "10.0.0.2", weights: true, wantCost: 0.5, wantWeight: 8},
+		// Endpoints keep a weight of at least 1.
+		{address: "10.0.0.3", weights: true, wantCost: 1000, wantWeight: 1},
+		// Endpoints without a valid cost are not modified.
+		{address: "10.0.0.4", weights: true, wantWeight: 4},
+		{address: "10.0.0.5", weights: true, wantWeight: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			test.SetForTest(t, &features.EnableEndpointCostWeights, tt.weights)
+			b := (&EndpointBuilder{}).WithCostProvider(costs)
+			c := &lbEndpointCopy{lbEp: lbEndpoint(tt.address, 8080, 4)}
+			b.applyCost(&model.IstioEndpoint{Address: tt.address}, c)
+			eep := c.lbEp
+			if eep.GetLoadBalancingWeight().GetValue() != tt.wantWeight {
+				t.Fatalf("expected weight %v, got %v", tt.wantWeight, eep.GetLoadBalancingWeight().GetValue())
+			}
+			cost, f := eep.GetMetadata().GetFilterMetadata()[util.EnvoyLbMetadataKey].GetFields()[util.LbCostMetadataKey]
+			if f != (tt.wantCost != 0) || cost.GetNumberValue() != tt.wantCost {
+				t.Fatalf("expected cost %v, got %v", tt.wantCost, cost)
+			}
+		})
+	}
+}
@@ -21,9 +21,12 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 
@@ -32,6 +35,7 @@ import (
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/loadbalancer"
 	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/serviceregistry/util/label"
 	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
@@ -39,9 +43,9 @@ import (
 	"istio.io/istio/pkg/config/schema/kind"
 	istiolog "istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/network"
-	"istio.io/istio/pkg/slices"
 	"istio.io/istio/pkg/spiffe"
 	"istio.io/istio/pkg/util/hash"
+	"istio.io/istio/pkg/util/identifier"
 )
 
 var (
@@ -59,6 +63,7 @@ const connectOriginate = "connect_originate"
 type EndpointBuilder struct {
 	// These fields define the primary key for an endpoint, and can be used as a cache key
 	clusterName            string
+	resourceName           string // the name of the ClusterLoadAssignment, if not the cluster name, see WithResourceName
 	network                network.ID
 	proxyView              model.ProxyView
 	clusterID              cluster.ID
@@ -67,18 +72,50 @@ type EndpointBuilder struct {
 	service                *model.Service
 	clusterLocal           bool
 	nodeType               model.NodeType
+	proxyLabels            labels.Instance
 	failoverPriorityLabels []byte
+	// portRangeEnd is the last port of SNI-DNAT clusters covering a port range, starting at port. It is
+	// part of the cluster name, so it does not need to be added to the cache key.
+	portRangeEnd int
 
 	// These fields are provided for convenience only
 	subsetName   string
 	subsetLabels labels.Instance
 	hostname     host.Name
 	port         int
-	push         *model.PushContext
-	proxy        *model.Proxy
+	push         *model.PushContext // nil for the builders created by New without a push context
+	proxy        *model.Proxy       // nil for the builders created by New without a proxy
 	dir          model.TrafficDirection
+	ambient      AmbientLookup
 
 	mtlsChecker *mtlsChecker
+	// loadFactors scales the endpoint weights from load reports, when set by WithLoadFactors.
+	loadFactors *LoadFactors
+	// costs provides the endpoint costs, when set by WithCostProvider.
+	costs CostProvider
+	// rollouts provides the rollout weights of the workloads, when set by WithRolloutWeights.
+	rollouts *model.RolloutWeights
+	// endpointSelection restricts the outbound endpoints, as configured by the Sidecar of the proxy.
+	endpointSelection *model.EndpointSelection
+	// nat64Prefix is the NAT64 prefix of the IPv6-only proxy, if it sets one.
+	nat64Prefix netip.Prefix
+	// meshSettings overrides the meshConfig settings of the push context, when set by WithMeshSettings.
+	meshSettings *model.EndpointMeshSettings
+	// trace records the filtered endpoints, when set by BuildClusterLoadAssignmentWithTrace.
+	trace *FilterTrace
+	// rampUntil is when the scale-up ramp of the endpoints last built ends, zero if they were not ramping.
+	rampUntil time.Time
+	// shardsMissing is set when the endpoints last built were empty because no registry has reported the endpoints of
+	// the service yet.
+	shardsMissing bool
+	// drainExpiry is when the first draining endpoint kept for persistent sessions expires, see SessionDrainExpiry.
+	drainExpiry time.Time
+	// capabilities are the features of the ClusterLoadAssignments supported by the client.
+	capabilities clientCapabilities
+	// telemetryMetadataKeys are the endpoint label keys requested by the telemetry providers of the proxy.
+	telemetryMetadataKeys []string
+	// cacheKeyExtensions are the extensions of the cache key registered when the builder was created.
+	cacheKeyExtensions []CacheKeyExtension
 }
 
 func NewEndpointBuilder(clusterName string, proxy *model.Proxy, push *model.PushContext) EndpointBuilder {
@@ -114,6 +151,7 @@ func NewCDSEndpointBuilder(
 		service:         service,
 		clusterLocal:    push.IsClusterLocal(service),
 		nodeType:        proxy.Type,
+		proxyLabels:     proxy.Labels,
 
 		subsetName: subsetName,
 		hostname:   hostname,
@@ -121,10 +159,31 @@ func NewCDSEndpointBuilder(
 		push:       push,
 		proxy:      proxy,
 		dir:        dir,
+		ambient:    noAmbient{},
+
+		capabilities:          clientCapabilitiesFor(proxy),
+		telemetryMetadataKeys: proxy.TelemetryEndpointMetadataKeys,
+		cacheKeyExtensions:    registeredCacheKeyExtensions(),
+		nat64Prefix:           nat64PrefixForProxy(proxy),
+	}
+	if push != nil {
+		b.ambient = push
+	}
+	if dir == model.TrafficDirectionOutbound && proxy.SidecarScope != nil {
+		b.endpointSelection = proxy.SidecarScope.EndpointSelection.ForService(service)
+	}
+	b.populate()
+	b.WithMeshSettings(proxy.EndpointMeshSettings)
+	return &b
+}
+
+// populate sets the fields of the builder derived from its cluster, service and DestinationRule.
+func (b *EndpointBuilder) populate() {
+	if _, maxPort, ok := model.ParseDNSSrvSubsetKeyPortRange(b.clusterName); ok {
+		b.portRangeEnd = maxPort
 	}
 	b.populateSubsetInfo()
 	b.populateFailoverPriorityLabels()
-	return &b
 }
 
 func (b *EndpointBuilder) servicePort(port int) *model.Port {
@@ -140,6 +199,33 @@ func (b *EndpointBuilder) servicePort(port int) *model.Port {
 	return svcPort
 }
 
+// servicePorts returns the service ports the cluster is built for: the port of the cluster, or all the service
+// ports within the range for SNI-DNAT clusters covering a port range.
+func (b *EndpointBuilder) servicePorts() []*model.Port {
+	if b.portRangeEnd == 0 {
+		if svcPort := b.servicePort(b.port); svcPort != nil {
+			return []*model.Port{svcPort}
+		}
+		return nil
+	}
+	if !b.ServiceFound() {
+		return nil
+	}
+	var out []*model.Port
+	for _, p := range b.service.Ports {
+		if p.Port >= b.port && p.Port <= b.portRangeEnd {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// withLbPortMetadata adds the service port to the load balancer metadata of the endpoint.
+// This allows the listeners to select the endpoints of a given port in clusters covering a port range.
+func withLbPortMetadata(eep *endpoint.LbEndpoint, port int) {
+	lbMetadata(eep).Fields[util.LbPortMetadataKey] = structpb.NewStringValue(strconv.Itoa(port))
+}
+
 func (b *EndpointBuilder) WithSubset(subset string) *EndpointBuilder {
 	if b == nil {
 		return nil
@@ -155,21 +241,71 @@ func (b *EndpointBuilder) populateSubsetInfo() {
 		b.subsetName = strings.TrimPrefix(b.subsetName, "http/")
 		b.subsetName = strings.TrimPrefix(b.subsetName, "tcp/")
 	}
-	b.mtlsChecker = newMtlsChecker(b.push, b.port, b.destinationRule.GetRule(), b.subsetName)
-	b.subsetLabels = getSubSetLabels(b.DestinationRule(), b.subsetName)
+	b.mtlsChecker = newMtlsChecker(b.push, b.hostname, b.port, b.destinationRule.GetRule(), b.ruleSubsetName())
+	b.subsetLabels = getSubSetLabels(b.DestinationRule(), b.ruleSubsetName())
+}
+
+// ruleSubsetName returns the name of the DestinationRule subset the endpoints are built for. The shadow clusters
+// receiving mirrored traffic use the subset named by the networking.istio.io/mirror-to annotation.
+func (b *EndpointBuilder) ruleSubsetName() string {
+	if b.subsetName == model.MirrorSubsetName {
+		if subset, _, ok := model.MirrorTo(b.destinationRule.GetRule()); ok {
+			return subset.Name
+		}
+	}
+	return b.subsetName
 }
 
 func (b *EndpointBuilder) populateFailoverPriorityLabels() {
-	enableFailover, lb := getOutlierDetectionAndLoadBalancerSettings(b.DestinationRule(), b.port, b.subsetName)
+	enableFailover, lb := getOutlierDetectionAndLoadBalancerSettings(b.DestinationRule(), b.port, b.ruleSubsetName())
 	if enableFailover {
-		lbSetting := loadbalancer.GetLocalityLbSetting(b.push.Mesh.GetLocalityLbSetting(), lb.GetLocalityLbSetting())
+		lbSetting := loadbalancer.GetLocalityLbSetting(b.meshLocalityLbSetting(), lb.GetLocalityLbSetting())
 		if lbSetting != nil && lbSetting.Distribute == nil &&
 			len(lbSetting.FailoverPriority) > 0 && (lbSetting.Enabled == nil || lbSetting.Enabled.Value) {
-			b.failoverPriorityLabels = util.GetFailoverPriorityLabels(b.proxy.Labels, lbSetting.FailoverPriority)
+			b.failoverPriorityLabels = util.GetFailoverPriorityLabels(b.proxyLabels, lbSetting.FailoverPriority)
 		}
 	}
 }
 
+// localityKey returns the part of the locality of the proxy the endpoints depend on, if any. Proxies of different
+// localities share the endpoints of the clusters without locality load balancing, topology aware routing or data
+// residency policy, which are then only built and marshaled once.
+func (b *EndpointBuilder) localityKey() string {
+	if b.localityLbApplies() || (b.service != nil && b.service.TopologyAwareHints()) {
+		return util.LocalityToString(b.locality)
+	}
+	if len(features.DataResidencyPolicy) > 0 {
+		return b.locality.GetRegion()
+	}
+	return ""
+}
+
+// localityLbApplies returns whether the priorities or weights of the endpoints depend on the locality of the proxy,
+// as done by loadbalancer.ApplyLocalityLBSetting.
+func (b *EndpointBuilder) localityLbApplies() bool {
+	enableFailover, lb := getOutlierDetectionAndLoadBalancerSettings(b.DestinationRule(), b.port, b.ruleSubsetName())
+	lbSetting := loadbalancer.GetLocalityLbSetting(b.meshLocalityLbSetting(), lb.GetLocalityLbSetting())
+	if lbSetting == nil {
+		return false
+	}
+	if lbSetting.GetDistribute() != nil {
+		return true
+	}
+	// The failover priorities depend on the labels of the proxy instead, see populateFailoverPriorityLabels.
+	return enableFailover && (lbSetting.Enabled == nil || lbSetting.Enabled.Value) && len(lbSetting.FailoverPriority) == 0
+}
+
+// TrafficPolicy returns the traffic policy of the destination rule applying to the endpoints of the cluster, with
+// the locality load balancing setting in effect once merged with the mesh default. Locality failover is only
+// enabled by a policy with outlier detection.
+func (b *EndpointBuilder) TrafficPolicy() (policy *v1alpha3.TrafficPolicy, lbSetting *v1alpha3.LocalityLoadBalancerSetting, failover bool) {
+	if dr := b.DestinationRule(); dr != nil {
+		policy = util.EffectiveTrafficPolicy(dr, b.ruleSubsetName(), &model.Port{Port: b.port})
+	}
+	lbSetting = loadbalancer.GetLocalityLbSetting(b.meshLocalityLbSetting(), policy.GetLoadBalancer().GetLocalityLbSetting())
+	return policy, lbSetting, policy.GetOutlierDetection() != nil
+}
+
 func (b *EndpointBuilder) DestinationRule() *v1alpha3.DestinationRule {
 	if dr := b.destinationRule.GetRule(); dr != nil {
 		dr, _ := dr.Spec.(*v1alpha3.DestinationRule)
@@ -182,6 +318,11 @@ func (b *EndpointBuilder) Type() string {
 	return model.EDSType
 }
 
+// Service returns the Service the endpoints are built for, or nil if it was not found.
+func (b *EndpointBuilder) Service() *model.Service {
+	return b.service
+}
+
 func (b *EndpointBuilder) ServiceFound() bool {
 	return b.service != nil
 }
@@ -205,6 +346,10 @@ func (b *EndpointBuilder) WriteHash(h hash.Hash) {
 	}
 	h.Write([]byte(b.clusterName))
 	h.Write(Separator)
+	if b.resourceName != "" {
+		h.Write([]byte(b.resourceName))
+		h.Write(Separator)
+	}
 	h.Write([]byte(b.network))
 	h.Write(Separator)
 	h.Write([]byte(b.clusterID))
@@ -213,18 +358,37 @@ func (b *EndpointBuilder) WriteHash(h hash.Hash) {
 	h.Write(Separator)
 	h.Write([]byte(strconv.FormatBool(b.clusterLocal)))
 	h.Write(Separator)
-	if features.EnableHBONE && b.proxy != nil {
-		h.Write([]byte(strconv.FormatBool(b.proxy.IsProxylessGrpc())))
-		h.Write(Separator)
-	}
-	h.Write([]byte(util.LocalityToString(b.locality)))
+	h.Write([]byte(b.capabilities.key))
+	h.Write(Separator)
+	h.Write([]byte(b.localityKey()))
 	h.Write(Separator)
 	if len(b.failoverPriorityLabels) > 0 {
 		h.Write(b.failoverPriorityLabels)
 		h.Write(Separator)
 	}
-	if b.service.Attributes.NodeLocal {
-		h.Write([]byte(b.proxy.GetNodeName()))
+	if b.service.Attributes.NodeLocal || b.preferNodeLocal() {
+		h.Write([]byte(b.nodeName()))
+		h.Write(Separator)
+	}
+	for _, key := range features.EndpointDiscoverabilityMetadataKeys {
+		// Endpoints may only be discoverable from proxies with matching metadata.
+		v, _ := b.proxy.DiscoverabilityMetadata(key) // nil-safe
+		h.Write([]byte(v))
+		h.Write(Separator)
+	}
+	if b.useNAT64() {
+		// IPv4 endpoint addresses are translated for IPv6-only proxies.
+		h.Write([]byte(b.nat64Prefix.String()))
+		h.Write(Separator)
+	}
+	if b.nodeType == model.Waypoint {
+		// Waypoints generate different endpoints depending on which workloads they own.
+		scope := b.waypointScope()
+		h.Write([]byte(scope.Namespace))
+		h.Write(Slash)
+		h.Write([]byte(scope.ServiceAccount))
+		h.Write(Slash)
+		h.Write([]byte(scope.Selector))
 		h.Write(Separator)
 	}
 
@@ -240,6 +404,14 @@ func (b *EndpointBuilder) WriteHash(h hash.Hash) {
 	}
 	h.Write(Separator)
 
+	// Proxies selected by different workload-scoped destination rules see different subsets
+	// and failover settings, so they must not share endpoints.
+	for _, selector := range b.destinationRule.SelectorFingerprints() {
+		h.Write([]byte(selector))
+		h.Write(Slash)
+	}
+	h.Write(Separator)
+
 	if b.service != nil {
 		h.Write([]byte(b.service.Hostname))
 		h.Write(Slash)
@@ -251,18 +423,51 @@ func (b *EndpointBuilder) WriteHash(h hash.Hash) {
 		h.Write([]byte(b.proxyView.String()))
 	}
 	h.Write(Separator)
+
+	if b.loadFactors != nil {
+		h.Write([]byte(strconv.FormatUint(b.loadFactors.Version, 10)))
+	}
+	h.Write(Separator)
+
+	if b.costs != nil {
+		h.Write([]byte(b.costs.Version()))
+	}
+	h.Write(Separator)
+
+	h.Write([]byte(b.rollouts.Version()))
+	h.Write(Separator)
+
+	h.Write([]byte(b.endpointSelection.Key()))
+	h.Write(Separator)
+
+	for _, key := range b.telemetryMetadataKeys {
+		h.Write([]byte(key))
+		h.Write(Slash)
+	}
+	h.Write(Separator)
+
+	if b.meshSettings != nil {
+		h.Write([]byte(b.meshSettings.Version))
+	}
+	h.Write(Separator)
+
+	for _, extension := range b.cacheKeyExtensions {
+		extension.WriteHash(h, b.proxy, b.service)
+		h.Write(Separator)
+	}
 }
 
 func (b *EndpointBuilder) Cacheable() bool {
 	// If service is not defined, we cannot do any caching as we will not have a way to
 	// invalidate the results.
 	// Service being nil means the EDS will be empty anyways, so not much lost here.
-	return b.service != nil
+	// Ramping endpoints change over time, without any update to invalidate them.
+	return b.service != nil && b.rampUntil.IsZero()
 }
 
 func (b *EndpointBuilder) DependentConfigs() []model.ConfigHash {
 	drs := b.destinationRule.GetFrom()
-	configs := make([]model.ConfigHash, 0, len(drs)+1)
+	configs := make([]model.ConfigHash, 0, len(drs)+2)
 	if b.destinationRule != nil {
 		for _, dr := range drs {
 			configs = append(configs, model.ConfigKey{
@@ -277,6 +482,16 @@ func (b *EndpointBuilder) DependentConfigs() []model.ConfigHash {
 			Name: string(b.service.Hostname), Namespace: b.service.Attributes.Namespace,
 		}.HashCode())
 	}
+	if b.service != nil && b.service.Attributes.ClusterSetOrigin != "" {
+		configs = append(configs, model.ConfigKey{
+			Kind: kind.ServiceEntry,
+			Name: string(b.service.Attributes.ClusterSetOrigin), Namespace: b.service.Attributes.Namespace,
+		}.HashCode())
+	}
+
+	for _, extension := range b.cacheKeyExtensions {
+		configs = append(configs, extension.DependentConfigs(b.proxy, b.service)...)
+	}
 
 	// For now, this matches clusterCache's DependentConfigs. If adding anything here, we may need to add them there.
 
@@ -287,11 +502,60 @@ type LocalityEndpoints struct {
 	istioEndpoints []*model.IstioEndpoint
 	// The protobuf message which contains LbEndpoint slice.
 	llbEndpoints endpoint.LocalityLbEndpoints
+	// group is the group of the endpoints, which sets their priority relative to the other groups.
+	group endpointGroup
+	// owned records, by index, the endpoints built for these LocalityEndpoints only, which may be modified in
+	// place. The other endpoints may be precomputed and shared with other clusters.
+	owned []bool
 }
 
 func (e *LocalityEndpoints) append(ep *model.IstioEndpoint, le *endpoint.LbEndpoint) {
 	e.istioEndpoints = append(e.istioEndpoints, ep)
 	e.llbEndpoints.LbEndpoints = append(e.llbEndpoints.LbEndpoints, le)
+	if e.owned != nil {
+		e.owned = append(e.owned, false)
+	}
+}
+
+// appendCopy appends the endpoint, recording whether it is owned.
+func (e *LocalityEndpoints) appendCopy(ep *model.IstioEndpoint, c *lbEndpointCopy) {
+	if !c.shared && e.owned == nil {
+		e.owned = make([]bool, len(e.llbEndpoints.LbEndpoints), cap(e.llbEndpoints.LbEndpoints))
+	}
+	e.append(ep, c.lbEp)
+	if !c.shared {
+		e.owned[len(e.owned)-1] = true
+	}
+}
+
+// mutable returns the endpoint at the index to modify, cloning it first if it is not owned.
+func (e *LocalityEndpoints) mutable(i int) *endpoint.LbEndpoint {
+	if i < len(e.owned) && e.owned[i] {
+		return e.llbEndpoints.LbEndpoints[i]
+	}
+	if e.owned == nil {
+		e.owned = make([]bool, len(e.llbEndpoints.LbEndpoints))
+	}
+	lbEp := proto.Clone(e.llbEndpoints.LbEndpoints[i]).(*endpoint.LbEndpoint)
+	e.llbEndpoints.LbEndpoints[i] = lbEp
+	e.owned[i] = true
+	return lbEp
+}
+
+// lbEndpointCopy is an endpoint being built, which may be precomputed and shared with other clusters: it is cloned
+// the first time it is modified, so the builds which do not modify the precomputed endpoints do not copy them.
+type lbEndpointCopy struct {
+	lbEp   *endpoint.LbEndpoint
+	shared bool
+}
+
+// mutable returns the endpoint to modify, cloning it first if it is shared.
+func (c *lbEndpointCopy) mutable() *endpoint.LbEndpoint {
+	if c.shared {
+		c.lbEp = proto.Clone(c.lbEp).(*endpoint.LbEndpoint)
+		c.shared = false
+	}
+	return c.lbEp
 }
 
 func (e *LocalityEndpoints) refreshWeight() {
@@ -319,27 +583,52 @@ func (b *EndpointBuilder) FromServiceEndpoints() []*endpoint.LocalityLbEndpoints
 	if b == nil {
 		return nil
 	}
+	if b.push == nil {
+		return nil
+	}
 	svcEps := b.push.ServiceEndpointsByPort(b.service, b.port, b.subsetLabels)
 	// don't use the pre-computed endpoints for CDS to preserve previous behavior
-	return ExtractEnvoyEndpoints(b.generate(svcEps, true))
+	primary, groups := splitGroups(b.generate(svcEps, true))
+	return appendGroups(ExtractEnvoyEndpoints(primary), groups)
+}
+
+// MtlsDecisions explains the TLS mode computed for each endpoint of the cluster, before the other filters apply.
+func (b *EndpointBuilder) MtlsDecisions(source EndpointSource) []MtlsDecision {
+	if !b.ServiceFound() {
+		return nil
+	}
+	svcPorts := b.servicePorts()
+	var out []MtlsDecision
+	for _, ep := range b.snapshotShards(source) {
+		for _, svcPort := range svcPorts {
+			if b.filterReason(ep, svcPort) == "" {
+				out = append(out, b.mtlsChecker.explain(ep.ForPort(svcPort.Name)))
+				break
+			}
+		}
+	}
+	return out
 }
 
 // BuildClusterLoadAssignment converts the shards for this EndpointBuilder's Service
 // into a ClusterLoadAssignment. Used for EDS.
-func (b *EndpointBuilder) BuildClusterLoadAssignment(endpointIndex *model.EndpointIndex) *endpoint.ClusterLoadAssignment {
-	svcEps := b.snapshotShards(endpointIndex)
+func (b *EndpointBuilder) BuildClusterLoadAssignment(source EndpointSource) *endpoint.ClusterLoadAssignment {
+	svcEps := b.snapshotShards(source)
 	localityLbEndpoints := b.generate(svcEps, false)
 	if len(localityLbEndpoints) == 0 {
-		return buildEmptyClusterLoadAssignment(b.clusterName)
+		return buildEmptyClusterLoadAssignment(b.claName())
 	}
 
+	// The locality load balancing settings only apply to the endpoints reached through the waypoints, if any,
+	// and not to the standby endpoints nor to the endpoints of remote networks failed over to.
+	localityLbEndpoints, groups := splitGroups(localityLbEndpoints)
 	l := b.createClusterLoadAssignment(localityLbEndpoints)
 
 	// If locality aware routing is enabled, prioritize endpoints or set their lb weight.
 	// Failover should only be enabled when there is an outlier detection, otherwise Envoy
 	// will never detect the hosts are unhealthy and redirect traffic.
-	enableFailover, lb := getOutlierDetectionAndLoadBalancerSettings(b.DestinationRule(), b.port, b.subsetName)
-	lbSetting := loadbalancer.GetLocalityLbSetting(b.push.Mesh.GetLocalityLbSetting(), lb.GetLocalityLbSetting())
+	enableFailover, lb := getOutlierDetectionAndLoadBalancerSettings(b.DestinationRule(), b.port, b.ruleSubsetName())
+	lbSetting := loadbalancer.GetLocalityLbSetting(b.meshLocalityLbSetting(), lb.GetLocalityLbSetting())
 	if lbSetting != nil {
 		// Make a shallow copy of the cla as we are mutating the endpoints with priorities/weights relative to the calling proxy
 		l = util.CloneClusterLoadAssignment(l)
@@ -350,7 +639,26 @@ func (b *EndpointBuilder) BuildClusterLoadAssignment(endpointIndex *model.Endpoi
 				LocalityLbEndpoints: l.Endpoints[i],
 			}
 		}
-		loadbalancer.ApplyLocalityLBSetting(l, wrappedLocalityLbEndpoints, b.locality, b.proxy.Labels, lbSetting, enableFailover)
+		loadbalancer.ApplyLocalityLBSetting(l, wrappedLocalityLbEndpoints, b.locality, b.proxyLabels, lbSetting, enableFailover)
+		if enableFailover && lbSetting.GetDistribute() == nil {
+			percentage, _ := model.FailoverPrewarmPercentage(b.destinationRule.GetRule())
+			if threshold, ok := model.LocalitySpilloverThreshold(b.destinationRule.GetRule()); ok {
+				// The overflow of priority 0 is sent to the first failover priority, like the prewarm trickle.
+				percentage = math.Max(percentage, loadbalancer.SpilloverPercentage(l, threshold))
+			}
+			loadbalancer.ApplyFailoverPrewarm(l, percentage)
+		}
+	}
+	if factor, ok := model.OverprovisioningFactor(b.destinationRule.GetRule()); ok {
+		l.Policy = &endpoint.ClusterLoadAssignment_Policy{OverprovisioningFactor: wrapperspb.UInt32(factor)}
+	}
+	// The endpoints on other nodes than the proxy come after all the priorities of the node local endpoints, then
+	// the endpoints of remote networks failed over to, then standby endpoints, and direct endpoints come last, after
+	// the endpoints reached through waypoints.
+	l.Endpoints = appendGroups(l.Endpoints, groups)
+	l = applySizeLimits(l, sizeLimitsFromFeatures())
+	if features.CanonicalizeEndpoints {
+		l = CanonicalizeClusterLoadAssignment(l)
 	}
 	return l
 }
@@ -362,16 +670,27 @@ func (b *EndpointBuilder) generate(eps []*model.IstioEndpoint, allowPrecomputed
 	if !b.ServiceFound() {
 		return nil
 	}
-	svcPort := b.servicePort(b.port)
-	if svcPort == nil {
+	svcPorts := b.servicePorts()
+	if len(svcPorts) == 0 {
 		return nil
 	}
 
-	eps = slices.Filter(eps, func(ep *model.IstioEndpoint) bool {
-		return b.filterIstioEndpoint(ep, svcPort)
-	})
+	eps, endpointPorts := b.selectEndpoints(eps, svcPorts)
+	b.recordSessionDrain(eps)
+	eps, rampFactors := b.filterScaleUpRamp(eps)
+
+	// Reuses of the precomputed endpoints are only recorded for the callers caching them.
+	recordPrecomputed := allowPrecomputed
+	allowPrecomputed = allowPrecomputed && b.precomputeEndpoints()
+	reused, rebuilt := 0, 0
 
 	localityEpMap := make(map[string]*LocalityEndpoints)
+	// fallbackEpMap holds the endpoints reaching workloads directly when their waypoints are unavailable.
+	fallbackEpMap := make(map[string]*LocalityEndpoints)
+	// standbyEpMap holds the endpoints only receiving traffic once the other endpoints are unhealthy.
+	standbyEpMap := make(map[string]*LocalityEndpoints)
+	// remoteNodeEpMap holds the endpoints on other nodes than the proxy, when node local endpoints are preferred.
+	remoteNodeEpMap := make(map[string]*LocalityEndpoints)
 	for _, ep := range eps {
 		eep := ep.EnvoyEndpoint()
 		mtlsEnabled := b.mtlsChecker.checkMtlsEnabled(ep)
@@ -390,13 +709,46 @@ func (b *EndpointBuilder) generate(eps []*model.IstioEndpoint, allowPrecomputed
 		if needToCompute || !allowPrecomputed {
 			eep = buildEnvoyLbEndpoint(b, ep, mtlsEnabled)
 			if eep == nil {
+				b.trace.filtered(ep, reasonBuildFailed)
+				endpointFiltered(reasonBuildFailed)
 				continue
 			}
 			if allowPrecomputed {
 				ep.ComputeEnvoyEndpoint(eep)
 			}
+			rebuilt++
+		} else {
+			reused++
+		}
+		// The precomputed endpoint is shared with other clusters, the steps below only clone it if they modify it.
+		lbEp := &lbEndpointCopy{lbEp: eep, shared: allowPrecomputed}
+		if endpointPorts != nil {
+			withLbPortMetadata(lbEp.mutable(), endpointPorts[ep])
 		}
-		locLbEps, found := localityEpMap[ep.Locality.Label]
+		b.applyPublishNotReady(ep, lbEp)
+		b.applyTerminating(ep, lbEp)
+		b.applyLoadFactor(lbEp)
+		applyScaleUpRamp(rampFactors, ep, lbEp)
+		b.applyCost(ep, lbEp)
+		applyCapacity(ep, lbEp)
+		b.applyCohort(ep, lbEp)
+		applyMigration(ep, lbEp)
+		b.applySubsetMetadata(ep, lbEp)
+		b.applyHostname(ep, lbEp)
+		applyAccessLogMetadata(ep, lbEp)
+		applySocketOptions(ep, lbEp)
+		b.applyTelemetryMetadata(ep, lbEp)
+		b.applyNAT64(lbEp)
+		eep = lbEp.lbEp
+		epMap := localityEpMap
+		standby := isStandby(ep)
+		remoteNode := !standby && b.isRemoteNode(ep)
+		if standby {
+			epMap = standbyEpMap
+		} else if remoteNode {
+			epMap = remoteNodeEpMap
+		}
+		locLbEps, found := epMap[ep.Locality.Label]
 		if !found {
 			locLbEps = &LocalityEndpoints{
 				llbEndpoints: endpoint.LocalityLbEndpoints{
@@ -404,21 +756,56 @@ func (b *EndpointBuilder) generate(eps []*model.IstioEndpoint, allowPrecomputed
 					LbEndpoints: make([]*endpoint.LbEndpoint, 0, len(eps)),
 				},
 			}
-			localityEpMap[ep.Locality.Label] = locLbEps
+			if standby {
+				locLbEps.group = standbyGroup
+			} else if remoteNode {
+				locLbEps.group = remoteNodeGroup
+			}
+			epMap[ep.Locality.Label] = locLbEps
+		}
+		locLbEps.appendCopy(ep, lbEp)
+
+		// Workloads served by several waypoints are reachable through each of them, so that a waypoint outage
+		// degrades instead of blackholing the traffic.
+		alternates, direct := b.waypointTunnelEndpoints(ep, eep)
+		for _, alt := range alternates {
+			locLbEps.appendCopy(ep, &lbEndpointCopy{lbEp: alt})
+		}
+		if direct != nil {
+			fallbackEps, found := fallbackEpMap[ep.Locality.Label]
+			if !found {
+				fallbackEps = &LocalityEndpoints{
+					llbEndpoints: endpoint.LocalityLbEndpoints{
+						Locality: util.ConvertLocality(ep.Locality.Label),
+					},
+					group: directFallbackGroup,
+				}
+				fallbackEpMap[ep.Locality.Label] = fallbackEps
+			}
+			fallbackEps.appendCopy(ep, &lbEndpointCopy{lbEp: direct})
 		}
-		locLbEps.append(ep, eep)
 	}
 
-	locEps := make([]*LocalityEndpoints, 0, len(localityEpMap))
-	locs := make([]string, 0, len(localityEpMap))
-	for k := range localityEpMap {
-		locs = append(locs, k)
+	locEps := make([]*LocalityEndpoints, 0, len(localityEpMap)+len(remoteNodeEpMap)+len(standbyEpMap)+len(fallbackEpMap))
+	for _, m := range []map[string]*LocalityEndpoints{localityEpMap, remoteNodeEpMap, standbyEpMap, fallbackEpMap} {
+		locs := make([]string, 0, len(m))
+		for k := range m {
+			locs = append(locs, k)
+		}
+		if len(locs) >= 2 {
+			sort.Strings(locs)
+		}
+		for _, locality := range locs {
+			locEps = append(locEps, m[locality])
+		}
 	}
-	if len(locs) >= 2 {
-		sort.Strings(locs)
+	b.applyRolloutWeights(locEps)
+	b.applyClusterWeights(locEps)
+	if normalizeWeights(locEps, b.maxEndpointWeight()) {
+		weightNormalizations.Increment()
+		log.Debugf("scaled down endpoint weights: service:%s, port: %d", b.service.Hostname, b.port)
 	}
-	for _, locality := range locs {
-		locLbEps := localityEpMap[locality]
+	for _, locLbEps := range locEps {
 		var weight uint32
 		var overflowStatus bool
 		for _, ep := range locLbEps.llbEndpoints.LbEndpoints {
@@ -429,29 +816,47 @@ func (b *EndpointBuilder) generate(eps []*model.IstioEndpoint, allowPrecomputed
 		}
 		if overflowStatus {
 			log.Warnf("Sum of localityLbEndpoints weight is overflow: service:%s, port: %d, locality:%s",
-				b.service.Hostname, b.port, locality)
+				b.service.Hostname, b.port, util.LocalityToString(locLbEps.llbEndpoints.Locality))
 		}
-		locEps = append(locEps, locLbEps)
+	}
+	if features.EnableCapacityLocalityWeights {
+		applyCapacityLocalityWeights(locEps)
 	}
 
-	if len(locEps) == 0 {
+	if len(locEps) == 0 && b.push != nil {
 		b.push.AddMetric(model.ProxyStatusClusterNoInstances, b.clusterName, "", "")
 	}
 
 	// Apply the Split Horizon EDS filter, if applicable.
-	locEps = b.EndpointsByNetworkFilter(locEps)
+	filtered := b.EndpointsByNetworkFilter(locEps)
+	b.trace.stage("network", locEps, filtered)
+	locEps = filtered
 
 	if model.IsDNSSrvSubsetKey(b.clusterName) {
 		// For the SNI-DNAT clusters, we are using AUTO_PASSTHROUGH gateway. AUTO_PASSTHROUGH is intended
 		// to passthrough mTLS requests. However, at the gateway we do not actually have any way to tell if the
 		// request is a valid mTLS request or not, since its passthrough TLS.
 		// To ensure we allow traffic only to mTLS endpoints, we filter out non-mTLS endpoints for these cluster types.
-		locEps = b.EndpointsWithMTLSFilter(locEps)
+		filtered = b.EndpointsWithMTLSFilter(locEps)
+		b.trace.stage("mtls", locEps, filtered)
+		locEps = filtered
 	}
 
+	b.applyClientCapabilities(locEps)
+	recordEndpoints(locEps)
+	if recordPrecomputed {
+		recordPrecomputedEndpoints(reused, rebuilt)
+	}
 	return locEps
 }
 
+// precomputeEndpoints returns whether the Envoy endpoints precomputed for the endpoints of the service can be reused.
+// It can be disabled globally with PILOT_ENABLE_PRECOMPUTED_ENDPOINTS, or per service with the
+// networking.istio.io/precompute-endpoints annotation.
+func (b *EndpointBuilder) precomputeEndpoints() bool {
+	return features.EnablePrecomputedEndpoints && !b.service.Attributes.DisablePrecomputedEndpoints
+}
+
 // addUint32AvoidOverflow returns sum of two uint32 and status. If sum overflows,
 // and returns MaxUint32 and status.
 func addUint32(left, right uint32) (uint32, bool) {
@@ -461,52 +866,146 @@ func addUint32(left, right uint32) (uint32, bool) {
 	return left + right, false
 }
 
-func (b *EndpointBuilder) filterIstioEndpoint(ep *model.IstioEndpoint, svcPort *model.Port) bool {
-	// for ServiceInternalTrafficPolicy
-	if b.service.Attributes.NodeLocal && ep.NodeName != b.proxy.GetNodeName() {
+// maxEndpointWeight returns the maximum endpoint weight for the service, 0 meaning unbounded.
+func (b *EndpointBuilder) maxEndpointWeight() uint32 {
+	if features.MaxEndpointWeightLabel != "" {
+		if v, f := b.service.Attributes.Labels[features.MaxEndpointWeightLabel]; f {
+			if w, err := strconv.ParseUint(v, 10, 32); err == nil && w > 0 {
+				return uint32(w)
+			}
+			log.Debugf("invalid %s label %q on service %s", features.MaxEndpointWeightLabel, v, b.service.Hostname)
+		}
+	}
+	if features.MaxEndpointWeight > 0 {
+		return uint32(features.MaxEndpointWeight)
+	}
+	return 0
+}
+
+// normalizeWeights scales the endpoint weights of all localities down, keeping their ratios, so that
+// no endpoint weight exceeds maxWeight (if set) and the sum of all weights fits in an uint32, as required by Envoy.
+// Endpoints may be shared with the precomputed cache, so they are only modified through LocalityEndpoints.mutable.
+// It returns true if any weight was changed.
+func normalizeWeights(locEps []*LocalityEndpoints, maxWeight uint32) bool {
+	var total uint64
+	var largest uint32
+	var count uint64
+	for _, locLbEps := range locEps {
+		for _, ep := range locLbEps.llbEndpoints.LbEndpoints {
+			w := ep.GetLoadBalancingWeight().GetValue()
+			total += uint64(w)
+			count++
+			if w > largest {
+				largest = w
+			}
+		}
+	}
+	factor := 1.0
+	if maxWeight > 0 && largest > maxWeight {
+		factor = float64(maxWeight) / float64(largest)
+	}
+	// Each scaled weight may be rounded up to 1, leave room for that.
+	if limit := uint64(math.MaxUint32) - count; count < math.MaxUint32 && float64(total)*factor > float64(limit) {
+		factor = float64(limit) / float64(total)
+	}
+	if factor >= 1 {
 		return false
 	}
+	for _, locLbEps := range locEps {
+		for i, ep := range locLbEps.llbEndpoints.LbEndpoints {
+			w := uint32(float64(ep.GetLoadBalancingWeight().GetValue()) * factor)
+			if w == 0 {
+				w = 1
+			}
+			if w == ep.GetLoadBalancingWeight().GetValue() {
+				continue
+			}
+			locLbEps.mutable(i).LoadBalancingWeight = &wrapperspb.UInt32Value{Value: w}
+		}
+	}
+	return true
+}
+
+// Reasons for endpoints to be left out of a ClusterLoadAssignment.
+const (
+	reasonNodeLocal         = "internal traffic policy: endpoint is on another node"
+	reasonNetworkView       = "network not in the proxy network view"
+	reasonEndpointSelection = "excluded by the sidecar endpoint selection"
+	reasonClusterLocal      = "cluster local service: endpoint is in another cluster"
+	reasonDiscoverability   = "not discoverable from the proxy"
+	reasonDataResidency     = "data residency policy excludes the proxy region"
+	reasonPortMismatch      = "service port name mismatch"
+	reasonSubsetMismatch    = "subset labels mismatch"
+	reasonDirectPod         = "address of another pod of the direct pod cluster"
+	reasonNoAddress         = "no address on the proxy network"
+	reasonDraining          = "draining"
+	reasonBuildFailed       = "failed to build the envoy endpoint"
+	reasonNoGateway         = "remote network without a gateway and no address"
+	reasonNetworkMtls       = "cross-network endpoint without mTLS"
+	reasonMtls              = "mTLS required by the SNI-DNAT cluster"
+	reasonScaleUpRamp       = "scale-up ramp: wave not started"
+	reasonDrainExpired      = "persistent session drain TTL expired"
+	reasonTopologyHints     = "topology aware routing: not hinted for the proxy zone"
+	reasonTrafficCut        = "traffic cut by the destination rule"
+)
+
+// filterReason returns why the endpoint is not selected for the service port, or an empty string if it is.
+func (b *EndpointBuilder) filterReason(ep *model.IstioEndpoint, svcPort *model.Port) string {
+	// for ServiceInternalTrafficPolicy
+	if b.service.Attributes.NodeLocal && ep.NodeName != b.nodeName() {
+		return reasonNodeLocal
+	}
 	// Only send endpoints from the networks in the network view requested by the proxy.
 	// The default network view assigned to the Proxy is nil, in that case match any network.
 	if !b.proxyView.IsVisible(ep) {
 		// Endpoint's network doesn't match the set of networks that the proxy wants to see.
-		return false
+		return reasonNetworkView
+	}
+	if b.endpointSelection.Excludes(ep) {
+		return reasonEndpointSelection
 	}
 	// If the downstream service is configured as cluster-local, only include endpoints that
 	// reside in the same cluster.
 	if b.clusterLocal && (b.clusterID != ep.Locality.ClusterID) {
-		return false
+		return reasonClusterLocal
 	}
 	// TODO(nmittler): Consider merging discoverability policy with cluster-local
-	if !ep.IsDiscoverableFromProxy(b.proxy) {
-		return false
+	if !b.discoverable(ep) {
+		return reasonDiscoverability
 	}
-	if svcPort.Name != ep.ServicePortName {
-		return false
+	if !b.residencyAllowed(ep) {
+		return reasonDataResidency
+	}
+	if !ep.ServesPort(svcPort.Name) {
+		return reasonPortMismatch
 	}
 	// Port labels
 	if !b.subsetLabels.SubsetOf(ep.Labels) {
-		return false
+		return reasonSubsetMismatch
+	}
+	if address, ok := model.DirectPodAddress(b.subsetName); ok && ep.Address != address {
+		return reasonDirectPod
 	}
 	// If we don't know the address we must eventually use a gateway address
 	if ep.Address == "" && ep.Network == b.network {
-		return false
+		return reasonNoAddress
 	}
-	// Draining endpoints are only sent to 'persistent session' clusters.
+	// Draining endpoints are only sent to 'persistent session' clusters, and terminating endpoints to the clusters
+	// of services including them.
 	draining := ep.HealthStatus == model.Draining ||
 		features.DrainingLabel != "" && ep.Labels[features.DrainingLabel] != ""
-	if draining {
-		persistentSession := b.service.Attributes.Labels[features.PersistentSessionLabel] != ""
-		if !persistentSession {
-			return false
-		}
+	if draining && !b.persistentSession() && !(isTerminating(ep) && b.includesTerminating()) {
+		return reasonDraining
 	}
-	return true
+	if draining && b.persistentSession() && sessionDrainExpired(ep) {
+		return reasonDrainExpired
+	}
+	return ""
 }
 
 // snapshotShards into a local slice to avoid lock contention
-func (b *EndpointBuilder) snapshotShards(endpointIndex *model.EndpointIndex) []*model.IstioEndpoint {
-	shards := b.findShards(endpointIndex)
+func (b *EndpointBuilder) snapshotShards(source EndpointSource) []*model.IstioEndpoint {
+	shards := b.findShards(source)
 	if shards == nil {
 		return nil
 	}
@@ -534,8 +1033,22 @@ func (b *EndpointBuilder) snapshotShards(endpointIndex *model.EndpointIndex) []*
 	return eps
 }
 
+// ResourceVersion returns the version of a marshaled ClusterLoadAssignment, a hash of its content. It changes with
+// the ClusterLoadAssignment, whichever of the endpoints, the DestinationRules or the proxy changed it, and is the
+// same on all the istiod replicas building the same ClusterLoadAssignment. Unlike a version derived from the
+// generations of the shards, it is not ordered: clients tell the ClusterLoadAssignments apart, not which is newer.
+func ResourceVersion(cla *anypb.Any) string {
+	if cla == nil {
+		return ""
+	}
+	h := hash.New()
+	h.Write(cla.Value)
+	return h.Sum()
+}
+
 // findShards returns the endpoints for a cluster
-func (b *EndpointBuilder) findShards(endpointIndex *model.EndpointIndex) *model.EndpointShards {
+func (b *EndpointBuilder) findShards(source EndpointSource) *model.EndpointShards {
+	b.shardsMissing = false
 	if b.service == nil {
 		log.Debugf("can not find the service for cluster %s", b.clusterName)
 		return nil
@@ -553,15 +1066,23 @@ func (b *EndpointBuilder) findShards(endpointIndex *model.EndpointIndex) *model.
 		return nil
 	}
 
-	epShards, f := endpointIndex.ShardsForService(string(b.hostname), b.service.Attributes.Namespace)
+	epShards, f := source.ShardsForService(string(b.hostname), b.service.Attributes.Namespace)
 	if !f {
 		// Shouldn't happen here
 		log.Debugf("can not find the endpointShards for cluster %s", b.clusterName)
+		b.shardsMissing = true
 		return nil
 	}
 	return epShards
 }
 
+// ShardsMissing returns true if the endpoints last built were empty because no registry has reported the endpoints of
+// the service yet, as while the registries resync, rather than because its registries report none. The shards of a
+// service are kept once its registries report no endpoints.
+func (b *EndpointBuilder) ShardsMissing() bool {
+	return b.shardsMissing
+}
+
 // Create the CLusterLoadAssignment. At this moment the options must have been applied to the locality lb endpoints.
 func (b *EndpointBuilder) createClusterLoadAssignment(llbOpts []*LocalityEndpoints) *endpoint.ClusterLoadAssignment {
 	llbEndpoints := make([]*endpoint.LocalityLbEndpoints, 0, len(llbOpts))
@@ -569,11 +1090,28 @@ func (b *EndpointBuilder) createClusterLoadAssignment(llbOpts []*LocalityEndpoin
 		llbEndpoints = append(llbEndpoints, &l.llbEndpoints)
 	}
 	return &endpoint.ClusterLoadAssignment{
-		ClusterName: b.clusterName,
+		ClusterName: b.claName(),
 		Endpoints:   llbEndpoints,
 	}
 }
 
+// WithResourceName sets the name of the ClusterLoadAssignment, when the client requests it under another name than
+// the cluster, such as an xdstp:// name. The name is part of the cache key, so the renamed resource is cached.
+func (b *EndpointBuilder) WithResourceName(name string) *EndpointBuilder {
+	if name != b.clusterName {
+		b.resourceName = name
+	}
+	return b
+}
+
+// claName returns the name of the ClusterLoadAssignment.
+func (b *EndpointBuilder) claName() string {
+	if b.resourceName != "" {
+		return b.resourceName
+	}
+	return b.clusterName
+}
+
 // cluster with no endpoints
 func buildEmptyClusterLoadAssignment(clusterName string) *endpoint.ClusterLoadAssignment {
 	return &endpoint.ClusterLoadAssignment{
@@ -582,10 +1120,15 @@ func buildEmptyClusterLoadAssignment(clusterName string) *endpoint.ClusterLoadAs
 }
 
 func (b *EndpointBuilder) gateways() *model.NetworkGateways {
+	if b.push == nil || b.push.NetworkManager() == nil {
+		// Builders created outside of a fully initialized push context have no network gateways.
+		return nil
+	}
+	mgr := b.push.NetworkManager()
 	if b.IsDNSCluster() {
-		return b.push.NetworkManager().Unresolved
+		return mgr.Unresolved
 	}
-	return b.push.NetworkManager().NetworkGateways
+	return mgr.NetworkGateways
 }
 
 func ExtractEnvoyEndpoints(locEps []*LocalityEndpoints) []*endpoint.LocalityLbEndpoints {
@@ -598,7 +1141,9 @@ func ExtractEnvoyEndpoints(locEps []*LocalityEndpoints) []*endpoint.LocalityLbEn
 
 // buildEnvoyLbEndpoint packs the endpoint based on istio info.
 func buildEnvoyLbEndpoint(b *EndpointBuilder, e *model.IstioEndpoint, mtlsEnabled bool) *endpoint.LbEndpoint {
-	addr := util.BuildAddress(e.Address, e.EndpointPort)
+	// The address proxies connect to, which differs from the address of the endpoint behind a NAT.
+	address, port := b.translateAddress(e.Network, e.Address), e.EndpointPort
+	addr := util.BuildAddress(address, port)
 	healthStatus := e.HealthStatus
 	if features.DrainingLabel != "" && e.Labels[features.DrainingLabel] != "" {
 		healthStatus = model.Draining
@@ -644,8 +1189,10 @@ func buildEnvoyLbEndpoint(b *EndpointBuilder, e *model.IstioEndpoint, mtlsEnable
 		meta.TLSMode = ""
 	}
 	util.AppendLbEndpointMetadata(meta, ep.Metadata)
+	if features.EnableEndpointProxyProtocol {
+		appendProxyProtocolMetadata(ep, e.Labels[constants.ProxyProtocolLabel])
+	}
 
-	address, port := e.Address, e.EndpointPort
 	tunnelAddress, tunnelPort := address, model.HBoneInboundListenPort
 
 	supportsTunnel := false
@@ -655,7 +1202,7 @@ func buildEnvoyLbEndpoint(b *EndpointBuilder, e *model.IstioEndpoint, mtlsEnable
 	}
 
 	// Otherwise has ambient enabled. Note: this is a synthetic label, not existing in the real Pod.
-	if b.push.SupportsTunnel(e.Network, e.Address) {
+	if b.ambient.SupportsTunnel(e.Network, e.Address) {
 		supportsTunnel = true
 	}
 	// Otherwise supports tunnel
@@ -664,19 +1211,19 @@ func buildEnvoyLbEndpoint(b *EndpointBuilder, e *model.IstioEndpoint, mtlsEnable
 	if e.SupportsTunnel(model.TunnelHTTP) {
 		supportsTunnel = true
 	}
-	if b.proxy.IsProxylessGrpc() {
-		// Proxyless client cannot handle tunneling, even if the server can
+	if !b.capabilities.has(CapabilityTunnel) {
+		// The client cannot handle tunneling, such as proxyless gRPC, even if the server can
 		supportsTunnel = false
 	}
 
-	if !b.proxy.EnableHBONE() {
+	if !b.enableHBONE() {
 		supportsTunnel = false
 	}
 
 	// Setup tunnel information, if needed
 	if b.dir == model.TrafficDirectionInboundVIP {
 		// This is only used in waypoint proxy
-		inScope := waypointInScope(b.proxy, e)
+		inScope := waypointInScope(b.ambient, b.waypointScope(), e)
 		if !inScope {
 			// A waypoint can *partially* select a Service in edge cases. In this case, some % of requests will
 			// go through the waypoint, and the rest direct. Since these have already been load balanced across,
@@ -685,7 +1232,6 @@ func buildEnvoyLbEndpoint(b *EndpointBuilder, e *model.IstioEndpoint, mtlsEnable
 		}
 		// For inbound, we only use EDS for the VIP cases. The VIP cluster will point to encap listener.
 		if supportsTunnel {
-			address := e.Address
 			tunnelPort := 15008
 			// We will connect to CONNECT origination internal listener, telling it to tunnel to ip:15008,
 			// and add some detunnel metadata that had the original port.
@@ -696,12 +1242,13 @@ func buildEnvoyLbEndpoint(b *EndpointBuilder, e *model.IstioEndpoint, mtlsEnable
 			}
 		}
 	} else if supportsTunnel {
-		// Support connecting to server side waypoint proxy, if the destination has one. This is for sidecars and ingress.
-		if b.dir == model.TrafficDirectionOutbound && !b.proxy.IsWaypointProxy() && !b.proxy.IsAmbient() {
-			workloads := findWaypoints(b.push, e)
+		// Support connecting to server side waypoint proxy, if the destination has one. This is for sidecars and ingress,
+		// as well as waypoints sending to workloads owned by another waypoint (for example, mirrored traffic).
+		if b.dir == model.TrafficDirectionOutbound && b.viaDestinationWaypoint(e) {
+			workloads := findWaypoints(b.ambient, e)
 			if len(workloads) > 0 {
 				// TODO: load balance
-				tunnelAddress = workloads[0].String()
+				tunnelAddress = b.translateAddress(e.Network, workloads[0].String())
 			}
 		}
 		// Setup tunnel metadata so requests will go through the tunnel
@@ -719,25 +1266,99 @@ func buildEnvoyLbEndpoint(b *EndpointBuilder, e *model.IstioEndpoint, mtlsEnable
 	return ep
 }
 
-// waypointInScope computes whether the endpoint is owned by the waypoint
-func waypointInScope(waypoint *model.Proxy, e *model.IstioEndpoint) bool {
-	scope := waypoint.WaypointScope()
-	if scope.Namespace != e.Namespace {
+// appendProxyProtocolMetadata adds the PROXY protocol version expected by the endpoint to its transport socket
+// match metadata, so that the cluster sends the PROXY protocol header to this endpoint only.
+func appendProxyProtocolMetadata(ep *endpoint.LbEndpoint, version string) {
+	if version != constants.ProxyProtocolV1 && version != constants.ProxyProtocolV2 {
+		return
+	}
+	if ep.Metadata.FilterMetadata == nil {
+		ep.Metadata.FilterMetadata = map[string]*structpb.Struct{}
+	}
+	match := ep.Metadata.FilterMetadata[util.EnvoyTransportSocketMetadataKey]
+	if match == nil {
+		match = &structpb.Struct{Fields: map[string]*structpb.Value{}}
+		ep.Metadata.FilterMetadata[util.EnvoyTransportSocketMetadataKey] = match
+	}
+	match.Fields[model.ProxyProtocolShortname] = structpb.NewStringValue(version)
+}
+
+// appendSNIClusterMetadata adds the sniCluster transport socket match metadata to the endpoint of a network gateway,
+// so that connections to it use the SNI format of the cluster of the gateway.
+func appendSNIClusterMetadata(ep *endpoint.LbEndpoint, clusterID cluster.ID) {
+	if ep.Metadata.FilterMetadata == nil {
+		ep.Metadata.FilterMetadata = map[string]*structpb.Struct{}
+	}
+	match := ep.Metadata.FilterMetadata[util.EnvoyTransportSocketMetadataKey]
+	if match == nil {
+		match = &structpb.Struct{Fields: map[string]*structpb.Value{}}
+		ep.Metadata.FilterMetadata[util.EnvoyTransportSocketMetadataKey] = match
+	}
+	match.Fields[model.SNIClusterShortname] = structpb.NewStringValue(clusterID.String())
+}
+
+// viaDestinationWaypoint returns true if outbound traffic to the endpoint should be sent through the waypoint
+// serving the endpoint, if there is one.
+func (b *EndpointBuilder) viaDestinationWaypoint(e *model.IstioEndpoint) bool {
+	if b.nodeType == model.Waypoint {
+		// A waypoint never sends to itself, but must go through the waypoint of workloads it does not own.
+		return !waypointInScope(b.ambient, b.waypointScope(), e)
+	}
+	return b.nodeType != model.Ztunnel
+}
+
+// nodeName returns the node of the proxy, if known. Without a proxy, it is read from the labels of the client.
+func (b *EndpointBuilder) nodeName() string {
+	if b.proxy == nil {
+		return b.proxyLabels[label.LabelHostname]
+	}
+	return b.proxy.GetNodeName()
+}
+
+// enableHBONE returns true if the proxy can tunnel over HBONE. Without a proxy, only the ambient node types do.
+func (b *EndpointBuilder) enableHBONE() bool {
+	if b.proxy == nil {
+		return b.nodeType == model.Waypoint || b.nodeType == model.Ztunnel
+	}
+	return b.proxy.EnableHBONE()
+}
+
+// waypointScope returns the scope of the waypoint the endpoints are built for. It is empty for other proxies.
+func (b *EndpointBuilder) waypointScope() model.WaypointScope {
+	if b.proxy == nil || !b.proxy.IsWaypointProxy() {
+		return model.WaypointScope{}
+	}
+	return b.proxy.WaypointScope()
+}
+
+// discoverable returns true if the endpoint is discoverable from the proxy. Without a proxy, the client is only
+// known by its cluster.
+func (b *EndpointBuilder) discoverable(ep *model.IstioEndpoint) bool {
+	if !ep.IsDiscoverableFromProxy(b.proxy) {
 		return false
 	}
+	return b.proxy != nil || ep.DiscoverabilityPolicy != model.DiscoverableFromSameCluster ||
+		identifier.IsSameOrEmpty(ep.Locality.ClusterID.String(), b.clusterID.String())
+}
+
+// waypointInScope computes whether the endpoint is owned by the waypoint. Namespaces may run several waypoints,
+// selecting their workloads by service account or labels: the endpoint is only owned by the one it resolves to.
+func waypointInScope(ambient AmbientLookup, scope model.WaypointScope, e *model.IstioEndpoint) bool {
 	ident, _ := spiffe.ParseIdentity(e.ServiceAccount)
-	if scope.ServiceAccount != "" && (scope.ServiceAccount != ident.ServiceAccount) {
-		return false
+	if owner, found := ambient.WaypointScopeFor(e.Namespace, ident.ServiceAccount, e.Labels); found {
+		return owner == scope
 	}
-	return true
+	return scope.Matches(e.Namespace, ident.ServiceAccount, e.Labels)
 }
 
-func findWaypoints(push *model.PushContext, e *model.IstioEndpoint) []netip.Addr {
+func findWaypoints(ambient AmbientLookup, e *model.IstioEndpoint) []netip.Addr {
 	ident, _ := spiffe.ParseIdentity(e.ServiceAccount)
-	ips := push.WaypointsFor(model.WaypointScope{
-		Namespace:      e.Namespace,
-		ServiceAccount: ident.ServiceAccount,
-	})
+	scope, found := ambient.WaypointScopeFor(e.Namespace, ident.ServiceAccount, e.Labels)
+	if !found {
+		scope = model.WaypointScope{Namespace: e.Namespace, ServiceAccount: ident.ServiceAccount}
+	}
+	// Pod IP ranges may overlap across networks: only use the waypoints on the network of the endpoint.
+	ips := ambient.WaypointsFor(e.Network, scope)
 	return ips
 }
 
@@ -752,16 +1373,7 @@ func getOutlierDetectionAndLoadBalancerSettings(
 	outlierDetectionEnabled := false
 	var lbSettings *v1alpha3.LoadBalancerSettings
 
-	port := &model.Port{Port: portNumber}
-	policy := util.MergeTrafficPolicy(nil, destinationRule.TrafficPolicy, port)
-
-	for _, subset := range destinationRule.Subsets {
-		if subset.Name == subsetName {
-			policy = util.MergeTrafficPolicy(policy, subset.TrafficPolicy, port)
-			break
-		}
-	}
-
+	policy := util.EffectiveTrafficPolicy(destinationRule, subsetName, &model.Port{Port: portNumber})
 	if policy != nil {
 		lbSettings = policy.LoadBalancer
 		if policy.OutlierDetection != nil {
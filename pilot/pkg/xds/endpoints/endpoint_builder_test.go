@@ -15,15 +15,32 @@
 package endpoints
 
 import (
+	"fmt"
+	"math"
+	"net/netip"
 	"reflect"
 	"testing"
 
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	wrappers "github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/protobuf/types/known/structpb"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	networking "istio.io/api/networking/v1alpha3"
+	selectorpb "istio.io/api/type/v1beta1"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/util/protoconv"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/network"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
 )
 
 func TestPopulateFailoverPriorityLabels(t *testing.T) {
@@ -219,13 +236,10 @@ func TestPopulateFailoverPriorityLabels(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			b := EndpointBuilder{
-				proxy: &model.Proxy{
-					Metadata: &model.NodeMetadata{},
-					Labels: map[string]string{
-						"app": "foo",
-						"a":   "a",
-						"b":   "b",
-					},
+				proxyLabels: map[string]string{
+					"app": "foo",
+					"a":   "a",
+					"b":   "b",
 				},
 				push: &model.PushContext{
 					Mesh: tt.mesh,
@@ -241,3 +255,532 @@ func TestPopulateFailoverPriorityLabels(t *testing.T) {
 		})
 	}
 }
+
+func TestTrafficPolicy(t *testing.T) {
+	mesh := &meshconfig.MeshConfig{
+		LocalityLbSetting: &networking.LocalityLoadBalancerSetting{FailoverPriority: []string{"mesh"}},
+	}
+	dr := &config.Config{
+		Spec: &networking.DestinationRule{
+			TrafficPolicy: &networking.TrafficPolicy{
+				PortLevelSettings: []*networking.TrafficPolicy_PortTrafficPolicy{{
+					Port:             &networking.PortSelector{Number: 9090},
+					OutlierDetection: &networking.OutlierDetection{ConsecutiveErrors: 5},
+					LoadBalancer: &networking.LoadBalancerSettings{
+						LocalityLbSetting: &networking.LocalityLoadBalancerSetting{FailoverPriority: []string{"port"}},
+					},
+				}},
+			},
+			Subsets: []*networking.Subset{{Name: "v1"}},
+		},
+	}
+	tests := []struct {
+		name             string
+		port             int
+		expectedPriority []string
+		expectedFailover bool
+	}{
+		{
+			name:             "port level failover priority",
+			port:             9090,
+			expectedPriority: []string{"port"},
+			expectedFailover: true,
+		},
+		{
+			name:             "mesh default",
+			port:             8080,
+			expectedPriority: []string{"mesh"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := EndpointBuilder{
+				subsetName:      "v1",
+				port:            tt.port,
+				push:            &model.PushContext{Mesh: mesh},
+				destinationRule: model.ConvertConsolidatedDestRule(dr),
+			}
+			_, lbSetting, failover := b.TrafficPolicy()
+			if !reflect.DeepEqual(lbSetting.GetFailoverPriority(), tt.expectedPriority) {
+				t.Fatalf("expected failover priority %v, got %v", tt.expectedPriority, lbSetting.GetFailoverPriority())
+			}
+			if failover != tt.expectedFailover {
+				t.Fatalf("expected failover %v, got %v", tt.expectedFailover, failover)
+			}
+		})
+	}
+}
+
+func TestViaDestinationWaypoint(t *testing.T) {
+	waypoint := &model.Proxy{
+		Type:            model.Waypoint,
+		ConfigNamespace: "ns",
+		Metadata:        &model.NodeMetadata{},
+	}
+	tests := []struct {
+		name  string
+		proxy *model.Proxy
+		ep    *model.IstioEndpoint
+		want  bool
+	}{
+		{
+			name:  "sidecar",
+			proxy: &model.Proxy{Type: model.SidecarProxy, Metadata: &model.NodeMetadata{}},
+			ep:    &model.IstioEndpoint{Namespace: "ns"},
+			want:  true,
+		},
+		{
+			name:  "ztunnel",
+			proxy: &model.Proxy{Type: model.Ztunnel, Metadata: &model.NodeMetadata{}},
+			ep:    &model.IstioEndpoint{Namespace: "ns"},
+			want:  false,
+		},
+		{
+			name:  "waypoint owned endpoint",
+			proxy: waypoint,
+			ep:    &model.IstioEndpoint{Namespace: "ns", ServiceAccount: "spiffe://cluster.local/ns/ns/sa/default"},
+			want:  false,
+		},
+		{
+			name:  "waypoint endpoint in other namespace",
+			proxy: waypoint,
+			ep:    &model.IstioEndpoint{Namespace: "other", ServiceAccount: "spiffe://cluster.local/ns/other/sa/default"},
+			want:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &EndpointBuilder{proxy: tt.proxy, nodeType: tt.proxy.Type, ambient: noAmbient{}}
+			if got := b.viaDestinationWaypoint(tt.ep); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// networkWaypoints serves the waypoints of each network, which may use the same IPs.
+type networkWaypoints map[network.ID][]netip.Addr
+
+func (networkWaypoints) SupportsTunnel(network.ID, string) bool { return true }
+
+func (n networkWaypoints) WaypointsFor(nw network.ID, _ model.WaypointScope) []netip.Addr {
+	return n[nw]
+}
+
+func (networkWaypoints) WaypointScopeFor(string, string, labels.Instance) (model.WaypointScope, bool) {
+	return model.WaypointScope{}, false
+}
+
+func TestFindWaypointsOverlappingNetworks(t *testing.T) {
+	ambient := networkWaypoints{
+		"nw1": {netip.MustParseAddr("10.0.0.10")},
+		"nw2": {netip.MustParseAddr("10.0.0.20")},
+	}
+	for _, nw := range []network.ID{"nw1", "nw2", "nw3"} {
+		t.Run(nw.String(), func(t *testing.T) {
+			// The same pod IP on each network.
+			e := &model.IstioEndpoint{Address: "10.0.0.1", Network: nw, Namespace: "ns"}
+			if got, want := findWaypoints(ambient, e), ambient[nw]; !slices.Equal(got, want) {
+				t.Fatalf("expected waypoints %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+// scopedWaypoints resolves the owners of workloads among the scopes of the waypoints of the mesh.
+type scopedWaypoints struct {
+	scopes *model.WaypointScopes
+}
+
+func (scopedWaypoints) SupportsTunnel(network.ID, string) bool { return true }
+
+func (scopedWaypoints) WaypointsFor(network.ID, model.WaypointScope) []netip.Addr { return nil }
+
+func (w scopedWaypoints) WaypointScopeFor(namespace, serviceAccount string, workloadLabels labels.Instance) (model.WaypointScope, bool) {
+	return w.scopes.Resolve(namespace, serviceAccount, workloadLabels)
+}
+
+func TestWaypointInScopeSelectors(t *testing.T) {
+	waypoint := func(annotations map[string]string) *model.Proxy {
+		return &model.Proxy{
+			Type:            model.Waypoint,
+			ConfigNamespace: "ns",
+			Metadata:        &model.NodeMetadata{Annotations: annotations},
+		}
+	}
+	namespace := waypoint(nil)
+	app := waypoint(map[string]string{constants.WaypointSelector: "app=a"})
+	appVersion := waypoint(map[string]string{constants.WaypointSelector: "version=v2,app=a"})
+	ambient := scopedWaypoints{scopes: &model.WaypointScopes{}}
+	for _, w := range []*model.Proxy{namespace, app, appVersion} {
+		ambient.scopes.Insert(w.WaypointScope())
+	}
+	ep := func(l labels.Instance) *model.IstioEndpoint {
+		return &model.IstioEndpoint{Namespace: "ns", ServiceAccount: "spiffe://cluster.local/ns/ns/sa/default", Labels: l}
+	}
+	cases := []struct {
+		name  string
+		ep    *model.IstioEndpoint
+		owner *model.Proxy
+	}{
+		{"unselected", ep(labels.Instance{"app": "b"}), namespace},
+		{"selected", ep(labels.Instance{"app": "a"}), app},
+		{"overlapping selectors", ep(labels.Instance{"app": "a", "version": "v2"}), appVersion},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			// The inbound VIP endpoints of a service are partitioned across the waypoints of the namespace.
+			for _, w := range []*model.Proxy{namespace, app, appVersion} {
+				assert.Equal(t, waypointInScope(ambient, w.WaypointScope(), tt.ep), w == tt.owner)
+			}
+		})
+	}
+}
+
+func TestNormalizeWeights(t *testing.T) {
+	build := func(weights ...[]uint32) []*LocalityEndpoints {
+		var out []*LocalityEndpoints
+		for _, ws := range weights {
+			l := &LocalityEndpoints{}
+			for _, w := range ws {
+				l.append(&model.IstioEndpoint{}, &endpoint.LbEndpoint{LoadBalancingWeight: &wrappers.UInt32Value{Value: w}})
+			}
+			out = append(out, l)
+		}
+		return out
+	}
+	weightsOf := func(locEps []*LocalityEndpoints) [][]uint32 {
+		var out [][]uint32
+		for _, l := range locEps {
+			var ws []uint32
+			for _, ep := range l.llbEndpoints.LbEndpoints {
+				ws = append(ws, ep.GetLoadBalancingWeight().GetValue())
+			}
+			out = append(out, ws)
+		}
+		return out
+	}
+	tests := []struct {
+		name       string
+		in         []*LocalityEndpoints
+		max        uint32
+		normalized bool
+		want       [][]uint32
+	}{
+		{
+			name:       "within range",
+			in:         build([]uint32{1, 2}, []uint32{3}),
+			max:        10,
+			normalized: false,
+			want:       [][]uint32{{1, 2}, {3}},
+		},
+		{
+			name:       "above max",
+			in:         build([]uint32{100, 50}, []uint32{1}),
+			max:        10,
+			normalized: true,
+			want:       [][]uint32{{10, 5}, {1}},
+		},
+		{
+			name:       "overflow",
+			in:         build([]uint32{math.MaxUint32 / 2, math.MaxUint32 / 2}, []uint32{math.MaxUint32 / 2}),
+			max:        0,
+			normalized: true,
+			want:       [][]uint32{{1431655764, 1431655764}, {1431655764}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeWeights(tt.in, tt.max); got != tt.normalized {
+				t.Fatalf("expected normalized=%v, got %v", tt.normalized, got)
+			}
+			if got := weightsOf(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected weights %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPortRangeServicePorts(t *testing.T) {
+	svc := &model.Service{
+		Hostname: "kafka.ns.svc.cluster.local",
+		Ports: model.PortList{
+			{Name: "broker-0", Port: 9092, Protocol: protocol.TCP},
+			{Name: "broker-1", Port: 9093, Protocol: protocol.TCP},
+			{Name: "broker-2", Port: 9094, Protocol: protocol.TCP},
+			{Name: "admin", Port: 9095, Protocol: protocol.TCP},
+		},
+	}
+	b := &EndpointBuilder{service: svc, port: 9092}
+	if got := len(b.servicePorts()); got != 1 {
+		t.Fatalf("expected a single port, got %d", got)
+	}
+	b.portRangeEnd = 9094
+	got := slices.Map(b.servicePorts(), func(p *model.Port) int { return p.Port })
+	if !reflect.DeepEqual(got, []int{9092, 9093, 9094}) {
+		t.Fatalf("unexpected ports %v", got)
+	}
+
+	eep := &endpoint.LbEndpoint{}
+	withLbPortMetadata(eep, 9093)
+	if v := eep.GetMetadata().GetFilterMetadata()[util.EnvoyLbMetadataKey].GetFields()[util.LbPortMetadataKey].GetStringValue(); v != "9093" {
+		t.Fatalf("expected port metadata 9093, got %q", v)
+	}
+
+	// The other load balancer metadata, such as the subset keys, is kept.
+	eep = &endpoint.LbEndpoint{}
+	lbMetadata(eep).Fields["version"] = structpb.NewStringValue("v1")
+	withLbPortMetadata(eep, 9094)
+	fields := eep.GetMetadata().GetFilterMetadata()[util.EnvoyLbMetadataKey].GetFields()
+	if fields["version"].GetStringValue() != "v1" || fields[util.LbPortMetadataKey].GetStringValue() != "9094" {
+		t.Fatalf("expected the port metadata to be merged, got %v", fields)
+	}
+}
+
+func TestRuleSubsetName(t *testing.T) {
+	dr := model.ConvertConsolidatedDestRule(&config.Config{
+		Meta: config.Meta{
+			Name:        "reviews",
+			Namespace:   "default",
+			Annotations: map[string]string{constants.MirrorToAnnotation: "canary"},
+		},
+		Spec: &networking.DestinationRule{
+			Host:    "reviews.default.svc.cluster.local",
+			Subsets: []*networking.Subset{{Name: "canary", Labels: map[string]string{"track": "canary"}}},
+		},
+	})
+	b := &EndpointBuilder{destinationRule: dr, subsetName: model.MirrorSubsetName}
+	if got := b.ruleSubsetName(); got != "canary" {
+		t.Fatalf("expected the shadow cluster to select the canary subset, got %q", got)
+	}
+	if got := getSubSetLabels(b.DestinationRule(), b.ruleSubsetName()); !reflect.DeepEqual(got, labels.Instance{"track": "canary"}) {
+		t.Fatalf("unexpected subset labels %v", got)
+	}
+	b.subsetName = "stable"
+	if got := b.ruleSubsetName(); got != "stable" {
+		t.Fatalf("expected the subset to be unchanged, got %q", got)
+	}
+}
+
+func TestKeyIncludesWorkloadSelector(t *testing.T) {
+	key := func(selector map[string]string) any {
+		dr := model.ConvertConsolidatedDestRule(&config.Config{
+			Meta: config.Meta{Name: "reviews", Namespace: "default"},
+			Spec: &networking.DestinationRule{
+				Host:             "reviews.default.svc.cluster.local",
+				WorkloadSelector: &selectorpb.WorkloadSelector{MatchLabels: selector},
+			},
+		})
+		b := &EndpointBuilder{destinationRule: dr, service: &model.Service{}, proxy: &model.Proxy{}}
+		return b.Key()
+	}
+	if key(map[string]string{"app": "a"}) == key(map[string]string{"app": "b"}) {
+		t.Fatalf("expected rules with different workload selectors to produce different keys")
+	}
+	if key(map[string]string{"app": "a"}) != key(map[string]string{"app": "a"}) {
+		t.Fatalf("expected rules with the same workload selector to produce the same key")
+	}
+}
+
+func TestKeyLocality(t *testing.T) {
+	key := func(locality string, policy *networking.TrafficPolicy) any {
+		b := &EndpointBuilder{
+			service:  &model.Service{},
+			proxy:    &model.Proxy{},
+			locality: util.ConvertLocality(locality),
+			push: &model.PushContext{Mesh: &meshconfig.MeshConfig{
+				LocalityLbSetting: &networking.LocalityLoadBalancerSetting{Enabled: &wrappers.BoolValue{Value: true}},
+			}},
+		}
+		if policy != nil {
+			b.destinationRule = model.ConvertConsolidatedDestRule(&config.Config{
+				Meta: config.Meta{Name: "reviews", Namespace: "default"},
+				Spec: &networking.DestinationRule{Host: "reviews.default.svc.cluster.local", TrafficPolicy: policy},
+			})
+		}
+		return b.Key()
+	}
+	failover := &networking.TrafficPolicy{OutlierDetection: &networking.OutlierDetection{ConsecutiveErrors: 5}}
+	distribute := &networking.TrafficPolicy{LoadBalancer: &networking.LoadBalancerSettings{
+		LocalityLbSetting: &networking.LocalityLoadBalancerSetting{
+			Distribute: []*networking.LocalityLoadBalancerSetting_Distribute{{From: "a/*", To: map[string]uint32{"a/*": 100}}},
+		},
+	}}
+
+	// Without locality load balancing, the proxies of all localities share the endpoints.
+	assert.Equal(t, key("a/b/c", nil), key("d/e/f", nil))
+	assert.Equal(t, key("a/b/c", &networking.TrafficPolicy{}), key("d/e/f", &networking.TrafficPolicy{}))
+	// With locality failover or distribution, the endpoints depend on the locality of the proxy.
+	assert.Equal(t, key("a/b/c", failover) == key("d/e/f", failover), false)
+	assert.Equal(t, key("a/b/c", distribute) == key("d/e/f", distribute), false)
+	assert.Equal(t, key("a/b/c", failover), key("a/b/c", failover))
+}
+
+func TestAppendProxyProtocolMetadata(t *testing.T) {
+	ep := &endpoint.LbEndpoint{Metadata: &core.Metadata{}}
+	appendProxyProtocolMetadata(ep, "v3")
+	if ep.Metadata.FilterMetadata != nil {
+		t.Fatalf("expected no metadata for an invalid version, got %v", ep.Metadata)
+	}
+	util.AppendLbEndpointMetadata(&model.EndpointMetadata{TLSMode: model.IstioMutualTLSModeLabel}, ep.Metadata)
+	appendProxyProtocolMetadata(ep, "v2")
+	fields := ep.Metadata.FilterMetadata[util.EnvoyTransportSocketMetadataKey].GetFields()
+	if fields[model.TLSModeLabelShortname].GetStringValue() != model.IstioMutualTLSModeLabel ||
+		fields[model.ProxyProtocolShortname].GetStringValue() != "v2" {
+		t.Fatalf("unexpected transport socket match metadata %v", fields)
+	}
+}
+
+func TestTunnelThroughGateway(t *testing.T) {
+	tunneled := &endpoint.LbEndpoint{Metadata: &core.Metadata{FilterMetadata: map[string]*structpb.Struct{
+		model.TunnelLabelShortName: util.BuildTunnelMetadataStruct("10.0.0.1", "10.0.0.1", 8080, model.HBoneInboundListenPort),
+	}}}
+	gateways := []model.NetworkGateway{
+		{Network: "nw2", Addr: "1.1.1.1", Port: 15443},
+		{Network: "nw2", Addr: "2.2.2.2", Port: 15443, HBONEPort: 15008},
+		{Network: "nw2", Addr: "3.3.3.3", Port: 15443, HBONEPort: 15008},
+	}
+	tunnelAddress := func(ep *endpoint.LbEndpoint) string {
+		return ep.GetMetadata().GetFilterMetadata()[model.TunnelLabelShortName].GetFields()["address"].GetStringValue()
+	}
+	tests := []struct {
+		name         string
+		ep           *endpoint.LbEndpoint
+		index        int
+		gateways     []model.NetworkGateway
+		fallbackPort uint32
+		want         string
+	}{
+		{"not tunneled", &endpoint.LbEndpoint{}, 0, gateways, 15008, ""},
+		{"no hbone gateway", tunneled, 0, gateways[:1], 0, ""},
+		{"first hbone gateway", tunneled, 0, gateways, 0, "2.2.2.2:15008"},
+		{"spread across gateways", tunneled, 1, gateways, 0, "3.3.3.3:15008"},
+		{"declared hbone port preferred to fallback", tunneled, 0, gateways, 15009, "2.2.2.2:15008"},
+		{"fallback port", tunneled, 0, gateways[:1], 15008, "1.1.1.1:15008"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tunnelThroughGateway(tt.ep, tt.index, tt.gateways, tt.fallbackPort)
+			if tt.want == "" {
+				if got != nil {
+					t.Fatalf("expected the endpoint not to be tunneled through a gateway, got %v", got)
+				}
+				return
+			}
+			if tunnelAddress(got) != tt.want {
+				t.Fatalf("expected tunnel address %v, got %v", tt.want, tunnelAddress(got))
+			}
+			destination := got.GetMetadata().GetFilterMetadata()[model.TunnelLabelShortName].GetFields()["destination"].GetStringValue()
+			if destination != "10.0.0.1:8080" {
+				t.Fatalf("expected the inner destination to be preserved, got %v", destination)
+			}
+			if tunnelAddress(tt.ep) != "10.0.0.1:15008" {
+				t.Fatalf("the original endpoint was modified")
+			}
+		})
+	}
+}
+
+func TestGatewayHBONEFallbackPort(t *testing.T) {
+	ep := &model.IstioEndpoint{Address: "10.0.0.1", Network: "nw2"}
+	ztunnel := &EndpointBuilder{ambient: networkWaypoints{}}
+	sidecar := &EndpointBuilder{ambient: noAmbient{}}
+
+	assert.Equal(t, ztunnel.gatewayHBONEFallbackPort(ep), uint32(0))
+	test.SetForTest(t, &features.AmbientEastWestHBONE, true)
+	assert.Equal(t, ztunnel.gatewayHBONEFallbackPort(ep), uint32(model.HBoneInboundListenPort))
+	// Endpoints not captured by ztunnel can still be reached through SNI routing.
+	assert.Equal(t, sidecar.gatewayHBONEFallbackPort(ep), uint32(0))
+}
+
+func TestMultiPortEndpoints(t *testing.T) {
+	svc := &model.Service{
+		Hostname: "example.ns.svc.cluster.local",
+		Ports: model.PortList{
+			{Name: "http", Port: 80, Protocol: protocol.HTTP},
+			{Name: "grpc", Port: 90, Protocol: protocol.GRPC},
+			{Name: "tcp", Port: 100, Protocol: protocol.TCP},
+		},
+		Attributes: model.ServiceAttributes{Namespace: "ns"},
+	}
+	index := model.NewEndpointIndex(model.DisabledCache{})
+	index.UpdateServiceEndpoints(model.ShardKey{Cluster: "c1"}, string(svc.Hostname), "ns", []*model.IstioEndpoint{{
+		Address: "10.0.0.1", ServicePortName: "http", EndpointPort: 8080, AdditionalPorts: map[string]uint32{"grpc": 9090},
+		Namespace: "ns", Locality: model.Locality{ClusterID: "c1"},
+	}})
+
+	for port, want := range map[int][]string{
+		80:  {"10.0.0.1:8080"},
+		90:  {"10.0.0.1:9090"},
+		100: nil,
+	} {
+		b := New(model.BuildSubsetKey(model.TrafficDirectionOutbound, "", svc.Hostname, port), WithService(svc), WithClusterID("c1"))
+		var got []string
+		for _, llb := range b.BuildClusterLoadAssignment(index).Endpoints {
+			for _, lbEp := range llb.LbEndpoints {
+				sa := lbEp.GetEndpoint().GetAddress().GetSocketAddress()
+				got = append(got, fmt.Sprintf("%s:%d", sa.GetAddress(), sa.GetPortValue()))
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("port %d: got endpoints %v, want %v", port, got, want)
+		}
+	}
+}
+
+func TestResourceVersion(t *testing.T) {
+	svc := &model.Service{
+		Hostname:   "example.ns.svc.cluster.local",
+		Ports:      model.PortList{{Name: "http", Port: 80, Protocol: protocol.HTTP}},
+		Attributes: model.ServiceAttributes{Namespace: "ns"},
+	}
+	clusterName := model.BuildSubsetKey(model.TrafficDirectionOutbound, "", svc.Hostname, 80)
+	// version builds the ClusterLoadAssignment of the service on a new index, as another istiod replica would.
+	version := func(address string) string {
+		index := model.NewEndpointIndex(model.DisabledCache{})
+		index.UpdateServiceEndpoints(model.ShardKey{Cluster: "c1"}, string(svc.Hostname), "ns", []*model.IstioEndpoint{{
+			Address: address, ServicePortName: "http", EndpointPort: 8080, Namespace: "ns", Locality: model.Locality{ClusterID: "c1"},
+		}})
+		b := New(clusterName, WithService(svc), WithClusterID("c1"))
+		return ResourceVersion(protoconv.MessageToAny(b.BuildClusterLoadAssignment(index)))
+	}
+
+	v1 := version("10.0.0.1")
+	same := version("10.0.0.1")
+	changed := version("10.0.0.2")
+	// The versions of the same ClusterLoadAssignment match, whichever index or replica built it.
+	assert.Equal(t, same, v1)
+	assert.Equal(t, changed != v1, true)
+	assert.Equal(t, ResourceVersion(nil), "")
+}
+
+func TestShardsMissing(t *testing.T) {
+	svc := &model.Service{
+		Hostname:   "example.ns.svc.cluster.local",
+		Ports:      model.PortList{{Name: "http", Port: 80, Protocol: protocol.HTTP}},
+		Attributes: model.ServiceAttributes{Namespace: "ns"},
+	}
+	index := model.NewEndpointIndex(model.DisabledCache{})
+	shard := model.ShardKey{Cluster: "c1"}
+	b := New(model.BuildSubsetKey(model.TrafficDirectionOutbound, "", svc.Hostname, 80), WithService(svc)).(*EndpointBuilder)
+
+	// No registry reported the endpoints of the service yet.
+	assert.Equal(t, len(b.BuildClusterLoadAssignment(index).Endpoints), 0)
+	assert.Equal(t, b.ShardsMissing(), true)
+
+	index.UpdateServiceEndpoints(shard, string(svc.Hostname), "ns", []*model.IstioEndpoint{{
+		Address: "10.0.0.1", EndpointPort: 8080, ServicePortName: "http", Namespace: "ns", HealthStatus: model.Healthy,
+	}})
+	assert.Equal(t, len(b.BuildClusterLoadAssignment(index).Endpoints), 1)
+	assert.Equal(t, b.ShardsMissing(), false)
+
+	// The registry reports no endpoints.
+	index.UpdateServiceEndpoints(shard, string(svc.Hostname), "ns", nil)
+	assert.Equal(t, len(b.BuildClusterLoadAssignment(index).Endpoints), 0)
+	assert.Equal(t, b.ShardsMissing(), false)
+
+	// The registry is removed, as during the resync of a remote cluster.
+	index.DeleteShard(shard)
+	assert.Equal(t, len(b.BuildClusterLoadAssignment(index).Endpoints), 0)
+	assert.Equal(t, b.ShardsMissing(), true)
+}
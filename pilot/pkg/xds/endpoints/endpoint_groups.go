@@ -0,0 +1,76 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"google.golang.org/protobuf/proto"
+)
+
+// endpointGroup classifies the endpoints which must only receive traffic once the endpoints of the preceding groups
+// are unavailable. The primary endpoints keep the priorities assigned by the locality load balancing settings, and
+// each following group is assigned the priority after the last one of the preceding groups.
+type endpointGroup uint8
+
+const (
+	primaryGroup endpointGroup = iota
+	// remoteNodeGroup holds the endpoints on other nodes than the proxy, for the services preferring node local
+	// endpoints.
+	remoteNodeGroup
+	// remoteNetworkGroup holds the endpoints of remote networks, in the Priority cross-network failover mode.
+	remoteNetworkGroup
+	// standbyGroup holds the standby endpoints.
+	standbyGroup
+	// directFallbackGroup holds the endpoints reaching workloads directly, bypassing their waypoints.
+	directFallbackGroup
+	endpointGroups
+)
+
+// splitGroups separates the primary endpoints from the endpoints of the other groups, returned in the order of the
+// groups.
+func splitGroups(locEps []*LocalityEndpoints) ([]*LocalityEndpoints, [][]*LocalityEndpoints) {
+	var primary []*LocalityEndpoints
+	groups := make([][]*LocalityEndpoints, endpointGroups-1)
+	for _, l := range locEps {
+		if l.group == primaryGroup {
+			primary = append(primary, l)
+		} else {
+			groups[l.group-1] = append(groups[l.group-1], l)
+		}
+	}
+	return primary, groups
+}
+
+// appendGroups appends the endpoints of the groups split by splitGroups after the prioritized endpoints, each
+// non-empty group at the priority following the ones before it.
+func appendGroups(out []*endpoint.LocalityLbEndpoints, groups [][]*LocalityEndpoints) []*endpoint.LocalityLbEndpoints {
+	for _, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		priority := uint32(0)
+		for _, e := range out {
+			if e.Priority >= priority {
+				priority = e.Priority + 1
+			}
+		}
+		for _, f := range group {
+			llb := proto.Clone(&f.llbEndpoints).(*endpoint.LocalityLbEndpoints)
+			llb.Priority = priority
+			out = append(out, llb)
+		}
+	}
+	return out
+}
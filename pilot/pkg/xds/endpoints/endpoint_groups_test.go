@@ -0,0 +1,45 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestAppendGroups(t *testing.T) {
+	locEps := func(group endpointGroup) *LocalityEndpoints {
+		return &LocalityEndpoints{group: group}
+	}
+	primary, groups := splitGroups([]*LocalityEndpoints{
+		locEps(directFallbackGroup), locEps(primaryGroup), locEps(standbyGroup), locEps(primaryGroup),
+	})
+	assert.Equal(t, len(primary), 2)
+
+	priorities := func(out []*endpoint.LocalityLbEndpoints) []uint32 {
+		var p []uint32
+		for _, l := range out {
+			p = append(p, l.Priority)
+		}
+		return p
+	}
+	// The groups follow the highest priority of the primary endpoints, without gaps for the empty groups.
+	prioritized := []*endpoint.LocalityLbEndpoints{{Priority: 0}, {Priority: 2}}
+	assert.Equal(t, priorities(appendGroups(prioritized, groups)), []uint32{0, 2, 3, 4})
+	assert.Equal(t, priorities(appendGroups(ExtractEnvoyEndpoints(primary), groups)), []uint32{0, 0, 1, 2})
+}
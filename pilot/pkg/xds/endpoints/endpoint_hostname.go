@@ -0,0 +1,77 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"net/netip"
+	"strings"
+
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+)
+
+// endpointHostname returns the hostname that produced an endpoint of a ServiceEntry: the address of the endpoint if
+// it is a DNS name, the host of the ServiceEntry otherwise.
+func (b *EndpointBuilder) endpointHostname(e *model.IstioEndpoint) string {
+	if e.Address != "" {
+		if _, err := netip.ParseAddr(e.Address); err != nil {
+			return e.Address
+		}
+	}
+	return string(b.service.Hostname)
+}
+
+// podHostname returns the fully qualified hostname of the pod of a Kubernetes endpoint,
+// "<hostname>.<subdomain>.<pod namespace>.svc.<cluster domain>", or "" if the pod has no subdomain and so no such
+// hostname. The cluster domain is the one of the service.
+func (b *EndpointBuilder) podHostname(e *model.IstioEndpoint) string {
+	if e.HostName == "" || e.SubDomain == "" {
+		return ""
+	}
+	_, domain, found := strings.Cut(string(b.service.Hostname), ".svc.")
+	if !found {
+		return ""
+	}
+	return e.HostName + "." + e.SubDomain + "." + e.Namespace + ".svc." + domain
+}
+
+// applyHostname sets the Envoy hostname of the endpoint, for clusters rewriting the Host header per
+// endpoint with auto_host_rewrite: the hostname that produced it for ServiceEntries, also added to its load balancer
+// metadata, or the hostname of its pod for Kubernetes services.
+func (b *EndpointBuilder) applyHostname(e *model.IstioEndpoint, eep *lbEndpointCopy) {
+	var hostname string
+	switch b.service.Attributes.ServiceRegistry {
+	case provider.External:
+		if features.EnableEndpointHostnameMetadata || features.EnableEndpointHostname {
+			hostname = b.endpointHostname(e)
+		}
+	case provider.Kubernetes:
+		if features.EnableEndpointHostname {
+			hostname = b.podHostname(e)
+		}
+	}
+	if hostname == "" {
+		return
+	}
+	lbEp := eep.mutable()
+	lbEp.GetEndpoint().Hostname = hostname
+	if features.EnableEndpointHostnameMetadata && b.service.Attributes.ServiceRegistry == provider.External {
+		lbMetadata(lbEp).Fields[util.LbHostnameMetadataKey] = structpb.NewStringValue(hostname)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestEndpointHostname(t *testing.T) {
+	type hostnames struct {
+		Hostname string
+		Metadata string
+	}
+	build := func(registry provider.ID) map[string]hostnames {
+		svc := endpointstest.NewService()
+		svc.Hostname = "api.example.com"
+		svc.Attributes.ServiceRegistry = registry
+		var eps []*model.IstioEndpoint
+		for _, address := range []string{"10.0.0.1", "api.us.example.com"} {
+			ep := endpointstest.NewEndpoint(address)
+			ep.HealthStatus = model.Healthy
+			eps = append(eps, ep)
+		}
+		index := endpointstest.NewIndex(svc, eps...)
+		b := New(endpointstest.OutboundCluster(svc), WithService(svc), WithClusterID(endpointstest.ClusterID))
+		out := map[string]hostnames{}
+		for _, llb := range b.BuildClusterLoadAssignment(index).Endpoints {
+			for _, lbEp := range llb.LbEndpoints {
+				lb := lbEp.GetMetadata().GetFilterMetadata()[util.EnvoyLbMetadataKey].GetFields()
+				out[lbEp.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()] = hostnames{
+					Hostname: lbEp.GetEndpoint().GetHostname(),
+					Metadata: lb[util.LbHostnameMetadataKey].GetStringValue(),
+				}
+			}
+		}
+		return out
+	}
+
+	assert.Equal(t, build(provider.External), map[string]hostnames{"10.0.0.1": {}, "api.us.example.com": {}})
+
+	test.SetForTest(t, &features.EnableEndpointHostnameMetadata, true)
+	assert.Equal(t, build(provider.External), map[string]hostnames{
+		"10.0.0.1":           {Hostname: "api.example.com", Metadata: "api.example.com"},
+		"api.us.example.com": {Hostname: "api.us.example.com", Metadata: "api.us.example.com"},
+	})
+	// Only the endpoints of ServiceEntries carry their hostname.
+	assert.Equal(t, build(provider.Kubernetes), map[string]hostnames{"10.0.0.1": {}, "api.us.example.com": {}})
+}
+
+func TestEndpointHostnameWithoutMetadata(t *testing.T) {
+	build := func(svc *model.Service, eps ...*model.IstioEndpoint) map[string]string {
+		index := endpointstest.NewIndex(svc, eps...)
+		b := New(endpointstest.OutboundCluster(svc), WithService(svc), WithClusterID(endpointstest.ClusterID))
+		out := map[string]string{}
+		for _, llb := range b.BuildClusterLoadAssignment(index).Endpoints {
+			for _, lbEp := range llb.LbEndpoints {
+				_, f := lbEp.GetMetadata().GetFilterMetadata()[util.EnvoyLbMetadataKey].GetFields()[util.LbHostnameMetadataKey]
+				assert.Equal(t, f, false)
+				out[lbEp.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()] = lbEp.GetEndpoint().GetHostname()
+			}
+		}
+		return out
+	}
+	service := func(hostname string, registry provider.ID) *model.Service {
+		svc := endpointstest.NewService()
+		svc.Hostname = host.Name(hostname)
+		svc.Attributes.ServiceRegistry = registry
+		return svc
+	}
+	ep := func(address, hostname, subdomain string) *model.IstioEndpoint {
+		ep := endpointstest.NewEndpoint(address)
+		ep.HostName, ep.SubDomain, ep.HealthStatus = hostname, subdomain, model.Healthy
+		return ep
+	}
+	kube := service("web.ns.svc.cluster.local", provider.Kubernetes)
+	external := service("api.example.com", provider.External)
+
+	assert.Equal(t, build(kube, ep("10.0.0.1", "web-0", "web")), map[string]string{"10.0.0.1": ""})
+
+	test.SetForTest(t, &features.EnableEndpointHostname, true)
+	assert.Equal(t, build(kube, ep("10.0.0.1", "web-0", "web"), ep("10.0.0.2", "web-1", "")), map[string]string{
+		"10.0.0.1": "web-0.web.ns.svc.cluster.local",
+		// Pods without a subdomain have no fully qualified hostname.
+		"10.0.0.2": "",
+	})
+	assert.Equal(t, build(external, ep("10.0.0.1", "", ""), ep("api.us.example.com", "", "")), map[string]string{
+		"10.0.0.1":           "api.example.com",
+		"api.us.example.com": "api.us.example.com",
+	})
+}
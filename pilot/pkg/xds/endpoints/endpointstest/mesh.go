@@ -0,0 +1,243 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package endpointstest generates synthetic meshes to test, benchmark and fuzz the endpoint builder.
+package endpointstest
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	memregistry "istio.io/istio/pilot/pkg/serviceregistry/memory"
+	"istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/network"
+	"istio.io/istio/pkg/test"
+)
+
+const (
+	// Namespace is the namespace of the services of the mesh.
+	Namespace = "ns"
+	// Port is the port of the services of the mesh, named "http".
+	Port = 80
+	// EndpointPort is the port of the endpoints of the mesh.
+	EndpointPort = 8080
+	// GatewayPort is the port of the network gateways of the mesh.
+	GatewayPort = 15443
+)
+
+// MeshOptions describe the size of a synthetic mesh.
+type MeshOptions struct {
+	// Services is the number of services of the mesh.
+	Services int
+	// Endpoints is the number of endpoints of each service.
+	Endpoints int
+	// Networks is the number of networks the endpoints are spread across. Each network is a cluster with a single
+	// locality and a gateway. With a single network, the mesh is not multi-network and has no gateway.
+	Networks int
+	// DestinationRule adds a DestinationRule to each service, with a subset per version and outlier detection, so
+	// that the endpoints are load balanced by locality.
+	DestinationRule bool
+	// HBONE labels the endpoints as supporting HBONE, and enables it on the proxies of the mesh. It only applies with
+	// PILOT_ENABLE_HBONE.
+	HBONE bool
+	// AddressTranslations are the address translations of the networks of MeshNetworks, by network.
+	AddressTranslations map[string][]mesh.NetworkAddressTranslation
+}
+
+// Mesh is a synthetic mesh of N services, each with M endpoints spread across K networks.
+type Mesh struct {
+	MeshOptions
+
+	Services []*model.Service
+	Gateways []model.NetworkGateway
+	// EndpointIndex holds the endpoints of the services, sharded by cluster.
+	EndpointIndex *model.EndpointIndex
+	// Push is an initialized push context for the mesh. Its service index holds the endpoints, as used by CDS.
+	Push *model.PushContext
+
+	env *model.Environment
+}
+
+// NewMesh generates a mesh of the given size. The endpoints of each service are assigned round-robin to the
+// networks, and have distinct addresses across the mesh.
+func NewMesh(t test.Failer, opts MeshOptions) *Mesh {
+	if opts.Networks < 1 {
+		opts.Networks = 1
+	}
+	m := &Mesh{MeshOptions: opts}
+	for s := 0; s < opts.Services; s++ {
+		m.Services = append(m.Services, &model.Service{
+			Hostname:   Hostname(s),
+			Attributes: model.ServiceAttributes{Name: fmt.Sprintf("svc-%d", s), Namespace: Namespace},
+			Ports:      model.PortList{{Port: Port, Protocol: protocol.HTTP, Name: "http"}},
+		})
+	}
+	if opts.Networks > 1 {
+		for n := 0; n < opts.Networks; n++ {
+			m.Gateways = append(m.Gateways, model.NetworkGateway{
+				Network: Network(n),
+				Cluster: Cluster(n),
+				Addr:    address(0xff0000 + n),
+				Port:    GatewayPort,
+			})
+		}
+	}
+
+	env := model.NewEnvironment()
+	m.EndpointIndex = model.NewEndpointIndex(model.DisabledCache{})
+	env.EndpointIndex = m.EndpointIndex
+	for s, svc := range m.Services {
+		shards, _ := m.EndpointIndex.GetOrCreateEndpointShard(string(svc.Hostname), Namespace)
+		shards.Lock()
+		for e := 0; e < opts.Endpoints; e++ {
+			n := e % opts.Networks
+			key := model.ShardKey{Cluster: Cluster(n)}
+			labels := map[string]string{"app": svc.Attributes.Name, "version": fmt.Sprintf("v%d", e%2)}
+			if opts.HBONE {
+				labels[model.TunnelLabel] = model.TunnelHTTP
+			}
+			shards.Shards[key] = append(shards.Shards[key], &model.IstioEndpoint{
+				Address:         address(s*opts.Endpoints + e),
+				EndpointPort:    EndpointPort,
+				ServicePortName: "http",
+				HostName:        string(svc.Hostname),
+				Namespace:       Namespace,
+				Labels:          labels,
+				TLSMode:         model.IstioMutualTLSModeLabel,
+				Network:         Network(n),
+				Locality: model.Locality{
+					Label:     Locality(n),
+					ClusterID: Cluster(n),
+				},
+			})
+		}
+		shards.Unlock()
+	}
+	sd := memregistry.NewServiceDiscovery(m.Services...)
+	sd.AddGateways(m.Gateways...)
+	env.ServiceDiscovery = sd
+	env.ConfigStore = memory.Make(collections.Pilot)
+	if opts.DestinationRule {
+		for _, svc := range m.Services {
+			if _, err := env.ConfigStore.Create(destinationRule(svc)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	env.Watcher = mesh.NewFixedWatcher(mesh.DefaultMeshConfig())
+	env.NetworksWatcher = mesh.NewFixedNetworksWatcher(nil)
+	env.NetworksWatcher.SetAddressTranslations(opts.AddressTranslations)
+	env.Init()
+	if err := env.InitNetworksManager(model.NewEndpointIndexUpdater(env.EndpointIndex)); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.PushContext().InitContext(env, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	m.env = env
+	m.Push = env.PushContext()
+
+	return m
+}
+
+// Proxy returns a sidecar proxy of the given network of the mesh, set up for its push context.
+func (m *Mesh) Proxy(n int) *model.Proxy {
+	p := &model.Proxy{
+		Type:            model.SidecarProxy,
+		ID:              "app.test",
+		IPAddresses:     []string{"1.1.1.1"},
+		ConfigNamespace: Namespace,
+		DNSDomain:       Namespace + ".svc.cluster.local",
+		Metadata: &model.NodeMetadata{
+			Namespace:    Namespace,
+			Network:      Network(n),
+			ClusterID:    Cluster(n),
+			IstioVersion: "1.20.0",
+			EnableHBONE:  model.StringBool(m.HBONE),
+		},
+		Locality: util.ConvertLocality(Locality(n)),
+	}
+	p.IstioVersion = model.ParseIstioVersion(p.Metadata.IstioVersion)
+	p.SetSidecarScope(m.Push)
+	p.SetServiceTargets(m.env.ServiceDiscovery)
+	p.SetGatewaysForProxy(m.Push)
+	p.SetTelemetryEndpointMetadataKeys(m.Push)
+	p.DiscoverIPMode()
+	return p
+}
+
+// destinationRule returns the DestinationRule of a service of a mesh with DestinationRule set.
+func destinationRule(svc *model.Service) config.Config {
+	return config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.DestinationRule,
+			Name:             svc.Attributes.Name,
+			Namespace:        Namespace,
+		},
+		Spec: &networking.DestinationRule{
+			Host: string(svc.Hostname),
+			TrafficPolicy: &networking.TrafficPolicy{
+				OutlierDetection: &networking.OutlierDetection{Consecutive_5XxErrors: wrapperspb.UInt32(5)},
+				LoadBalancer: &networking.LoadBalancerSettings{
+					LocalityLbSetting: &networking.LocalityLoadBalancerSetting{Enabled: wrapperspb.Bool(true)},
+				},
+			},
+			Subsets: []*networking.Subset{
+				{Name: "v0", Labels: map[string]string{"version": "v0"}},
+				{Name: "v1", Labels: map[string]string{"version": "v1"}},
+			},
+		},
+	}
+}
+
+// ClusterName returns the outbound cluster of the s-th service of a mesh.
+func ClusterName(s int) string {
+	return model.BuildSubsetKey(model.TrafficDirectionOutbound, "", Hostname(s), Port)
+}
+
+// Hostname returns the hostname of the s-th service of a mesh.
+func Hostname(s int) host.Name {
+	return host.Name(fmt.Sprintf("svc-%d.%s.svc.cluster.local", s, Namespace))
+}
+
+// Network returns the n-th network of a mesh.
+func Network(n int) network.ID {
+	return network.ID(fmt.Sprintf("network-%d", n))
+}
+
+// Cluster returns the cluster of the n-th network of a mesh.
+func Cluster(n int) cluster.ID {
+	return cluster.ID(fmt.Sprintf("cluster-%d", n))
+}
+
+// Locality returns the locality of the n-th network of a mesh.
+func Locality(n int) string {
+	return fmt.Sprintf("region/zone-%d/subzone", n)
+}
+
+// address returns the i-th address of the 10.0.0.0/8 range.
+func address(i int) string {
+	return fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+}
@@ -0,0 +1,78 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpointstest
+
+import (
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+	"istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/protocol"
+)
+
+const (
+	// ServiceHostname is the hostname of the service returned by NewService.
+	ServiceHostname host.Name = "example.ns.svc.cluster.local"
+	// ClusterID is the cluster of the endpoints returned by NewEndpoint.
+	ClusterID cluster.ID = "c1"
+)
+
+// Shard is the shard of the endpoints of the index returned by NewIndex.
+var Shard = model.ShardKey{Cluster: ClusterID, Provider: provider.Kubernetes}
+
+// NewService returns a service of the namespace with a single "http" port.
+func NewService() *model.Service {
+	return &model.Service{
+		Hostname:   ServiceHostname,
+		Ports:      model.PortList{{Name: "http", Port: Port, Protocol: protocol.HTTP}},
+		Attributes: model.ServiceAttributes{Namespace: Namespace},
+	}
+}
+
+// NewEndpoint returns an endpoint of the "http" port of a service of the namespace, in ClusterID.
+func NewEndpoint(address string) *model.IstioEndpoint {
+	return &model.IstioEndpoint{
+		Address:         address,
+		EndpointPort:    EndpointPort,
+		ServicePortName: "http",
+		Namespace:       Namespace,
+		Locality:        model.Locality{ClusterID: ClusterID},
+	}
+}
+
+// NewIndex returns an EndpointIndex holding the endpoints of the service in Shard.
+func NewIndex(svc *model.Service, eps ...*model.IstioEndpoint) *model.EndpointIndex {
+	index := model.NewEndpointIndex(model.DisabledCache{})
+	index.UpdateServiceEndpoints(Shard, string(svc.Hostname), svc.Attributes.Namespace, eps)
+	return index
+}
+
+// OutboundCluster returns the outbound cluster of the port of the service.
+func OutboundCluster(svc *model.Service) string {
+	return model.BuildSubsetKey(model.TrafficDirectionOutbound, "", svc.Hostname, svc.Ports[0].Port)
+}
+
+// Addresses returns the addresses of the endpoints of the ClusterLoadAssignment, by locality and priority.
+func Addresses(cla *endpoint.ClusterLoadAssignment) []string {
+	var out []string
+	for _, llb := range cla.GetEndpoints() {
+		for _, lbEp := range llb.LbEndpoints {
+			out = append(out, lbEp.GetEndpoint().GetAddress().GetSocketAddress().GetAddress())
+		}
+	}
+	return out
+}
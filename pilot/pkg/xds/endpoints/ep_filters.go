@@ -16,12 +16,16 @@ package endpoints
 
 import (
 	"math"
+	"net"
+	"strconv"
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
 	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
 	labelutil "istio.io/istio/pilot/pkg/serviceregistry/util/label"
@@ -29,6 +33,8 @@ import (
 	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/maps"
 	"istio.io/istio/pkg/network"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/util/identifier"
 )
 
 // EndpointsByNetworkFilter is a network filter function to support Split Horizon EDS - filter the endpoints based on the network
@@ -50,6 +56,7 @@ func (b *EndpointBuilder) EndpointsByNetworkFilter(endpoints []*LocalityEndpoint
 	// This will allow us to more easily spread traffic to the endpoint across multiple
 	// network gateways, increasing reliability of the endpoint.
 	scaleFactor := b.gateways().GetLBWeightScaleFactor()
+	failover := b.remoteNetworkFailover()
 
 	// Go through all cluster endpoints and add those with the same network as the sidecar
 	// to the result. Also count the number of endpoints per each remote network while
@@ -61,10 +68,25 @@ func (b *EndpointBuilder) EndpointsByNetworkFilter(endpoints []*LocalityEndpoint
 				Priority: ep.llbEndpoints.Priority,
 				// Endpoints and weight will be reset below.
 			},
+			group: ep.group,
+		}
+		// In the Priority cross-network failover mode, the endpoints reached through network gateways are kept
+		// apart, at a lower priority than the local endpoints. Standby and fallback endpoints keep their priority.
+		remoteEndpoints := lbEndpoints
+		if failover && ep.group == primaryGroup {
+			remoteEndpoints = &LocalityEndpoints{
+				llbEndpoints: endpoint.LocalityLbEndpoints{
+					Locality: ep.llbEndpoints.Locality,
+				},
+				group: remoteNetworkGroup,
+			}
 		}
 
 		// Create a map to keep track of the gateways used and their aggregate weights.
 		gatewayWeights := make(map[model.NetworkGateway]uint32)
+		// Draining endpoints, only sent to persistent session clusters, are tracked apart so that the gateways
+		// fronting them do not receive new sessions on their behalf.
+		drainingWeights := make(map[model.NetworkGateway]uint32)
 
 		// Process all the endpoints.
 		for i, lbEp := range ep.llbEndpoints.LbEndpoints {
@@ -72,6 +94,7 @@ func (b *EndpointBuilder) EndpointsByNetworkFilter(endpoints []*LocalityEndpoint
 
 			// If the proxy can't view the network for this endpoint, exclude it entirely.
 			if !b.proxyView.IsVisible(istioEndpoint) {
+				endpointFiltered(reasonNetworkView)
 				continue
 			}
 
@@ -93,33 +116,62 @@ func (b *EndpointBuilder) EndpointsByNetworkFilter(endpoints []*LocalityEndpoint
 			// Check if the endpoint is directly reachable. It's considered directly reachable if
 			// the endpoint is either on the local network or on a remote network that can be reached
 			// directly from the local network.
-			if b.proxy.InNetwork(epNetwork) || len(gateways) == 0 {
+			if identifier.IsSameOrEmpty(epNetwork.String(), b.network.String()) || len(gateways) == 0 {
 				// The endpoint is directly reachable - just add it.
 				// If there is no gateway, the address must not be empty
 				if lbEp.GetEndpoint().GetAddress().GetSocketAddress().GetAddress() != "" {
 					lbEndpoints.append(ep.istioEndpoints[i], lbEp)
+				} else {
+					endpointFiltered(reasonNoGateway)
 				}
 
 				continue
 			}
 
+			// Endpoints reached through HBONE are tunneled through the HBONE port of a gateway of their network. Unlike
+			// SNI routing, they keep their own endpoint, preserving their locality and identity.
+			if tunneled := tunnelThroughGateway(lbEp, i, gateways, b.gatewayHBONEFallbackPort(istioEndpoint)); tunneled != nil {
+				remoteEndpoints.append(istioEndpoint, tunneled)
+				continue
+			}
+
 			// Cross-network traffic relies on mTLS to be enabled for SNI routing
 			// TODO BTS may allow us to work around this
 			if !isMtlsEnabled(lbEp) {
+				endpointFiltered(reasonNetworkMtls)
 				continue
 			}
 
 			// Apply the weight for this endpoint to the network gateways.
-			splitWeightAmongGateways(weight, gateways, gatewayWeights)
+			if lbEp.GetHealthStatus() == core.HealthStatus_DRAINING {
+				splitWeightAmongGateways(weight, gateways, drainingWeights)
+			} else {
+				splitWeightAmongGateways(weight, gateways, gatewayWeights)
+			}
 		}
 
 		// Sort the gateways into an ordered list so that the generated endpoints are deterministic.
 		gateways := maps.Keys(gatewayWeights)
+		for gw := range drainingWeights {
+			if _, f := gatewayWeights[gw]; !f {
+				gateways = append(gateways, gw)
+			}
+		}
 		gateways = model.SortGateways(gateways)
 
 		// Create endpoints for the gateways.
 		for _, gw := range gateways {
-			epWeight := gatewayWeights[gw]
+			epWeight, active := gatewayWeights[gw]
+			healthStatus := core.HealthStatus_UNKNOWN
+			if !active {
+				// All the endpoints behind the gateway are draining: keep the gateway for the existing sessions only.
+				epWeight = drainingWeights[gw]
+				healthStatus = core.HealthStatus_DRAINING
+			}
+			if b.gateways().IsUnresolvable(gw) {
+				// The hostname of the gateway does not resolve, keep it out of the load balancing until it does.
+				healthStatus = core.HealthStatus_UNHEALTHY
+			}
 			if epWeight == 0 {
 				log.Warnf("gateway weight must be greater than 0, scaleFactor is %d", scaleFactor)
 				epWeight = 1
@@ -145,7 +197,8 @@ func (b *EndpointBuilder) EndpointsByNetworkFilter(endpoints []*LocalityEndpoint
 				LoadBalancingWeight: &wrappers.UInt32Value{
 					Value: epWeight,
 				},
-				Metadata: &core.Metadata{},
+				HealthStatus: healthStatus,
+				Metadata:     &core.Metadata{},
 			}
 			// TODO: figure out a way to extract locality data from the gateway public endpoints in meshNetworks
 			util.AppendLbEndpointMetadata(&model.EndpointMetadata{
@@ -154,13 +207,20 @@ func (b *EndpointBuilder) EndpointsByNetworkFilter(endpoints []*LocalityEndpoint
 				ClusterID: b.clusterID,
 				Labels:    labels.Instance{},
 			}, gwEp.Metadata)
+			if _, f := features.CrossNetworkSNIOverrides[gw.Cluster.String()]; f {
+				appendSNIClusterMetadata(gwEp, gw.Cluster)
+			}
 			// Currently gateway endpoint does not support tunnel.
-			lbEndpoints.append(gwIstioEp, gwEp)
+			remoteEndpoints.append(gwIstioEp, gwEp)
 		}
 
 		// Endpoint members could be stripped or aggregated by network. Adjust weight value here.
 		lbEndpoints.refreshWeight()
 		filtered = append(filtered, lbEndpoints)
+		if remoteEndpoints != lbEndpoints && len(remoteEndpoints.istioEndpoints) > 0 {
+			remoteEndpoints.refreshWeight()
+			filtered = append(filtered, remoteEndpoints)
+		}
 	}
 
 	return filtered
@@ -186,6 +246,45 @@ func (b *EndpointBuilder) selectNetworkGateways(nw network.ID, c cluster.ID) []m
 	return gws
 }
 
+// tunnelThroughGateway returns a copy of the HBONE endpoint with its tunnel targeting the HBONE port of one of the
+// gateways, so that the gateway forwards the inner tunnel to the endpoint (double HBONE). The endpoints are spread
+// across the gateways by their index. If no gateway declares an HBONE port, the gateways are tunneled through the
+// fallback port, if any. Returns nil if the endpoint is not tunneled or no gateway supports HBONE.
+func tunnelThroughGateway(ep *endpoint.LbEndpoint, index int, gateways []model.NetworkGateway, fallbackPort uint32) *endpoint.LbEndpoint {
+	tunnel := ep.GetMetadata().GetFilterMetadata()[model.TunnelLabelShortName]
+	if tunnel == nil {
+		return nil
+	}
+	hbone := slices.Filter(gateways, func(gw model.NetworkGateway) bool {
+		return gw.HBONEPort != 0
+	})
+	if len(hbone) == 0 && fallbackPort != 0 {
+		hbone = slices.Map(gateways, func(gw model.NetworkGateway) model.NetworkGateway {
+			gw.HBONEPort = fallbackPort
+			return gw
+		})
+	}
+	if len(hbone) == 0 {
+		return nil
+	}
+	gw := hbone[index%len(hbone)]
+	ep = proto.Clone(ep).(*endpoint.LbEndpoint)
+	ep.Metadata.FilterMetadata[model.TunnelLabelShortName].Fields["address"] = structpb.NewStringValue(
+		net.JoinHostPort(gw.Addr, strconv.Itoa(int(gw.HBONEPort))))
+	return ep
+}
+
+// gatewayHBONEFallbackPort returns the port through which the gateways of the network of the endpoint are tunneled
+// when they do not declare an HBONE port, or 0 if they must not be. Workloads captured by ztunnel cannot be reached
+// through SNI routing, so they are assumed to be reachable through the HBONE port of the gateways when
+// AmbientEastWestHBONE is enabled.
+func (b *EndpointBuilder) gatewayHBONEFallbackPort(ep *model.IstioEndpoint) uint32 {
+	if !features.AmbientEastWestHBONE || !b.ambient.SupportsTunnel(ep.Network, ep.Address) {
+		return 0
+	}
+	return model.HBoneInboundListenPort
+}
+
 func (b *EndpointBuilder) scaleEndpointLBWeight(ep *endpoint.LbEndpoint, scaleFactor uint32) uint32 {
 	if ep.GetLoadBalancingWeight() == nil || ep.GetLoadBalancingWeight().Value == 0 {
 		return scaleFactor
@@ -213,6 +312,7 @@ func (b *EndpointBuilder) EndpointsWithMTLSFilter(endpoints []*LocalityEndpoints
 	// A new array of endpoints to be returned that will have both local and
 	// remote gateways (if any)
 	filtered := make([]*LocalityEndpoints, 0)
+	var excluded []*model.IstioEndpoint
 
 	// Go through all cluster endpoints and add those with mTLS enabled
 	for _, ep := range endpoints {
@@ -222,11 +322,15 @@ func (b *EndpointBuilder) EndpointsWithMTLSFilter(endpoints []*LocalityEndpoints
 				Priority: ep.llbEndpoints.Priority,
 				// Endpoints and will be reset below.
 			},
+			group: ep.group,
 		}
 
 		for i, lbEp := range ep.llbEndpoints.LbEndpoints {
 			if !isMtlsEnabled(lbEp) {
 				// no mTLS, skip it
+				b.trace.filtered(ep.istioEndpoints[i], reasonMtls)
+				endpointFiltered(reasonMtls)
+				excluded = append(excluded, ep.istioEndpoints[i])
 				continue
 			}
 			lbEndpoints.append(ep.istioEndpoints[i], lbEp)
@@ -235,6 +339,7 @@ func (b *EndpointBuilder) EndpointsWithMTLSFilter(endpoints []*LocalityEndpoints
 		lbEndpoints.refreshWeight()
 		filtered = append(filtered, lbEndpoints)
 	}
+	b.recordMtlsExcluded(excluded)
 
 	return filtered
 }
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"testing"
 
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"google.golang.org/protobuf/testing/protocmp"
@@ -27,6 +28,7 @@ import (
 	"istio.io/api/type/v1beta1"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/xds"
 	. "istio.io/istio/pilot/pkg/xds/endpoints"
 	"istio.io/istio/pilot/test/xdstest"
@@ -590,6 +592,179 @@ func TestEndpointsByNetworkFilter_SkipLBWithHostname(t *testing.T) {
 	runNetworkFilterTest(t, ds, networkFiltered, "")
 }
 
+func TestEndpointsByNetworkFilter_Draining(t *testing.T) {
+	test.SetForTest(t, &features.MultiNetworkGatewayAPI, true)
+	ds := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{
+		Services: []*model.Service{{
+			Hostname: "example.ns.svc.cluster.local",
+			Attributes: model.ServiceAttributes{
+				Name:      "example",
+				Namespace: "ns",
+				Labels:    map[string]string{features.PersistentSessionLabel: "session"},
+			},
+			Ports: model.PortList{{Port: 80, Protocol: protocol.HTTP, Name: "http"}},
+		}},
+		Gateways: []model.NetworkGateway{
+			{Network: "network2", Cluster: "cluster2a", Addr: "2.2.2.2", Port: 80},
+			{Network: "network2", Cluster: "cluster2b", Addr: "2.2.2.20", Port: 80},
+		},
+	})
+	ds.Env().InitNetworksManager(ds.Discovery)
+
+	index := model.NewEndpointIndex(model.NewXdsCache())
+	for shard, eps := range map[model.ShardKey][]*model.IstioEndpoint{
+		{Cluster: "cluster2a"}: {
+			{Address: "20.0.0.1"},
+			{Address: "20.0.0.9", HealthStatus: model.Draining},
+		},
+		{Cluster: "cluster2b"}: {
+			{Address: "20.0.0.2", HealthStatus: model.Draining},
+		},
+	} {
+		for _, ep := range eps {
+			ep.Network = "network2"
+			ep.Locality.ClusterID = shard.Cluster
+			ep.ServicePortName = "http"
+			ep.Namespace = "ns"
+			ep.HostName = "example.ns.svc.cluster.local"
+			ep.EndpointPort = 8080
+			ep.TLSMode = "istio"
+			if ep.HealthStatus == 0 {
+				ep.HealthStatus = model.Healthy
+			}
+		}
+		index.UpdateServiceEndpoints(shard, "example.ns.svc.cluster.local", "ns", eps)
+	}
+
+	cn := "outbound|80||example.ns.svc.cluster.local"
+	b := NewEndpointBuilder(cn, ds.SetupProxy(makeProxy("network1", "cluster1a")), ds.PushContext())
+	health := map[string]core.HealthStatus{}
+	weights := map[string]uint32{}
+	for _, llb := range b.BuildClusterLoadAssignment(index).Endpoints {
+		for _, ep := range llb.LbEndpoints {
+			addr := ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()
+			health[addr] = ep.GetHealthStatus()
+			weights[addr] = ep.GetLoadBalancingWeight().GetValue()
+		}
+	}
+	// The gateway of cluster2a receives new sessions on behalf of its healthy endpoint only.
+	if health["2.2.2.2"] != core.HealthStatus_UNKNOWN {
+		t.Fatalf("expected the gateway of cluster2a to be healthy, got %v", health)
+	}
+	// All the endpoints of cluster2b are draining, so is its gateway.
+	if health["2.2.2.20"] != core.HealthStatus_DRAINING {
+		t.Fatalf("expected the gateway of cluster2b to be draining, got %v", health)
+	}
+	if weights["2.2.2.2"] != weights["2.2.2.20"] {
+		t.Fatalf("expected the draining endpoint to be excluded from the weight of the gateway of cluster2a, got %v", weights)
+	}
+}
+
+func TestEndpointsByNetworkFilter_PriorityFailover(t *testing.T) {
+	test.SetForTest(t, &features.MultiNetworkGatewayAPI, true)
+	build := func(failover string) map[string]uint32 {
+		ds := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{
+			Services: []*model.Service{{
+				Hostname: "example.ns.svc.cluster.local",
+				Attributes: model.ServiceAttributes{
+					Name:          "example",
+					Namespace:     "ns",
+					K8sAttributes: model.K8sAttributes{CrossNetworkFailover: failover},
+				},
+				Ports: model.PortList{{Port: 80, Protocol: protocol.HTTP, Name: "http"}},
+			}},
+			Gateways: []model.NetworkGateway{{Network: "network2", Cluster: "cluster2a", Addr: "2.2.2.2", Port: 80}},
+		})
+		ds.Env().InitNetworksManager(ds.Discovery)
+
+		index := model.NewEndpointIndex(model.NewXdsCache())
+		for shard, ep := range map[model.ShardKey]*model.IstioEndpoint{
+			{Cluster: "cluster1a"}: {Address: "10.0.0.1", Network: "network1"},
+			{Cluster: "cluster2a"}: {Address: "20.0.0.1", Network: "network2"},
+		} {
+			ep.Locality.ClusterID = shard.Cluster
+			ep.ServicePortName = "http"
+			ep.Namespace = "ns"
+			ep.HostName = "example.ns.svc.cluster.local"
+			ep.EndpointPort = 8080
+			ep.TLSMode = "istio"
+			ep.HealthStatus = model.Healthy
+			index.UpdateServiceEndpoints(shard, "example.ns.svc.cluster.local", "ns", []*model.IstioEndpoint{ep})
+		}
+
+		cn := "outbound|80||example.ns.svc.cluster.local"
+		b := NewEndpointBuilder(cn, ds.SetupProxy(makeProxy("network1", "cluster1a")), ds.PushContext())
+		priorities := map[string]uint32{}
+		for _, llb := range b.BuildClusterLoadAssignment(index).Endpoints {
+			for _, ep := range llb.LbEndpoints {
+				priorities[ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()] = llb.Priority
+			}
+		}
+		return priorities
+	}
+
+	if got := build(""); got["10.0.0.1"] != 0 || got["2.2.2.2"] != 0 {
+		t.Fatalf("expected the remote network gateway at the priority of the local endpoints, got %v", got)
+	}
+	if got := build("Priority"); got["10.0.0.1"] != 0 || got["2.2.2.2"] != 1 {
+		t.Fatalf("expected the remote network gateway at a lower priority than the local endpoints, got %v", got)
+	}
+	test.SetForTest(t, &features.CrossNetworkFailover, "Priority")
+	if got := build(""); got["10.0.0.1"] != 0 || got["2.2.2.2"] != 1 {
+		t.Fatalf("expected the mesh-wide mode to apply, got %v", got)
+	}
+	// The annotation of the service overrides the mesh-wide mode.
+	if got := build("Weighted"); got["10.0.0.1"] != 0 || got["2.2.2.2"] != 0 {
+		t.Fatalf("expected the service mode to override the mesh-wide mode, got %v", got)
+	}
+}
+
+func TestEndpointsByNetworkFilter_SNIOverride(t *testing.T) {
+	test.SetForTest(t, &features.MultiNetworkGatewayAPI, true)
+	test.SetForTest(t, &features.CrossNetworkSNIOverrides, map[string]string{"cluster2b": "{sni}.v2"})
+	ds := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{
+		Services: []*model.Service{{
+			Hostname:   "example.ns.svc.cluster.local",
+			Attributes: model.ServiceAttributes{Name: "example", Namespace: "ns"},
+			Ports:      model.PortList{{Port: 80, Protocol: protocol.HTTP, Name: "http"}},
+		}},
+		Gateways: []model.NetworkGateway{
+			{Network: "network2", Cluster: "cluster2a", Addr: "2.2.2.2", Port: 80},
+			{Network: "network2", Cluster: "cluster2b", Addr: "2.2.2.20", Port: 80},
+		},
+	})
+	ds.Env().InitNetworksManager(ds.Discovery)
+
+	index := model.NewEndpointIndex(model.NewXdsCache())
+	for shard, address := range map[model.ShardKey]string{{Cluster: "cluster2a"}: "20.0.0.1", {Cluster: "cluster2b"}: "20.0.0.2"} {
+		index.UpdateServiceEndpoints(shard, "example.ns.svc.cluster.local", "ns", []*model.IstioEndpoint{{
+			Address:         address,
+			Network:         "network2",
+			Locality:        model.Locality{ClusterID: shard.Cluster},
+			ServicePortName: "http",
+			Namespace:       "ns",
+			HostName:        "example.ns.svc.cluster.local",
+			EndpointPort:    8080,
+			TLSMode:         "istio",
+			HealthStatus:    model.Healthy,
+		}})
+	}
+
+	cn := "outbound|80||example.ns.svc.cluster.local"
+	b := NewEndpointBuilder(cn, ds.SetupProxy(makeProxy("network1", "cluster1a")), ds.PushContext())
+	sniClusters := map[string]string{}
+	for _, llb := range b.BuildClusterLoadAssignment(index).Endpoints {
+		for _, ep := range llb.LbEndpoints {
+			addr := ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()
+			match := ep.GetMetadata().GetFilterMetadata()[util.EnvoyTransportSocketMetadataKey]
+			sniClusters[addr] = match.GetFields()[model.SNIClusterShortname].GetStringValue()
+		}
+	}
+	if sniClusters["2.2.2.20"] != "cluster2b" || sniClusters["2.2.2.2"] != "" {
+		t.Fatalf("expected only the gateway of cluster2b to select its SNI, got %v", sniClusters)
+	}
+}
+
 type networkFilterCase struct {
 	name  string
 	proxy *model.Proxy
@@ -0,0 +1,69 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/fuzz"
+)
+
+func FuzzBuildClusterLoadAssignment(f *testing.F) {
+	fuzz.Fuzz(f, func(fg fuzz.Helper) {
+		opts := fuzz.Struct[endpointstest.MeshOptions](fg)
+		// Keep the meshes small, fuzzing their content matters more than their size.
+		opts.Services = 1 + bounded(opts.Services, 4)
+		opts.Endpoints = bounded(opts.Endpoints, 16)
+		opts.Networks = 1 + bounded(opts.Networks, 4)
+		m := endpointstest.NewMesh(fg.T(), opts)
+		// Add arbitrary endpoints to the first service, on its port so they are not all filtered out.
+		shards, _ := m.EndpointIndex.ShardsForService(string(endpointstest.Hostname(0)), endpointstest.Namespace)
+		for _, ep := range fuzz.Slice[*model.IstioEndpoint](fg, 4) {
+			ep.ServicePortName = "http"
+			key := model.ShardKey{Cluster: ep.Locality.ClusterID}
+			shards.Lock()
+			shards.Shards[key] = append(shards.Shards[key], ep)
+			shards.Unlock()
+		}
+
+		for n := 0; n < opts.Networks; n++ {
+			proxy := m.Proxy(n)
+			for s := 0; s < opts.Services; s++ {
+				b := NewEndpointBuilder(endpointstest.ClusterName(s), proxy, m.Push)
+				eps := b.snapshotShards(m.EndpointIndex)
+				svcPort := b.servicePort(b.port)
+				for _, ep := range eps {
+					b.filterReason(ep, svcPort)
+				}
+				for _, locEps := range b.generate(eps, false) {
+					locEps.AssertInvarianceInTest()
+				}
+				b.EndpointsByNetworkFilter(localityEndpoints(&b, eps))
+				b.BuildClusterLoadAssignment(m.EndpointIndex)
+			}
+		}
+	})
+}
+
+// bounded returns v modulo n, as a positive number.
+func bounded(v, n int) int {
+	v %= n
+	if v < 0 {
+		v += n
+	}
+	return v
+}
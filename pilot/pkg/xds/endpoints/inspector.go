@@ -0,0 +1,152 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"sort"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+// ServiceEndpointReference is an endpoint of a service in the endpoint index.
+type ServiceEndpointReference struct {
+	Service   string `json:"service"`
+	Namespace string `json:"namespace"`
+	Shard     string `json:"shard"`
+	Port      uint32 `json:"port"`
+	PortName  string `json:"portName,omitempty"`
+	Locality  string `json:"locality,omitempty"`
+	Health    string `json:"health"`
+	Weight    uint32 `json:"weight"`
+}
+
+// ClusterEndpointReference is an endpoint of the ClusterLoadAssignment built for a cluster of a proxy.
+type ClusterEndpointReference struct {
+	Proxy    string `json:"proxy"`
+	Cluster  string `json:"cluster"`
+	Port     uint32 `json:"port"`
+	Locality string `json:"locality,omitempty"`
+	Priority uint32 `json:"priority"`
+	Health   string `json:"health"`
+	Weight   uint32 `json:"weight"`
+}
+
+// Inspector lists the services and the clusters including the endpoints with an address, for debugging. It is a
+// snapshot of the endpoint index when it is created, along with the ClusterLoadAssignments added to it, and is not
+// updated afterwards. Only the endpoints with the address are retained.
+type Inspector struct {
+	address  string
+	services []ServiceEndpointReference
+	clusters []ClusterEndpointReference
+}
+
+// NewInspector finds the endpoints of the index with the address.
+func NewInspector(index *model.EndpointIndex, address string) *Inspector {
+	i := &Inspector{address: address}
+	index.ForEachEndpoint(func(svc, ns string, shard model.ShardKey, ep *model.IstioEndpoint) {
+		if ep.Address != address {
+			return
+		}
+		i.services = append(i.services, ServiceEndpointReference{
+			Service:   svc,
+			Namespace: ns,
+			Shard:     shard.String(),
+			Port:      ep.EndpointPort,
+			PortName:  ep.ServicePortName,
+			Locality:  ep.Locality.Label,
+			// The health statuses of the endpoints match the ones of Envoy.
+			Health: corev3.HealthStatus(ep.HealthStatus).String(),
+			Weight: ep.GetLoadBalancingWeight(),
+		})
+	})
+	return i
+}
+
+// IncludesService reports whether an endpoint of the service has the address. The ClusterLoadAssignments of the
+// clusters of other services do not need to be added to find the clusters including it.
+func (i *Inspector) IncludesService(service, namespace string) bool {
+	for _, ref := range i.services {
+		if ref.Service == service && ref.Namespace == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// AddClusterLoadAssignment adds the endpoints of the ClusterLoadAssignment built for the proxy with the address.
+// Endpoints reached through a network gateway or a waypoint are found by the address of the gateway or waypoint.
+func (i *Inspector) AddClusterLoadAssignment(proxyID string, cla *endpoint.ClusterLoadAssignment) {
+	for _, llb := range cla.GetEndpoints() {
+		for _, lbEp := range llb.LbEndpoints {
+			ref := ClusterEndpointReference{
+				Proxy:    proxyID,
+				Cluster:  cla.ClusterName,
+				Locality: util.LocalityToString(llb.Locality),
+				Priority: llb.Priority,
+				Health:   lbEp.HealthStatus.String(),
+				Weight:   lbEp.GetLoadBalancingWeight().GetValue(),
+			}
+			addresses := []*corev3.Address{lbEp.GetEndpoint().GetAddress()}
+			for _, additional := range lbEp.GetEndpoint().GetAdditionalAddresses() {
+				addresses = append(addresses, additional.GetAddress())
+			}
+			for _, addr := range addresses {
+				sa := addr.GetSocketAddress()
+				if sa == nil || sa.Address != i.address {
+					continue
+				}
+				ref.Port = sa.GetPortValue()
+				i.clusters = append(i.clusters, ref)
+			}
+		}
+	}
+}
+
+// Services returns the endpoints of the services with the address, sorted.
+func (i *Inspector) Services() []ServiceEndpointReference {
+	out := append([]ServiceEndpointReference{}, i.services...)
+	sort.Slice(out, func(a, b int) bool {
+		if out[a].Service != out[b].Service {
+			return out[a].Service < out[b].Service
+		}
+		if out[a].Namespace != out[b].Namespace {
+			return out[a].Namespace < out[b].Namespace
+		}
+		if out[a].Shard != out[b].Shard {
+			return out[a].Shard < out[b].Shard
+		}
+		return out[a].Port < out[b].Port
+	})
+	return out
+}
+
+// Clusters returns the endpoints of the clusters of the proxies with the address, sorted.
+func (i *Inspector) Clusters() []ClusterEndpointReference {
+	out := append([]ClusterEndpointReference{}, i.clusters...)
+	sort.Slice(out, func(a, b int) bool {
+		if out[a].Proxy != out[b].Proxy {
+			return out[a].Proxy < out[b].Proxy
+		}
+		if out[a].Cluster != out[b].Cluster {
+			return out[a].Cluster < out[b].Cluster
+		}
+		return out[a].Port < out[b].Port
+	})
+	return out
+}
@@ -0,0 +1,72 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestInspector(t *testing.T) {
+	svc := endpointstest.NewService()
+	ep := func(addr string, health model.HealthStatus, weight uint32) *model.IstioEndpoint {
+		ep := endpointstest.NewEndpoint(addr)
+		ep.Locality.Label, ep.HealthStatus, ep.LbWeight = "region/zone", health, weight
+		return ep
+	}
+	index := model.NewEndpointIndex(model.DisabledCache{})
+	index.UpdateServiceEndpoints(model.ShardKey{Cluster: "c1", Provider: provider.Kubernetes}, string(svc.Hostname), "ns", []*model.IstioEndpoint{
+		ep("10.0.0.1", model.Healthy, 3),
+		ep("10.0.0.2", model.Healthy, 0),
+	})
+	index.UpdateServiceEndpoints(model.ShardKey{Cluster: "c1", Provider: provider.External}, "other.example.com", "ns", []*model.IstioEndpoint{
+		ep("10.0.0.1", model.Draining, 0),
+	})
+
+	inspector := NewInspector(index, "10.0.0.1")
+	assert.Equal(t, inspector.Services(), []ServiceEndpointReference{
+		{
+			Service: "example.ns.svc.cluster.local", Namespace: "ns", Shard: "Kubernetes/c1", Port: 8080, PortName: "http",
+			Locality: "region/zone", Health: "HEALTHY", Weight: 3,
+		},
+		{
+			Service: "other.example.com", Namespace: "ns", Shard: "External/c1", Port: 8080, PortName: "http",
+			Locality: "region/zone", Health: "DRAINING", Weight: 1,
+		},
+	})
+	assert.Equal(t, inspector.IncludesService(string(svc.Hostname), "ns"), true)
+	assert.Equal(t, inspector.IncludesService("other.example.com", "ns"), true)
+	assert.Equal(t, inspector.IncludesService("missing.example.com", "ns"), false)
+
+	other := NewInspector(index, "10.0.0.2")
+	assert.Equal(t, other.IncludesService(string(svc.Hostname), "ns"), true)
+	assert.Equal(t, other.IncludesService("other.example.com", "ns"), false)
+	assert.Equal(t, len(NewInspector(index, "10.0.0.3").Services()), 0)
+
+	b := New(endpointstest.OutboundCluster(svc), WithService(svc), WithClusterID(endpointstest.ClusterID))
+	cla := b.BuildClusterLoadAssignment(index)
+	inspector.AddClusterLoadAssignment("pod.ns", cla)
+	assert.Equal(t, inspector.Clusters(), []ClusterEndpointReference{{
+		Proxy: "pod.ns", Cluster: "outbound|80||example.ns.svc.cluster.local", Port: 8080,
+		Locality: "region/zone", Health: "HEALTHY", Weight: 3,
+	}})
+	missing := NewInspector(index, "10.0.0.3")
+	missing.AddClusterLoadAssignment("pod.ns", cla)
+	assert.Equal(t, len(missing.Clusters()), 0)
+}
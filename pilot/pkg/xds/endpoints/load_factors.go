@@ -0,0 +1,65 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"net"
+	"strconv"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+// LoadFactors holds the factors applied to the endpoint weights of a cluster, computed from the load reported by
+// the proxies.
+type LoadFactors struct {
+	// Version changes each time the factors change. It is part of the cache key of the cluster.
+	Version uint64
+	// ByAddress holds the factor of the endpoints, keyed by ip:port. Other endpoints keep their weight.
+	ByAddress map[string]float64
+}
+
+// WithLoadFactors sets the factors applied to the endpoint weights. They are only used by ROUND_ROBIN clusters:
+// the other load balancers already account for the load of the endpoints, or must keep their hashing stable.
+func (b *EndpointBuilder) WithLoadFactors(factors *LoadFactors) *EndpointBuilder {
+	if factors == nil || len(factors.ByAddress) == 0 {
+		return b
+	}
+	_, lb := getOutlierDetectionAndLoadBalancerSettings(b.DestinationRule(), b.port, b.ruleSubsetName())
+	if lb.GetSimple() != v1alpha3.LoadBalancerSettings_ROUND_ROBIN {
+		return b
+	}
+	b.loadFactors = factors
+	return b
+}
+
+// applyLoadFactor scales the weight of the endpoint by its load factor, if it has one.
+func (b *EndpointBuilder) applyLoadFactor(eep *lbEndpointCopy) {
+	if b.loadFactors == nil {
+		return
+	}
+	sa := eep.lbEp.GetEndpoint().GetAddress().GetSocketAddress()
+	if sa == nil {
+		return
+	}
+	factor, f := b.loadFactors.ByAddress[net.JoinHostPort(sa.GetAddress(), strconv.Itoa(int(sa.GetPortValue())))]
+	if !f || factor == 1 {
+		return
+	}
+	weight := util.ScaleWeight(float64(eep.lbEp.GetLoadBalancingWeight().GetValue()), factor)
+	eep.mutable().LoadBalancingWeight = &wrapperspb.UInt32Value{Value: weight}
+}
@@ -0,0 +1,103 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config"
+)
+
+func lbEndpoint(address string, port uint32, weight uint32) *endpoint.LbEndpoint {
+	return &endpoint.LbEndpoint{
+		HostIdentifier: &endpoint.LbEndpoint_Endpoint{
+			Endpoint: &endpoint.Endpoint{
+				Address: &core.Address{Address: &core.Address_SocketAddress{SocketAddress: &core.SocketAddress{
+					Address:       address,
+					PortSpecifier: &core.SocketAddress_PortValue{PortValue: port},
+				}}},
+			},
+		},
+		LoadBalancingWeight: wrapperspb.UInt32(weight),
+	}
+}
+
+func TestWithLoadFactors(t *testing.T) {
+	factors := &LoadFactors{Version: 1, ByAddress: map[string]float64{"10.0.0.1:8080": 0.5}}
+	dr := func(simple networking.LoadBalancerSettings_SimpleLB) *model.ConsolidatedDestRule {
+		return model.ConvertConsolidatedDestRule(&config.Config{
+			Spec: &networking.DestinationRule{
+				TrafficPolicy: &networking.TrafficPolicy{
+					LoadBalancer: &networking.LoadBalancerSettings{
+						LbPolicy: &networking.LoadBalancerSettings_Simple{Simple: simple},
+					},
+				},
+			},
+		})
+	}
+	tests := []struct {
+		name string
+		dr   *model.ConsolidatedDestRule
+		want bool
+	}{
+		{"no destination rule", nil, false},
+		{"least request", dr(networking.LoadBalancerSettings_LEAST_REQUEST), false},
+		{"round robin", dr(networking.LoadBalancerSettings_ROUND_ROBIN), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &EndpointBuilder{destinationRule: tt.dr, port: 80}
+			if got := b.WithLoadFactors(factors).loadFactors != nil; got != tt.want {
+				t.Fatalf("expected load factors to be used: %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestApplyLoadFactor(t *testing.T) {
+	b := &EndpointBuilder{loadFactors: &LoadFactors{Version: 1, ByAddress: map[string]float64{
+		"10.0.0.1:8080": 0.5,
+		"10.0.0.2:8080": 2,
+		"10.0.0.3:8080": 0.001,
+	}}}
+	tests := []struct {
+		address string
+		weight  uint32
+		want    uint32
+	}{
+		{"10.0.0.1", 4, 2},
+		{"10.0.0.2", 4, 8},
+		// Endpoints keep a weight of at least 1.
+		{"10.0.0.3", 4, 1},
+		// Endpoints without a factor keep their weight.
+		{"10.0.0.4", 4, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			c := &lbEndpointCopy{lbEp: lbEndpoint(tt.address, 8080, tt.weight)}
+			b.applyLoadFactor(c)
+			eep := c.lbEp
+			if eep.GetLoadBalancingWeight().GetValue() != tt.want {
+				t.Fatalf("expected weight %v, got %v", tt.want, eep.GetLoadBalancingWeight().GetValue())
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+// LocalityEndpointCount is the number of endpoints of a locality of a ClusterLoadAssignment.
+type LocalityEndpointCount struct {
+	Locality string `json:"locality"`
+	Priority uint32 `json:"priority"`
+	// Endpoints is the number of endpoints of the locality, whatever their health.
+	Endpoints int `json:"endpoints"`
+	// Healthy is the number of endpoints Envoy considers healthy, with an unknown or healthy status.
+	Healthy int `json:"healthy"`
+}
+
+// LocalityEndpointCounts returns the number of endpoints of each locality of the ClusterLoadAssignment, in order.
+func LocalityEndpointCounts(cla *endpoint.ClusterLoadAssignment) []LocalityEndpointCount {
+	out := make([]LocalityEndpointCount, 0, len(cla.GetEndpoints()))
+	for _, llb := range cla.GetEndpoints() {
+		out = append(out, localityEndpointCount(llb))
+	}
+	return out
+}
+
+func localityEndpointCount(llb *endpoint.LocalityLbEndpoints) LocalityEndpointCount {
+	count := LocalityEndpointCount{
+		Locality:  util.LocalityToString(llb.Locality),
+		Priority:  llb.Priority,
+		Endpoints: len(llb.LbEndpoints),
+	}
+	for _, lbEp := range llb.LbEndpoints {
+		if lbEp.HealthStatus == corev3.HealthStatus_UNKNOWN || lbEp.HealthStatus == corev3.HealthStatus_HEALTHY {
+			count.Healthy++
+		}
+	}
+	return count
+}
@@ -0,0 +1,55 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestLocalityEndpointCounts(t *testing.T) {
+	svc := endpointstest.NewService()
+	ep := func(addr, locality string, health model.HealthStatus) *model.IstioEndpoint {
+		ep := endpointstest.NewEndpoint(addr)
+		ep.Locality.Label, ep.HealthStatus = locality, health
+		return ep
+	}
+	index := endpointstest.NewIndex(svc,
+		ep("10.0.0.1", "region/zone-a", model.Healthy),
+		ep("10.0.0.2", "region/zone-a", model.Healthy),
+		ep("10.0.0.3", "region/zone-b", model.Healthy),
+	)
+	b := New(endpointstest.OutboundCluster(svc), WithService(svc), WithClusterID(endpointstest.ClusterID))
+	assert.Equal(t, LocalityEndpointCounts(b.BuildClusterLoadAssignment(index)), []LocalityEndpointCount{
+		{Locality: "region/zone-a", Endpoints: 2, Healthy: 2},
+		{Locality: "region/zone-b", Endpoints: 1, Healthy: 1},
+	})
+
+	// Only the endpoints with a healthy or unknown status are healthy for Envoy.
+	assert.Equal(t, LocalityEndpointCounts(&endpoint.ClusterLoadAssignment{Endpoints: []*endpoint.LocalityLbEndpoints{{
+		Priority: 1,
+		LbEndpoints: []*endpoint.LbEndpoint{
+			{HealthStatus: corev3.HealthStatus_UNKNOWN},
+			{HealthStatus: corev3.HealthStatus_UNHEALTHY},
+			{HealthStatus: corev3.HealthStatus_DRAINING},
+		},
+	}}}), []LocalityEndpointCount{{Priority: 1, Endpoints: 3, Healthy: 1}})
+}
@@ -0,0 +1,44 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// WithMeshSettings overrides the meshConfig settings of the push context. A nil value keeps the push context settings.
+// The builders of a proxy use its EndpointMeshSettings.
+func (b *EndpointBuilder) WithMeshSettings(s *model.EndpointMeshSettings) *EndpointBuilder {
+	if s == nil {
+		return b
+	}
+	b.meshSettings = s
+	b.clusterLocal = b.service != nil && s.ClusterLocalHosts.IsClusterLocal(b.service.Hostname)
+	b.failoverPriorityLabels = nil
+	b.populateFailoverPriorityLabels()
+	return b
+}
+
+// meshLocalityLbSetting returns the locality load balancing settings of the meshConfig.
+func (b *EndpointBuilder) meshLocalityLbSetting() *v1alpha3.LocalityLoadBalancerSetting {
+	if b.meshSettings != nil {
+		return b.meshSettings.LocalityLbSetting
+	}
+	if b.push == nil {
+		return nil
+	}
+	return b.push.Mesh.GetLocalityLbSetting()
+}
@@ -0,0 +1,37 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+// applyMigration adds the address the endpoint migrated from, or to, to its load balancer metadata, if its workload
+// instance was re-addressed. See PILOT_ENABLE_ENDPOINT_MIGRATION.
+func applyMigration(e *model.IstioEndpoint, eep *lbEndpointCopy) {
+	if e.PreviousAddress == "" && e.MigratedTo == "" {
+		return
+	}
+	lb := lbMetadata(eep.mutable())
+	if e.PreviousAddress != "" {
+		lb.Fields[util.LbPreviousAddressMetadataKey] = structpb.NewStringValue(e.PreviousAddress)
+	}
+	if e.MigratedTo != "" {
+		lb.Fields[util.LbMigratedToMetadataKey] = structpb.NewStringValue(e.MigratedTo)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestEndpointMigration(t *testing.T) {
+	test.SetForTest(t, &features.EnableEndpointMigration, true)
+	type migrated struct {
+		Health     corev3.HealthStatus
+		Previous   string
+		MigratedTo string
+	}
+	build := func(labels map[string]string) map[string]migrated {
+		svc := endpointstest.NewService()
+		svc.Attributes.Labels = labels
+		svc.Attributes.IncludeTerminating = constants.IncludeTerminatingAlways
+		index := model.NewEndpointIndex(model.DisabledCache{})
+		for _, address := range []string{"10.0.0.1", "10.0.0.2"} {
+			ep := endpointstest.NewEndpoint(address)
+			ep.InstanceName, ep.HealthStatus = "pod-1", model.Healthy
+			index.UpdateServiceEndpoints(endpointstest.Shard, string(svc.Hostname), "ns", []*model.IstioEndpoint{ep})
+		}
+		b := New(endpointstest.OutboundCluster(svc), WithService(svc), WithClusterID(endpointstest.ClusterID))
+		out := map[string]migrated{}
+		for _, llb := range b.BuildClusterLoadAssignment(index).Endpoints {
+			for _, lbEp := range llb.LbEndpoints {
+				lb := lbEp.GetMetadata().GetFilterMetadata()[util.EnvoyLbMetadataKey].GetFields()
+				out[lbEp.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()] = migrated{
+					Health:     lbEp.HealthStatus,
+					Previous:   lb[util.LbPreviousAddressMetadataKey].GetStringValue(),
+					MigratedTo: lb[util.LbMigratedToMetadataKey].GetStringValue(),
+				}
+			}
+		}
+		return out
+	}
+
+	// The previous address is not a terminating endpoint, even for services including them.
+	assert.Equal(t, build(nil), map[string]migrated{
+		"10.0.0.2": {Health: corev3.HealthStatus_HEALTHY, Previous: "10.0.0.1"},
+	})
+	assert.Equal(t, build(map[string]string{features.PersistentSessionLabel: "cookie"}), map[string]migrated{
+		"10.0.0.2": {Health: corev3.HealthStatus_HEALTHY, Previous: "10.0.0.1"},
+		"10.0.0.1": {Health: corev3.HealthStatus_DRAINING, MigratedTo: "10.0.0.2"},
+	})
+}
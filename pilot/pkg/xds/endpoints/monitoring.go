@@ -0,0 +1,139 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/monitoring"
+)
+
+var (
+	clusterTag = monitoring.CreateLabel("cluster")
+	reasonTag  = monitoring.CreateLabel("reason")
+	resultTag  = monitoring.CreateLabel("result")
+	limitTag   = monitoring.CreateLabel("limit")
+	serviceTag = monitoring.CreateLabel("service")
+	subsetTag  = monitoring.CreateLabel("subset")
+
+	weightNormalizations = monitoring.NewSum(
+		"pilot_eds_weight_normalizations",
+		"Total number of times endpoint load balancing weights were scaled down to fit the configured or maximum range.",
+	)
+
+	builtEndpoints = monitoring.NewSum(
+		"pilot_eds_endpoints",
+		"Total number of endpoints included in the ClusterLoadAssignments built by istiod, by the cluster the endpoints are in.",
+	)
+
+	filteredEndpoints = monitoring.NewSum(
+		"pilot_eds_endpoints_filtered",
+		"Total number of endpoints left out of the ClusterLoadAssignments built by istiod, by reason.",
+	)
+
+	sessionDrainedEndpoints = monitoring.NewGauge(
+		"pilot_eds_session_drained_endpoints",
+		"Number of draining endpoints kept in the clusters of services with persistent sessions, where they only "+
+			"receive the sessions pinned to them, by service and subset, as of the last ClusterLoadAssignment built.",
+	)
+
+	precomputedEndpoints = monitoring.NewSum(
+		"pilot_eds_precomputed_endpoints",
+		"Total number of Envoy endpoints built for CDS, by whether the precomputed endpoint was reused or rebuilt.",
+	)
+
+	topologyHintsFallbacks = monitoring.NewSum(
+		"pilot_eds_topology_hints_fallback",
+		"Total number of times all the endpoints of a service with topology aware routing were sent, as they could not be "+
+			"filtered by their zone hints, by reason.",
+	)
+
+	claSizeLimitExceeded = monitoring.NewSum(
+		"pilot_eds_cla_size_limit_exceeded",
+		"Total number of ClusterLoadAssignments built by istiod exceeding their soft or hard size limit, by limit. "+
+			"The ClusterLoadAssignments exceeding their hard limit are truncated.",
+	)
+
+	// filteredEndpointsByReason holds the filteredEndpoints metric of each reason endpoints are left out for, so that
+	// filtering an endpoint does not allocate.
+	filteredEndpointsByReason = func() map[string]monitoring.Metric {
+		m := make(map[string]monitoring.Metric, len(filterReasonLabels))
+		for reason, label := range filterReasonLabels {
+			m[reason] = filteredEndpoints.With(reasonTag.Value(label))
+		}
+		return m
+	}()
+	filteredEndpointsOther     = filteredEndpoints.With(reasonTag.Value("other"))
+	filteredEndpointsSizeLimit = filteredEndpoints.With(reasonTag.Value("size_limit"))
+
+	// filterReasonLabels maps the reasons endpoints are left out to the values of the reason label.
+	filterReasonLabels = map[string]string{
+		reasonNodeLocal:         "node_local",
+		reasonNetworkView:       "network_view",
+		reasonEndpointSelection: "endpoint_selection",
+		reasonClusterLocal:      "cluster_local",
+		reasonDiscoverability:   "discoverability",
+		reasonDataResidency:     "data_residency",
+		reasonPortMismatch:      "port_mismatch",
+		reasonSubsetMismatch:    "subset_mismatch",
+		reasonNoAddress:         "no_address",
+		reasonDraining:          "draining",
+		reasonBuildFailed:       "build_failed",
+		reasonNoGateway:         "no_gateway",
+		reasonNetworkMtls:       "network_mtls",
+		reasonMtls:              "mtls",
+		reasonScaleUpRamp:       "scale_up_ramp",
+		reasonDrainExpired:      "drain_expired",
+		reasonTopologyHints:     "topology_hints",
+		reasonTrafficCut:        "traffic_cut",
+	}
+)
+
+func endpointFiltered(reason string) {
+	metric, ok := filteredEndpointsByReason[reason]
+	if !ok {
+		metric = filteredEndpointsOther
+	}
+	metric.Increment()
+}
+
+func topologyHintsFallback(reason string) {
+	topologyHintsFallbacks.With(reasonTag.Value(reason)).Increment()
+}
+
+// recordEndpoints records the number of endpoints of a ClusterLoadAssignment by the cluster they are in.
+func recordEndpoints(locEps []*LocalityEndpoints) {
+	var counts map[cluster.ID]int
+	for _, l := range locEps {
+		for _, ep := range l.istioEndpoints {
+			if counts == nil {
+				counts = map[cluster.ID]int{}
+			}
+			counts[ep.Locality.ClusterID]++
+		}
+	}
+	for c, n := range counts {
+		builtEndpoints.With(clusterTag.Value(c.String())).RecordInt(int64(n))
+	}
+}
+
+// recordPrecomputedEndpoints records how many precomputed endpoints were reused and rebuilt by a build.
+func recordPrecomputedEndpoints(reused, rebuilt int) {
+	if reused > 0 {
+		precomputedEndpoints.With(resultTag.Value("reused")).RecordInt(int64(reused))
+	}
+	if rebuilt > 0 {
+		precomputedEndpoints.With(resultTag.Value("rebuilt")).RecordInt(int64(rebuilt))
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/monitoring/monitortest"
+)
+
+func TestEndpointMetrics(t *testing.T) {
+	mt := monitortest.New(t)
+
+	ep := func(c string) *model.IstioEndpoint {
+		return &model.IstioEndpoint{Locality: model.Locality{ClusterID: cluster.ID(c)}}
+	}
+	recordEndpoints([]*LocalityEndpoints{
+		{istioEndpoints: []*model.IstioEndpoint{ep("c1"), ep("c1")}},
+		{istioEndpoints: []*model.IstioEndpoint{ep("c2")}},
+	})
+	mt.Assert(builtEndpoints.Name(), map[string]string{"cluster": "c1"}, monitortest.Exactly(2))
+	mt.Assert(builtEndpoints.Name(), map[string]string{"cluster": "c2"}, monitortest.Exactly(1))
+
+	endpointFiltered(reasonDraining)
+	endpointFiltered(reasonDraining)
+	endpointFiltered(reasonMtls)
+	endpointFiltered("unknown")
+	mt.Assert(filteredEndpoints.Name(), map[string]string{"reason": "draining"}, monitortest.Exactly(2))
+	mt.Assert(filteredEndpoints.Name(), map[string]string{"reason": "mtls"}, monitortest.Exactly(1))
+	mt.Assert(filteredEndpoints.Name(), map[string]string{"reason": "other"}, monitortest.Exactly(1))
+}
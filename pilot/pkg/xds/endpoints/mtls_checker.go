@@ -22,18 +22,21 @@ import (
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/security/authn/factory"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/host"
 )
 
 // TODO this logic is probably done elsewhere in XDS, possible code-reuse + perf improvements
 type mtlsChecker struct {
 	push            *model.PushContext
+	hostname        host.Name
 	svcPort         int
 	destinationRule *networkingapi.ClientTLSSettings_TLSmode
 }
 
-func newMtlsChecker(push *model.PushContext, svcPort int, dr *config.Config, subset string) *mtlsChecker {
+func newMtlsChecker(push *model.PushContext, hostname host.Name, svcPort int, dr *config.Config, subset string) *mtlsChecker {
 	return &mtlsChecker{
 		push:            push,
+		hostname:        hostname,
 		svcPort:         svcPort,
 		destinationRule: tlsModeForDestinationRule(dr, subset, svcPort),
 	}
@@ -57,10 +60,66 @@ func (c *mtlsChecker) checkMtlsEnabled(ep *model.IstioEndpoint) bool {
 	if ep.TLSMode != model.IstioMutualTLSModeLabel {
 		return false
 	}
+	if c.push == nil {
+		// Without a push context, there are no PeerAuthentications: the endpoints are PERMISSIVE.
+		return true
+	}
+
+	return c.push.MutualTLSModeForService(c.hostname, ep.Namespace, ep.EndpointPort, ep.Labels, func() model.MutualTLSMode {
+		return factory.
+			NewMtlsPolicy(c.push, ep.Namespace, ep.Labels).
+			GetMutualTLSModeForPort(ep.EndpointPort)
+	}) != model.MTLSDisable
+}
 
-	return factory.
-		NewMtlsPolicy(c.push, ep.Namespace, ep.Labels).
-		GetMutualTLSModeForPort(ep.EndpointPort) != model.MTLSDisable
+// MtlsDecision explains the TLS mode computed for an endpoint: the DestinationRule TLS settings take precedence, then
+// endpoints without a sidecar are sent plaintext, and the PeerAuthentications of the others decide.
+type MtlsDecision struct {
+	Address string `json:"address"`
+	Port    uint32 `json:"port"`
+	// MtlsEnabled is true if the endpoint is sent with the istio TLS mode.
+	MtlsEnabled bool `json:"mtlsEnabled"`
+	// DestinationRuleTLSMode is the TLS mode set by the DestinationRule for the port and subset of the cluster.
+	DestinationRuleTLSMode string `json:"destinationRuleTlsMode,omitempty"`
+	// EndpointTLSMode is the TLS mode of the endpoint, from its security.istio.io/tlsMode label.
+	EndpointTLSMode string `json:"endpointTlsMode,omitempty"`
+	// PeerAuthenticationMode is the mutual TLS mode of the endpoint port set by its PeerAuthentications.
+	PeerAuthenticationMode string `json:"peerAuthenticationMode,omitempty"`
+	// PeerAuthentications are the PeerAuthentications applying to the endpoint, from the root namespace to the
+	// workload.
+	PeerAuthentications []string `json:"peerAuthentications,omitempty"`
+	// Reason is the setting deciding the TLS mode.
+	Reason string `json:"reason"`
+}
+
+// explain returns how checkMtlsEnabled decides the TLS mode of the endpoint.
+func (c *mtlsChecker) explain(ep *model.IstioEndpoint) MtlsDecision {
+	d := MtlsDecision{
+		Address:         ep.Address,
+		Port:            ep.EndpointPort,
+		MtlsEnabled:     c.checkMtlsEnabled(ep),
+		EndpointTLSMode: ep.TLSMode,
+	}
+	if drMode := c.destinationRule; drMode != nil {
+		d.DestinationRuleTLSMode = drMode.String()
+		d.Reason = "DestinationRule TLS mode"
+		return d
+	}
+	if ep.TLSMode != model.IstioMutualTLSModeLabel {
+		d.Reason = "endpoint without sidecar or with TLS disabled"
+		return d
+	}
+	if c.push == nil {
+		d.PeerAuthenticationMode = model.MTLSPermissive.String()
+		d.Reason = "PeerAuthentication mode"
+		return d
+	}
+	for _, pa := range c.push.AuthnPolicies.GetPeerAuthenticationsForWorkload(ep.Namespace, ep.Labels) {
+		d.PeerAuthentications = append(d.PeerAuthentications, pa.Namespace+"/"+pa.Name)
+	}
+	d.PeerAuthenticationMode = factory.NewMtlsPolicy(c.push, ep.Namespace, ep.Labels).GetMutualTLSModeForPort(ep.EndpointPort).String()
+	d.Reason = "PeerAuthentication mode"
+	return d
 }
 
 func tlsModeForDestinationRule(drc *config.Config, subset string, port int) *networkingapi.ClientTLSSettings_TLSmode {
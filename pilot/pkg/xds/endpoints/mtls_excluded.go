@@ -0,0 +1,144 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/slices"
+)
+
+// MtlsExclusion lists the endpoints of a SNI-DNAT cluster excluded by a gateway because they do not use mTLS, as the
+// AUTO_PASSTHROUGH gateways only forward mTLS traffic. It usually denotes a misconfigured remote cluster.
+type MtlsExclusion struct {
+	ClusterName string             `json:"clusterName"`
+	Proxy       string             `json:"proxy"`
+	Endpoints   []FilteredEndpoint `json:"endpoints"`
+	LastBuilt   time.Time          `json:"lastBuilt"`
+}
+
+// clusterMtlsExclusions are the exclusions of a SNI-DNAT cluster, by the ID of the gateway which built it.
+type clusterMtlsExclusions struct {
+	hostname  host.Name
+	namespace string
+	byProxy   map[string]MtlsExclusion
+}
+
+// mtlsExclusions holds the last exclusions of each SNI-DNAT cluster, as the ClusterLoadAssignments are built. The
+// gateways may have different views of the endpoints, so each one is compared with its own previous exclusions.
+var mtlsExclusions = struct {
+	sync.RWMutex
+	byCluster map[string]*clusterMtlsExclusions
+}{byCluster: map[string]*clusterMtlsExclusions{}}
+
+// MtlsExcluded returns the SNI-DNAT clusters with endpoints excluded because they do not use mTLS, sorted by name
+// and gateway.
+func MtlsExcluded() []MtlsExclusion {
+	mtlsExclusions.RLock()
+	defer mtlsExclusions.RUnlock()
+	var out []MtlsExclusion
+	for _, c := range mtlsExclusions.byCluster {
+		for _, e := range c.byProxy {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].ClusterName != out[j].ClusterName {
+			return out[i].ClusterName < out[j].ClusterName
+		}
+		return out[i].Proxy < out[j].Proxy
+	})
+	return out
+}
+
+// ForgetMtlsExcludedService forgets the exclusions of the clusters of a deleted service.
+func ForgetMtlsExcludedService(hostname, namespace string) {
+	mtlsExclusions.Lock()
+	defer mtlsExclusions.Unlock()
+	for name, c := range mtlsExclusions.byCluster {
+		if c.hostname == host.Name(hostname) && c.namespace == namespace {
+			delete(mtlsExclusions.byCluster, name)
+		}
+	}
+}
+
+// ForgetMtlsExcludedProxy forgets the exclusions of a disconnected gateway.
+func ForgetMtlsExcludedProxy(proxyID string) {
+	mtlsExclusions.Lock()
+	defer mtlsExclusions.Unlock()
+	for name, c := range mtlsExclusions.byCluster {
+		delete(c.byProxy, proxyID)
+		if len(c.byProxy) == 0 {
+			delete(mtlsExclusions.byCluster, name)
+		}
+	}
+}
+
+// recordMtlsExcluded records the endpoints of the cluster excluded by EndpointsWithMTLSFilter, in the push status
+// and the list returned by MtlsExcluded. The exclusions are logged when they change for the gateway.
+func (b *EndpointBuilder) recordMtlsExcluded(excluded []*model.IstioEndpoint) {
+	proxyID := b.proxy.GetID()
+	mtlsExclusions.Lock()
+	c := mtlsExclusions.byCluster[b.clusterName]
+	var previous MtlsExclusion
+	found := false
+	if c != nil {
+		previous, found = c.byProxy[proxyID]
+	}
+	if len(excluded) == 0 {
+		if found {
+			delete(c.byProxy, proxyID)
+			if len(c.byProxy) == 0 {
+				delete(mtlsExclusions.byCluster, b.clusterName)
+			}
+		}
+		mtlsExclusions.Unlock()
+		if found {
+			log.Infof("cluster %s no longer excludes endpoints due to plaintext TLS mode for %s", b.clusterName, proxyID)
+		}
+		return
+	}
+	endpoints := slices.Map(excluded, func(ep *model.IstioEndpoint) FilteredEndpoint {
+		return FilteredEndpoint{
+			Address:         ep.Address,
+			Port:            ep.EndpointPort,
+			ServicePortName: ep.ServicePortName,
+			Cluster:         ep.Locality.ClusterID.String(),
+			Reason:          reasonMtls,
+		}
+	})
+	if c == nil {
+		c = &clusterMtlsExclusions{hostname: b.hostname, byProxy: map[string]MtlsExclusion{}}
+		if b.service != nil {
+			c.namespace = b.service.Attributes.Namespace
+		}
+		mtlsExclusions.byCluster[b.clusterName] = c
+	}
+	c.byProxy[proxyID] = MtlsExclusion{ClusterName: b.clusterName, Proxy: proxyID, Endpoints: endpoints, LastBuilt: time.Now()}
+	mtlsExclusions.Unlock()
+
+	msg := fmt.Sprintf("%d endpoints excluded due to plaintext TLS mode", len(excluded))
+	if b.push != nil {
+		b.push.AddMetric(model.ProxyStatusClusterMtlsExcluded, b.clusterName, proxyID, msg)
+	}
+	if !found || !slices.Equal(previous.Endpoints, endpoints) {
+		log.Warnf("cluster %s: %s for %s, see /debug/mtls_excluded", b.clusterName, msg, proxyID)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"fmt"
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestMtlsExcluded(t *testing.T) {
+	const clusterName = "outbound_.80_._.example.ns.svc.cluster.local"
+	t.Cleanup(func() {
+		ForgetMtlsExcludedService("example.ns.svc.cluster.local", "ns")
+	})
+	filter := func(proxyID string, plaintext ...string) []string {
+		locLbEps := &LocalityEndpoints{}
+		for _, address := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+			mode := model.IstioMutualTLSModeLabel
+			if slices.Contains(plaintext, address) {
+				mode = model.DisabledTLSModeLabel
+			}
+			eep := lbEndpoint(address, 8080, 1)
+			eep.Metadata = &core.Metadata{FilterMetadata: map[string]*structpb.Struct{
+				util.EnvoyTransportSocketMetadataKey: {Fields: map[string]*structpb.Value{
+					model.TLSModeLabelShortname: structpb.NewStringValue(mode),
+				}},
+			}}
+			locLbEps.append(&model.IstioEndpoint{Address: address, EndpointPort: 8080, Locality: model.Locality{ClusterID: "remote"}}, eep)
+		}
+		push := model.NewPushContext()
+		b := &EndpointBuilder{
+			clusterName: clusterName,
+			hostname:    "example.ns.svc.cluster.local",
+			service:     &model.Service{Attributes: model.ServiceAttributes{Namespace: "ns"}},
+			proxy:       &model.Proxy{ID: proxyID},
+			push:        push,
+		}
+		b.EndpointsWithMTLSFilter([]*LocalityEndpoints{locLbEps})
+
+		status := push.ProxyStatus[model.ProxyStatusClusterMtlsExcluded.Name()]
+		var excluded []string
+		for _, e := range MtlsExcluded() {
+			if e.ClusterName != clusterName || e.Proxy != proxyID {
+				continue
+			}
+			assert.Equal(t, status[clusterName].Message, fmt.Sprintf("%d endpoints excluded due to plaintext TLS mode", len(plaintext)))
+			for _, ep := range e.Endpoints {
+				assert.Equal(t, ep.Cluster, "remote")
+				excluded = append(excluded, ep.Address)
+			}
+		}
+		if excluded == nil {
+			assert.Equal(t, len(status), 0)
+		}
+		return excluded
+	}
+
+	assert.Equal(t, filter("gw1", "10.0.0.1", "10.0.0.3"), []string{"10.0.0.1", "10.0.0.3"})
+	// The exclusions are recorded by gateway, as they may have different views of the endpoints.
+	assert.Equal(t, filter("gw2", "10.0.0.2"), []string{"10.0.0.2"})
+	assert.Equal(t, len(MtlsExcluded()), 2)
+	// Once the endpoints use mTLS, the cluster is no longer listed for the gateway.
+	assert.Equal(t, filter("gw1"), nil)
+	assert.Equal(t, len(MtlsExcluded()), 1)
+
+	ForgetMtlsExcludedService("example.ns.svc.cluster.local", "other")
+	assert.Equal(t, len(MtlsExcluded()), 1)
+	ForgetMtlsExcludedService("example.ns.svc.cluster.local", "ns")
+	assert.Equal(t, len(MtlsExcluded()), 0)
+
+	filter("gw1", "10.0.0.1")
+	ForgetMtlsExcludedProxy("gw1")
+	assert.Equal(t, len(MtlsExcluded()), 0)
+}
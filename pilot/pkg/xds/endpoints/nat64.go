@@ -0,0 +1,90 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"net/netip"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+)
+
+// nat64PrefixForProxy returns the NAT64 prefix of an IPv6-only proxy, set with its NAT64_PREFIX metadata, or an
+// invalid prefix if it is not set, invalid, or the proxy supports IPv4.
+func nat64PrefixForProxy(proxy *model.Proxy) netip.Prefix {
+	if proxy == nil || proxy.Metadata == nil || proxy.Metadata.NAT64Prefix == "" || !proxy.IsIPv6() {
+		return netip.Prefix{}
+	}
+	return parseNAT64Prefix(proxy.Metadata.NAT64Prefix)
+}
+
+// parseNAT64Prefix parses a NAT64 prefix. The prefix lengths are the ones defined by RFC 6052.
+func parseNAT64Prefix(s string) netip.Prefix {
+	if s == "" {
+		return netip.Prefix{}
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil || !prefix.Addr().Is6() {
+		log.Debugf("ignoring invalid NAT64 prefix %q", s)
+		return netip.Prefix{}
+	}
+	switch prefix.Bits() {
+	case 32, 40, 48, 56, 64, 96:
+		return prefix.Masked()
+	default:
+		log.Debugf("ignoring NAT64 prefix %q: the prefix length must be 32, 40, 48, 56, 64 or 96", s)
+		return netip.Prefix{}
+	}
+}
+
+// synthesizeNAT64 embeds the IPv4 address in the NAT64 prefix, as described in RFC 6052.
+func synthesizeNAT64(prefix netip.Prefix, v4 netip.Addr) netip.Addr {
+	out := prefix.Addr().As16()
+	pos := prefix.Bits() / 8
+	for _, octet := range v4.As4() {
+		if pos == 8 {
+			// Bits 64 to 71 of the address are reserved and must be zero.
+			pos++
+		}
+		out[pos] = octet
+		pos++
+	}
+	return netip.AddrFrom16(out)
+}
+
+// useNAT64 returns true if the IPv4 endpoints of the service are translated for the proxy.
+func (b *EndpointBuilder) useNAT64() bool {
+	return b.nat64Prefix.IsValid() && b.service != nil && b.service.Attributes.ServiceRegistry == provider.External
+}
+
+// applyNAT64 translates the IPv4 address of the endpoint to the NAT64 prefix, if the service is a
+// ServiceEntry and the proxy only supports IPv6.
+func (b *EndpointBuilder) applyNAT64(eep *lbEndpointCopy) {
+	if !b.useNAT64() {
+		return
+	}
+	sa := eep.lbEp.GetEndpoint().GetAddress().GetSocketAddress()
+	if sa == nil {
+		return
+	}
+	addr, err := netip.ParseAddr(sa.GetAddress())
+	if err != nil || !addr.Is4() {
+		return
+	}
+	eep.mutable().GetEndpoint().Address.Address.(*corev3.Address_SocketAddress).SocketAddress.Address =
+		synthesizeNAT64(b.nat64Prefix, addr).String()
+}
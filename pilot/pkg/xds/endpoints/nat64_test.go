@@ -0,0 +1,96 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"net/netip"
+	"testing"
+
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+)
+
+func TestSynthesizeNAT64(t *testing.T) {
+	v4 := netip.MustParseAddr("192.0.2.33")
+	cases := []struct {
+		prefix string
+		want   string
+	}{
+		{"64:ff9b::/96", "64:ff9b::c000:221"},
+		{"2001:db8::/32", "2001:db8:c000:221::"},
+		{"2001:db8:100::/40", "2001:db8:1c0:2:21::"},
+		{"2001:db8:122:344::/64", "2001:db8:122:344:c0:2:2100:0"},
+	}
+	for _, c := range cases {
+		prefix := parseNAT64Prefix(c.prefix)
+		if got := synthesizeNAT64(prefix, v4).String(); got != c.want {
+			t.Errorf("%s: expected %s, got %s", c.prefix, c.want, got)
+		}
+	}
+	for _, invalid := range []string{"64:ff9b::/80", "10.0.0.0/8", "invalid"} {
+		if parseNAT64Prefix(invalid).IsValid() {
+			t.Errorf("expected %s to be rejected", invalid)
+		}
+	}
+}
+
+func TestApplyNAT64(t *testing.T) {
+	proxy := func(ips ...string) *model.Proxy {
+		p := &model.Proxy{IPAddresses: ips, Metadata: &model.NodeMetadata{NAT64Prefix: "64:ff9b::/96"}}
+		p.DiscoverIPMode()
+		return p
+	}
+	builder := func(proxy *model.Proxy, svc *model.Service) *EndpointBuilder {
+		return &EndpointBuilder{proxy: proxy, service: svc, nat64Prefix: nat64PrefixForProxy(proxy)}
+	}
+	address := func(b *EndpointBuilder, ip string) string {
+		eep := &lbEndpointCopy{lbEp: &endpoint.LbEndpoint{HostIdentifier: &endpoint.LbEndpoint_Endpoint{
+			Endpoint: &endpoint.Endpoint{Address: util.BuildAddress(ip, 80)},
+		}}}
+		b.applyNAT64(eep)
+		return eep.lbEp.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()
+	}
+	serviceEntry := &model.Service{Attributes: model.ServiceAttributes{ServiceRegistry: provider.External}}
+	b := builder(proxy("2001:db8::1"), serviceEntry)
+	if got := address(b, "192.0.2.33"); got != "64:ff9b::c000:221" {
+		t.Fatalf("expected a translated address, got %s", got)
+	}
+	if got := address(b, "2001:db8::2"); got != "2001:db8::2" {
+		t.Fatalf("expected IPv6 addresses to be left alone, got %s", got)
+	}
+
+	b = builder(proxy("10.0.0.1", "2001:db8::1"), serviceEntry)
+	if got := address(b, "192.0.2.33"); got != "192.0.2.33" {
+		t.Fatalf("expected no translation for dual stack proxies, got %s", got)
+	}
+
+	b = builder(proxy("2001:db8::1"), &model.Service{Attributes: model.ServiceAttributes{
+		ServiceRegistry: provider.Kubernetes,
+	}})
+	if got := address(b, "192.0.2.33"); got != "192.0.2.33" {
+		t.Fatalf("expected no translation for Kubernetes services, got %s", got)
+	}
+
+	// Without the proxy metadata, nothing is translated.
+	noPrefix := proxy("2001:db8::1")
+	noPrefix.Metadata.NAT64Prefix = ""
+	b = builder(noPrefix, serviceEntry)
+	if got := address(b, "192.0.2.33"); got != "192.0.2.33" {
+		t.Fatalf("expected no translation without a NAT64 prefix, got %s", got)
+	}
+}
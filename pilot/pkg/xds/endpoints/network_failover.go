@@ -0,0 +1,25 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"istio.io/istio/pkg/config/constants"
+)
+
+// remoteNetworkFailover returns true if the endpoints of remote networks, reached through their network gateways,
+// only receive traffic when the local endpoints are unhealthy.
+func (b *EndpointBuilder) remoteNetworkFailover() bool {
+	return b.service.CrossNetworkFailover() == constants.CrossNetworkFailoverPriority
+}
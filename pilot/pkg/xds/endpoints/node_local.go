@@ -0,0 +1,32 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// preferNodeLocal returns true if the node local endpoints are preferred, with the
+// networking.istio.io/internal-traffic-policy: PreferLocal annotation of the service. Proxies with no known node,
+// such as VMs, have no node local endpoints and treat all the endpoints alike.
+func (b *EndpointBuilder) preferNodeLocal() bool {
+	return b.service.Attributes.PreferNodeLocal && b.nodeName() != ""
+}
+
+// isRemoteNode returns true if the endpoint is on another node than the proxy, for the services preferring node
+// local endpoints.
+func (b *EndpointBuilder) isRemoteNode(e *model.IstioEndpoint) bool {
+	return b.preferNodeLocal() && e.NodeName != b.nodeName()
+}
@@ -0,0 +1,78 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/util/label"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestPreferNodeLocalEndpoints(t *testing.T) {
+	svc := endpointstest.NewService()
+	svc.Attributes.PreferNodeLocal = true
+	ep := func(address, node string) *model.IstioEndpoint {
+		ep := endpointstest.NewEndpoint(address)
+		ep.NodeName = node
+		return ep
+	}
+	type priority struct {
+		Addresses []string
+		Priority  uint32
+	}
+	build := func(node string, eps ...*model.IstioEndpoint) []priority {
+		index := endpointstest.NewIndex(svc, eps...)
+		b := New(endpointstest.OutboundCluster(svc),
+			WithService(svc),
+			WithClusterID(endpointstest.ClusterID),
+			WithLabels(map[string]string{label.LabelHostname: node}),
+		)
+		var out []priority
+		for _, llb := range b.BuildClusterLoadAssignment(index).Endpoints {
+			p := priority{Priority: llb.Priority}
+			for _, lbEp := range llb.LbEndpoints {
+				p.Addresses = append(p.Addresses, lbEp.GetEndpoint().GetAddress().GetSocketAddress().GetAddress())
+			}
+			out = append(out, p)
+		}
+		return out
+	}
+	standby := ep("10.0.0.4", "node-c")
+	standby.Labels = map[string]string{constants.StandbyLabel: "true"}
+
+	// The endpoints on the node of the proxy are preferred, the others receive the traffic spilling over.
+	assert.Equal(t, build("node-a", ep("10.0.0.1", "node-a"), ep("10.0.0.2", "node-b"), ep("10.0.0.3", "node-c")), []priority{
+		{Addresses: []string{"10.0.0.1"}, Priority: 0},
+		{Addresses: []string{"10.0.0.2", "10.0.0.3"}, Priority: 1},
+	})
+	// Without a node local endpoint, the other endpoints receive the traffic.
+	assert.Equal(t, build("node-d", ep("10.0.0.2", "node-b"), ep("10.0.0.3", "node-c")), []priority{
+		{Addresses: []string{"10.0.0.2", "10.0.0.3"}, Priority: 0},
+	})
+	// Standby endpoints come after the endpoints of other nodes.
+	assert.Equal(t, build("node-a", ep("10.0.0.1", "node-a"), ep("10.0.0.2", "node-b"), standby), []priority{
+		{Addresses: []string{"10.0.0.1"}, Priority: 0},
+		{Addresses: []string{"10.0.0.2"}, Priority: 1},
+		{Addresses: []string{"10.0.0.4"}, Priority: 2},
+	})
+	// Proxies with no node treat all the endpoints alike.
+	assert.Equal(t, build("", ep("10.0.0.1", "node-a"), ep("10.0.0.2", "node-b")), []priority{
+		{Addresses: []string{"10.0.0.1", "10.0.0.2"}, Priority: 0},
+	})
+}
@@ -0,0 +1,162 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// PassthroughEndpoint is an endpoint of a ServiceEntry reached through the PassthroughEndpointsCluster.
+type PassthroughEndpoint struct {
+	Service  *model.Service
+	Endpoint *model.IstioEndpoint
+}
+
+// Key is the <address>:<port> of the endpoint, which selects it within the PassthroughEndpointsCluster.
+func (p PassthroughEndpoint) Key() string {
+	return net.JoinHostPort(p.Endpoint.Address, strconv.Itoa(int(p.Endpoint.EndpointPort)))
+}
+
+// isPassthroughEndpoint returns true if the endpoint can be reached through the PassthroughEndpointsCluster.
+func isPassthroughEndpoint(ep *model.IstioEndpoint) bool {
+	if ep.EndpointPort == 0 {
+		return false
+	}
+	// Endpoints resolved by DNS cannot be matched by the address of the connection.
+	_, err := netip.ParseAddr(ep.Address)
+	return err == nil
+}
+
+// PassthroughKeys returns the keys of the endpoints reached through the PassthroughEndpointsCluster. The filter chains
+// of the virtual outbound listener only depend on them, so the other changes of the endpoints are pushed by EDS.
+func PassthroughKeys(eps []*model.IstioEndpoint) sets.String {
+	keys := sets.New[string]()
+	for _, ep := range eps {
+		if isPassthroughEndpoint(ep) {
+			keys.Insert(PassthroughEndpoint{Endpoint: ep}.Key())
+		}
+	}
+	return keys
+}
+
+// PassthroughEndpoints returns the endpoints of the ServiceEntries visible to the proxy with an IP address, sorted by
+// address and port. Endpoints of several services are attributed to the first service by hostname and namespace.
+// The endpoints are the ones of the push context, so the listeners and the ClusterLoadAssignment built for the
+// same push agree.
+func PassthroughEndpoints(proxy *model.Proxy, push *model.PushContext) []PassthroughEndpoint {
+	if proxy.SidecarScope == nil {
+		return nil
+	}
+	services := make([]*model.Service, 0)
+	for _, svc := range proxy.SidecarScope.Services() {
+		if svc.Attributes.ServiceRegistry == provider.External {
+			services = append(services, svc)
+		}
+	}
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Hostname != services[j].Hostname {
+			return services[i].Hostname < services[j].Hostname
+		}
+		return services[i].Attributes.Namespace < services[j].Attributes.Namespace
+	})
+
+	var out []PassthroughEndpoint
+	seen := sets.New[string]()
+	for _, svc := range services {
+		byPort := push.ServiceEndpoints(svc.Key())
+		ports := make([]int, 0, len(byPort))
+		for port := range byPort {
+			ports = append(ports, port)
+		}
+		sort.Ints(ports)
+		for _, port := range ports {
+			for _, ep := range byPort[port] {
+				if !isPassthroughEndpoint(ep) || !ep.IsDiscoverableFromProxy(proxy) {
+					continue
+				}
+				pep := PassthroughEndpoint{Service: svc, Endpoint: ep}
+				if seen.InsertContains(pep.Key()) {
+					continue
+				}
+				out = append(out, pep)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		ai, aj := netip.MustParseAddr(out[i].Endpoint.Address), netip.MustParseAddr(out[j].Endpoint.Address)
+		if ai != aj {
+			return ai.Less(aj)
+		}
+		return out[i].Endpoint.EndpointPort < out[j].Endpoint.EndpointPort
+	})
+	return out
+}
+
+// BuildPassthroughClusterLoadAssignment builds the ClusterLoadAssignment of the PassthroughEndpointsCluster of the
+// proxy, named after the requested resource. Each endpoint carries the telemetry metadata of its workload and the
+// hostname of its service, and is selected by the filter chain of its address through its load balancer metadata.
+func BuildPassthroughClusterLoadAssignment(proxy *model.Proxy, push *model.PushContext, name string) *endpoint.ClusterLoadAssignment {
+	cla := &endpoint.ClusterLoadAssignment{ClusterName: name}
+	peps := PassthroughEndpoints(proxy, push)
+	if len(peps) == 0 {
+		return cla
+	}
+	lbEps := make([]*endpoint.LbEndpoint, 0, len(peps))
+	for _, pep := range peps {
+		lbEps = append(lbEps, buildPassthroughLbEndpoint(pep))
+	}
+	cla.Endpoints = []*endpoint.LocalityLbEndpoints{{LbEndpoints: lbEps}}
+	return cla
+}
+
+func buildPassthroughLbEndpoint(pep PassthroughEndpoint) *endpoint.LbEndpoint {
+	ep := pep.Endpoint
+	lbEp := &endpoint.LbEndpoint{
+		// The endpoint is the only one of its subset, and the traffic matching its filter chain would be sent to its
+		// address by the PassthroughCluster anyway: it is always healthy, so the traffic is never dropped.
+		HealthStatus:        corev3.HealthStatus_HEALTHY,
+		LoadBalancingWeight: wrapperspb.UInt32(ep.GetLoadBalancingWeight()),
+		HostIdentifier: &endpoint.LbEndpoint_Endpoint{
+			Endpoint: &endpoint.Endpoint{
+				// Passthrough traffic goes to the address it was sent to, so the address is not translated.
+				Address: util.BuildAddress(ep.Address, ep.EndpointPort),
+			},
+		},
+		Metadata: &corev3.Metadata{},
+	}
+	meta := ep.MetadataClone()
+	// Passthrough traffic is sent as is, like through the PassthroughCluster.
+	meta.TLSMode = ""
+	if meta.Namespace == "" {
+		meta.Namespace = pep.Service.Attributes.Namespace
+	}
+	util.AppendLbEndpointMetadata(meta, lbEp.Metadata)
+	istioMetadata(lbEp).Fields[util.IstioServiceMetadataKey] = structpb.NewStringValue(string(pep.Service.Hostname))
+	lbMetadata(lbEp).Fields[util.LbAddressMetadataKey] = structpb.NewStringValue(pep.Key())
+	return lbEp
+}
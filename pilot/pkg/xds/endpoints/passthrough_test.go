@@ -0,0 +1,106 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints_test
+
+import (
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/xds"
+	. "istio.io/istio/pilot/pkg/xds/endpoints"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/util/sets"
+)
+
+func TestBuildPassthroughClusterLoadAssignment(t *testing.T) {
+	ds := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: db
+  namespace: default
+spec:
+  hosts:
+  - db.example.com
+  ports:
+  - number: 5432
+    name: tcp
+    protocol: TCP
+  resolution: STATIC
+  endpoints:
+  - address: 10.1.0.2
+  - address: 10.1.0.1
+    labels:
+      app: db
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: api
+  namespace: default
+spec:
+  hosts:
+  - api.example.com
+  ports:
+  - number: 443
+    name: tls
+    protocol: TLS
+  resolution: DNS
+  endpoints:
+  - address: api.internal.example.com
+`})
+	proxy := ds.SetupProxy(nil)
+
+	cla := BuildPassthroughClusterLoadAssignment(proxy, ds.PushContext(), util.PassthroughEndpointsCluster)
+	assert.Equal(t, cla.ClusterName, util.PassthroughEndpointsCluster)
+	// The endpoints resolved by DNS are not included, as connections are not sent to their hostnames.
+	var keys, services, workloads []string
+	for _, llb := range cla.Endpoints {
+		for _, lbEp := range llb.LbEndpoints {
+			md := lbEp.GetMetadata().GetFilterMetadata()
+			keys = append(keys, md[util.EnvoyLbMetadataKey].GetFields()[util.LbAddressMetadataKey].GetStringValue())
+			services = append(services, md[util.IstioMetadataKey].GetFields()[util.IstioServiceMetadataKey].GetStringValue())
+			workloads = append(workloads, md[util.IstioMetadataKey].GetFields()["workload"].GetStringValue())
+			// Passthrough traffic is not upgraded to mTLS.
+			assert.Equal(t, md[util.EnvoyTransportSocketMetadataKey] == nil, true)
+			// The traffic matching the filter chain of the endpoint is never dropped because of its health.
+			assert.Equal(t, lbEp.HealthStatus, core.HealthStatus_HEALTHY)
+		}
+	}
+	assert.Equal(t, keys, []string{"10.1.0.1:5432", "10.1.0.2:5432"})
+	assert.Equal(t, services, []string{"db.example.com", "db.example.com"})
+	assert.Equal(t, workloads, []string{";default;db;;Kubernetes", ";default;;;Kubernetes"})
+}
+
+func TestPassthroughKeys(t *testing.T) {
+	eps := []*model.IstioEndpoint{
+		{Address: "10.1.0.1", EndpointPort: 5432, HealthStatus: model.Healthy},
+		{Address: "api.internal.example.com", EndpointPort: 443},
+		{Address: "10.1.0.2"},
+	}
+	assert.Equal(t, PassthroughKeys(eps), sets.New("10.1.0.1:5432"))
+
+	// The changes of the endpoints other than their addresses and ports do not change the keys, so they are only
+	// pushed by EDS.
+	updated := []*model.IstioEndpoint{
+		{Address: "10.1.0.1", EndpointPort: 5432, HealthStatus: model.UnHealthy, Labels: map[string]string{"app": "db"}},
+	}
+	assert.Equal(t, PassthroughKeys(updated).Equals(PassthroughKeys(eps)), true)
+	moved := []*model.IstioEndpoint{{Address: "10.1.0.3", EndpointPort: 5432}}
+	assert.Equal(t, PassthroughKeys(moved).Equals(PassthroughKeys(eps)), false)
+}
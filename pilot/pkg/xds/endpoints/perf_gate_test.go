@@ -0,0 +1,99 @@
+//go:build perftest
+// +build perftest
+
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"testing"
+
+	"istio.io/istio/pilot/test/util"
+)
+
+// calibrationBenchmark sorts a fixed list of numbers. The cost of the scenarios is recorded relative to it, so that
+// the baselines hold across machines.
+func calibrationBenchmark(b *testing.B) {
+	input := rand.New(rand.NewSource(1)).Perm(100000)
+	values := make([]int, len(input))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		copy(values, input)
+		sort.Ints(values)
+	}
+}
+
+// perfBaseline is the recorded cost of a scenario.
+type perfBaseline struct {
+	// RelativeCost is the time per operation of the scenario, relative to the calibration benchmark.
+	RelativeCost float64 `json:"relativeCost"`
+	// AllocsPerOp is the number of allocations per operation of the scenario.
+	AllocsPerOp int64 `json:"allocsPerOp"`
+}
+
+// TestEndpointBuilderPerfGate fails if a scenario of the performance suite is perfRegressionFactor times more
+// expensive than its recorded baseline. Its timings are only meaningful on a dedicated machine, so it is built with
+// the perftest tag only, as set by `make perftest.endpoints`.
+func TestEndpointBuilderPerfGate(t *testing.T) {
+	calibration := float64(testing.Benchmark(calibrationBenchmark).NsPerOp())
+	got := map[string]perfBaseline{}
+	for _, sc := range perfScenarios(perfScenarioSizes...) {
+		r := testing.Benchmark(perfBenchmark(t, sc))
+		got[sc.name] = perfBaseline{
+			RelativeCost: math.Round(float64(r.NsPerOp())/calibration*1000) / 1000,
+			AllocsPerOp:  r.AllocsPerOp(),
+		}
+		t.Logf("%s: %s %s, relative cost %.3f", sc.name, r.String(), r.MemString(), got[sc.name].RelativeCost)
+	}
+
+	if util.Refresh() {
+		data, err := json.MarshalIndent(got, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(perfBaselineFile, append(data, '\n'), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	data, err := os.ReadFile(perfBaselineFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]perfBaseline{}
+	if err := json.Unmarshal(data, &want); err != nil {
+		t.Fatal(err)
+	}
+	for name, g := range got {
+		w, f := want[name]
+		if !f {
+			t.Errorf("%s: no baseline recorded, refresh %s", name, perfBaselineFile)
+			continue
+		}
+		if g.RelativeCost > w.RelativeCost*perfRegressionFactor {
+			t.Errorf("%s: relative cost %.3f is more than %d times the baseline %.3f", name, g.RelativeCost, perfRegressionFactor, w.RelativeCost)
+		}
+		if g.AllocsPerOp > w.AllocsPerOp*perfRegressionFactor {
+			t.Errorf("%s: %d allocations per operation is more than %d times the baseline %d",
+				name, g.AllocsPerOp, perfRegressionFactor, w.AllocsPerOp)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"fmt"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/test"
+)
+
+const (
+	// perfBaselineFile records the cost of each scenario of the performance suite. Refresh it with REFRESH_GOLDEN=true
+	// and the perftest tag when a change is expected to affect the performance of the endpoint builder.
+	perfBaselineFile = "testdata/perf_baseline.json"
+	// perfRegressionFactor is how much slower than its baseline a scenario can be before the gate fails.
+	perfRegressionFactor = 2
+)
+
+// perfScenarioSizes are the numbers of endpoints of the scenarios of the performance suite.
+var perfScenarioSizes = []int{1000, 10000, 100000}
+
+// perfScenario is a scenario of the performance suite: the ClusterLoadAssignment of a single service of the given
+// number of endpoints, built for a proxy of the first network.
+type perfScenario struct {
+	name string
+	opts endpointstest.MeshOptions
+	// precomputed disables PILOT_ENABLE_HBONE, so the builder reuses the precomputed Envoy endpoints.
+	precomputed bool
+}
+
+func perfScenarios(sizes ...int) []perfScenario {
+	var out []perfScenario
+	for _, size := range sizes {
+		variants := []perfScenario{
+			{name: "precomputed", opts: endpointstest.MeshOptions{Networks: 1}, precomputed: true},
+			{name: "base", opts: endpointstest.MeshOptions{Networks: 1}},
+			{name: "dr", opts: endpointstest.MeshOptions{Networks: 1, DestinationRule: true}},
+			{name: "hbone", opts: endpointstest.MeshOptions{Networks: 1, HBONE: true}},
+			{name: "multinetwork", opts: endpointstest.MeshOptions{Networks: 4}},
+		}
+		for _, v := range variants {
+			v.opts.Services = 1
+			v.opts.Endpoints = size
+			v.name = fmt.Sprintf("%dk/%s", size/1000, v.name)
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// perfBenchmark returns the benchmark of a scenario. The mesh is generated once, outside of the benchmark.
+func perfBenchmark(t test.Failer, sc perfScenario) func(b *testing.B) {
+	test.SetForTest(t, &features.EnableHBONE, !sc.precomputed)
+	m := endpointstest.NewMesh(t, sc.opts)
+	builder := NewEndpointBuilder(endpointstest.ClusterName(0), m.Proxy(0), m.Push)
+	return func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			if sc.precomputed {
+				// Only CDS reuses the precomputed endpoints.
+				builder.FromServiceEndpoints()
+			} else {
+				builder.BuildClusterLoadAssignment(m.EndpointIndex)
+			}
+		}
+	}
+}
+
+func BenchmarkEndpointBuilderSuite(b *testing.B) {
+	for _, sc := range perfScenarios(perfScenarioSizes...) {
+		b.Run(sc.name, perfBenchmark(b, sc))
+	}
+}
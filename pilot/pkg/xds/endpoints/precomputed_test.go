@@ -0,0 +1,87 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/monitoring/monitortest"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestPrecomputedEndpoints(t *testing.T) {
+	build := func(disabled bool) (*model.IstioEndpoint, *EndpointBuilder) {
+		svc := endpointstest.NewService()
+		svc.Attributes.DisablePrecomputedEndpoints = disabled
+		ep := endpointstest.NewEndpoint("10.0.0.1")
+		ep.HealthStatus = model.Healthy
+		b := New(endpointstest.OutboundCluster(svc), WithService(svc), WithClusterID(endpointstest.ClusterID)).(*EndpointBuilder)
+		return ep, b
+	}
+	assertResults := func(mt *monitortest.MetricsTest, reused, rebuilt float64) {
+		t.Helper()
+		mt.Assert(precomputedEndpoints.Name(), map[string]string{"result": "reused"}, monitortest.Exactly(reused))
+		mt.Assert(precomputedEndpoints.Name(), map[string]string{"result": "rebuilt"}, monitortest.Exactly(rebuilt))
+	}
+
+	t.Run("reused", func(t *testing.T) {
+		mt := monitortest.New(t)
+		ep, b := build(false)
+		b.generate([]*model.IstioEndpoint{ep}, true)
+		assert.Equal(t, ep.EnvoyEndpoint() != nil, true)
+		b.generate([]*model.IstioEndpoint{ep}, true)
+		assertResults(mt, 1, 1)
+	})
+	t.Run("shared", func(t *testing.T) {
+		ep, b := build(false)
+		b.generate([]*model.IstioEndpoint{ep}, true)
+		// The builds which do not modify the precomputed endpoint do not copy it.
+		eep := b.generate([]*model.IstioEndpoint{ep}, true)[0].llbEndpoints.LbEndpoints[0]
+		assert.Equal(t, eep == ep.EnvoyEndpoint(), true)
+	})
+	t.Run("copied", func(t *testing.T) {
+		ep, b := build(false)
+		ep.CapacityRPS = 100
+		for i := 0; i < 2; i++ {
+			eep := b.generate([]*model.IstioEndpoint{ep}, true)[0].llbEndpoints.LbEndpoints[0]
+			assert.Equal(t, eep != ep.EnvoyEndpoint(), true)
+			assert.Equal(t, lbMetadata(eep).Fields[util.LbCapacityRPSMetadataKey].GetNumberValue(), 100.0)
+		}
+		// The precomputed endpoint is shared with other clusters, the builder modifies a copy of it.
+		assert.Equal(t, ep.EnvoyEndpoint().GetMetadata().GetFilterMetadata()[util.EnvoyLbMetadataKey] == nil, true)
+	})
+	t.Run("annotation", func(t *testing.T) {
+		mt := monitortest.New(t)
+		ep, b := build(true)
+		b.generate([]*model.IstioEndpoint{ep}, true)
+		b.generate([]*model.IstioEndpoint{ep}, true)
+		assert.Equal(t, ep.EnvoyEndpoint() == nil, true)
+		assertResults(mt, 0, 2)
+	})
+	t.Run("feature", func(t *testing.T) {
+		test.SetForTest(t, &features.EnablePrecomputedEndpoints, false)
+		mt := monitortest.New(t)
+		ep, b := build(false)
+		b.generate([]*model.IstioEndpoint{ep}, true)
+		b.generate([]*model.IstioEndpoint{ep}, true)
+		assert.Equal(t, ep.EnvoyEndpoint() == nil, true)
+		assertResults(mt, 0, 2)
+	})
+}
@@ -0,0 +1,53 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/constants"
+)
+
+// publishNotReadyHealth returns how the not ready endpoints of the service are sent, from the annotation of
+// its DestinationRule or else PILOT_PUBLISH_NOT_READY_ADDRESSES_HEALTH.
+func (b *EndpointBuilder) publishNotReadyHealth() string {
+	if dr := b.destinationRule.GetRule(); dr != nil {
+		switch v := dr.Annotations[constants.PublishNotReadyAddresses]; v {
+		case constants.PublishNotReadyHealthy, constants.PublishNotReadyUnhealthy:
+			return v
+		case "":
+		default:
+			log.Debugf("invalid %s annotation %q on DestinationRule %s/%s",
+				constants.PublishNotReadyAddresses, v, dr.Namespace, dr.Name)
+		}
+	}
+	if features.PublishNotReadyAddressesHealth == constants.PublishNotReadyUnhealthy {
+		return constants.PublishNotReadyUnhealthy
+	}
+	return constants.PublishNotReadyHealthy
+}
+
+// applyPublishNotReady marks the endpoint healthy if it is a not ready endpoint of a service publishing
+// them, and they are to be sent as healthy. Otherwise they keep their unhealthy status, as do the endpoints of
+// completed pods, which never receive traffic.
+func (b *EndpointBuilder) applyPublishNotReady(e *model.IstioEndpoint, eep *lbEndpointCopy) {
+	if !b.service.Attributes.PublishNotReadyAddresses || e.Completed || e.HealthStatus != model.UnHealthy ||
+		eep.lbEp.HealthStatus != corev3.HealthStatus_UNHEALTHY || b.publishNotReadyHealth() != constants.PublishNotReadyHealthy {
+		return
+	}
+	eep.mutable().HealthStatus = corev3.HealthStatus_HEALTHY
+}
@@ -0,0 +1,105 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+
+	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestApplyPublishNotReady(t *testing.T) {
+	drWith := func(v string) *model.ConsolidatedDestRule {
+		return model.ConvertConsolidatedDestRule(&config.Config{
+			Meta: config.Meta{
+				Name:        "dr",
+				Namespace:   "ns",
+				Annotations: map[string]string{constants.PublishNotReadyAddresses: v},
+			},
+			Spec: &v1alpha3.DestinationRule{Host: "svc.ns.svc.cluster.local"},
+		})
+	}
+	tests := []struct {
+		name      string
+		publish   bool
+		flag      string
+		dr        *model.ConsolidatedDestRule
+		health    model.HealthStatus
+		completed bool
+		want      corev3.HealthStatus
+	}{
+		{name: "not published", health: model.UnHealthy, flag: "healthy", want: corev3.HealthStatus_UNHEALTHY},
+		{name: "published healthy", publish: true, health: model.UnHealthy, flag: "healthy", want: corev3.HealthStatus_HEALTHY},
+		{name: "published unhealthy", publish: true, health: model.UnHealthy, flag: "unhealthy", want: corev3.HealthStatus_UNHEALTHY},
+		{
+			name: "destination rule override", publish: true, health: model.UnHealthy, flag: "healthy",
+			dr: drWith("unhealthy"), want: corev3.HealthStatus_UNHEALTHY,
+		},
+		{
+			name: "destination rule override healthy", publish: true, health: model.UnHealthy, flag: "unhealthy",
+			dr: drWith("healthy"), want: corev3.HealthStatus_HEALTHY,
+		},
+		{
+			name: "invalid destination rule override", publish: true, health: model.UnHealthy, flag: "healthy",
+			dr: drWith("maybe"), want: corev3.HealthStatus_HEALTHY,
+		},
+		{name: "draining", publish: true, health: model.Draining, flag: "healthy", want: corev3.HealthStatus_DRAINING},
+		{name: "completed pod", publish: true, health: model.UnHealthy, completed: true, flag: "healthy", want: corev3.HealthStatus_UNHEALTHY},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test.SetForTest(t, &features.PublishNotReadyAddressesHealth, tt.flag)
+			b := &EndpointBuilder{
+				service:         &model.Service{Attributes: model.ServiceAttributes{K8sAttributes: model.K8sAttributes{PublishNotReadyAddresses: tt.publish}}},
+				destinationRule: tt.dr,
+			}
+			shared := lbEndpoint("10.0.0.1", 8080, 1)
+			shared.HealthStatus = corev3.HealthStatus(tt.health)
+			eep := &lbEndpointCopy{lbEp: shared, shared: true}
+			b.applyPublishNotReady(&model.IstioEndpoint{HealthStatus: tt.health, Completed: tt.completed}, eep)
+			assert.Equal(t, eep.lbEp.HealthStatus, tt.want)
+			// The shared endpoint is only cloned if it is modified.
+			assert.Equal(t, shared.HealthStatus, corev3.HealthStatus(tt.health))
+			assert.Equal(t, eep.lbEp == shared, tt.want == corev3.HealthStatus(tt.health))
+		})
+	}
+}
+
+func TestCompletedPodEndpoints(t *testing.T) {
+	svc := endpointstest.NewService()
+	svc.Attributes.PublishNotReadyAddresses = true
+	notReady, completed := endpointstest.NewEndpoint("10.0.0.1"), endpointstest.NewEndpoint("10.0.0.2")
+	notReady.HealthStatus = model.UnHealthy
+	completed.HealthStatus, completed.Completed = model.UnHealthy, true
+	index := endpointstest.NewIndex(svc, notReady, completed)
+	b := New(endpointstest.OutboundCluster(svc), WithService(svc), WithClusterID(endpointstest.ClusterID))
+	got := map[string]corev3.HealthStatus{}
+	for _, llb := range b.BuildClusterLoadAssignment(index).Endpoints {
+		for _, lbEp := range llb.LbEndpoints {
+			got[lbEp.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()] = lbEp.GetHealthStatus()
+		}
+	}
+	// The not ready endpoint is published, the endpoint of the completed pod never receives traffic.
+	assert.Equal(t, got, map[string]corev3.HealthStatus{"10.0.0.1": corev3.HealthStatus_HEALTHY, "10.0.0.2": corev3.HealthStatus_UNHEALTHY})
+}
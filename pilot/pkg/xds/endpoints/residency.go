@@ -0,0 +1,76 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	lru "github.com/hashicorp/golang-lru/v2"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/monitoring"
+)
+
+var (
+	residencyTag = monitoring.CreateLabel("residency")
+	regionTag    = monitoring.CreateLabel("region")
+
+	residencyExcludedEndpoints = monitoring.NewSum(
+		"pilot_eds_endpoints_residency_excluded",
+		"Total number of endpoints left out of the ClusterLoadAssignments built by istiod by the data residency policy, "+
+			"by the residency of the endpoints and the region of the proxies. Each endpoint is counted once per region.",
+	)
+)
+
+// maxResidencyExclusions bounds the exclusions remembered to count each of them once.
+const maxResidencyExclusions = 10000
+
+// residencyExclusion is an endpoint of a service left out for the proxies of a region.
+type residencyExclusion struct {
+	residency string
+	region    string
+	hostname  string
+	address   string
+}
+
+// residencyExclusions are the exclusions already counted. Exclusions evicted from it are counted again when seen.
+var residencyExclusions, _ = lru.New[residencyExclusion, struct{}](maxResidencyExclusions)
+
+// residencyAllowed returns whether PILOT_DATA_RESIDENCY_POLICY allows the endpoint to be sent to the proxy of the
+// builder. Endpoints without a residency, or with a residency without policy, are sent to all proxies, while the
+// other endpoints are never sent to proxies without a known region.
+func (b *EndpointBuilder) residencyAllowed(ep *model.IstioEndpoint) bool {
+	if ep.DataResidency == "" {
+		return true
+	}
+	regions, f := features.DataResidencyPolicy[ep.DataResidency]
+	if !f {
+		return true
+	}
+	region := b.locality.GetRegion()
+	return region != "" && regions.Contains(region)
+}
+
+// auditResidencyExcluded records an endpoint left out by the data residency policy. As the endpoints are built again
+// for every push and every proxy, each endpoint is only counted and logged the first time it is left out for a region.
+func (b *EndpointBuilder) auditResidencyExcluded(ep *model.IstioEndpoint) {
+	region := b.locality.GetRegion()
+	key := residencyExclusion{residency: ep.DataResidency, region: region, hostname: string(b.hostname), address: ep.Address}
+	if seen, _ := residencyExclusions.ContainsOrAdd(key, struct{}{}); seen {
+		return
+	}
+	residencyExcludedEndpoints.With(residencyTag.Value(ep.DataResidency), regionTag.Value(region)).Increment()
+	log.Debugf("data residency %q of endpoint %s excludes it from cluster %s of proxy %s in region %q",
+		ep.DataResidency, ep.Address, b.clusterName, b.proxy.GetID(), region)
+}
@@ -0,0 +1,87 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/monitoring/monitortest"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/util/sets"
+)
+
+func TestDataResidency(t *testing.T) {
+	test.SetForTest(t, &features.DataResidencyPolicy, map[string]sets.String{
+		"eu": sets.New("eu-west-1", "eu-central-1"),
+	})
+	svc := endpointstest.NewService()
+	var eps []*model.IstioEndpoint
+	for address, residency := range map[string]string{
+		"10.0.0.1": "",
+		"10.0.0.2": "eu",
+		"10.0.0.3": "us",
+	} {
+		ep := endpointstest.NewEndpoint(address)
+		ep.DataResidency = residency
+		eps = append(eps, ep)
+	}
+	index := endpointstest.NewIndex(svc, eps...)
+
+	cases := []struct {
+		name   string
+		region string
+		want   []string
+	}{
+		{name: "allowed region", region: "eu-west-1", want: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}},
+		{name: "other region", region: "us-east-1", want: []string{"10.0.0.1", "10.0.0.3"}},
+		{name: "unknown region", want: []string{"10.0.0.1", "10.0.0.3"}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New(endpointstest.OutboundCluster(svc),
+				WithService(svc), WithClusterID(endpointstest.ClusterID), WithLocality(&corev3.Locality{Region: tt.region}))
+			got := sets.New(endpointstest.Addresses(b.BuildClusterLoadAssignment(index))...)
+			assert.Equal(t, sets.SortedList(got), tt.want)
+		})
+	}
+}
+
+func TestDataResidencyAudit(t *testing.T) {
+	test.SetForTest(t, &features.DataResidencyPolicy, map[string]sets.String{"eu": sets.New("eu-west-1")})
+	residencyExclusions.Purge()
+	mt := monitortest.New(t)
+	svc := endpointstest.NewService()
+	ep := endpointstest.NewEndpoint("10.0.0.1")
+	ep.DataResidency = "eu"
+	index := endpointstest.NewIndex(svc, ep)
+	build := func(region string) {
+		b := New(endpointstest.OutboundCluster(svc),
+			WithService(svc), WithClusterID(endpointstest.ClusterID), WithLocality(&corev3.Locality{Region: region}))
+		b.BuildClusterLoadAssignment(index)
+	}
+
+	// The exclusion is counted once, however many times the endpoints are built.
+	build("us-east-1")
+	build("us-east-1")
+	mt.Assert(residencyExcludedEndpoints.Name(), map[string]string{"residency": "eu", "region": "us-east-1"}, monitortest.Exactly(1))
+	build("us-west-1")
+	mt.Assert(residencyExcludedEndpoints.Name(), map[string]string{"residency": "eu", "region": "us-west-1"}, monitortest.Exactly(1))
+}
@@ -0,0 +1,103 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+// rolloutResolution is the number of weight units per unit of the original weight of a locality, so that the
+// rollout percentages are applied precisely while keeping the relative weights of the localities.
+const rolloutResolution = 1000
+
+// WithRolloutWeights sets the rollout weights of the workloads.
+func (b *EndpointBuilder) WithRolloutWeights(rollouts *model.RolloutWeights) *EndpointBuilder {
+	b.rollouts = rollouts
+	return b
+}
+
+// applyRolloutWeights weights the endpoints of the workloads being rolled out so that, in each locality, they
+// receive their percentage of the traffic. The remaining traffic is shared by the other endpoints. Within each
+// workload, the endpoints keep their relative weights.
+func (b *EndpointBuilder) applyRolloutWeights(locEps []*LocalityEndpoints) {
+	if b.rollouts.Empty() {
+		return
+	}
+	for _, locLbEps := range locEps {
+		applyLocalityRolloutWeights(locLbEps, b.rollouts)
+	}
+}
+
+func applyLocalityRolloutWeights(locLbEps *LocalityEndpoints, rollouts *model.RolloutWeights) {
+	type group struct {
+		share  float64
+		weight float64
+	}
+	// The group of the endpoints not being rolled out has the key "".
+	groups := map[string]*group{}
+	keys := make([]string, len(locLbEps.istioEndpoints))
+	rolledOut := false
+	var total float64
+	for i, ep := range locLbEps.istioEndpoints {
+		key := ""
+		percentage, ok := rollouts.Weight(ep)
+		if ok {
+			key = string(ep.Locality.ClusterID) + "/" + ep.Namespace + "/" + ep.WorkloadName
+			rolledOut = true
+		}
+		keys[i] = key
+		g := groups[key]
+		if g == nil {
+			g = &group{share: float64(percentage) / 100}
+			groups[key] = g
+		}
+		w := float64(locLbEps.llbEndpoints.LbEndpoints[i].GetLoadBalancingWeight().GetValue())
+		g.weight += w
+		total += w
+	}
+	if !rolledOut {
+		return
+	}
+	var rolloutShares float64
+	for key, g := range groups {
+		if key != "" {
+			rolloutShares += g.share
+		}
+	}
+	if g := groups[""]; g != nil {
+		g.share = math.Max(0, 1-rolloutShares)
+	}
+	var shares float64
+	for _, g := range groups {
+		shares += g.share
+	}
+	if shares == 0 {
+		// All the endpoints belong to workloads drained by their rollout, keep their weights.
+		return
+	}
+	for i, eep := range locLbEps.llbEndpoints.LbEndpoints {
+		g := groups[keys[i]]
+		weight := uint32(1)
+		if g.weight > 0 {
+			weight = util.ScaleWeight(float64(eep.GetLoadBalancingWeight().GetValue()), total*rolloutResolution*g.share/shares/g.weight)
+		}
+		locLbEps.mutable(i).LoadBalancingWeight = &wrapperspb.UInt32Value{Value: weight}
+	}
+}
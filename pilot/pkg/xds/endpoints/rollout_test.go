@@ -0,0 +1,66 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestApplyRolloutWeights(t *testing.T) {
+	workload := func(name string) *model.IstioEndpoint {
+		return &model.IstioEndpoint{
+			Namespace:    "default",
+			WorkloadName: name,
+			Locality:     model.Locality{ClusterID: "cluster-1"},
+		}
+	}
+	weights := func(rollouts *model.RolloutWeights, eps ...*model.IstioEndpoint) []uint32 {
+		locLbEps := &LocalityEndpoints{}
+		for _, ep := range eps {
+			locLbEps.append(ep, lbEndpoint("10.0.0.1", 8080, 1))
+		}
+		b := &EndpointBuilder{}
+		b.WithRolloutWeights(rollouts).applyRolloutWeights([]*LocalityEndpoints{locLbEps})
+		var out []uint32
+		for _, eep := range locLbEps.llbEndpoints.LbEndpoints {
+			out = append(out, eep.GetLoadBalancingWeight().GetValue())
+		}
+		return out
+	}
+	stable, canary := workload("reviews-v1"), workload("reviews-v2")
+
+	rollouts := model.NewRolloutWeights()
+	assert.Equal(t, weights(rollouts, stable, stable, canary), []uint32{1, 1, 1})
+
+	// The canary endpoint gets 10% of the traffic of the locality, the stable ones share the rest.
+	assert.Equal(t, rollouts.Set("cluster-1", "default", "reviews-v2", 10, true), true)
+	assert.Equal(t, weights(rollouts, stable, stable, canary), []uint32{1350, 1350, 300})
+	// Without stable endpoints, the canary gets all the traffic.
+	assert.Equal(t, weights(rollouts, canary, canary), []uint32{1000, 1000})
+
+	// A drained canary only keeps the minimal weight.
+	rollouts.Set("cluster-1", "default", "reviews-v2", 0, true)
+	assert.Equal(t, weights(rollouts, stable, canary), []uint32{2000, 1})
+	assert.Equal(t, weights(rollouts, canary), []uint32{1})
+
+	// The workloads of other clusters are not rolled out.
+	other := workload("reviews-v2")
+	other.Locality.ClusterID = "cluster-2"
+	rollouts.Set("cluster-1", "default", "reviews-v2", 50, true)
+	assert.Equal(t, weights(rollouts, stable, canary, other), []uint32{750, 1500, 750})
+}
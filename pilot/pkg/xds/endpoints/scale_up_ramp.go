@@ -0,0 +1,113 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+const (
+	// scaleUpRampWaves is the number of waves the endpoints of a scale-up are introduced in. Each wave starts after
+	// an equal share of the ramp window, and ramps its weights up over that share.
+	scaleUpRampWaves = 4
+	// scaleUpRampMinFactor is the weight factor of the endpoints of a wave as it starts.
+	scaleUpRampMinFactor = 0.1
+	// scaleUpRampResolution is the number of weight units per unit of the original weight of the endpoints while
+	// ramping, so that the weight factors are applied precisely.
+	scaleUpRampResolution = 100
+)
+
+// scaleUpRampClock returns the current time, overridden in tests.
+var scaleUpRampClock = time.Now
+
+// ScaleUpRampUntil returns when the scale-up ramp of the endpoints last built ends, or the zero time if they were
+// not ramping. The ClusterLoadAssignment must be rebuilt as the ramp progresses.
+func (b *EndpointBuilder) ScaleUpRampUntil() time.Time {
+	return b.rampUntil
+}
+
+// filterScaleUpRamp introduces the endpoints of a large scale-up in waves. When the endpoints first seen within the
+// ramp window of the service are at least as many as the other endpoints, they are split into waves by the time
+// they were first seen. The endpoints of the waves which have not started are left out, and the weight factors of
+// the endpoints of the started waves are returned.
+func (b *EndpointBuilder) filterScaleUpRamp(eps []*model.IstioEndpoint) ([]*model.IstioEndpoint, map[*model.IstioEndpoint]float64) {
+	b.rampUntil = time.Time{}
+	window := b.service.Attributes.ScaleUpRamp
+	if !features.EnableScaleUpRamp || window <= 0 {
+		return eps, nil
+	}
+	now := scaleUpRampClock()
+	var recent []*model.IstioEndpoint
+	for _, ep := range eps {
+		if !ep.FirstSeen.IsZero() && now.Sub(ep.FirstSeen) < window {
+			recent = append(recent, ep)
+		}
+	}
+	// Without established endpoints, such as after a restart of istiod, there is nothing to protect.
+	if established := len(eps) - len(recent); established == 0 || len(recent) < established {
+		return eps, nil
+	}
+
+	sort.Slice(recent, func(i, j int) bool {
+		if !recent[i].FirstSeen.Equal(recent[j].FirstSeen) {
+			return recent[i].FirstSeen.Before(recent[j].FirstSeen)
+		}
+		return recent[i].Address < recent[j].Address
+	})
+	start := recent[0].FirstSeen
+	b.rampUntil = start.Add(window)
+	waveLength := window / scaleUpRampWaves
+	factors := make(map[*model.IstioEndpoint]float64, len(recent))
+	for i, ep := range recent {
+		wave := i * scaleUpRampWaves / len(recent)
+		elapsed := now.Sub(start.Add(time.Duration(wave) * waveLength))
+		if elapsed < 0 {
+			continue
+		}
+		factors[ep] = math.Min(1, math.Max(scaleUpRampMinFactor, float64(elapsed)/float64(waveLength)))
+	}
+	out := make([]*model.IstioEndpoint, 0, len(eps))
+	for _, ep := range eps {
+		if _, f := factors[ep]; !f && !ep.FirstSeen.IsZero() && now.Sub(ep.FirstSeen) < window {
+			b.trace.filtered(ep, reasonScaleUpRamp)
+			endpointFiltered(reasonScaleUpRamp)
+			continue
+		}
+		out = append(out, ep)
+	}
+	return out, factors
+}
+
+// applyScaleUpRamp scales the weight of the endpoint by its ramp factor. While ramping, the weights of all
+// the endpoints are scaled by scaleUpRampResolution.
+func applyScaleUpRamp(factors map[*model.IstioEndpoint]float64, ep *model.IstioEndpoint, eep *lbEndpointCopy) {
+	if factors == nil {
+		return
+	}
+	factor, f := factors[ep]
+	if !f {
+		factor = 1
+	}
+	weight := util.ScaleWeight(float64(eep.lbEp.GetLoadBalancingWeight().GetValue()), scaleUpRampResolution*factor)
+	eep.mutable().LoadBalancingWeight = &wrapperspb.UInt32Value{Value: weight}
+}
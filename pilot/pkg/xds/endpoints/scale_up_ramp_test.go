@@ -0,0 +1,82 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestScaleUpRamp(t *testing.T) {
+	test.SetForTest(t, &features.EnableScaleUpRamp, true)
+	start := time.Unix(10000, 0)
+	now := start
+	scaleUpRampClock = func() time.Time { return now }
+	t.Cleanup(func() { scaleUpRampClock = time.Now })
+
+	endpoints := func(n int, firstSeen time.Time, offset int) []*model.IstioEndpoint {
+		var out []*model.IstioEndpoint
+		for i := 0; i < n; i++ {
+			out = append(out, &model.IstioEndpoint{Address: fmt.Sprintf("10.0.0.%d", offset+i), FirstSeen: firstSeen})
+		}
+		return out
+	}
+	established := endpoints(2, start.Add(-time.Hour), 0)
+	scaleUp := append(append([]*model.IstioEndpoint{}, established...), endpoints(8, start, 10)...)
+	b := &EndpointBuilder{service: &model.Service{Attributes: model.ServiceAttributes{
+		K8sAttributes: model.K8sAttributes{ScaleUpRamp: 4 * time.Minute},
+	}}}
+	weights := func(eps []*model.IstioEndpoint) []uint32 {
+		eps, factors := b.filterScaleUpRamp(eps)
+		var out []uint32
+		for _, ep := range eps {
+			eep := &lbEndpointCopy{lbEp: lbEndpoint(ep.Address, 80, 1)}
+			applyScaleUpRamp(factors, ep, eep)
+			out = append(out, eep.lbEp.GetLoadBalancingWeight().GetValue())
+		}
+		return out
+	}
+
+	// The first wave starts with the scale-up.
+	assert.Equal(t, weights(scaleUp), []uint32{100, 100, 10, 10})
+	assert.Equal(t, b.ScaleUpRampUntil(), start.Add(4*time.Minute))
+	assert.Equal(t, b.Cacheable(), false)
+
+	// Each wave starts after a quarter of the window and ramps up over the next quarter.
+	now = start.Add(90 * time.Second)
+	assert.Equal(t, weights(scaleUp), []uint32{100, 100, 100, 100, 50, 50})
+
+	// Once the window is over, all the endpoints keep their weight.
+	now = start.Add(4 * time.Minute)
+	assert.Equal(t, weights(scaleUp), []uint32{1, 1, 1, 1, 1, 1, 1, 1, 1, 1})
+	assert.Equal(t, b.ScaleUpRampUntil(), time.Time{})
+	assert.Equal(t, b.Cacheable(), true)
+
+	// Scale-ups adding fewer endpoints than the established ones are not ramped, nor are endpoints without
+	// established endpoints.
+	now = start
+	assert.Equal(t, weights(append(append([]*model.IstioEndpoint{}, established...), endpoints(1, start, 10)...)), []uint32{1, 1, 1})
+	assert.Equal(t, weights(endpoints(8, start, 10)), []uint32{1, 1, 1, 1, 1, 1, 1, 1})
+
+	// Services without the annotation are not ramped.
+	b.service.Attributes.ScaleUpRamp = 0
+	assert.Equal(t, len(weights(scaleUp)), 10)
+}
@@ -0,0 +1,108 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"sort"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// SelectedLocality is the endpoints of a locality selected for a cluster, without their Envoy representation.
+type SelectedLocality struct {
+	// Locality is the locality label of the endpoints, such as "region/zone/subzone".
+	Locality string
+	// Endpoints are the endpoints of the locality, in the order of the shards.
+	Endpoints []*model.IstioEndpoint
+}
+
+// SelectEndpoints returns the endpoints of the cluster sent to the proxy, grouped by locality in sorted order. They
+// go through the same filters as the ClusterLoadAssignments of EDS, so that other consumers of the endpoints, such
+// as the workload xDS of ambient, stay consistent with EDS. The Envoy specific steps, such as the network gateways,
+// the weights and the priorities, are left to the consumers. Endpoints serving several ports are replaced by their
+// endpoint of the port of the cluster.
+func (b *EndpointBuilder) SelectEndpoints(source EndpointSource) []SelectedLocality {
+	if !b.ServiceFound() {
+		return nil
+	}
+	svcPorts := b.servicePorts()
+	if len(svcPorts) == 0 {
+		return nil
+	}
+	eps, _ := b.selectEndpoints(b.snapshotShards(source), svcPorts)
+	eps, _ = b.filterScaleUpRamp(eps)
+	return groupByLocality(eps)
+}
+
+// selectEndpoints returns the endpoints selected for the service ports of the cluster. For clusters covering a port
+// range, it also returns the service port of each endpoint, to be recorded in its metadata.
+func (b *EndpointBuilder) selectEndpoints(
+	eps []*model.IstioEndpoint,
+	svcPorts []*model.Port,
+) ([]*model.IstioEndpoint, map[*model.IstioEndpoint]int) {
+	var endpointPorts map[*model.IstioEndpoint]int
+	if b.portRangeEnd > 0 {
+		endpointPorts = make(map[*model.IstioEndpoint]int, len(eps))
+	}
+	selected := make([]*model.IstioEndpoint, 0, len(eps))
+	for _, ep := range eps {
+		reason := ""
+		for _, svcPort := range svcPorts {
+			r := b.filterReason(ep, svcPort)
+			if r == "" {
+				// Endpoints serving several ports are replaced by their endpoint of the port of the cluster.
+				ep = ep.ForPort(svcPort.Name)
+				if endpointPorts != nil {
+					endpointPorts[ep] = svcPort.Port
+				}
+				reason = ""
+				break
+			}
+			if reason == "" {
+				reason = r
+			}
+		}
+		if reason != "" {
+			b.trace.filtered(ep, reason)
+			endpointFiltered(reason)
+			if reason == reasonDataResidency {
+				b.auditResidencyExcluded(ep)
+			}
+			continue
+		}
+		selected = append(selected, ep)
+	}
+	eps = selected
+	eps = b.filterTerminating(eps)
+	eps = b.filterTopologyHints(eps)
+	eps = b.filterTrafficCut(eps)
+	return eps, endpointPorts
+}
+
+// groupByLocality groups the endpoints by locality, sorted by locality label.
+func groupByLocality(eps []*model.IstioEndpoint) []SelectedLocality {
+	byLocality := map[string][]*model.IstioEndpoint{}
+	for _, ep := range eps {
+		byLocality[ep.Locality.Label] = append(byLocality[ep.Locality.Label], ep)
+	}
+	out := make([]SelectedLocality, 0, len(byLocality))
+	for locality, eps := range byLocality {
+		out = append(out, SelectedLocality{Locality: locality, Endpoints: eps})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Locality < out[j].Locality
+	})
+	return out
+}
@@ -0,0 +1,57 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestSelectEndpoints(t *testing.T) {
+	svc := endpointstest.NewService()
+	ep := func(addr, portName, locality string) *model.IstioEndpoint {
+		ep := endpointstest.NewEndpoint(addr)
+		ep.ServicePortName, ep.Locality.Label = portName, locality
+		return ep
+	}
+	index := endpointstest.NewIndex(svc,
+		ep("10.0.0.1", "http", "r1/z2"),
+		ep("10.0.0.2", "http", "r1/z1"),
+		ep("10.0.0.3", "grpc", "r1/z1"),
+		ep("10.0.0.4", "http", "r1/z1"),
+	)
+	b := New(endpointstest.OutboundCluster(svc), WithService(svc), WithClusterID(endpointstest.ClusterID))
+
+	got := map[string][]string{}
+	var localities []string
+	for _, l := range b.SelectEndpoints(index) {
+		localities = append(localities, l.Locality)
+		for _, ep := range l.Endpoints {
+			got[l.Locality] = append(got[l.Locality], ep.Address)
+		}
+	}
+	// The endpoint of another port is filtered out, like in the ClusterLoadAssignment.
+	assert.Equal(t, localities, []string{"r1/z1", "r1/z2"})
+	assert.Equal(t, got, map[string][]string{"r1/z1": {"10.0.0.2", "10.0.0.4"}, "r1/z2": {"10.0.0.1"}})
+
+	cla := map[string]int{}
+	for _, llb := range b.BuildClusterLoadAssignment(index).Endpoints {
+		cla[llb.GetLocality().GetZone()] = len(llb.LbEndpoints)
+	}
+	assert.Equal(t, cla, map[string]int{"z1": 2, "z2": 1})
+}
@@ -0,0 +1,63 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"time"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// sessionDrainClock returns the current time, overridden in tests.
+var sessionDrainClock = time.Now
+
+// SessionDrainExpiry returns when the first draining endpoint kept for the persistent sessions of the endpoints last
+// built is removed, or the zero time if none is. The ClusterLoadAssignment must be rebuilt then.
+func (b *EndpointBuilder) SessionDrainExpiry() time.Time {
+	return b.drainExpiry
+}
+
+// sessionDrainExpired returns true if the draining endpoint was kept for persistent sessions for longer than
+// features.PersistentSessionDrainTTL.
+func sessionDrainExpired(ep *model.IstioEndpoint) bool {
+	if features.PersistentSessionDrainTTL <= 0 || ep.DrainingSince.IsZero() {
+		return false
+	}
+	return !sessionDrainClock().Before(ep.DrainingSince.Add(features.PersistentSessionDrainTTL))
+}
+
+// recordSessionDrain records the draining endpoints sent to a persistent session cluster, where they only receive the
+// sessions pinned to them, and when the first of them expires.
+func (b *EndpointBuilder) recordSessionDrain(eps []*model.IstioEndpoint) {
+	b.drainExpiry = time.Time{}
+	if !b.persistentSession() {
+		return
+	}
+	drained := 0
+	for _, ep := range eps {
+		if ep.HealthStatus != model.Draining {
+			continue
+		}
+		drained++
+		if features.PersistentSessionDrainTTL <= 0 || ep.DrainingSince.IsZero() {
+			continue
+		}
+		if expiry := ep.DrainingSince.Add(features.PersistentSessionDrainTTL); b.drainExpiry.IsZero() || expiry.Before(b.drainExpiry) {
+			b.drainExpiry = expiry
+		}
+	}
+	sessionDrainedEndpoints.With(serviceTag.Value(string(b.hostname)), subsetTag.Value(b.subsetName)).RecordInt(int64(drained))
+}
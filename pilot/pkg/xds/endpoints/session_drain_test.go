@@ -0,0 +1,68 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/monitoring/monitortest"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestSessionDrainTTL(t *testing.T) {
+	test.SetForTest(t, &features.PersistentSessionDrainTTL, time.Minute)
+	mt := monitortest.New(t)
+	svc := endpointstest.NewService()
+	// Header based sessions keep the draining endpoints as well as cookie based ones.
+	svc.Attributes.Labels = map[string]string{features.PersistentSessionHeaderLabel: "x-session"}
+	endpoint := func(address string, health model.HealthStatus) *model.IstioEndpoint {
+		ep := endpointstest.NewEndpoint(address)
+		ep.HealthStatus = health
+		return ep
+	}
+	start := time.Now()
+	index := endpointstest.NewIndex(svc, endpoint("10.0.0.1", model.Healthy), endpoint("10.0.0.2", model.Draining))
+
+	now := start
+	sessionDrainClock = func() time.Time { return now }
+	t.Cleanup(func() { sessionDrainClock = time.Now })
+	b := New(endpointstest.OutboundCluster(svc), WithService(svc), WithClusterID(endpointstest.ClusterID)).(*EndpointBuilder)
+	addresses := func() []string {
+		return endpointstest.Addresses(b.BuildClusterLoadAssignment(index))
+	}
+
+	assert.Equal(t, addresses(), []string{"10.0.0.1", "10.0.0.2"})
+	drained := map[string]string{"service": string(svc.Hostname), "subset": ""}
+	mt.Assert(sessionDrainedEndpoints.Name(), drained, monitortest.Exactly(1))
+	expiry := b.SessionDrainExpiry()
+	assert.Equal(t, !expiry.Before(start.Add(time.Minute)) && expiry.Before(time.Now().Add(time.Minute)), true)
+
+	// An update of the shard keeps the time the endpoint started draining.
+	index.UpdateServiceEndpoints(endpointstest.Shard, string(svc.Hostname), "ns", []*model.IstioEndpoint{
+		endpoint("10.0.0.1", model.Healthy), endpoint("10.0.0.2", model.Draining),
+	})
+	assert.Equal(t, addresses(), []string{"10.0.0.1", "10.0.0.2"})
+	assert.Equal(t, b.SessionDrainExpiry(), expiry)
+
+	now = expiry
+	assert.Equal(t, addresses(), []string{"10.0.0.1"})
+	assert.Equal(t, b.SessionDrainExpiry(), time.Time{})
+	mt.Assert(sessionDrainedEndpoints.Name(), drained, monitortest.Exactly(0))
+}
@@ -0,0 +1,220 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"hash/fnv"
+	"net"
+	"sort"
+	"strconv"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+// sizeLimits are the limits on the size of a ClusterLoadAssignment. A limit of 0 is not enforced.
+type sizeLimits struct {
+	softEndpoints int
+	hardEndpoints int
+	softBytes     int
+	hardBytes     int
+}
+
+func sizeLimitsFromFeatures() sizeLimits {
+	return sizeLimits{
+		softEndpoints: features.EDSSoftLimitEndpoints,
+		hardEndpoints: features.EDSHardLimitEndpoints,
+		softBytes:     features.EDSSoftLimitBytes,
+		hardBytes:     features.EDSHardLimitBytes,
+	}
+}
+
+func (l sizeLimits) enabled() bool {
+	return l.softEndpoints > 0 || l.hardEndpoints > 0 || l.softBytes > 0 || l.hardBytes > 0
+}
+
+// applySizeLimits enforces the limits on the ClusterLoadAssignment. Exceeding a soft limit is logged and recorded by
+// the pilot_eds_cla_size_limit_exceeded metric. Exceeding a hard limit truncates the endpoints to a subset within the
+// limits, keeping at least one endpoint. The endpoints of the highest priorities are kept first, healthy ones first
+// within a priority, then picked by the hash of their address and the cluster name, so the same endpoints are kept
+// across pushes and istiod replicas. The endpoints kept carry the number of endpoints before truncation in their
+// istio metadata. The ClusterLoadAssignment is not modified; a truncated copy is returned.
+func applySizeLimits(cla *endpoint.ClusterLoadAssignment, limits sizeLimits) *endpoint.ClusterLoadAssignment {
+	if !limits.enabled() {
+		return cla
+	}
+	count := 0
+	for _, llb := range cla.GetEndpoints() {
+		count += len(llb.LbEndpoints)
+	}
+	size := 0
+	if limits.softBytes > 0 || limits.hardBytes > 0 {
+		size = proto.Size(cla)
+	}
+	if limits.softEndpoints > 0 && count > limits.softEndpoints || limits.softBytes > 0 && size > limits.softBytes {
+		log.Warnf("ClusterLoadAssignment of cluster %s exceeds its soft size limit with %d endpoints and %d bytes",
+			cla.ClusterName, count, size)
+		claSizeLimitExceeded.With(limitTag.Value("soft")).Increment()
+	}
+
+	keep := count
+	if limits.hardEndpoints > 0 && keep > limits.hardEndpoints {
+		keep = limits.hardEndpoints
+	}
+	if limits.hardBytes > 0 && size > limits.hardBytes && count*limits.hardBytes/size < keep {
+		keep = count * limits.hardBytes / size
+	}
+	// An empty ClusterLoadAssignment would fail all the requests, rather than overloading the endpoints kept.
+	if keep < 1 {
+		keep = 1
+	}
+	if keep >= count {
+		return cla
+	}
+	claSizeLimitExceeded.With(limitTag.Value("hard")).Increment()
+
+	ranked := rankEndpoints(cla)
+	out := truncateClusterLoadAssignment(cla, ranked, keep, count)
+	// The marker added to the endpoints kept is accounted for by shrinking the subset until it fits.
+	for limits.hardBytes > 0 && keep > 1 && proto.Size(out) > limits.hardBytes {
+		keep = keep * 9 / 10
+		if keep < 1 {
+			keep = 1
+		}
+		out = truncateClusterLoadAssignment(cla, ranked, keep, count)
+	}
+	log.Warnf("ClusterLoadAssignment of cluster %s exceeds its hard size limit, truncated from %d to %d endpoints",
+		cla.ClusterName, count, keep)
+	filteredEndpointsSizeLimit.RecordInt(int64(count - keep))
+	return out
+}
+
+type endpointPosition struct {
+	locality, endpoint int
+}
+
+// rankEndpoints returns the positions of the endpoints of the ClusterLoadAssignment, ordered by the priority of their
+// locality, then healthy endpoints first, then by the hash of their address and the cluster name.
+func rankEndpoints(cla *endpoint.ClusterLoadAssignment) []endpointPosition {
+	type ranked struct {
+		endpointPosition
+		priority  uint32
+		unhealthy bool
+		hash      uint64
+		key       string
+	}
+	var all []ranked
+	for i, llb := range cla.Endpoints {
+		for j, lbEp := range llb.LbEndpoints {
+			key := lbEndpointKey(lbEp)
+			h := fnv.New64a()
+			h.Write([]byte(cla.ClusterName))
+			h.Write([]byte{0})
+			h.Write([]byte(key))
+			all = append(all, ranked{
+				endpointPosition: endpointPosition{i, j},
+				priority:         llb.Priority,
+				unhealthy:        !isHealthyForTruncation(lbEp.HealthStatus),
+				hash:             h.Sum64(),
+				key:              key,
+			})
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].priority != all[j].priority {
+			return all[i].priority < all[j].priority
+		}
+		if all[i].unhealthy != all[j].unhealthy {
+			return !all[i].unhealthy
+		}
+		if all[i].hash != all[j].hash {
+			return all[i].hash < all[j].hash
+		}
+		return all[i].key < all[j].key
+	})
+	out := make([]endpointPosition, 0, len(all))
+	for _, r := range all {
+		out = append(out, r.endpointPosition)
+	}
+	return out
+}
+
+// isHealthyForTruncation reports whether Envoy sends requests to an endpoint with the health status.
+func isHealthyForTruncation(status corev3.HealthStatus) bool {
+	return status == corev3.HealthStatus_HEALTHY || status == corev3.HealthStatus_UNKNOWN
+}
+
+func lbEndpointKey(lbEp *endpoint.LbEndpoint) string {
+	address := lbEp.GetEndpoint().GetAddress()
+	if sa := address.GetSocketAddress(); sa != nil {
+		return net.JoinHostPort(sa.GetAddress(), strconv.Itoa(int(sa.GetPortValue())))
+	}
+	if ia := address.GetEnvoyInternalAddress(); ia != nil {
+		return ia.GetServerListenerName() + "/" + ia.GetEndpointId()
+	}
+	return address.GetPipe().GetPath()
+}
+
+// truncateClusterLoadAssignment returns a copy of the ClusterLoadAssignment with the first keep ranked endpoints,
+// in their original order. Localities left without endpoints are dropped, and the weights of the others are scaled
+// by the share of their endpoint weights kept, so their endpoints are not loaded more than before.
+func truncateClusterLoadAssignment(cla *endpoint.ClusterLoadAssignment, ranked []endpointPosition, keep, count int,
+) *endpoint.ClusterLoadAssignment {
+	kept := make(map[endpointPosition]struct{}, keep)
+	for _, p := range ranked[:keep] {
+		kept[p] = struct{}{}
+	}
+	out := &endpoint.ClusterLoadAssignment{ClusterName: cla.ClusterName, Policy: cla.Policy}
+	for i, llb := range cla.Endpoints {
+		var lbEps []*endpoint.LbEndpoint
+		var total, keptWeight uint64
+		for j, lbEp := range llb.LbEndpoints {
+			weight := uint64(lbEp.GetLoadBalancingWeight().GetValue())
+			if weight == 0 {
+				weight = 1
+			}
+			total += weight
+			if _, ok := kept[endpointPosition{i, j}]; !ok {
+				continue
+			}
+			keptWeight += weight
+			lbEps = append(lbEps, withTruncatedFrom(lbEp, count))
+		}
+		if len(lbEps) == 0 {
+			continue
+		}
+		clone := util.CloneLocalityLbEndpoint(llb)
+		clone.LbEndpoints = lbEps
+		if w := llb.GetLoadBalancingWeight().GetValue(); w > 0 && keptWeight < total {
+			// Rounded up, so that a locality keeps a weight.
+			clone.LoadBalancingWeight = &wrapperspb.UInt32Value{Value: uint32((uint64(w)*keptWeight + total - 1) / total)}
+		}
+		out.Endpoints = append(out.Endpoints, clone)
+	}
+	return out
+}
+
+func withTruncatedFrom(lbEp *endpoint.LbEndpoint, count int) *endpoint.LbEndpoint {
+	// The ClusterLoadAssignment is not modified.
+	lbEp = proto.Clone(lbEp).(*endpoint.LbEndpoint)
+	istioMetadata(lbEp).Fields[util.IstioTruncatedFromMetadataKey] = structpb.NewNumberValue(float64(count))
+	return lbEp
+}
@@ -0,0 +1,145 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"fmt"
+	"testing"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/monitoring/monitortest"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestApplySizeLimits(t *testing.T) {
+	mt := monitortest.New(t)
+	cla := &endpoint.ClusterLoadAssignment{ClusterName: "outbound|80||example.ns.svc.cluster.local"}
+	for z, zone := range []string{"zone-a", "zone-b"} {
+		llb := &endpoint.LocalityLbEndpoints{Locality: &corev3.Locality{Region: "region", Zone: zone}}
+		for i := 0; i < 10; i++ {
+			llb.LbEndpoints = append(llb.LbEndpoints, &endpoint.LbEndpoint{
+				HostIdentifier: &endpoint.LbEndpoint_Endpoint{Endpoint: &endpoint.Endpoint{
+					Address: util.BuildAddress(fmt.Sprintf("10.0.%d.%d", z, i), 8080),
+				}},
+			})
+		}
+		cla.Endpoints = append(cla.Endpoints, llb)
+	}
+	original := proto.Clone(cla).(*endpoint.ClusterLoadAssignment)
+	addresses := func(cla *endpoint.ClusterLoadAssignment) []string {
+		var out []string
+		for _, llb := range cla.Endpoints {
+			for _, lbEp := range llb.LbEndpoints {
+				out = append(out, lbEndpointKey(lbEp))
+			}
+		}
+		return out
+	}
+
+	// Within the limits.
+	assert.Equal(t, applySizeLimits(cla, sizeLimits{}), cla)
+	assert.Equal(t, applySizeLimits(cla, sizeLimits{hardEndpoints: 20, hardBytes: proto.Size(cla)}), cla)
+
+	// The soft limits only log and record the metric.
+	assert.Equal(t, applySizeLimits(cla, sizeLimits{softEndpoints: 10, softBytes: 100}), cla)
+	mt.Assert(claSizeLimitExceeded.Name(), map[string]string{"limit": "soft"}, monitortest.Exactly(1))
+
+	truncated := applySizeLimits(cla, sizeLimits{hardEndpoints: 5})
+	mt.Assert(claSizeLimitExceeded.Name(), map[string]string{"limit": "hard"}, monitortest.Exactly(1))
+	mt.Assert(filteredEndpoints.Name(), map[string]string{"reason": "size_limit"}, monitortest.Exactly(15))
+	assert.Equal(t, len(addresses(truncated)), 5)
+	for _, llb := range truncated.Endpoints {
+		for _, lbEp := range llb.LbEndpoints {
+			fields := lbEp.GetMetadata().GetFilterMetadata()[util.IstioMetadataKey].GetFields()
+			assert.Equal(t, fields[util.IstioTruncatedFromMetadataKey].GetNumberValue(), float64(20))
+		}
+	}
+	// The ClusterLoadAssignment is not modified.
+	assert.Equal(t, cla, original)
+
+	// The same endpoints are kept whatever their order, and a larger limit keeps a superset of them.
+	reversed := proto.Clone(cla).(*endpoint.ClusterLoadAssignment)
+	slices.Reverse(reversed.Endpoints)
+	assert.Equal(t, slices.Sort(addresses(applySizeLimits(reversed, sizeLimits{hardEndpoints: 5}))), slices.Sort(addresses(truncated)))
+	larger := addresses(applySizeLimits(cla, sizeLimits{hardEndpoints: 10}))
+	for _, address := range addresses(truncated) {
+		assert.Equal(t, slices.Contains(larger, address), true)
+	}
+
+	// The endpoints are truncated to fit in the byte limit, including their marker.
+	limit := proto.Size(cla) / 3
+	truncated = applySizeLimits(cla, sizeLimits{hardBytes: limit})
+	assert.Equal(t, proto.Size(truncated) <= limit, true)
+	assert.Equal(t, len(addresses(truncated)) > 0, true)
+
+	// At least one endpoint is kept.
+	assert.Equal(t, len(addresses(applySizeLimits(cla, sizeLimits{hardBytes: 1}))), 1)
+}
+
+func TestApplySizeLimitsRanking(t *testing.T) {
+	lbEp := func(address string, health corev3.HealthStatus) *endpoint.LbEndpoint {
+		return &endpoint.LbEndpoint{
+			HostIdentifier: &endpoint.LbEndpoint_Endpoint{Endpoint: &endpoint.Endpoint{
+				Address: util.BuildAddress(address, 8080),
+			}},
+			HealthStatus:        health,
+			LoadBalancingWeight: &wrapperspb.UInt32Value{Value: 1},
+		}
+	}
+	cla := &endpoint.ClusterLoadAssignment{
+		ClusterName: "outbound|80||example.ns.svc.cluster.local",
+		Endpoints: []*endpoint.LocalityLbEndpoints{
+			{
+				Locality:            &corev3.Locality{Zone: "remote"},
+				Priority:            1,
+				LoadBalancingWeight: &wrapperspb.UInt32Value{Value: 2},
+				LbEndpoints:         []*endpoint.LbEndpoint{lbEp("10.0.1.1", corev3.HealthStatus_HEALTHY), lbEp("10.0.1.2", corev3.HealthStatus_HEALTHY)},
+			},
+			{
+				Locality:            &corev3.Locality{Zone: "local"},
+				LoadBalancingWeight: &wrapperspb.UInt32Value{Value: 4},
+				LbEndpoints: []*endpoint.LbEndpoint{
+					lbEp("10.0.0.1", corev3.HealthStatus_UNHEALTHY),
+					lbEp("10.0.0.2", corev3.HealthStatus_HEALTHY),
+					lbEp("10.0.0.3", corev3.HealthStatus_DRAINING),
+					lbEp("10.0.0.4", corev3.HealthStatus_HEALTHY),
+				},
+			},
+		},
+	}
+
+	// The healthy endpoints of the highest priority are kept first, and the weight of their locality is scaled.
+	truncated := applySizeLimits(cla, sizeLimits{hardEndpoints: 2})
+	assert.Equal(t, len(truncated.Endpoints), 1)
+	assert.Equal(t, truncated.Endpoints[0].Locality.Zone, "local")
+	assert.Equal(t, truncated.Endpoints[0].LoadBalancingWeight.GetValue(), uint32(2))
+	for _, lbEp := range truncated.Endpoints[0].LbEndpoints {
+		assert.Equal(t, lbEp.HealthStatus, corev3.HealthStatus_HEALTHY)
+	}
+
+	// Lower priorities are only kept once all the endpoints of the higher ones are.
+	truncated = applySizeLimits(cla, sizeLimits{hardEndpoints: 5})
+	assert.Equal(t, len(truncated.Endpoints), 2)
+	assert.Equal(t, len(truncated.Endpoints[0].LbEndpoints), 1)
+	assert.Equal(t, truncated.Endpoints[0].LoadBalancingWeight.GetValue(), uint32(1))
+	assert.Equal(t, len(truncated.Endpoints[1].LbEndpoints), 4)
+	assert.Equal(t, truncated.Endpoints[1].LoadBalancingWeight.GetValue(), uint32(4))
+}
@@ -0,0 +1,55 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+// socketOptionsStruct returns the socket options as metadata, with durations in seconds, or nil if none is set.
+func socketOptionsStruct(o *model.SocketOptions) *structpb.Struct {
+	if o == nil {
+		return nil
+	}
+	fields := map[string]*structpb.Value{}
+	if o.KeepaliveTime > 0 {
+		fields["keepalive_time"] = structpb.NewNumberValue(o.KeepaliveTime.Seconds())
+	}
+	if o.KeepaliveInterval > 0 {
+		fields["keepalive_interval"] = structpb.NewNumberValue(o.KeepaliveInterval.Seconds())
+	}
+	if o.KeepaliveProbes > 0 {
+		fields["keepalive_probes"] = structpb.NewNumberValue(float64(o.KeepaliveProbes))
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return &structpb.Struct{Fields: fields}
+}
+
+// applySocketOptions adds the socket options of the WorkloadEntry or ServiceEntry of the endpoint to its istio
+// metadata, if any. The transport socket matching the endpoint reads them from there, and the internal upstream
+// transport socket passes them through to the internal listener of tunneled endpoints along the rest of the istio
+// metadata.
+func applySocketOptions(e *model.IstioEndpoint, eep *lbEndpointCopy) {
+	options := socketOptionsStruct(e.SocketOptions)
+	if options == nil {
+		return
+	}
+	istioMetadata(eep.mutable()).Fields[util.IstioSocketOptionsMetadataKey] = structpb.NewStructValue(options)
+}
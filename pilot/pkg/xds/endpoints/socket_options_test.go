@@ -0,0 +1,51 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestSocketOptions(t *testing.T) {
+	svc := endpointstest.NewService()
+	keepalive, other := endpointstest.NewEndpoint("10.0.0.1"), endpointstest.NewEndpoint("10.0.0.2")
+	keepalive.HealthStatus, other.HealthStatus = model.Healthy, model.Healthy
+	keepalive.SocketOptions = &model.SocketOptions{KeepaliveTime: 30 * time.Second, KeepaliveProbes: 3}
+	index := endpointstest.NewIndex(svc, keepalive, other)
+	b := New(endpointstest.OutboundCluster(svc), WithService(svc), WithClusterID(endpointstest.ClusterID))
+
+	got := map[string]*structpb.Struct{}
+	for _, llb := range b.BuildClusterLoadAssignment(index).Endpoints {
+		for _, lbEp := range llb.LbEndpoints {
+			istio := lbEp.GetMetadata().GetFilterMetadata()[util.IstioMetadataKey].GetFields()
+			got[lbEp.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()] = istio[util.IstioSocketOptionsMetadataKey].GetStructValue()
+		}
+	}
+	assert.Equal(t, got, map[string]*structpb.Struct{
+		"10.0.0.1": {Fields: map[string]*structpb.Value{
+			"keepalive_time":   structpb.NewNumberValue(30),
+			"keepalive_probes": structpb.NewNumberValue(3),
+		}},
+		"10.0.0.2": nil,
+	})
+}
@@ -0,0 +1,53 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/config/constants"
+)
+
+func TestStandbyEndpoints(t *testing.T) {
+	svc := endpointstest.NewService()
+	standby := endpointstest.NewEndpoint("10.0.0.2")
+	standby.Labels = map[string]string{constants.StandbyLabel: "true"}
+	index := endpointstest.NewIndex(svc, endpointstest.NewEndpoint("10.0.0.1"), standby)
+
+	b := New(endpointstest.OutboundCluster(svc),
+		WithService(svc),
+		WithClusterID(endpointstest.ClusterID),
+	)
+	cla := b.BuildClusterLoadAssignment(index)
+	if len(cla.Endpoints) != 2 {
+		t.Fatalf("expected 2 localities, got %d", len(cla.Endpoints))
+	}
+	for i, want := range []struct {
+		address  string
+		priority uint32
+	}{{"10.0.0.1", 0}, {"10.0.0.2", 1}} {
+		llb := cla.Endpoints[i]
+		if len(llb.LbEndpoints) != 1 {
+			t.Fatalf("expected 1 endpoint at priority %d, got %d", llb.Priority, len(llb.LbEndpoints))
+		}
+		if got := llb.LbEndpoints[0].GetEndpoint().GetAddress().GetSocketAddress().GetAddress(); got != want.address {
+			t.Fatalf("expected %s at index %d, got %s", want.address, i, got)
+		}
+		if llb.Priority != want.priority {
+			t.Fatalf("expected priority %d for %s, got %d", want.priority, want.address, llb.Priority)
+		}
+	}
+}
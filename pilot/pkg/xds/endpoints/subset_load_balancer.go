@@ -0,0 +1,48 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/config/labels"
+)
+
+// applySubsetMetadata adds the DestinationRule subsets the endpoint belongs to to its load balancer metadata, if the
+// subsets are selected by the subset load balancer of the default cluster.
+func (b *EndpointBuilder) applySubsetMetadata(e *model.IstioEndpoint, eep *lbEndpointCopy) {
+	if b.subsetName != "" || b.service == nil {
+		return
+	}
+	port, _ := b.service.Ports.GetByPort(b.port)
+	if !model.SubsetLoadBalancer(b.destinationRule.GetRule(), b.service, port) {
+		return
+	}
+	var subsets []string
+	for _, subset := range b.DestinationRule().GetSubsets() {
+		if labels.Instance(subset.GetLabels()).SubsetOf(e.Labels) {
+			subsets = append(subsets, subset.GetName())
+		}
+	}
+	if len(subsets) == 0 {
+		return
+	}
+	lb := lbMetadata(eep.mutable())
+	for _, subset := range subsets {
+		lb.Fields[util.LbSubsetMetadataPrefix+subset] = structpb.NewBoolValue(true)
+	}
+}
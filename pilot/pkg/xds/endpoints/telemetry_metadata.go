@@ -0,0 +1,42 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+// applyTelemetryMetadata adds the values of the labels requested by the telemetry providers of the proxy, with the
+// telemetry.istio.io/endpoint-metadata annotation of its Telemetries, to the istio.telemetry metadata of the endpoint. The labels the endpoint does not have are left out.
+func (b *EndpointBuilder) applyTelemetryMetadata(e *model.IstioEndpoint, eep *lbEndpointCopy) {
+	var fields map[string]*structpb.Value
+	for _, key := range b.telemetryMetadataKeys {
+		v, f := e.Labels[key]
+		if !f {
+			continue
+		}
+		if fields == nil {
+			fields = map[string]*structpb.Value{}
+		}
+		fields[key] = structpb.NewStringValue(v)
+	}
+	if len(fields) == 0 {
+		return
+	}
+	filterMetadata(eep.mutable(), util.IstioTelemetryMetadataKey).Fields = fields
+}
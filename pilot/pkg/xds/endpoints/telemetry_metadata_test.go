@@ -0,0 +1,74 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	tpb "istio.io/api/telemetry/v1alpha1"
+	"istio.io/api/type/v1beta1"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestTelemetryMetadata(t *testing.T) {
+	svc := endpointstest.NewService()
+	ep := endpointstest.NewEndpoint("10.0.0.1")
+	ep.Labels = map[string]string{"app": "example", "team": "payments"}
+	index := endpointstest.NewIndex(svc, ep)
+
+	push := model.NewPushContext()
+	push.Mesh = mesh.DefaultMeshConfig()
+	push.AuthnPolicies = &model.AuthenticationPolicies{}
+	push.Telemetry = &model.Telemetries{
+		RootNamespace: "istio-system",
+		NamespaceToTelemetries: map[string][]model.Telemetry{
+			"client": {{
+				Name: "wasm", Namespace: "client", EndpointMetadataKeys: "team,version",
+				Spec: &tpb.Telemetry{Selector: &v1beta1.WorkloadSelector{MatchLabels: map[string]string{"app": "wasm"}}},
+			}},
+		},
+	}
+	build := func(labels map[string]string) (*EndpointBuilder, map[string]string) {
+		proxy := &model.Proxy{
+			Type: model.SidecarProxy, ConfigNamespace: "client", Labels: labels,
+			Metadata: &model.NodeMetadata{ClusterID: endpointstest.ClusterID, Labels: labels},
+		}
+		proxy.SetTelemetryEndpointMetadataKeys(push)
+		b := New(endpointstest.OutboundCluster(svc),
+			WithProxy(proxy), WithPushContext(push), WithAmbient(noAmbient{}), WithService(svc)).(*EndpointBuilder)
+		lbEp := b.BuildClusterLoadAssignment(index).Endpoints[0].LbEndpoints[0]
+		fields := lbEp.GetMetadata().GetFilterMetadata()[util.IstioTelemetryMetadataKey].GetFields()
+		if fields == nil {
+			return b, nil
+		}
+		out := map[string]string{}
+		for k, v := range fields {
+			out[k] = v.GetStringValue()
+		}
+		return b, out
+	}
+
+	// The labels requested by the Telemetry selecting the proxy are added, the missing ones are left out.
+	selected, metadata := build(map[string]string{"app": "wasm"})
+	assert.Equal(t, metadata, map[string]string{"team": "payments"})
+	other, metadata := build(map[string]string{"app": "other"})
+	assert.Equal(t, metadata, nil)
+	// The proxies requesting different metadata do not share the ClusterLoadAssignments.
+	assert.Equal(t, selected.Key() != other.Key(), true)
+}
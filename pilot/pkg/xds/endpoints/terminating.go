@@ -0,0 +1,72 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/slices"
+)
+
+// isTerminating returns true for the endpoints of terminating pods which are still serving. They are ingested as
+// draining, unlike the endpoints drained with PILOT_DRAINING_LABEL and the previous addresses of migrated workloads.
+func isTerminating(ep *model.IstioEndpoint) bool {
+	return ep.HealthStatus == model.Draining && ep.MigratedTo == "" &&
+		(features.DrainingLabel == "" || ep.Labels[features.DrainingLabel] == "")
+}
+
+// includesTerminating returns true if the terminating endpoints of the service are sent as healthy endpoints.
+// Services with persistent sessions receive them as draining endpoints instead.
+func (b *EndpointBuilder) includesTerminating() bool {
+	return b.service.IncludeTerminating() != constants.IncludeTerminatingNever && !b.persistentSession()
+}
+
+func (b *EndpointBuilder) persistentSession() bool {
+	return b.service.PersistentSession()
+}
+
+// filterTerminating drops the terminating endpoints if the service only includes them when it has no ready
+// endpoint, and some of the endpoints are ready.
+func (b *EndpointBuilder) filterTerminating(eps []*model.IstioEndpoint) []*model.IstioEndpoint {
+	if !b.includesTerminating() || b.service.IncludeTerminating() != constants.IncludeTerminatingWhenNoReady {
+		return eps
+	}
+	ready := slices.FindFunc(eps, func(ep *model.IstioEndpoint) bool {
+		return ep.HealthStatus == model.Healthy
+	}) != nil
+	if !ready {
+		return eps
+	}
+	return slices.Filter(eps, func(ep *model.IstioEndpoint) bool {
+		if isTerminating(ep) {
+			b.trace.filtered(ep, reasonDraining)
+			endpointFiltered(reasonDraining)
+			return false
+		}
+		return true
+	})
+}
+
+// applyTerminating marks the endpoint healthy if it is terminating and the service includes its
+// terminating endpoints.
+func (b *EndpointBuilder) applyTerminating(e *model.IstioEndpoint, eep *lbEndpointCopy) {
+	if !isTerminating(e) || !b.includesTerminating() {
+		return
+	}
+	eep.mutable().HealthStatus = corev3.HealthStatus_HEALTHY
+}
@@ -0,0 +1,62 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestIncludeTerminating(t *testing.T) {
+	build := func(policy string, ready bool) map[string]corev3.HealthStatus {
+		svc := endpointstest.NewService()
+		svc.Attributes.IncludeTerminating = policy
+		terminating := endpointstest.NewEndpoint("10.0.0.1")
+		terminating.HealthStatus = model.Draining
+		eps := []*model.IstioEndpoint{terminating}
+		if ready {
+			ep := endpointstest.NewEndpoint("10.0.0.2")
+			ep.HealthStatus = model.Healthy
+			eps = append(eps, ep)
+		}
+		index := endpointstest.NewIndex(svc, eps...)
+		b := New(endpointstest.OutboundCluster(svc), WithService(svc), WithClusterID(endpointstest.ClusterID))
+		out := map[string]corev3.HealthStatus{}
+		for _, llb := range b.BuildClusterLoadAssignment(index).Endpoints {
+			for _, lbEp := range llb.LbEndpoints {
+				out[lbEp.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()] = lbEp.HealthStatus
+			}
+		}
+		return out
+	}
+
+	assert.Equal(t, build(constants.IncludeTerminatingNever, false), map[string]corev3.HealthStatus{})
+	assert.Equal(t, build(constants.IncludeTerminatingAlways, true), map[string]corev3.HealthStatus{
+		"10.0.0.1": corev3.HealthStatus_HEALTHY,
+		"10.0.0.2": corev3.HealthStatus_HEALTHY,
+	})
+	assert.Equal(t, build(constants.IncludeTerminatingWhenNoReady, true), map[string]corev3.HealthStatus{
+		"10.0.0.2": corev3.HealthStatus_HEALTHY,
+	})
+	assert.Equal(t, build(constants.IncludeTerminatingWhenNoReady, false), map[string]corev3.HealthStatus{
+		"10.0.0.1": corev3.HealthStatus_HEALTHY,
+	})
+}
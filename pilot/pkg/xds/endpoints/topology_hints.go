@@ -0,0 +1,67 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/slices"
+)
+
+const (
+	// The reasons the endpoints of a service with topology aware routing are not filtered by their zone hints.
+	hintsFallbackUnknownZone  = "unknown_zone"
+	hintsFallbackMissingHints = "missing_hints"
+	hintsFallbackNoZoneHints  = "no_zone_endpoints"
+)
+
+// filterTopologyHints keeps the endpoints hinted for the zone of the proxy, for the services with topology aware
+// routing. As kube-proxy does, all the endpoints are kept if the zone of the proxy is unknown, if a ready endpoint
+// has no hints, such as when the EndpointSlice controller could not allocate them for lack of endpoints in some
+// zones, or if no ready endpoint is hinted for the zone of the proxy.
+func (b *EndpointBuilder) filterTopologyHints(eps []*model.IstioEndpoint) []*model.IstioEndpoint {
+	if !b.service.TopologyAwareHints() || len(eps) == 0 {
+		return eps
+	}
+	zone := b.locality.GetZone()
+	if zone == "" {
+		topologyHintsFallback(hintsFallbackUnknownZone)
+		return eps
+	}
+	zoneEndpoint := false
+	for _, ep := range eps {
+		if ep.HealthStatus != model.Healthy {
+			continue
+		}
+		if len(ep.ZoneHints) == 0 {
+			topologyHintsFallback(hintsFallbackMissingHints)
+			return eps
+		}
+		if slices.Contains(ep.ZoneHints, zone) {
+			zoneEndpoint = true
+		}
+	}
+	if !zoneEndpoint {
+		topologyHintsFallback(hintsFallbackNoZoneHints)
+		return eps
+	}
+	return slices.Filter(eps, func(ep *model.IstioEndpoint) bool {
+		if slices.Contains(ep.ZoneHints, zone) {
+			return true
+		}
+		b.trace.filtered(ep, reasonTopologyHints)
+		endpointFiltered(reasonTopologyHints)
+		return false
+	})
+}
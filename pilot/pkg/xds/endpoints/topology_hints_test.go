@@ -0,0 +1,73 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/monitoring/monitortest"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestTopologyHints(t *testing.T) {
+	test.SetForTest(t, &features.EnableTopologyAwareHints, true)
+	mt := monitortest.New(t)
+	svc := endpointstest.NewService()
+	svc.Attributes.TopologyModeAuto = true
+	ep := func(addr string, health model.HealthStatus, zones ...string) *model.IstioEndpoint {
+		ep := endpointstest.NewEndpoint(addr)
+		ep.HealthStatus, ep.ZoneHints = health, zones
+		return ep
+	}
+	build := func(zone string, eps ...*model.IstioEndpoint) []string {
+		b := New(endpointstest.OutboundCluster(svc), WithService(svc), WithClusterID(endpointstest.ClusterID),
+			WithLocality(&corev3.Locality{Region: "region", Zone: zone}))
+		return slices.Sort(endpointstest.Addresses(b.BuildClusterLoadAssignment(endpointstest.NewIndex(svc, eps...))))
+	}
+	hinted := []*model.IstioEndpoint{
+		ep("10.0.0.1", model.Healthy, "zone-a"),
+		ep("10.0.0.2", model.Healthy, "zone-b"),
+		ep("10.0.0.3", model.Healthy, "zone-a", "zone-c"),
+	}
+
+	assert.Equal(t, build("zone-a", hinted...), []string{"10.0.0.1", "10.0.0.3"})
+	assert.Equal(t, build("zone-b", hinted...), []string{"10.0.0.2"})
+	mt.Assert(filteredEndpoints.Name(), map[string]string{"reason": "topology_hints"}, monitortest.Exactly(3))
+
+	// The hints could not be allocated for all the endpoints, so none are used.
+	assert.Equal(t, build("zone-a", append(hinted, ep("10.0.0.4", model.Healthy))...),
+		[]string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"})
+	mt.Assert(topologyHintsFallbacks.Name(), map[string]string{"reason": "missing_hints"}, monitortest.Exactly(1))
+	// Only the hints of the ready endpoints are required.
+	assert.Equal(t, build("zone-b", append(hinted, ep("10.0.0.4", model.UnHealthy))...), []string{"10.0.0.2"})
+
+	// No endpoint is hinted for the zone of the proxy.
+	assert.Equal(t, build("zone-d", hinted...), []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"})
+	mt.Assert(topologyHintsFallbacks.Name(), map[string]string{"reason": "no_zone_endpoints"}, monitortest.Exactly(1))
+
+	// The zone of the proxy is unknown.
+	assert.Equal(t, build("", hinted...), []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"})
+	mt.Assert(topologyHintsFallbacks.Name(), map[string]string{"reason": "unknown_zone"}, monitortest.Exactly(1))
+
+	test.SetForTest(t, &features.EnableTopologyAwareHints, false)
+	assert.Equal(t, build("zone-b", hinted...), []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"})
+}
@@ -0,0 +1,86 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// FilterTrace records why the EndpointBuilder dropped endpoints while building a ClusterLoadAssignment.
+// It is only used for debugging.
+type FilterTrace struct {
+	// Filtered lists the endpoints of the service that were not selected for the cluster.
+	Filtered []FilteredEndpoint `json:"filtered,omitempty"`
+	// Stages lists the number of endpoints before and after each filter applied to the selected endpoints.
+	Stages []FilterStage `json:"stages,omitempty"`
+}
+
+// FilteredEndpoint is an endpoint dropped by the EndpointBuilder.
+type FilteredEndpoint struct {
+	Address         string `json:"address"`
+	Port            uint32 `json:"port"`
+	ServicePortName string `json:"servicePortName,omitempty"`
+	Cluster         string `json:"cluster,omitempty"`
+	Reason          string `json:"reason"`
+}
+
+// FilterStage describes the effect of a filter applied to the endpoints of the cluster.
+type FilterStage struct {
+	Name   string `json:"name"`
+	Before int    `json:"before"`
+	After  int    `json:"after"`
+}
+
+func (t *FilterTrace) filtered(ep *model.IstioEndpoint, reason string) {
+	if t == nil {
+		return
+	}
+	t.Filtered = append(t.Filtered, FilteredEndpoint{
+		Address:         ep.Address,
+		Port:            ep.EndpointPort,
+		ServicePortName: ep.ServicePortName,
+		Cluster:         ep.Locality.ClusterID.String(),
+		Reason:          reason,
+	})
+}
+
+func (t *FilterTrace) stage(name string, before, after []*LocalityEndpoints) {
+	if t == nil {
+		return
+	}
+	t.Stages = append(t.Stages, FilterStage{Name: name, Before: countEndpoints(before), After: countEndpoints(after)})
+}
+
+func countEndpoints(locEps []*LocalityEndpoints) int {
+	n := 0
+	for _, l := range locEps {
+		n += len(l.llbEndpoints.LbEndpoints)
+	}
+	return n
+}
+
+// BuildClusterLoadAssignmentWithTrace is like BuildClusterLoadAssignment, but also returns the trace of the
+// endpoints dropped while building it. The result is never cached, so this is intended for debugging only.
+func (b *EndpointBuilder) BuildClusterLoadAssignmentWithTrace(source EndpointSource) (*endpoint.ClusterLoadAssignment, *FilterTrace) {
+	trace := &FilterTrace{}
+	b.trace = trace
+	defer func() {
+		b.trace = nil
+	}()
+	cla := b.BuildClusterLoadAssignment(source)
+	return cla, trace
+}
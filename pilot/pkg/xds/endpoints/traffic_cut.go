@@ -0,0 +1,44 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/slices"
+)
+
+// filterTrafficCut leaves out the endpoints cut by the networking.istio.io/traffic-cut annotation of the
+// DestinationRule. The endpoints of all the clusters are built together, so the cut applies to all of them in the
+// same push. The cut is applied even if it leaves the cluster without endpoints, such as the cluster of the subset
+// being cut, so that the cut traffic is never sent to the cut endpoints.
+func (b *EndpointBuilder) filterTrafficCut(eps []*model.IstioEndpoint) []*model.IstioEndpoint {
+	selector, ok := model.TrafficCut(b.destinationRule.GetRule())
+	if !ok || len(eps) == 0 {
+		return eps
+	}
+	kept := slices.Filter(eps, func(ep *model.IstioEndpoint) bool {
+		return !selector.SubsetOf(ep.Labels)
+	})
+	if len(kept) == 0 {
+		log.Debugf("traffic cut of cluster %s leaves out all its endpoints", b.clusterName)
+	}
+	for _, ep := range eps {
+		if selector.SubsetOf(ep.Labels) {
+			b.trace.filtered(ep, reasonTrafficCut)
+			endpointFiltered(reasonTrafficCut)
+		}
+	}
+	return kept
+}
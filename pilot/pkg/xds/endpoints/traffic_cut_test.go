@@ -0,0 +1,75 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"testing"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+	"istio.io/istio/pilot/pkg/xds/endpoints/endpointstest"
+	"istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/monitoring/monitortest"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestTrafficCut(t *testing.T) {
+	mt := monitortest.New(t)
+	svc := endpointstest.NewService()
+	ep := func(addr string, clusterID cluster.ID, version string) *model.IstioEndpoint {
+		ep := endpointstest.NewEndpoint(addr)
+		ep.Labels = map[string]string{"app": "example", "version": version}
+		ep.Locality.ClusterID = clusterID
+		return ep
+	}
+	build := func(cut string, eps ...*model.IstioEndpoint) []string {
+		index := model.NewEndpointIndex(model.DisabledCache{})
+		byCluster := map[cluster.ID][]*model.IstioEndpoint{}
+		for _, e := range eps {
+			byCluster[e.Locality.ClusterID] = append(byCluster[e.Locality.ClusterID], e)
+		}
+		for c, ceps := range byCluster {
+			index.UpdateServiceEndpoints(model.ShardKey{Cluster: c, Provider: provider.Kubernetes}, string(svc.Hostname), "ns", ceps)
+		}
+		dr := model.ConvertConsolidatedDestRule(&config.Config{
+			Meta: config.Meta{Name: "dr", Namespace: "ns", Annotations: map[string]string{constants.TrafficCutAnnotation: cut}},
+			Spec: &networking.DestinationRule{Host: string(svc.Hostname)},
+		})
+		b := New(endpointstest.OutboundCluster(svc),
+			WithService(svc), WithClusterID(endpointstest.ClusterID), WithDestinationRule(dr))
+		return slices.Sort(endpointstest.Addresses(b.BuildClusterLoadAssignment(index)))
+	}
+	eps := []*model.IstioEndpoint{
+		ep("10.0.0.1", "c1", "blue"),
+		ep("10.0.0.2", "c1", "green"),
+		ep("10.0.1.1", "c2", "blue"),
+		ep("10.0.1.2", "c2", "green"),
+	}
+
+	assert.Equal(t, build("", eps...), []string{"10.0.0.1", "10.0.0.2", "10.0.1.1", "10.0.1.2"})
+	// The blue endpoints of all the clusters are cut.
+	assert.Equal(t, build("version=blue", eps...), []string{"10.0.0.2", "10.0.1.2"})
+	mt.Assert(filteredEndpoints.Name(), map[string]string{"reason": "traffic_cut"}, monitortest.Exactly(2))
+	assert.Equal(t, build("app=example, version=green", eps...), []string{"10.0.0.1", "10.0.1.1"})
+	// The cut applies even if it leaves out all the endpoints, such as the ones of the subset cut.
+	assert.Equal(t, build("app=example", eps...), nil)
+	assert.Equal(t, build("version=blue", eps[0], eps[2]), nil)
+	// Invalid annotations are ignored.
+	assert.Equal(t, build("version", eps...), []string{"10.0.0.1", "10.0.0.2", "10.0.1.1", "10.0.1.2"})
+}
@@ -0,0 +1,65 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"net"
+	"strconv"
+
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"google.golang.org/protobuf/proto"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/config/constants"
+)
+
+// waypointTunnelEndpoints returns the additional endpoints to reach the workload through its other waypoints, and
+// directly if the service allows falling back to the workload when its waypoints are unavailable. The given
+// endpoint tunnels through the first waypoint; it is returned unchanged for endpoints not reached via waypoints.
+func (b *EndpointBuilder) waypointTunnelEndpoints(e *model.IstioEndpoint, eep *endpoint.LbEndpoint) ([]*endpoint.LbEndpoint, *endpoint.LbEndpoint) {
+	if b.dir != model.TrafficDirectionOutbound || eep.GetMetadata().GetFilterMetadata()[model.TunnelLabelShortName] == nil {
+		return nil, nil
+	}
+	if !b.viaDestinationWaypoint(e) {
+		return nil, nil
+	}
+	waypoints := findWaypoints(b.ambient, e)
+	if len(waypoints) == 0 {
+		return nil, nil
+	}
+	var alternates []*endpoint.LbEndpoint
+	for _, wp := range waypoints[1:] {
+		alternates = append(alternates, retunnel(e, eep, wp.String(), "@"+wp.String()))
+	}
+	var direct *endpoint.LbEndpoint
+	if b.service.Attributes.Labels[constants.WaypointFallbackLabel] == constants.WaypointFallbackDirect {
+		direct = retunnel(e, eep, e.Address, "@direct")
+	}
+	return alternates, direct
+}
+
+// retunnel returns a copy of the endpoint tunneling through the given address. The suffix keeps the internal
+// address of the copy distinct from the one of the original endpoint.
+func retunnel(e *model.IstioEndpoint, eep *endpoint.LbEndpoint, tunnelAddress, suffix string) *endpoint.LbEndpoint {
+	out := proto.Clone(eep).(*endpoint.LbEndpoint)
+	destination := net.JoinHostPort(e.Address, strconv.Itoa(int(e.EndpointPort)))
+	out.HostIdentifier = &endpoint.LbEndpoint_Endpoint{Endpoint: &endpoint.Endpoint{
+		Address: util.BuildInternalAddressWithIdentifier(connectOriginate, destination+suffix),
+	}}
+	out.Metadata.FilterMetadata[model.TunnelLabelShortName] = util.BuildTunnelMetadataStruct(
+		tunnelAddress, e.Address, int(e.EndpointPort), model.HBoneInboundListenPort)
+	return out
+}
@@ -0,0 +1,96 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoints
+
+import (
+	"net/netip"
+	"testing"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/network"
+)
+
+type fakeWaypoints []netip.Addr
+
+func (fakeWaypoints) SupportsTunnel(network.ID, string) bool { return true }
+
+func (f fakeWaypoints) WaypointsFor(network.ID, model.WaypointScope) []netip.Addr { return f }
+
+func (fakeWaypoints) WaypointScopeFor(string, string, labels.Instance) (model.WaypointScope, bool) {
+	return model.WaypointScope{}, false
+}
+
+func TestWaypointTunnelEndpoints(t *testing.T) {
+	e := &model.IstioEndpoint{
+		Address:        "10.0.0.1",
+		EndpointPort:   8080,
+		Namespace:      "ns",
+		ServiceAccount: "spiffe://cluster.local/ns/ns/sa/default",
+	}
+	tunneled := func() *endpoint.LbEndpoint {
+		return &endpoint.LbEndpoint{Metadata: &corev3.Metadata{FilterMetadata: map[string]*structpb.Struct{
+			model.TunnelLabelShortName: util.BuildTunnelMetadataStruct("10.1.0.1", "10.0.0.1", 8080, model.HBoneInboundListenPort),
+		}}}
+	}
+	tunnelAddress := func(eep *endpoint.LbEndpoint) string {
+		return eep.GetMetadata().GetFilterMetadata()[model.TunnelLabelShortName].GetFields()["address"].GetStringValue()
+	}
+	waypoints := fakeWaypoints{netip.MustParseAddr("10.1.0.1"), netip.MustParseAddr("10.1.0.2")}
+	b := &EndpointBuilder{
+		dir:     model.TrafficDirectionOutbound,
+		proxy:   &model.Proxy{Type: model.SidecarProxy, Metadata: &model.NodeMetadata{}},
+		service: &model.Service{},
+		ambient: waypoints,
+	}
+
+	alternates, direct := b.waypointTunnelEndpoints(e, tunneled())
+	if len(alternates) != 1 || tunnelAddress(alternates[0]) != "10.1.0.2:15008" {
+		t.Fatalf("expected an endpoint through the second waypoint, got %v", alternates)
+	}
+	if direct != nil {
+		t.Fatalf("expected no direct endpoint without the fallback label, got %v", direct)
+	}
+
+	b.service.Attributes.Labels = map[string]string{constants.WaypointFallbackLabel: constants.WaypointFallbackDirect}
+	_, direct = b.waypointTunnelEndpoints(e, tunneled())
+	if direct == nil || tunnelAddress(direct) != "10.0.0.1:15008" {
+		t.Fatalf("expected a direct endpoint, got %v", direct)
+	}
+	if id := direct.GetEndpoint().GetAddress().GetEnvoyInternalAddress().GetEndpointId(); id != "10.0.0.1:8080@direct" {
+		t.Fatalf("unexpected endpoint id %q", id)
+	}
+
+	// Endpoints not reached through a tunnel are left alone.
+	alternates, direct = b.waypointTunnelEndpoints(e, &endpoint.LbEndpoint{})
+	if alternates != nil || direct != nil {
+		t.Fatalf("expected no additional endpoints, got %v %v", alternates, direct)
+	}
+
+	// Direct endpoints are moved after the priorities of the endpoints reached through waypoints.
+	primary, groups := splitGroups([]*LocalityEndpoints{
+		{llbEndpoints: endpoint.LocalityLbEndpoints{}},
+		{llbEndpoints: endpoint.LocalityLbEndpoints{}, group: directFallbackGroup},
+	})
+	if len(primary) != 1 || len(groups[directFallbackGroup-1]) != 1 {
+		t.Fatalf("expected one primary and one fallback group, got %d and %d", len(primary), len(groups[directFallbackGroup-1]))
+	}
+}
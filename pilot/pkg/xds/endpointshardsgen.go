@@ -0,0 +1,108 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	anypb "google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/kind"
+)
+
+// TypeURLEndpointShards streams the endpoint shards of the services of the namespaces requested as resource names,
+// in the format of /debug/endpointShardz. Unlike the debug types, it is watched: the shards of a namespace are pushed
+// again each time the endpoints of one of its services change. It is used by istioctl x endpoint-watch.
+const TypeURLEndpointShards = "istio.io/endpointShards"
+
+// EndpointShardsGen generates the TypeURLEndpointShards resources. The identities of the system namespace may watch
+// any namespace, the other identities only their own namespace.
+type EndpointShardsGen struct {
+	Server          *DiscoveryServer
+	SystemNamespace string
+}
+
+var _ model.XdsResourceGenerator = &EndpointShardsGen{}
+
+func (g *EndpointShardsGen) Generate(proxy *model.Proxy, w *model.WatchedResource, req *model.PushRequest) (model.Resources, model.XdsLogDetails, error) {
+	if proxy.VerifiedIdentity == nil {
+		log.Warnf("proxy %s is not authorized to watch endpoint shards. Ensure you are connecting over TLS port and are authenticated.", proxy.ID)
+		return nil, model.DefaultXdsLogDetails, status.Error(codes.Unauthenticated, "authentication required")
+	}
+	if len(w.ResourceNames) == 0 {
+		return nil, model.DefaultXdsLogDetails, status.Error(codes.InvalidArgument, "a namespace is required")
+	}
+	identity := proxy.VerifiedIdentity
+	for _, ns := range w.ResourceNames {
+		if identity.Namespace != g.SystemNamespace && identity.Namespace != ns {
+			return nil, model.DefaultXdsLogDetails, status.Errorf(codes.PermissionDenied,
+				"the endpoint shards of namespace %q are not available for current identity: %q", ns, identity)
+		}
+	}
+	if !endpointShardsUpdated(req, w.ResourceNames) {
+		return nil, model.DefaultXdsLogDetails, nil
+	}
+	res := make(model.Resources, 0, len(w.ResourceNames))
+	for _, ns := range w.ResourceNames {
+		b, err := config.ToJSON(namespaceShardz(g.Server.Env.EndpointIndex, ns))
+		if err != nil {
+			return nil, model.DefaultXdsLogDetails, err
+		}
+		res = append(res, &discovery.Resource{
+			Name:     ns,
+			Resource: &anypb.Any{TypeUrl: TypeURLEndpointShards, Value: b},
+		})
+	}
+	return res, model.DefaultXdsLogDetails, nil
+}
+
+// endpointShardsUpdated returns true if the push may change the endpoint shards of the namespaces: on the initial
+// request and on the pushes updating a service of the namespaces.
+func endpointShardsUpdated(req *model.PushRequest, namespaces []string) bool {
+	if len(req.ConfigsUpdated) == 0 {
+		return true
+	}
+	for key := range model.ConfigsOfKind(req.ConfigsUpdated, kind.ServiceEntry) {
+		for _, ns := range namespaces {
+			if key.Namespace == ns {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// namespaceShardz returns the endpoint shards of the services of the namespace, by hostname and namespace, or of all
+// the services if the namespace is empty.
+func namespaceShardz(index *model.EndpointIndex, namespace string) map[string]map[string]*model.EndpointShards {
+	shardz := index.Shardz()
+	if namespace == "" {
+		return shardz
+	}
+	for svc, byNs := range shardz {
+		for ns := range byNs {
+			if ns != namespace {
+				delete(byNs, ns)
+			}
+		}
+		if len(byNs) == 0 {
+			delete(shardz, svc)
+		}
+	}
+	return shardz
+}
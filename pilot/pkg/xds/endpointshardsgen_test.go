@@ -0,0 +1,104 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/util/sets"
+)
+
+func TestEndpointShardsGen(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	shard := model.ShardKey{Cluster: "c1"}
+	s.Discovery.Env.EndpointIndex.UpdateServiceEndpoints(shard, "a.ns1.svc.cluster.local", "ns1",
+		[]*model.IstioEndpoint{{Address: "10.0.0.1", EndpointPort: 80, Namespace: "ns1"}})
+	s.Discovery.Env.EndpointIndex.UpdateServiceEndpoints(shard, "b.ns2.svc.cluster.local", "ns2",
+		[]*model.IstioEndpoint{{Address: "10.0.0.2", EndpointPort: 80, Namespace: "ns2"}})
+	gen := s.Discovery.Generators[TypeURLEndpointShards]
+
+	generate := func(identity *spiffe.Identity, ns string, req *model.PushRequest) (model.Resources, error) {
+		t.Helper()
+		proxy := &model.Proxy{ID: "istioctl", VerifiedIdentity: identity}
+		res, _, err := gen.Generate(proxy, &model.WatchedResource{TypeUrl: TypeURLEndpointShards, ResourceNames: []string{ns}}, req)
+		return res, err
+	}
+	hostnames := func(res model.Resources) []string {
+		t.Helper()
+		assert.Equal(t, len(res), 1)
+		shardz := map[string]any{}
+		if err := json.Unmarshal(res[0].Resource.Value, &shardz); err != nil {
+			t.Fatal(err)
+		}
+		out := sets.New[string]()
+		for hostname := range shardz {
+			out.Insert(hostname)
+		}
+		return sets.SortedList(out)
+	}
+
+	// Only the verified identities may watch the endpoint shards.
+	_, err := generate(nil, "ns1", &model.PushRequest{Full: true})
+	assert.Equal(t, status.Code(err), codes.Unauthenticated)
+
+	// The identities of the other namespaces may only watch their own namespace.
+	_, err = generate(&spiffe.Identity{Namespace: "ns2", ServiceAccount: "sa"}, "ns1", &model.PushRequest{Full: true})
+	assert.Equal(t, status.Code(err), codes.PermissionDenied)
+	res, err := generate(&spiffe.Identity{Namespace: "ns1", ServiceAccount: "sa"}, "ns1", &model.PushRequest{Full: true})
+	assert.NoError(t, err)
+	assert.Equal(t, hostnames(res), []string{"a.ns1.svc.cluster.local"})
+
+	// The identities of the system namespace may watch any namespace.
+	system := &spiffe.Identity{Namespace: "istio-system", ServiceAccount: "istiod"}
+	res, err = generate(system, "ns2", &model.PushRequest{Full: true})
+	assert.NoError(t, err)
+	assert.Equal(t, hostnames(res), []string{"b.ns2.svc.cluster.local"})
+
+	// The shards are only pushed again when a service of the namespace is updated.
+	updated := func(ns string) *model.PushRequest {
+		return &model.PushRequest{ConfigsUpdated: sets.New(model.ConfigKey{Kind: kind.ServiceEntry, Name: "a." + ns + ".svc.cluster.local", Namespace: ns})}
+	}
+	res, err = generate(system, "ns1", updated("ns2"))
+	assert.NoError(t, err)
+	assert.Equal(t, len(res), 0)
+	res, err = generate(system, "ns1", updated("ns1"))
+	assert.NoError(t, err)
+	assert.Equal(t, hostnames(res), []string{"a.ns1.svc.cluster.local"})
+}
+
+func TestEndpointShardsNeedsPush(t *testing.T) {
+	proxy := &model.Proxy{
+		Type:             model.SidecarProxy,
+		SidecarScope:     &model.SidecarScope{},
+		WatchedResources: map[string]*model.WatchedResource{},
+	}
+	req := &model.PushRequest{
+		Push:           model.NewPushContext(),
+		ConfigsUpdated: sets.New(model.ConfigKey{Kind: kind.ServiceEntry, Name: "a.ns1.svc.cluster.local", Namespace: "ns1"}),
+	}
+	assert.Equal(t, DefaultProxyNeedsPush(proxy, req), false)
+
+	// The services updated are pushed to the endpoint-watch clients, visible to them or not.
+	proxy.WatchedResources[TypeURLEndpointShards] = &model.WatchedResource{TypeUrl: TypeURLEndpointShards, ResourceNames: []string{"ns1"}}
+	assert.Equal(t, DefaultProxyNeedsPush(proxy, req), true)
+}
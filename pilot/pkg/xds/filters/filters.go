@@ -23,6 +23,7 @@ import (
 	fault "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/fault/v3"
 	grpcstats "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/grpc_stats/v3"
 	grpcweb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/grpc_web/v3"
+	headertometadata "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/header_to_metadata/v3"
 	router "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/router/v3"
 	statefulsession "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/stateful_session/v3"
 	httpwasm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
@@ -49,6 +50,9 @@ const (
 	TLSTransportProtocol       = "tls"
 	RawBufferTransportProtocol = "raw_buffer"
 
+	// EndpointCohortFilterName is the name of the HTTP filter selecting the endpoint cohort of a request.
+	EndpointCohortFilterName = "envoy.filters.http.header_to_metadata"
+
 	// Alpn HTTP filter name which will override the ALPN for upstream TLS connection.
 	AlpnFilterName = "istio.alpn"
 
@@ -353,6 +357,26 @@ func BuildRouterFilter(ctx RouterFilterContext) *hcm.HttpFilter {
 	return routers[ctx]
 }
 
+// BuildEndpointCohortFilter returns the filter copying the header to the load balancer metadata of the request,
+// so that the subset load balancer of the destination cluster sends the request to the endpoint cohort it names.
+func BuildEndpointCohortFilter(header string) *hcm.HttpFilter {
+	return &hcm.HttpFilter{
+		Name: EndpointCohortFilterName,
+		ConfigType: &hcm.HttpFilter_TypedConfig{
+			TypedConfig: protoconv.MessageToAny(&headertometadata.Config{
+				RequestRules: []*headertometadata.Config_Rule{{
+					Header: header,
+					OnHeaderPresent: &headertometadata.Config_KeyValuePair{
+						MetadataNamespace: util.EnvoyLbMetadataKey,
+						Key:               util.LbCohortMetadataKey,
+						Type:              headertometadata.Config_STRING,
+					},
+				}},
+			}),
+		},
+	}
+}
+
 var (
 	// These ALPNs are injected in the client side by the ALPN filter.
 	// "istio" is added for each upstream protocol in order to make it
@@ -0,0 +1,341 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	lrs "github.com/envoyproxy/go-control-plane/envoy/service/load_stats/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"istio.io/istio/pilot/pkg/features"
+	istiogrpc "istio.io/istio/pilot/pkg/grpc"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds/endpoints"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// loadFactorMaxStep bounds the change of the weight factor of an endpoint in a single adjustment, so that the
+// weights converge instead of oscillating between two reports.
+const loadFactorMaxStep = 2
+
+// loadReports collects the requests in progress reported by the proxies through the Load Reporting Service, and
+// derives the weight factors of the endpoints of each cluster from them.
+//
+// Reports are keyed by the reporting stream rather than by node ID, so that a stream cannot replace or drop the
+// reports of another one claiming the same node.
+type loadReports struct {
+	mu sync.RWMutex
+	// inProgress holds the latest requests in progress of each endpoint, by cluster and reporting stream.
+	inProgress map[string]map[string]map[string]uint64
+	factors    map[string]*endpoints.LoadFactors
+	// requested holds the clusters each stream reported requests to since it started reporting.
+	requested map[string]sets.String
+	// nodes holds the node of each reporting stream.
+	nodes map[string]string
+}
+
+func newLoadReports() *loadReports {
+	return &loadReports{
+		inProgress: map[string]map[string]map[string]uint64{},
+		factors:    map[string]*endpoints.LoadFactors{},
+		requested:  map[string]sets.String{},
+		nodes:      map[string]string{},
+	}
+}
+
+// record replaces the load previously reported by the stream of the node for the clusters of the report. Only the
+// clusters the node subscribes to are recorded, so a proxy cannot alter the weights of the clusters of other proxies.
+func (r *loadReports) record(conID, node string, stats []*endpoint.ClusterStats, subscribed sets.String) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nodes[conID] = node
+	if r.requested[conID] == nil {
+		r.requested[conID] = sets.New[string]()
+	}
+	for _, cs := range stats {
+		if dir, _, _, _ := model.ParseSubsetKey(cs.GetClusterName()); dir != model.TrafficDirectionOutbound {
+			continue
+		}
+		if !subscribed.Contains(cs.GetClusterName()) {
+			continue
+		}
+		byAddress := map[string]uint64{}
+		for _, ls := range cs.GetUpstreamLocalityStats() {
+			if ls.GetTotalIssuedRequests() > 0 {
+				r.requested[conID].Insert(cs.GetClusterName())
+			}
+			for _, es := range ls.GetUpstreamEndpointStats() {
+				sa := es.GetAddress().GetSocketAddress()
+				if sa == nil {
+					continue
+				}
+				byAddress[net.JoinHostPort(sa.GetAddress(), strconv.Itoa(int(sa.GetPortValue())))] += es.GetTotalRequestsInProgress()
+			}
+		}
+		if r.inProgress[cs.GetClusterName()] == nil {
+			r.inProgress[cs.GetClusterName()] = map[string]map[string]uint64{}
+		}
+		r.inProgress[cs.GetClusterName()][conID] = byAddress
+	}
+}
+
+// forget drops the load reported by a closed stream.
+func (r *loadReports) forget(conID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.requested, conID)
+	delete(r.nodes, conID)
+	for cluster, byCon := range r.inProgress {
+		delete(byCon, conID)
+		if len(byCon) == 0 {
+			delete(r.inProgress, cluster)
+		}
+	}
+}
+
+// requestedClusters returns the clusters the proxy sent requests to, and whether it reports its load.
+func (r *loadReports) requestedClusters(node string) (sets.String, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := sets.New[string]()
+	reporting := false
+	for conID, n := range r.nodes {
+		if n == node {
+			reporting = true
+			out.Merge(r.requested[conID])
+		}
+	}
+	return out, reporting
+}
+
+// loadFactors returns the weight factors of the endpoints of the cluster, or nil.
+func (r *loadReports) loadFactors(cluster string) *endpoints.LoadFactors {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.factors[cluster]
+}
+
+// adjust updates the weight factors of the clusters from the latest reports, and returns the clusters whose
+// factors changed.
+func (r *loadReports) adjust(maxFactor float64) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var changed []string
+	for cluster := range r.factors {
+		if _, f := r.inProgress[cluster]; !f {
+			// No proxy reports the load of the cluster anymore: restore the weights.
+			delete(r.factors, cluster)
+			changed = append(changed, cluster)
+		}
+	}
+	for cluster, byCon := range r.inProgress {
+		load := map[string]uint64{}
+		for _, byAddress := range byCon {
+			for addr, n := range byAddress {
+				load[addr] += n
+			}
+		}
+		previous := r.factors[cluster]
+		var prev map[string]float64
+		if previous != nil {
+			prev = previous.ByAddress
+		}
+		next := computeLoadFactors(prev, load, maxFactor)
+		if maps.Equal(prev, next) {
+			continue
+		}
+		version := uint64(1)
+		if previous != nil {
+			version = previous.Version + 1
+		}
+		r.factors[cluster] = &endpoints.LoadFactors{Version: version, ByAddress: next}
+		changed = append(changed, cluster)
+	}
+	return changed
+}
+
+// computeLoadFactors adjusts the weight factors of the endpoints of a cluster from their requests in progress:
+// the endpoints more loaded than the average get a lower weight, the others a higher one, until the load is even.
+// Each adjustment changes a factor by at most loadFactorMaxStep, and the factors are bounded by maxFactor.
+func computeLoadFactors(previous map[string]float64, load map[string]uint64, maxFactor float64) map[string]float64 {
+	if len(load) == 0 {
+		return nil
+	}
+	total := uint64(0)
+	for _, n := range load {
+		total += n
+	}
+	if total == 0 {
+		// Idle endpoints do not tell anything about their capacity: keep the current factors.
+		out := make(map[string]float64, len(load))
+		for addr := range load {
+			if f, ok := previous[addr]; ok {
+				out[addr] = f
+			}
+		}
+		return out
+	}
+	mean := float64(total) / float64(len(load))
+	out := make(map[string]float64, len(load))
+	for addr, n := range load {
+		// Smooth the ratio, as the load of an endpoint may be 0 while the others are busy.
+		step := bound((mean+1)/(float64(n)+1), loadFactorMaxStep)
+		f, ok := previous[addr]
+		if !ok {
+			f = 1
+		}
+		f = bound(f*step, maxFactor)
+		if f != 1 {
+			out[addr] = f
+		}
+	}
+	return out
+}
+
+// bound returns v within [1/limit, limit].
+func bound(v, limit float64) float64 {
+	if v > limit {
+		return limit
+	}
+	if v < 1/limit {
+		return 1 / limit
+	}
+	return v
+}
+
+// StreamLoadStats implements the Load Reporting Service, receiving the load of the endpoints from the proxies.
+// The proxies report the load of all their clusters, per endpoint. Reports are only accepted from a proxy connected
+// over ADS with the same node and identity, and only for the clusters it subscribes to.
+func (s *DiscoveryServer) StreamLoadStats(stream lrs.LoadReportingService_StreamLoadStatsServer) error {
+	identities, err := s.authenticate(stream.Context())
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	node := ""
+	conID := ""
+	var con *Connection
+	defer func() {
+		if conID != "" {
+			s.loadReports.forget(conID)
+		}
+	}()
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			if istiogrpc.IsExpectedGRPCError(err) {
+				return nil
+			}
+			return err
+		}
+		// The node is only set for the first request.
+		if node == "" {
+			node = req.GetNode().GetId()
+			if node == "" {
+				return status.Error(codes.InvalidArgument, "missing node information")
+			}
+			conID = connectionID(node)
+			if err := stream.Send(&lrs.LoadStatsResponse{
+				SendAllClusters:           true,
+				LoadReportingInterval:     durationpb.New(features.LoadReportInterval),
+				ReportEndpointGranularity: true,
+			}); err != nil {
+				return err
+			}
+		}
+		if con == nil || !s.isConnected(con) {
+			con = s.loadReporterConnection(node, identities)
+			if con == nil {
+				// The proxy is not connected over ADS (yet), its load cannot be attributed to its clusters.
+				continue
+			}
+		}
+		s.loadReports.record(conID, node, req.GetClusterStats(), sets.New(con.Clusters()...))
+	}
+}
+
+// loadReporterConnection returns the ADS connection of the node matching the identities of a load reporting
+// stream, or nil.
+func (s *DiscoveryServer) loadReporterConnection(node string, identities []string) *Connection {
+	for _, con := range s.Clients() {
+		if con.node.GetId() != node {
+			continue
+		}
+		if features.EnableXDSIdentityCheck && identities != nil {
+			if _, err := checkConnectionIdentity(con.proxy, identities); err != nil {
+				continue
+			}
+		}
+		return con
+	}
+	return nil
+}
+
+// isConnected reports whether the ADS connection is still open.
+func (s *DiscoveryServer) isConnected(con *Connection) bool {
+	s.adsClientsMutex.RLock()
+	defer s.adsClientsMutex.RUnlock()
+	return s.adsClients[con.conID] == con
+}
+
+// adjustLoadFactors updates the endpoint weight factors from the load reports, and pushes the clusters whose
+// weights changed.
+func (s *DiscoveryServer) adjustLoadFactors() {
+	changed := s.loadReports.adjust(features.LoadReportMaxWeightFactor)
+	if len(changed) == 0 {
+		return
+	}
+	push := s.globalPushContext()
+	if push == nil {
+		return
+	}
+	updated := sets.New[model.ConfigKey]()
+	for _, cluster := range changed {
+		_, _, hostname, _ := model.ParseSubsetKey(cluster)
+		for ns := range push.ServiceIndex.HostnameAndNamespace[hostname] {
+			updated.Insert(model.ConfigKey{Kind: kind.ServiceEntry, Name: string(hostname), Namespace: ns})
+		}
+	}
+	if len(updated) == 0 {
+		return
+	}
+	s.ConfigUpdate(&model.PushRequest{
+		Full:           false,
+		ConfigsUpdated: updated,
+		Reason:         model.NewReasonStats(model.EndpointUpdate),
+	})
+}
+
+// periodicAdjustLoadFactors adjusts the endpoint weights at the load reporting interval.
+func (s *DiscoveryServer) periodicAdjustLoadFactors(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(features.LoadReportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.adjustLoadFactors()
+		case <-stopCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/util/sets"
+)
+
+func TestComputeLoadFactors(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous map[string]float64
+		load     map[string]uint64
+		want     map[string]float64
+	}{
+		{
+			name: "even load",
+			load: map[string]uint64{"a": 5, "b": 5},
+			want: map[string]float64{},
+		},
+		{
+			name: "uneven load",
+			load: map[string]uint64{"a": 1, "b": 3},
+			want: map[string]float64{"a": 1.5, "b": 0.75},
+		},
+		{
+			name:     "accumulates",
+			previous: map[string]float64{"a": 1.5, "b": 0.75},
+			load:     map[string]uint64{"a": 1, "b": 3},
+			want:     map[string]float64{"a": 2.25, "b": 0.5625},
+		},
+		{
+			name: "bounded step",
+			load: map[string]uint64{"a": 0, "b": 0, "c": 300},
+			want: map[string]float64{"a": 2, "b": 2, "c": 0.5},
+		},
+		{
+			name:     "bounded factor",
+			previous: map[string]float64{"a": 4, "b": 0.25},
+			load:     map[string]uint64{"a": 0, "b": 100},
+			want:     map[string]float64{"a": 4, "b": 0.25},
+		},
+		{
+			name:     "idle keeps factors",
+			previous: map[string]float64{"a": 2, "gone": 2},
+			load:     map[string]uint64{"a": 0, "b": 0},
+			want:     map[string]float64{"a": 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, computeLoadFactors(tt.previous, tt.load, 4), tt.want)
+		})
+	}
+}
+
+func clusterStats(cluster string, inProgress map[string]uint64) *endpoint.ClusterStats {
+	ls := &endpoint.UpstreamLocalityStats{}
+	for ip, n := range inProgress {
+		ls.UpstreamEndpointStats = append(ls.UpstreamEndpointStats, &endpoint.UpstreamEndpointStats{
+			Address: &core.Address{Address: &core.Address_SocketAddress{SocketAddress: &core.SocketAddress{
+				Address:       ip,
+				PortSpecifier: &core.SocketAddress_PortValue{PortValue: 8080},
+			}}},
+			TotalRequestsInProgress: n,
+		})
+	}
+	return &endpoint.ClusterStats{ClusterName: cluster, UpstreamLocalityStats: []*endpoint.UpstreamLocalityStats{ls}}
+}
+
+func TestLoadReports(t *testing.T) {
+	const cluster = "outbound|80||a.ns.svc.cluster.local"
+	const other = "outbound|80||b.ns.svc.cluster.local"
+	subscribed := sets.New(cluster, "inbound|80||")
+	r := newLoadReports()
+	r.record("proxy-1", "node", []*endpoint.ClusterStats{
+		clusterStats(cluster, map[string]uint64{"10.0.0.1": 1, "10.0.0.2": 1}),
+		// Inbound clusters are not load balanced by istiod.
+		clusterStats("inbound|80||", map[string]uint64{"127.0.0.1": 4}),
+		// Clusters the proxy does not subscribe to are ignored.
+		clusterStats(other, map[string]uint64{"10.0.1.1": 100, "10.0.1.2": 0}),
+	}, subscribed)
+	// Another stream of the same node does not replace the reports of the first one.
+	r.record("proxy-2", "node", []*endpoint.ClusterStats{
+		clusterStats(cluster, map[string]uint64{"10.0.0.1": 0, "10.0.0.2": 2}),
+	}, subscribed)
+
+	assert.Equal(t, r.adjust(4), []string{cluster})
+	factors := r.loadFactors(cluster)
+	assert.Equal(t, factors.Version, uint64(1))
+	assert.Equal(t, factors.ByAddress, map[string]float64{"10.0.0.1:8080": 1.5, "10.0.0.2:8080": 0.75})
+	assert.Equal(t, r.loadFactors("inbound|80||") == nil, true)
+	assert.Equal(t, r.loadFactors(other) == nil, true)
+
+	// The load is even now, the factors are kept.
+	r.record("proxy-2", "node", []*endpoint.ClusterStats{
+		clusterStats(cluster, map[string]uint64{"10.0.0.1": 1, "10.0.0.2": 1}),
+	}, subscribed)
+	assert.Equal(t, r.adjust(4), []string(nil))
+	assert.Equal(t, r.loadFactors(cluster).Version, uint64(1))
+
+	// Closing one stream keeps the reports of the other.
+	r.forget("proxy-2")
+	_, reporting := r.requestedClusters("node")
+	assert.Equal(t, reporting, true)
+
+	// Without reports, the weights are restored.
+	r.forget("proxy-1")
+	r.forget("proxy-2")
+	assert.Equal(t, r.adjust(4), []string{cluster})
+	assert.Equal(t, r.loadFactors(cluster) == nil, true)
+}
+
+func TestLoadReporterConnection(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	s.Connect(nil, nil, []string{v3.ClusterType})
+	con := s.Discovery.Clients()[0]
+	node := con.node.GetId()
+
+	assert.Equal(t, s.Discovery.loadReporterConnection(node, nil) == con, true)
+	assert.Equal(t, s.Discovery.loadReporterConnection("other", nil) == nil, true)
+	own := spiffe.Identity{TrustDomain: "cluster.local", Namespace: con.proxy.ConfigNamespace, ServiceAccount: con.proxy.Metadata.ServiceAccount}
+	assert.Equal(t, s.Discovery.loadReporterConnection(node, []string{own.String()}) == con, true)
+	// A stream authenticated as another workload cannot report the load of the proxy.
+	other := spiffe.Identity{TrustDomain: "cluster.local", Namespace: "other", ServiceAccount: "other"}
+	assert.Equal(t, s.Discovery.loadReporterConnection(node, []string{other.String()}) == nil, true)
+	assert.Equal(t, s.Discovery.isConnected(con), true)
+}
@@ -0,0 +1,242 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"sync"
+	"time"
+	"unsafe"
+
+	"google.golang.org/protobuf/proto"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pkg/config"
+)
+
+const (
+	memoryEndpointIndex = "endpoint_index"
+	memoryXdsCache      = "xds_cache"
+	memoryPushQueue     = "push_queue"
+	memoryConfigStore   = "config_store"
+)
+
+// ballast is a heap allocation kept for the lifetime of istiod. It raises the heap size the garbage collector
+// targets, so that it runs less often while the live heap is small. The memory is never touched, so the pages
+// backing it are mostly not resident.
+var ballast struct {
+	sync.Mutex
+	b []byte
+}
+
+// maxMemoryBallastBytes bounds the size of the ballast, which must stay well below the memory of istiod.
+const maxMemoryBallastBytes = 4 << 30
+
+// setMemoryBallast sets the size of the ballast, bounded by maxMemoryBallastBytes and half the soft memory limit,
+// and returns the size set.
+func setMemoryBallast(size int) int {
+	if size < 0 {
+		size = 0
+	}
+	limit := int64(maxMemoryBallastBytes)
+	if memoryLimit := debug.SetMemoryLimit(-1); memoryLimit/2 < limit {
+		limit = memoryLimit / 2
+	}
+	if int64(size) > limit {
+		log.Warnf("memory ballast of %d bytes exceeds the maximum of %d bytes, using the maximum", size, limit)
+		size = int(limit)
+	}
+	ballast.Lock()
+	defer ballast.Unlock()
+	if len(ballast.b) == size {
+		return size
+	}
+	ballast.b = nil
+	if size > 0 {
+		ballast.b = make([]byte, size)
+	}
+	memoryBallastBytes.Record(float64(size))
+	return size
+}
+
+func memoryBallastSize() int {
+	ballast.Lock()
+	defer ballast.Unlock()
+	return len(ballast.b)
+}
+
+// gcPercent returns the current GOGC value, negative if the garbage collector is disabled. It is read from the
+// runtime metrics, as setting it to read the previous value would briefly change it for the whole process.
+func gcPercent() int {
+	sample := []metrics.Sample{{Name: "/gc/gogc:percent"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return -1
+	}
+	p := sample[0].Value.Uint64()
+	if p == math.MaxUint64 {
+		return -1
+	}
+	return int(p)
+}
+
+// SubsystemMemory is the estimated memory used by a subsystem of istiod.
+type SubsystemMemory struct {
+	Name           string `json:"name"`
+	Items          int    `json:"items"`
+	EstimatedBytes int    `json:"estimatedBytes"`
+}
+
+// MemoryStatus is the memory status of istiod returned by /debug/memz.
+type MemoryStatus struct {
+	GCPercent        int               `json:"gcPercent"`
+	MemoryLimitBytes int64             `json:"memoryLimitBytes"`
+	BallastBytes     int               `json:"ballastBytes"`
+	HeapAllocBytes   uint64            `json:"heapAllocBytes"`
+	HeapSysBytes     uint64            `json:"heapSysBytes"`
+	NumGC            uint32            `json:"numGC"`
+	Subsystems       []SubsystemMemory `json:"subsystems"`
+}
+
+// memoryAccounting estimates the memory used by the main subsystems of istiod. The estimates cover the data held
+// by each subsystem, not the overhead of the Go runtime, and are meant to attribute the growth of the heap.
+func (s *DiscoveryServer) memoryAccounting() []SubsystemMemory {
+	var out []SubsystemMemory
+	if s.Env != nil && s.Env.EndpointIndex != nil {
+		count, size := s.Env.EndpointIndex.MemoryEstimate()
+		out = append(out, SubsystemMemory{Name: memoryEndpointIndex, Items: count, EstimatedBytes: size})
+	}
+	if s.Cache != nil {
+		cached := s.Cache.Snapshot()
+		size := 0
+		for _, r := range cached {
+			size += proto.Size(r)
+		}
+		out = append(out, SubsystemMemory{Name: memoryXdsCache, Items: len(cached), EstimatedBytes: size})
+	}
+	if s.pushQueue != nil {
+		out = append(out, SubsystemMemory{Name: memoryPushQueue, Items: s.pushQueue.Pending(), EstimatedBytes: s.pushQueue.MemoryEstimate()})
+	}
+	if s.Env != nil && s.Env.ConfigStore != nil {
+		count, size := 0, 0
+		for _, schema := range s.Env.ConfigStore.Schemas().All() {
+			for _, c := range s.Env.ConfigStore.List(schema.GroupVersionKind(), "") {
+				count++
+				size += configSizeEstimate(c)
+			}
+		}
+		out = append(out, SubsystemMemory{Name: memoryConfigStore, Items: count, EstimatedBytes: size})
+	}
+	return out
+}
+
+func configSizeEstimate(c config.Config) int {
+	size := int(unsafe.Sizeof(c)) + len(c.Name) + len(c.Namespace) + len(c.Domain) + len(c.ResourceVersion)
+	for k, v := range c.Labels {
+		size += len(k) + len(v)
+	}
+	for k, v := range c.Annotations {
+		size += len(k) + len(v)
+	}
+	if m, ok := c.Spec.(proto.Message); ok {
+		size += proto.Size(m)
+	}
+	return size
+}
+
+func (s *DiscoveryServer) memoryStatus() MemoryStatus {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return MemoryStatus{
+		GCPercent:        gcPercent(),
+		MemoryLimitBytes: debug.SetMemoryLimit(-1),
+		BallastBytes:     memoryBallastSize(),
+		HeapAllocBytes:   ms.HeapAlloc,
+		HeapSysBytes:     ms.HeapSys,
+		NumGC:            ms.NumGC,
+		Subsystems:       s.memoryAccounting(),
+	}
+}
+
+func (s *DiscoveryServer) recordMemoryAccounting() {
+	for _, m := range s.memoryAccounting() {
+		memoryEstimatedBytes.With(subsystemTag.Value(m.Name)).Record(float64(m.EstimatedBytes))
+	}
+}
+
+func (s *DiscoveryServer) periodicMemoryAccounting(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(features.MemoryAccountingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.recordMemoryAccounting()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// memoryz shows the memory status of istiod. On POST, the gcPercent, memoryLimit and ballast query parameters change
+// the GC percentage, the soft memory limit and the size of the heap ballast, in bytes.
+func (s *DiscoveryServer) memoryz(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	if req.Method != http.MethodPost {
+		if q.Has("gcPercent") || q.Has("memoryLimit") || q.Has("ballast") {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_, _ = w.Write([]byte("The memory settings can only be changed with POST\n"))
+			return
+		}
+		writeJSON(w, s.memoryStatus(), req)
+		return
+	}
+	if v := q.Get("gcPercent"); v != "" {
+		p, err := strconv.Atoi(v)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(w, "Invalid gcPercent %q, expected a number, negative to disable the garbage collector\n", v)
+			return
+		}
+		debug.SetGCPercent(p)
+		log.WithLabels("audit", "memory", "gcPercent", p, "remote", req.RemoteAddr).Warnf("GC percentage changed")
+	}
+	if v := q.Get("memoryLimit"); v != "" {
+		limit, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || limit <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(w, "Invalid memoryLimit %q, expected a positive number of bytes\n", v)
+			return
+		}
+		debug.SetMemoryLimit(limit)
+		log.WithLabels("audit", "memory", "memoryLimit", limit, "remote", req.RemoteAddr).Warnf("Memory limit changed")
+	}
+	if v := q.Get("ballast"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(w, "Invalid ballast %q, expected a number of bytes\n", v)
+			return
+		}
+		size = setMemoryBallast(size)
+		log.WithLabels("audit", "memory", "ballast", size, "remote", req.RemoteAddr).Warnf("Memory ballast changed")
+	}
+	writeJSON(w, s.memoryStatus(), req)
+}
@@ -0,0 +1,109 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime/debug"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestMemoryz(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{
+		ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se
+  namespace: default
+spec:
+  hosts:
+  - example.com
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: STATIC
+  endpoints:
+  - address: 1.2.3.4
+`,
+	})
+	s.Discovery.Env.EndpointIndex.UpdateServiceEndpoints(model.ShardKey{Cluster: "c1", Provider: provider.Kubernetes},
+		"a.ns.svc.cluster.local", "ns", []*model.IstioEndpoint{{Address: "10.0.0.1"}, {Address: "10.0.0.2"}})
+
+	gcPercent, memoryLimit := gcPercent(), debug.SetMemoryLimit(-1)
+	t.Cleanup(func() {
+		debug.SetGCPercent(gcPercent)
+		debug.SetMemoryLimit(memoryLimit)
+		setMemoryBallast(0)
+	})
+
+	memoryz := func(method, query string) (int, MemoryStatus) {
+		rr := httptest.NewRecorder()
+		s.Discovery.memoryz(rr, httptest.NewRequest(method, "/debug/memz"+query, nil))
+		out := MemoryStatus{}
+		if rr.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &out))
+		}
+		return rr.Code, out
+	}
+
+	code, status := memoryz(http.MethodGet, "")
+	assert.Equal(t, code, http.StatusOK)
+	subsystems := map[string]SubsystemMemory{}
+	for _, m := range status.Subsystems {
+		subsystems[m.Name] = m
+	}
+	assert.Equal(t, subsystems[memoryEndpointIndex].Items >= 2, true)
+	assert.Equal(t, subsystems[memoryEndpointIndex].EstimatedBytes > 0, true)
+	assert.Equal(t, subsystems[memoryConfigStore].Items,
+		len(s.Discovery.Env.ConfigStore.List(gvk.ServiceEntry, "")))
+	assert.Equal(t, subsystems[memoryConfigStore].EstimatedBytes > 0, true)
+	_, f := subsystems[memoryXdsCache]
+	assert.Equal(t, f, true)
+	_, f = subsystems[memoryPushQueue]
+	assert.Equal(t, f, true)
+
+	assert.Equal(t, status.GCPercent, gcPercent)
+
+	// The settings are only changed with POST.
+	code, _ = memoryz(http.MethodGet, "?gcPercent=250")
+	assert.Equal(t, code, http.StatusMethodNotAllowed)
+	_, status = memoryz(http.MethodGet, "")
+	assert.Equal(t, status.GCPercent, gcPercent)
+
+	code, status = memoryz(http.MethodPost, "?gcPercent=250&memoryLimit=4000000000&ballast=1024")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, status.GCPercent, 250)
+	assert.Equal(t, status.MemoryLimitBytes, int64(4000000000))
+	assert.Equal(t, status.BallastBytes, 1024)
+
+	// The ballast is bounded by half the memory limit.
+	code, status = memoryz(http.MethodPost, "?memoryLimit=400000000&ballast=300000000")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, status.BallastBytes, 200000000)
+
+	code, _ = memoryz(http.MethodPost, "?memoryLimit=-1")
+	assert.Equal(t, code, http.StatusBadRequest)
+	code, _ = memoryz(http.MethodPost, "?ballast=lots")
+	assert.Equal(t, code, http.StatusBadRequest)
+}
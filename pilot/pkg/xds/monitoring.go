@@ -31,6 +31,8 @@ var (
 	typeTag    = monitoring.CreateLabel("type")
 	versionTag = monitoring.CreateLabel("version")
 
+	compressionTag = monitoring.CreateLabel("compression")
+
 	// pilot_total_xds_rejects should be used instead. This is for backwards compatibility
 	cdsReject = monitoring.NewGauge(
 		"pilot_xds_cds_reject",
@@ -161,6 +163,77 @@ var (
 	inboundServiceUpdates = inboundUpdates.With(typeTag.Value("svc"))
 	inboundServiceDeletes = inboundUpdates.With(typeTag.Value("svcdelete"))
 
+	edsClaBytes = monitoring.NewDistribution(
+		"pilot_eds_cla_bytes",
+		"Size in bytes of the ClusterLoadAssignments sent to proxies.",
+		[]float64{1000, 10000, 100000, 1000000, 4000000, 10000000},
+	)
+
+	cohortTag = monitoring.CreateLabel("cohort")
+
+	edsMeshCanaryPushes = monitoring.NewSum(
+		"pilot_eds_mesh_canary_pushes",
+		"Total number of EDS pushes during a rollout of meshConfig endpoint settings, labeled by the cohort of the proxy.",
+	)
+
+	edsMeshCanaryRejects = monitoring.NewSum(
+		"pilot_eds_mesh_canary_rejects",
+		"Total number of EDS rejections during a rollout of meshConfig endpoint settings, labeled by the cohort of the proxy.",
+	)
+
+	subsystemTag = monitoring.CreateLabel("subsystem")
+
+	memoryEstimatedBytes = monitoring.NewGauge(
+		"pilot_memory_estimated_bytes",
+		"Estimated memory used by the subsystems of istiod, labeled by subsystem.",
+		monitoring.WithUnit(monitoring.Bytes),
+	)
+
+	memoryBallastBytes = monitoring.NewGauge(
+		"pilot_memory_ballast_bytes",
+		"Size of the heap ballast kept by istiod.",
+		monitoring.WithUnit(monitoring.Bytes),
+	)
+
+	edsPauseEvents = monitoring.NewSum(
+		"pilot_eds_push_pause_events",
+		"Total number of times EDS pushes were paused or resumed for a service, labeled by action.",
+	)
+
+	edsPushPolicyEvents = monitoring.NewSum(
+		"pilot_eds_push_policy_events",
+		"Total number of endpoint updates handled by a per-service push policy, labeled by outcome.",
+	)
+
+	readOnlyEdsDenied = monitoring.NewSum(
+		"pilot_xds_readonly_eds_denied",
+		"Total number of endpoint resources requested by read-only xDS clients for services not exported to their namespace.",
+	)
+
+	edsInvisibleUpdatesSkipped = monitoring.NewSum(
+		"pilot_eds_invisible_updates_skipped",
+		"Total number of ClusterLoadAssignments not rebuilt by incremental pushes, as their endpoints only changed on "+
+			"networks the proxy cannot view.",
+	)
+
+	edsPrecomputations = monitoring.NewSum(
+		"pilot_eds_precomputations",
+		"Total number of ClusterLoadAssignments rebuilt in the background after endpoint updates, when "+
+			"PILOT_ENABLE_EDS_PRECOMPUTATION is enabled.",
+	)
+
+	edsLastKnownGoodRetained = monitoring.NewSum(
+		"pilot_eds_last_known_good_retained",
+		"Total number of ClusterLoadAssignments replaced by their last known good endpoints while the endpoints of "+
+			"their service were not indexed yet.",
+	)
+
+	wireBytes = monitoring.NewSum(
+		"pilot_xds_wire_bytes",
+		"Total bytes of xDS responses sent to clients, labeled by type and compression. Compressed bytes include the gRPC framing.",
+		monitoring.WithUnit(monitoring.Bytes),
+	)
+
 	configSizeBytes = monitoring.NewDistribution(
 		"pilot_xds_config_size_bytes",
 		"Distribution of configuration sizes pushed to clients",
@@ -193,6 +266,7 @@ var triggerMetric = map[model.TriggerReason]monitoring.Metric{
 	model.ProxyRequest:    pushTriggers.With(typeTag.Value(string(model.ProxyRequest))),
 	model.NamespaceUpdate: pushTriggers.With(typeTag.Value(string(model.NamespaceUpdate))),
 	model.ClusterUpdate:   pushTriggers.With(typeTag.Value(string(model.ClusterUpdate))),
+	model.RolloutUpdate:   pushTriggers.With(typeTag.Value(string(model.RolloutUpdate))),
 }
 
 func recordPushTriggers(reasons model.ReasonStats) {
@@ -0,0 +1,132 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestMtlsz(t *testing.T) {
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: reviews
+  namespace: default
+spec:
+  hosts:
+  - reviews.default.svc.cluster.local
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  - number: 9080
+    name: http-admin
+    protocol: HTTP
+  resolution: STATIC
+  workloadSelector:
+    labels:
+      app: reviews
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: WorkloadEntry
+metadata:
+  name: reviews-sidecar
+  namespace: default
+spec:
+  address: 1.1.1.1
+  labels:
+    app: reviews
+    security.istio.io/tlsMode: istio
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: WorkloadEntry
+metadata:
+  name: reviews-plaintext
+  namespace: default
+spec:
+  address: 2.2.2.2
+  labels:
+    app: reviews
+---
+apiVersion: security.istio.io/v1beta1
+kind: PeerAuthentication
+metadata:
+  name: default
+  namespace: default
+spec:
+  mtls:
+    mode: STRICT
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: reviews
+  namespace: default
+spec:
+  host: reviews.default.svc.cluster.local
+  trafficPolicy:
+    portLevelSettings:
+    - port:
+        number: 9080
+      tls:
+        mode: DISABLE
+`})
+	mtlsz := func(query string) (int, []xds.MtlsDecisions) {
+		req := httptest.NewRequest(http.MethodGet, "/debug/mtlsz?"+query, nil)
+		rr := httptest.NewRecorder()
+		s.Discovery.Mtlsz(rr, req)
+		var out []xds.MtlsDecisions
+		if rr.Code == http.StatusOK {
+			if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rr.Code, out
+	}
+
+	code, out := mtlsz("service=reviews.default.svc.cluster.local&port=80")
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, len(out), 1)
+	assert.Equal(t, out[0].Cluster, "outbound|80||reviews.default.svc.cluster.local")
+	assert.Equal(t, out[0].DestinationRules, []string{"default/reviews"})
+	decisions := map[string]bool{}
+	for _, d := range out[0].Endpoints {
+		decisions[d.Address] = d.MtlsEnabled
+		if d.Address == "1.1.1.1" {
+			assert.Equal(t, d.PeerAuthenticationMode, "STRICT")
+			assert.Equal(t, d.PeerAuthentications, []string{"default/default"})
+		}
+	}
+	assert.Equal(t, decisions, map[string]bool{"1.1.1.1": true, "2.2.2.2": false})
+
+	// The DestinationRule TLS settings of the port take precedence.
+	_, out = mtlsz("service=reviews.default.svc.cluster.local&port=9080")
+	for _, d := range out[0].Endpoints {
+		assert.Equal(t, d.MtlsEnabled, false)
+		assert.Equal(t, d.DestinationRuleTLSMode, "DISABLE")
+	}
+
+	code, _ = mtlsz("service=unknown.default.svc.cluster.local")
+	assert.Equal(t, code, http.StatusNotFound)
+	code, _ = mtlsz("")
+	assert.Equal(t, code, http.StatusBadRequest)
+}
@@ -108,5 +108,15 @@ func DefaultProxyNeedsPush(proxy *model.Proxy, req *model.PushRequest) bool {
 		}
 	}
 
+	// The endpoint-watch clients follow the services of the namespaces they watch, visible to them or not.
+	if model.HasConfigsOfKind(req.ConfigsUpdated, kind.ServiceEntry) {
+		proxy.RLock()
+		_, watching := proxy.WatchedResources[TypeURLEndpointShards]
+		proxy.RUnlock()
+		if watching {
+			return true
+		}
+	}
+
 	return false
 }
@@ -16,6 +16,7 @@ package xds
 
 import (
 	"sync"
+	"unsafe"
 
 	"istio.io/istio/pilot/pkg/model"
 )
@@ -125,6 +126,22 @@ func (p *PushQueue) Pending() int {
 	return len(p.queue)
 }
 
+// MemoryEstimate returns an estimate of the memory used by the push requests waiting in the queue, in bytes.
+func (p *PushQueue) MemoryEstimate() int {
+	p.cond.L.Lock()
+	defer p.cond.L.Unlock()
+	size := len(p.queue) * int(unsafe.Sizeof(&Connection{}))
+	for _, requests := range []map[*Connection]*model.PushRequest{p.pending, p.processing} {
+		for _, req := range requests {
+			size += int(unsafe.Sizeof(req))
+			if req != nil {
+				size += int(unsafe.Sizeof(*req)) + len(req.ConfigsUpdated)*int(unsafe.Sizeof(model.ConfigKey{}))
+			}
+		}
+	}
+	return size
+}
+
 // ShutDown will cause queue to ignore all new items added to it. As soon as the
 // worker goroutines have drained the existing items in the queue, they will be
 // instructed to exit.
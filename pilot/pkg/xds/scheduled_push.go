@@ -0,0 +1,118 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"sync"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// scheduledPushInterval is how often the scheduled pushes are sent and the endpoint TTLs are checked.
+const scheduledPushInterval = 5 * time.Second
+
+type scheduledPushKey struct {
+	hostname  string
+	namespace string
+}
+
+// scheduledPushes tracks when the endpoints of services are to be pushed again, as their ClusterLoadAssignments
+// change over time without any endpoint update: while their endpoints ramp up after a scale-up, and when the
+// draining endpoints kept for their persistent sessions expire. Once pushed, a service is no longer tracked until
+// its endpoints are rebuilt and schedule the next push.
+type scheduledPushes struct {
+	mu sync.Mutex
+	at map[scheduledPushKey]time.Time
+}
+
+func newScheduledPushes() *scheduledPushes {
+	return &scheduledPushes{at: map[scheduledPushKey]time.Time{}}
+}
+
+// schedule records that the endpoints of the service are to be pushed at the given time. The earliest push is kept.
+func (p *scheduledPushes) schedule(svc *model.Service, at time.Time) {
+	key := scheduledPushKey{hostname: string(svc.Hostname), namespace: svc.Attributes.Namespace}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cur, f := p.at[key]; !f || at.Before(cur) {
+		p.at[key] = at
+	}
+}
+
+// due returns the services to push at the given time, and stops tracking them.
+func (p *scheduledPushes) due(now time.Time) []scheduledPushKey {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var out []scheduledPushKey
+	for key, at := range p.at {
+		if !now.Before(at) {
+			out = append(out, key)
+			delete(p.at, key)
+		}
+	}
+	return out
+}
+
+// scheduleEndpointsPush schedules the next push of the endpoints built by the builder, if they change over time.
+func (s *DiscoveryServer) scheduleEndpointsPush(svc *model.Service, rampUntil, drainExpiry time.Time, now time.Time) {
+	if !rampUntil.IsZero() {
+		// The endpoints are pushed periodically as the ramp progresses, and one last time with their full weight
+		// once it is over.
+		at := now.Add(scheduledPushInterval)
+		if rampUntil.Before(at) {
+			at = rampUntil
+		}
+		s.scheduledPushes.schedule(svc, at)
+	}
+	if !drainExpiry.IsZero() {
+		s.scheduledPushes.schedule(svc, drainExpiry)
+	}
+}
+
+// pushScheduled pushes the endpoints of the services scheduled by the given time.
+func (s *DiscoveryServer) pushScheduled(now time.Time) {
+	due := s.scheduledPushes.due(now)
+	if len(due) == 0 {
+		return
+	}
+	configs := sets.NewWithLength[model.ConfigKey](len(due))
+	for _, key := range due {
+		configs.Insert(model.ConfigKey{Kind: kind.ServiceEntry, Name: key.hostname, Namespace: key.namespace})
+	}
+	s.ConfigUpdate(&model.PushRequest{
+		Full:           false,
+		ConfigsUpdated: configs,
+		Reason:         model.NewReasonStats(model.EndpointUpdate),
+	})
+}
+
+// periodicScheduledPushes sends the scheduled pushes and expires the endpoints not refreshed within their TTL
+// periodically.
+func (s *DiscoveryServer) periodicScheduledPushes(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(scheduledPushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.expireEndpointTTLs(now)
+			s.pushScheduled(now)
+		case <-stopCh:
+			return
+		}
+	}
+}
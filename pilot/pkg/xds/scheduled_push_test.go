@@ -0,0 +1,53 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestScheduledPushes(t *testing.T) {
+	now := time.Unix(10000, 0)
+	svc := &model.Service{Hostname: "a.ns.svc.cluster.local", Attributes: model.ServiceAttributes{Namespace: "ns"}}
+	s := &DiscoveryServer{scheduledPushes: newScheduledPushes()}
+	due := func(now time.Time) []string {
+		var out []string
+		for _, key := range s.scheduledPushes.due(now) {
+			out = append(out, key.namespace+"/"+key.hostname)
+		}
+		return out
+	}
+	assert.Equal(t, due(now), nil)
+
+	// A draining endpoint expires in a minute, the earliest push is kept.
+	s.scheduleEndpointsPush(svc, time.Time{}, now.Add(2*time.Minute), now)
+	s.scheduleEndpointsPush(svc, time.Time{}, now.Add(time.Minute), now)
+	assert.Equal(t, due(now), nil)
+	assert.Equal(t, due(now.Add(time.Minute)), []string{"ns/a.ns.svc.cluster.local"})
+	// The service is no longer tracked until its endpoints are rebuilt.
+	assert.Equal(t, due(now.Add(2*time.Minute)), nil)
+
+	// The endpoints ramping up are pushed periodically, until the ramp is over.
+	s.scheduleEndpointsPush(svc, now.Add(time.Minute), time.Time{}, now)
+	assert.Equal(t, due(now), nil)
+	assert.Equal(t, due(now.Add(scheduledPushInterval)), []string{"ns/a.ns.svc.cluster.local"})
+	now = now.Add(58 * time.Second)
+	s.scheduleEndpointsPush(svc, now.Add(2*time.Second), time.Time{}, now)
+	assert.Equal(t, due(now.Add(2*time.Second)), []string{"ns/a.ns.svc.cluster.local"})
+}
@@ -0,0 +1,127 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// SidecarSuggestion is the Sidecar egress configuration suggested for the sidecars of a namespace, from the
+// clusters they subscribe to and the ones they actually send requests to.
+type SidecarSuggestion struct {
+	Namespace string `json:"namespace"`
+	// Proxies is the number of sidecars of the namespace reporting their load.
+	Proxies int `json:"proxies"`
+	// SubscribedHosts is the number of service hosts whose endpoints the sidecars watch.
+	SubscribedHosts int `json:"subscribedHosts"`
+	// Hosts are the suggested egress hosts, in namespace/host form.
+	Hosts []string `json:"hosts"`
+	// Updated is when the suggestion was last published.
+	Updated time.Time `json:"updated,omitempty"`
+}
+
+// SidecarSuggestions returns the suggested Sidecar egress hosts of each namespace, sorted by namespace. Only the
+// sidecars reporting their load through the Load Reporting Service are considered.
+func (s *DiscoveryServer) SidecarSuggestions() []SidecarSuggestion {
+	push := s.globalPushContext()
+	if push == nil {
+		return nil
+	}
+	type namespaceHosts struct {
+		proxies    int
+		subscribed sets.Set[host.Name]
+		requested  sets.Set[host.Name]
+	}
+	byNamespace := map[string]*namespaceHosts{}
+	for _, con := range s.Clients() {
+		if con.proxy.Type != model.SidecarProxy {
+			continue
+		}
+		requested, reporting := s.loadReports.requestedClusters(con.node.GetId())
+		if !reporting {
+			continue
+		}
+		nh := byNamespace[con.proxy.ConfigNamespace]
+		if nh == nil {
+			nh = &namespaceHosts{subscribed: sets.New[host.Name](), requested: sets.New[host.Name]()}
+			byNamespace[con.proxy.ConfigNamespace] = nh
+		}
+		nh.proxies++
+		for _, cluster := range con.Clusters() {
+			if _, _, hostname, _ := model.ParseSubsetKey(cluster); hostname != "" {
+				nh.subscribed.Insert(hostname)
+			}
+		}
+		for cluster := range requested {
+			if _, _, hostname, _ := model.ParseSubsetKey(cluster); hostname != "" {
+				nh.requested.Insert(hostname)
+			}
+		}
+	}
+
+	out := make([]SidecarSuggestion, 0, len(byNamespace))
+	for _, ns := range maps.Keys(byNamespace) {
+		nh := byNamespace[ns]
+		hosts := make([]string, 0, len(nh.requested))
+		for hostname := range nh.requested {
+			hosts = append(hosts, egressHost(push, hostname))
+		}
+		sort.Strings(hosts)
+		out = append(out, SidecarSuggestion{
+			Namespace:       ns,
+			Proxies:         nh.proxies,
+			SubscribedHosts: nh.subscribed.Len(),
+			Hosts:           hosts,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Namespace < out[j].Namespace
+	})
+	return out
+}
+
+// egressHost returns the Sidecar egress host matching the services with the hostname.
+func egressHost(push *model.PushContext, hostname host.Name) string {
+	byNamespace := push.ServiceIndex.HostnameAndNamespace[hostname]
+	if len(byNamespace) == 1 {
+		for ns := range byNamespace {
+			return ns + "/" + string(hostname)
+		}
+	}
+	return "*/" + string(hostname)
+}
+
+// sidecarSuggestionsz returns the suggested Sidecar egress hosts of each namespace, or of the namespace set with
+// the namespace query parameter.
+func (s *DiscoveryServer) sidecarSuggestionsz(w http.ResponseWriter, req *http.Request) {
+	suggestions := s.SidecarSuggestions()
+	if ns := req.URL.Query().Get("namespace"); ns != "" {
+		filtered := []SidecarSuggestion{}
+		for _, suggestion := range suggestions {
+			if suggestion.Namespace == ns {
+				filtered = append(filtered, suggestion)
+			}
+		}
+		suggestions = filtered
+	}
+	writeJSON(w, suggestions, req)
+}
@@ -0,0 +1,87 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/util/sets"
+)
+
+func TestSidecarSuggestions(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{
+		ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: a
+  namespace: ns1
+spec:
+  hosts:
+  - a.example.com
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: STATIC
+  endpoints:
+  - address: 1.2.3.4
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: b
+  namespace: ns2
+spec:
+  hosts:
+  - b.example.com
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: STATIC
+  endpoints:
+  - address: 1.2.3.5
+`,
+	})
+	s.Connect(nil, nil, []string{v3.ClusterType, v3.EndpointType})
+	clients := s.Discovery.Clients()
+	assert.Equal(t, len(clients), 1)
+	con := clients[0]
+
+	// Sidecars not reporting their load are not considered.
+	assert.Equal(t, len(s.Discovery.SidecarSuggestions()), 0)
+
+	s.Discovery.loadReports.record(con.conID, con.node.GetId(), []*endpoint.ClusterStats{{
+		ClusterName:           "outbound|80||a.example.com",
+		UpstreamLocalityStats: []*endpoint.UpstreamLocalityStats{{TotalIssuedRequests: 3}},
+	}, {
+		ClusterName:           "outbound|80||b.example.com",
+		UpstreamLocalityStats: []*endpoint.UpstreamLocalityStats{{}},
+	}}, sets.New(con.Clusters()...))
+	suggestions := s.Discovery.SidecarSuggestions()
+	assert.Equal(t, len(suggestions), 1)
+	assert.Equal(t, suggestions[0].Namespace, con.proxy.ConfigNamespace)
+	assert.Equal(t, suggestions[0].Proxies, 1)
+	assert.Equal(t, suggestions[0].SubscribedHosts >= 2, true)
+	assert.Equal(t, suggestions[0].Hosts, []string{"ns1/a.example.com"})
+
+	s.Discovery.loadReports.forget(con.conID)
+	assert.Equal(t, len(s.Discovery.SidecarSuggestions()), 0)
+}
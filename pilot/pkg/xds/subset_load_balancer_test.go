@@ -0,0 +1,268 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds_test
+
+import (
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pilot/test/xdstest"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestSubsetLoadBalancer(t *testing.T) {
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: reviews
+  namespace: default
+spec:
+  hosts:
+  - reviews.example.com
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: STATIC
+  endpoints:
+  - address: 1.1.1.1
+    labels:
+      version: v1
+  - address: 2.2.2.2
+    labels:
+      version: v2
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: reviews
+  namespace: default
+  annotations:
+    networking.istio.io/subset-load-balancer: "true"
+spec:
+  host: reviews.example.com
+  subsets:
+  - name: v1
+    labels:
+      version: v1
+  - name: v2
+    labels:
+      version: v2
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: reviews
+  namespace: default
+spec:
+  hosts:
+  - reviews.example.com
+  http:
+  - route:
+    - destination:
+        host: reviews.example.com
+        subset: v2
+`})
+	proxy := s.SetupProxy(&model.Proxy{ConfigNamespace: "default"})
+	defaultCluster := "outbound|80||reviews.example.com"
+
+	clusters := xdstest.ExtractClusters(s.Clusters(proxy))
+	// The subset clusters are kept for the references which cannot select the subsets through metadata.
+	if clusters["outbound|80|v1|reviews.example.com"] == nil || clusters["outbound|80|v2|reviews.example.com"] == nil {
+		t.Fatalf("missing subset clusters in %v", xdstest.MapKeys(clusters))
+	}
+	var keys []string
+	for _, selector := range clusters[defaultCluster].GetLbSubsetConfig().GetSubsetSelectors() {
+		keys = append(keys, selector.Keys...)
+	}
+	assert.Equal(t, keys, []string{util.LbSubsetMetadataPrefix + "v1", util.LbSubsetMetadataPrefix + "v2"})
+
+	subsets := map[string][]string{}
+	for _, cla := range s.Endpoints(proxy) {
+		if cla.ClusterName != defaultCluster {
+			continue
+		}
+		for _, llb := range cla.Endpoints {
+			for _, ep := range llb.LbEndpoints {
+				address := ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()
+				for key := range ep.GetMetadata().GetFilterMetadata()[util.EnvoyLbMetadataKey].GetFields() {
+					subsets[address] = append(subsets[address], key)
+				}
+			}
+		}
+	}
+	assert.Equal(t, subsets["1.1.1.1"], []string{util.LbSubsetMetadataPrefix + "v1"})
+	assert.Equal(t, subsets["2.2.2.2"], []string{util.LbSubsetMetadataPrefix + "v2"})
+
+	vhosts := xdstest.ExtractRouteConfigurations(s.Routes(proxy))["80"].GetVirtualHosts()
+	found := false
+	for _, vh := range vhosts {
+		if vh.Name != "reviews.example.com:80" {
+			continue
+		}
+		found = true
+		action := vh.Routes[0].GetRoute()
+		assert.Equal(t, action.GetCluster(), defaultCluster)
+		match := action.GetMetadataMatch().GetFilterMetadata()[util.EnvoyLbMetadataKey].GetFields()
+		assert.Equal(t, match[util.LbSubsetMetadataPrefix+"v2"].GetBoolValue(), true)
+	}
+	if !found {
+		t.Fatalf("virtual host of the service not found in %v", vhosts)
+	}
+}
+
+func TestSubsetLoadBalancerRouteKinds(t *testing.T) {
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: reviews
+  namespace: default
+spec:
+  hosts:
+  - reviews.example.com
+  - ratings.example.com
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: STATIC
+  endpoints:
+  - address: 1.1.1.1
+    labels:
+      version: v1
+  - address: 2.2.2.2
+    labels:
+      version: v2
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: db
+  namespace: default
+spec:
+  hosts:
+  - db.example.com
+  addresses:
+  - 240.0.0.1
+  ports:
+  - number: 9000
+    name: tcp
+    protocol: TCP
+  resolution: STATIC
+  endpoints:
+  - address: 3.3.3.3
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: reviews
+  namespace: default
+  annotations:
+    networking.istio.io/subset-load-balancer: "true"
+spec:
+  host: reviews.example.com
+  subsets:
+  - name: v1
+    labels:
+      version: v1
+  - name: v2
+    labels:
+      version: v2
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: reviews
+  namespace: default
+spec:
+  hosts:
+  - reviews.example.com
+  http:
+  - mirror:
+      host: reviews.example.com
+      subset: v1
+    route:
+    - destination:
+        host: reviews.example.com
+        subset: v2
+      weight: 50
+    - destination:
+        host: ratings.example.com
+      weight: 50
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: db
+  namespace: default
+spec:
+  hosts:
+  - db.example.com
+  tcp:
+  - route:
+    - destination:
+        host: reviews.example.com
+        subset: v1
+        port:
+          number: 80
+`})
+	proxy := s.SetupProxy(&model.Proxy{ConfigNamespace: "default"})
+	clusters := xdstest.ExtractClusters(s.Clusters(proxy))
+
+	// The weighted split across hosts only rewrites the subset of the host using the subset load balancer.
+	var action *route.RouteAction
+	for _, vh := range xdstest.ExtractRouteConfigurations(s.Routes(proxy))["80"].GetVirtualHosts() {
+		if vh.Name == "reviews.example.com:80" {
+			action = vh.Routes[0].GetRoute()
+		}
+	}
+	if action == nil {
+		t.Fatal("virtual host of the service not found")
+	}
+	weighted := action.GetWeightedClusters().GetClusters()
+	assert.Equal(t, len(weighted), 2)
+	assert.Equal(t, weighted[0].Name, "outbound|80||reviews.example.com")
+	match := weighted[0].GetMetadataMatch().GetFilterMetadata()[util.EnvoyLbMetadataKey].GetFields()
+	assert.Equal(t, match[util.LbSubsetMetadataPrefix+"v2"].GetBoolValue(), true)
+	assert.Equal(t, weighted[1].Name, "outbound|80||ratings.example.com")
+	assert.Equal(t, weighted[1].GetMetadataMatch() == nil, true)
+
+	// The mirror keeps its subset cluster, which is still built.
+	mirror := action.GetRequestMirrorPolicies()[0].GetCluster()
+	assert.Equal(t, mirror, "outbound|80|v1|reviews.example.com")
+	if clusters[mirror] == nil {
+		t.Fatalf("missing mirror cluster %v in %v", mirror, xdstest.MapKeys(clusters))
+	}
+
+	// So does the TCP route.
+	var tcpCluster string
+	for _, l := range s.Listeners(proxy) {
+		if l.Name != "240.0.0.1_9000" {
+			continue
+		}
+		tcp := xdstest.ExtractTCPProxy(t, l.FilterChains[0])
+		tcpCluster = tcp.GetCluster()
+	}
+	assert.Equal(t, tcpCluster, "outbound|80|v1|reviews.example.com")
+	if clusters[tcpCluster] == nil {
+		t.Fatalf("missing TCP route cluster %v in %v", tcpCluster, xdstest.MapKeys(clusters))
+	}
+}
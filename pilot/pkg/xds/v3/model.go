@@ -135,3 +135,33 @@ func GetResourceType(shortType string) string {
 func IsEnvoyType(typeURL string) bool {
 	return strings.HasPrefix(typeURL, envoyTypePrefix)
 }
+
+const xdstpScheme = "xdstp://"
+
+// XdstpResourceName returns the xdstp:// name of a resource, as used by xDS federation clients:
+// xdstp://{authority}/{resource type}/{id}.
+func XdstpResourceName(authority, typeURL, id string) string {
+	return xdstpScheme + authority + "/" + strings.TrimPrefix(typeURL, resource.APITypePrefix) + "/" + id
+}
+
+// ParseXdstpResourceName returns the authority and id of an xdstp:// resource name of the type. Context parameters
+// are ignored. It returns false if the name is not an xdstp:// name of the type.
+func ParseXdstpResourceName(typeURL, name string) (authority, id string, ok bool) {
+	rest, f := strings.CutPrefix(name, xdstpScheme)
+	if !f {
+		return "", "", false
+	}
+	authority, rest, f = strings.Cut(rest, "/")
+	if !f {
+		return "", "", false
+	}
+	rest, f = strings.CutPrefix(rest, strings.TrimPrefix(typeURL, resource.APITypePrefix)+"/")
+	if !f {
+		return "", "", false
+	}
+	id, _, _ = strings.Cut(rest, "?")
+	if id == "" {
+		return "", "", false
+	}
+	return authority, id, true
+}
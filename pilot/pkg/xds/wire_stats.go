@@ -0,0 +1,161 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/grpc/stats"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+// WireBytes counts the bytes of the xDS responses sent on a stream.
+type WireBytes struct {
+	// Uncompressed is the size of the serialized responses.
+	Uncompressed int64 `json:"uncompressed"`
+	// Compressed is the size of the responses as sent on the wire, after compression and with the gRPC framing.
+	Compressed int64 `json:"compressed"`
+}
+
+// wireStats tracks the bytes sent on an xDS stream, per type.
+type wireStats struct {
+	mu     sync.Mutex
+	byType map[string]WireBytes
+}
+
+func (w *wireStats) record(typeURL string, uncompressed, compressed int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	b := w.byType[typeURL]
+	b.Uncompressed += int64(uncompressed)
+	b.Compressed += int64(compressed)
+	w.byType[typeURL] = b
+}
+
+func (w *wireStats) snapshot() map[string]WireBytes {
+	out := map[string]WireBytes{}
+	if w == nil {
+		return out
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for k, v := range w.byType {
+		out[v3.GetShortType(k)] = v
+	}
+	return out
+}
+
+type wireStatsKey struct{}
+
+// wireStatsFromContext returns the wireStats of the stream, if the gRPC server uses the WireStatsHandler.
+func wireStatsFromContext(ctx context.Context) *wireStats {
+	w, _ := ctx.Value(wireStatsKey{}).(*wireStats)
+	return w
+}
+
+// WireStatsHandler is a gRPC stats.Handler recording the exact bytes of the xDS responses sent on ADS streams.
+// The counts are kept per connection, to find the proxies receiving the most configuration.
+type WireStatsHandler struct{}
+
+var _ stats.Handler = WireStatsHandler{}
+
+// TagRPC attaches the wireStats of ADS streams to their context.
+func (WireStatsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	if !strings.HasPrefix(info.FullMethodName, "/envoy.service.discovery.v3.AggregatedDiscoveryService/") {
+		return ctx
+	}
+	return context.WithValue(ctx, wireStatsKey{}, &wireStats{byType: map[string]WireBytes{}})
+}
+
+// HandleRPC records the size of the responses sent.
+func (WireStatsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	out, ok := s.(*stats.OutPayload)
+	if !ok || out.IsClient() {
+		return
+	}
+	var typeURL string
+	switch res := out.Payload.(type) {
+	case *discovery.DiscoveryResponse:
+		typeURL = res.TypeUrl
+	case *discovery.DeltaDiscoveryResponse:
+		typeURL = res.TypeUrl
+	default:
+		return
+	}
+	if w := wireStatsFromContext(ctx); w != nil {
+		w.record(typeURL, out.Length, out.WireLength)
+	}
+	metricType := typeTag.Value(v3.GetMetricType(typeURL))
+	wireBytes.With(metricType, compressionTag.Value("uncompressed")).RecordInt(int64(out.Length))
+	wireBytes.With(metricType, compressionTag.Value("compressed")).RecordInt(int64(out.WireLength))
+}
+
+func (WireStatsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (WireStatsHandler) HandleConn(context.Context, stats.ConnStats) {}
+
+// ProxyWireBytes describes the bytes sent to a connected proxy, for debugging.
+type ProxyWireBytes struct {
+	ConnectionID string               `json:"connectionId"`
+	Total        WireBytes            `json:"total"`
+	Types        map[string]WireBytes `json:"types"`
+}
+
+// wireBytesz lists the connected proxies which were sent the most bytes, as measured on the wire.
+// It is mapped to /debug/wire_bytes, and accepts an optional `top` query parameter, defaulting to 20.
+func (s *DiscoveryServer) wireBytesz(w http.ResponseWriter, req *http.Request) {
+	top := 20
+	if t := req.URL.Query().Get("top"); t != "" {
+		n, err := strconv.Atoi(t)
+		if err != nil || n <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("top must be a positive integer\n"))
+			return
+		}
+		top = n
+	}
+	writeJSON(w, topWireBytes(s.Clients(), top), req)
+}
+
+func topWireBytes(connections []*Connection, top int) []ProxyWireBytes {
+	out := make([]ProxyWireBytes, 0, len(connections))
+	for _, con := range connections {
+		p := ProxyWireBytes{ConnectionID: con.conID, Types: con.wireStats.snapshot()}
+		for _, b := range p.Types {
+			p.Total.Uncompressed += b.Uncompressed
+			p.Total.Compressed += b.Compressed
+		}
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Total.Compressed != out[j].Total.Compressed {
+			return out[i].Total.Compressed > out[j].Total.Compressed
+		}
+		return out[i].ConnectionID < out[j].ConnectionID
+	})
+	if len(out) > top {
+		out = out[:top]
+	}
+	return out
+}
@@ -0,0 +1,43 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestWireBytes(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	ads := s.ConnectADS()
+	res := ads.RequestResponseAck(t, &discovery.DiscoveryRequest{TypeUrl: v3.ClusterType})
+	ads.RequestResponseAck(t, &discovery.DiscoveryRequest{TypeUrl: v3.ListenerType})
+	s.ConnectADS().RequestResponseAck(t, &discovery.DiscoveryRequest{TypeUrl: v3.ClusterType})
+
+	top := topWireBytes(s.Discovery.Clients(), 1)
+	assert.Equal(t, len(top), 1)
+	cds := top[0].Types["CDS"]
+	if cds.Uncompressed < int64(len(res.Resources)) || cds.Compressed <= cds.Uncompressed {
+		t.Fatalf("unexpected CDS bytes %+v, expected the gRPC framing on top of the payload", cds)
+	}
+	if top[0].Total.Compressed != cds.Compressed+top[0].Types["LDS"].Compressed {
+		t.Fatalf("unexpected total %+v for types %+v", top[0].Total, top[0].Types)
+	}
+	assert.Equal(t, len(topWireBytes(s.Discovery.Clients(), 10)), 2)
+}
@@ -15,13 +15,19 @@
 package xds
 
 import (
+	"net/netip"
+
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/proto"
 
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/util/protoconv"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/network"
 	"istio.io/istio/pkg/util/sets"
+	"istio.io/istio/pkg/workloadapi"
 )
 
 type WorkloadGenerator struct {
@@ -91,6 +97,7 @@ func (e WorkloadGenerator) GenerateDeltas(
 
 	have := sets.New[string]()
 	for _, addr := range addrs {
+		addr = withNetworkGateway(addr, req.Push)
 		aliases := addr.Aliases()
 		n := addr.ResourceName()
 		have.Insert(n)
@@ -126,6 +133,39 @@ func (e WorkloadGenerator) GenerateDeltas(
 	return resources, removed, model.XdsLogDetails{}, true, nil
 }
 
+// withNetworkGateway sets the east-west gateway through which a workload on a remote network is reached, if the
+// gateway supports HBONE. ztunnel then tunnels the traffic to the gateway, which forwards the inner HBONE connection
+// to the workload, preserving the identity of the client.
+func withNetworkGateway(addr *model.AddressInfo, push *model.PushContext) *model.AddressInfo {
+	wl := addr.GetWorkload()
+	if wl == nil || wl.GetNetwork() == "" || wl.GetNetworkGateway() != nil || push == nil || push.NetworkManager() == nil {
+		return addr
+	}
+	gateways := push.NetworkManager().GatewaysForNetworkAndCluster(network.ID(wl.GetNetwork()), cluster.ID(wl.GetClusterId()))
+	if len(gateways) == 0 {
+		gateways = push.NetworkManager().GatewaysForNetwork(network.ID(wl.GetNetwork()))
+	}
+	for _, gw := range gateways {
+		if gw.HBONEPort == 0 {
+			continue
+		}
+		ip, err := netip.ParseAddr(gw.Addr)
+		if err != nil {
+			continue
+		}
+		wl = proto.Clone(wl).(*workloadapi.Workload)
+		wl.NetworkGateway = &workloadapi.GatewayAddress{
+			Destination: &workloadapi.GatewayAddress_Address{Address: &workloadapi.NetworkAddress{
+				Network: gw.Network.String(),
+				Address: ip.AsSlice(),
+			}},
+			Port: gw.HBONEPort,
+		}
+		return &model.AddressInfo{Address: &workloadapi.Address{Type: &workloadapi.Address_Workload{Workload: wl}}}
+	}
+	return addr
+}
+
 func (e WorkloadGenerator) Generate(proxy *model.Proxy, w *model.WatchedResource, req *model.PushRequest) (model.Resources, model.XdsLogDetails, error) {
 	resources, _, details, _, err := e.GenerateDeltas(proxy, req, w)
 	return resources, details, err
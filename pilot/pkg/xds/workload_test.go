@@ -16,6 +16,8 @@ package xds
 
 import (
 	"context"
+	"fmt"
+	"net/netip"
 	"testing"
 	"time"
 
@@ -24,6 +26,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 
+	"istio.io/api/label"
 	"istio.io/api/security/v1beta1"
 	metav1beta1 "istio.io/api/type/v1beta1"
 	"istio.io/istio/pilot/pkg/features"
@@ -35,7 +38,9 @@ import (
 	"istio.io/istio/pkg/kube/kclient/clienttest"
 	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/test/util/retry"
 	"istio.io/istio/pkg/util/sets"
+	"istio.io/istio/pkg/workloadapi"
 )
 
 func buildExpect(t *testing.T) func(resp *discovery.DeltaDiscoveryResponse, names ...string) {
@@ -406,3 +411,74 @@ func TestWorkloadPeerAuthentication(t *testing.T) {
 	createPod(s, "pod", "sa", "127.0.0.1", "node")
 	ads.ExpectNoResponse()
 }
+
+func TestWorkloadNetworkGateway(t *testing.T) {
+	gateway := func(ports ...int32) *corev1.Service {
+		svc := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "eastwest", Namespace: "istio-system", Labels: map[string]string{
+				label.TopologyNetwork.Name: "nw2",
+			}},
+			Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer, ClusterIP: "10.0.0.10"},
+			Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{Ingress: []corev1.LoadBalancerIngress{{
+				IP: "2.2.2.2",
+			}}}},
+		}
+		for _, p := range ports {
+			svc.Spec.Ports = append(svc.Spec.Ports, corev1.ServicePort{Name: fmt.Sprint(p), Port: p, Protocol: corev1.ProtocolTCP})
+		}
+		return svc
+	}
+	workload := func(nw string) *model.AddressInfo {
+		return &model.AddressInfo{Address: &workloadapi.Address{Type: &workloadapi.Address_Workload{Workload: &workloadapi.Workload{
+			Uid:       "Kubernetes//Pod/ns/pod",
+			Network:   nw,
+			ClusterId: "Kubernetes",
+		}}}}
+	}
+	tests := []struct {
+		name    string
+		gateway *corev1.Service
+		addr    *model.AddressInfo
+		want    *workloadapi.GatewayAddress
+	}{
+		{
+			name:    "hbone gateway",
+			gateway: gateway(15443, 15008),
+			addr:    workload("nw2"),
+			want: &workloadapi.GatewayAddress{
+				Destination: &workloadapi.GatewayAddress_Address{Address: &workloadapi.NetworkAddress{
+					Network: "nw2",
+					Address: netip.MustParseAddr("2.2.2.2").AsSlice(),
+				}},
+				Port: 15008,
+			},
+		},
+		{
+			name:    "gateway without hbone",
+			gateway: gateway(15443),
+			addr:    workload("nw2"),
+		},
+		{
+			name:    "network without gateway",
+			gateway: gateway(15443, 15008),
+			addr:    workload("nw1"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewFakeDiscoveryServer(t, FakeOptions{KubernetesObjects: []runtime.Object{tt.gateway}})
+			retry.UntilSuccessOrFail(t, func() error {
+				if len(s.Env().NetworkManager.AllGateways()) == 0 {
+					return fmt.Errorf("no gateways")
+				}
+				return nil
+			}, retry.Timeout(5*time.Second))
+			push := s.PushContext()
+			got := withNetworkGateway(tt.addr, push)
+			assert.Equal(t, got.GetWorkload().GetNetworkGateway(), tt.want)
+			if tt.addr.GetWorkload().GetNetworkGateway() != nil {
+				t.Fatalf("the original workload was modified")
+			}
+		})
+	}
+}
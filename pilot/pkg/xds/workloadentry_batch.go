@@ -0,0 +1,95 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/config/validation"
+	"istio.io/istio/pkg/util/protomarshal"
+)
+
+// workloadEntryBatchItem is a single WorkloadEntry of a posted batch.
+type workloadEntryBatchItem struct {
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Spec        json.RawMessage   `json:"spec"`
+}
+
+// workloadEntryBatchz replaces the WorkloadEntries of a fleet with the posted JSON list of entries.
+func (s *DiscoveryServer) workloadEntryBatchz(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = w.Write([]byte("The workload entries of the fleet must be posted\n"))
+		return
+	}
+	if s.ApplyWorkloadEntryBatch == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("WorkloadEntry batches are not supported by this server\n"))
+		return
+	}
+	namespace, fleet := req.URL.Query().Get("namespace"), req.URL.Query().Get("fleet")
+	if namespace == "" || fleet == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("You must provide a namespace and fleet in the query string\n"))
+		return
+	}
+	var items []workloadEntryBatchItem
+	if err := json.NewDecoder(req.Body).Decode(&items); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(w, "Invalid batch: %v\n", err)
+		return
+	}
+	entries := make([]config.Config, 0, len(items))
+	for _, item := range items {
+		wle := &networking.WorkloadEntry{}
+		if err := protomarshal.Unmarshal(item.Spec, wle); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(w, "Invalid workload entry %q: %v\n", item.Name, err)
+			return
+		}
+		cfg := config.Config{
+			Meta: config.Meta{
+				GroupVersionKind: gvk.WorkloadEntry,
+				Name:             item.Name,
+				Namespace:        namespace,
+				Labels:           item.Labels,
+				Annotations:      item.Annotations,
+			},
+			Spec: wle,
+		}
+		if _, err := validation.ValidateWorkloadEntry(cfg); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintf(w, "Invalid workload entry %q: %v\n", item.Name, err)
+			return
+		}
+		entries = append(entries, cfg)
+	}
+	res, err := s.ApplyWorkloadEntryBatch(namespace, fleet, entries)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+	log.WithLabels("audit", "workloadentry_batch", "fleet", namespace+"/"+fleet, "remote", req.RemoteAddr).
+		Infof("WorkloadEntry batch applied: %+v", res)
+	writeJSON(w, res, req)
+}
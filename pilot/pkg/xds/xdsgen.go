@@ -65,6 +65,10 @@ func ControlPlane() *core.ControlPlane {
 }
 
 func (s *DiscoveryServer) findGenerator(typeURL string, con *Connection) model.XdsResourceGenerator {
+	if con.proxy.Metadata.Generator == ReadOnlyGenerator {
+		// Read-only clients only get the types with a read-only generator.
+		return s.Generators[ReadOnlyGenerator+"/"+typeURL]
+	}
 	if g, f := s.Generators[con.proxy.Metadata.Generator+"/"+typeURL]; f {
 		return g
 	}
@@ -194,6 +194,9 @@ type ADSC struct {
 	// Last received message, by type
 	Received map[string]*discovery.DiscoveryResponse
 
+	// resourceNames are the resource names last requested with Send, by type, so that they are kept on ACKs.
+	resourceNames map[string][]string
+
 	mutex sync.RWMutex
 
 	Mesh *v1alpha1.MeshConfig
@@ -903,6 +906,12 @@ func (a *ADSC) node() *core.Node {
 
 // Raw send of a request.
 func (a *ADSC) Send(req *discovery.DiscoveryRequest) error {
+	a.mutex.Lock()
+	if a.resourceNames == nil {
+		a.resourceNames = map[string][]string{}
+	}
+	a.resourceNames[req.TypeUrl] = req.ResourceNames
+	a.mutex.Unlock()
 	if a.sendNodeMeta {
 		req.Node = a.node()
 		a.sendNodeMeta = false
@@ -1145,15 +1154,18 @@ func (a *ADSC) ack(msg *discovery.DiscoveryResponse) {
 		return
 	}
 
-	if msg.TypeUrl == v3.EndpointType {
+	switch msg.TypeUrl {
+	case v3.EndpointType:
 		for c := range a.edsClusters {
 			resources = append(resources, c)
 		}
-	}
-	if msg.TypeUrl == v3.RouteType {
+	case v3.RouteType:
 		for r := range a.routes {
 			resources = append(resources, r)
 		}
+	default:
+		// Keep the subscription to the resources requested with Send.
+		resources = a.resourceNames[msg.TypeUrl]
 	}
 
 	_ = a.stream.Send(&discovery.DiscoveryRequest{
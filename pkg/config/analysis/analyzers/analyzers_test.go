@@ -515,6 +515,18 @@ var testGrid = []testCase{
 		analyzer: &destinationrule.CaCertificateAnalyzer{},
 		expected: []message{},
 	},
+	{
+		name: "destinationrule panic threshold",
+		inputFiles: []string{
+			"testdata/destinationrule-panic-threshold.yaml",
+		},
+		analyzer: &destinationrule.PanicThresholdAnalyzer{},
+		expected: []message{
+			{msg.PanicThresholdOverridesOutlierDetection, "DestinationRule reviews-panic"},
+			{msg.InvalidAnnotation, "DestinationRule ratings-invalid"},
+			{msg.InvalidAnnotation, "DestinationRule ratings-invalid"},
+		},
+	},
 	{
 		name: "dupmatches",
 		inputFiles: []string{
@@ -0,0 +1,90 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package destinationrule
+
+import (
+	"strconv"
+
+	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/analysis"
+	"istio.io/istio/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+// PanicThresholdAnalyzer checks the panic threshold and overprovisioning factor annotations of DestinationRules,
+// and warns when the panic threshold overrides the minHealthPercent of the outlier detection.
+type PanicThresholdAnalyzer struct{}
+
+var _ analysis.Analyzer = &PanicThresholdAnalyzer{}
+
+func (p *PanicThresholdAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "destinationrule.PanicThresholdAnalyzer",
+		Description: "Checks the panic threshold and overprovisioning factor annotations of DestinationRules",
+		Inputs: []config.GroupVersionKind{
+			gvk.DestinationRule,
+		},
+	}
+}
+
+func (p *PanicThresholdAnalyzer) Analyze(ctx analysis.Context) {
+	ctx.ForEach(gvk.DestinationRule, func(r *resource.Instance) bool {
+		p.analyzeDestinationRule(r, ctx)
+		return true
+	})
+}
+
+func (p *PanicThresholdAnalyzer) analyzeDestinationRule(r *resource.Instance, ctx analysis.Context) {
+	dr := r.Message.(*v1alpha3.DestinationRule)
+
+	if v, f := r.Metadata.Annotations[constants.OverprovisioningFactorAnnotation]; f {
+		if factor, err := strconv.ParseUint(v, 10, 32); err != nil || factor < 100 {
+			ctx.Report(gvk.DestinationRule, msg.NewInvalidAnnotation(r, constants.OverprovisioningFactorAnnotation,
+				"value must be an integer percentage of at least 100"))
+		}
+	}
+
+	v, f := r.Metadata.Annotations[constants.PanicThresholdAnnotation]
+	if !f {
+		return
+	}
+	if threshold, err := strconv.ParseFloat(v, 64); err != nil || threshold < 0 || threshold > 100 {
+		ctx.Report(gvk.DestinationRule, msg.NewInvalidAnnotation(r, constants.PanicThresholdAnnotation,
+			"value must be a percentage between 0 and 100"))
+		return
+	}
+	// The panic threshold applies to all the clusters of the DestinationRule, including the port level
+	// and subset ones, so any outlier detection setting its own minHealthPercent is overridden.
+	policies := []*v1alpha3.TrafficPolicy{dr.GetTrafficPolicy()}
+	for _, subset := range dr.GetSubsets() {
+		policies = append(policies, subset.GetTrafficPolicy())
+	}
+	for _, policy := range policies {
+		outliers := []*v1alpha3.OutlierDetection{policy.GetOutlierDetection()}
+		for _, pls := range policy.GetPortLevelSettings() {
+			outliers = append(outliers, pls.GetOutlierDetection())
+		}
+		for _, od := range outliers {
+			if od.GetMinHealthPercent() > 0 {
+				ctx.Report(gvk.DestinationRule, msg.NewPanicThresholdOverridesOutlierDetection(r,
+					r.Metadata.FullName.String(), v, int(od.GetMinHealthPercent())))
+				return
+			}
+		}
+	}
+}
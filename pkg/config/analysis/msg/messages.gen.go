@@ -232,6 +232,10 @@ var (
 	// InvalidGatewayCredential defines a diag.MessageType for message "InvalidGatewayCredential".
 	// Description: The credential provided for the Gateway resource is invalid
 	InvalidGatewayCredential = diag.NewMessageType(diag.Error, "IST0161", "The credential referenced by the Gateway %s in namespace %s is invalid, which can cause the traffic not to work as expected.")
+
+	// PanicThresholdOverridesOutlierDetection defines a diag.MessageType for message "PanicThresholdOverridesOutlierDetection".
+	// Description: The panic threshold annotation of a DestinationRule overrides the minHealthPercent of its outlier detection
+	PanicThresholdOverridesOutlierDetection = diag.NewMessageType(diag.Warning, "IST0162", "The DestinationRule %s sets the panic threshold to %s%%, which overrides outlierDetection.minHealthPercent (%d%%). With a threshold of 0, traffic fails fast once all endpoints are ejected; with a higher threshold, it is sent to all endpoints, including ejected ones, below that percentage of healthy endpoints.")
 )
 
 // All returns a list of all known message types.
@@ -293,6 +297,7 @@ func All() []*diag.MessageType {
 		ConflictingTelemetryWorkloadSelectors,
 		MultipleTelemetriesWithoutWorkloadSelectors,
 		InvalidGatewayCredential,
+		PanicThresholdOverridesOutlierDetection,
 	}
 }
 
@@ -846,3 +851,14 @@ func NewInvalidGatewayCredential(r *resource.Instance, gatewayName string, gatew
 		gatewayNamespace,
 	)
 }
+
+// NewPanicThresholdOverridesOutlierDetection returns a new diag.Message based on PanicThresholdOverridesOutlierDetection.
+func NewPanicThresholdOverridesOutlierDetection(r *resource.Instance, destinationRule string, panicThreshold string, minHealthPercent int) diag.Message {
+	return diag.NewMessage(
+		PanicThresholdOverridesOutlierDetection,
+		r,
+		destinationRule,
+		panicThreshold,
+		minHealthPercent,
+	)
+}
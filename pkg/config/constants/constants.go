@@ -131,6 +131,174 @@ const (
 	// Label to skip config comparison.
 	AlwaysPushLabel = "internal.istio.io/always-push"
 
+	// ClientSideWeightedRoundRobinAnnotation, when set to "true" on a DestinationRule, configures Envoy's
+	// client side weighted round robin load balancer for the clusters of the rule. The endpoints are expected
+	// to report their utilization using ORCA load reports, which are used to compute the endpoint weights.
+	ClientSideWeightedRoundRobinAnnotation = "networking.istio.io/client-side-weighted-round-robin"
+
+	// PanicThresholdAnnotation sets the healthy panic threshold of the clusters of a DestinationRule, as a percentage
+	// between 0 and 100. When the percentage of healthy endpoints falls below the threshold, Envoy ignores the health
+	// status and balances across all endpoints. "0" disables the panic mode, failing fast instead.
+	PanicThresholdAnnotation = "networking.istio.io/panic-threshold"
+
+	// OverprovisioningFactorAnnotation sets the overprovisioning factor of the clusters of a DestinationRule, as a
+	// percentage of at least 100. It controls how early traffic spills over to lower priorities as endpoints become
+	// unhealthy: with the Envoy default of 140, a priority keeps all traffic while at least 72% of its endpoints are healthy.
+	OverprovisioningFactorAnnotation = "networking.istio.io/overprovisioning-factor"
+
+	// FailoverPrewarmAnnotation sets the percentage of requests of the clusters of a DestinationRule sent to the
+	// localities of the first failover priority, between 0 and 100 exclusive. The trickle keeps their connection
+	// pools and TLS sessions warm, so that failing over to them does not cause a latency spike. It only applies when
+	// locality failover is in use.
+	FailoverPrewarmAnnotation = "networking.istio.io/failover-prewarm-percentage"
+
+	// LocalitySpilloverAnnotation sets the utilization, in percent of its capacity, up to which the localities of
+	// the priority 0 of a DestinationRule keep the traffic of their clients. Beyond it, the overflow is progressively
+	// shifted to the localities of the first failover priority. The utilization is estimated from the endpoint
+	// weights, assuming every locality with endpoints originates an equal share of the requests. It only applies
+	// when locality failover is in use. It stands in for a LocalityLoadBalancerSetting field, which the
+	// networking API does not define.
+	LocalitySpilloverAnnotation = "networking.istio.io/locality-spillover-threshold"
+
+	// MirrorToAnnotation names a subset of a DestinationRule to mirror the traffic of its host to. The mirrored
+	// requests are sent to a shadow cluster of the subset endpoints, keeping their stats apart from the subset
+	// cluster. Routes that already mirror their traffic are left unchanged.
+	MirrorToAnnotation = "networking.istio.io/mirror-to"
+
+	// MirrorPercentageAnnotation sets the percentage of requests mirrored by MirrorToAnnotation, between 0 and 100.
+	// All requests are mirrored by default.
+	MirrorPercentageAnnotation = "networking.istio.io/mirror-percentage"
+
+	// SubsetLoadBalancerAnnotation, when set to "true" on a DestinationRule, selects the subsets of the rule with
+	// Envoy's subset load balancer. The endpoints of the default cluster are tagged with the subsets they belong to,
+	// and the HTTP routes to a subset match them through their metadata. The traffic policies of the subsets are not
+	// applied to these routes, as their endpoints share the default cluster. The subset clusters are still built for
+	// the references which cannot carry a metadata match, such as mirrors, TCP routes and proxyless gRPC.
+	SubsetLoadBalancerAnnotation = "networking.istio.io/subset-load-balancer"
+
+	// RetryHostPredicatesAnnotation sets the host predicates of the retries of the routes to the host of a
+	// DestinationRule, as a comma separated list replacing the default `previous-hosts`. `previous-hosts` avoids the
+	// endpoints already attempted, and `omit-host-metadata:<key>=<value>` avoids the endpoints with the given
+	// `envoy.lb` metadata, for example `omit-host-metadata:istio.io/cohort=canary`.
+	RetryHostPredicatesAnnotation = "networking.istio.io/retry-host-predicates"
+
+	// EndpointPushPolicyAnnotation controls how endpoint updates of a Service are pushed. The value is either
+	// "immediate", to push without waiting for the debounce period, or a duration such as "10s", to coalesce the
+	// endpoint updates of the Service for at least that long.
+	EndpointPushPolicyAnnotation = "networking.istio.io/endpoint-push-policy"
+
+	// IncludeTerminatingAnnotation is a Service annotation controlling whether the endpoints of terminating pods which
+	// are still serving are sent: IncludeTerminatingAlways, IncludeTerminatingWhenNoReady, when the service has no
+	// ready endpoint, or IncludeTerminatingNever. It overrides PILOT_INCLUDE_TERMINATING_ENDPOINTS.
+	IncludeTerminatingAnnotation  = "networking.istio.io/include-terminating"
+	IncludeTerminatingAlways      = "Always"
+	IncludeTerminatingWhenNoReady = "WhenNoReady"
+	IncludeTerminatingNever       = "Never"
+
+	// CrossNetworkFailoverAnnotation is a Service annotation controlling how the endpoints of remote networks,
+	// reached through their network gateways, are load balanced: CrossNetworkFailoverWeighted, along with the local
+	// endpoints according to their weights, or CrossNetworkFailoverPriority, at a lower priority than the local
+	// endpoints so that they only receive traffic when the local endpoints are unhealthy. It overrides
+	// PILOT_CROSS_NETWORK_FAILOVER.
+	CrossNetworkFailoverAnnotation = "networking.istio.io/cross-network-failover"
+	CrossNetworkFailoverWeighted   = "Weighted"
+	CrossNetworkFailoverPriority   = "Priority"
+
+	// DirectPodClustersAnnotation is a Service annotation which, when set to "true", adds a cluster per endpoint of
+	// the service, routed from the endpoint address. Clients addressing specific pods, such as Prometheus scraping
+	// through its sidecar, get the endpoint metadata from EDS, including mTLS, instead of going through the
+	// PassthroughCluster. As each endpoint adds a cluster and a virtual host to every client, and the changes of the
+	// endpoint addresses trigger full pushes, it is meant for small services: the ports with more endpoints than
+	// PILOT_MAX_DIRECT_POD_CLUSTERS get no pod cluster.
+	DirectPodClustersAnnotation = "networking.istio.io/direct-pod-clusters"
+
+	// PrecomputeEndpointsAnnotation is a Service annotation which, when set to "false", disables the reuse of the
+	// Envoy endpoints precomputed for the endpoints of the service, which are then rebuilt on every push. It is
+	// meant for services whose endpoints are modified by extensions, which the precomputed endpoints may miss.
+	PrecomputeEndpointsAnnotation = "networking.istio.io/precompute-endpoints"
+
+	// RolloutWeightAnnotation is a Deployment annotation setting the percentage of the traffic of the services
+	// selecting its pods sent to them, between 0 and 100, when PILOT_ENABLE_ROLLOUT_WEIGHTS is set. The endpoints of
+	// the Deployment are weighted accordingly in each locality, so that a new revision fronted by the same Service
+	// can be canaried gradually without route changes.
+	RolloutWeightAnnotation = "networking.istio.io/rollout-weight"
+
+	// WorkloadEntryPortLabelsAnnotation is a WorkloadEntry annotation declaring additional labels for some of its
+	// ports, as a JSON object mapping port names to labels, e.g. {"admin": {"role": "admin"}}. The labels are added
+	// to the endpoints of the port only, so that a workload exposing several ports can be in different subsets
+	// for each of them.
+	WorkloadEntryPortLabelsAnnotation = "networking.istio.io/port-labels"
+
+	// UpstreamSocketOptionsAnnotation is a WorkloadEntry or ServiceEntry annotation setting the TCP socket options of
+	// the upstream connections to its endpoints, as a comma separated list of <option>=<value> among keepalive-time
+	// and keepalive-interval, whole second durations such as "30s", and keepalive-probes, a count. The annotation of a
+	// ServiceEntry applies to its inline endpoints. The options are added to the istio metadata of the endpoints,
+	// consumed by the transport sockets matching them and passed through to internal listeners.
+	UpstreamSocketOptionsAnnotation = "networking.istio.io/upstream-socket-options"
+
+	// EndpointTTLAnnotation is a WorkloadEntry or ServiceEntry annotation setting the TTL of its endpoints, as a
+	// duration such as "5m". The endpoints not refreshed by an update of the endpoints of their service within the TTL
+	// are marked unhealthy, and removed after another TTL period. This protects the mesh from the stale entries of
+	// external registries pushing WorkloadEntries or ServiceEntries, e.g. over MCP, which are expected to refresh
+	// them periodically. The annotation of a ServiceEntry applies to its inline endpoints.
+	EndpointTTLAnnotation = "networking.istio.io/endpoint-ttl"
+
+	// DataResidencyLabel tags the endpoints of a pod, a node or a WorkloadEntry with the jurisdiction its data must
+	// stay in, e.g. "eu". A pod label takes precedence over the label of its node. PILOT_DATA_RESIDENCY_POLICY
+	// restricts the client regions the endpoints of each jurisdiction are sent to.
+	DataResidencyLabel = "topology.istio.io/data-residency"
+
+	// ExternalNameResolutionAnnotation controls how an ExternalName Service is resolved. With the value
+	// ExternalNameResolutionEDS, istiod resolves the external name and serves the addresses through EDS,
+	// so that DestinationRule policies such as outlier detection and locality load balancing apply.
+	ExternalNameResolutionAnnotation = "networking.istio.io/external-name-resolution"
+	ExternalNameResolutionEDS        = "eds"
+
+	// AddressTypeAnnotation is a Service or Pod annotation selecting the address through which the endpoints are
+	// reached: AddressTypePodIP, the default, or AddressTypeHostIP, the address of the node of the pod with the host
+	// port of the container port, for workloads which are not reachable through their pod IP. The annotation of a
+	// pod takes precedence over the one of its services.
+	AddressTypeAnnotation = "traffic.istio.io/address-type"
+	AddressTypePodIP      = "PodIP"
+	AddressTypeHostIP     = "HostIP"
+
+	// ScaleUpRampAnnotation is a Service annotation holding a duration, such as "2m". When the endpoints of the
+	// service at least double at once, the new endpoints are introduced in waves over that window, with ramping
+	// weights, so that stateful backends are not hit by a connection storm. Requires PILOT_ENABLE_SCALE_UP_RAMP.
+	ScaleUpRampAnnotation = "networking.istio.io/scale-up-ramp"
+
+	// CapacityRPSAnnotation is a Pod annotation holding the requests per second budget of the pod, such as "500". It
+	// is added to the load balancer metadata of its endpoints, for adaptive concurrency and client-side weighted round
+	// robin extensions.
+	CapacityRPSAnnotation = "networking.istio.io/capacity-rps"
+
+	// ClusterWeightsAnnotation splits the traffic of the host of a DestinationRule across the clusters of its
+	// endpoints, as a comma separated list of cluster weights such as "cluster-a=80,cluster-b=20". The endpoint weights
+	// are scaled so that each cluster receives its share of the traffic, in each priority. The clusters weighted zero, or
+	// not listed, keep a marginal share of the traffic. There is no mesh-wide default: MeshConfig has no field for
+	// cluster weights.
+	ClusterWeightsAnnotation = "networking.istio.io/cluster-weights"
+
+	// InternalTrafficPolicyAnnotation is a Service annotation relaxing the internal traffic policy of the service. With
+	// the value InternalTrafficPolicyPreferLocal, the endpoints on the node of the client are preferred, and the other
+	// endpoints only receive traffic once they are unhealthy or missing, so that node local caches served by daemonsets
+	// keep working while their local instance is down. It takes precedence over spec.internalTrafficPolicy.
+	InternalTrafficPolicyAnnotation  = "networking.istio.io/internal-traffic-policy"
+	InternalTrafficPolicyPreferLocal = "PreferLocal"
+
+	// TelemetryEndpointMetadataAnnotation is a Telemetry annotation requesting endpoint metadata for the telemetry
+	// providers of the workloads it selects, such as WASM extensions recording upstream peer metadata. It is a comma
+	// separated list of endpoint label keys; the values of these labels are added to the endpoints sent to the selected
+	// workloads, under the istio.telemetry filter metadata. The keys requested by the root namespace, namespace and
+	// workload Telemetries are combined.
+	TelemetryEndpointMetadataAnnotation = "telemetry.istio.io/endpoint-metadata"
+
+	// TrafficCutAnnotation cuts the traffic of the host of a DestinationRule to its endpoints matching a label
+	// selector, such as `version=blue`, as a comma separated list of <label>=<value>. The matching endpoints are left
+	// out of the clusters of the host in all clusters of the mesh at once, flipping their weight to zero, for
+	// blue/green cutovers. The cut applies even if no other endpoint remains, e.g. for the subset of the endpoints cut.
+	TrafficCutAnnotation = "networking.istio.io/traffic-cut"
+
 	// InternalParentNames declares the original resources of an internally-generate config. This is used by k8s gateway-api.
 	// It is a comma separated list. For example, "HTTPRoute/foo.default,HTTPRoute/bar.default"
 	InternalParentNames    = "internal.istio.io/parents"
@@ -155,6 +323,61 @@ const (
 
 	WaypointServiceAccount = "istio.io/for-service-account"
 
+	// WaypointSelector is a waypoint annotation restricting the waypoint to the workloads of its namespace matching a
+	// comma separated list of key=value labels, so that a namespace can run several waypoints, each owning a subset
+	// of its workloads.
+	WaypointSelector = "istio.io/for-selector"
+
+	// WaypointFallbackLabel is a service label controlling how sidecars and gateways reach the service when
+	// its waypoints are unavailable. With the value WaypointFallbackDirect, the workloads are also sent directly,
+	// at a lower priority than through the waypoints. Note that this bypasses the policies applied by the waypoints.
+	WaypointFallbackLabel  = "istio.io/waypoint-fallback"
+	WaypointFallbackDirect = "direct"
+
+	// ProxyProtocolLabel is a workload label declaring that the workload expects connections to start with a PROXY
+	// protocol header, with the value ProxyProtocolV1 or ProxyProtocolV2. It is set on pods or WorkloadEntries.
+	ProxyProtocolLabel = "networking.istio.io/proxy-protocol"
+	ProxyProtocolV1    = "v1"
+	ProxyProtocolV2    = "v2"
+
+	// StandbyLabel is a workload label marking the endpoint as a standby when set to "true". It is set on pods, for
+	// example on the standby replicas of a StatefulSet, or on WorkloadEntries. Standby endpoints are sent at a lower
+	// priority than the other endpoints of the service, so they only receive traffic once those are unhealthy.
+	StandbyLabel = "networking.istio.io/standby"
+
+	// LocalityOverride is an annotation or label setting the locality of a WorkloadEntry, or of the WorkloadEntries
+	// auto-registered for a WorkloadGroup, as region/zone/subzone. As label values cannot contain `/`, labels use `.`
+	// as separator instead. It is used when the WorkloadEntry does not set its locality.
+	LocalityOverride = "topology.istio.io/locality"
+
+	// WeightFromMetadata is a WorkloadGroup annotation setting the load balancing weight of the WorkloadEntries
+	// auto-registered for it from a node metadata field reported by their proxies, as <field> or <field>*<factor>.
+	// For example, "CPUS*10" weights VMs started with ISTIO_META_CPUS=4 at 40. The weight of the template is used when
+	// the field is missing or not a positive number.
+	WeightFromMetadata = "networking.istio.io/weight-from-metadata"
+
+	// MaxWeight is a WorkloadGroup annotation capping the weights read from metadata as WeightFromMetadata, as they are
+	// reported by the VMs themselves. It defaults to 100.
+	MaxWeight = "networking.istio.io/max-weight"
+
+	// EndpointSelection is a Sidecar annotation restricting the endpoints sent to its proxies, as a JSON object with
+	// the excludeLocalities and excludeClusters fields. Its egress field restricts the endpoints of some services
+	// further, as a list of objects with the same fields and the hosts they apply to, in the namespace/dnsName format
+	// of the hosts of the Sidecar egress listeners, e.g.
+	// {"excludeClusters":["c3"],"egress":[{"hosts":["./*"],"excludeLocalities":["eu-west1"]}]}.
+	EndpointSelection = "networking.istio.io/endpoint-selection"
+
+	// PublishNotReadyAddresses is a DestinationRule annotation overriding how the not ready endpoints of services with
+	// publishNotReadyAddresses are sent: PublishNotReadyHealthy or PublishNotReadyUnhealthy.
+	PublishNotReadyAddresses = "networking.istio.io/publish-not-ready-addresses"
+	PublishNotReadyHealthy   = "healthy"
+	PublishNotReadyUnhealthy = "unhealthy"
+
+	// CompletedPodEndpointsExclude and CompletedPodEndpointsUnhealthy are the values of
+	// PILOT_COMPLETED_POD_ENDPOINTS, controlling how the endpoints of completed pods are handled.
+	CompletedPodEndpointsExclude   = "exclude"
+	CompletedPodEndpointsUnhealthy = "unhealthy"
+
 	ManagedGatewayLabel               = "gateway.istio.io/managed"
 	ManagedGatewayController          = "istio.io/gateway-controller"
 	UnmanagedGatewayController        = "istio.io/unmanaged-gateway"
@@ -37,6 +37,9 @@ func NewConfigMapWatcher(client kube.Client, namespace, name, key string, multiW
 			return
 		}
 		if meshNetworks != nil {
+			// The networks were validated along with their address translations.
+			translations, _ := mesh.ParseMeshNetworksAddressTranslations(cm.Data["meshNetworks"])
+			w.SetAddressTranslations(translations)
 			w.SetNetworks(meshNetworks)
 		}
 		if multiWatch {
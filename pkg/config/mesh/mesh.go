@@ -16,6 +16,7 @@ package mesh
 
 import (
 	"fmt"
+	"net/netip"
 	"os"
 	"time"
 
@@ -318,9 +319,82 @@ func ParseMeshNetworks(yaml string) (*meshconfig.MeshNetworks, error) {
 	if err := validation.ValidateMeshNetworks(&out); err != nil {
 		return nil, err
 	}
+	if _, err := ParseMeshNetworksAddressTranslations(yaml); err != nil {
+		return nil, err
+	}
 	return &out, nil
 }
 
+// NetworkAddressTranslation is a 1:1 NAT mapping of the addresses of the endpoints of a network, from a private prefix
+// to a public prefix of the same length, for proxies of other networks reaching them through the NAT. It is set by the
+// addressTranslations of the network in MeshNetworks:
+//
+//	networks:
+//	  edge:
+//	    addressTranslations:
+//	    - privateCidr: 10.0.0.0/24
+//	      publicCidr: 203.0.113.0/24
+//
+// The MeshNetworks API does not define the field, so it is parsed separately from the same document.
+type NetworkAddressTranslation struct {
+	Private netip.Prefix
+	Public  netip.Prefix
+}
+
+// ParseMeshNetworksAddressTranslations returns the address translations of the networks of the MeshNetworks YAML,
+// by network.
+func ParseMeshNetworksAddressTranslations(yml string) (map[string][]NetworkAddressTranslation, error) {
+	var raw struct {
+		Networks map[string]struct {
+			AddressTranslations []struct {
+				PrivateCIDR string `json:"privateCidr"`
+				PublicCIDR  string `json:"publicCidr"`
+			} `json:"addressTranslations"`
+		} `json:"networks"`
+	}
+	if err := yaml.Unmarshal([]byte(yml), &raw); err != nil {
+		return nil, multierror.Prefix(err, "failed to parse the address translations of the networks.")
+	}
+	var out map[string][]NetworkAddressTranslation
+	var errs error
+	for nw, n := range raw.Networks {
+		for _, t := range n.AddressTranslations {
+			private, err := netip.ParsePrefix(t.PrivateCIDR)
+			if err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("network %s: invalid private CIDR: %v", nw, err))
+				continue
+			}
+			public, err := netip.ParsePrefix(t.PublicCIDR)
+			if err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("network %s: invalid public CIDR: %v", nw, err))
+				continue
+			}
+			if private.Bits() != public.Bits() || private.Addr().Is4() != public.Addr().Is4() {
+				errs = multierror.Append(errs, fmt.Errorf("network %s: the CIDRs %s and %s must have the same length and address family",
+					nw, t.PrivateCIDR, t.PublicCIDR))
+				continue
+			}
+			if out == nil {
+				out = map[string][]NetworkAddressTranslation{}
+			}
+			out[nw] = append(out[nw], NetworkAddressTranslation{Private: private.Masked(), Public: public.Masked()})
+		}
+	}
+	if errs != nil {
+		return nil, errs
+	}
+	return out, nil
+}
+
+// ReadMeshNetworksAddressTranslations gets the address translations of the networks from a mesh networks config file.
+func ReadMeshNetworksAddressTranslations(filename string) (map[string][]NetworkAddressTranslation, error) {
+	yaml, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, multierror.Prefix(err, "cannot read networks config file")
+	}
+	return ParseMeshNetworksAddressTranslations(string(yaml))
+}
+
 // ReadMeshNetworks gets mesh networks configuration from a config file
 func ReadMeshNetworks(filename string) (*meshconfig.MeshNetworks, error) {
 	yaml, err := os.ReadFile(filename)
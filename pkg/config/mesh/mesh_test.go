@@ -16,6 +16,7 @@ package mesh_test
 
 import (
 	"fmt"
+	"net/netip"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -429,6 +430,49 @@ trustDomainAliases:
 	}
 }
 
+func TestParseMeshNetworksAddressTranslations(t *testing.T) {
+	yml := `
+networks:
+  edge:
+    endpoints:
+    - fromCidr: "10.0.0.0/16"
+    addressTranslations:
+    - privateCidr: 10.0.0.1/24
+      publicCidr: 203.0.113.0/24
+    - privateCidr: fd00::/64
+      publicCidr: 2001:db8::/64
+  core:
+    endpoints:
+    - fromRegistry: reg1
+`
+	// The address translations are not part of the MeshNetworks API, but do not prevent parsing it.
+	networks, err := mesh.ParseMeshNetworks(yml)
+	assert.NoError(t, err)
+	assert.Equal(t, len(networks.Networks), 2)
+
+	translations, err := mesh.ParseMeshNetworksAddressTranslations(yml)
+	assert.NoError(t, err)
+	assert.Equal(t, len(translations), 1)
+	assert.Equal(t, translations["edge"][0] == mesh.NetworkAddressTranslation{
+		Private: netip.MustParsePrefix("10.0.0.0/24"), Public: netip.MustParsePrefix("203.0.113.0/24"),
+	}, true)
+	assert.Equal(t, translations["edge"][1] == mesh.NetworkAddressTranslation{
+		Private: netip.MustParsePrefix("fd00::/64"), Public: netip.MustParsePrefix("2001:db8::/64"),
+	}, true)
+
+	for _, invalid := range []string{
+		"{privateCidr: 10.0.0.0/16, publicCidr: 203.0.113.0/24}",
+		"{privateCidr: 10.0.0.0/16, publicCidr: 2001:db8::/16}",
+		"{privateCidr: invalid, publicCidr: 203.0.113.0/24}",
+	} {
+		yml := "networks:\n  edge:\n    addressTranslations:\n    - " + invalid + "\n"
+		_, err := mesh.ParseMeshNetworksAddressTranslations(yml)
+		assert.Error(t, err)
+		_, err = mesh.ParseMeshNetworks(yml)
+		assert.Error(t, err)
+	}
+}
+
 func TestApplyMeshNetworksDefaults(t *testing.T) {
 	yml := `
 networks:
@@ -30,6 +30,8 @@ type NetworksHolder interface {
 	SetNetworks(*meshconfig.MeshNetworks)
 	Networks() *meshconfig.MeshNetworks
 	PrevNetworks() *meshconfig.MeshNetworks
+	SetAddressTranslations(map[string][]NetworkAddressTranslation)
+	AddressTranslations() map[string][]NetworkAddressTranslation
 }
 
 // NetworksWatcher watches changes to the mesh networks config.
@@ -46,6 +48,8 @@ type internalNetworkWatcher struct {
 	handlers     []func()
 	networks     *meshconfig.MeshNetworks
 	prevNetworks *meshconfig.MeshNetworks
+	// addressTranslations are the address translations of the networks, which are not part of MeshNetworks.
+	addressTranslations map[string][]NetworkAddressTranslation
 }
 
 // NewFixedNetworksWatcher creates a new NetworksWatcher that always returns the given config.
@@ -63,11 +67,17 @@ func NewNetworksWatcher(fileWatcher filewatcher.FileWatcher, filename string) (N
 		return nil, fmt.Errorf("failed to read mesh networks configuration from %q: %v", filename, err)
 	}
 
+	translations, err := ReadMeshNetworksAddressTranslations(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mesh networks configuration from %q: %v", filename, err)
+	}
+
 	networksdump, _ := protomarshal.ToJSONWithIndent(meshNetworks, "   ")
 	log.Infof("mesh networks configuration: %s", networksdump)
 
 	w := &internalNetworkWatcher{
-		networks: meshNetworks,
+		networks:            meshNetworks,
+		addressTranslations: translations,
 	}
 
 	// Watch the networks config file for changes and reload if it got modified
@@ -78,6 +88,12 @@ func NewNetworksWatcher(fileWatcher filewatcher.FileWatcher, filename string) (N
 			log.Warnf("failed to read mesh networks configuration from %q: %v", filename, err)
 			return
 		}
+		translations, err := ReadMeshNetworksAddressTranslations(filename)
+		if err != nil {
+			log.Warnf("failed to read mesh networks configuration from %q: %v", filename, err)
+			return
+		}
+		w.SetAddressTranslations(translations)
 		w.SetNetworks(meshNetworks)
 	})
 	return w, nil
@@ -125,6 +141,33 @@ func (w *internalNetworkWatcher) SetNetworks(meshNetworks *meshconfig.MeshNetwor
 	}
 }
 
+// AddressTranslations returns the latest address translations of the networks of the mesh.
+func (w *internalNetworkWatcher) AddressTranslations() map[string][]NetworkAddressTranslation {
+	if w == nil {
+		return nil
+	}
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.addressTranslations
+}
+
+// SetAddressTranslations will use the given address translations of the networks and notify all handlers of the change
+func (w *internalNetworkWatcher) SetAddressTranslations(translations map[string][]NetworkAddressTranslation) {
+	var handlers []func()
+
+	w.mutex.Lock()
+	if !reflect.DeepEqual(translations, w.addressTranslations) {
+		log.Infof("mesh networks address translations updated to: %v", translations)
+		w.addressTranslations = translations
+		handlers = append([]func(){}, w.handlers...)
+	}
+	w.mutex.Unlock()
+
+	for _, h := range handlers {
+		h()
+	}
+}
+
 // AddNetworksHandler registers a callback handler for changes to the mesh network config.
 func (w *internalNetworkWatcher) AddNetworksHandler(h func()) {
 	w.mutex.Lock()
@@ -19,6 +19,7 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"net/url"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
@@ -217,6 +218,9 @@ var BuildClientsFromConfig = func(kubeConfig []byte, clusterId cluster.ID) (kube
 	if err := sanitizeKubeConfig(*rawConfig, features.InsecureKubeConfigOptions); err != nil {
 		return nil, fmt.Errorf("kubeconfig is not allowed: %v", err)
 	}
+	if err := applyTunnel(rawConfig, features.RemoteClusterTunnelURL); err != nil {
+		return nil, fmt.Errorf("remote cluster tunnel is not valid: %v", err)
+	}
 
 	clientConfig := clientcmd.NewDefaultClientConfig(*rawConfig, &clientcmd.ConfigOverrides{})
 
@@ -230,6 +234,32 @@ var BuildClientsFromConfig = func(kubeConfig []byte, clusterId cluster.ID) (kube
 	return clients, nil
 }
 
+// applyTunnel routes the clusters of the kubeconfig that do not set a proxy-url through the tunnel proxy.
+// client-go resumes the watches through the tunnel from their last resource version when it reconnects.
+func applyTunnel(config *api.Config, tunnel string) error {
+	if tunnel == "" {
+		return nil
+	}
+	u, err := url.Parse(tunnel)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("missing host in %q", u.Redacted())
+	}
+	for _, c := range config.Clusters {
+		if c.ProxyURL == "" {
+			c.ProxyURL = tunnel
+		}
+	}
+	return nil
+}
+
 // sanitizeKubeConfig sanitizes a kubeconfig file to strip out insecure settings which may leak
 // confidential materials.
 // See https://github.com/kubernetes/kubectl/issues/697
@@ -29,6 +29,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 
 	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/schema/collection"
 	"istio.io/istio/pkg/config/schema/resource"
 	"istio.io/istio/pkg/config/validation"
@@ -75,6 +76,10 @@ type Options struct {
 
 	// Use an existing mux instead of creating our own.
 	Mux *http.ServeMux
+
+	// Check optionally checks the configurations passing validation against the current state of the mesh. Its
+	// warnings are returned in the admission response and its errors reject the configuration.
+	Check func(config.Config) (validation.Warning, error)
 }
 
 // String produces a stringified version of the arguments for debugging.
@@ -99,6 +104,7 @@ type Webhook struct {
 	// pilot
 	schemas      collection.Schemas
 	domainSuffix string
+	check        func(config.Config) (validation.Warning, error)
 }
 
 // New creates a new instance of the admission webhook server.
@@ -110,6 +116,7 @@ func New(o Options) (*Webhook, error) {
 	wh := &Webhook{
 		schemas:      o.Schemas,
 		domainSuffix: o.DomainSuffix,
+		check:        o.Check,
 	}
 
 	o.Mux.HandleFunc("/validate", wh.serveValidate)
@@ -244,6 +251,18 @@ func (wh *Webhook) validate(request *kube.AdmissionRequest) *kube.AdmissionRespo
 		return toAdmissionResponse(err)
 	}
 
+	if wh.check != nil {
+		checkWarnings, err := wh.check(*out)
+		if err != nil {
+			scope.Infof("configuration is rejected: %v", addDryRunMessageIfNeeded(err.Error()))
+			reportValidationFailed(request, reasonCheckFailed, isDryRun)
+			return toAdmissionResponse(fmt.Errorf("configuration is rejected: %v", err))
+		}
+		if checkWarnings != nil {
+			warnings = multierror.Append(warnings, checkWarnings)
+		}
+	}
+
 	reportValidationPass(request)
 	return &kube.AdmissionResponse{Allowed: true, Warnings: toKubeWarnings(warnings)}
 }
@@ -17,6 +17,7 @@ package server
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -32,7 +33,9 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 
+	pkgconfig "istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/validation"
 	"istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/test/config"
 	"istio.io/istio/pkg/testcerts"
@@ -208,6 +211,36 @@ func TestAdmitPilot(t *testing.T) {
 	}
 }
 
+func TestAdmitPilotCheck(t *testing.T) {
+	wh := createTestWebhook(t)
+	var warning, err error
+	wh.check = func(pkgconfig.Config) (validation.Warning, error) {
+		return warning, err
+	}
+	request := &kube.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Kind: collections.Mock.Kind()},
+		Object:    runtime.RawExtension{Raw: makePilotConfig(t, 0, true, false)},
+		Operation: kube.Create,
+	}
+
+	got := wh.validate(request)
+	if !got.Allowed || len(got.Warnings) != 0 {
+		t.Fatalf("got allowed %v with warnings %v, want allowed without warnings", got.Allowed, got.Warnings)
+	}
+
+	warning = errors.New("no endpoints")
+	got = wh.validate(request)
+	if !got.Allowed || len(got.Warnings) != 1 || got.Warnings[0] != "no endpoints" {
+		t.Fatalf("got allowed %v with warnings %v, want allowed with the check warning", got.Allowed, got.Warnings)
+	}
+
+	err = errors.New("no endpoints")
+	got = wh.validate(request)
+	if got.Allowed || !strings.Contains(got.Result.Message, "no endpoints") {
+		t.Fatalf("got allowed %v with result %v, want rejected by the check", got.Allowed, got.Result)
+	}
+}
+
 func makeTestReview(t *testing.T, valid bool, apiVersion string) []byte {
 	t.Helper()
 	review := admissionv1.AdmissionReview{